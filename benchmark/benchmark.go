@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fastbrew/internal/paths"
 	"flag"
 	"fmt"
 	"math"
@@ -170,12 +171,7 @@ func runCommand(bin, dir string, args ...string) error {
 }
 
 func clearFastbrewCaches() error {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return err
-	}
-	cacheDir := filepath.Join(home, ".fastbrew", "cache")
-	return os.RemoveAll(cacheDir)
+	return os.RemoveAll(paths.CacheDir())
 }
 
 func warmupCaches(fastbrewPath, projectRoot string) error {
@@ -1,18 +1,13 @@
 package cmd
 
 import (
-	"bufio"
 	"fastbrew/internal/brew"
 	"fmt"
 	"os"
-	"path/filepath"
-	"strings"
 
 	"github.com/spf13/cobra"
 )
 
-var autoremoveDryRun bool
-
 var autoremoveCmd = &cobra.Command{
 	Use:   "autoremove",
 	Short: "Remove orphaned dependencies that are no longer needed",
@@ -20,175 +15,79 @@ var autoremoveCmd = &cobra.Command{
 but are no longer required by any installed formula.
 
 A package is considered orphaned if:
-- It is not a "leaf" package (i.e., it appears as a dependency of something)
-- No currently installed leaf package depends on it (directly or transitively)
+- It was installed as a dependency rather than requested by name
+- No explicitly installed package depends on it (directly or transitively)
+
+Install reasons are recorded by fastbrew install; for packages it did not
+install, the INSTALL_RECEIPT.json written by Homebrew is used. Packages
+with neither are treated as explicitly installed and never removed.
 
 Use --dry-run to preview what would be removed without actually removing anything.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if !dryRun {
+			defer lockFastbrew()()
+		}
+
 		client, err := newBrewClient()
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Error: %v\n", err)
+			os.Exit(exitCode(err))
 		}
 
-		orphans, err := findOrphanedPackages(client)
+		orphans, err := client.Orphans()
 		if err != nil {
-			fmt.Printf("Error finding orphaned packages: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Error finding orphaned packages: %v\n", err)
+			os.Exit(exitCode(err))
 		}
 
 		if len(orphans) == 0 {
-			fmt.Println("✅ No orphaned packages to remove.")
-			return
-		}
-
-		fmt.Printf("🔍 Found %d orphaned package(s):\n", len(orphans))
-		for _, pkg := range orphans {
-			fmt.Printf("  • %s\n", pkg)
-		}
-
-		if autoremoveDryRun {
-			fmt.Println("\n💡 Dry run - no packages were removed.")
-			fmt.Println("   Run without --dry-run to remove these packages.")
+			fmt.Fprintln(stdout, "✅ No orphaned packages to remove.")
 			return
 		}
 
-		// Prompt for confirmation
-		fmt.Printf("\n❓ Remove %d orphaned package(s)? [y/N]: ", len(orphans))
-		reader := bufio.NewReader(os.Stdin)
-		response, _ := reader.ReadString('\n')
-		response = strings.TrimSpace(strings.ToLower(response))
-
-		if response != "y" && response != "yes" {
-			fmt.Println("Cancelled.")
+		if dryRun {
+			for _, pkg := range orphans {
+				result, err := client.UninstallFormula(pkg, brew.UninstallOptions{DryRun: true})
+				if err != nil {
+					fmt.Fprintf(stdout, "❌ %s: %v\n", pkg, err)
+					continue
+				}
+				printUninstallResult(result)
+			}
+			fmt.Fprintln(stdout, "\n💡 Dry run - no packages were removed.")
+			fmt.Fprintln(stdout, "   Run without --dry-run to remove these packages.")
 			return
 		}
 
-		// Remove orphans
-		removed := 0
+		fmt.Fprintf(stdout, "🔍 Found %d orphaned package(s):\n", len(orphans))
 		for _, pkg := range orphans {
-			pkgPath := filepath.Join(client.Cellar, pkg)
-
-			// Unlink first while Cellar still exists
-			client.Unlink(pkg)
-
-			// Remove opt symlink
-			optLink := filepath.Join(client.Prefix, "opt", pkg)
-			if info, err := os.Lstat(optLink); err == nil && info.Mode()&os.ModeSymlink != 0 {
-				os.Remove(optLink)
-			}
-
-			// Then remove from Cellar
-			if err := os.RemoveAll(pkgPath); err != nil {
-				fmt.Printf("❌ Error removing %s: %v\n", pkg, err)
-				continue
-			}
-
-			fmt.Printf("✅ Removed %s\n", pkg)
-			removed++
-		}
-
-		fmt.Printf("\n🧹 Removed %d orphaned package(s).\n", removed)
-	},
-}
-
-// findOrphanedPackages identifies packages that were installed as dependencies
-// but are no longer needed by any installed leaf package.
-func findOrphanedPackages(client *brew.Client) ([]string, error) {
-	installed, err := client.ListInstalledNative()
-	if err != nil {
-		return nil, fmt.Errorf("failed to list installed packages: %w", err)
-	}
-
-	if len(installed) == 0 {
-		return nil, nil
-	}
-
-	idx, err := client.LoadIndex()
-	if err != nil {
-		return nil, fmt.Errorf("failed to load index: %w", err)
-	}
-
-	// Build formula lookup map
-	formulaMap := make(map[string]brew.Formula)
-	for _, f := range idx.Formulae {
-		formulaMap[f.Name] = f
-	}
-
-	// Build set of installed package names (formulae only, not casks)
-	installedSet := make(map[string]bool)
-	for _, pkg := range installed {
-		if !pkg.IsCask {
-			installedSet[pkg.Name] = true
+			fmt.Fprintf(stdout, "  • %s\n", pkg)
 		}
-	}
 
-	// Step 1: Find all packages that ARE dependencies of some installed package
-	// These are packages that appear in the dependency list of at least one installed package
-	isDependencyOf := make(map[string][]string) // pkg -> list of packages that depend on it
-	for _, pkg := range installed {
-		if pkg.IsCask {
-			continue
-		}
-		if f, ok := formulaMap[pkg.Name]; ok {
-			for _, dep := range f.Dependencies {
-				if installedSet[dep] {
-					isDependencyOf[dep] = append(isDependencyOf[dep], pkg.Name)
-				}
-			}
-		}
-	}
-
-	// Step 2: Find leaves - packages that are NOT dependencies of any other installed package
-	leaves := make(map[string]bool)
-	for _, pkg := range installed {
-		if pkg.IsCask {
-			continue
-		}
-		if len(isDependencyOf[pkg.Name]) == 0 {
-			leaves[pkg.Name] = true
+		ok, err := newPrompter().Confirm(fmt.Sprintf("Remove %d orphaned package(s)?", len(orphans)))
+		if err != nil {
+			fmt.Fprintf(stdout, "Error: %v\n", err)
+			os.Exit(exitCode(err))
 		}
-	}
-
-	// Step 3: Compute transitive dependencies of all leaves
-	// These are packages that are actually needed
-	needed := make(map[string]bool)
-	var markNeeded func(name string)
-	markNeeded = func(name string) {
-		if needed[name] {
+		if !ok {
+			fmt.Fprintln(stdout, "Cancelled.")
 			return
 		}
-		needed[name] = true
 
-		if f, ok := formulaMap[name]; ok {
-			for _, dep := range f.Dependencies {
-				if installedSet[dep] {
-					markNeeded(dep)
-				}
+		var removed []string
+		for _, pkg := range orphans {
+			result, err := client.UninstallFormula(pkg, brew.UninstallOptions{})
+			if err != nil {
+				fmt.Fprintf(stdout, "❌ Error removing %s: %v\n", pkg, err)
+				continue
 			}
+			printUninstallResult(result)
+			removed = append(removed, pkg)
 		}
-	}
-
-	// Mark all leaves and their dependencies as needed
-	for leaf := range leaves {
-		markNeeded(leaf)
-	}
-
-	// Step 4: Find orphans - installed packages that are not needed
-	var orphans []string
-	for _, pkg := range installed {
-		if pkg.IsCask {
-			continue
-		}
-		if !needed[pkg.Name] {
-			orphans = append(orphans, pkg.Name)
-		}
-	}
-
-	return orphans, nil
+		fmt.Fprintf(stdout, "\n🧹 Removed %d orphaned package(s).\n", len(removed))
+	},
 }
 
 func init() {
-	autoremoveCmd.Flags().BoolVar(&autoremoveDryRun, "dry-run", false, "Show what would be removed without actually removing")
 	rootCmd.AddCommand(autoremoveCmd)
 }
@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"fastbrew/internal/brew"
+	"fastbrew/internal/paths"
+	"fastbrew/internal/services"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// autoupdateLabel names the launchd agent and systemd units of the
+// auto-update timer.
+const autoupdateLabel = "fastbrew.autoupdate"
+
+var (
+	autoupdateInterval       time.Duration
+	autoupdateRecordOutdated bool
+	autoupdateNotify         bool
+)
+
+// AutoupdateStatusView is the --json schema for autoupdate status.
+type AutoupdateStatusView struct {
+	Enabled   bool       `json:"enabled"`
+	Path      string     `json:"path,omitempty"`
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+	Outdated  []string   `json:"outdated,omitempty"`
+}
+
+var autoupdateCmd = &cobra.Command{
+	Use:   "autoupdate",
+	Short: "Update the index on a timer in the background",
+	Long: `Run 'fastbrew update' periodically as a launchd agent (macOS) or a
+systemd user timer (Linux).
+
+With --record-outdated the timer also counts outdated packages, and other
+commands then print a one-line hint while the count is less than two days
+old. Upgrading or uninstalling the packages clears them from the hint.
+
+With --notify the timer records outdated packages too, and posts a desktop
+notification (osascript on macOS, notify-send on Linux) when there are any.`,
+}
+
+var autoupdateEnableCmd = &cobra.Command{
+	Use:   "enable",
+	Short: "Install the auto-update timer",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if autoupdateInterval < time.Hour {
+			exitWithError("Error", fmt.Errorf("--interval must be at least 1h"))
+		}
+		command := []string{fastbrewExecutable(), "update"}
+		switch {
+		case autoupdateNotify:
+			command = append(command, "--notify")
+		case autoupdateRecordOutdated:
+			command = append(command, "--record-outdated")
+		}
+		timer := services.Timer{
+			Label:       autoupdateLabel,
+			Description: "fastbrew index auto-update",
+			Command:     command,
+			Interval:    autoupdateInterval,
+			LogPath:     filepath.Join(paths.StateDir(), "autoupdate.log"),
+		}
+		if err := services.InstallTimer(timer); err != nil {
+			exitWithError("Error enabling auto-update", err)
+		}
+		if !autoupdateRecordOutdated && !autoupdateNotify {
+			// A record left from an earlier timer would go stale unnoticed.
+			_ = brew.ForgetOutdated()
+		}
+		fmt.Fprintf(stdout, "✅ Auto-update enabled: 'fastbrew update' runs every %s\n", autoupdateInterval)
+		fmt.Fprintf(stdout, "   %s\n", services.TimerPath(autoupdateLabel))
+	},
+}
+
+var autoupdateDisableCmd = &cobra.Command{
+	Use:   "disable",
+	Short: "Remove the auto-update timer",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := services.RemoveTimer(autoupdateLabel); err != nil {
+			exitWithError("Error disabling auto-update", err)
+		}
+		if err := brew.ForgetOutdated(); err != nil {
+			fmt.Fprintf(stderr, "Warning: failed to remove the outdated record: %v\n", err)
+		}
+		fmt.Fprintln(stdout, "✅ Auto-update disabled")
+	},
+}
+
+var autoupdateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the auto-update timer is installed",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		view := AutoupdateStatusView{}
+		if path := services.TimerPath(autoupdateLabel); path != "" {
+			if _, err := os.Stat(path); err == nil {
+				view.Enabled, view.Path = true, path
+			}
+		}
+		record, err := brew.LoadOutdatedRecord()
+		if err != nil {
+			exitWithError("Error", err)
+		}
+		if record != nil {
+			view.CheckedAt, view.Outdated = &record.CheckedAt, record.Packages
+		}
+
+		if jsonOutput {
+			printJSON(view)
+			return
+		}
+		if view.Enabled {
+			fmt.Fprintf(stdout, "Auto-update: enabled (%s)\n", view.Path)
+		} else {
+			fmt.Fprintln(stdout, "Auto-update: disabled")
+		}
+		if record != nil {
+			fmt.Fprintf(stdout, "Last outdated check: %s (%s)\n",
+				record.CheckedAt.Local().Format("2006-01-02 15:04"), outdatedCountText(len(record.Packages)))
+		}
+	},
+}
+
+// fastbrewExecutable returns the path the timer runs fastbrew by. The one
+// on PATH is preferred, as the running executable may be a versioned path
+// in the Cellar that an upgrade removes.
+func fastbrewExecutable() string {
+	if path, err := exec.LookPath("fastbrew"); err == nil {
+		if abs, err := filepath.Abs(path); err == nil {
+			return abs
+		}
+	}
+	if exe, err := os.Executable(); err == nil {
+		return exe
+	}
+	return "fastbrew"
+}
+
+func outdatedCountText(n int) string {
+	switch n {
+	case 0:
+		return "No packages are outdated"
+	case 1:
+		return "1 package is outdated"
+	default:
+		return fmt.Sprintf("%d packages are outdated", n)
+	}
+}
+
+// noOutdatedHint lists the top-level commands that never print the outdated
+// hint: those that deal with outdated packages themselves, and plumbing.
+var noOutdatedHint = map[string]bool{
+	"update": true, "upgrade": true, "outdated": true, "autoupdate": true,
+	"completion": true, "help": true, "daemon": true, "plugin": true,
+	"__complete": true, "__completeNoDesc": true,
+}
+
+// printOutdatedHint prints "N packages are outdated" to stderr after a
+// command when a fresh outdated record lists any, on a terminal only.
+func printOutdatedHint(cmd *cobra.Command) {
+	if jsonOutput || cmd == cmd.Root() || !isTerminal(os.Stderr) {
+		return
+	}
+	top := cmd
+	for top.HasParent() && top.Parent() != cmd.Root() {
+		top = top.Parent()
+	}
+	if noOutdatedHint[top.Name()] {
+		return
+	}
+	record, err := brew.LoadOutdatedRecord()
+	if err != nil || record == nil || len(record.Packages) == 0 || !record.Fresh(time.Now()) {
+		return
+	}
+	fmt.Fprintf(stderr, "💡 %s; run 'fastbrew upgrade'.\n", outdatedCountText(len(record.Packages)))
+}
+
+func init() {
+	autoupdateEnableCmd.Flags().DurationVar(&autoupdateInterval, "interval", 24*time.Hour, "How often to update the index")
+	autoupdateEnableCmd.Flags().BoolVar(&autoupdateRecordOutdated, "record-outdated", false, "Also count outdated packages, for a hint in other commands")
+	autoupdateEnableCmd.Flags().BoolVar(&autoupdateNotify, "notify", false, "Also post a desktop notification when packages are outdated (implies --record-outdated)")
+	autoupdateCmd.AddCommand(autoupdateEnableCmd)
+	autoupdateCmd.AddCommand(autoupdateDisableCmd)
+	autoupdateCmd.AddCommand(autoupdateStatusCmd)
+	rootCmd.AddCommand(autoupdateCmd)
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fastbrew/internal/brew"
+	"fastbrew/internal/progress"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	benchmarkBrew     bool
+	benchmarkBrewPath string
+	benchmarkDeps     brew.DepsOptions
+)
+
+var benchmarkCmd = &cobra.Command{
+	Use:   "benchmark <formula...>",
+	Short: "Time installing formulae, optionally against brew",
+	Long: `Time fastbrew installing the formulae and their dependencies into a
+temporary prefix: first with an empty download cache, then again into a
+fresh prefix reusing the bottles and extracted files the first run cached.
+The package index is loaded before timing starts. Nothing outside the
+temporary directory is changed, and it is removed at the end.
+
+With --brew, 'brew install' of the same formulae is timed the same way, run
+from the temporary prefix with its own download cache. Outside its default
+prefix brew may build formulae from source, which shows in its times.
+
+Casks are not supported: their artifacts are installed outside the prefix.`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeAvailablePackages,
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := brew.BenchmarkOptions{Deps: benchmarkDeps}
+		if benchmarkBrew || benchmarkBrewPath != "" {
+			opts.BrewPath = benchmarkBrewPath
+			if opts.BrewPath == "" {
+				path, err := exec.LookPath("brew")
+				if err != nil {
+					exitWithError("Error", fmt.Errorf("--brew needs brew on PATH or --brew-path: %w", err))
+				}
+				opts.BrewPath = path
+			}
+		}
+
+		client, err := newBrewClient()
+		if err != nil {
+			exitWithError("Error initializing brew client", err)
+		}
+		if jsonOutput {
+			quietForJSON(client)
+		}
+
+		ctx, stopInterrupt := interruptContext(cmd.Context())
+		defer stopInterrupt()
+
+		report, err := client.Benchmark(ctx, args, opts)
+		if err != nil {
+			exitWithError("Error running benchmark", err)
+		}
+		if jsonOutput {
+			printJSON(report)
+		} else {
+			writeBenchmarkReport(stdout, report)
+		}
+		for _, run := range report.Runs {
+			if run.Tool == brew.BenchmarkToolFastbrew && run.Error != "" {
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+// writeBenchmarkReport prints one row per run, then how much faster
+// fastbrew was than brew for each cache state both completed.
+func writeBenchmarkReport(out io.Writer, report *brew.BenchmarkReport) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TOOL\tCACHE\tTIME\tKEGS\tTRANSFERRED")
+	for _, run := range report.Runs {
+		transferred := "-"
+		if run.Tool == brew.BenchmarkToolFastbrew {
+			transferred = progress.FormatBytes(run.BytesTransferred)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", run.Tool, run.Cache, formatStepTime(run.Duration), run.Kegs, transferred)
+	}
+	w.Flush()
+
+	for _, run := range report.Runs {
+		if run.Error != "" {
+			fmt.Fprintf(out, "❌ %s (%s cache): %s\n", run.Tool, run.Cache, run.Error)
+		}
+	}
+	for _, cache := range []string{brew.BenchmarkCacheCold, brew.BenchmarkCacheWarm} {
+		fast, ok := report.Run(brew.BenchmarkToolFastbrew, cache)
+		slow, hasBrew := report.Run(brew.BenchmarkToolBrew, cache)
+		if !ok || !hasBrew || fast.Error != "" || slow.Error != "" || fast.Duration <= 0 {
+			continue
+		}
+		fmt.Fprintf(out, "🚀 %s cache: fastbrew was %.1fx faster than brew\n", cache, float64(slow.Duration)/float64(fast.Duration))
+	}
+}
+
+func init() {
+	benchmarkCmd.Flags().BoolVar(&benchmarkBrew, "brew", false, "Also time 'brew install' of the same formulae")
+	benchmarkCmd.Flags().StringVar(&benchmarkBrewPath, "brew-path", "", "brew executable to compare against (implies --brew; found on PATH by default)")
+	benchmarkCmd.Flags().BoolVar(&benchmarkDeps.IncludeOptional, "include-optional", false, "Also install optional dependencies")
+	benchmarkCmd.Flags().BoolVar(&benchmarkDeps.SkipRecommended, "skip-recommended", false, "Do not install recommended dependencies")
+	rootCmd.AddCommand(benchmarkCmd)
+}
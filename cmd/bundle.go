@@ -1,11 +1,14 @@
 package cmd
 
 import (
+	"context"
 	"fastbrew/internal/brew"
 	"fastbrew/internal/bundle"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -21,110 +24,256 @@ var bundleInstallCmd = &cobra.Command{
 	Short: "Install dependencies from a Brewfile",
 	Run: func(cmd *cobra.Command, args []string) {
 		file, _ := cmd.Flags().GetString("file")
-		dryRun, _ := cmd.Flags().GetBool("dry-run")
 		verbose, _ := cmd.Flags().GetBool("verbose")
+		locked, _ := cmd.Flags().GetBool("locked")
 
 		if file == "" {
 			file = findBrewfile()
 		}
 
 		if file == "" {
-			fmt.Println("Error: No Brewfile found. Use --file to specify one.")
+			fmt.Fprintln(stdout, "Error: No Brewfile found. Use --file to specify one.")
 			os.Exit(1)
 		}
 
 		parser := bundle.SimpleParser()
 		brewfile, err := parser.ParseFile(file)
 		if err != nil {
-			fmt.Printf("Error parsing Brewfile: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Error parsing Brewfile: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+
+		var lockfile *bundle.Lockfile
+		if locked {
+			lockfile, err = loadFreshLockfile(file, brewfile)
+			if err != nil {
+				fmt.Fprintf(stdout, "Error: %v\n", err)
+				os.Exit(exitCode(err))
+			}
 		}
 
 		if dryRun {
-			fmt.Println("Would install:")
+			fmt.Fprintln(stdout, "Would install:")
 			for _, brew := range brewfile.GetBrews() {
-				fmt.Printf("  brew: %s\n", brew.Name)
+				fmt.Fprintf(stdout, "  brew: %s\n", brew.Name)
 			}
 			for _, cask := range brewfile.GetCasks() {
-				fmt.Printf("  cask: %s\n", cask.Name)
+				fmt.Fprintf(stdout, "  cask: %s\n", cask.Name)
 			}
 			for _, tap := range brewfile.GetTaps() {
-				fmt.Printf("  tap: %s/%s\n", tap.User, tap.Repo)
+				fmt.Fprintf(stdout, "  tap: %s/%s\n", tap.User, tap.Repo)
 			}
 			for _, mas := range brewfile.GetMasApps() {
-				fmt.Printf("  mas: %s (id: %d)\n", mas.Name, mas.ID)
+				fmt.Fprintf(stdout, "  mas: %s (id: %d)\n", mas.Name, mas.ID)
 			}
 			return
 		}
 
 		if verbose {
-			fmt.Printf("Installing from %s...\n", file)
+			fmt.Fprintf(stdout, "Installing from %s...\n", file)
 		}
 
+		defer lockFastbrew()()
+
 		client, err := newBrewClient()
 		if err != nil {
-			fmt.Printf("Error creating client: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Error creating client: %v\n", err)
+			os.Exit(exitCode(err))
 		}
 
-		taps := brewfile.GetTaps()
-		if len(taps) > 0 {
-			fmt.Printf("📦 Tapping %d repositories...\n", len(taps))
-			tapManager, err := newTapManager()
-			if err != nil {
-				fmt.Printf("Error initializing tap manager: %v\n", err)
-				os.Exit(1)
-			}
-			for _, tap := range taps {
-				tapRepo := fmt.Sprintf("%s/%s", tap.User, tap.Repo)
-				fmt.Printf("  tap: %s\n", tapRepo)
-				if err := tapManager.Tap(tapRepo, false); err != nil {
-					fmt.Printf("  ⚠️  Warning: failed to tap %s: %v\n", tapRepo, err)
-				}
-			}
-		}
+		ctx, stopInterrupt := interruptContext(cmd.Context())
+		defer stopInterrupt()
 
-		casks := brewfile.GetCasks()
-		if len(casks) > 0 {
-			fmt.Printf("🍷 Installing %d casks...\n", len(casks))
-			installer := brew.NewCaskInstaller(client)
-			for _, cask := range casks {
-				if verbose {
-					fmt.Printf("  Installing cask: %s\n", cask.Name)
-				}
-				if err := installer.Install(cask.Name, client.ProgressManager); err != nil {
-					fmt.Printf("  ⚠️  Error installing cask %s: %v\n", cask.Name, err)
-				} else if verbose {
-					fmt.Printf("  ✅ %s installed\n", cask.Name)
-				}
+		backend := &bundleBackend{ctx: ctx, client: client}
+		if lockfile != nil {
+			if err := checkLockedCasks(client, lockfile); err != nil {
+				fmt.Fprintf(stdout, "Error: %v\n", err)
+				os.Exit(exitCode(err))
 			}
+			backend.lock = brewInstallLock(lockfile)
 		}
 
-		brews := brewfile.GetBrews()
-		if len(brews) > 0 {
-			fmt.Printf("🍺 Installing %d formulae...\n", len(brews))
-			formulae := make([]string, len(brews))
-			for i, b := range brews {
-				formulae[i] = b.Name
+		report := bundle.Install(brewfile, backend, func(res bundle.EntryResult) {
+			if res.Err != nil {
+				fmt.Fprintf(stdout, "  ❌ %s %s: %v\n", res.Type, res.Name, res.Err)
+			} else {
+				fmt.Fprintf(stdout, "  ✅ %s %s\n", res.Type, res.Name)
+			}
+		})
+		cleanupAfterInterrupt(ctx, client)
+
+		if failed := report.Failed(); len(failed) > 0 {
+			fmt.Fprintf(stdout, "❌ %d of %d Brewfile entries failed\n", len(failed), len(report.Results))
+			errs := make([]error, len(failed))
+			for i, res := range failed {
+				errs[i] = res.Err
 			}
-			if err := client.InstallNative(formulae); err != nil {
-				fmt.Printf("Error installing formulae: %v\n", err)
-				os.Exit(1)
+			if ctx.Err() != nil {
+				os.Exit(exitInterrupted)
 			}
+			exitForFailures(errs, len(report.Results))
 		}
 
-		masApps := brewfile.GetMasApps()
-		if len(masApps) > 0 {
-			fmt.Printf("📱 Found %d Mac App Store apps (not yet supported)\n", len(masApps))
-			for _, mas := range masApps {
-				fmt.Printf("  mas: %s (id: %d)\n", mas.Name, mas.ID)
+		if !locked {
+			lockPath := bundle.LockPath(file)
+			if err := writeLockfile(client, brewfile, lockPath); err != nil {
+				fmt.Fprintf(stdout, "⚠️  Failed to write %s: %v\n", lockPath, err)
+			} else if verbose {
+				fmt.Fprintf(stdout, "Wrote %s\n", lockPath)
 			}
 		}
-
-		fmt.Println("✅ Bundle install complete!")
+		fmt.Fprintln(stdout, "✅ Bundle install complete!")
 	},
 }
 
+// loadFreshLockfile reads the lock next to a Brewfile and makes sure it
+// still describes that Brewfile on this platform.
+func loadFreshLockfile(file string, brewfile *bundle.Brewfile) (*bundle.Lockfile, error) {
+	lockPath := bundle.LockPath(file)
+	lockfile, err := bundle.ReadLockfile(lockPath)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s not found; run `fastbrew bundle install` without --locked to create it", lockPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	platform, err := brew.GetPlatform()
+	if err != nil {
+		return nil, err
+	}
+	if err := lockfile.CheckFresh(brewfile, platform); err != nil {
+		return nil, fmt.Errorf("%w; run `fastbrew bundle install` without --locked to update it", err)
+	}
+	return lockfile, nil
+}
+
+// checkLockedCasks fails if a locked cask's artifact is no longer the one
+// the API serves. Cask artifacts are not versioned, so the locked one can
+// only be installed while it is current.
+func checkLockedCasks(client *brew.Client, lockfile *bundle.Lockfile) error {
+	for _, locked := range lockfile.Casks {
+		metadata, err := client.FetchCaskMetadata(locked.Name)
+		if err != nil {
+			return err
+		}
+		if metadata.URL != locked.URL || metadata.SHA256 != locked.SHA256 {
+			return fmt.Errorf("lock file is stale: cask %s is now %s (locked %s)", locked.Name, metadata.Version, locked.Version)
+		}
+	}
+	return nil
+}
+
+func brewInstallLock(lockfile *bundle.Lockfile) *brew.InstallLock {
+	bottles := make([]brew.LockedBottle, 0, len(lockfile.Brews))
+	for _, f := range lockfile.Brews {
+		bottles = append(bottles, brew.LockedBottle{
+			Name:         f.Name,
+			Version:      f.Version,
+			Revision:     f.Revision,
+			URL:          f.URL,
+			SHA256:       f.SHA256,
+			Dependencies: f.Dependencies,
+		})
+	}
+	return brew.NewInstallLock(bottles)
+}
+
+// writeLockfile records the artifacts the Brewfile currently resolves to.
+func writeLockfile(client *brew.Client, brewfile *bundle.Brewfile, path string) error {
+	platform, err := brew.GetPlatform()
+	if err != nil {
+		return err
+	}
+	lockfile := &bundle.Lockfile{
+		Entries:  bundle.EntriesDigest(brewfile),
+		Platform: platform,
+	}
+
+	for _, tap := range brewfile.GetTaps() {
+		lockfile.Taps = append(lockfile.Taps, tap.User+"/"+tap.Repo)
+	}
+
+	var names []string
+	for _, b := range brewfile.GetBrews() {
+		names = append(names, b.Name)
+	}
+	bottles, err := client.ResolveBottles(names)
+	if err != nil {
+		return err
+	}
+	for _, b := range bottles {
+		lockfile.Brews = append(lockfile.Brews, bundle.LockedFormula{
+			Name:         b.Name,
+			Version:      b.Version,
+			Revision:     b.Revision,
+			URL:          b.URL,
+			SHA256:       b.SHA256,
+			Dependencies: b.Dependencies,
+		})
+	}
+
+	for _, c := range brewfile.GetCasks() {
+		metadata, err := client.FetchCaskMetadata(c.Name)
+		if err != nil {
+			return err
+		}
+		lockfile.Casks = append(lockfile.Casks, bundle.LockedCask{
+			Name:    c.Name,
+			Version: metadata.Version,
+			URL:     metadata.URL,
+			SHA256:  metadata.SHA256,
+		})
+	}
+
+	for _, app := range brewfile.GetMasApps() {
+		lockfile.Mas = append(lockfile.Mas, bundle.LockedMas{Name: app.Name, ID: app.ID})
+	}
+
+	return lockfile.Write(path)
+}
+
+// bundleBackend installs Brewfile entries through the native client and
+// tap manager, and Mac App Store apps through the mas CLI. Once ctx is
+// cancelled the remaining entries fail without being started.
+type bundleBackend struct {
+	ctx    context.Context
+	client *brew.Client
+	taps   *brew.TapManager
+	lock   *brew.InstallLock
+}
+
+func (b *bundleBackend) Tap(tap *bundle.TapCommand) error {
+	if err := b.ctx.Err(); err != nil {
+		return err
+	}
+	if b.taps == nil {
+		manager, err := newTapManager()
+		if err != nil {
+			return err
+		}
+		b.taps = manager
+	}
+	repo := tap.User + "/" + tap.Repo
+	if _, exists := b.taps.GetTap(repo); exists {
+		return nil
+	}
+	if tap.URL != "" {
+		return b.taps.TapWithRemote(repo, tap.URL, false)
+	}
+	return b.taps.Tap(repo, false)
+}
+
+func (b *bundleBackend) Install(names []string) error {
+	return b.client.InstallNativeWithOptions(b.ctx, names, brew.InstallOptions{Lock: b.lock})
+}
+
+func (b *bundleBackend) InstallMas(app *bundle.MasCommand) error {
+	if err := b.ctx.Err(); err != nil {
+		return err
+	}
+	return bundle.InstallMasApp(app.ID)
+}
+
 var bundleDumpCmd = &cobra.Command{
 	Use:   "dump",
 	Short: "Generate a Brewfile from installed packages",
@@ -139,8 +288,8 @@ var bundleDumpCmd = &cobra.Command{
 		dumper := bundle.NewDumper()
 		result, err := dumper.Dump(opts)
 		if err != nil {
-			fmt.Printf("Error dumping packages: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Error dumping packages: %v\n", err)
+			os.Exit(exitCode(err))
 		}
 
 		genOpts := bundle.DefaultGeneratorOptions()
@@ -150,31 +299,31 @@ var bundleDumpCmd = &cobra.Command{
 		if file == "" || file == "-" {
 			err = generator.Generate(os.Stdout, result)
 			if err != nil {
-				fmt.Printf("Error generating Brewfile: %v\n", err)
-				os.Exit(1)
+				fmt.Fprintf(stdout, "Error generating Brewfile: %v\n", err)
+				os.Exit(exitCode(err))
 			}
 			return
 		}
 
 		if _, err := os.Stat(file); err == nil && !force {
-			fmt.Printf("File %s already exists. Use --force to overwrite.\n", file)
+			fmt.Fprintf(stdout, "File %s already exists. Use --force to overwrite.\n", file)
 			os.Exit(1)
 		}
 
 		f, err := os.Create(file)
 		if err != nil {
-			fmt.Printf("Error creating file: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Error creating file: %v\n", err)
+			os.Exit(exitCode(err))
 		}
 		defer f.Close()
 
 		err = generator.Generate(f, result)
 		if err != nil {
-			fmt.Printf("Error generating Brewfile: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Error generating Brewfile: %v\n", err)
+			os.Exit(exitCode(err))
 		}
 
-		fmt.Printf("Brewfile written to %s\n", file)
+		fmt.Fprintf(stdout, "Brewfile written to %s\n", file)
 	},
 }
 
@@ -183,80 +332,99 @@ var bundleCheckCmd = &cobra.Command{
 	Short: "Check if all dependencies are satisfied",
 	Run: func(cmd *cobra.Command, args []string) {
 		file, _ := cmd.Flags().GetString("file")
+		verbose, _ := cmd.Flags().GetBool("verbose")
 
 		if file == "" {
 			file = findBrewfile()
 		}
 
 		if file == "" {
-			fmt.Println("Error: No Brewfile found. Use --file to specify one.")
+			fmt.Fprintln(stdout, "Error: No Brewfile found. Use --file to specify one.")
 			os.Exit(1)
 		}
 
 		parser := bundle.SimpleParser()
 		brewfile, err := parser.ParseFile(file)
 		if err != nil {
-			fmt.Printf("Error parsing Brewfile: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Error parsing Brewfile: %v\n", err)
+			os.Exit(exitCode(err))
 		}
 
 		client, err := newBrewClient()
 		if err != nil {
-			fmt.Printf("Error creating client: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Error creating client: %v\n", err)
+			os.Exit(exitCode(err))
 		}
 
 		installed, err := client.ListInstalledNative()
 		if err != nil {
-			fmt.Printf("Error listing installed: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Error listing installed: %v\n", err)
+			os.Exit(exitCode(err))
 		}
 
-		installedMap := make(map[string]bool)
-		for _, pkg := range installed {
-			installedMap[pkg.Name] = true
+		state := bundle.CheckState{
+			Formulae: make(map[string]bool),
+			Casks:    make(map[string]bool),
+			Taps:     make(map[string]bool),
 		}
-
-		var missing []string
-
-		tapManager, tapErr := newTapManager()
-		if tapErr != nil {
-			fmt.Printf("Error initializing tap manager: %v\n", tapErr)
-			os.Exit(1)
+		for _, pkg := range installed {
+			if pkg.IsCask {
+				state.Casks[pkg.Name] = true
+			} else {
+				state.Formulae[pkg.Name] = true
+			}
 		}
 
-		for _, tap := range brewfile.GetTaps() {
-			tapRepo := fmt.Sprintf("%s/%s", tap.User, tap.Repo)
-			_, exists := tapManager.GetTap(tapRepo)
-			if !exists {
-				localPath := filepath.Join("/opt/homebrew/Library/Taps", tap.User, "homebrew-"+tap.Repo)
-				if _, err := os.Stat(localPath); os.IsNotExist(err) {
-					missing = append(missing, "tap: "+tapRepo)
-				}
+		if len(brewfile.GetTaps()) > 0 {
+			tapManager, err := newTapManager()
+			if err != nil {
+				fmt.Fprintf(stdout, "Error initializing tap manager: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+			taps, err := tapManager.ListTaps()
+			if err != nil {
+				fmt.Fprintf(stdout, "Error listing taps: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+			for _, tap := range taps {
+				state.Taps[strings.ToLower(tap.Name)] = true
 			}
 		}
 
-		for _, cask := range brewfile.GetCasks() {
-			if !installedMap[cask.Name] {
-				missing = append(missing, "cask: "+cask.Name)
+		if len(brewfile.GetMasApps()) > 0 {
+			if apps, err := bundle.NewDumper().DumpMas(); err == nil {
+				state.MasApps = make(map[int]bool, len(apps))
+				for _, app := range apps {
+					if id, err := strconv.Atoi(app.ID); err == nil {
+						state.MasApps[id] = true
+					}
+				}
 			}
 		}
 
-		for _, brew := range brewfile.GetBrews() {
-			if !installedMap[brew.Name] {
-				missing = append(missing, "brew: "+brew.Name)
+		report := bundle.Check(brewfile, state)
+		if verbose {
+			for _, entry := range report.Entries {
+				if entry.Satisfied {
+					fmt.Fprintf(stdout, "  ✅ %s %s\n", entry.Type, entry.Name)
+				}
 			}
 		}
 
+		missing := report.Missing()
 		if len(missing) > 0 {
-			fmt.Println("❌ The following dependencies are missing:")
-			for _, m := range missing {
-				fmt.Printf("  %s\n", m)
+			fmt.Fprintln(stdout, "❌ The following dependencies are missing:")
+			for _, entry := range missing {
+				if entry.Reason != "" {
+					fmt.Fprintf(stdout, "  %s: %s (%s)\n", entry.Type, entry.Name, entry.Reason)
+				} else {
+					fmt.Fprintf(stdout, "  %s: %s\n", entry.Type, entry.Name)
+				}
 			}
 			os.Exit(1)
 		}
 
-		fmt.Println("✅ All dependencies are satisfied")
+		fmt.Fprintln(stdout, "✅ All dependencies are satisfied")
 	},
 }
 
@@ -282,14 +450,15 @@ func findBrewfile() string {
 
 func init() {
 	bundleInstallCmd.Flags().String("file", "", "Path to Brewfile")
-	bundleInstallCmd.Flags().Bool("dry-run", false, "Show what would be installed")
 	bundleInstallCmd.Flags().Bool("verbose", false, "Verbose output")
+	bundleInstallCmd.Flags().Bool("locked", false, "Install exactly the artifacts in Brewfile.lock.json; fail if it is stale")
 
 	bundleDumpCmd.Flags().String("file", "", "Output file (default: stdout)")
 	bundleDumpCmd.Flags().Bool("describe", false, "Include package descriptions as comments")
 	bundleDumpCmd.Flags().Bool("force", false, "Overwrite existing file")
 
 	bundleCheckCmd.Flags().String("file", "", "Path to Brewfile")
+	bundleCheckCmd.Flags().Bool("verbose", false, "List satisfied entries too")
 
 	bundleCmd.AddCommand(bundleInstallCmd)
 	bundleCmd.AddCommand(bundleDumpCmd)
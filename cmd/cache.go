@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"fastbrew/internal/brew"
+	"fastbrew/internal/config"
+	"fastbrew/internal/progress"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	cachePruneAll        bool
+	cachePruneMaxAgeDays int
+)
+
+// CacheBlobView is the --json schema for one entry of `cache list`.
+type CacheBlobView struct {
+	SHA256   string    `json:"sha256"`
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	LastUsed time.Time `json:"last_used"`
+	Names    []string  `json:"names"`
+}
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect and prune the download cache",
+	Long: `Downloaded bottles are stored once per SHA256 in the cache's blobs
+directory, with <name>-<version>.bottle links pointing at them. A bottle that is already
+cached under any name is reused instead of downloaded again.`,
+}
+
+var cacheListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List cached downloads",
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newBrewClient()
+		if err != nil {
+			exitWithError("Error initializing brew client", err)
+		}
+
+		blobs, err := client.ListDownloadCache()
+		if err != nil {
+			exitWithError("Error reading download cache", err)
+		}
+
+		if jsonOutput {
+			views := make([]CacheBlobView, 0, len(blobs))
+			for _, blob := range blobs {
+				views = append(views, CacheBlobView{
+					SHA256:   blob.SHA256,
+					Path:     blob.Path,
+					Size:     blob.Size,
+					LastUsed: blob.LastUsed,
+					Names:    blob.Names,
+				})
+			}
+			printJSON(views)
+			return
+		}
+
+		if len(blobs) == 0 {
+			fmt.Fprintln(stdout, "Download cache is empty.")
+			return
+		}
+
+		var total int64
+		for _, blob := range blobs {
+			total += blob.Size
+			names := strings.Join(blob.Names, ", ")
+			if names == "" {
+				names = "(unreferenced)"
+			}
+			fmt.Fprintf(stdout, "  %s  %10s  %s  %s\n", blob.SHA256[:min(12, len(blob.SHA256))],
+				progress.FormatBytes(blob.Size), blob.LastUsed.Format("2006-01-02"), names)
+		}
+		fmt.Fprintf(stdout, "📦 %d cached downloads, %s\n", len(blobs), progress.FormatBytes(total))
+	},
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove unreferenced and unused cached downloads",
+	Long: `Remove cache names whose download is missing and downloads no name refers
+to. With --max-age-days (or the cleanup_max_age_days config key), downloads
+not used for that long are removed too. When the max_cache_size config key
+is set, the least recently used downloads are then removed until the cache
+fits; --all empties the cache.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		defer lockFastbrew()()
+
+		client, err := newBrewClient()
+		if err != nil {
+			exitWithError("Error initializing brew client", err)
+		}
+
+		maxAge := config.Get().GetCleanupMaxAge()
+		if cmd.Flags().Changed("max-age-days") {
+			maxAge = time.Duration(cachePruneMaxAgeDays) * 24 * time.Hour
+		}
+
+		report, err := client.PruneDownloadCache(brew.CachePruneOptions{
+			MaxAge:  maxAge,
+			MaxSize: config.Get().GetMaxCacheSize(),
+			All:     cachePruneAll,
+			DryRun:  dryRun,
+		})
+		if err != nil {
+			exitWithError("Error pruning download cache", err)
+		}
+
+		verb := "Removed"
+		if dryRun {
+			verb = "Would remove"
+		}
+		var blobs int
+		for _, item := range report.Items {
+			if item.Kind == brew.CleanupKindCache {
+				blobs++
+				fmt.Fprintf(stdout, "  🧽 %s %s (%s)\n", verb, item.Path, progress.FormatBytes(item.Bytes))
+			}
+		}
+
+		if len(report.Items) == 0 {
+			fmt.Fprintln(stdout, "✅ Nothing to prune.")
+			return
+		}
+		if dryRun {
+			fmt.Fprintf(stdout, "✅ Pruning would remove %d downloads and free %s.\n", blobs, progress.FormatBytes(report.BytesReclaimed()))
+			return
+		}
+		fmt.Fprintf(stdout, "✅ Removed %d downloads, freed %s.\n", blobs, progress.FormatBytes(report.BytesReclaimed()))
+	},
+}
+
+func init() {
+	cachePruneCmd.Flags().BoolVar(&cachePruneAll, "all", false, "Remove every cached download")
+	cachePruneCmd.Flags().IntVar(&cachePruneMaxAgeDays, "max-age-days", 0, "Also remove downloads not used for this many days (overrides cleanup_max_age_days)")
+	cacheCmd.AddCommand(cacheListCmd)
+	cacheCmd.AddCommand(cachePruneCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
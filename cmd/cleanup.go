@@ -1,107 +1,101 @@
 package cmd
 
 import (
+	"fastbrew/internal/brew"
+	"fastbrew/internal/config"
+	"fastbrew/internal/progress"
 	"fmt"
 	"os"
-	"path/filepath"
-	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
+var (
+	cleanupKeep       int
+	cleanupMaxAgeDays int
+)
+
 var cleanupCmd = &cobra.Command{
 	Use:   "cleanup",
 	Short: "Remove old versions of installed formulae and clear cache",
+	Long: `Remove superseded Cellar versions, directories left by extractions
+interrupted over an hour ago, downloaded bottles, stale resume metadata and
+broken symlinks.
+
+The newest --keep versions of each formula are always kept, as is the linked
+version. With a maximum age (--max-age-days, or the cleanup_max_age_days
+config key), older versions and cached files younger than that age are kept
+too. Pinned formulae are skipped.
+
+--dry-run lists each item with the space removing it would free.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if !dryRun {
+			defer lockFastbrew()()
+		}
+
 		client, err := newBrewClient()
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Error: %v\n", err)
+			os.Exit(exitCode(err))
 		}
 
-		fmt.Println("🧹 Cleaning up old versions...")
-
-		entries, err := os.ReadDir(client.Cellar)
-		if err == nil {
-			for _, entry := range entries {
-				if !entry.IsDir() {
-					continue
-				}
-
-				pkgDir := filepath.Join(client.Cellar, entry.Name())
-				versions, err := os.ReadDir(pkgDir)
-				if err != nil || len(versions) <= 1 {
-					continue
-				}
+		maxAge := config.Get().GetCleanupMaxAge()
+		if cmd.Flags().Changed("max-age-days") {
+			maxAge = time.Duration(cleanupMaxAgeDays) * 24 * time.Hour
+		}
+		pinned, _ := loadPinnedPackages()
 
-				// Keep latest version (alphabetical last for simplicity in MVP)
-				latest := versions[len(versions)-1].Name()
-				for i := 0; i < len(versions)-1; i++ {
-					v := versions[i].Name()
-					if v == latest {
-						continue
-					}
-					fmt.Printf("  🗑️  Removing %s %s...\n", entry.Name(), v)
-					os.RemoveAll(filepath.Join(pkgDir, v))
-				}
-			}
+		if dryRun {
+			fmt.Fprintln(stdout, "🔍 Dry run: nothing will be removed.")
+		}
+		report, err := client.Cleanup(brew.CleanupOptions{
+			KeepVersions: cleanupKeep,
+			MaxAge:       maxAge,
+			MaxCacheSize: config.Get().GetMaxCacheSize(),
+			Skip:         pinned,
+			DryRun:       dryRun,
+		})
+		if err != nil {
+			fmt.Fprintf(stdout, "Error during cleanup: %v\n", err)
+			os.Exit(exitCode(err))
 		}
 
-		fmt.Println("🧽 Clearing cache...")
-		cacheDir, err := client.GetCacheDir()
-		if err == nil {
-			// Don't remove formula.json/cask.json/search.gob as they are needed for performance
-			// Only remove downloaded tarballs
-			cacheEntries, err := os.ReadDir(cacheDir)
-			if err == nil {
-				for _, ce := range cacheEntries {
-					if ce.IsDir() {
-						continue
-					}
-					name := ce.Name()
-					if name != "formula.json.zst" && name != "cask.json.zst" &&
-						name != "search.gob.zst" && name != "prefix_index.gob" &&
-						!strings.HasSuffix(name, ".fastbrew-resume") {
-						os.Remove(filepath.Join(cacheDir, name))
-					}
+		verb := "Removed"
+		if dryRun {
+			verb = "Would remove"
+		}
+		for _, item := range report.Items {
+			switch item.Kind {
+			case brew.CleanupKindKeg:
+				if item.Version == "" {
+					fmt.Fprintf(stdout, "  🗑️  %s empty Cellar directory: %s\n", verb, item.Path)
+					continue
 				}
+				fmt.Fprintf(stdout, "  🗑️  %s %s %s (%s)\n", verb, item.Package, item.Version, progress.FormatBytes(item.Bytes))
+			case brew.CleanupKindTemp:
+				fmt.Fprintf(stdout, "  🧽 %s interrupted extraction: %s (%s)\n", verb, item.Path, progress.FormatBytes(item.Bytes))
+			case brew.CleanupKindSymlink:
+				fmt.Fprintf(stdout, "  🔗 %s broken symlink: %s\n", verb, item.Path)
+			default:
+				fmt.Fprintf(stdout, "  🧽 %s %s (%s)\n", verb, item.Path, progress.FormatBytes(item.Bytes))
 			}
 		}
 
-		fmt.Println("🔗 Checking for broken symlinks...")
-		linkDirs := []string{"bin", "sbin", "lib", "include", "share", "etc", "opt"}
-		brokenCount := 0
-		for _, dir := range linkDirs {
-			dirPath := filepath.Join(client.Prefix, dir)
-			if _, err := os.Stat(dirPath); os.IsNotExist(err) {
-				continue
-			}
-			filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
-				if err != nil {
-					return nil
-				}
-				linfo, lerr := os.Lstat(path)
-				if lerr != nil {
-					return nil
-				}
-				if linfo.Mode()&os.ModeSymlink != 0 {
-					if _, serr := os.Stat(path); serr != nil {
-						fmt.Printf("  🗑️  Removing broken symlink: %s\n", path)
-						os.Remove(path)
-						brokenCount++
-					}
-				}
-				return nil
-			})
+		if len(report.Items) == 0 {
+			fmt.Fprintln(stdout, "✅ Nothing to clean up.")
+			return
 		}
-		if brokenCount > 0 {
-			fmt.Printf("  Removed %d broken symlink(s)\n", brokenCount)
+		if dryRun {
+			fmt.Fprintf(stdout, "✅ Cleanup would free %s.\n", progress.FormatBytes(report.BytesReclaimed()))
+			return
 		}
-
-		fmt.Println("✅ Cleanup complete!")
+		fmt.Fprintf(stdout, "✅ Cleanup complete! Freed %s.\n", progress.FormatBytes(report.BytesReclaimed()))
 	},
 }
 
 func init() {
+	cleanupCmd.Flags().IntVar(&cleanupKeep, "keep", 1, "Number of newest versions of each formula to keep")
+	cleanupCmd.Flags().IntVar(&cleanupMaxAgeDays, "max-age-days", 0, "Only remove versions and cached files older than this many days (overrides cleanup_max_age_days)")
 	rootCmd.AddCommand(cleanupCmd)
 }
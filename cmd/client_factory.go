@@ -1,44 +1,106 @@
 package cmd
 
 import (
+	"fastbrew/internal/auth"
 	"fastbrew/internal/brew"
 	"fastbrew/internal/config"
 	"fastbrew/internal/daemon"
+	"fastbrew/internal/download"
+	"fastbrew/internal/httpclient"
+	"fastbrew/internal/retry"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sync"
 )
 
 var daemonWarmupOnce sync.Once
 
+// noAPICache is set by the global --no-cache flag: formula and cask
+// metadata is fetched from the API instead of the on-disk response cache.
+var noAPICache bool
+
 func newBrewClient() (*brew.Client, error) {
-	client, err := brew.NewClient()
+	cfg := config.Get()
+	client, err := brew.NewClientWithOptions(brew.ClientOptions{
+		Prefix:      cfg.GetPrefix(),
+		CacheDir:    cfg.GetCacheDir(),
+		Concurrency: cfg.GetParallelDownloads(),
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	cfg := config.Get()
-	client.MaxParallel = cfg.GetParallelDownloads()
+	client.Out = stdout
+	client.RetryAttempts = cfg.GetRetryAttempts()
+	client.IndexSnapshots = cfg.IndexSnapshots
+	client.ExtractWorkers = cfg.ExtractWorkers
+	client.DisableExtractCache = !cfg.ExtractCache
+	client.DisableAPICache = noAPICache
+	schedulerConfig := download.Config{
+		MaxConcurrent:     client.MaxParallel,
+		MaxPerHost:        cfg.GetMaxConnsPerHost(),
+		MaxBytesPerSecond: cfg.GetMaxBandwidth(),
+	}
+	if cacheDir, err := client.GetCacheDir(); err == nil {
+		schedulerConfig.ThroughputStatsPath = filepath.Join(cacheDir, "throughput.json")
+	}
+	client.Scheduler = download.NewScheduler(schedulerConfig)
 	if cfg.Verbose {
 		client.Verbose = true
 	}
+	client.VerifyAttestations = cfg.VerifyAttestations
+	client.Mirrors = cfg.Mirrors
+	client.Constraints = cfg.Constraints
+	client.Hooks = cfg.Hooks
+	client.Credentials = auth.NewStore(cfg.Credentials)
 	client.SetInvalidationHook(notifyDaemonInvalidation)
 
 	return client, nil
 }
 
+// applyNetworkConfig installs the configured proxy, CA, timeout and retry
+// settings on the shared HTTP client before any command runs.
+func applyNetworkConfig() {
+	cfg := config.Get()
+	timeouts := cfg.GetHTTPTimeouts()
+	httpclient.DefaultConfig.DialTimeout = timeouts.Connect
+	httpclient.DefaultConfig.ResponseHeaderTimeout = timeouts.ResponseHeader
+	httpclient.DefaultConfig.Timeout = timeouts.Request
+	if n := cfg.GetRetryAttempts(); n > 0 {
+		retry.DefaultConfig.MaxAttempts = n
+	}
+
+	n := cfg.GetNetwork()
+	err := httpclient.Configure(httpclient.NetworkConfig{
+		ProxyURL:           n.Proxy,
+		NoProxy:            n.NoProxy,
+		CABundle:           n.CABundle,
+		InsecureSkipVerify: n.InsecureSkipVerify,
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	if n.InsecureSkipVerify {
+		fmt.Fprintln(stderr, "⚠️  TLS certificate verification is disabled (network.insecure_skip_verify)")
+	}
+}
+
 func newTapManager() (*brew.TapManager, error) {
 	manager, err := brew.NewTapManager()
 	if err != nil {
 		return nil, err
 	}
 	manager.SetInvalidationHook(notifyDaemonInvalidation)
+	manager.Credentials = auth.NewStore(config.Get().Credentials)
 	return manager, nil
 }
 
 func getDaemonClientForRead() (*daemon.Client, error) {
 	cfg := config.Get()
-	if !cfg.Daemon.Enabled {
+	// The daemon keeps its own metadata cache, which --no-cache cannot reach.
+	if !cfg.Daemon.Enabled || noAPICache {
 		return nil, nil
 	}
 
@@ -69,7 +131,7 @@ func warnDaemonFallback(commandName string, err error) {
 	if err == nil {
 		return
 	}
-	fmt.Fprintf(os.Stderr, "⚠️  daemon fallback for %s: %v\n", commandName, err)
+	fmt.Fprintf(stderr, "⚠️  daemon fallback for %s: %v\n", commandName, err)
 }
 
 func notifyDaemonInvalidation(event string) {
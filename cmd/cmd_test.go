@@ -1,7 +1,16 @@
 package cmd
 
 import (
+	"bytes"
+	"fastbrew/internal/brew"
+	"fastbrew/internal/resume"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestRootCommand(t *testing.T) {
@@ -45,8 +54,9 @@ func TestInstallFlags(t *testing.T) {
 func TestCommandRegistration(t *testing.T) {
 	expectedSubCommands := []string{
 		"install", "uninstall", "update", "upgrade", "search", "list",
-		"info", "deps", "leaves", "doctor", "tap", "services", "bundle",
-		"cleanup", "pin", "reinstall", "autoremove", "sh", "link",
+		"info", "deps", "uses", "leaves", "licenses", "switch", "last", "doctor", "tap", "services", "bundle",
+		"cleanup", "cache", "pin", "reinstall", "autoremove", "sh", "link", "links", "plugin", "autoupdate", "benchmark", "compat",
+		"downloads", "shims", "exit-codes",
 	}
 
 	for _, name := range expectedSubCommands {
@@ -56,3 +66,264 @@ func TestCommandRegistration(t *testing.T) {
 		}
 	}
 }
+
+func TestJSONFlagIsGlobal(t *testing.T) {
+	flag := rootCmd.PersistentFlags().Lookup("json")
+	if flag == nil {
+		t.Fatal("Expected persistent 'json' flag on root command")
+	}
+
+	for _, name := range []string{"install", "upgrade", "outdated", "doctor", "list", "search"} {
+		cmd, _, _ := rootCmd.Find([]string{name})
+		if cmd == nil || cmd.InheritedFlags().Lookup("json") == nil {
+			t.Errorf("Expected %q to inherit the 'json' flag", name)
+		}
+	}
+}
+
+func TestFilterOutdatedKind(t *testing.T) {
+	outdated := []OutdatedView{
+		{Name: "wget", IsCask: false},
+		{Name: "firefox", IsCask: true},
+	}
+
+	if got := filterOutdatedKind(outdated, false, false); len(got) != 2 {
+		t.Errorf("Expected no filtering, got %v", got)
+	}
+	if got := filterOutdatedKind(outdated, true, false); len(got) != 1 || got[0].Name != "wget" {
+		t.Errorf("Expected only formulae, got %v", got)
+	}
+	if got := filterOutdatedKind(outdated, false, true); len(got) != 1 || got[0].Name != "firefox" {
+		t.Errorf("Expected only casks, got %v", got)
+	}
+	if got := filterOutdatedKind(nil, false, false); got == nil {
+		t.Error("Expected an empty, non-nil slice")
+	}
+}
+
+func TestOutdatedNotification(t *testing.T) {
+	title, body := outdatedNotification([]string{"wget"})
+	if title != "fastbrew: 1 package is outdated" || body != "wget. Run 'fastbrew upgrade'." {
+		t.Errorf("got %q, %q", title, body)
+	}
+	_, body = outdatedNotification([]string{"a", "b", "c", "d", "e", "f", "g"})
+	if body != "a, b, c, d, e and 2 more. Run 'fastbrew upgrade'." {
+		t.Errorf("long list body = %q", body)
+	}
+}
+
+func TestPackageCommandsComplete(t *testing.T) {
+	for _, args := range [][]string{
+		{"install"}, {"info"}, {"uninstall"}, {"upgrade"}, {"unlink"},
+		{"pin"}, {"unpin"}, {"services", "start"}, {"services", "log"}, {"config", "get"},
+	} {
+		cmd, _, err := rootCmd.Find(args)
+		if err != nil || cmd.ValidArgsFunction == nil {
+			t.Errorf("%v should have a completion function", args)
+		}
+	}
+}
+
+func TestCompletionHelpers(t *testing.T) {
+	if got := withoutArgs([]string{"jq", "wget", "curl"}, []string{"wget"}); !reflect.DeepEqual(got, []string{"jq", "curl"}) {
+		t.Errorf("withoutArgs = %v", got)
+	}
+
+	keys, _ := completeConfigKeys(configGetCmd, nil, "daemon.auto")
+	if len(keys) != 1 || keys[0] != "daemon.auto_start\tStart the daemon on demand" {
+		t.Errorf("completeConfigKeys(daemon.auto) = %q", keys)
+	}
+	if got, _ := singleArg(completeConfigKeys)(configGetCmd, []string{"verbose"}, ""); len(got) != 0 {
+		t.Errorf("second argument should not complete, got %v", got)
+	}
+}
+
+func TestParseSelection(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []int
+	}{
+		{"", []int{0, 1, 2, 3, 4}},
+		{"all\n", []int{0, 1, 2, 3, 4}},
+		{"none", nil},
+		{"2", []int{1}},
+		{"1 3-4", []int{0, 2, 3}},
+		{"5,1, 2-3", []int{0, 1, 2, 4}},
+	}
+	for _, tt := range tests {
+		got, err := parseSelection(tt.input, 5)
+		if err != nil {
+			t.Errorf("parseSelection(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSelection(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+
+	for _, input := range []string{"0", "6", "3-1", "x", "1-y"} {
+		if _, err := parseSelection(input, 5); err == nil {
+			t.Errorf("Expected parseSelection(%q) to fail", input)
+		}
+	}
+}
+
+func writePluginScript(t *testing.T, dir, name, body string, mode os.FileMode) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+body+"\n"), mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindPlugins(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugin tests use sh scripts")
+	}
+	first, second := t.TempDir(), t.TempDir()
+	writePluginScript(t, first, "fastbrew-hello", "exit 0", 0755)
+	writePluginScript(t, first, "fastbrew-list", "exit 0", 0755)
+	writePluginScript(t, first, "fastbrew-notes", "exit 0", 0644)
+	writePluginScript(t, second, "fastbrew-hello", "exit 0", 0755)
+	writePluginScript(t, second, "fastbrew-sync", "exit 0", 0755)
+
+	plugins := findPlugins(strings.Join([]string{first, second}, string(os.PathListSeparator)))
+	want := []Plugin{
+		{Name: "hello", Path: filepath.Join(first, "fastbrew-hello")},
+		{Name: "list", Path: filepath.Join(first, "fastbrew-list"), Shadowed: true},
+		{Name: "sync", Path: filepath.Join(second, "fastbrew-sync")},
+	}
+	if !reflect.DeepEqual(plugins, want) {
+		t.Errorf("findPlugins() = %+v, want %+v", plugins, want)
+	}
+}
+
+func TestRunPlugin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugin tests use sh scripts")
+	}
+	dir := t.TempDir()
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	out := filepath.Join(dir, "out")
+	writePluginScript(t, dir, "fastbrew-hello", `echo "$FASTBREW_PLUGIN $FASTBREW_CONFIG $*" > `+out+`; exit 7`, 0755)
+
+	if _, ok := lookupPlugin("install"); ok {
+		t.Error("built-in commands should not be looked up as plugins")
+	}
+	if _, ok := lookupPlugin("--json"); ok {
+		t.Error("flags should not be looked up as plugins")
+	}
+	path, ok := lookupPlugin("hello")
+	if !ok {
+		t.Fatal("expected fastbrew-hello to be found")
+	}
+	if code := runPlugin("hello", path, []string{"a", "b"}); code != 7 {
+		t.Errorf("runPlugin() = %d, want the plugin's exit status 7", code)
+	}
+	data, _ := os.ReadFile(out)
+	if !strings.HasPrefix(string(data), "hello ") || !strings.HasSuffix(string(data), "config.json a b\n") {
+		t.Errorf("unexpected plugin output %q", data)
+	}
+}
+
+func TestTranslateBrewArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want [][]string
+	}{
+		{[]string{"ls", "--versions", "--casks"}, [][]string{{"list", "--versions", "--cask"}}},
+		{[]string{"rm", "--formula", "-f", "jq"}, [][]string{{"uninstall", "--force", "jq"}}},
+		{[]string{"up"}, [][]string{{"update"}, {"upgrade"}}},
+		{[]string{"install", "--cask", "-v", "firefox"}, [][]string{{"install", "--verbose", "firefox"}}},
+		{[]string{"deps", "jq"}, [][]string{{"deps", "jq", "--recursive"}}},
+		{[]string{"deps", "--1", "jq"}, [][]string{{"deps", "jq"}}},
+		{[]string{"services", "start", "postgresql"}, [][]string{{"services", "start", "postgresql"}}},
+	}
+	for _, tt := range tests {
+		plan := translateBrewArgs(tt.args)
+		if plan.fallback != "" || !reflect.DeepEqual(plan.commands, tt.want) {
+			t.Errorf("translateBrewArgs(%q) = %q (fallback %q), want %q", tt.args, plan.commands, plan.fallback, tt.want)
+		}
+	}
+
+	for _, args := range [][]string{{"bump-formula-pr", "jq"}, {"install", "--build-from-source", "jq"}, {"services", "cleanup"}, {"up", "jq"}} {
+		if plan := translateBrewArgs(args); plan.fallback == "" {
+			t.Errorf("Expected translateBrewArgs(%q) to fall back to brew, got %q", args, plan.commands)
+		}
+	}
+}
+
+func TestFindRealBrew(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("brew is not available on Windows")
+	}
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	shim, brewDir := t.TempDir(), t.TempDir()
+	if err := os.Symlink(self, filepath.Join(shim, "brew")); err != nil {
+		t.Fatal(err)
+	}
+	writePluginScript(t, brewDir, "brew", "exit 0", 0755)
+
+	got, err := findRealBrew(strings.Join([]string{shim, brewDir}, string(os.PathListSeparator)))
+	if err != nil || got != filepath.Join(brewDir, "brew") {
+		t.Errorf("findRealBrew() = %q, %v; want the brew that is not fastbrew", got, err)
+	}
+	if _, err := findRealBrew(shim); err == nil {
+		t.Error("Expected no brew to be found when the only one is fastbrew")
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := map[string]time.Duration{
+		"":    0,
+		"7d":  7 * 24 * time.Hour,
+		"36h": 36 * time.Hour,
+		"90m": 90 * time.Minute,
+	}
+	for in, want := range tests {
+		if got, err := parseAge(in); err != nil || got != want {
+			t.Errorf("parseAge(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"d", "-1d", "soon", "-2h"} {
+		if _, err := parseAge(in); err == nil {
+			t.Errorf("parseAge(%q) should fail", in)
+		}
+	}
+}
+
+func TestWriteDownloadsTable(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	downloads := []*resume.PartialDownload{{
+		URL:             "https://ghcr.io/v2/homebrew/core/wget/blobs/sha256:abc",
+		LocalPath:       "/cache/wget--1.21.4.bottle.tar.gz",
+		TotalSize:       4 << 20,
+		DownloadedBytes: 1 << 20,
+		State:           resume.StateFailed,
+		UpdatedAt:       now.Add(-3 * 24 * time.Hour),
+	}}
+	var out bytes.Buffer
+	writeDownloadsTable(&out, downloads, now)
+	want := "FILE                        PROGRESS       AGE  STATE   URL\n" +
+		"wget--1.21.4.bottle.tar.gz  25% of 4.0 MB  3d   failed  https://ghcr.io/v2/homebrew/core/wget/blobs/sha256:abc\n"
+	if out.String() != want {
+		t.Errorf("writeDownloadsTable() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestWriteLinksTable(t *testing.T) {
+	links := []brew.LinkedFile{
+		{KegLink: brew.KegLink{Path: "bin/jq", Target: "/opt/homebrew/Cellar/jq/1.7.1/bin/jq"}, Version: "1.7.1", Status: "ok"},
+		{KegLink: brew.KegLink{Path: "share/man/man1/jq.1", Target: "/opt/homebrew/Cellar/jq/1.7.1/share/man/man1/jq.1"}, Version: "1.7.1", Status: "missing"},
+	}
+	var out bytes.Buffer
+	writeLinksTable(&out, links)
+	want := "LINK                 TARGET                                             STATUS\n" +
+		"bin/jq               /opt/homebrew/Cellar/jq/1.7.1/bin/jq               ok\n" +
+		"share/man/man1/jq.1  /opt/homebrew/Cellar/jq/1.7.1/share/man/man1/jq.1  missing\n"
+	if out.String() != want {
+		t.Errorf("writeLinksTable() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// brewCommand maps one brew command to its fastbrew equivalent.
+type brewCommand struct {
+	command string
+	// flags maps brew flags to fastbrew ones. An empty value drops a flag
+	// fastbrew has no use for, such as --formula where it tells formulae
+	// and casks apart itself. Flags not listed fall back to brew.
+	flags map[string]string
+	// subcommands, when set, are the only first arguments fastbrew
+	// supports (e.g. services start); others fall back to brew.
+	subcommands []string
+}
+
+var (
+	dropFormulaCask = map[string]string{"--formula": "", "--formulae": "", "--cask": "", "--casks": ""}
+	dropVerbose     = map[string]string{"-v": "", "--verbose": ""}
+)
+
+// brewCommands lists the brew commands fastbrew can run. brew's own
+// aliases (ls, rm, ...) are resolved through brewAliases first.
+var brewCommands = map[string]brewCommand{
+	"list": {command: "list", flags: map[string]string{
+		"--formula": "--formula", "--formulae": "--formula", "--cask": "--cask", "--casks": "--cask",
+		"--versions": "--versions", "--installed-on-request": "--installed-on-request",
+		"--installed-as-dependency": "--installed-as-dependency",
+	}},
+	"uninstall": {command: "uninstall", flags: mergeFlags(dropFormulaCask, map[string]string{
+		"--zap": "--zap", "--force": "--force", "-f": "--force",
+	})},
+	"install": {command: "install", flags: mergeFlags(dropFormulaCask, map[string]string{
+		"-q": "--quiet", "--quiet": "--quiet", "-v": "--verbose", "--verbose": "--verbose",
+		"--include-test": "--include-test", "--force-bottle": "",
+	})},
+	"upgrade": {command: "upgrade", flags: map[string]string{
+		"-n": "--dry-run", "--dry-run": "--dry-run", "-q": "--quiet", "--quiet": "--quiet",
+		"-v": "", "--verbose": "",
+	}},
+	"update":     {command: "update", flags: map[string]string{"--force": "--force", "-f": "--force", "-v": "--verbose", "--verbose": "--verbose"}},
+	"search":     {command: "search", flags: map[string]string{"--formula": "--formula", "--formulae": "--formula", "--cask": "--cask", "--casks": "--cask", "--desc": "--desc"}},
+	"info":       {command: "info", flags: dropFormulaCask},
+	"outdated":   {command: "outdated", flags: mergeFlags(dropVerbose, map[string]string{"--formula": "--formula", "--formulae": "--formula", "--cask": "--cask", "--casks": "--cask", "-g": "--greedy", "--greedy": "--greedy", "-q": "--quiet", "--quiet": "--quiet"})},
+	"deps":       {command: "deps", flags: map[string]string{"--tree": "--tree", "--installed": "--installed", "--include-build": "--include-build", "--include-test": "--include-test", "--include-optional": "--include-optional", "--skip-recommended": "--skip-recommended", "--1": ""}},
+	"uses":       {command: "uses", flags: map[string]string{"--installed": "--installed"}},
+	"leaves":     {command: "leaves"},
+	"missing":    {command: "missing"},
+	"cleanup":    {command: "cleanup", flags: map[string]string{"-n": "--dry-run", "--dry-run": "--dry-run"}},
+	"doctor":     {command: "doctor", flags: map[string]string{"-v": "--verbose", "--verbose": "--verbose"}},
+	"reinstall":  {command: "reinstall", flags: mergeFlags(dropFormulaCask, map[string]string{"-v": "--verbose", "--verbose": "--verbose", "--force": "--force", "-f": "--force"})},
+	"autoremove": {command: "autoremove", flags: map[string]string{"-n": "--dry-run", "--dry-run": "--dry-run"}},
+	"link":       {command: "link", flags: map[string]string{"--overwrite": "--overwrite", "-f": "--force", "--force": "--force", "-n": "--dry-run", "--dry-run": "--dry-run"}},
+	"unlink":     {command: "unlink"},
+	"pin":        {command: "pin"},
+	"unpin":      {command: "unpin"},
+	"tap":        {command: "tap"},
+	"untap":      {command: "untap", flags: map[string]string{"-f": "--force", "--force": "--force"}},
+	"tap-info":   {command: "tap-info", flags: map[string]string{"--installed": "--installed"}},
+	"services":   {command: "services", subcommands: []string{"list", "start", "stop", "restart"}},
+}
+
+// brewAliases are brew's shorthands for the commands above.
+var brewAliases = map[string]string{
+	"ls": "list", "rm": "uninstall", "remove": "uninstall", "-S": "search",
+	"abv": "info", "dr": "doctor", "ln": "link", "instal": "install",
+}
+
+func mergeFlags(maps ...map[string]string) map[string]string {
+	out := make(map[string]string)
+	for _, m := range maps {
+		for k, v := range m {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// compatPlan is what a brew command line translates to: the fastbrew
+// command lines to run in order, or the reason it is left to brew.
+type compatPlan struct {
+	commands [][]string
+	fallback string
+}
+
+// translateBrewArgs maps a brew command line to fastbrew. `brew up` runs
+// update and then upgrade; brew deps is recursive unless --1 is given,
+// while fastbrew deps is direct unless --recursive is.
+func translateBrewArgs(args []string) compatPlan {
+	if len(args) == 0 {
+		return compatPlan{fallback: "no command given"}
+	}
+	name := args[0]
+	if name == "up" {
+		if len(args) > 1 {
+			return compatPlan{fallback: "brew up takes no arguments here"}
+		}
+		return compatPlan{commands: [][]string{{"update"}, {"upgrade"}}}
+	}
+	if alias, ok := brewAliases[name]; ok {
+		name = alias
+	}
+	spec, ok := brewCommands[name]
+	if !ok {
+		return compatPlan{fallback: fmt.Sprintf("fastbrew has no equivalent of brew %s", args[0])}
+	}
+
+	out := []string{spec.command}
+	direct := false
+	for i, arg := range args[1:] {
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			if i == 0 && spec.subcommands != nil && !slices.Contains(spec.subcommands, arg) {
+				return compatPlan{fallback: fmt.Sprintf("fastbrew has no equivalent of brew %s %s", name, arg)}
+			}
+			out = append(out, arg)
+			continue
+		}
+		if arg == "--" {
+			out = append(out, args[i+2:]...)
+			break
+		}
+		mapped, ok := spec.flags[arg]
+		if !ok {
+			return compatPlan{fallback: fmt.Sprintf("fastbrew %s does not support brew's %s", spec.command, arg)}
+		}
+		if arg == "--1" {
+			direct = true
+		}
+		if mapped != "" && !slices.Contains(out, mapped) {
+			out = append(out, mapped)
+		}
+	}
+	if spec.command == "deps" && !direct {
+		out = append(out, "--recursive")
+	}
+	return compatPlan{commands: [][]string{out}}
+}
+
+var compatCmd = &cobra.Command{
+	Use:   "compat <brew command> [args...]",
+	Short: "Run a brew command line with fastbrew",
+	Long: `Run a brew command line with the fastbrew command that does the same,
+translating aliases and flags: 'fastbrew compat ls --versions' runs
+'fastbrew list --versions', 'rm' runs uninstall, and 'up' runs update
+followed by upgrade. brew deps lists dependencies recursively, so deps gets
+--recursive unless --1 is given.
+
+Commands and flags fastbrew has no equivalent for are run by the real brew
+found on PATH, with a warning. To use it for everyday brew commands:
+
+  alias brew='fastbrew compat'`,
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 || args[0] == "-h" || args[0] == "--help" {
+			cmd.Help()
+			return
+		}
+		plan := translateBrewArgs(args)
+		if plan.fallback != "" {
+			fmt.Fprintf(stderr, "⚠️  %s; running brew instead\n", plan.fallback)
+			brew, err := findRealBrew(os.Getenv("PATH"))
+			if err != nil {
+				exitWithError("Error", err)
+			}
+			os.Exit(runCompat(brew, args))
+		}
+
+		self, err := os.Executable()
+		if err != nil {
+			exitWithError("Error", err)
+		}
+		for _, command := range plan.commands {
+			if code := runCompat(self, command); code != 0 {
+				os.Exit(code)
+			}
+		}
+	},
+}
+
+// runCompat runs path with args attached to this terminal and returns its
+// exit status. As with plugins, Ctrl-C reaches the child and fastbrew
+// waits for it.
+func runCompat(path string, args []string) int {
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	defer signal.Stop(signals)
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exitErr) && exitErr.ExitCode() >= 0:
+		return exitErr.ExitCode()
+	case errors.As(err, &exitErr):
+		return exitInterrupted
+	default:
+		fmt.Fprintf(stderr, "Error: running %s: %v\n", filepath.Base(path), err)
+		return 1
+	}
+}
+
+// findRealBrew returns the first brew in pathList that is not fastbrew
+// itself, which it is when brew is a link to fastbrew.
+func findRealBrew(pathList string) (string, error) {
+	self, _ := os.Executable()
+	self, _ = filepath.EvalSymlinks(self)
+	for _, dir := range filepath.SplitList(pathList) {
+		if dir == "" {
+			continue
+		}
+		path := filepath.Join(dir, "brew")
+		if !isExecutableFile(path) {
+			continue
+		}
+		if resolved, err := filepath.EvalSymlinks(path); err == nil && resolved == self {
+			continue
+		}
+		return path, nil
+	}
+	return "", fmt.Errorf("brew was not found on PATH")
+}
+
+func init() {
+	rootCmd.AddCommand(compatCmd)
+}
@@ -1,7 +1,13 @@
 package cmd
 
 import (
+	"fastbrew/internal/brew"
+	"fastbrew/internal/config"
+	"fastbrew/internal/services"
+	"io"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -51,6 +57,173 @@ PowerShell:
 	},
 }
 
+// Completion functions run on every <TAB>, so they only read local state:
+// the saved prefix index, the Cellar and the service manager. None of them
+// may print, since shells read completions from stdout.
+
+// completionClient returns a silent client, or nil if none can be made.
+func completionClient() *brew.Client {
+	client, err := newBrewClient()
+	if err != nil {
+		return nil
+	}
+	client.Out = io.Discard
+	return client
+}
+
+// completeAvailablePackages completes formula, cask and tap formula names.
+func completeAvailablePackages(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	client := completionClient()
+	if client == nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return withoutArgs(client.CompleteNames(toComplete), args), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeInstalledPackages completes installed formulae and casks.
+func completeInstalledPackages(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return installedCompletions(args, toComplete, false), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeInstalledFormulae completes installed formulae only.
+func completeInstalledFormulae(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return installedCompletions(args, toComplete, true), cobra.ShellCompDirectiveNoFileComp
+}
+
+func installedCompletions(args []string, toComplete string, formulaeOnly bool) []string {
+	client := completionClient()
+	if client == nil {
+		return nil
+	}
+	pkgs, err := client.ListInstalledNative()
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, pkg := range pkgs {
+		if formulaeOnly && pkg.IsCask {
+			continue
+		}
+		if strings.HasPrefix(pkg.Name, toComplete) {
+			names = append(names, pkg.Name)
+		}
+	}
+	sort.Strings(names)
+	return withoutArgs(names, args)
+}
+
+// completeSwitch completes an installed formula, then its installed
+// versions.
+func completeSwitch(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return completeInstalledFormulae(cmd, args, toComplete)
+	}
+	client := completionClient()
+	if len(args) > 1 || client == nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	pkgs, err := client.ListInstalledNative()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var versions []string
+	for _, pkg := range pkgs {
+		if pkg.Name != args[0] || pkg.IsCask {
+			continue
+		}
+		for _, v := range pkg.Versions {
+			if strings.HasPrefix(v, toComplete) {
+				versions = append(versions, v)
+			}
+		}
+	}
+	return versions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completePinnedPackages completes the packages that are pinned.
+func completePinnedPackages(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	pinned, err := brew.LoadPinned()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var names []string
+	for name := range pinned {
+		if strings.HasPrefix(name, toComplete) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeServices completes the services known to the service manager in
+// the scope selected with --scope.
+func completeServices(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	mgr := services.NewServiceManager()
+	if serviceScope != "" {
+		scoped, err := services.NewServiceManagerWithScope(services.ServiceScope(serviceScope))
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		mgr = scoped
+	}
+	svcs, err := mgr.ListServices()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var names []string
+	for _, svc := range svcs {
+		if strings.HasPrefix(svc.Name, toComplete) {
+			names = append(names, svc.Name+"\t"+string(svc.Status))
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeConfigKeys completes the first argument with configuration keys.
+func completeConfigKeys(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var keys []string
+	for _, k := range config.Keys() {
+		if strings.HasPrefix(k.Name, toComplete) {
+			keys = append(keys, k.Name+"\t"+k.Help)
+		}
+	}
+	for _, prefix := range []string{config.MirrorKeyPrefix, config.CredentialKeyPrefix, config.ConstraintKeyPrefix, config.HookKeyPrefix} {
+		if strings.HasPrefix(prefix, toComplete) {
+			keys = append(keys, prefix)
+		}
+	}
+	return keys, cobra.ShellCompDirectiveNoFileComp
+}
+
+// singleArg restricts a completion function to the first argument.
+func singleArg(fn cobra.CompletionFunc) cobra.CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return fn(cmd, args, toComplete)
+	}
+}
+
+// withoutArgs drops names already given on the command line.
+func withoutArgs(names, args []string) []string {
+	if len(args) == 0 {
+		return names
+	}
+	given := make(map[string]bool, len(args))
+	for _, arg := range args {
+		given[arg] = true
+	}
+	out := names[:0]
+	for _, name := range names {
+		if !given[name] {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
 func init() {
 	rootCmd.AddCommand(completionCmd)
 }
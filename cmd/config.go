@@ -2,12 +2,12 @@ package cmd
 
 import (
 	"encoding/json"
+	"fastbrew/internal/brew"
 	"fastbrew/internal/config"
 	"fmt"
 	"os"
-	"strconv"
 	"strings"
-	"time"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 )
@@ -21,74 +21,206 @@ var configShowCmd = &cobra.Command{
 	Use:   "show",
 	Short: "Show current configuration",
 	Run: func(cmd *cobra.Command, args []string) {
-		cfg := config.Get()
+		cfg := *config.Get()
+		// Never print stored tokens.
+		if len(cfg.Credentials) > 0 {
+			masked := make(map[string]string, len(cfg.Credentials))
+			for host := range cfg.Credentials {
+				masked[host] = "********"
+			}
+			cfg.Credentials = masked
+		}
 		data, _ := json.MarshalIndent(cfg, "", "  ")
-		fmt.Println(string(data))
-		fmt.Printf("\nConfig file: %s\n", config.GetConfigPath())
+		fmt.Fprintln(stdout, string(data))
+		fmt.Fprintf(stdout, "\nConfig file: %s\n", config.GetConfigPath())
+	},
+}
+
+var configGetCmd = &cobra.Command{
+	Use:               "get <key>",
+	Short:             "Print a configuration value",
+	Long:              "Print the effective value of a key, including any FASTBREW_* environment override.",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: singleArg(completeConfigKeys),
+	Run: func(cmd *cobra.Command, args []string) {
+		value, err := config.Get().Value(args[0])
+		if err != nil {
+			exitWithError("Error", err)
+		}
+		if config.IsSecretKey(args[0]) && value != "" {
+			value = "********"
+		}
+		fmt.Fprintln(stdout, value)
 	},
 }
 
 var configSetCmd = &cobra.Command{
-	Use:   "set <key> <value>",
-	Short: "Set a configuration value",
-	Args:  cobra.ExactArgs(2),
+	Use:               "set <key> <value>",
+	Short:             "Set a configuration value",
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: singleArg(completeConfigKeys),
 	Run: func(cmd *cobra.Command, args []string) {
-		cfg := config.Get()
 		key, value := args[0], args[1]
-
-		switch key {
-		case "parallel_downloads":
-			n, err := strconv.Atoi(value)
-			if err != nil || n < 1 {
-				fmt.Println("Error: parallel_downloads must be a positive integer")
-				os.Exit(1)
+		if strings.HasPrefix(key, config.ConstraintKeyPrefix) && value != "" && value != "none" {
+			if _, err := brew.ParseVersionConstraint(value); err != nil {
+				exitWithError("Error", err)
 			}
-			cfg.ParallelDownloads = n
-		case "show_progress":
-			cfg.ShowProgress = parseConfigBool(value)
-		case "auto_cleanup":
-			cfg.AutoCleanup = parseConfigBool(value)
-		case "verbose":
-			cfg.Verbose = parseConfigBool(value)
-		case "daemon.enabled":
-			cfg.Daemon.Enabled = parseConfigBool(value)
-		case "daemon.auto_start":
-			cfg.Daemon.AutoStart = parseConfigBool(value)
-		case "daemon.idle_timeout":
-			if _, err := time.ParseDuration(value); err != nil {
-				fmt.Printf("Error: invalid duration for daemon.idle_timeout: %v\n", err)
-				os.Exit(1)
+		}
+		if name, ok := strings.CutPrefix(key, config.HookKeyPrefix); ok {
+			if _, _, err := brew.ParseHookKey(name); err != nil {
+				exitWithError("Error", err)
 			}
-			cfg.Daemon.IdleTimeout = value
-		case "daemon.socket_path":
-			cfg.Daemon.SocketPath = value
-		case "daemon.prewarm":
-			cfg.Daemon.Prewarm = parseConfigBool(value)
-		default:
-			fmt.Printf("Unknown config key: %s\n", key)
-			fmt.Println("Available keys: parallel_downloads, show_progress, auto_cleanup, verbose, daemon.enabled, daemon.auto_start, daemon.idle_timeout, daemon.socket_path, daemon.prewarm")
-			os.Exit(1)
 		}
+		cfg := loadConfigFile()
+		if err := cfg.SetValue(key, value); err != nil {
+			exitWithError("Error", err)
+		}
+		saveConfigFile(cfg)
+
+		if config.IsSecretKey(key) {
+			value = "********"
+		}
+		fmt.Fprintf(stdout, "✅ Set %s = %s\n", key, value)
+		warnConfigEnvOverride(key)
+	},
+}
 
-		if err := cfg.Save(); err != nil {
-			fmt.Printf("Error saving config: %v\n", err)
-			os.Exit(1)
+var configUnsetCmd = &cobra.Command{
+	Use:               "unset <key>",
+	Short:             "Restore a configuration value to its default",
+	Long:              "Restore a key to its default value, or remove a mirrors.<host>, credentials.<host>, constraints.<package> or hooks.<event> entry.",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: singleArg(completeConfigKeys),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigFile()
+		if err := cfg.Unset(args[0]); err != nil {
+			exitWithError("Error", err)
 		}
-		fmt.Printf("✅ Set %s = %s\n", key, value)
+		saveConfigFile(cfg)
+		fmt.Fprintf(stdout, "✅ Unset %s\n", args[0])
+		warnConfigEnvOverride(args[0])
 	},
 }
 
+// ConfigEntryView is one row of `config list`.
+type ConfigEntryView struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+	Env    string `json:"env,omitempty"`
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List configuration values and where they come from",
+	Long: `List every key with its effective value and its source: "env" when a
+FASTBREW_* environment variable overrides it, "file" when it is set in the
+config file, or "default".
+
+Precedence, highest first: environment variables, the config file, defaults.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		entries := configEntries(config.Get(), loadConfigFile(), config.DefaultConfig())
+		if jsonOutput {
+			printJSON(entries)
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+		for _, e := range entries {
+			value := e.Value
+			if value == "" {
+				value = `""`
+			}
+			source := e.Source
+			if e.Source == "env" {
+				source = "env (" + e.Env + ")"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", e.Key, value, source)
+		}
+		w.Flush()
+	},
+}
+
+// configEntries describes every fixed key plus the mirrors and credentials
+// that are set. effective includes environment overrides; file does not.
+func configEntries(effective, file, defaults *config.Config) []ConfigEntryView {
+	var entries []ConfigEntryView
+	for _, k := range config.Keys() {
+		value, _ := effective.Value(k.Name)
+		fileValue, _ := file.Value(k.Name)
+		defaultValue, _ := defaults.Value(k.Name)
+
+		entry := ConfigEntryView{Key: k.Name, Value: value, Source: "default"}
+		switch {
+		case os.Getenv(k.Env) != "" && value != fileValue:
+			entry.Source, entry.Env = "env", k.Env
+		case fileValue != defaultValue:
+			entry.Source = "file"
+		}
+		entries = append(entries, entry)
+	}
+	for _, key := range effective.MapKeys() {
+		value, _ := effective.Value(key)
+		if config.IsSecretKey(key) {
+			value = "********"
+		}
+		entries = append(entries, ConfigEntryView{Key: key, Value: value, Source: "file"})
+	}
+	return entries
+}
+
+// loadConfigFile reads the config file without environment overrides, for
+// changes that are saved back.
+func loadConfigFile() *config.Config {
+	cfg, err := config.LoadFile()
+	if err != nil {
+		exitWithError("Error reading config", err)
+	}
+	return cfg
+}
+
+func saveConfigFile(cfg *config.Config) {
+	if err := cfg.Save(); err != nil {
+		exitWithError("Error saving config", err)
+	}
+}
+
+// warnConfigEnvOverride points out that a change has no effect while an
+// environment variable overrides the key.
+func warnConfigEnvOverride(key string) {
+	k, ok := config.LookupKey(key)
+	if !ok {
+		return
+	}
+	if v := os.Getenv(k.Env); v != "" {
+		fmt.Fprintf(stderr, "⚠️  %s=%s is set and takes precedence over the config file\n", k.Env, v)
+	}
+}
+
+// configKeysHelp lists the keys, their environment variables and what they
+// do, for the set command's help.
+func configKeysHelp() string {
+	var b strings.Builder
+	b.WriteString("Set a configuration value in the config file. Values are validated before\nthey are saved.\n\nKeys:\n")
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	for _, k := range config.Keys() {
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", k.Name, k.Env, k.Help)
+	}
+	fmt.Fprintf(w, "  %s<host>\t\t%s\n", config.MirrorKeyPrefix, "Mirror base URL for host, or none")
+	fmt.Fprintf(w, "  %s<host>\t\t%s\n", config.CredentialKeyPrefix, "user:secret or token for host, or none")
+	fmt.Fprintf(w, "  %s<package>\t\t%s\n", config.ConstraintKeyPrefix, "Versions upgrades may move to, e.g. \"<21\" or \"=16.*\", or none")
+	fmt.Fprintf(w, "  %s<event>[.<package>]\t\t%s\n", config.HookKeyPrefix, "Shell command run on pre/post-install, -upgrade or -uninstall, or none")
+	w.Flush()
+	return strings.TrimRight(b.String(), "\n")
+}
+
 func init() {
+	configSetCmd.Long = configKeysHelp()
 	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configGetCmd)
 	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configUnsetCmd)
+	configCmd.AddCommand(configListCmd)
 	rootCmd.AddCommand(configCmd)
 }
-
-func parseConfigBool(value string) bool {
-	switch strings.ToLower(strings.TrimSpace(value)) {
-	case "1", "true", "yes", "on":
-		return true
-	default:
-		return false
-	}
-}
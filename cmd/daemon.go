@@ -16,6 +16,14 @@ import (
 var daemonCmd = &cobra.Command{
 	Use:   "daemon",
 	Short: "Manage fastbrewd background process",
+	Long: `Manage fastbrewd, which keeps the package index in memory and runs
+installs, upgrades and uninstalls as background jobs.
+
+Other programs can use it through JSON-RPC 2.0, one message per line, on
+the socket 'fastbrew daemon status' prints. Methods include search, info,
+deps, list, outdated, install, upgrade, uninstall, job_status, job_watch,
+which sends job_event notifications until the job finishes, and
+progress_watch, which sends every download progress event.`,
 }
 
 var daemonStartCmd = &cobra.Command{
@@ -23,10 +31,10 @@ var daemonStartCmd = &cobra.Command{
 	Short: "Start fastbrewd in the background",
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := startDaemonProcess(false); err != nil {
-			fmt.Printf("Error starting daemon: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Error starting daemon: %v\n", err)
+			os.Exit(exitCode(err))
 		}
-		fmt.Println("✅ fastbrewd started")
+		fmt.Fprintln(stdout, "✅ fastbrewd started")
 	},
 }
 
@@ -37,20 +45,20 @@ var daemonStopCmd = &cobra.Command{
 		cfg := config.Get()
 		client := daemon.NewClient(cfg.GetDaemonSocketPath(), Version)
 		if err := client.Shutdown(); err != nil {
-			fmt.Printf("Error stopping daemon: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Error stopping daemon: %v\n", err)
+			os.Exit(exitCode(err))
 		}
 
 		deadline := time.Now().Add(3 * time.Second)
 		for time.Now().Before(deadline) {
 			if _, err := client.Status(); err != nil {
-				fmt.Println("✅ fastbrewd stopped")
+				fmt.Fprintln(stdout, "✅ fastbrewd stopped")
 				return
 			}
 			time.Sleep(100 * time.Millisecond)
 		}
 
-		fmt.Println("⚠️  stop signal sent, daemon may still be shutting down")
+		fmt.Fprintln(stdout, "⚠️  stop signal sent, daemon may still be shutting down")
 	},
 }
 
@@ -62,16 +70,16 @@ var daemonStatusCmd = &cobra.Command{
 		client := daemon.NewClient(cfg.GetDaemonSocketPath(), Version)
 		status, err := client.Status()
 		if err != nil {
-			fmt.Printf("fastbrewd: stopped (%v)\n", err)
+			fmt.Fprintf(stdout, "fastbrewd: stopped (%v)\n", err)
 			return
 		}
 
-		fmt.Println("fastbrewd: running")
-		fmt.Printf("pid: %d\n", status.PID)
-		fmt.Printf("socket: %s\n", status.SocketPath)
-		fmt.Printf("started: %s\n", status.StartedAt.Format(time.RFC3339))
-		fmt.Printf("last activity: %s\n", status.LastActivityAt.Format(time.RFC3339))
-		fmt.Printf("idle timeout: %ds\n", status.IdleTimeoutSecs)
+		fmt.Fprintln(stdout, "fastbrewd: running")
+		fmt.Fprintf(stdout, "pid: %d\n", status.PID)
+		fmt.Fprintf(stdout, "socket: %s\n", status.SocketPath)
+		fmt.Fprintf(stdout, "started: %s\n", status.StartedAt.Format(time.RFC3339))
+		fmt.Fprintf(stdout, "last activity: %s\n", status.LastActivityAt.Format(time.RFC3339))
+		fmt.Fprintf(stdout, "idle timeout: %ds\n", status.IdleTimeoutSecs)
 	},
 }
 
@@ -83,30 +91,30 @@ var daemonStatsCmd = &cobra.Command{
 		client := daemon.NewClient(cfg.GetDaemonSocketPath(), Version)
 		stats, err := client.Stats()
 		if err != nil {
-			fmt.Printf("Error reading daemon stats: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Error reading daemon stats: %v\n", err)
+			os.Exit(exitCode(err))
 		}
 
-		fmt.Printf("uptime_seconds: %d\n", stats.UptimeSeconds)
-		fmt.Printf("requests_total: %d\n", stats.RequestsTotal)
-		fmt.Printf("cache_hits: %d\n", stats.CacheHits)
-		fmt.Printf("cache_misses: %d\n", stats.CacheMisses)
+		fmt.Fprintf(stdout, "uptime_seconds: %d\n", stats.UptimeSeconds)
+		fmt.Fprintf(stdout, "requests_total: %d\n", stats.RequestsTotal)
+		fmt.Fprintf(stdout, "cache_hits: %d\n", stats.CacheHits)
+		fmt.Fprintf(stdout, "cache_misses: %d\n", stats.CacheMisses)
 		if stats.LastWarmupAt != nil {
-			fmt.Printf("last_warmup_at: %s\n", stats.LastWarmupAt.Format(time.RFC3339))
+			fmt.Fprintf(stdout, "last_warmup_at: %s\n", stats.LastWarmupAt.Format(time.RFC3339))
 		}
 
-		fmt.Printf("installed_cached: %t\n", stats.InstalledCached)
-		fmt.Printf("outdated_cached: %t\n", stats.OutdatedCached)
-		fmt.Printf("leaves_cached: %t\n", stats.LeavesCached)
-		fmt.Printf("search_entries: %d\n", stats.SearchEntries)
-		fmt.Printf("deps_entries: %d\n", stats.DepsCacheEntries)
-		fmt.Printf("tap_entries: %d\n", stats.TapCacheEntries)
-		fmt.Printf("services_entries: %d\n", stats.ServicesEntries)
-		fmt.Printf("formula_meta_entries: %d\n", stats.FormulaMetaEntries)
-		fmt.Printf("cask_meta_entries: %d\n", stats.CaskMetaEntries)
-		fmt.Printf("jobs_total: %d\n", stats.JobsTotal)
-		fmt.Printf("jobs_running: %d\n", stats.JobsRunning)
-		fmt.Printf("jobs_failed: %d\n", stats.JobsFailed)
+		fmt.Fprintf(stdout, "installed_cached: %t\n", stats.InstalledCached)
+		fmt.Fprintf(stdout, "outdated_cached: %t\n", stats.OutdatedCached)
+		fmt.Fprintf(stdout, "leaves_cached: %t\n", stats.LeavesCached)
+		fmt.Fprintf(stdout, "search_entries: %d\n", stats.SearchEntries)
+		fmt.Fprintf(stdout, "deps_entries: %d\n", stats.DepsCacheEntries)
+		fmt.Fprintf(stdout, "tap_entries: %d\n", stats.TapCacheEntries)
+		fmt.Fprintf(stdout, "services_entries: %d\n", stats.ServicesEntries)
+		fmt.Fprintf(stdout, "formula_meta_entries: %d\n", stats.FormulaMetaEntries)
+		fmt.Fprintf(stdout, "cask_meta_entries: %d\n", stats.CaskMetaEntries)
+		fmt.Fprintf(stdout, "jobs_total: %d\n", stats.JobsTotal)
+		fmt.Fprintf(stdout, "jobs_running: %d\n", stats.JobsRunning)
+		fmt.Fprintf(stdout, "jobs_failed: %d\n", stats.JobsFailed)
 	},
 }
 
@@ -117,10 +125,10 @@ var daemonWarmupCmd = &cobra.Command{
 		cfg := config.Get()
 		client := daemon.NewClient(cfg.GetDaemonSocketPath(), Version)
 		if err := client.Warmup(); err != nil {
-			fmt.Printf("Error warming daemon cache: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Error warming daemon cache: %v\n", err)
+			os.Exit(exitCode(err))
 		}
-		fmt.Println("✅ daemon warmup complete")
+		fmt.Fprintln(stdout, "✅ daemon warmup complete")
 	},
 }
 
@@ -135,14 +143,16 @@ var daemonServeCmd = &cobra.Command{
 			IdleTimeout:   cfg.GetDaemonIdleTimeout(),
 			BinaryVersion: Version,
 			Prewarm:       cfg.Daemon.Prewarm,
+			Prefix:        cfg.GetPrefix(),
+			CacheDir:      cfg.GetCacheDir(),
 		})
 		if err != nil {
-			fmt.Printf("Error initializing daemon: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Error initializing daemon: %v\n", err)
+			os.Exit(exitCode(err))
 		}
 		if err := server.ServeUntilInterrupted(); err != nil {
-			fmt.Printf("Daemon exited with error: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Daemon exited with error: %v\n", err)
+			os.Exit(exitCode(err))
 		}
 	},
 }
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"fastbrew/internal/brew"
 	"fmt"
 	"os"
 	"strings"
@@ -8,47 +9,151 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var (
+	depsTree      bool
+	depsRecursive bool
+	depsOpts      brew.DepsOptions
+)
+
 var depsCmd = &cobra.Command{
 	Use:   "deps [package...]",
 	Short: "Show dependencies for packages (fast cached lookup)",
-	Args:  cobra.MinimumNArgs(1),
+	Long: `Show the direct dependencies of each formula using the cached index.
+
+Use --recursive for every transitive dependency, or --tree to render the
+full dependency tree.`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeAvailablePackages,
 	Run: func(cmd *cobra.Command, args []string) {
-		var deps []string
-		if daemonClient, daemonErr := getDaemonClientForRead(); daemonClient != nil {
-			daemonDeps, err := daemonClient.Deps(args)
-			if err == nil {
-				deps = daemonDeps
-			} else {
+		opts := depsOpts
+
+		if depsRecursive && !depsTree && opts == (brew.DepsOptions{}) {
+			if daemonClient, daemonErr := getDaemonClientForRead(); daemonClient != nil {
+				deps, err := daemonClient.Deps(args)
+				if err == nil {
+					printDeps(deps)
+					return
+				}
 				warnDaemonFallback("deps", err)
+			} else if daemonErr != nil {
+				warnDaemonFallback("deps", daemonErr)
 			}
-		} else if daemonErr != nil {
-			warnDaemonFallback("deps", daemonErr)
 		}
 
-		if deps == nil {
-			client, err := newBrewClient()
-			if err != nil {
-				fmt.Printf("Error: %v\n", err)
-				os.Exit(1)
-			}
+		client, err := newBrewClient()
+		if err != nil {
+			fmt.Fprintf(stdout, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
 
-			localDeps, depsErr := client.ResolveDeps(args)
-			if depsErr != nil {
-				fmt.Printf("Error resolving dependencies: %v\n", depsErr)
-				os.Exit(1)
+		if depsTree {
+			for i, name := range args {
+				root, err := client.DepsTree(name, opts)
+				if err != nil {
+					fmt.Fprintf(stdout, "Error resolving dependencies: %v\n", err)
+					os.Exit(exitCode(err))
+				}
+				if i > 0 {
+					fmt.Fprintln(stdout)
+				}
+				fmt.Fprintln(stdout, root.Name)
+				printDepTree(root.Children, "")
 			}
-			deps = localDeps
+			return
 		}
 
-		if len(deps) == 0 {
-			fmt.Println("No dependencies found.")
+		if depsRecursive {
+			var deps []string
+			for _, name := range args {
+				root, err := client.DepsTree(name, opts)
+				if err != nil {
+					fmt.Fprintf(stdout, "Error resolving dependencies: %v\n", err)
+					os.Exit(exitCode(err))
+				}
+				deps = append(deps, flattenDepTree(root.Children)...)
+			}
+			printDeps(uniqueStrings(deps))
 			return
 		}
 
-		fmt.Printf("📦 Dependencies: %s\n", strings.Join(deps, ", "))
+		for _, name := range args {
+			deps, err := client.Deps(name, opts)
+			if err != nil {
+				fmt.Fprintf(stdout, "Error resolving dependencies: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+			if len(args) > 1 {
+				fmt.Fprintf(stdout, "%s: %s\n", name, strings.Join(deps, " "))
+				continue
+			}
+			printDeps(deps)
+		}
 	},
 }
 
+func printDeps(deps []string) {
+	if len(deps) == 0 {
+		fmt.Fprintln(stdout, "No dependencies found.")
+		return
+	}
+	fmt.Fprintf(stdout, "📦 Dependencies: %s\n", strings.Join(deps, ", "))
+}
+
+// printDepTree renders nodes beneath a parent using box-drawing connectors.
+func printDepTree(nodes []*brew.DepNode, prefix string) {
+	for i, node := range nodes {
+		connector, childPrefix := "├── ", "│   "
+		if i == len(nodes)-1 {
+			connector, childPrefix = "└── ", "    "
+		}
+
+		label := node.Name
+		var notes []string
+		if node.Kind != "" && node.Kind != brew.DependencyRuntime {
+			notes = append(notes, node.Kind)
+		}
+		if node.Installed {
+			notes = append(notes, "installed")
+		}
+		if len(notes) > 0 {
+			label += " (" + strings.Join(notes, ", ") + ")"
+		}
+
+		fmt.Fprintln(stdout, prefix+connector+label)
+		printDepTree(node.Children, prefix+childPrefix)
+	}
+}
+
+// flattenDepTree lists every node in the tree, dependencies before the
+// formulae that need them.
+func flattenDepTree(nodes []*brew.DepNode) []string {
+	var names []string
+	for _, node := range nodes {
+		names = append(names, flattenDepTree(node.Children)...)
+		names = append(names, node.Name)
+	}
+	return names
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var out []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
 func init() {
+	depsCmd.Flags().BoolVar(&depsTree, "tree", false, "Show dependencies as a tree")
+	depsCmd.Flags().BoolVarP(&depsRecursive, "recursive", "r", false, "List all transitive dependencies")
+	depsCmd.Flags().BoolVar(&depsOpts.InstalledOnly, "installed", false, "Only show installed dependencies")
+	depsCmd.Flags().BoolVar(&depsOpts.IncludeBuild, "include-build", false, "Include build dependencies")
+	depsCmd.Flags().BoolVar(&depsOpts.IncludeTest, "include-test", false, "Include test dependencies")
+	depsCmd.Flags().BoolVar(&depsOpts.IncludeOptional, "include-optional", false, "Include optional dependencies")
+	depsCmd.Flags().BoolVar(&depsOpts.SkipRecommended, "skip-recommended", false, "Exclude recommended dependencies")
 	rootCmd.AddCommand(depsCmd)
 }
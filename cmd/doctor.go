@@ -2,35 +2,86 @@ package cmd
 
 import (
 	"fastbrew/internal/brew"
-	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
 )
 
-var verbose bool
+var (
+	verbose      bool
+	doctorVerify bool
+	doctorFix    bool
+)
 
 var doctorCmd = &cobra.Command{
 	Use:   "doctor",
 	Short: "Check system for potential problems",
-	Long:  `Run comprehensive diagnostics on your Homebrew installation to identify issues and suggest fixes.`,
+	Long: `Run comprehensive diagnostics on your Homebrew installation to identify issues and suggest fixes.
+
+With --verify, every keg is also checked against the file list and hashes
+recorded when its bottle was extracted.
+
+With --fix, problems that are safe to repair are repaired: dangling opt
+links, empty Cellar directories, kegs superseded by the linked version and
+resume metadata of deleted partial downloads are removed, and when the prefix is not in PATH the output of 'fastbrew
+shellenv' is appended to your shell's startup file.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		client, err := newBrewClient()
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			exitWithError("Error", err)
 		}
 
+		var unlock func()
+		if doctorFix {
+			unlock = lockFastbrew()
+		}
 		doctor := brew.NewDoctor(client, verbose)
+		doctor.Verify = doctorVerify
+		doctor.Fix = doctorFix
 		results := doctor.RunDiagnostics()
-		doctor.PrintResults(results)
-
+		if unlock != nil {
+			unlock()
+		}
 		exitCode := doctor.GetExitCode(results)
+
+		if jsonOutput {
+			report := DoctorReportView{ExitCode: exitCode, Checks: make([]DoctorCheckView, len(results))}
+			for i, r := range results {
+				report.Checks[i] = DoctorCheckView{
+					Name:       r.Name,
+					Status:     string(r.Status),
+					Message:    r.Message,
+					Suggestion: r.Suggestion,
+					Details:    r.Details,
+					Fixed:      r.Fixed,
+				}
+			}
+			printJSON(report)
+		} else {
+			doctor.PrintResults(results)
+		}
+
 		os.Exit(exitCode)
 	},
 }
 
+type DoctorCheckView struct {
+	Name       string   `json:"name"`
+	Status     string   `json:"status"`
+	Message    string   `json:"message"`
+	Suggestion string   `json:"suggestion,omitempty"`
+	Details    []string `json:"details,omitempty"`
+	Fixed      bool     `json:"fixed,omitempty"`
+}
+
+type DoctorReportView struct {
+	ExitCode int               `json:"exit_code"`
+	Checks   []DoctorCheckView `json:"checks"`
+}
+
 func init() {
 	rootCmd.AddCommand(doctorCmd)
 	doctorCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Show detailed diagnostic output")
+	doctorCmd.Flags().BoolVar(&doctorVerify, "verify", false, "Verify installed kegs against their recorded manifests")
+	doctorCmd.Flags().BoolVar(&doctorFix, "fix", false, "Repair what can be repaired safely: stale Cellar entries and a missing PATH setup")
 }
@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"fastbrew/internal/progress"
+	"fastbrew/internal/resume"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var downloadsClearOlderThan string
+
+// PartialDownloadView is the --json schema for one partial download.
+type PartialDownloadView struct {
+	URL             string                `json:"url"`
+	Path            string                `json:"path"`
+	DownloadedBytes int64                 `json:"downloaded_bytes"`
+	TotalSize       int64                 `json:"total_size"`
+	Progress        float64               `json:"progress"`
+	State           string                `json:"state"`
+	UpdatedAt       time.Time             `json:"updated_at"`
+	History         []StateTransitionView `json:"history"`
+}
+
+// StateTransitionView is one entry of a partial download's state history.
+type StateTransitionView struct {
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	At   time.Time `json:"at"`
+}
+
+func partialDownloadView(pd *resume.PartialDownload) PartialDownloadView {
+	view := PartialDownloadView{
+		URL:             pd.URL,
+		Path:            pd.LocalPath,
+		DownloadedBytes: pd.DownloadedBytes,
+		TotalSize:       pd.TotalSize,
+		Progress:        pd.CalculateProgress(),
+		State:           pd.State.String(),
+		UpdatedAt:       pd.UpdatedAt,
+		History:         make([]StateTransitionView, len(pd.StateHistory)),
+	}
+	for i, t := range pd.StateHistory {
+		view.History[i] = StateTransitionView{From: t.FromState, To: t.ToState, At: t.Timestamp}
+	}
+	return view
+}
+
+var downloadsCmd = &cobra.Command{
+	Use:   "downloads",
+	Short: "List partial downloads that will be resumed",
+	Long: `List the interrupted downloads in the cache with their progress, age and
+state. The next download of the same file continues from where it stopped,
+unless the remote file changed in the meantime.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newBrewClient()
+		if err != nil {
+			exitWithError("Error initializing brew client", err)
+		}
+		downloads, err := client.PartialDownloads()
+		if err != nil {
+			exitWithError("Error reading partial downloads", err)
+		}
+
+		if jsonOutput {
+			views := make([]PartialDownloadView, len(downloads))
+			for i, pd := range downloads {
+				views[i] = partialDownloadView(pd)
+			}
+			printJSON(views)
+			return
+		}
+		if len(downloads) == 0 {
+			fmt.Fprintln(stdout, "No partial downloads.")
+			return
+		}
+		writeDownloadsTable(stdout, downloads, time.Now())
+	},
+}
+
+// writeDownloadsTable prints one row per partial download.
+func writeDownloadsTable(out io.Writer, downloads []*resume.PartialDownload, now time.Time) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tPROGRESS\tAGE\tSTATE\tURL")
+	for _, pd := range downloads {
+		fmt.Fprintf(w, "%s\t%.0f%% of %s\t%s\t%s\t%s\n", filepath.Base(pd.LocalPath), pd.CalculateProgress(),
+			progress.FormatBytes(pd.TotalSize), formatAge(now.Sub(pd.UpdatedAt)), pd.State, pd.URL)
+	}
+	w.Flush()
+}
+
+var downloadsShowCmd = &cobra.Command{
+	Use:   "show <file>",
+	Short: "Show a partial download and its state history",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newBrewClient()
+		if err != nil {
+			exitWithError("Error initializing brew client", err)
+		}
+		downloads, err := client.PartialDownloads()
+		if err != nil {
+			exitWithError("Error reading partial downloads", err)
+		}
+		for _, pd := range downloads {
+			if filepath.Base(pd.LocalPath) != args[0] && pd.LocalPath != args[0] && pd.URL != args[0] {
+				continue
+			}
+			if jsonOutput {
+				printJSON(partialDownloadView(pd))
+				return
+			}
+			fmt.Fprintf(stdout, "File:     %s\n", pd.LocalPath)
+			fmt.Fprintf(stdout, "URL:      %s\n", pd.URL)
+			fmt.Fprintf(stdout, "Progress: %s of %s (%.1f%%)\n", progress.FormatBytes(pd.DownloadedBytes), progress.FormatBytes(pd.TotalSize), pd.CalculateProgress())
+			if pd.ETag != "" {
+				fmt.Fprintf(stdout, "ETag:     %s\n", pd.ETag)
+			}
+			fmt.Fprintf(stdout, "State:    %s\n", pd.State)
+			fmt.Fprintln(stdout, "History:")
+			for _, t := range pd.StateHistory {
+				fmt.Fprintf(stdout, "  %s  %s -> %s\n", t.Timestamp.Format("2006-01-02 15:04:05"), t.FromState, t.ToState)
+			}
+			return
+		}
+		exitWithError("Error", fmt.Errorf("no partial download of %s; see 'fastbrew downloads'", args[0]))
+	},
+}
+
+var downloadsClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove partial downloads",
+	Long: `Remove partial downloads and their resume metadata, so the files are
+downloaded from the start next time. With --older-than (e.g. 7d or 12h),
+only those not touched for that long are removed.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		olderThan, err := parseAge(downloadsClearOlderThan)
+		if err != nil {
+			exitWithError("Error", fmt.Errorf("--older-than: %w", err))
+		}
+		defer lockFastbrew()()
+
+		client, err := newBrewClient()
+		if err != nil {
+			exitWithError("Error initializing brew client", err)
+		}
+		cleared, err := client.ClearPartialDownloads(olderThan)
+		for _, pd := range cleared {
+			fmt.Fprintf(stdout, "  🧽 Removed %s (%s)\n", pd.LocalPath, progress.FormatBytes(pd.DownloadedBytes))
+		}
+		if err != nil {
+			exitWithError("Error clearing partial downloads", err)
+		}
+		if len(cleared) == 0 {
+			fmt.Fprintln(stdout, "✅ No partial downloads to remove.")
+			return
+		}
+		fmt.Fprintf(stdout, "✅ Removed %d partial download(s).\n", len(cleared))
+	},
+}
+
+// parseAge parses a duration such as 7d, 36h or 90m. The empty string is
+// zero.
+func parseAge(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid number of days %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("negative duration %q", s)
+	}
+	return d, nil
+}
+
+// formatAge renders d coarsely: 45s, 12m, 5h, 3d.
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
+
+func init() {
+	downloadsClearCmd.Flags().StringVar(&downloadsClearOlderThan, "older-than", "", "Only remove partial downloads not touched for this long (e.g. 7d)")
+	downloadsCmd.AddCommand(downloadsShowCmd)
+	downloadsCmd.AddCommand(downloadsClearCmd)
+	rootCmd.AddCommand(downloadsCmd)
+}
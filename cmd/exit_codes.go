@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// exitCodesCmd is a help topic: it has no Run, so cobra lists it under
+// "Additional help topics" and shows it with fastbrew help exit-codes.
+var exitCodesCmd = &cobra.Command{
+	Use:   "exit-codes",
+	Short: "Exit statuses and what each means",
+	Long: `fastbrew exits with a status that tells scripts what kind of failure occurred:
+
+  0    success
+  1    other failure
+  2    usage error (unknown command or flag, wrong arguments)
+  3    not found: no such formula, cask, tap or installed package
+  4    network: a download or API request failed
+  5    checksum: a download did not match its SHA-256 or attestation
+  6    conflict: another fastbrew process holds the lock
+  7    permission: a file or directory could not be written
+  8    partial failure: some packages succeeded and others failed
+  130  interrupted by Ctrl-C
+
+When every package of an operation fails the same way the status is that
+failure's (e.g. 4 when offline); mixed failures exit with 1. With --json,
+errors carry the same category as "kind" (or "error_kind" for install,
+upgrade, uninstall and reinstall).
+
+  fastbrew install wget
+  case $? in
+    4) echo "offline, retry later" ;;
+    8) echo "some packages failed" ;;
+  esac`,
+}
+
+func init() {
+	rootCmd.AddCommand(exitCodesCmd)
+}
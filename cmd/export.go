@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportPackages []string
+	exportOut      string
+)
+
+// ExportView is the --json schema of `export`.
+type ExportView struct {
+	Path     string   `json:"path"`
+	Platform string   `json:"platform"`
+	Packages []string `json:"packages"`
+	Formulae []string `json:"formulae"`
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export [formula...]",
+	Short: "Bundle bottles for offline installation",
+	Long: `Download the bottles for the given formulae and all of their dependencies,
+for this platform, into a single tar archive together with their formula
+metadata. Install it on another machine without network access using
+fastbrew import.`,
+	Example: `  fastbrew export --packages go,node --out bundle.tar
+  fastbrew import bundle.tar`,
+	ValidArgsFunction: completeInstalledFormulae,
+	Run: func(cmd *cobra.Command, args []string) {
+		packages := append(append([]string{}, exportPackages...), args...)
+		if len(packages) == 0 {
+			exitWithError("Error", fmt.Errorf("no packages given; use --packages or pass formula names"))
+		}
+
+		client, err := newBrewClient()
+		if err != nil {
+			exitWithError("Error initializing brew client", err)
+		}
+		if jsonOutput {
+			quietForJSON(client)
+		}
+
+		manifest, err := client.ExportBundle(packages, exportOut)
+		if err != nil {
+			exitWithError("Error exporting", err)
+		}
+
+		if jsonOutput {
+			view := ExportView{Path: exportOut, Platform: manifest.Platform, Packages: manifest.Packages}
+			for _, entry := range manifest.Formulae {
+				view.Formulae = append(view.Formulae, entry.Formula.Name)
+			}
+			printJSON(view)
+			return
+		}
+		fmt.Fprintf(stdout, "✅ Exported %d bottle(s) for %s\n", len(manifest.Formulae), manifest.Platform)
+	},
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import <archive>",
+	Short: "Install formulae from an export archive without network access",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var rec *mutationRecorder
+		if jsonOutput {
+			rec = newMutationRecorder()
+		} else {
+			fmt.Fprintf(stdout, "🚀 FastBrew importing %s\n", args[0])
+		}
+
+		defer lockFastbrew()()
+
+		client, err := newBrewClient()
+		if err != nil {
+			exitWithError("Error initializing brew client", err)
+		}
+		if rec != nil {
+			quietForJSON(client)
+			client.SetMutationHook(rec.recordMutation)
+		}
+
+		var requested []string
+		manifest, err := client.ImportBundle(args[0])
+		if manifest != nil {
+			requested = manifest.Packages
+		}
+		finishMutation(rec, "install", requested, err, "Error importing", "✅ Done!")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(importCmd)
+
+	exportCmd.Flags().StringSliceVar(&exportPackages, "packages", nil, "Comma-separated formulae to export")
+	exportCmd.Flags().StringVarP(&exportOut, "out", "o", "fastbrew-export.tar", "Path of the archive to write")
+}
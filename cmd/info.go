@@ -27,29 +27,30 @@ type RemoteFormulaView struct {
 }
 
 var infoCmd = &cobra.Command{
-	Use:   "info [package...]",
-	Short: "Display information about packages",
-	Args:  cobra.MinimumNArgs(1),
+	Use:               "info [package...]",
+	Short:             "Display information about packages",
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeAvailablePackages,
 	Run: func(cmd *cobra.Command, args []string) {
 		if daemonClient, daemonErr := getDaemonClientForRead(); daemonClient != nil {
 			packages, err := daemonClient.Info(args)
 			if err == nil {
 				for i, pkg := range packages {
 					if i > 0 {
-						fmt.Println()
+						fmt.Fprintln(stdout)
 					}
-					fmt.Printf("🍺 %s: %s\n", pkg.Name, pkg.Version)
+					fmt.Fprintf(stdout, "🍺 %s: %s\n", pkg.Name, pkg.Version)
 					if pkg.Desc != "" {
-						fmt.Printf("%s\n", pkg.Desc)
+						fmt.Fprintf(stdout, "%s\n", pkg.Desc)
 					}
 					if pkg.Homepage != "" {
-						fmt.Printf("🌐 %s\n", pkg.Homepage)
+						fmt.Fprintf(stdout, "🌐 %s\n", pkg.Homepage)
 					}
 					if len(pkg.Dependencies) > 0 {
-						fmt.Printf("📦 Dependencies: %s\n", strings.Join(pkg.Dependencies, ", "))
+						fmt.Fprintf(stdout, "📦 Dependencies: %s\n", strings.Join(pkg.Dependencies, ", "))
 					}
 					if pkg.KegOnly {
-						fmt.Println("⚠️  Keg-only")
+						fmt.Fprintln(stdout, "⚠️  Keg-only")
 					}
 				}
 				return
@@ -61,8 +62,8 @@ var infoCmd = &cobra.Command{
 
 		client, err := newBrewClient()
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Error: %v\n", err)
+			os.Exit(exitCode(err))
 		}
 
 		results := make([]packageInfoResult, len(args))
@@ -76,7 +77,7 @@ var infoCmd = &cobra.Command{
 				sem <- struct{}{}
 				defer func() { <-sem }()
 
-				formula, fetchErr := client.FetchFormula(name)
+				formula, fetchErr := client.FetchFormula(cmd.Context(), name)
 				if fetchErr != nil {
 					results[idx] = packageInfoResult{pkg: name, err: fetchErr}
 					return
@@ -99,27 +100,27 @@ var infoCmd = &cobra.Command{
 
 		for i, res := range results {
 			if i > 0 {
-				fmt.Println()
+				fmt.Fprintln(stdout)
 			}
 
 			if res.err != nil {
-				fmt.Printf("Error fetching %s: %v\n", res.pkg, res.err)
+				fmt.Fprintf(stdout, "Error fetching %s: %v\n", res.pkg, res.err)
 				continue
 			}
 
 			formula := res.formula
-			fmt.Printf("🍺 %s: %s\n", formula.Name, formula.Stable)
+			fmt.Fprintf(stdout, "🍺 %s: %s\n", formula.Name, formula.Stable)
 			if formula.Desc != "" {
-				fmt.Printf("%s\n", formula.Desc)
+				fmt.Fprintf(stdout, "%s\n", formula.Desc)
 			}
 			if formula.Homepage != "" {
-				fmt.Printf("🌐 %s\n", formula.Homepage)
+				fmt.Fprintf(stdout, "🌐 %s\n", formula.Homepage)
 			}
 			if len(formula.Dependencies) > 0 {
-				fmt.Printf("📦 Dependencies: %s\n", strings.Join(formula.Dependencies, ", "))
+				fmt.Fprintf(stdout, "📦 Dependencies: %s\n", strings.Join(formula.Dependencies, ", "))
 			}
 			if formula.KegOnly {
-				fmt.Println("⚠️  Keg-only")
+				fmt.Fprintln(stdout, "⚠️  Keg-only")
 			}
 		}
 	},
@@ -1,10 +1,12 @@
 package cmd
 
 import (
+	"context"
 	"fastbrew/internal/brew"
 	"fastbrew/internal/config"
 	"fastbrew/internal/daemon"
 	"fastbrew/internal/progress"
+	"fastbrew/internal/tui"
 	"fmt"
 	"os"
 	"time"
@@ -13,77 +15,187 @@ import (
 )
 
 var showProgress bool
+var installQuiet bool
 var installVerbose bool
 var strictNative bool
+var installBottles []string
+var installSHA256 string
+var installDeps brew.DepsOptions
+var installDenyLicenses []string
 
 var installCmd = &cobra.Command{
 	Use:   "install [package...]",
 	Short: "Install packages with parallel downloading",
-	Args:  cobra.MinimumNArgs(1),
+	Long: `Install packages with parallel downloading.
+
+With --bottle, installs bottle tarballs from disk instead (a file, or a
+directory of them) without downloading anything. Each bottle is verified
+against --sha256, the JSON file 'brew bottle --json' writes next to it, or
+the formula's checksum from the API.
+
+With --deny-license, nothing is installed when a requested formula or one
+of the dependencies it would add is under a denied license; see
+'fastbrew licenses --help' for how licenses are matched.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(installBottles) > 0 {
+			if len(args) > 0 {
+				return fmt.Errorf("package names cannot be combined with --bottle")
+			}
+			if len(installDenyLicenses) > 0 {
+				return fmt.Errorf("--deny-license cannot be combined with --bottle")
+			}
+			return nil
+		}
+		if installSHA256 != "" {
+			return fmt.Errorf("--sha256 requires --bottle")
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
+	ValidArgsFunction: completeAvailablePackages,
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Printf("🚀 FastBrew installing: %v\n", args)
+		if len(installBottles) > 0 {
+			installLocalBottles(installBottles)
+			return
+		}
+		checkLicensePolicy(args, installDeps, installDenyLicenses)
+
+		started := time.Now()
+		var rec *mutationRecorder
+		if jsonOutput {
+			rec = newMutationRecorder()
+		} else {
+			fmt.Fprintf(stdout, "🚀 FastBrew installing: %v\n", args)
+		}
 		jobOpts := daemon.JobSubmitOptions{
-			StrictNative: strictNative,
+			StrictNative:    strictNative,
+			IncludeBuild:    installDeps.IncludeBuild,
+			IncludeTest:     installDeps.IncludeTest,
+			IncludeOptional: installDeps.IncludeOptional,
+			SkipRecommended: installDeps.SkipRecommended,
 		}
-		if ran, err := tryRunMutationJob("install", daemon.JobOperationInstall, args, jobOpts); ran {
-			if err != nil {
-				fmt.Printf("Error installing packages: %v\n", err)
-				os.Exit(1)
-			}
-			fmt.Println("✅ Done!")
+		if ran, err := tryRunMutationJob("install", daemon.JobOperationInstall, args, jobOpts, rec); ran {
+			printRunSummary(started)
+			finishMutation(rec, "install", args, err, "Error installing packages", "✅ Done!")
 			return
 		}
 
+		defer lockFastbrew()()
+
 		client, err := newBrewClient()
 		if err != nil {
-			fmt.Printf("Error initializing brew client: %v\n", err)
-			os.Exit(1)
+			exitWithError("Error initializing brew client", err)
 		}
 
 		cfg := config.Get()
 		client.Verbose = installVerbose || cfg.Verbose
 		client.MaxParallel = cfg.GetParallelDownloads()
-
-		if showProgress {
-			client.EnableProgress()
-			defer client.DisableProgress()
-			go displayProgress(client.ProgressManager)
+		if rec != nil {
+			quietForJSON(client)
+			client.SetMutationHook(rec.recordMutation)
 		}
 
-		if err := client.InstallNativeWithOptions(args, brew.InstallOptions{StrictNative: strictNative}); err != nil {
-			fmt.Printf("Error installing packages: %v\n", err)
-			os.Exit(1)
-		}
-		fmt.Println("✅ Done!")
+		ctx, stopInterrupt := interruptContext(cmd.Context())
+		defer stopInterrupt()
+
+		stopProgress := startProgressDisplay(client, showProgress || cfg.ShowProgress, installQuiet)
+		err = client.InstallNativeWithOptions(ctx, args, brew.InstallOptions{StrictNative: strictNative, Deps: installDeps})
+		stopProgress()
+		cleanupAfterInterrupt(ctx, client)
+		printRunSummary(started)
+		finishMutation(rec, "install", args, err, "Error installing packages", "✅ Done!")
 	},
 }
 
-func displayProgress(pm *progress.Manager) {
-	ticker := time.NewTicker(500 * time.Millisecond)
-	defer ticker.Stop()
+func installLocalBottles(paths []string) {
+	var rec *mutationRecorder
+	if jsonOutput {
+		rec = newMutationRecorder()
+	} else {
+		fmt.Fprintf(stdout, "🚀 FastBrew installing local bottles: %v\n", paths)
+	}
 
-	for range ticker.C {
-		agg := pm.GetAggregateProgress()
-		if agg.TotalDownloads == 0 {
-			continue
-		}
+	defer lockFastbrew()()
+
+	client, err := newBrewClient()
+	if err != nil {
+		exitWithError("Error initializing brew client", err)
+	}
+	client.Verbose = installVerbose || config.Get().Verbose
+	if rec != nil {
+		quietForJSON(client)
+		client.SetMutationHook(rec.recordMutation)
+	}
+
+	err = client.InstallLocalBottles(paths, brew.LocalBottleOptions{SHA256: installSHA256})
+	finishMutation(rec, "install", paths, err, "Error installing bottles", "✅ Done!")
+}
+
+// cleanupAfterInterrupt removes extraction directories an interrupted
+// install or upgrade may have left in the Cellar. It runs while the
+// fastbrew lock is still held, so no other install is using them.
+func cleanupAfterInterrupt(ctx context.Context, client *brew.Client) {
+	if ctx.Err() == nil {
+		return
+	}
+	if err := client.RemoveExtractionTempDirs(); err != nil {
+		fmt.Fprintf(stderr, "Warning: %v\n", err)
+	}
+}
 
-		if agg.OverallPercentage > 0 && agg.OverallPercentage < 100 {
-			speedMB := agg.AverageSpeed / (1024 * 1024)
-			fmt.Printf("\r  📊 Progress: %.1f%% | Active: %d | Speed: %.2f MB/s    ",
-				agg.OverallPercentage, agg.ActiveDownloads, speedMB)
+// startProgressDisplay enables download progress reporting on client when
+// enabled and output is human-readable. On a terminal a live dashboard is
+// drawn below the command output; otherwise plain progress lines are
+// printed. With quiet, only download start and finish lines are printed.
+// --progress-json replaces all of these with JSON events on stderr. The
+// returned function stops the display and must be called before printing
+// the final result.
+func startProgressDisplay(client *brew.Client, enabled, quiet bool) func() {
+	if progressJSON {
+		client.EnableProgress()
+		detach := progress.NewJSONRenderer(progressOutput).Attach(client.ProgressManager)
+		return func() {
+			detach()
+			client.DisableProgress()
 		}
+	}
+	if jsonOutput || (!enabled && !quiet) {
+		return func() {}
+	}
 
-		if pm.IsComplete() || agg.TotalDownloads == agg.CompletedDownloads+agg.FailedDownloads {
-			fmt.Println()
-			return
+	client.EnableProgress()
+	if quiet || !isTerminal(os.Stdout) {
+		out := client.Out
+		if out == nil {
+			out = stdout
+		}
+		detach := progress.NewTextRenderer(out, quiet).Attach(client.ProgressManager)
+		return func() {
+			detach()
+			client.DisableProgress()
 		}
 	}
+
+	dashboard := tui.StartDownloadDashboard(client.ProgressManager)
+	prevOut := client.Out
+	client.Out = plainOutput(dashboard.Writer())
+	return func() {
+		dashboard.Stop()
+		client.Out = prevOut
+		client.DisableProgress()
+	}
 }
 
 func init() {
-	installCmd.Flags().BoolVarP(&showProgress, "progress", "p", false, "Show download progress")
+	installCmd.Flags().BoolVarP(&showProgress, "progress", "p", false, "Show download progress (always on when show_progress is set)")
+	installCmd.Flags().BoolVarP(&installQuiet, "quiet", "q", false, "Suppress progress bars; only print when each download starts and finishes")
 	installCmd.Flags().BoolVar(&installVerbose, "verbose", false, "Show detailed output (extraction timing, etc.)")
 	installCmd.Flags().BoolVar(&strictNative, "strict-native", false, "Disable brew fallback for unsupported tap formulas")
+	installCmd.Flags().BoolVar(&installDeps.IncludeBuild, "include-build", false, "Also install build dependencies")
+	installCmd.Flags().BoolVar(&installDeps.IncludeTest, "include-test", false, "Also install test dependencies")
+	installCmd.Flags().BoolVar(&installDeps.IncludeOptional, "include-optional", false, "Also install optional dependencies")
+	installCmd.Flags().BoolVar(&installDeps.SkipRecommended, "skip-recommended", false, "Do not install recommended dependencies")
+	installCmd.Flags().StringSliceVar(&installBottles, "bottle", nil, "Install a local bottle file or directory of bottles (repeatable)")
+	installCmd.Flags().StringVar(&installSHA256, "sha256", "", "Expected SHA-256 of the --bottle file")
+	installCmd.Flags().StringSliceVar(&installDenyLicenses, "deny-license", nil, "Refuse to install formulae under this license, including dependencies (repeatable)")
 	rootCmd.AddCommand(installCmd)
 }
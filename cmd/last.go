@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fastbrew/internal/brew"
+	"fastbrew/internal/progress"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var lastCmd = &cobra.Command{
+	Use:   "last",
+	Short: "Show the timing report of the last install or upgrade",
+	Long: `Show the summary printed at the end of the last install or upgrade: for
+each formula the bottle size, download time, extract time and number of
+linked files, then the total wall time and bytes transferred. Bottles
+reused from the download cache show their size but add nothing to the bytes
+transferred. With --json, durations are nanoseconds.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		report, err := brew.LoadRunReport()
+		if err != nil {
+			exitWithError("Error reading the last report", err)
+		}
+		if jsonOutput {
+			printJSON(report)
+			return
+		}
+		if report == nil {
+			fmt.Fprintln(stdout, "No install or upgrade recorded yet.")
+			return
+		}
+		fmt.Fprintf(stdout, "Last %s, %s:\n", report.Operation, report.StartedAt.Local().Format("2006-01-02 15:04:05"))
+		writeRunReport(stdout, report)
+	},
+}
+
+// printRunSummary prints the report of the install or upgrade that began
+// at or after since, whether it ran here or in the daemon. Nothing is
+// printed for JSON output or when nothing was installed.
+func printRunSummary(since time.Time) {
+	if jsonOutput {
+		return
+	}
+	report, err := brew.LoadRunReport()
+	if err != nil || report == nil || report.StartedAt.Before(since) {
+		return
+	}
+	fmt.Fprintln(stdout)
+	writeRunReport(stdout, report)
+}
+
+// writeRunReport prints one row per formula followed by the totals.
+func writeRunReport(out io.Writer, report *brew.RunReport) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVERSION\tSIZE\tDOWNLOAD\tEXTRACT\tLINKS")
+	for _, pkg := range report.Packages {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\n", pkg.Name, pkg.Version, progress.FormatBytes(pkg.DownloadSize),
+			formatStepTime(pkg.DownloadTime), formatStepTime(pkg.ExtractTime), pkg.Links)
+	}
+	w.Flush()
+	fmt.Fprintf(out, "⏱️  %d package(s) in %s; %s transferred (%s of bottles)\n", len(report.Packages),
+		formatStepTime(report.WallTime), progress.FormatBytes(report.BytesTransferred), progress.FormatBytes(report.DownloadTotal()))
+	if report.Error != "" {
+		fmt.Fprintf(out, "⚠️  Finished with an error: %s\n", report.Error)
+	}
+}
+
+// formatStepTime rounds d for display; "-" marks a step that did not run.
+func formatStepTime(d time.Duration) string {
+	if d <= 0 {
+		return "-"
+	}
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(10 * time.Millisecond).String()
+}
+
+func init() {
+	rootCmd.AddCommand(lastCmd)
+}
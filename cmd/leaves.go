@@ -1,9 +1,7 @@
 package cmd
 
 import (
-	"fastbrew/internal/brew"
 	"fmt"
-	"os"
 
 	"github.com/spf13/cobra"
 )
@@ -11,68 +9,55 @@ import (
 var leavesCmd = &cobra.Command{
 	Use:   "leaves",
 	Short: "List installed formulae that are not dependencies of another installed formula",
+	Long: `List the top-level installed formulae: those no other installed formula
+depends on. These are usually the packages that were installed on purpose;
+everything else was pulled in as a dependency.`,
+	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		if daemonClient, daemonErr := getDaemonClientForRead(); daemonClient != nil {
-			leaves, err := daemonClient.Leaves()
-			if err == nil {
-				for _, name := range leaves {
-					fmt.Println(name)
-				}
-				return
+		leaves := leavesFromDaemon()
+		if leaves == nil {
+			client, err := newBrewClient()
+			if err != nil {
+				exitWithError("Error", err)
+			}
+			leaves, err = client.Leaves()
+			if err != nil {
+				exitWithError("Error computing leaves", err)
 			}
-			warnDaemonFallback("leaves", err)
-		} else if daemonErr != nil {
-			warnDaemonFallback("leaves", daemonErr)
-		}
-
-		client, err := newBrewClient()
-		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
-		}
-
-		installed, err := client.ListInstalledNative()
-		if err != nil {
-			fmt.Printf("Error listing installed: %v\n", err)
-			os.Exit(1)
 		}
 
-		if len(installed) == 0 {
+		if jsonOutput {
+			if leaves == nil {
+				leaves = []string{}
+			}
+			printJSON(leaves)
 			return
 		}
-
-		// Map to store if a package is a dependency
-		isDep := make(map[string]bool)
-
-		// Load index to check dependencies
-		// Note: This might be slow if index is missing, but usually it's cached.
-		idx, err := client.LoadIndex()
-		if err != nil {
-			// Fallback: If we can't load index, we can't determine leaves accurately.
-			// But we can try to continue with what we have if some info is available.
-			fmt.Printf("Warning: Could not load index for accurate leaves: %v\n", err)
-		} else {
-			formulaMap := make(map[string]brew.Formula)
-			for _, f := range idx.Formulae {
-				formulaMap[f.Name] = f
-			}
-
-			// Check dependencies of each installed package
-			for _, pkg := range installed {
-				if f, ok := formulaMap[pkg.Name]; ok {
-					for _, dep := range f.Dependencies {
-						isDep[dep] = true
-					}
-				}
-			}
+		for _, name := range leaves {
+			fmt.Fprintln(stdout, name)
 		}
+	},
+}
 
-		for _, pkg := range installed {
-			if !isDep[pkg.Name] {
-				fmt.Println(pkg.Name)
-			}
+// leavesFromDaemon returns the daemon's cached leaves, or nil when the
+// daemon is unavailable and they must be computed locally.
+func leavesFromDaemon() []string {
+	daemonClient, daemonErr := getDaemonClientForRead()
+	if daemonClient == nil {
+		if daemonErr != nil {
+			warnDaemonFallback("leaves", daemonErr)
 		}
-	},
+		return nil
+	}
+	leaves, err := daemonClient.Leaves()
+	if err != nil {
+		warnDaemonFallback("leaves", err)
+		return nil
+	}
+	if leaves == nil {
+		leaves = []string{}
+	}
+	return leaves
 }
 
 func init() {
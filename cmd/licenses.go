@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"fastbrew/internal/brew"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	licensesDeny      []string
+	licensesByPackage bool
+	licensesDeps      brew.DepsOptions
+)
+
+// LicenseSummary is one row of the licenses summary: a license expression
+// and the packages under it.
+type LicenseSummary struct {
+	License  string   `json:"license"`
+	Count    int      `json:"count"`
+	Packages []string `json:"packages"`
+}
+
+// LicensesView is the --json schema for licenses.
+type LicensesView struct {
+	Licenses []LicenseSummary      `json:"licenses"`
+	Packages []brew.PackageLicense `json:"packages"`
+	// Denied lists the packages under a --deny license.
+	Denied []string `json:"denied,omitempty"`
+}
+
+var licensesCmd = &cobra.Command{
+	Use:   "licenses [formula...]",
+	Short: "Summarize the licenses of installed packages",
+	Long: `Summarize the licenses of installed formulae, as recorded in the index.
+
+With formula arguments, report instead the formulae installing them would
+add: the formulae and their dependencies that are not installed yet.
+
+--deny checks the packages against a license policy and exits with status 1
+when any of them is under a denied license. A formula offering a choice
+("Apache-2.0 or GPL-3.0") is only denied when every choice is. A bare
+license such as GPL-3.0 also denies GPL-3.0-only and GPL-3.0-or-later.`,
+	ValidArgsFunction: completeAvailablePackages,
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newBrewClient()
+		if err != nil {
+			exitWithError("Error initializing brew client", err)
+		}
+		var licenses []brew.PackageLicense
+		if len(args) > 0 {
+			licenses, err = client.InstallLicenses(args, licensesDeps, licensesDeny)
+		} else {
+			licenses, err = client.InstalledLicenses(licensesDeny)
+		}
+		if err != nil {
+			exitWithError("Error reading licenses", err)
+		}
+
+		view := LicensesView{Licenses: summarizeLicenses(licenses), Packages: licenses}
+		if view.Packages == nil {
+			view.Packages = []brew.PackageLicense{}
+		}
+		for _, pkg := range licenses {
+			if pkg.Denied {
+				view.Denied = append(view.Denied, pkg.Name)
+			}
+		}
+
+		if jsonOutput {
+			printJSON(view)
+		} else {
+			printLicenses(view)
+		}
+		if len(view.Denied) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+// summarizeLicenses groups packages by license, most used first.
+func summarizeLicenses(licenses []brew.PackageLicense) []LicenseSummary {
+	byLicense := make(map[string]*LicenseSummary)
+	var out []LicenseSummary
+	for _, pkg := range licenses {
+		s, ok := byLicense[pkg.License]
+		if !ok {
+			s = &LicenseSummary{License: pkg.License}
+			byLicense[pkg.License] = s
+		}
+		s.Count++
+		s.Packages = append(s.Packages, pkg.Name)
+	}
+	for _, s := range byLicense {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].License < out[j].License
+	})
+	if out == nil {
+		out = []LicenseSummary{}
+	}
+	return out
+}
+
+func printLicenses(view LicensesView) {
+	if len(view.Packages) == 0 {
+		fmt.Fprintln(stdout, "No packages to report")
+		return
+	}
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	if licensesByPackage {
+		fmt.Fprintln(w, "PACKAGE\tLICENSE")
+		for _, pkg := range view.Packages {
+			fmt.Fprintf(w, "%s\t%s\n", pkg.Name, pkg.License)
+		}
+	} else {
+		fmt.Fprintln(w, "LICENSE\tCOUNT\tPACKAGES")
+		for _, s := range view.Licenses {
+			fmt.Fprintf(w, "%s\t%d\t%s\n", s.License, s.Count, strings.Join(s.Packages, ", "))
+		}
+	}
+	w.Flush()
+	if len(view.Denied) > 0 {
+		fmt.Fprintf(stderr, "❌ %d package(s) under a denied license: %s\n", len(view.Denied), strings.Join(view.Denied, ", "))
+	}
+}
+
+// checkLicensePolicy stops an install of names when it would add a formula
+// under one of the denied licenses, directly or as a dependency.
+func checkLicensePolicy(names []string, deps brew.DepsOptions, denied []string) {
+	if len(denied) == 0 {
+		return
+	}
+	client, err := newBrewClient()
+	if err != nil {
+		exitWithError("Error initializing brew client", err)
+	}
+	licenses, err := client.InstallLicenses(names, deps, denied)
+	if err != nil {
+		exitWithError("Error checking licenses", err)
+	}
+	var violations []string
+	for _, pkg := range licenses {
+		if pkg.Denied {
+			violations = append(violations, fmt.Sprintf("%s (%s)", pkg.Name, pkg.License))
+		}
+	}
+	if len(violations) > 0 {
+		exitWithError("Error", fmt.Errorf("install would add formulae under a denied license: %s", strings.Join(violations, ", ")))
+	}
+}
+
+func init() {
+	licensesCmd.Flags().StringSliceVar(&licensesDeny, "deny", nil, "Fail when a package is under this license (repeatable)")
+	licensesCmd.Flags().BoolVar(&licensesByPackage, "by-package", false, "List each package's license instead of the summary")
+	licensesCmd.Flags().BoolVar(&licensesDeps.IncludeBuild, "include-build", false, "Include build dependencies of formula arguments")
+	licensesCmd.Flags().BoolVar(&licensesDeps.IncludeOptional, "include-optional", false, "Include optional dependencies of formula arguments")
+	licensesCmd.Flags().BoolVar(&licensesDeps.SkipRecommended, "skip-recommended", false, "Skip recommended dependencies of formula arguments")
+	rootCmd.AddCommand(licensesCmd)
+}
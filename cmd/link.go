@@ -13,60 +13,64 @@ import (
 var (
 	linkOverwrite bool
 	linkForce     bool
-	linkDryRun    bool
 )
 
 var linkCmd = &cobra.Command{
-	Use:   "link [formula...]",
-	Short: "Symlink a formula's installed files into the prefix",
-	Long:  `Link a formula's installed files into the Homebrew prefix, making them available in PATH.`,
-	Args:  cobra.MinimumNArgs(1),
+	Use:               "link [formula...]",
+	Short:             "Symlink a formula's installed files into the prefix",
+	Long:              `Link a formula's installed files into the Homebrew prefix, making them available in PATH.`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeInstalledFormulae,
 	Run: func(cmd *cobra.Command, args []string) {
+		if !dryRun {
+			defer lockFastbrew()()
+		}
+
 		client, err := newBrewClient()
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Error: %v\n", err)
+			os.Exit(exitCode(err))
 		}
 
 		for _, pkg := range args {
-			if linkDryRun {
-				fmt.Printf("Would link %s...\n", pkg)
+			if dryRun {
+				fmt.Fprintf(stdout, "Would link %s...\n", pkg)
 				version, verErr := findInstalledVersion(client, pkg)
 				if verErr != nil {
-					fmt.Printf("  Error: %v\n", verErr)
+					fmt.Fprintf(stdout, "  Error: %v\n", verErr)
 					continue
 				}
 				result, err := client.LinkDryRun(pkg, version)
 				if err != nil {
-					fmt.Printf("  Error: %v\n", err)
+					fmt.Fprintf(stdout, "  Error: %v\n", err)
 					continue
 				}
 				for _, binary := range result.Binaries {
-					fmt.Printf("  -> %s\n", binary)
+					fmt.Fprintf(stdout, "  -> %s\n", binary)
 				}
 				continue
 			}
 
-			fmt.Printf("🔗 Linking %s...\n", pkg)
+			fmt.Fprintf(stdout, "🔗 Linking %s...\n", pkg)
 
 			version, verErr := findInstalledVersion(client, pkg)
 			if verErr != nil {
-				fmt.Printf("  ❌ Error: %v\n", verErr)
+				fmt.Fprintf(stdout, "  ❌ Error: %v\n", verErr)
 				continue
 			}
 
 			result, err := client.Link(pkg, version)
 			if err != nil {
-				fmt.Printf("  ❌ Error: %v\n", err)
+				fmt.Fprintf(stdout, "  ❌ Error: %v\n", err)
 				continue
 			}
 
 			if len(result.Binaries) == 0 {
-				fmt.Printf("  ℹ️  No binaries to link\n")
+				fmt.Fprintf(stdout, "  ℹ️  No binaries to link\n")
 			} else {
-				fmt.Printf("  ✅ Linked %d binary(ies)\n", len(result.Binaries))
+				fmt.Fprintf(stdout, "  ✅ Linked %d binary(ies)\n", len(result.Binaries))
 				for _, binary := range result.Binaries {
-					fmt.Printf("     • %s\n", binary)
+					fmt.Fprintf(stdout, "     • %s\n", binary)
 				}
 			}
 		}
@@ -74,24 +78,27 @@ var linkCmd = &cobra.Command{
 }
 
 var unlinkCmd = &cobra.Command{
-	Use:   "unlink [formula...]",
-	Short: "Remove symlinks for a formula from the prefix",
-	Long:  `Unlink a formula's symlinks from the Homebrew prefix, removing them from PATH.`,
-	Args:  cobra.MinimumNArgs(1),
+	Use:               "unlink [formula...]",
+	Short:             "Remove symlinks for a formula from the prefix",
+	Long:              `Unlink a formula's symlinks from the Homebrew prefix, removing them from PATH.`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeInstalledFormulae,
 	Run: func(cmd *cobra.Command, args []string) {
+		defer lockFastbrew()()
+
 		client, err := newBrewClient()
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Error: %v\n", err)
+			os.Exit(exitCode(err))
 		}
 
 		for _, pkg := range args {
-			fmt.Printf("🔗 Unlinking %s...\n", pkg)
+			fmt.Fprintf(stdout, "🔗 Unlinking %s...\n", pkg)
 			if err := client.Unlink(pkg); err != nil {
-				fmt.Printf("  ❌ Error: %v\n", err)
+				fmt.Fprintf(stdout, "  ❌ Error: %v\n", err)
 				continue
 			}
-			fmt.Printf("  ✅ Unlinked\n")
+			fmt.Fprintf(stdout, "  ✅ Unlinked\n")
 		}
 	},
 }
@@ -119,5 +126,6 @@ func init() {
 
 	linkCmd.Flags().BoolVar(&linkOverwrite, "overwrite", false, "Overwrite existing symlinks")
 	linkCmd.Flags().BoolVar(&linkForce, "force", false, "Force link even if formula is keg-only")
-	linkCmd.Flags().BoolVarP(&linkDryRun, "dry-run", "n", false, "Show what would be linked without making changes")
+	// Redeclared locally for brew's -n shorthand; it sets the global --dry-run.
+	linkCmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Show what would be linked without making changes")
 }
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"errors"
+	"fastbrew/internal/brew"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var linksCmd = &cobra.Command{
+	Use:   "links <formula>",
+	Short: "List the symlinks a formula has in the prefix",
+	Long: `List the symlinks fastbrew created in the prefix for a formula, with where
+each one points and whether it is still in place. Unlink and uninstall
+remove exactly these links.
+
+Formulae linked before links were recorded have none listed; run
+'fastbrew link <formula>' once to record them.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeInstalledFormulae,
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newBrewClient()
+		if err != nil {
+			exitWithError("Error initializing brew client", err)
+		}
+		links, err := client.Links(args[0])
+		if errors.Is(err, os.ErrNotExist) {
+			if jsonOutput {
+				printJSON([]brew.LinkedFile{})
+				return
+			}
+			fmt.Fprintf(stdout, "No recorded links for %s. Run 'fastbrew link %s' to record them.\n", args[0], args[0])
+			return
+		}
+		if err != nil {
+			exitWithError("Error reading links", err)
+		}
+
+		if jsonOutput {
+			printJSON(links)
+			return
+		}
+		if len(links) == 0 {
+			fmt.Fprintf(stdout, "%s has no links in the prefix.\n", args[0])
+			return
+		}
+		writeLinksTable(stdout, links)
+	},
+}
+
+// writeLinksTable prints links as a table of link, target and status.
+func writeLinksTable(out io.Writer, links []brew.LinkedFile) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "LINK\tTARGET\tSTATUS")
+	for _, link := range links {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", link.Path, link.Target, link.Status)
+	}
+	w.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(linksCmd)
+}
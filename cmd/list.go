@@ -1,24 +1,53 @@
 package cmd
 
 import (
+	"fastbrew/internal/brew"
+	"fastbrew/internal/progress"
 	"fmt"
-	"os"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 )
 
+var (
+	listOnRequest    bool
+	listAsDependency bool
+	listCask         bool
+	listFormula      bool
+	listVersions     bool
+	listSort         string
+)
+
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List installed packages (native fast scan)",
+	Long: `List installed formulae and casks with their version, size on disk and
+type. Packages with more than one version installed are marked; --versions
+shows all of them with the linked one starred, and the size counts every
+version. A formula switched to an older version with 'fastbrew switch' shows
+that version. Sizes are cached and only measured again for kegs that
+changed.
+
+--installed-on-request and --installed-as-dependency filter formulae by the
+install reason in their INSTALL_RECEIPT.json; formulae without a receipt
+count as installed on request.`,
+	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		var packages []PackageListView
+		if listSort != "name" && listSort != "size" {
+			exitWithError("Error", fmt.Errorf("invalid --sort %q: use name or size", listSort))
+		}
+
+		var installed []brew.PackageInfo
 
 		if daemonClient, daemonErr := getDaemonClientForRead(); daemonClient != nil {
 			daemonPackages, err := daemonClient.ListInstalled()
 			if err == nil {
-				packages = make([]PackageListView, len(daemonPackages))
+				installed = make([]brew.PackageInfo, len(daemonPackages))
 				for i, pkg := range daemonPackages {
-					packages[i] = PackageListView{Name: pkg.Name, Version: pkg.Version}
+					installed[i] = brew.PackageInfo{Name: pkg.Name, Version: pkg.Version, IsCask: pkg.IsCask, InstalledAsDependency: pkg.InstalledAsDependency}
 				}
 			} else {
 				warnDaemonFallback("list", err)
@@ -27,40 +56,141 @@ var listCmd = &cobra.Command{
 			warnDaemonFallback("list", daemonErr)
 		}
 
-		if packages == nil {
-			client, err := newBrewClient()
+		client, err := newBrewClient()
+		if err != nil {
+			exitWithError("Error", err)
+		}
+		if installed == nil {
+			installed, err = client.ListInstalledNative()
 			if err != nil {
-				fmt.Printf("Error: %v\n", err)
-				os.Exit(1)
+				exitWithError("Error listing packages", err)
 			}
+		}
 
-			localPackages, listErr := client.ListInstalledNative()
-			if listErr != nil {
-				fmt.Printf("Error listing packages: %v\n", listErr)
-				os.Exit(1)
-			}
-			packages = make([]PackageListView, len(localPackages))
-			for i, pkg := range localPackages {
-				packages[i] = PackageListView{Name: pkg.Name, Version: pkg.Version}
-			}
+		packages := make([]PackageListView, 0, len(installed))
+		for _, pkg := range client.DescribeInstalled(installed) {
+			packages = append(packages, PackageListView{
+				Name:                  pkg.Name,
+				Version:               pkg.Version(),
+				Versions:              pkg.Versions,
+				LinkedVersion:         pkg.LinkedVersion,
+				MultipleVersions:      len(pkg.Versions) > 1,
+				IsCask:                pkg.IsCask,
+				InstalledAsDependency: pkg.InstalledAsDependency,
+				Size:                  pkg.Size,
+			})
 		}
+		packages = filterInstallReason(packages, listOnRequest, listAsDependency)
+		packages = filterPackageKind(packages, listCask, listFormula)
+		sortPackageList(packages, listSort)
 
-		if len(packages) == 0 {
-			fmt.Println("No packages installed.")
+		if jsonOutput {
+			if packages == nil {
+				packages = []PackageListView{}
+			}
+			printJSON(packages)
 			return
 		}
 
-		for _, pkg := range packages {
-			fmt.Printf("%s %s\n", pkg.Name, pkg.Version)
+		if len(packages) == 0 {
+			fmt.Fprintln(stdout, "No packages installed.")
+			return
 		}
+		writePackageTable(stdout, packages, listVersions)
 	},
 }
 
 type PackageListView struct {
-	Name    string
-	Version string
+	Name                  string   `json:"name"`
+	Version               string   `json:"version"`
+	Versions              []string `json:"versions"`
+	LinkedVersion         string   `json:"linked_version,omitempty"`
+	MultipleVersions      bool     `json:"multiple_versions"`
+	IsCask                bool     `json:"is_cask"`
+	InstalledAsDependency bool     `json:"installed_as_dependency"`
+	Size                  int64    `json:"size"`
+}
+
+// filterInstallReason keeps the formulae installed on request, as a
+// dependency, or both when neither or both are asked for. Casks have no
+// install reason and are dropped by either filter.
+func filterInstallReason(packages []PackageListView, onRequest, asDependency bool) []PackageListView {
+	if onRequest == asDependency {
+		return packages
+	}
+	var filtered []PackageListView
+	for _, pkg := range packages {
+		if !pkg.IsCask && pkg.InstalledAsDependency == asDependency {
+			filtered = append(filtered, pkg)
+		}
+	}
+	return filtered
+}
+
+// filterPackageKind keeps only casks or only formulae, or both when
+// neither or both are asked for.
+func filterPackageKind(packages []PackageListView, casks, formulae bool) []PackageListView {
+	if casks == formulae {
+		return packages
+	}
+	var filtered []PackageListView
+	for _, pkg := range packages {
+		if pkg.IsCask == casks {
+			filtered = append(filtered, pkg)
+		}
+	}
+	return filtered
+}
+
+// sortPackageList orders packages by name, or largest first for "size".
+func sortPackageList(packages []PackageListView, by string) {
+	sort.SliceStable(packages, func(i, j int) bool {
+		if by == "size" && packages[i].Size != packages[j].Size {
+			return packages[i].Size > packages[j].Size
+		}
+		return packages[i].Name < packages[j].Name
+	})
+}
+
+// writePackageTable prints one row per package. With allVersions every
+// installed version is listed and the linked one starred; otherwise a
+// package with other versions still installed shows how many there are,
+// next to the linked version when it is not the newest.
+func writePackageTable(out io.Writer, packages []PackageListView, allVersions bool) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVERSION\tSIZE\tTYPE")
+	for _, pkg := range packages {
+		version := pkg.Version
+		switch {
+		case allVersions:
+			versions := make([]string, len(pkg.Versions))
+			for i, v := range pkg.Versions {
+				versions[i] = v
+				if pkg.MultipleVersions && v == pkg.LinkedVersion {
+					versions[i] += "*"
+				}
+			}
+			version = strings.Join(versions, ", ")
+		case pkg.LinkedVersion != "" && pkg.LinkedVersion != pkg.Version:
+			version = fmt.Sprintf("%s (switched; newest %s)", pkg.LinkedVersion, pkg.Version)
+		case pkg.MultipleVersions:
+			version += fmt.Sprintf(" (+%d older)", len(pkg.Versions)-1)
+		}
+		kind := "formula"
+		if pkg.IsCask {
+			kind = "cask"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", pkg.Name, version, progress.FormatBytes(pkg.Size), kind)
+	}
+	w.Flush()
 }
 
 func init() {
+	listCmd.Flags().BoolVar(&listOnRequest, "installed-on-request", false, "Only list formulae that were explicitly installed")
+	listCmd.Flags().BoolVar(&listAsDependency, "installed-as-dependency", false, "Only list formulae installed as dependencies")
+	listCmd.Flags().BoolVar(&listCask, "cask", false, "Only list casks")
+	listCmd.Flags().BoolVar(&listFormula, "formula", false, "Only list formulae")
+	listCmd.Flags().BoolVar(&listVersions, "versions", false, "Show every installed version")
+	listCmd.Flags().StringVar(&listSort, "sort", "name", "Sort by name or size (largest first)")
 	rootCmd.AddCommand(listCmd)
 }
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fastbrew/internal/lockfile"
+	"fmt"
+)
+
+var waitForLock bool
+
+// lockFastbrew takes the lock serialising commands that change the Cellar,
+// download cache or index, and returns the function that releases it. When
+// another fastbrew process holds the lock the command exits, or with --wait
+// blocks until the lock is free or Ctrl-C is pressed. Commands that hand
+// their work to the daemon must not call it: the daemon takes the lock for
+// each job.
+func lockFastbrew() func() {
+	path := lockfile.DefaultPath()
+	lock, err := lockfile.TryAcquire(path)
+	var held *lockfile.HeldError
+	if errors.As(err, &held) {
+		if !waitForLock {
+			exitWithError("Error", fmt.Errorf("%w; rerun with --wait to wait for it", err))
+		}
+		fmt.Fprintf(stderr, "⏳ %v, waiting for it to finish...\n", held)
+		ctx, stopInterrupt := interruptContext(context.Background())
+		lock, err = lockfile.Acquire(ctx, path)
+		if ctx.Err() != nil {
+			err = fmt.Errorf("stopped waiting for the lock: %w", ctx.Err())
+		}
+		stopInterrupt()
+	}
+	if err != nil {
+		exitWithError("Error", err)
+	}
+	return func() { lock.Release() }
+}
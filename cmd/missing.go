@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fastbrew/internal/brew"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var missingCmd = &cobra.Command{
+	Use:   "missing [formula...]",
+	Short: "Check installed formulae for missing dependencies",
+	Long: `List installed formulae whose runtime dependencies are not installed,
+using the dependency lists in the cached index. With arguments, only the
+named formulae are checked.
+
+Exits with status 1 when any dependency is missing.`,
+	ValidArgsFunction: completeInstalledFormulae,
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newBrewClient()
+		if err != nil {
+			exitWithError("Error", err)
+		}
+		missing, err := client.Missing(args...)
+		if err != nil {
+			exitWithError("Error checking dependencies", err)
+		}
+
+		if jsonOutput {
+			if missing == nil {
+				missing = []brew.MissingDeps{}
+			}
+			printJSON(missing)
+		} else {
+			for _, item := range missing {
+				fmt.Fprintf(stdout, "%s: %s\n", item.Formula, strings.Join(item.Missing, " "))
+			}
+		}
+		if len(missing) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(missingCmd)
+}
@@ -1,35 +1,61 @@
 package cmd
 
 import (
+	"encoding/json"
 	"errors"
+	"fastbrew/internal/brew"
+	"fastbrew/internal/config"
 	"fastbrew/internal/daemon"
 	"fmt"
 	"time"
 )
 
-func tryRunMutationJob(commandName, operation string, packages []string, options daemon.JobSubmitOptions) (bool, error) {
-	daemonClient, daemonErr := getDaemonClientForRead()
+// tryRunMutationJob runs a mutation through the daemon when available. Job
+// events are printed, or fed to recorder when one is given.
+func tryRunMutationJob(commandName, operation string, packages []string, options daemon.JobSubmitOptions, recorder *mutationRecorder) (bool, error) {
+	daemonClient := mutationJobClient(commandName, operation)
 	if daemonClient == nil {
-		if daemonErr != nil {
-			warnDaemonFallback(commandName, daemonErr)
-		}
 		return false, nil
 	}
+	return runMutationJob(daemonClient, commandName, operation, packages, options, recorder)
+}
+
+// mutationJobClient returns the daemon client to run operation through, or
+// nil when it has to run in-process.
+func mutationJobClient(commandName, operation string) *daemon.Client {
+	// Hooks run here, with the user's configuration and terminal, rather
+	// than in the daemon. Reinstalls run none.
+	if operation != daemon.JobOperationReinstall && brew.HasHooks(config.Get().Hooks) {
+		return nil
+	}
 
+	daemonClient, daemonErr := getDaemonClientForRead()
+	if daemonClient == nil && daemonErr != nil {
+		warnDaemonFallback(commandName, daemonErr)
+	}
+	return daemonClient
+}
+
+// runMutationJob submits operation to daemonClient and streams its events.
+// It reports false when the job could not be submitted, so the command
+// falls back to running in-process.
+func runMutationJob(daemonClient *daemon.Client, commandName, operation string, packages []string, options daemon.JobSubmitOptions, recorder *mutationRecorder) (bool, error) {
 	jobID, err := daemonClient.SubmitJob(operation, packages, options)
 	if err != nil {
 		warnDaemonFallback(commandName, err)
 		return false, nil
 	}
 
-	if err := streamMutationJob(daemonClient, jobID); err != nil {
+	if err := streamMutationJob(daemonClient, jobID, recorder); err != nil {
 		return true, err
 	}
 
 	return true, nil
 }
 
-func streamMutationJob(client *daemon.Client, jobID string) error {
+func streamMutationJob(client *daemon.Client, jobID string, recorder *mutationRecorder) error {
+	// Download events relay the daemon's progress events for --progress-json.
+	progressEncoder := json.NewEncoder(progressOutput)
 	fromSeq := 0
 	for {
 		stream, err := client.JobStream(jobID, fromSeq, true)
@@ -38,7 +64,14 @@ func streamMutationJob(client *daemon.Client, jobID string) error {
 		}
 
 		for _, event := range stream.Events {
-			fmt.Println(formatMutationEvent(event))
+			if progressJSON && event.Progress != nil {
+				_ = progressEncoder.Encode(event.Progress)
+			}
+			if recorder != nil {
+				recorder.recordJobEvent(event)
+			} else {
+				fmt.Fprintln(stdout, formatMutationEvent(event))
+			}
 			fromSeq = event.Seq + 1
 		}
 
@@ -47,7 +80,7 @@ func streamMutationJob(client *daemon.Client, jobID string) error {
 			return nil
 		case daemon.JobStatusFailed:
 			if stream.Job.Error != "" {
-				return errors.New(stream.Job.Error)
+				return &brew.Error{Kind: brew.ErrorKind(stream.Job.ErrorKind), Err: errors.New(stream.Job.Error)}
 			}
 			return fmt.Errorf("daemon job %s failed", jobID)
 		default:
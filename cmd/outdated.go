@@ -1,53 +1,56 @@
 package cmd
 
 import (
-	"encoding/json"
+	"fastbrew/internal/brew"
+	"fastbrew/internal/cmdrunner"
+	"fastbrew/internal/config"
+	"fastbrew/internal/notify"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
 
 var (
-	outdatedQuiet bool
-	outdatedJSON  bool
+	outdatedQuiet   bool
+	outdatedGreedy  bool
+	outdatedFormula bool
+	outdatedCask    bool
+	outdatedNotify  bool
 )
 
+// notifyListLimit is how many package names a notification lists.
+const notifyListLimit = 5
+
 var outdatedCmd = &cobra.Command{
 	Use:   "outdated",
 	Short: "List outdated packages (faster than brew outdated)",
+	Long: `List installed formulae and casks that have a newer version available.
+
+Casks that update themselves are skipped unless --greedy is given. Packages
+whose new version is ruled out by a version constraint are marked held;
+upgrade leaves them alone.
+
+With --notify a desktop notification (osascript on macOS, notify-send on
+Linux) also sums up the packages upgrade would change, when there are any.
+It is meant for a timer: see 'fastbrew autoupdate enable --notify'.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		var outdated []OutdatedView
 
-		if daemonClient, daemonErr := getDaemonClientForRead(); daemonClient != nil {
-			daemonOutdated, err := daemonClient.Outdated()
-			if err == nil {
-				outdated = make([]OutdatedView, len(daemonOutdated))
-				for i, item := range daemonOutdated {
-					outdated[i] = OutdatedView{
-						Name:           item.Name,
-						CurrentVersion: item.CurrentVersion,
-						NewVersion:     item.NewVersion,
-						IsCask:         item.IsCask,
-					}
-				}
-			} else {
-				warnDaemonFallback("outdated", err)
-			}
-		} else if daemonErr != nil {
-			warnDaemonFallback("outdated", daemonErr)
+		// The daemon caches the default (non-greedy) view only.
+		if !outdatedGreedy {
+			outdated = outdatedFromDaemon()
 		}
 
 		if outdated == nil {
 			client, err := newBrewClient()
 			if err != nil {
-				fmt.Printf("Error: %v\n", err)
-				os.Exit(1)
+				exitWithError("Error", err)
 			}
-			localOutdated, outdatedErr := client.GetOutdated()
+			localOutdated, outdatedErr := client.GetOutdatedWithOptions(brew.OutdatedOptions{Greedy: outdatedGreedy})
 			if outdatedErr != nil {
-				fmt.Printf("Error checking for outdated packages: %v\n", outdatedErr)
-				os.Exit(1)
+				exitWithError("Error checking for outdated packages", outdatedErr)
 			}
 			outdated = make([]OutdatedView, len(localOutdated))
 			for i, item := range localOutdated {
@@ -56,28 +59,49 @@ var outdatedCmd = &cobra.Command{
 					CurrentVersion: item.CurrentVersion,
 					NewVersion:     item.NewVersion,
 					IsCask:         item.IsCask,
+					Held:           item.Held,
+					Constraint:     item.Constraint,
+					RenamedTo:      item.RenamedTo,
+				}
+			}
+		}
+
+		outdated = filterOutdatedKind(outdated, outdatedFormula, outdatedCask)
+
+		if outdatedNotify {
+			var names []string
+			for _, pkg := range outdated {
+				if !pkg.Held {
+					names = append(names, pkg.Name)
 				}
 			}
+			notifyOutdated(names)
+		}
+
+		if jsonOutput {
+			printJSON(outdated)
+			return
 		}
 
 		if len(outdated) == 0 {
 			os.Exit(0)
 		}
 
-		if outdatedJSON {
-			output, err := json.MarshalIndent(outdated, "", "  ")
-			if err != nil {
-				fmt.Printf("Error encoding JSON: %v\n", err)
-				os.Exit(1)
-			}
-			fmt.Println(string(output))
-		} else if outdatedQuiet {
+		if outdatedQuiet {
 			for _, pkg := range outdated {
-				fmt.Println(pkg.Name)
+				fmt.Fprintln(stdout, pkg.Name)
 			}
 		} else {
 			for _, pkg := range outdated {
-				fmt.Printf("%s (%s) < %s\n", pkg.Name, pkg.CurrentVersion, pkg.NewVersion)
+				if pkg.Held {
+					fmt.Fprintf(stdout, "%s (%s) < %s [held: %s]\n", pkg.Name, pkg.CurrentVersion, pkg.NewVersion, pkg.Constraint)
+					continue
+				}
+				if pkg.RenamedTo != "" {
+					fmt.Fprintf(stdout, "%s (%s) < %s [renamed to %s]\n", pkg.Name, pkg.CurrentVersion, pkg.NewVersion, pkg.RenamedTo)
+					continue
+				}
+				fmt.Fprintf(stdout, "%s (%s) < %s\n", pkg.Name, pkg.CurrentVersion, pkg.NewVersion)
 			}
 		}
 
@@ -89,10 +113,87 @@ type OutdatedView struct {
 	CurrentVersion string `json:"current_version"`
 	NewVersion     string `json:"new_version"`
 	IsCask         bool   `json:"is_cask"`
+	Held           bool   `json:"held"`
+	Constraint     string `json:"constraint,omitempty"`
+	RenamedTo      string `json:"renamed_to,omitempty"`
+}
+
+// outdatedFromDaemon returns the daemon's cached outdated list, or nil when
+// the daemon is unavailable.
+func outdatedFromDaemon() []OutdatedView {
+	daemonClient, daemonErr := getDaemonClientForRead()
+	if daemonClient == nil {
+		warnDaemonFallback("outdated", daemonErr)
+		return nil
+	}
+	daemonOutdated, err := daemonClient.Outdated()
+	if err != nil {
+		warnDaemonFallback("outdated", err)
+		return nil
+	}
+	// The daemon does not know the constraints in this user's config.
+	brew.MarkHeld(daemonOutdated, config.Get().Constraints)
+	outdated := make([]OutdatedView, len(daemonOutdated))
+	for i, item := range daemonOutdated {
+		outdated[i] = OutdatedView{
+			Name:           item.Name,
+			CurrentVersion: item.CurrentVersion,
+			NewVersion:     item.NewVersion,
+			IsCask:         item.IsCask,
+			Held:           item.Held,
+			Constraint:     item.Constraint,
+			RenamedTo:      item.RenamedTo,
+		}
+	}
+	return outdated
+}
+
+// filterOutdatedKind keeps only formulae or only casks when one of the
+// filters is set. The result is never nil so it encodes as a JSON array.
+func filterOutdatedKind(outdated []OutdatedView, formulaOnly, caskOnly bool) []OutdatedView {
+	filtered := make([]OutdatedView, 0, len(outdated))
+	for _, pkg := range outdated {
+		if formulaOnly && pkg.IsCask || caskOnly && !pkg.IsCask {
+			continue
+		}
+		filtered = append(filtered, pkg)
+	}
+	return filtered
+}
+
+// notifyOutdated posts a desktop notification listing names, unless it is
+// empty. A notification that cannot be sent, such as on a machine without
+// a desktop session, is only warned about on stderr.
+func notifyOutdated(names []string) {
+	if len(names) == 0 {
+		return
+	}
+	title, body := outdatedNotification(names)
+	if err := notify.Send(&cmdrunner.DefaultCommandRunner{}, title, body); err != nil {
+		fmt.Fprintf(stderr, "⚠️  Could not send a desktop notification: %v\n", err)
+	}
+}
+
+// outdatedNotification returns the title and body of the notification for
+// the outdated packages names.
+func outdatedNotification(names []string) (title, body string) {
+	listed := names
+	if len(listed) > notifyListLimit {
+		listed = listed[:notifyListLimit]
+	}
+	body = strings.Join(listed, ", ")
+	if more := len(names) - len(listed); more > 0 {
+		body += fmt.Sprintf(" and %d more", more)
+	}
+	return "fastbrew: " + outdatedCountText(len(names)), body + ". Run 'fastbrew upgrade'."
 }
 
 func init() {
 	outdatedCmd.Flags().BoolVarP(&outdatedQuiet, "quiet", "q", false, "Only display names of outdated packages")
-	outdatedCmd.Flags().BoolVar(&outdatedJSON, "json", false, "Output in JSON format")
+	outdatedCmd.Flags().BoolVarP(&outdatedGreedy, "greedy", "g", false, "Include casks that update themselves")
+	outdatedCmd.Flags().BoolVar(&outdatedFormula, "formula", false, "Only list outdated formulae")
+	outdatedCmd.Flags().BoolVar(&outdatedCask, "cask", false, "Only list outdated casks")
+	outdatedCmd.MarkFlagsMutuallyExclusive("formula", "cask")
+	outdatedCmd.Flags().BoolVar(&outdatedNotify, "notify", false, "Also post a desktop notification summing up the outdated packages")
 	rootCmd.AddCommand(outdatedCmd)
 }
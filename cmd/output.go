@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fastbrew/internal/brew"
+	"fastbrew/internal/config"
+	"fastbrew/internal/daemon"
+	"fastbrew/internal/emoji"
+	"fastbrew/internal/prompt"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// jsonOutput is set by the global --json flag. Commands that honor it write a
+// single JSON document to stdout and keep human-readable text off stdout.
+var jsonOutput bool
+
+// dryRun is set by the global --dry-run flag. Commands that honor it pass
+// it to the brew API as a DryRun option and report what would change.
+var dryRun bool
+
+// assumeYes and noInput are set by the global --yes and --no-input flags,
+// which override the confirm config key.
+var (
+	assumeYes bool
+	noInput   bool
+)
+
+// newPrompter returns the Prompter commands ask their questions through,
+// reading stdin under the confirmation policy.
+func newPrompter() *prompt.Prompter {
+	policy := config.Get().GetConfirmPolicy()
+	switch {
+	case assumeYes:
+		policy = prompt.PolicyYes
+	case noInput:
+		policy = prompt.PolicyNever
+	}
+	return prompt.New(os.Stdin, stdout, policy)
+}
+
+// progressJSON is set by the global --progress-json flag: download progress
+// is written to progressOutput as one JSON ProgressEvent per line.
+var progressJSON bool
+
+// progressOutput receives --progress-json events. It is not filtered like
+// stderr, as the events are for programs.
+var progressOutput io.Writer = os.Stderr
+
+// stdout and stderr receive human-readable command output. applyOutputConfig
+// replaces them with writers that strip emoji when the emoji key is off.
+var (
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
+)
+
+// applyOutputConfig applies the color and emoji settings before any command
+// runs. NO_COLOR turns colors off as well.
+func applyOutputConfig() {
+	cfg := config.Get()
+	if !cfg.Color || os.Getenv("NO_COLOR") != "" {
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+	stdout = plainOutput(os.Stdout)
+	stderr = plainOutput(os.Stderr)
+}
+
+// plainOutput wraps w to strip emoji when the emoji key is off.
+func plainOutput(w io.Writer) io.Writer {
+	if config.Get().Emoji {
+		return w
+	}
+	return emoji.NewWriter(w)
+}
+
+// MutationPackageView is the final reported state of one package in an
+// install/upgrade/uninstall/reinstall run.
+type MutationPackageView struct {
+	Name    string `json:"name"`
+	Phase   string `json:"phase"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// MutationResultView is the --json schema for mutating commands.
+type MutationResultView struct {
+	Operation string                `json:"operation"`
+	Requested []string              `json:"requested"`
+	Success   bool                  `json:"success"`
+	Error     string                `json:"error,omitempty"`
+	ErrorKind string                `json:"error_kind,omitempty"`
+	Packages  []MutationPackageView `json:"packages"`
+}
+
+// ErrorView is the --json schema for commands that fail before producing a result.
+type ErrorView struct {
+	Error string `json:"error"`
+	// Kind is the category of the failure, e.g. network or not-found.
+	Kind string `json:"kind,omitempty"`
+}
+
+// mutationRecorder keeps the latest non-progress event per package so a
+// mutating command can report per-package outcomes as JSON.
+type mutationRecorder struct {
+	mu     sync.Mutex
+	order  []string
+	latest map[string]MutationPackageView
+}
+
+func newMutationRecorder() *mutationRecorder {
+	return &mutationRecorder{latest: make(map[string]MutationPackageView)}
+}
+
+func (r *mutationRecorder) record(name, phase, status, message string) {
+	if name == "" || status == brew.MutationStatusProgress {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, seen := r.latest[name]; !seen {
+		r.order = append(r.order, name)
+	}
+	r.latest[name] = MutationPackageView{Name: name, Phase: phase, Status: status, Message: message}
+}
+
+func (r *mutationRecorder) recordMutation(event brew.MutationEvent) {
+	r.record(event.Package, event.Phase, event.Status, event.Message)
+}
+
+func (r *mutationRecorder) recordJobEvent(event daemon.JobEvent) {
+	if event.Kind != daemon.JobEventKindPackage {
+		return
+	}
+	r.record(event.Package, event.Phase, event.Status, event.Message)
+}
+
+func (r *mutationRecorder) packages() []MutationPackageView {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	views := make([]MutationPackageView, 0, len(r.order))
+	for _, name := range r.order {
+		views = append(views, r.latest[name])
+	}
+	return views
+}
+
+// result builds the JSON document for a finished mutating command.
+func (r *mutationRecorder) result(operation string, requested []string, err error) MutationResultView {
+	if requested == nil {
+		requested = []string{}
+	}
+	view := MutationResultView{
+		Operation: operation,
+		Requested: requested,
+		Success:   err == nil,
+		Packages:  r.packages(),
+	}
+	if err != nil {
+		view.Error = err.Error()
+		view.ErrorKind = string(brew.KindOf(err))
+	}
+	return view
+}
+
+// quietForJSON silences a client's progress text when --json is active.
+func quietForJSON(client *brew.Client) {
+	if jsonOutput {
+		client.Out = io.Discard
+	}
+}
+
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintf(stderr, "Error encoding JSON: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// exitCode returns the exit status for a command failing with err: one per
+// kind of failure (see fastbrew help exit-codes), or exitInterrupted when
+// the command was interrupted.
+func exitCode(err error) int {
+	if errors.Is(err, context.Canceled) {
+		return exitInterrupted
+	}
+	return brew.KindOf(err).ExitCode()
+}
+
+// exitForFailures exits with failuresExitCode when any package failed.
+func exitForFailures(errs []error, total int) {
+	if code := failuresExitCode(errs, total); code != 0 {
+		os.Exit(code)
+	}
+}
+
+// failuresExitCode returns the exit status of a command on total packages
+// of which those failing with errs failed: the status for the errors'
+// shared kind when every package failed, a partial failure when only some
+// did, and 0 when none did.
+func failuresExitCode(errs []error, total int) int {
+	if len(errs) == 0 {
+		return 0
+	}
+	if len(errs) < total {
+		return brew.ExitPartialFailure
+	}
+	return brew.SharedKind(errs).ExitCode()
+}
+
+// exitWithError reports err in the active output mode and exits with the
+// status for its kind. The text form is "<prefix>: <err>".
+func exitWithError(prefix string, err error) {
+	if jsonOutput {
+		printJSON(ErrorView{Error: err.Error(), Kind: string(brew.KindOf(err))})
+	} else {
+		fmt.Fprintf(stdout, "%s: %v\n", prefix, err)
+	}
+	os.Exit(exitCode(err))
+}
+
+// finishMutation reports the outcome of a mutating command. With a recorder
+// it prints the JSON result; otherwise it prints errPrefix or doneMsg. A
+// non-nil err exits with the status for its kind in both modes, or
+// exitInterrupted when the command was interrupted.
+func finishMutation(rec *mutationRecorder, operation string, requested []string, err error, errPrefix, doneMsg string) {
+	if rec != nil {
+		printJSON(rec.result(operation, requested, err))
+		if err != nil {
+			os.Exit(exitCode(err))
+		}
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintln(stdout, "⏹️  Interrupted. Partial downloads will resume next time.")
+		os.Exit(exitInterrupted)
+	}
+	if err != nil {
+		fmt.Fprintf(stdout, "%s: %v\n", errPrefix, err)
+		os.Exit(exitCode(err))
+	}
+	fmt.Fprintln(stdout, doneMsg)
+}
+
+// isTerminal reports whether f is attached to a character device such as a
+// terminal, as opposed to a pipe or file.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,407 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fastbrew/internal/brew"
+	"fastbrew/internal/config"
+	"fastbrew/internal/daemon"
+	"fastbrew/internal/prompt"
+	"fastbrew/internal/services"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMutationRecorderKeepsLatestOutcome(t *testing.T) {
+	rec := newMutationRecorder()
+	rec.recordMutation(brew.MutationEvent{Package: "jq", Phase: brew.MutationPhaseDownload, Status: brew.MutationStatusRunning})
+	rec.recordMutation(brew.MutationEvent{Package: "jq", Phase: brew.MutationPhaseDownload, Status: brew.MutationStatusProgress})
+	rec.recordMutation(brew.MutationEvent{Package: "wget", Phase: brew.MutationPhaseMetadata, Status: brew.MutationStatusFailed, Message: "not found"})
+	rec.recordMutation(brew.MutationEvent{Package: "jq", Phase: brew.MutationPhaseComplete, Status: brew.MutationStatusSucceeded})
+	rec.recordJobEvent(daemon.JobEvent{Kind: daemon.JobEventKindJob, Status: "failed"})
+
+	result := rec.result("install", []string{"jq", "wget"}, errors.New("1 package failed"))
+	if result.Success {
+		t.Error("Expected Success to be false when an error is given")
+	}
+	if result.Error != "1 package failed" {
+		t.Errorf("Expected error message to be preserved, got %q", result.Error)
+	}
+	if len(result.Packages) != 2 {
+		t.Fatalf("Expected 2 packages, got %d", len(result.Packages))
+	}
+	if result.Packages[0].Name != "jq" || result.Packages[0].Status != brew.MutationStatusSucceeded {
+		t.Errorf("Expected jq to end succeeded, got %+v", result.Packages[0])
+	}
+	if result.Packages[1].Name != "wget" || result.Packages[1].Message != "not found" {
+		t.Errorf("Expected wget failure to be recorded, got %+v", result.Packages[1])
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{errors.New("boom"), 1},
+		{&brew.Error{Kind: brew.KindNotFound, Err: errors.New("wget is not installed")}, brew.ExitNotFound},
+		{fmt.Errorf("install: %w", &brew.Error{Kind: brew.KindPartialFailure, Err: errors.New("1 package(s) failed")}), brew.ExitPartialFailure},
+		{fmt.Errorf("download: %w", context.Canceled), exitInterrupted},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+
+	result := newMutationRecorder().result("upgrade", nil, &brew.Error{Kind: brew.KindNetwork, Err: errors.New("offline")})
+	if result.ErrorKind != "network" {
+		t.Errorf("Expected error_kind network, got %q", result.ErrorKind)
+	}
+}
+
+func TestFailuresExitCode(t *testing.T) {
+	notInstalled := []error{notInstalledError("wget"), notInstalledError("jq")}
+	if got := failuresExitCode(nil, 2); got != 0 {
+		t.Errorf("no failures = %d, want 0", got)
+	}
+	if got := failuresExitCode(notInstalled, 2); got != brew.ExitNotFound {
+		t.Errorf("all not installed = %d, want %d", got, brew.ExitNotFound)
+	}
+	if got := failuresExitCode(notInstalled[:1], 2); got != brew.ExitPartialFailure {
+		t.Errorf("one of two failed = %d, want %d", got, brew.ExitPartialFailure)
+	}
+	if got := failuresExitCode(append(notInstalled[:1:1], errors.New("boom")), 2); got != brew.ExitOther {
+		t.Errorf("mixed failures = %d, want %d", got, brew.ExitOther)
+	}
+}
+
+func TestWriteServicesTable(t *testing.T) {
+	var out bytes.Buffer
+	writeServicesTable(&out, []services.Service{
+		{Name: "homebrew.mxcl.postgresql", Status: services.StatusRunning, Pid: 812, Scope: services.ScopeUser, PlistPath: "/Users/me/Library/LaunchAgents/homebrew.mxcl.postgresql.plist"},
+		{Name: "homebrew.mxcl.redis", Status: services.StatusError, LastExitCode: 78, Scope: services.ScopeSystem, PlistPath: "/Library/LaunchDaemons/homebrew.mxcl.redis.plist"},
+	})
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected a header and two rows, got %q", out.String())
+	}
+	if fields := strings.Fields(lines[0]); strings.Join(fields, " ") != "NAME STATUS PID SCOPE FILE" {
+		t.Errorf("Unexpected header %q", lines[0])
+	}
+	if fields := strings.Fields(lines[1]); len(fields) != 5 || fields[2] != "812" || fields[3] != "user" {
+		t.Errorf("Unexpected running row %q", lines[1])
+	}
+	if !strings.Contains(lines[2], "exit 78") || !strings.Contains(lines[2], "system") {
+		t.Errorf("Expected exit code and scope in error row, got %q", lines[2])
+	}
+}
+
+func TestConfigEntriesReportSources(t *testing.T) {
+	t.Setenv("FASTBREW_PARALLEL_DOWNLOADS", "3")
+
+	defaults := config.DefaultConfig()
+	file := config.DefaultConfig()
+	file.Verbose = true
+	file.Credentials = map[string]string{"ghcr.io": "secret"}
+	effective := *file
+	effective.ParallelDownloads = 3
+
+	sources := make(map[string]ConfigEntryView)
+	for _, e := range configEntries(&effective, file, defaults) {
+		sources[e.Key] = e
+	}
+	if e := sources["parallel_downloads"]; e.Source != "env" || e.Env != "FASTBREW_PARALLEL_DOWNLOADS" || e.Value != "3" {
+		t.Errorf("parallel_downloads = %+v, want env override", e)
+	}
+	if e := sources["verbose"]; e.Source != "file" {
+		t.Errorf("verbose source = %q, want file", e.Source)
+	}
+	if e := sources["daemon.prewarm"]; e.Source != "default" {
+		t.Errorf("daemon.prewarm source = %q, want default", e.Source)
+	}
+	if e := sources["credentials.ghcr.io"]; e.Value != "********" {
+		t.Errorf("credential value = %q, want it masked", e.Value)
+	}
+}
+
+func TestWritePackageTable(t *testing.T) {
+	packages := []PackageListView{
+		{Name: "jq", Version: "1.7", Versions: []string{"1.6", "1.7"}, MultipleVersions: true, Size: 2048},
+		{Name: "firefox", Version: "120.0", Versions: []string{"120.0"}, IsCask: true, Size: 300},
+	}
+
+	var out bytes.Buffer
+	writePackageTable(&out, packages, false)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected a header and two rows, got %q", out.String())
+	}
+	if fields := strings.Fields(lines[0]); strings.Join(fields, " ") != "NAME VERSION SIZE TYPE" {
+		t.Errorf("Unexpected header %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "1.7 (+1 older)") || !strings.HasSuffix(lines[1], "formula") {
+		t.Errorf("Expected older version count in jq row, got %q", lines[1])
+	}
+	if !strings.HasSuffix(lines[2], "cask") {
+		t.Errorf("Expected cask type in firefox row, got %q", lines[2])
+	}
+
+	out.Reset()
+	writePackageTable(&out, packages, true)
+	if !strings.Contains(out.String(), "1.6, 1.7") {
+		t.Errorf("Expected all versions with --versions, got %q", out.String())
+	}
+
+	packages[0].LinkedVersion = "1.6"
+	out.Reset()
+	writePackageTable(&out, packages, false)
+	if !strings.Contains(out.String(), "1.6 (switched; newest 1.7)") {
+		t.Errorf("Expected the switched version, got %q", out.String())
+	}
+	out.Reset()
+	writePackageTable(&out, packages, true)
+	if !strings.Contains(out.String(), "1.6*, 1.7") {
+		t.Errorf("Expected the linked version starred with --versions, got %q", out.String())
+	}
+}
+
+func TestPackageListFiltersAndSorts(t *testing.T) {
+	packages := []PackageListView{
+		{Name: "wget", Size: 10},
+		{Name: "firefox", IsCask: true, Size: 500},
+		{Name: "jq", Size: 20},
+	}
+
+	if casks := filterPackageKind(packages, true, false); len(casks) != 1 || casks[0].Name != "firefox" {
+		t.Errorf("Expected only firefox with --cask, got %+v", casks)
+	}
+	if formulae := filterPackageKind(packages, false, true); len(formulae) != 2 {
+		t.Errorf("Expected two formulae with --formula, got %+v", formulae)
+	}
+
+	sortPackageList(packages, "size")
+	if packages[0].Name != "firefox" || packages[2].Name != "wget" {
+		t.Errorf("Expected largest first, got %+v", packages)
+	}
+	sortPackageList(packages, "name")
+	if packages[0].Name != "firefox" || packages[1].Name != "jq" {
+		t.Errorf("Expected name order, got %+v", packages)
+	}
+}
+
+func TestWriteUpgradePlan(t *testing.T) {
+	var out bytes.Buffer
+	writeUpgradePlan(&out, &brew.UpgradePlan{
+		Packages: []brew.UpgradePlanEntry{
+			{Name: "firefox", CurrentVersion: "119.0", NewVersion: "120.0", IsCask: true, DownloadSize: -1},
+			{Name: "jq", CurrentVersion: "1.6", NewVersion: "1.7", DownloadSize: 0, Cached: true},
+			{Name: "wget", CurrentVersion: "1.21", NewVersion: "1.24", DownloadSize: 2048},
+		},
+		DownloadSize: 2048,
+		UnknownSizes: 1,
+	})
+
+	text := out.String()
+	for _, want := range []string{"3 package(s) to upgrade", "unknown", "cached", "2.0 KB", "plus 1 of unknown size"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in plan, got %q", want, text)
+		}
+	}
+}
+
+func TestWriteUpdateSummary(t *testing.T) {
+	var out bytes.Buffer
+	writeUpdateSummary(&out, UpdateView{Changed: true, Added: []string{"curl"}, Updated: []string{"jq", "wget"}}, true)
+	text := out.String()
+	for _, want := range []string{"3 formulae changed (1 new, 2 updated, 0 removed)", "New: curl", "Updated: jq, wget"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in summary, got %q", want, text)
+		}
+	}
+	if strings.Contains(text, "Removed:") {
+		t.Errorf("Expected no empty Removed line, got %q", text)
+	}
+
+	out.Reset()
+	writeUpdateSummary(&out, UpdateView{}, true)
+	if out.String() != "Already up-to-date.\n" {
+		t.Errorf("Expected up-to-date message, got %q", out.String())
+	}
+}
+
+func TestWriteRunReport(t *testing.T) {
+	var out bytes.Buffer
+	writeRunReport(&out, &brew.RunReport{
+		Operation:        "install",
+		WallTime:         2500 * time.Millisecond,
+		BytesTransferred: 1024,
+		Packages: []brew.PackageTiming{
+			{Name: "jq", Version: "1.7", DownloadSize: 2048, DownloadTime: 120 * time.Millisecond, ExtractTime: 30 * time.Millisecond, Links: 4},
+			{Name: "oniguruma", Version: "6.9", DownloadSize: 1024, Links: 1},
+		},
+	})
+
+	text := out.String()
+	for _, want := range []string{"jq", "120ms", "30ms", "2.5s", "1.0 KB transferred", "3.0 KB of bottles", "2 package(s)"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in report, got %q", want, text)
+		}
+	}
+	if !strings.Contains(text, "-") {
+		t.Errorf("Expected steps that did not run to show -, got %q", text)
+	}
+}
+
+func TestWriteBenchmarkReport(t *testing.T) {
+	var out bytes.Buffer
+	writeBenchmarkReport(&out, &brew.BenchmarkReport{
+		Formulae: []string{"jq"},
+		Runs: []brew.BenchmarkRun{
+			{Tool: brew.BenchmarkToolFastbrew, Cache: brew.BenchmarkCacheCold, Duration: time.Second, Kegs: 2, BytesTransferred: 2048},
+			{Tool: brew.BenchmarkToolFastbrew, Cache: brew.BenchmarkCacheWarm, Duration: 200 * time.Millisecond, Kegs: 2},
+			{Tool: brew.BenchmarkToolBrew, Cache: brew.BenchmarkCacheCold, Duration: 4 * time.Second, Kegs: 2},
+			{Tool: brew.BenchmarkToolBrew, Cache: brew.BenchmarkCacheWarm, Error: "exit status 1"},
+		},
+	})
+
+	text := out.String()
+	for _, want := range []string{"2.0 KB", "200ms", "cold cache: fastbrew was 4.0x faster", "brew (warm cache): exit status 1"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in report, got %q", want, text)
+		}
+	}
+	if strings.Contains(text, "warm cache: fastbrew was") {
+		t.Errorf("Expected no comparison against a failed run, got %q", text)
+	}
+}
+
+func TestSelectUpgrades(t *testing.T) {
+	stdout = &bytes.Buffer{}
+	defer func() { stdout = plainOutput(os.Stdout) }()
+
+	plan := &brew.UpgradePlan{Packages: []brew.UpgradePlanEntry{{Name: "jq"}, {Name: "wget"}}}
+	outdated := []brew.OutdatedPackage{{Name: "jq"}, {Name: "wget"}}
+
+	selected, err := selectUpgrades(plan, outdated, answering("7\n2\n"))
+	if err != nil || len(selected) != 1 || selected[0].Name != "wget" {
+		t.Errorf("Expected wget after an invalid answer, got %+v (%v)", selected, err)
+	}
+	if selected, err := selectUpgrades(plan, outdated, answering("")); selected != nil || !errors.Is(err, prompt.ErrInputRequired) {
+		t.Errorf("Expected an input error at end of input, got %+v (%v)", selected, err)
+	}
+	if selected, err := selectUpgrades(plan, outdated, prompt.New(strings.NewReader(""), stdout, prompt.PolicyYes)); err != nil || len(selected) != 2 {
+		t.Errorf("Expected --yes to select everything, got %+v (%v)", selected, err)
+	}
+	if _, err := selectUpgrades(plan, outdated, prompt.New(strings.NewReader("1\n"), stdout, prompt.PolicyNever)); !errors.Is(err, prompt.ErrInputRequired) {
+		t.Errorf("Expected --no-input to fail, got %v", err)
+	}
+}
+
+// answering returns a Prompter that reads input as the user's answers.
+func answering(input string) *prompt.Prompter {
+	return prompt.New(strings.NewReader(input), stdout, prompt.PolicyAsk)
+}
+
+func TestConfirmZap(t *testing.T) {
+	out := &bytes.Buffer{}
+	stdout = out
+	defer func() { stdout = plainOutput(os.Stdout) }()
+
+	plan := &brew.ZapPlan{Token: "firefox", Trash: []string{"/Users/me/Library/Caches/Firefox"}, Refused: []string{"/Users/me/Library"}}
+	if confirmZap(plan, false, answering("\n")) {
+		t.Error("Expected the default answer to decline")
+	}
+	if !confirmZap(plan, false, answering("y\n")) {
+		t.Error("Expected y to confirm")
+	}
+	if !confirmZap(plan, true, answering("")) {
+		t.Error("Expected --force to skip the question")
+	}
+	if !confirmZap(&brew.ZapPlan{Token: "firefox"}, false, answering("")) {
+		t.Error("Expected an empty plan to need no confirmation")
+	}
+	for _, want := range []string{"Library/Caches/Firefox", "Leaving /Users/me/Library", "No leftover files"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in output, got %q", want, out.String())
+		}
+	}
+}
+
+func TestStopServicesFor(t *testing.T) {
+	out := &bytes.Buffer{}
+	stdout = out
+	defer func() { stdout = plainOutput(os.Stdout) }()
+
+	mgr := services.NewFakeManager(
+		services.Service{Name: "homebrew.mxcl.postgresql@14", Status: services.StatusRunning},
+		services.Service{Name: "homebrew.mxcl.redis", Status: services.StatusStopped},
+		services.Service{Name: "com.example.agent", Status: services.StatusRunning},
+	)
+	running := runningHomebrewServices(mgr)
+	if len(running) != 1 || running[0].Name != "homebrew.mxcl.postgresql@14" {
+		t.Fatalf("runningHomebrewServices() = %+v, want only postgresql@14", running)
+	}
+	if got := servicesBacking(running, []string{"jq", "redis"}); len(got) != 0 {
+		t.Errorf("Expected no running service to back jq or redis, got %+v", got)
+	}
+	backing := servicesBacking(running, []string{"postgresql@14"})
+
+	if _, ok := stopServicesFor("upgrade", backing, false, mgr, answering("\n")); ok {
+		t.Error("Expected the default answer to cancel")
+	}
+	if _, ok := stopServicesFor("upgrade", backing, false, mgr, prompt.New(strings.NewReader("y\n"), stdout, prompt.PolicyNever)); ok || len(mgr.Called()) != 0 {
+		t.Errorf("Expected --no-input to cancel without stopping anything, got %v", mgr.Called())
+	}
+	if _, ok := stopServicesFor("upgrade", backing, true, mgr, answering("")); !ok || len(mgr.Called()) != 0 {
+		t.Errorf("Expected --force to go ahead without stopping anything, got %v", mgr.Called())
+	}
+	stopped, ok := stopServicesFor("upgrade", backing, false, mgr, answering("y\n"))
+	if !ok || len(stopped) != 1 {
+		t.Fatalf("Expected y to stop postgresql@14, got %v", mgr.Called())
+	}
+	startServices(stopped, mgr)
+	want := []string{"stop homebrew.mxcl.postgresql@14", "start homebrew.mxcl.postgresql@14"}
+	if !reflect.DeepEqual(mgr.Called(), want) {
+		t.Errorf("Expected postgresql@14 to be stopped and started again, got %v", mgr.Called())
+	}
+	if !strings.Contains(out.String(), "homebrew.mxcl.postgresql@14 is running from postgresql@14") {
+		t.Errorf("Expected a warning naming the service, got %q", out.String())
+	}
+}
+
+func TestRestartUnhealthy(t *testing.T) {
+	healthy, unhealthy := true, false
+	views := []ServiceHealthView{
+		{Name: "homebrew.mxcl.postgresql@14", Status: "running", Check: "tcp localhost:5432", Healthy: &unhealthy, Error: "connection refused", Restart: true},
+		{Name: "homebrew.mxcl.redis", Status: "running", Check: "command redis-cli ping", Healthy: &unhealthy, Error: "exit status 1"},
+		{Name: "homebrew.mxcl.web", Status: "running", Check: "http http://localhost:8080", Healthy: &healthy, Restart: true},
+		{Name: "homebrew.mxcl.nginx", Status: "stopped"},
+	}
+
+	var out bytes.Buffer
+	mgr := services.NewFakeManager()
+	for _, view := range views {
+		mgr.Services = append(mgr.Services, services.Service{Name: view.Name, Status: services.ServiceStatus(view.Status)})
+	}
+	if n := restartUnhealthy(&out, mgr, views); n != 1 || !reflect.DeepEqual(mgr.Called(), []string{"restart homebrew.mxcl.postgresql@14"}) {
+		t.Errorf("restartUnhealthy() = %d, called %v; want only postgresql@14 restarted", n, mgr.Called())
+	}
+	if !strings.Contains(out.String(), "homebrew.mxcl.redis is unhealthy (exit status 1); restart is not enabled") {
+		t.Errorf("Expected redis to be logged without a restart, got %q", out.String())
+	}
+
+	out.Reset()
+	writeHealthTable(&out, views)
+	for _, want := range []string{"unhealthy: connection refused", "healthy  ", "homebrew.mxcl.nginx          stopped  -"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in the health table, got %q", want, out.String())
+		}
+	}
+}
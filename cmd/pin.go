@@ -1,111 +1,72 @@
 package cmd
 
 import (
-	"bufio"
+	"fastbrew/internal/brew"
 	"fmt"
 	"os"
-	"path/filepath"
-	"strings"
 
 	"github.com/spf13/cobra"
 )
 
-func getPinFilePath() string {
-	home, _ := os.UserHomeDir()
-	return filepath.Join(home, ".fastbrew", "pinned")
-}
-
 func loadPinnedPackages() (map[string]bool, error) {
-	pinned := make(map[string]bool)
-	path := getPinFilePath()
-
-	file, err := os.Open(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return pinned, nil
-		}
-		return nil, err
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		name := strings.TrimSpace(scanner.Text())
-		if name != "" && !strings.HasPrefix(name, "#") {
-			pinned[name] = true
-		}
-	}
-	return pinned, scanner.Err()
+	return brew.LoadPinned()
 }
 
 func savePinnedPackages(pinned map[string]bool) error {
-	path := getPinFilePath()
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return err
-	}
-
-	file, err := os.Create(path)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	for name := range pinned {
-		fmt.Fprintln(file, name)
-	}
-	return nil
+	return brew.SavePinned(pinned)
 }
 
 var pinCmd = &cobra.Command{
-	Use:   "pin <package>",
-	Short: "Pin a package to prevent upgrades",
-	Args:  cobra.ExactArgs(1),
+	Use:               "pin <package>",
+	Short:             "Pin a package to prevent upgrades",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: singleArg(completeInstalledPackages),
 	Run: func(cmd *cobra.Command, args []string) {
 		pkg := args[0]
 		pinned, err := loadPinnedPackages()
 		if err != nil {
-			fmt.Printf("Error loading pinned packages: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Error loading pinned packages: %v\n", err)
+			os.Exit(exitCode(err))
 		}
 
 		if pinned[pkg] {
-			fmt.Printf("📌 %s is already pinned\n", pkg)
+			fmt.Fprintf(stdout, "📌 %s is already pinned\n", pkg)
 			return
 		}
 
 		pinned[pkg] = true
 		if err := savePinnedPackages(pinned); err != nil {
-			fmt.Printf("Error saving pinned packages: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Error saving pinned packages: %v\n", err)
+			os.Exit(exitCode(err))
 		}
-		fmt.Printf("📌 Pinned %s\n", pkg)
+		fmt.Fprintf(stdout, "📌 Pinned %s\n", pkg)
 	},
 }
 
 var unpinCmd = &cobra.Command{
-	Use:   "unpin <package>",
-	Short: "Unpin a package to allow upgrades",
-	Args:  cobra.ExactArgs(1),
+	Use:               "unpin <package>",
+	Short:             "Unpin a package to allow upgrades",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: singleArg(completePinnedPackages),
 	Run: func(cmd *cobra.Command, args []string) {
 		pkg := args[0]
 		pinned, err := loadPinnedPackages()
 		if err != nil {
-			fmt.Printf("Error loading pinned packages: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Error loading pinned packages: %v\n", err)
+			os.Exit(exitCode(err))
 		}
 
 		if !pinned[pkg] {
-			fmt.Printf("%s is not pinned\n", pkg)
+			fmt.Fprintf(stdout, "%s is not pinned\n", pkg)
 			return
 		}
 
 		delete(pinned, pkg)
 		if err := savePinnedPackages(pinned); err != nil {
-			fmt.Printf("Error saving pinned packages: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Error saving pinned packages: %v\n", err)
+			os.Exit(exitCode(err))
 		}
-		fmt.Printf("📍 Unpinned %s\n", pkg)
+		fmt.Fprintf(stdout, "📍 Unpinned %s\n", pkg)
 	},
 }
 
@@ -115,18 +76,18 @@ var pinnedCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		pinned, err := loadPinnedPackages()
 		if err != nil {
-			fmt.Printf("Error loading pinned packages: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Error loading pinned packages: %v\n", err)
+			os.Exit(exitCode(err))
 		}
 
 		if len(pinned) == 0 {
-			fmt.Println("No pinned packages.")
+			fmt.Fprintln(stdout, "No pinned packages.")
 			return
 		}
 
-		fmt.Println("📌 Pinned packages:")
+		fmt.Fprintln(stdout, "📌 Pinned packages:")
 		for name := range pinned {
-			fmt.Printf("  • %s\n", name)
+			fmt.Fprintf(stdout, "  • %s\n", name)
 		}
 	},
 }
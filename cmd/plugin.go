@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"errors"
+	"fastbrew/internal/config"
+	"fastbrew/internal/paths"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// pluginPrefix starts the names of executables on PATH that run as
+// fastbrew subcommands: fastbrew-foo is invoked as "fastbrew foo".
+const pluginPrefix = "fastbrew-"
+
+// Plugin is an external subcommand found on PATH.
+type Plugin struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	// Shadowed is set when a built-in command of the same name runs
+	// instead.
+	Shadowed bool `json:"shadowed,omitempty"`
+}
+
+var pluginCmd = &cobra.Command{
+	Use:   "plugin",
+	Short: "Work with fastbrew-<name> plugin commands",
+	Long: `Executables named fastbrew-<name> on PATH run as 'fastbrew <name>', with the
+remaining arguments passed through. Built-in commands take precedence, and
+the first match on PATH wins.
+
+Plugins receive:
+  FASTBREW_BIN          path to the fastbrew executable
+  FASTBREW_CLI_VERSION  fastbrew's version
+  FASTBREW_PLUGIN       the plugin's name
+  FASTBREW_PREFIX       Homebrew prefix (unset when none is found)
+  FASTBREW_CELLAR       Cellar under the prefix
+  FASTBREW_CACHE_DIR    download and index cache
+  FASTBREW_CONFIG       path to config.json
+  FASTBREW_STATE_DIR    pins, install reasons, taps and journals
+
+'fastbrew plugin index' prints the package index as JSON, and most commands
+accept --json for machine-readable output.`,
+}
+
+var pluginListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List fastbrew-<name> plugins on PATH",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		plugins := findPlugins(os.Getenv("PATH"))
+		if jsonOutput {
+			if plugins == nil {
+				plugins = []Plugin{}
+			}
+			printJSON(plugins)
+			return
+		}
+		if len(plugins) == 0 {
+			fmt.Fprintln(stdout, "No plugins found on PATH.")
+			return
+		}
+		writePluginTable(stdout, plugins)
+	},
+}
+
+var pluginIndexCask bool
+
+var pluginIndexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Print the formula (or cask) index as JSON",
+	Long: `Print the cached formula index, or the cask index with --cask, as the JSON
+array formulae.brew.sh serves. The cache is refreshed first when it is stale.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newBrewClient()
+		if err != nil {
+			exitWithError("Error", err)
+		}
+		client.Out = io.Discard
+		data, err := client.RawIndexJSON(pluginIndexCask)
+		if err != nil {
+			exitWithError("Error", err)
+		}
+		os.Stdout.Write(data)
+	},
+}
+
+func writePluginTable(out io.Writer, plugins []Plugin) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tPATH")
+	for _, p := range plugins {
+		path := p.Path
+		if p.Shadowed {
+			path += " (shadowed by built-in command)"
+		}
+		fmt.Fprintf(w, "%s\t%s\n", p.Name, path)
+	}
+	w.Flush()
+}
+
+// findPlugins lists the plugins in the directories of pathList, sorted by
+// name. Only the first executable for each name is kept, as that is the
+// one that runs.
+func findPlugins(pathList string) []Plugin {
+	seen := make(map[string]bool)
+	var plugins []Plugin
+	for _, dir := range filepath.SplitList(pathList) {
+		if dir == "" {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := pluginName(entry.Name())
+			if !ok || seen[name] {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if !isExecutableFile(path) {
+				continue
+			}
+			seen[name] = true
+			plugins = append(plugins, Plugin{Name: name, Path: path, Shadowed: isBuiltinCommand(name)})
+		}
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins
+}
+
+// pluginName returns the subcommand an executable named file provides.
+func pluginName(file string) (string, bool) {
+	name, ok := strings.CutPrefix(file, pluginPrefix)
+	if ok && runtime.GOOS == "windows" {
+		name, ok = strings.CutSuffix(strings.ToLower(name), ".exe")
+	}
+	return name, ok && name != ""
+}
+
+func isExecutableFile(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	return runtime.GOOS == "windows" || info.Mode().Perm()&0111 != 0
+}
+
+// isBuiltinCommand reports whether name runs a command of fastbrew itself,
+// including help and the hidden completion commands cobra adds.
+func isBuiltinCommand(name string) bool {
+	if name == "help" || strings.HasPrefix(name, "__") {
+		return true
+	}
+	for _, c := range rootCmd.Commands() {
+		if c.Name() == name || c.HasAlias(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupPlugin finds the plugin that "fastbrew name" should run: the first
+// fastbrew-<name> on PATH, unless name is a flag or a built-in command.
+func lookupPlugin(name string) (string, bool) {
+	if name == "" || strings.HasPrefix(name, "-") || strings.ContainsRune(name, filepath.Separator) || isBuiltinCommand(name) {
+		return "", false
+	}
+	path, err := exec.LookPath(pluginPrefix + name)
+	if err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+// pluginEnv is the environment a plugin runs with: this process's plus the
+// variables documented in pluginCmd's help.
+func pluginEnv(name string) []string {
+	cfg := config.Get()
+	env := append(os.Environ(),
+		"FASTBREW_CLI_VERSION="+Version,
+		"FASTBREW_PLUGIN="+name,
+		"FASTBREW_CONFIG="+config.GetConfigPath(),
+		"FASTBREW_STATE_DIR="+paths.StateDir(),
+	)
+	if exe, err := os.Executable(); err == nil {
+		env = append(env, "FASTBREW_BIN="+exe)
+	}
+	cacheDir := cfg.GetCacheDir()
+	if cacheDir == "" {
+		cacheDir = paths.CacheDir()
+	}
+	env = append(env, "FASTBREW_CACHE_DIR="+cacheDir)
+	if client, err := newBrewClient(); err == nil {
+		env = append(env, "FASTBREW_PREFIX="+client.Prefix, "FASTBREW_CELLAR="+client.Cellar)
+	}
+	return env
+}
+
+// runPlugin runs the plugin at path with args, connected to this process's
+// terminal, and returns its exit status. Ctrl-C reaches the plugin, which
+// decides when to stop; fastbrew waits for it.
+func runPlugin(name, path string, args []string) int {
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = pluginEnv(name)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	defer signal.Stop(signals)
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exitErr) && exitErr.ExitCode() >= 0:
+		return exitErr.ExitCode()
+	case errors.As(err, &exitErr):
+		// Killed by a signal.
+		return exitInterrupted
+	default:
+		fmt.Fprintf(stderr, "Error: running plugin %s: %v\n", name, err)
+		return 1
+	}
+}
+
+func init() {
+	pluginIndexCmd.Flags().BoolVar(&pluginIndexCask, "cask", false, "Print the cask index instead of the formula index")
+	pluginCmd.AddCommand(pluginListCmd)
+	pluginCmd.AddCommand(pluginIndexCmd)
+	rootCmd.AddCommand(pluginCmd)
+}
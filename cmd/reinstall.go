@@ -5,7 +5,7 @@ import (
 	"fastbrew/internal/daemon"
 	"fmt"
 	"os"
-	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -14,90 +14,114 @@ var (
 	reinstallBuildFromSource bool
 	reinstallForce           bool
 	reinstallVerbose         bool
+	reinstallForceDownload   bool
 )
 
 var reinstallCmd = &cobra.Command{
 	Use:   "reinstall [formula...]",
 	Short: "Uninstall and then install a formula",
-	Long:  `Reinstall a formula by first uninstalling it, then installing it again.`,
-	Args:  cobra.MinimumNArgs(1),
+	Long: `Reinstall a formula by unlinking and removing its keg, then extracting and
+linking a fresh copy of its bottle. The bottle is fetched before anything is
+removed, and the opt link keeps pointing at a keg throughout so dependents
+stay usable. Pass --force-download to ignore the cached bottle.`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeInstalledFormulae,
 	Run: func(cmd *cobra.Command, args []string) {
-		if ran, err := tryRunMutationJob("reinstall", daemon.JobOperationReinstall, args, daemon.JobSubmitOptions{}); ran {
+		if ran, err := tryRunMutationJob("reinstall", daemon.JobOperationReinstall, args, daemon.JobSubmitOptions{ForceDownload: reinstallForceDownload}, nil); ran {
 			if err != nil {
-				fmt.Printf("Error: %v\n", err)
-				os.Exit(1)
+				fmt.Fprintf(stdout, "Error: %v\n", err)
+				os.Exit(exitCode(err))
 			}
 			return
 		}
 
+		defer lockFastbrew()()
+
 		client, err := newBrewClient()
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+
+		if reinstallVerbose {
+			client.Verbose = true
 		}
+		ctx, stopInterrupt := interruptContext(cmd.Context())
+		defer stopInterrupt()
 
+		stopProgress := startProgressDisplay(client, false, false)
+
+		var errs []error
 		for _, pkg := range args {
-			fmt.Printf("🔄 Reinstalling %s...\n", pkg)
+			if ctx.Err() != nil {
+				break
+			}
+			fmt.Fprintf(stdout, "🔄 Reinstalling %s...\n", pkg)
 
 			isCask, _ := client.IsCask(pkg)
 			if isCask {
-				fmt.Println("  🍷 Reinstalling cask...")
+				fmt.Fprintln(stdout, "  🍷 Reinstalling cask...")
 				installer := brew.NewCaskInstaller(client)
 				installer.SetOperation(brew.MutationOperationReinstall)
 				if err := installer.Uninstall(pkg); err != nil {
-					fmt.Printf("  ⚠️  Uninstall warning: %v\n", err)
+					fmt.Fprintf(stdout, "  ⚠️  Uninstall warning: %v\n", err)
 				}
 				if err := installer.Install(pkg, client.ProgressManager); err != nil {
-					fmt.Printf("  ❌ Error reinstalling cask: %v\n", err)
+					fmt.Fprintf(stdout, "  ❌ Error reinstalling cask: %v\n", err)
+					errs = append(errs, err)
 				} else {
-					fmt.Printf("  ✅ %s reinstalled successfully!\n", pkg)
+					fmt.Fprintf(stdout, "  ✅ %s reinstalled successfully!\n", pkg)
 				}
 				continue
 			}
 
-			fmt.Println("  🔗 Unlinking...")
-			if err := client.Unlink(pkg); err != nil && reinstallVerbose {
-				fmt.Printf("  ⚠️  Unlink warning: %v\n", err)
-			}
-
-			fmt.Println("  🗑️  Removing old version...")
-			pkgPath := filepath.Join(client.Cellar, pkg)
-			if err := os.RemoveAll(pkgPath); err != nil && reinstallVerbose {
-				fmt.Printf("  ⚠️  Removal warning: %v\n", err)
-			}
-
-			formula, err := client.FetchFormula(pkg)
+			result, err := client.Reinstall(ctx, pkg, brew.ReinstallOptions{ForceDownload: reinstallForceDownload})
 			if err != nil {
-				fmt.Printf("  ❌ Error fetching formula: %v\n", err)
+				fmt.Fprintf(stdout, "  ❌ Error reinstalling: %v\n", err)
+				errs = append(errs, err)
 				continue
 			}
-
-			fmt.Println("  📦 Installing...")
-			if err := client.InstallBottle(formula); err != nil {
-				fmt.Printf("  ❌ Error installing: %v\n", err)
-				continue
-			}
-
-			fmt.Println("  🔗 Linking...")
-			result, err := client.Link(formula.Name, formula.Versions.Stable)
-			if err != nil {
-				fmt.Printf("  ❌ Error linking: %v\n", err)
-				continue
-			}
-
-			if result.Success {
-				fmt.Printf("  ✅ %s reinstalled successfully!\n", pkg)
-			} else {
-				fmt.Printf("  ⚠️  Reinstalled with %d error(s)\n", len(result.Errors))
-			}
+			printReinstallResult(result)
+		}
+		stopProgress()
+		cleanupAfterInterrupt(ctx, client)
+		if err := ctx.Err(); err != nil {
+			finishMutation(nil, "reinstall", args, err, "Error reinstalling", "")
 		}
+		exitForFailures(errs, len(args))
 	},
 }
 
+// printReinstallResult reports what a formula reinstall changed.
+func printReinstallResult(result *brew.ReinstallResult) {
+	source := "cached bottle"
+	if result.Downloaded {
+		source = "downloaded bottle"
+	}
+	if result.PreviousVersion != result.Version {
+		fmt.Fprintf(stdout, "  ✅ %s %s → %s (%s)\n", result.Name, result.PreviousVersion, result.Version, source)
+	} else {
+		fmt.Fprintf(stdout, "  ✅ %s %s reinstalled (%s)\n", result.Name, result.Version, source)
+	}
+	if len(result.RemovedKegs) > 0 {
+		fmt.Fprintf(stdout, "     Removed kegs: %s\n", strings.Join(result.RemovedKegs, ", "))
+	}
+	if len(result.LinksAdded) > 0 {
+		fmt.Fprintf(stdout, "     Links added: %s\n", strings.Join(result.LinksAdded, ", "))
+	}
+	if len(result.LinksRemoved) > 0 {
+		fmt.Fprintf(stdout, "     Links removed: %s\n", strings.Join(result.LinksRemoved, ", "))
+	}
+	if !result.Changed() {
+		fmt.Fprintln(stdout, "     No version or link changes")
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(reinstallCmd)
 
 	reinstallCmd.Flags().BoolVar(&reinstallBuildFromSource, "build-from-source", false, "Compile from source instead of using bottle")
 	reinstallCmd.Flags().BoolVar(&reinstallForce, "force", false, "Force reinstall even if already latest")
+	reinstallCmd.Flags().BoolVar(&reinstallForceDownload, "force-download", false, "Download the bottle again instead of using the cached copy")
 	reinstallCmd.Flags().BoolVarP(&reinstallVerbose, "verbose", "v", false, "Show detailed output")
 }
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fastbrew/internal/brew"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var rollbackList bool
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback [transaction-id]",
+	Short: "Undo an interrupted or previous install/upgrade",
+	Long: `Revert the kegs and symlinks recorded in an install or upgrade transaction.
+
+Without arguments, every transaction that never finished (for example after a
+crash or Ctrl+C) is rolled back. Pass a transaction id to undo a specific run,
+or --list to see the recorded transactions.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newBrewClient()
+		if err != nil {
+			exitWithError("Error", err)
+		}
+
+		if rollbackList {
+			txns, err := client.ListTransactions()
+			if err != nil {
+				exitWithError("Error listing transactions", err)
+			}
+			printTransactions(txns)
+			return
+		}
+
+		// A running install's journal is pending too; wait for it to end.
+		defer lockFastbrew()()
+
+		var rolledBack []*brew.Transaction
+		if len(args) == 1 {
+			txn, err := client.RollbackTransaction(args[0])
+			if err != nil {
+				exitWithError("Error rolling back", err)
+			}
+			rolledBack = append(rolledBack, txn)
+		} else {
+			rolledBack, err = client.RollbackPending()
+			if err != nil {
+				exitWithError("Error rolling back", err)
+			}
+		}
+
+		if jsonOutput {
+			printJSON(transactionViews(rolledBack))
+			return
+		}
+		if len(rolledBack) == 0 {
+			fmt.Fprintln(stdout, "✅ No interrupted transactions to roll back.")
+			return
+		}
+		for _, txn := range rolledBack {
+			fmt.Fprintf(stdout, "↩️  Rolled back %s (%s: %s)\n", txn.ID, txn.Operation, strings.Join(txn.Packages, ", "))
+		}
+	},
+}
+
+type TransactionView struct {
+	ID        string   `json:"id"`
+	Operation string   `json:"operation"`
+	Status    string   `json:"status"`
+	StartedAt string   `json:"started_at"`
+	Packages  []string `json:"packages"`
+	Steps     int      `json:"steps"`
+}
+
+func transactionViews(txns []*brew.Transaction) []TransactionView {
+	views := make([]TransactionView, len(txns))
+	for i, txn := range txns {
+		views[i] = TransactionView{
+			ID:        txn.ID,
+			Operation: txn.Operation,
+			Status:    txn.Status,
+			StartedAt: txn.StartedAt.Format("2006-01-02 15:04:05"),
+			Packages:  txn.Packages,
+			Steps:     len(txn.Steps),
+		}
+	}
+	return views
+}
+
+func printTransactions(txns []*brew.Transaction) {
+	views := transactionViews(txns)
+	if jsonOutput {
+		printJSON(views)
+		return
+	}
+	if len(views) == 0 {
+		fmt.Fprintln(stdout, "No transactions recorded.")
+		return
+	}
+
+	fmt.Fprintf(stdout, "%-40s %-10s %-12s %s\n", "ID", "OPERATION", "STATUS", "PACKAGES")
+	for _, v := range views {
+		fmt.Fprintf(stdout, "%-40s %-10s %-12s %s\n", v.ID, v.Operation, v.Status, strings.Join(v.Packages, ", "))
+	}
+}
+
+func init() {
+	rollbackCmd.Flags().BoolVar(&rollbackList, "list", false, "List recorded transactions")
+	rootCmd.AddCommand(rollbackCmd)
+}
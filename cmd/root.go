@@ -1,9 +1,16 @@
 package cmd
 
 import (
+	"context"
+	"fastbrew/internal/brew"
+	"fastbrew/internal/log"
+	"fastbrew/internal/paths"
 	"fastbrew/internal/tui"
 	"fmt"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 
 	"github.com/spf13/cobra"
 )
@@ -15,18 +22,116 @@ var rootCmd = &cobra.Command{
 It features parallel execution, a modern TUI, and zero-latency search.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := tui.Start(); err != nil {
-			fmt.Printf("Error running TUI: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Error running TUI: %v\n", err)
+			os.Exit(exitCode(err))
 		}
 	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		printOutdatedHint(cmd)
+	},
 }
 
+var (
+	logFile  string
+	logLevel string
+)
+
 func Execute() {
+	defer log.Close()
+	if len(os.Args) > 1 {
+		if path, ok := lookupPlugin(os.Args[1]); ok {
+			os.Exit(runPlugin(os.Args[1], path, os.Args[2:]))
+		}
+	}
+	// Failing commands exit from their Run; errors reaching here are
+	// usage errors such as unknown flags.
 	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+		log.Close()
+		os.Exit(brew.ExitUsage)
+	}
+}
+
+// exitInterrupted is the exit status of a command stopped by Ctrl-C, as
+// shells report for SIGINT.
+const exitInterrupted = 130
+
+// interruptContext returns a context cancelled by the first Ctrl-C or
+// SIGTERM, for commands that stop cleanly: downloads keep their resume
+// metadata and temporary directories are removed before exiting. After the
+// first signal the default handling is restored, so a second one quits at
+// once. stop must be called when the command is done.
+func interruptContext(parent context.Context) (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancel(parent)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-signals:
+			signal.Stop(signals)
+			fmt.Fprintln(stderr, "\nInterrupted, stopping (press Ctrl-C again to quit now)...")
+			cancel()
+		case <-done:
+		}
+	}()
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() {
+			signal.Stop(signals)
+			close(done)
+			cancel()
+		})
+	}
+}
+
+// migrateLegacyData moves data from ~/.fastbrew, where older releases kept
+// it, to the XDG directories. It runs before the config is loaded so the
+// moved config file is the one read.
+func migrateLegacyData() {
+	moved, err := paths.Migrate()
+	for _, m := range moved {
+		fmt.Fprintf(os.Stderr, "Moved %s to %s\n", m.From, m.To)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not move all fastbrew data out of %s: %v (see fastbrew doctor)\n", paths.LegacyDir(), err)
+	}
+}
+
+// setupLogging opens the log file named by --log-file or FASTBREW_LOG_FILE.
+// Flags take precedence over the environment.
+func setupLogging() {
+	file, level := logFile, logLevel
+	if !rootCmd.PersistentFlags().Changed("log-file") {
+		if env := os.Getenv("FASTBREW_LOG_FILE"); env != "" {
+			file = env
+		}
+	}
+	if !rootCmd.PersistentFlags().Changed("log-level") {
+		if env := os.Getenv("FASTBREW_LOG_LEVEL"); env != "" {
+			level = env
+		}
+	}
+
+	parsed, err := log.ParseLevel(level)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	if err := log.Setup(log.Options{File: file, Level: parsed}); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		os.Exit(exitCode(err))
 	}
 }
 
 func init() {
-	// Flags and configuration can go here
+	cobra.OnInitialize(migrateLegacyData, applyOutputConfig, applyNetworkConfig, setupLogging)
+	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "Output structured JSON (install, upgrade, update, outdated, doctor, services, list, search)")
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Show what would change without changing anything (uninstall, cleanup, autoremove, upgrade, link, cache prune, bundle install)")
+	rootCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "Answer yes to confirmation prompts (or set the confirm config key)")
+	rootCmd.PersistentFlags().BoolVar(&noInput, "no-input", false, "Never prompt; fail when an answer is required (or set the confirm config key)")
+	rootCmd.PersistentFlags().BoolVar(&progressJSON, "progress-json", false, "Write download progress to stderr as one JSON event per line (install, upgrade, reinstall)")
+	rootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "Append a structured debug log to this file (or set FASTBREW_LOG_FILE)")
+	rootCmd.PersistentFlags().BoolVar(&noAPICache, "no-cache", false, "Fetch formula and cask metadata from the API instead of the local response cache")
+	rootCmd.PersistentFlags().BoolVar(&waitForLock, "wait", false, "Wait for another running fastbrew process instead of exiting")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Log level for --log-file: debug, info, warn or error (or set FASTBREW_LOG_LEVEL)")
 }
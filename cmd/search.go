@@ -1,33 +1,64 @@
 package cmd
 
 import (
+	"fastbrew/internal/brew"
 	"fmt"
-	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
 
 type SearchResultView struct {
-	Name   string
-	Desc   string
-	IsCask bool
+	Name      string `json:"name"`
+	Desc      string `json:"desc"`
+	IsCask    bool   `json:"is_cask"`
+	Installed bool   `json:"installed"`
+	// Aliases are other names the formula can be installed by.
+	Aliases []string `json:"aliases,omitempty"`
 }
 
+var (
+	searchFormula bool
+	searchCask    bool
+	searchDesc    bool
+	searchLimit   int
+)
+
 var searchCmd = &cobra.Command{
 	Use:   "search [query]",
 	Short: "Instant search for packages",
-	Args:  cobra.ExactArgs(1),
+	Long: `Search formulae, casks and tapped formulae by name, falling back to
+descriptions. Exact and prefix name matches are listed first, then other name
+matches, then packages whose description contains every word of the query.
+Formula aliases match like names and are shown in parentheses. Installed
+packages are marked.`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		query := args[0]
-		var results []SearchResultView
+		opts := brew.SearchOptions{
+			FormulaOnly: searchFormula,
+			CaskOnly:    searchCask,
+			DescOnly:    searchDesc,
+		}
+		// JSON output is unlimited unless a limit is asked for. One extra
+		// result is fetched to tell whether any were cut off.
+		limit := searchLimit
+		if jsonOutput && !cmd.Flags().Changed("limit") {
+			limit = 0
+		}
+		if limit > 0 {
+			opts.Limit = limit + 1
+		}
+
+		var items []brew.SearchItem
+		var installed []brew.PackageInfo
+		searched := false
 
 		if daemonClient, daemonErr := getDaemonClientForRead(); daemonClient != nil {
-			daemonResults, err := daemonClient.Search(query)
+			daemonResults, err := daemonClient.Search(query, opts)
 			if err == nil {
-				results = make([]SearchResultView, len(daemonResults))
-				for i, item := range daemonResults {
-					results[i] = SearchResultView{Name: item.Name, Desc: item.Desc, IsCask: item.IsCask}
-				}
+				items, searched = daemonResults, true
+				installed, _ = daemonClient.ListInstalled()
 			} else {
 				warnDaemonFallback("search", err)
 			}
@@ -35,50 +66,88 @@ var searchCmd = &cobra.Command{
 			warnDaemonFallback("search", daemonErr)
 		}
 
-		if results == nil {
+		if !searched {
 			client, err := newBrewClient()
 			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
+				exitWithError("Error", err)
 			}
-			localResults, searchErr := client.SearchFuzzyWithIndex(query)
+			localResults, searchErr := client.Search(query, opts)
 			if searchErr != nil {
-				fmt.Printf("Error searching: %v\n", searchErr)
-				os.Exit(1)
-			}
-			results = make([]SearchResultView, len(localResults))
-			for i, item := range localResults {
-				results[i] = SearchResultView{Name: item.Name, Desc: item.Desc, IsCask: item.IsCask}
+				exitWithError("Error searching", searchErr)
 			}
+			items = localResults
+			installed, _ = client.ListInstalledNative()
 		}
 
-		fmt.Printf("🔍 Searching for '%s'...\n", query)
+		more := limit > 0 && len(items) > limit
+		if more {
+			items = items[:limit]
+		}
+		results := searchResultViews(items, installed)
 
-		if len(results) == 0 {
-			fmt.Println("No matches found.")
+		if jsonOutput {
+			printJSON(results)
 			return
 		}
 
-		limit := 40
-		if len(results) < limit {
-			limit = len(results)
+		fmt.Fprintf(stdout, "🔍 Searching for '%s'...\n", query)
+
+		if len(results) == 0 {
+			fmt.Fprintln(stdout, "No matches found.")
+			return
 		}
 
-		for i := 0; i < limit; i++ {
-			item := results[i]
+		for _, item := range results {
 			emoji := "🍺"
 			if item.IsCask {
 				emoji = "🍷"
 			}
-			fmt.Printf("%s %s: %s\n", emoji, item.Name, item.Desc)
+			mark := ""
+			if item.Installed {
+				mark = " ✅"
+			}
+			aliases := ""
+			if len(item.Aliases) > 0 {
+				aliases = " (" + strings.Join(item.Aliases, ", ") + ")"
+			}
+			fmt.Fprintf(stdout, "%s %s%s%s: %s\n", emoji, item.Name, aliases, mark, item.Desc)
 		}
 
-		if len(results) > 40 {
-			fmt.Println("... and more results")
+		if more {
+			fmt.Fprintln(stdout, "... and more results (use --limit 0 to show all)")
 		}
 	},
 }
 
+// searchResultViews converts search items to views, marking those that are
+// installed. Tap formulae are installed under their short name.
+func searchResultViews(items []brew.SearchItem, installed []brew.PackageInfo) []SearchResultView {
+	formulae := make(map[string]bool)
+	casks := make(map[string]bool)
+	for _, pkg := range installed {
+		if pkg.IsCask {
+			casks[pkg.Name] = true
+		} else {
+			formulae[pkg.Name] = true
+		}
+	}
+
+	results := make([]SearchResultView, len(items))
+	for i, item := range items {
+		isInstalled := casks[item.Name]
+		if !item.IsCask {
+			isInstalled = formulae[item.Name[strings.LastIndex(item.Name, "/")+1:]]
+		}
+		results[i] = SearchResultView{Name: item.Name, Desc: item.Desc, IsCask: item.IsCask, Installed: isInstalled, Aliases: item.Aliases}
+	}
+	return results
+}
+
 func init() {
+	searchCmd.Flags().BoolVar(&searchFormula, "formula", false, "Only search formulae")
+	searchCmd.Flags().BoolVar(&searchCask, "cask", false, "Only search casks")
+	searchCmd.Flags().BoolVar(&searchDesc, "desc", false, "Search descriptions only")
+	searchCmd.Flags().IntVarP(&searchLimit, "limit", "n", 40, "Maximum number of results (0 for no limit)")
+	searchCmd.MarkFlagsMutuallyExclusive("formula", "cask")
 	rootCmd.AddCommand(searchCmd)
 }
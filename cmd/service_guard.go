@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fastbrew/internal/brew"
+	"fastbrew/internal/prompt"
+	"fastbrew/internal/services"
+	"fmt"
+	"slices"
+)
+
+// runningHomebrewServices returns the running services that belong to a
+// formula. Where services cannot be listed, none are reported.
+func runningHomebrewServices(mgr services.ServiceManager) []services.Service {
+	svcs, err := mgr.ListServices()
+	if err != nil {
+		return nil
+	}
+	var running []services.Service
+	for _, svc := range svcs {
+		if svc.Status == services.StatusRunning && services.FormulaName(svc.Name) != "" {
+			running = append(running, svc)
+		}
+	}
+	return running
+}
+
+// servicesBacking returns the services in svcs run by one of formulae.
+func servicesBacking(svcs []services.Service, formulae []string) []services.Service {
+	var backing []services.Service
+	for _, svc := range svcs {
+		if slices.Contains(formulae, services.FormulaName(svc.Name)) {
+			backing = append(backing, svc)
+		}
+	}
+	return backing
+}
+
+// stopServicesFor warns that svcs are running from formulae about to be
+// uninstalled or upgraded (operation) and offers to stop them first. It
+// returns the services it stopped and whether to go ahead: not when the
+// user declines or a service fails to stop. With force the services are
+// left running and the operation goes ahead.
+func stopServicesFor(operation string, svcs []services.Service, force bool, mgr services.ServiceManager, p *prompt.Prompter) ([]services.Service, bool) {
+	if len(svcs) == 0 {
+		return nil, true
+	}
+	if jsonOutput {
+		if force {
+			return nil, true
+		}
+		printJSON(ErrorView{Error: fmt.Sprintf("running services would be affected by the %s; stop them first or pass --force", operation)})
+		return nil, false
+	}
+	for _, svc := range svcs {
+		fmt.Fprintf(stdout, "⚠️  %s is running from %s\n", svc.Name, services.FormulaName(svc.Name))
+	}
+	if force {
+		fmt.Fprintln(stdout, "⚠️  Continuing with the services running (--force)")
+		return nil, true
+	}
+
+	question := fmt.Sprintf("Stop them before the %s?", operation)
+	if operation == "upgrade" {
+		question = "Stop them for the upgrade and start them again afterwards?"
+	}
+	ok, err := p.Confirm(question)
+	if err != nil {
+		fmt.Fprintf(stdout, "Error: %v\n", err)
+		return nil, false
+	}
+	if !ok {
+		fmt.Fprintln(stdout, "Cancelled.")
+		return nil, false
+	}
+
+	var stopped []services.Service
+	for _, svc := range svcs {
+		if err := mgr.Stop(svc.Name); err != nil {
+			fmt.Fprintf(stdout, "❌ Error stopping %s: %v\n", svc.Name, err)
+			startServices(stopped, mgr)
+			return nil, false
+		}
+		fmt.Fprintf(stdout, "⏹️  Stopped %s\n", svc.Name)
+		stopped = append(stopped, svc)
+	}
+	notifyDaemonInvalidation(brew.EventServiceChanged)
+	return stopped, true
+}
+
+// startServices starts services stopped by stopServicesFor again.
+func startServices(svcs []services.Service, mgr services.ServiceManager) {
+	if len(svcs) == 0 {
+		return
+	}
+	for _, svc := range svcs {
+		if err := mgr.Start(svc.Name); err != nil {
+			fmt.Fprintf(stdout, "⚠️  Failed to start %s again: %v\n", svc.Name, err)
+			continue
+		}
+		fmt.Fprintf(stdout, "▶️  Started %s\n", svc.Name)
+	}
+	notifyDaemonInvalidation(brew.EventServiceChanged)
+}
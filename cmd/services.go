@@ -1,10 +1,15 @@
 package cmd
 
 import (
+	"context"
 	"fastbrew/internal/brew"
 	"fastbrew/internal/services"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 )
@@ -14,77 +19,133 @@ var serviceScope string
 var servicesCmd = &cobra.Command{
 	Use:   "services",
 	Short: "Manage Homebrew services",
-	Long:  "Start, stop, restart, and list Homebrew-installed services",
+	Long: `Start, stop, restart, and list Homebrew-installed services.
+
+Services are managed through launchd on macOS and systemd on Linux. Without a
+subcommand the services are listed.`,
+	Run: runServicesList,
 }
 
 var servicesListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all services",
-	Run: func(cmd *cobra.Command, args []string) {
-		if daemonClient, daemonErr := getDaemonClientForRead(); daemonClient != nil {
-			svcs, err := daemonClient.ServicesList(serviceScope)
-			if err == nil {
-				printServices(svcs)
-				return
-			}
-			warnDaemonFallback("services list", err)
-		} else if daemonErr != nil {
-			warnDaemonFallback("services list", daemonErr)
-		}
+	Run:   runServicesList,
+}
 
-		mgr := getServiceManager()
-		svcs, err := mgr.ListServices()
-		if err != nil {
-			fmt.Printf("Error listing services: %v\n", err)
-			os.Exit(1)
+func runServicesList(cmd *cobra.Command, args []string) {
+	if daemonClient, daemonErr := getDaemonClientForRead(); daemonClient != nil {
+		svcs, err := daemonClient.ServicesList(serviceScope)
+		if err == nil {
+			printServices(svcs)
+			return
 		}
+		warnDaemonFallback("services list", err)
+	} else if daemonErr != nil {
+		warnDaemonFallback("services list", daemonErr)
+	}
 
-		printServices(svcs)
-	},
+	mgr := getServiceManager()
+	svcs, err := mgr.ListServices()
+	if err != nil {
+		exitWithError("Error listing services", err)
+	}
+
+	printServices(svcs)
 }
 
 var servicesStartCmd = &cobra.Command{
 	Use:   "start <service>",
 	Short: "Start a service",
-	Args:  cobra.ExactArgs(1),
+	Long: `Start a service. KEY=VALUE lines in services/<name>.env next to
+config.json, named after the service or its formula, are added to the
+service's environment each time it starts or restarts, so they survive
+upgrades that regenerate its launchd plist or systemd unit.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: singleArg(completeServices),
 	Run: func(cmd *cobra.Command, args []string) {
 		mgr := getServiceManager()
 		if err := mgr.Start(args[0]); err != nil {
-			fmt.Printf("Error starting %s: %v\n", args[0], err)
-			os.Exit(1)
+			exitWithError(fmt.Sprintf("Error starting %s", args[0]), err)
 		}
 		notifyDaemonInvalidation(brew.EventServiceChanged)
-		fmt.Printf("✅ Started %s\n", args[0])
+		if jsonOutput {
+			printJSON(ServiceActionView{Service: args[0], Action: "start", Success: true})
+			return
+		}
+		fmt.Fprintf(stdout, "✅ Started %s\n", args[0])
 	},
 }
 
 var servicesStopCmd = &cobra.Command{
-	Use:   "stop <service>",
-	Short: "Stop a service",
-	Args:  cobra.ExactArgs(1),
+	Use:               "stop <service>",
+	Short:             "Stop a service",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: singleArg(completeServices),
 	Run: func(cmd *cobra.Command, args []string) {
 		mgr := getServiceManager()
 		if err := mgr.Stop(args[0]); err != nil {
-			fmt.Printf("Error stopping %s: %v\n", args[0], err)
-			os.Exit(1)
+			exitWithError(fmt.Sprintf("Error stopping %s", args[0]), err)
 		}
 		notifyDaemonInvalidation(brew.EventServiceChanged)
-		fmt.Printf("✅ Stopped %s\n", args[0])
+		if jsonOutput {
+			printJSON(ServiceActionView{Service: args[0], Action: "stop", Success: true})
+			return
+		}
+		fmt.Fprintf(stdout, "✅ Stopped %s\n", args[0])
 	},
 }
 
 var servicesRestartCmd = &cobra.Command{
-	Use:   "restart <service>",
-	Short: "Restart a service",
-	Args:  cobra.ExactArgs(1),
+	Use:               "restart <service>",
+	Short:             "Restart a service",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: singleArg(completeServices),
 	Run: func(cmd *cobra.Command, args []string) {
 		mgr := getServiceManager()
 		if err := mgr.Restart(args[0]); err != nil {
-			fmt.Printf("Error restarting %s: %v\n", args[0], err)
-			os.Exit(1)
+			exitWithError(fmt.Sprintf("Error restarting %s", args[0]), err)
 		}
 		notifyDaemonInvalidation(brew.EventServiceChanged)
-		fmt.Printf("✅ Restarted %s\n", args[0])
+		if jsonOutput {
+			printJSON(ServiceActionView{Service: args[0], Action: "restart", Success: true})
+			return
+		}
+		fmt.Fprintf(stdout, "✅ Restarted %s\n", args[0])
+	},
+}
+
+var (
+	serviceLogLines  int
+	serviceLogFollow bool
+)
+
+var servicesLogCmd = &cobra.Command{
+	Use:   "log <service>",
+	Short: "Show a service's output",
+	Long: `Print the last lines a service wrote. On macOS these come from the files
+named by StandardOutPath and StandardErrorPath in the service's plist; on
+Linux from its user journal. With --follow new output is printed as it
+arrives until interrupted.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: singleArg(completeServices),
+	Run: func(cmd *cobra.Command, args []string) {
+		mgr := getServiceManager()
+		lines, err := mgr.Logs(args[0], serviceLogLines)
+		if err != nil {
+			exitWithError(fmt.Sprintf("Error reading logs for %s", args[0]), err)
+		}
+		for _, line := range lines {
+			fmt.Fprintln(stdout, line)
+		}
+		if !serviceLogFollow {
+			return
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err := mgr.FollowLogs(ctx, args[0], os.Stdout); err != nil {
+			exitWithError(fmt.Sprintf("Error following logs for %s", args[0]), err)
+		}
 	},
 }
 
@@ -95,38 +156,88 @@ func getServiceManager() services.ServiceManager {
 	}
 	mgr, err := services.NewServiceManagerWithScope(scope)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		exitWithError("Error", err)
 	}
 	return mgr
 }
 
 func init() {
+	servicesCmd.Flags().StringVar(&serviceScope, "scope", "", "Scope to use (user, system, all)")
 	servicesListCmd.Flags().StringVar(&serviceScope, "scope", "", "Scope to use (user, system, all)")
 	servicesStartCmd.Flags().StringVar(&serviceScope, "scope", "", "Scope to use (user, system, all)")
 	servicesStopCmd.Flags().StringVar(&serviceScope, "scope", "", "Scope to use (user, system, all)")
 	servicesRestartCmd.Flags().StringVar(&serviceScope, "scope", "", "Scope to use (user, system, all)")
+	servicesLogCmd.Flags().StringVar(&serviceScope, "scope", "", "Scope to use (user, system, all)")
+	servicesLogCmd.Flags().IntVarP(&serviceLogLines, "lines", "n", 50, "Number of lines to show")
+	servicesLogCmd.Flags().BoolVarP(&serviceLogFollow, "follow", "f", false, "Keep printing new output")
 
 	servicesCmd.AddCommand(servicesListCmd)
 	servicesCmd.AddCommand(servicesStartCmd)
 	servicesCmd.AddCommand(servicesStopCmd)
 	servicesCmd.AddCommand(servicesRestartCmd)
+	servicesCmd.AddCommand(servicesLogCmd)
 	rootCmd.AddCommand(servicesCmd)
 }
 
+type ServiceView struct {
+	Name         string `json:"name"`
+	Status       string `json:"status"`
+	Pid          int    `json:"pid,omitempty"`
+	Label        string `json:"label,omitempty"`
+	LastExitCode int    `json:"last_exit_code"`
+	Scope        string `json:"scope,omitempty"`
+	File         string `json:"file,omitempty"`
+}
+
+type ServiceActionView struct {
+	Service string `json:"service"`
+	Action  string `json:"action"`
+	Success bool   `json:"success"`
+}
+
 func printServices(svcs []services.Service) {
+	if jsonOutput {
+		views := make([]ServiceView, len(svcs))
+		for i, svc := range svcs {
+			views[i] = ServiceView{
+				Name:         svc.Name,
+				Status:       string(svc.Status),
+				Pid:          svc.Pid,
+				Label:        svc.Label,
+				LastExitCode: svc.LastExitCode,
+				Scope:        string(svc.Scope),
+				File:         svc.PlistPath,
+			}
+		}
+		printJSON(views)
+		return
+	}
+
 	if len(svcs) == 0 {
-		fmt.Println("No services found.")
+		fmt.Fprintln(stdout, "No services found.")
 		return
 	}
 
-	fmt.Printf("%-30s %-10s %-10s\n", "NAME", "STATUS", "PID")
-	fmt.Println("--------------------------------------------------")
+	writeServicesTable(os.Stdout, svcs)
+}
+
+// writeServicesTable prints one row per service. Services that exited with
+// an error show the exit code in place of a PID.
+func writeServicesTable(out io.Writer, svcs []services.Service) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSTATUS\tPID\tSCOPE\tFILE")
 	for _, svc := range svcs {
 		pid := "-"
 		if svc.Pid > 0 {
 			pid = fmt.Sprintf("%d", svc.Pid)
+		} else if svc.Status == services.StatusError && svc.LastExitCode != 0 {
+			pid = fmt.Sprintf("exit %d", svc.LastExitCode)
+		}
+		scope := string(svc.Scope)
+		if scope == "" {
+			scope = "-"
 		}
-		fmt.Printf("%-30s %-10s %-10s\n", svc.Name, svc.Status, pid)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", svc.Name, svc.Status, pid, scope, svc.PlistPath)
 	}
+	w.Flush()
 }
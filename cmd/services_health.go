@@ -0,0 +1,267 @@
+package cmd
+
+import (
+	"context"
+	"fastbrew/internal/brew"
+	"fastbrew/internal/paths"
+	"fastbrew/internal/services"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// watchdogLabel names the launchd agent and systemd units of the services
+// watchdog timer.
+const watchdogLabel = "fastbrew.services-watchdog"
+
+var watchdogInterval time.Duration
+
+// ServiceHealthView is the --json schema for services status.
+type ServiceHealthView struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// Check is empty for services without a configured health check.
+	Check   string `json:"check,omitempty"`
+	Healthy *bool  `json:"healthy,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Restart bool   `json:"restart,omitempty"`
+}
+
+// healthConfigPath returns services.yaml, next to config.json.
+func healthConfigPath() string {
+	return filepath.Join(paths.ConfigDir(), "services.yaml")
+}
+
+var servicesStatusCmd = &cobra.Command{
+	Use:   "status [service...]",
+	Short: "Run the services' health checks",
+	Long: `List services with the result of their health checks, configured per
+service in services.yaml next to config.json:
+
+  postgresql@14:        # formula or service name
+    tcp: localhost:5432 # or http: <url>, or command: <shell command>
+    timeout: 5s
+    restart: true       # let the watchdog restart it when unhealthy
+
+A tcp check passes when the port accepts a connection, an http check when
+the URL answers below 400, a command check when it exits with status 0.
+Checks of services that are not running are skipped. The exit status is 1
+when a check fails.`,
+	ValidArgsFunction: completeServices,
+	Run: func(cmd *cobra.Command, args []string) {
+		policies, err := services.LoadHealthPolicies(healthConfigPath())
+		if err != nil {
+			exitWithError("Error reading health checks", err)
+		}
+		mgr := getServiceManager()
+		svcs, err := mgr.ListServices()
+		if err != nil {
+			exitWithError("Error listing services", err)
+		}
+		if len(args) > 0 {
+			svcs = filterServices(svcs, args)
+		}
+
+		views := checkServiceHealth(cmd.Context(), svcs, policies)
+		if jsonOutput {
+			printJSON(views)
+		} else if len(views) == 0 {
+			fmt.Fprintln(stdout, "No services found.")
+		} else {
+			writeHealthTable(stdout, views)
+		}
+		for _, view := range views {
+			if view.Healthy != nil && !*view.Healthy {
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+// filterServices keeps the services named by names, by service or formula
+// name.
+func filterServices(svcs []services.Service, names []string) []services.Service {
+	var kept []services.Service
+	for _, svc := range svcs {
+		for _, name := range names {
+			if svc.Name == name || services.FormulaName(svc.Name) == name {
+				kept = append(kept, svc)
+				break
+			}
+		}
+	}
+	return kept
+}
+
+// checkServiceHealth runs the health check of each running service in svcs
+// that has one, in parallel.
+func checkServiceHealth(ctx context.Context, svcs []services.Service, policies []services.HealthPolicy) []ServiceHealthView {
+	views := make([]ServiceHealthView, len(svcs))
+	var wg sync.WaitGroup
+	for i, svc := range svcs {
+		views[i] = ServiceHealthView{Name: svc.Name, Status: string(svc.Status)}
+		policy, ok := healthPolicyFor(svc.Name, policies)
+		if !ok {
+			continue
+		}
+		views[i].Check, views[i].Restart = policy.Check.String(), policy.Restart
+		if svc.Status != services.StatusRunning {
+			continue
+		}
+		wg.Add(1)
+		go func(view *ServiceHealthView) {
+			defer wg.Done()
+			err := policy.Run(ctx)
+			healthy := err == nil
+			view.Healthy = &healthy
+			if err != nil {
+				view.Error = err.Error()
+			}
+		}(&views[i])
+	}
+	wg.Wait()
+	return views
+}
+
+func healthPolicyFor(name string, policies []services.HealthPolicy) (services.HealthPolicy, bool) {
+	for _, p := range policies {
+		if p.Matches(name) {
+			return p, true
+		}
+	}
+	return services.HealthPolicy{}, false
+}
+
+// writeHealthTable prints one row per service; "-" marks services without
+// a check or not running.
+func writeHealthTable(out io.Writer, views []ServiceHealthView) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSTATUS\tHEALTH\tCHECK")
+	for _, view := range views {
+		health := "-"
+		if view.Healthy != nil {
+			health = "healthy"
+			if !*view.Healthy {
+				health = "unhealthy: " + view.Error
+			}
+		}
+		check := view.Check
+		if check == "" {
+			check = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", view.Name, view.Status, health, check)
+	}
+	w.Flush()
+}
+
+var servicesWatchdogCmd = &cobra.Command{
+	Use:   "watchdog",
+	Short: "Restart unhealthy services on a timer",
+	Long: `Run the health checks of services.yaml periodically as a launchd agent
+(macOS) or a systemd user timer (Linux), restarting running services whose
+check fails and whose policy sets restart: true. See 'fastbrew services
+status --help' for the file's format.`,
+}
+
+var servicesWatchdogEnableCmd = &cobra.Command{
+	Use:   "enable",
+	Short: "Install the watchdog timer",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if watchdogInterval < time.Minute {
+			exitWithError("Error", fmt.Errorf("--interval must be at least 1m"))
+		}
+		if _, err := services.LoadHealthPolicies(healthConfigPath()); err != nil {
+			exitWithError("Error reading health checks", err)
+		}
+		timer := services.Timer{
+			Label:       watchdogLabel,
+			Description: "fastbrew services watchdog",
+			Command:     []string{fastbrewExecutable(), "services", "watchdog", "run"},
+			Interval:    watchdogInterval,
+			LogPath:     filepath.Join(paths.StateDir(), "services-watchdog.log"),
+		}
+		if err := services.InstallTimer(timer); err != nil {
+			exitWithError("Error enabling the watchdog", err)
+		}
+		fmt.Fprintf(stdout, "✅ Watchdog enabled: health checks run every %s\n", watchdogInterval)
+		fmt.Fprintf(stdout, "   %s\n", services.TimerPath(watchdogLabel))
+	},
+}
+
+var servicesWatchdogDisableCmd = &cobra.Command{
+	Use:   "disable",
+	Short: "Remove the watchdog timer",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := services.RemoveTimer(watchdogLabel); err != nil {
+			exitWithError("Error disabling the watchdog", err)
+		}
+		fmt.Fprintln(stdout, "✅ Watchdog disabled")
+	},
+}
+
+var servicesWatchdogRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Run the health checks once and restart unhealthy services",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		policies, err := services.LoadHealthPolicies(healthConfigPath())
+		if err != nil {
+			exitWithError("Error reading health checks", err)
+		}
+		if len(policies) == 0 {
+			return
+		}
+		mgr := getServiceManager()
+		svcs, err := mgr.ListServices()
+		if err != nil {
+			exitWithError("Error listing services", err)
+		}
+		if restartUnhealthy(stdout, mgr, checkServiceHealth(cmd.Context(), svcs, policies)) > 0 {
+			notifyDaemonInvalidation(brew.EventServiceChanged)
+		}
+	},
+}
+
+// restartUnhealthy restarts the services in views whose check failed and
+// whose policy allows it, logging each to out, and returns how many it
+// restarted.
+func restartUnhealthy(out io.Writer, mgr services.ServiceManager, views []ServiceHealthView) int {
+	restarted := 0
+	stamp := time.Now().Format("2006-01-02 15:04:05")
+	for _, view := range views {
+		if view.Healthy == nil || *view.Healthy {
+			continue
+		}
+		if !view.Restart {
+			fmt.Fprintf(out, "%s %s is unhealthy (%s); restart is not enabled\n", stamp, view.Name, view.Error)
+			continue
+		}
+		if err := mgr.Restart(view.Name); err != nil {
+			fmt.Fprintf(out, "%s %s is unhealthy (%s); restart failed: %v\n", stamp, view.Name, view.Error, err)
+			continue
+		}
+		fmt.Fprintf(out, "%s %s is unhealthy (%s); restarted\n", stamp, view.Name, view.Error)
+		restarted++
+	}
+	return restarted
+}
+
+func init() {
+	servicesStatusCmd.Flags().StringVar(&serviceScope, "scope", "", "Scope to use (user, system, all)")
+	servicesWatchdogRunCmd.Flags().StringVar(&serviceScope, "scope", "", "Scope to use (user, system, all)")
+	servicesWatchdogEnableCmd.Flags().DurationVar(&watchdogInterval, "interval", 5*time.Minute, "How often to run the health checks")
+
+	servicesWatchdogCmd.AddCommand(servicesWatchdogEnableCmd)
+	servicesWatchdogCmd.AddCommand(servicesWatchdogDisableCmd)
+	servicesWatchdogCmd.AddCommand(servicesWatchdogRunCmd)
+	servicesCmd.AddCommand(servicesStatusCmd)
+	servicesCmd.AddCommand(servicesWatchdogCmd)
+}
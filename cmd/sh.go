@@ -1,9 +1,8 @@
 package cmd
 
 import (
+	"fastbrew/internal/brew"
 	"fmt"
-	"os"
-	"path/filepath"
 
 	"github.com/spf13/cobra"
 )
@@ -11,45 +10,20 @@ import (
 var shAuto bool
 
 var shCmd = &cobra.Command{
-	Use:   "sh",
-	Short: "Print shell environment configuration",
-	Long:  `Print the shell commands required to set up Homebrew in your shell environment.`,
+	Use:        "sh",
+	Short:      "Print shell environment configuration",
+	Long:       `Print the shell commands required to set up Homebrew in your shell environment.`,
+	Deprecated: "use 'fastbrew shellenv' instead",
 	Run: func(cmd *cobra.Command, args []string) {
-		shell := os.Getenv("SHELL")
-		if shAuto && shell != "" {
-			shell = filepath.Base(shell)
+		shell := "sh"
+		if shAuto {
+			shell = brew.DetectShell()
 		}
-
-		if shell == "" {
-			shell = "bash"
-		}
-
-		prefix := os.Getenv("HOMEBREW_PREFIX")
-		if prefix == "" {
-			if _, err := os.Stat("/opt/homebrew"); err == nil {
-				prefix = "/opt/homebrew"
-			} else if _, err := os.Stat("/usr/local"); err == nil {
-				prefix = "/usr/local"
-			} else if _, err := os.Stat("/home/linuxbrew/.linuxbrew"); err == nil {
-				prefix = "/home/linuxbrew/.linuxbrew"
-			}
-		}
-
-		if prefix == "" {
-			fmt.Fprintln(os.Stderr, "Error: Could not determine Homebrew prefix. Set HOMEBREW_PREFIX environment variable")
-			os.Exit(1)
-		}
-
-		binPath := filepath.Join(prefix, "bin")
-		manPath := filepath.Join(prefix, "share/man")
-
-		if shell == "fish" {
-			fmt.Printf("set -gx PATH %s $PATH\n", binPath)
-			fmt.Printf("set -gx MANPATH %s $MANPATH\n", manPath)
-		} else {
-			fmt.Printf("export PATH=\"%s:$PATH\"\n", binPath)
-			fmt.Printf("export MANPATH=\"%s:$MANPATH\"\n", manPath)
+		client, err := newBrewClient()
+		if err != nil {
+			exitWithError("Error", err)
 		}
+		fmt.Fprint(stdout, brew.ShellEnv(client.Prefix, shell))
 	},
 }
 
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"fastbrew/internal/brew"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var shellenvCmd = &cobra.Command{
+	Use:   "shellenv [bash|zsh|fish|sh]",
+	Short: "Print the environment setup for the Homebrew prefix",
+	Long: `Print the commands that add the Homebrew prefix's bin and sbin to PATH, and
+its man and info pages to MANPATH and INFOPATH. The shell is taken from
+$SHELL unless named.
+
+Add them to your shell's startup file, or let 'fastbrew doctor --fix' do it:
+
+  $ fastbrew shellenv >> ~/.zshrc
+  $ fastbrew shellenv fish >> ~/.config/fish/config.fish`,
+	Args:      cobra.MaximumNArgs(1),
+	ValidArgs: []string{"bash", "zsh", "fish", "sh"},
+	Run: func(cmd *cobra.Command, args []string) {
+		shell := brew.DetectShell()
+		if len(args) > 0 {
+			shell = args[0]
+		}
+		client, err := newBrewClient()
+		if err != nil {
+			exitWithError("Error", err)
+		}
+		fmt.Fprint(stdout, brew.ShellEnv(client.Prefix, shell))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(shellenvCmd)
+}
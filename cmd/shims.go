@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fastbrew/internal/brew"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var shimsCmd = &cobra.Command{
+	Use:   "shims",
+	Short: "List the wrapper scripts in the prefix's shims directory",
+	Long: `List the shims fastbrew manages in <prefix>/shims: small wrapper scripts that
+run a keg's binary with the keg's bin first in PATH. They suit keg-only
+tools that are not linked, and binaries wanted under another name, such as
+python3.12 as python.
+
+For shims to take effect, <prefix>/shims must come before <prefix>/bin in
+PATH; fastbrew doctor checks that every shim resolves.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newBrewClient()
+		if err != nil {
+			exitWithError("Error initializing brew client", err)
+		}
+		shims, err := client.Shims()
+		if err != nil {
+			exitWithError("Error reading shims", err)
+		}
+
+		if jsonOutput {
+			if shims == nil {
+				shims = []brew.Shim{}
+			}
+			printJSON(shims)
+			return
+		}
+		if len(shims) == 0 {
+			fmt.Fprintln(stdout, "No shims. Add one with: fastbrew shims add <formula> [binary[=name]...]")
+			return
+		}
+		writeShimsTable(stdout, shims)
+	},
+}
+
+// writeShimsTable prints one row per shim with its package and target.
+func writeShimsTable(out io.Writer, shims []brew.Shim) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SHIM\tFORMULA\tTARGET\tSTATUS")
+	for _, shim := range shims {
+		status := "ok"
+		if shim.Broken() {
+			status = "broken"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", shim.Name, shim.Package, shim.Target, status)
+	}
+	w.Flush()
+}
+
+var shimsAddCmd = &cobra.Command{
+	Use:   "add <formula> [binary[=name]...]",
+	Short: "Add shims for a formula's binaries",
+	Long: `Write shims for the named binaries of an installed formula, or for every
+executable in its bin directory when none are named. binary=name installs
+the shim under another name:
+
+  $ fastbrew shims add python@3.12 python3.12=python
+
+Shims run the binary through opt/<formula>, so they keep working after
+upgrades. An existing shim of the same name is replaced.`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeInstalledFormulae,
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newBrewClient()
+		if err != nil {
+			exitWithError("Error initializing brew client", err)
+		}
+		shims, err := client.CreateShims(args[0], args[1:])
+		for _, shim := range shims {
+			fmt.Fprintf(stdout, "✅ %s -> %s\n", shim.Path, shim.Target)
+		}
+		if err != nil {
+			exitWithError("Error", err)
+		}
+	},
+}
+
+var shimsRemoveCmd = &cobra.Command{
+	Use:   "remove <shim|formula>...",
+	Short: "Remove shims by name, or all shims of a formula",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newBrewClient()
+		if err != nil {
+			exitWithError("Error initializing brew client", err)
+		}
+		removed, err := client.RemoveShims(args...)
+		for _, shim := range removed {
+			fmt.Fprintf(stdout, "🗑️  Removed %s\n", shim.Path)
+		}
+		if err != nil {
+			exitWithError("Error", err)
+		}
+		if len(removed) == 0 {
+			fmt.Fprintln(stdout, "No matching shims.")
+		}
+	},
+}
+
+func init() {
+	shimsCmd.AddCommand(shimsAddCmd)
+	shimsCmd.AddCommand(shimsRemoveCmd)
+	rootCmd.AddCommand(shimsCmd)
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var switchCmd = &cobra.Command{
+	Use:   "switch <formula> <version>",
+	Short: "Link another installed version of a formula",
+	Long: `Make an installed version of a formula the active one: opt/<formula> and
+the links in the prefix are pointed at that version's keg in the Cellar.
+
+Only versions already in the Cellar can be switched to; 'fastbrew list
+--versions' shows them, with the active one starred. The newest version
+stays installed and can be switched back to the same way. Pin the formula
+to keep an upgrade from relinking the newest version.`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeSwitch,
+	Run: func(cmd *cobra.Command, args []string) {
+		name, version := args[0], args[1]
+
+		defer lockFastbrew()()
+
+		client, err := newBrewClient()
+		if err != nil {
+			exitWithError("Error initializing brew client", err)
+		}
+		result, err := client.Switch(name, version)
+		if err != nil {
+			exitWithError("Error switching "+name, err)
+		}
+		for _, linkErr := range result.Errors {
+			fmt.Fprintf(stderr, "Warning: %v\n", linkErr)
+		}
+		fmt.Fprintf(stdout, "✅ %s %s is now active (%d binary(ies) linked)\n", name, version, len(result.Binaries))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(switchCmd)
+}
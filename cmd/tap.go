@@ -12,22 +12,29 @@ import (
 var tapFull bool
 
 var tapCmd = &cobra.Command{
-	Use:   "tap [user/repo]",
+	Use:   "tap [user/repo] [url]",
 	Short: "Manage Homebrew taps",
 	Long: `Tap management commands for Homebrew.
 With no arguments, lists all taps.
-With a repo argument, adds the tap.`,
+With a repo argument, adds the tap. An optional URL clones it from another
+git host, e.g. GitHub Enterprise; private hosts use the credentials from
+'fastbrew config set credentials.<host>', the environment or git.`,
+	Args: cobra.MaximumNArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
 		tapManager, err := newTapManager()
 		if err != nil {
-			fmt.Printf("Error initializing tap manager: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Error initializing tap manager: %v\n", err)
+			os.Exit(exitCode(err))
 		}
 
 		if len(args) == 0 {
 			listTaps(tapManager)
 		} else {
-			addTap(tapManager, args[0], tapFull)
+			remoteURL := ""
+			if len(args) == 2 {
+				remoteURL = args[1]
+			}
+			addTap(tapManager, args[0], remoteURL, tapFull)
 		}
 	},
 }
@@ -40,8 +47,8 @@ var untapCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		tapManager, err := newTapManager()
 		if err != nil {
-			fmt.Printf("Error initializing tap manager: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Error initializing tap manager: %v\n", err)
+			os.Exit(exitCode(err))
 		}
 
 		force, _ := cmd.Flags().GetBool("force")
@@ -69,8 +76,8 @@ var tapInfoCmd = &cobra.Command{
 
 		tapManager, err := newTapManager()
 		if err != nil {
-			fmt.Printf("Error initializing tap manager: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Error initializing tap manager: %v\n", err)
+			os.Exit(exitCode(err))
 		}
 
 		showTapInfo(tapManager, args[0], installedOnly)
@@ -90,60 +97,66 @@ func init() {
 func listTaps(tm *brew.TapManager) {
 	taps, err := tm.ListTaps()
 	if err != nil {
-		fmt.Printf("Error listing taps: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(stdout, "Error listing taps: %v\n", err)
+		os.Exit(exitCode(err))
 	}
 
 	if len(taps) == 0 {
-		fmt.Println("No taps installed.")
-		fmt.Println("Use 'fastbrew tap user/repo' to add a tap.")
+		fmt.Fprintln(stdout, "No taps installed.")
+		fmt.Fprintln(stdout, "Use 'fastbrew tap user/repo' to add a tap.")
 		return
 	}
 
-	fmt.Printf("Installed taps (%d):\n\n", len(taps))
+	fmt.Fprintf(stdout, "Installed taps (%d):\n\n", len(taps))
 
 	for _, tap := range taps {
-		fmt.Printf("📦 %s\n", tap.Name)
+		fmt.Fprintf(stdout, "📦 %s\n", tap.Name)
 		if tap.RemoteURL != "" {
-			fmt.Printf("   Remote: %s\n", tap.RemoteURL)
+			fmt.Fprintf(stdout, "   Remote: %s\n", tap.RemoteURL)
 		}
 		if tap.IsCustom {
-			fmt.Printf("   Type: Custom tap\n")
+			fmt.Fprintf(stdout, "   Type: Custom tap\n")
 		}
-		fmt.Println()
+		fmt.Fprintln(stdout)
 	}
 }
 
-func addTap(tm *brew.TapManager, repo string, full bool) {
+func addTap(tm *brew.TapManager, repo, remoteURL string, full bool) {
 	repo = normalizeTapRepo(repo)
 
-	fmt.Printf("📦 Tapping %s...\n", repo)
+	fmt.Fprintf(stdout, "📦 Tapping %s...\n", repo)
 	if full {
-		fmt.Println("   (Full clone mode)")
+		fmt.Fprintln(stdout, "   (Full clone mode)")
 	}
 
-	if err := tm.Tap(repo, full); err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+	var err error
+	if remoteURL != "" {
+		err = tm.TapWithRemote(repo, remoteURL, full)
+	} else {
+		err = tm.Tap(repo, full)
+	}
+	if err != nil {
+		fmt.Fprintf(stdout, "Error: %v\n", err)
+		os.Exit(exitCode(err))
 	}
 
-	fmt.Printf("✅ Successfully tapped %s\n", repo)
+	fmt.Fprintf(stdout, "✅ Successfully tapped %s\n", repo)
 }
 
 func removeTap(tm *brew.TapManager, repo string, force bool) {
 	repo = normalizeTapRepo(repo)
 
-	fmt.Printf("📦 Untapping %s...\n", repo)
+	fmt.Fprintf(stdout, "📦 Untapping %s...\n", repo)
 	if force {
-		fmt.Println("   (Force mode: ignoring installed formulae)")
+		fmt.Fprintln(stdout, "   (Force mode: ignoring installed formulae)")
 	}
 
 	if err := tm.Untap(repo, force); err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(stdout, "Error: %v\n", err)
+		os.Exit(exitCode(err))
 	}
 
-	fmt.Printf("✅ Successfully untapped %s\n", repo)
+	fmt.Fprintf(stdout, "✅ Successfully untapped %s\n", repo)
 }
 
 func showTapInfo(tm *brew.TapManager, repo string, installedOnly bool) {
@@ -151,66 +164,66 @@ func showTapInfo(tm *brew.TapManager, repo string, installedOnly bool) {
 
 	info, err := tm.GetTapInfo(repo, installedOnly)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(stdout, "Error: %v\n", err)
+		os.Exit(exitCode(err))
 	}
 
 	printTapInfo(info, installedOnly)
 }
 
 func printTapInfo(info *brew.TapInfo, installedOnly bool) {
-	fmt.Printf("📦 %s\n", info.Tap.Name)
-	fmt.Println(strings.Repeat("=", 40))
+	fmt.Fprintf(stdout, "📦 %s\n", info.Tap.Name)
+	fmt.Fprintln(stdout, strings.Repeat("=", 40))
 
 	if info.Tap.RemoteURL != "" {
-		fmt.Printf("Remote URL: %s\n", info.Tap.RemoteURL)
+		fmt.Fprintf(stdout, "Remote URL: %s\n", info.Tap.RemoteURL)
 	}
 	if info.Tap.LocalPath != "" {
-		fmt.Printf("Local Path: %s\n", info.Tap.LocalPath)
+		fmt.Fprintf(stdout, "Local Path: %s\n", info.Tap.LocalPath)
 	}
-	fmt.Printf("Installed: %s\n", info.Tap.InstalledAt.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(stdout, "Installed: %s\n", info.Tap.InstalledAt.Format("2006-01-02 15:04:05"))
 	if info.Tap.IsCustom {
-		fmt.Println("Type: Custom tap")
+		fmt.Fprintln(stdout, "Type: Custom tap")
 	}
 
-	fmt.Println()
+	fmt.Fprintln(stdout)
 
 	if installedOnly {
-		fmt.Printf("📋 Installed Formulae (%d):\n", len(info.Installed))
+		fmt.Fprintf(stdout, "📋 Installed Formulae (%d):\n", len(info.Installed))
 		if len(info.Installed) == 0 {
-			fmt.Println("   No formulae from this tap are currently installed.")
+			fmt.Fprintln(stdout, "   No formulae from this tap are currently installed.")
 		} else {
 			for _, formula := range info.Installed {
-				fmt.Printf("   • %s\n", formula)
+				fmt.Fprintf(stdout, "   • %s\n", formula)
 			}
 		}
 	} else {
-		fmt.Printf("📋 Formulae (%d):\n", len(info.Formulae))
+		fmt.Fprintf(stdout, "📋 Formulae (%d):\n", len(info.Formulae))
 		if len(info.Formulae) == 0 {
-			fmt.Println("   No formulae in this tap.")
+			fmt.Fprintln(stdout, "   No formulae in this tap.")
 		} else if len(info.Formulae) <= 20 {
 			for _, formula := range info.Formulae {
-				fmt.Printf("   • %s\n", formula)
+				fmt.Fprintf(stdout, "   • %s\n", formula)
 			}
 		} else {
 			for _, formula := range info.Formulae[:20] {
-				fmt.Printf("   • %s\n", formula)
+				fmt.Fprintf(stdout, "   • %s\n", formula)
 			}
-			fmt.Printf("   ... and %d more\n", len(info.Formulae)-20)
+			fmt.Fprintf(stdout, "   ... and %d more\n", len(info.Formulae)-20)
 		}
 
 		if len(info.Casks) > 0 {
-			fmt.Println()
-			fmt.Printf("🍷 Casks (%d):\n", len(info.Casks))
+			fmt.Fprintln(stdout)
+			fmt.Fprintf(stdout, "🍷 Casks (%d):\n", len(info.Casks))
 			if len(info.Casks) <= 10 {
 				for _, cask := range info.Casks {
-					fmt.Printf("   • %s\n", cask)
+					fmt.Fprintf(stdout, "   • %s\n", cask)
 				}
 			} else {
 				for _, cask := range info.Casks[:10] {
-					fmt.Printf("   • %s\n", cask)
+					fmt.Fprintf(stdout, "   • %s\n", cask)
 				}
-				fmt.Printf("   ... and %d more\n", len(info.Casks)-10)
+				fmt.Fprintf(stdout, "   ... and %d more\n", len(info.Casks)-10)
 			}
 		}
 	}
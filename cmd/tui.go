@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"fastbrew/internal/tui"
+
+	"github.com/spf13/cobra"
+)
+
+var tuiCmd = &cobra.Command{
+	Use:   "tui",
+	Short: "Open the interactive package browser",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := tui.Start(); err != nil {
+			exitWithError("Error running TUI", err)
+		}
+	},
+}
+
+var tuiServicesCmd = &cobra.Command{
+	Use:   "services",
+	Short: "Manage services interactively",
+	Long: `List services with their live status, start, stop and restart them, and
+follow the selected service's output (its StandardOutPath on macOS, its
+journal on Linux) in a side pane.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := tui.StartServices(getServiceManager()); err != nil {
+			exitWithError("Error running TUI", err)
+		}
+	},
+}
+
+func init() {
+	tuiServicesCmd.Flags().StringVar(&serviceScope, "scope", "", "Scope to use (user, system, all)")
+
+	tuiCmd.AddCommand(tuiServicesCmd)
+	rootCmd.AddCommand(tuiCmd)
+}
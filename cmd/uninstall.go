@@ -3,63 +3,224 @@ package cmd
 import (
 	"fastbrew/internal/brew"
 	"fastbrew/internal/daemon"
+	"fastbrew/internal/progress"
+	"fastbrew/internal/prompt"
+	"fastbrew/internal/services"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
 
+var (
+	uninstallZap   bool
+	uninstallForce bool
+)
+
 var uninstallCmd = &cobra.Command{
 	Use:   "uninstall [package...]",
 	Short: "Uninstall packages (native fast removal)",
-	Args:  cobra.MinimumNArgs(1),
+	Long: `Uninstall formulae and casks.
+
+--zap also removes what a cask leaves behind outside the Caskroom: the
+application support files, preferences and caches its zap stanza names.
+The paths are listed and removed only after confirmation, or right away
+with --force. Casks no longer installed can be zapped too. Paths as broad
+as ~/Library itself are never removed, and zap directives other than
+trash and rmdir (launchctl, pkgutil, ...) are listed but not carried out.
+
+Uninstalling a formula whose service is running (e.g. redis) names the
+service and offers to stop it first; declining cancels the uninstall.
+--force uninstalls without asking and leaves the service running.
+
+--dry-run lists the kegs and prefix symlinks each formula would remove, and
+the files --zap would remove, without changing anything.`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeInstalledPackages,
 	Run: func(cmd *cobra.Command, args []string) {
-		if ran, err := tryRunMutationJob("uninstall", daemon.JobOperationUninstall, args, daemon.JobSubmitOptions{}); ran {
-			if err != nil {
-				fmt.Printf("Error: %v\n", err)
+		in := newPrompter()
+		if !dryRun {
+			svcMgr := services.NewServiceManager()
+			if _, ok := stopServicesFor("uninstall", servicesBacking(runningHomebrewServices(svcMgr), args), uninstallForce, svcMgr, in); !ok {
 				os.Exit(1)
 			}
-			return
+		}
+
+		// The daemon does not zap or dry-run, so those always run here.
+		if !uninstallZap && !dryRun {
+			if ran, err := tryRunMutationJob("uninstall", daemon.JobOperationUninstall, args, daemon.JobSubmitOptions{}, nil); ran {
+				if err != nil {
+					fmt.Fprintf(stdout, "Error: %v\n", err)
+					os.Exit(exitCode(err))
+				}
+				return
+			}
+		}
+
+		if !dryRun {
+			defer lockFastbrew()()
 		}
 
 		client, err := newBrewClient()
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stdout, "Error: %v\n", err)
+			os.Exit(exitCode(err))
 		}
 
-		removedAny := false
+		var removed []string
+		var errs []error
 		for _, pkg := range args {
-			pkgPath := filepath.Join(client.Cellar, pkg)
-
-			if _, err := os.Stat(pkgPath); os.IsNotExist(err) {
-				fmt.Printf("⚠️  %s is not installed\n", pkg)
+			if _, err := os.Stat(filepath.Join(client.Cellar, pkg)); os.IsNotExist(err) {
+				if isCask, _ := client.IsCask(pkg); isCask {
+					ok, err := uninstallCask(client, pkg, in)
+					if ok {
+						removed = append(removed, pkg)
+					}
+					if err != nil {
+						errs = append(errs, err)
+					}
+					continue
+				}
+				fmt.Fprintf(stdout, "⚠️  %s is not installed\n", pkg)
+				errs = append(errs, notInstalledError(pkg))
 				continue
 			}
-
-			client.Unlink(pkg)
-
-			optLink := filepath.Join(client.Prefix, "opt", pkg)
-			if info, err := os.Lstat(optLink); err == nil && info.Mode()&os.ModeSymlink != 0 {
-				os.Remove(optLink)
+			if uninstallZap {
+				fmt.Fprintf(stdout, "⚠️  --zap only applies to casks; uninstalling %s normally\n", pkg)
 			}
 
-			if err := os.RemoveAll(pkgPath); err != nil {
-				fmt.Printf("❌ Error removing %s: %v\n", pkg, err)
+			result, err := client.UninstallFormula(pkg, brew.UninstallOptions{DryRun: dryRun})
+			if err != nil {
+				fmt.Fprintf(stdout, "❌ Not uninstalling %s: %v\n", pkg, err)
+				errs = append(errs, err)
 				continue
 			}
-
-			fmt.Printf("✅ Uninstalled %s\n", pkg)
-			removedAny = true
+			printUninstallResult(result)
+			removed = append(removed, pkg)
 		}
 
-		if removedAny {
+		if dryRun {
+			fmt.Fprintln(stdout, "\n💡 Dry run - nothing was uninstalled.")
+			exitForFailures(errs, len(args))
+			return
+		}
+		if len(removed) > 0 {
 			notifyDaemonInvalidation(brew.EventInstalledChanged)
 		}
+		exitForFailures(errs, len(args))
 	},
 }
 
+// notInstalledError is the failure for uninstalling name when it is not
+// installed.
+func notInstalledError(name string) error {
+	return &brew.Error{Kind: brew.KindNotFound, Err: fmt.Errorf("%s is not installed", name)}
+}
+
+// printUninstallResult reports a formula removed by UninstallFormula or, in
+// a dry run, the kegs and links it would remove.
+func printUninstallResult(result *brew.UninstallResult) {
+	if !dryRun {
+		fmt.Fprintf(stdout, "✅ Uninstalled %s\n", result.Package)
+		return
+	}
+	fmt.Fprintf(stdout, "🗑️  Would uninstall %s (%s):\n", result.Package, progress.FormatBytes(result.Bytes))
+	for _, keg := range result.Kegs {
+		fmt.Fprintf(stdout, "  • %s\n", keg)
+	}
+	for _, link := range result.Links {
+		fmt.Fprintf(stdout, "  🔗 %s\n", link)
+	}
+}
+
+// uninstallCask removes the cask name and, with --zap, the files its zap
+// stanza names once confirmed. It reports whether the cask was installed
+// and removed. With --dry-run it only lists what would go.
+func uninstallCask(client *brew.Client, name string, in *prompt.Prompter) (bool, error) {
+	installer := brew.NewCaskInstaller(client)
+	installer.SetOperation(brew.MutationOperationUninstall)
+	installed, _, _ := installer.IsInstalled(name)
+
+	var plan *brew.ZapPlan
+	if uninstallZap {
+		p, err := installer.PlanZap(name)
+		if err != nil {
+			fmt.Fprintf(stdout, "❌ Not zapping %s: %v\n", name, err)
+			return false, err
+		}
+		if !confirmZap(p, uninstallForce || dryRun, in) {
+			fmt.Fprintln(stdout, "Cancelled.")
+			return false, nil
+		}
+		plan = p
+	}
+
+	if dryRun {
+		if installed {
+			fmt.Fprintf(stdout, "🗑️  Would uninstall cask %s\n", name)
+		} else if plan == nil {
+			fmt.Fprintf(stdout, "⚠️  %s is not installed\n", name)
+			return false, notInstalledError(name)
+		}
+		return installed, nil
+	}
+
+	if installed {
+		if err := installer.Uninstall(name); err != nil {
+			fmt.Fprintf(stdout, "❌ Error uninstalling %s: %v\n", name, err)
+			return false, err
+		}
+	} else if plan == nil {
+		fmt.Fprintf(stdout, "⚠️  %s is not installed\n", name)
+		return false, notInstalledError(name)
+	}
+
+	if plan != nil && !plan.Empty() {
+		if err := installer.Zap(plan); err != nil {
+			fmt.Fprintf(stdout, "⚠️  %v\n", err)
+		} else {
+			fmt.Fprintf(stdout, "✅ Zapped %s\n", name)
+		}
+	}
+	return installed, nil
+}
+
+// confirmZap lists what plan removes and asks before going ahead, unless
+// force is set or there is nothing to remove.
+func confirmZap(plan *brew.ZapPlan, force bool, in *prompt.Prompter) bool {
+	for _, path := range plan.Refused {
+		fmt.Fprintf(stdout, "  ⏭️  Leaving %s (too broad to remove)\n", path)
+	}
+	if len(plan.Unsupported) > 0 {
+		fmt.Fprintf(stdout, "  ⏭️  Not carried out: %s\n", strings.Join(plan.Unsupported, ", "))
+	}
+	if plan.Empty() {
+		fmt.Fprintf(stdout, "🧹 No leftover files found for %s.\n", plan.Token)
+		return true
+	}
+
+	fmt.Fprintf(stdout, "🧹 Zapping %s removes:\n", plan.Token)
+	for _, path := range plan.Trash {
+		fmt.Fprintf(stdout, "  • %s\n", path)
+	}
+	for _, path := range plan.Rmdir {
+		fmt.Fprintf(stdout, "  • %s (if empty)\n", path)
+	}
+	if force {
+		return true
+	}
+
+	ok, err := in.Confirm(fmt.Sprintf("Remove %d path(s)?", len(plan.Trash)+len(plan.Rmdir)))
+	if err != nil {
+		fmt.Fprintf(stdout, "Error: %v\n", err)
+	}
+	return ok
+}
+
 func init() {
+	uninstallCmd.Flags().BoolVar(&uninstallZap, "zap", false, "Also remove a cask's application support files, preferences and caches")
+	uninstallCmd.Flags().BoolVar(&uninstallForce, "force", false, "Zap, and uninstall formulae of running services, without asking")
 	rootCmd.AddCommand(uninstallCmd)
 }
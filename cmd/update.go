@@ -1,36 +1,218 @@
 package cmd
 
 import (
+	"fastbrew/internal/brew"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
+var (
+	updateForce          bool
+	updateRecordOutdated bool
+	updateVerbose        bool
+	updateRebuildIndexes bool
+	updateRollback       bool
+	updateNotify         bool
+)
+
+// UpdateView is the --json schema for update.
+type UpdateView struct {
+	Changed bool     `json:"changed"`
+	Added   []string `json:"added"`
+	Updated []string `json:"updated"`
+	Removed []string `json:"removed"`
+	// Outdated is the number of outdated packages, with --record-outdated.
+	Outdated *int `json:"outdated,omitempty"`
+}
+
 var updateCmd = &cobra.Command{
 	Use:   "update",
 	Short: "Update Homebrew and FastBrew index in parallel",
+	Long: `Check the formula and cask indexes for changes. The server is asked
+whether each index changed since the last update, so an unchanged index is
+not downloaded again. Pass --force to download both in full.
+
+When the formula index changed it is compared with the previous copy, and
+the formulae added, updated to a new version or removed are counted (listed
+with --verbose). The index database and search indexes are then rebuilt in
+a background process, so the next search or install does not wait for them.
+
+The indexes replaced by the last few updates are kept (see the
+index_snapshots config key). A download that does not parse is replaced by
+the previous index straight away; --rollback goes back one update by hand,
+until the next update.
+
+With --record-outdated the outdated packages are counted afterwards and
+saved, and other commands mention them for the next two days. 'fastbrew
+autoupdate enable' runs this on a timer. --notify implies --record-outdated
+and also posts a desktop notification when any package is outdated.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if updateRebuildIndexes {
+			rebuildIndexes()
+			return
+		}
+		defer lockFastbrew()()
+		if updateRollback {
+			rollbackIndex()
+			return
+		}
+
 		client, err := newBrewClient()
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			exitWithError("Error", err)
 		}
+		quietForJSON(client)
 
-		fmt.Println("🔄 Updating FastBrew index...")
-		changed, err := client.ForceRefreshIndex()
+		if !jsonOutput {
+			fmt.Fprintln(stdout, "🔄 Updating FastBrew index...")
+		}
+		update, err := client.UpdateIndex(brew.IndexUpdateOptions{Force: updateForce})
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			exitWithError("Error", err)
 		}
-		if changed {
-			fmt.Println("✅ Index updated!")
-			return
+		if update.Changed {
+			if err := startIndexRebuild(); err != nil {
+				fmt.Fprintf(stderr, "⚠️  Could not rebuild the search indexes in the background: %v\n", err)
+			}
+		}
+
+		view := UpdateView{
+			Changed: update.Changed,
+			Added:   nonNil(update.Added),
+			Updated: nonNil(update.Updated),
+			Removed: nonNil(update.Removed),
+		}
+		if !jsonOutput {
+			writeUpdateSummary(stdout, view, updateVerbose)
+		}
+
+		if updateRecordOutdated || updateNotify {
+			record, err := client.RecordOutdated()
+			if err != nil {
+				exitWithError("Error checking outdated packages", err)
+			}
+			count := len(record.Packages)
+			view.Outdated = &count
+			if !jsonOutput {
+				fmt.Fprintf(stdout, "📋 %s\n", outdatedCountText(count))
+			}
+			if updateNotify {
+				notifyOutdated(record.Packages)
+			}
+		}
+		if jsonOutput {
+			printJSON(view)
 		}
-		fmt.Println("Already up-to-date.")
 	},
 }
 
+// writeUpdateSummary prints what an update changed; verbose lists the
+// formulae by name.
+func writeUpdateSummary(w io.Writer, view UpdateView, verbose bool) {
+	n := len(view.Added) + len(view.Updated) + len(view.Removed)
+	switch {
+	case !view.Changed:
+		fmt.Fprintln(w, "Already up-to-date.")
+		return
+	case n > 0:
+		fmt.Fprintf(w, "✅ Index updated! %d formulae changed (%d new, %d updated, %d removed)\n",
+			n, len(view.Added), len(view.Updated), len(view.Removed))
+	default:
+		fmt.Fprintln(w, "✅ Index updated!")
+	}
+	if !verbose {
+		return
+	}
+	for _, group := range []struct {
+		label string
+		names []string
+	}{
+		{"New", view.Added},
+		{"Updated", view.Updated},
+		{"Removed", view.Removed},
+	} {
+		if len(group.names) > 0 {
+			fmt.Fprintf(w, "  %s: %s\n", group.label, strings.Join(group.names, ", "))
+		}
+	}
+}
+
+// nonNil returns s, or an empty slice for nil so JSON shows [].
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// startIndexRebuild runs update --rebuild-indexes in a detached process
+// that outlives this one. It takes no lock, so it never holds up the next
+// command: the indexes are written to a temporary file and renamed into
+// place, and the index database records the copy of the cached index it
+// was read from, so one built from an index an update has since replaced
+// is rebuilt by the next command needing it.
+func startIndexRebuild() error {
+	exePath, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(exePath, "update", "--rebuild-indexes")
+	cmd.SysProcAttr = daemonSysProcAttr()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// rebuildIndexes is update --rebuild-indexes.
+func rebuildIndexes() {
+	client, err := newBrewClient()
+	if err != nil {
+		exitWithError("Error", err)
+	}
+	quietForJSON(client)
+	if err := client.RebuildIndexes(); err != nil {
+		exitWithError("Error rebuilding indexes", err)
+	}
+	if !jsonOutput {
+		fmt.Fprintln(stdout, "✅ Indexes rebuilt")
+	}
+}
+
+// rollbackIndex is update --rollback.
+func rollbackIndex() {
+	client, err := newBrewClient()
+	if err != nil {
+		exitWithError("Error", err)
+	}
+	snapshot, err := client.RollbackIndex()
+	if err != nil {
+		exitWithError("Error", err)
+	}
+	if err := startIndexRebuild(); err != nil {
+		fmt.Fprintf(stderr, "⚠️  Could not rebuild the search indexes in the background: %v\n", err)
+	}
+	if jsonOutput {
+		printJSON(snapshot)
+		return
+	}
+	fmt.Fprintf(stdout, "↩️  Restored the package index from before the update of %s\n", snapshot.Created.Local().Format(time.DateTime))
+}
+
 func init() {
+	updateCmd.Flags().BoolVar(&updateForce, "force", false, "Download the full index even if the server reports it unchanged")
+	updateCmd.Flags().BoolVar(&updateRecordOutdated, "record-outdated", false, "Count outdated packages afterwards, for the hint other commands print")
+	updateCmd.Flags().BoolVar(&updateNotify, "notify", false, "Record outdated packages and post a desktop notification when there are any")
+	updateCmd.Flags().BoolVarP(&updateVerbose, "verbose", "v", false, "List the formulae added, updated and removed")
+	updateCmd.Flags().BoolVar(&updateRollback, "rollback", false, "Restore the package index from before the last update")
+	updateCmd.MarkFlagsMutuallyExclusive("rollback", "force")
+	updateCmd.Flags().BoolVar(&updateRebuildIndexes, "rebuild-indexes", false, "Only rebuild the index database and search indexes from the cached index")
+	updateCmd.Flags().MarkHidden("rebuild-indexes")
 	rootCmd.AddCommand(updateCmd)
 }
@@ -4,81 +4,358 @@ import (
 	"fastbrew/internal/brew"
 	"fastbrew/internal/config"
 	"fastbrew/internal/daemon"
+	"fastbrew/internal/progress"
+	"fastbrew/internal/prompt"
+	"fastbrew/internal/services"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
+var (
+	upgradeQuiet       bool
+	upgradeInteractive bool
+	upgradeForce       bool
+)
+
 var upgradeCmd = &cobra.Command{
 	Use:   "upgrade [package...]",
 	Short: "Upgrade packages with parallel fetching",
+	Long: `Upgrade outdated packages, or only the named ones. Pinned packages are
+skipped, as are packages whose new version is ruled out by their version
+constraint (fastbrew config set constraints.<name> "<21").
+
+--dry-run prints the plan, with each bottle's download size from its registry
+manifest, and changes nothing. --interactive shows the same plan numbered and
+asks which packages to upgrade.
+
+Before upgrading a formula whose service is running (e.g. postgresql), the
+service is named and fastbrew offers to stop it for the upgrade and start it
+again afterwards; declining cancels the upgrade. --force upgrades without
+asking and leaves the services running.`,
+	ValidArgsFunction: completeInstalledPackages,
 	Run: func(cmd *cobra.Command, args []string) {
+		if upgradeInteractive && jsonOutput {
+			exitWithError("Error", fmt.Errorf("--interactive cannot be combined with --json"))
+		}
+
 		pinned, _ := loadPinnedPackages()
 		pinnedList := make([]string, 0, len(pinned))
 		for name := range pinned {
 			pinnedList = append(pinnedList, name)
 		}
 
-		if ran, err := tryRunMutationJob("upgrade", daemon.JobOperationUpgrade, args, daemon.JobSubmitOptions{Pinned: pinnedList}); ran {
-			if err != nil {
-				fmt.Printf("Error upgrading: %v\n", err)
-				os.Exit(1)
+		started := time.Now()
+		var rec *mutationRecorder
+		if jsonOutput {
+			rec = newMutationRecorder()
+		}
+
+		svcMgr := services.NewServiceManager()
+
+		// The plan is built locally; the daemon only runs upgrades. The
+		// services of the formulae it will replace are stopped only once it
+		// has the job, and started again however it ends.
+		if !dryRun && !upgradeInteractive {
+			if daemonClient := mutationJobClient("upgrade", daemon.JobOperationUpgrade); daemonClient != nil {
+				stopped, ok := stopServicesFor("upgrade", servicesToUpgrade(svcMgr, args, pinned), upgradeForce, svcMgr, newPrompter())
+				if !ok {
+					os.Exit(1)
+				}
+				restartServices := sync.OnceFunc(func() { startServices(stopped, svcMgr) })
+				defer restartServices()
+				ran, err := runMutationJob(daemonClient, "upgrade", daemon.JobOperationUpgrade, args, daemon.JobSubmitOptions{Pinned: pinnedList, Constraints: config.Get().Constraints}, rec)
+				restartServices()
+				if ran {
+					printRunSummary(started)
+					finishMutation(rec, "upgrade", args, err, "Error upgrading", "✅ Upgrade complete!")
+					forgetUpgraded(args)
+					return
+				}
 			}
-			fmt.Println("✅ Upgrade complete!")
-			return
+		}
+
+		if !dryRun {
+			defer lockFastbrew()()
 		}
 
 		client, err := newBrewClient()
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			exitWithError("Error", err)
 		}
 
 		cfg := config.Get()
 		client.MaxParallel = cfg.GetParallelDownloads()
+		if rec != nil {
+			quietForJSON(client)
+			client.SetMutationHook(rec.recordMutation)
+		}
 
 		var outdated []brew.OutdatedPackage
 
 		if len(args) > 0 {
 			outdated, err = client.GetOutdatedForPackages(args)
-			if err != nil {
-				fmt.Printf("Error checking outdated: %v\n", err)
-				os.Exit(1)
-			}
 		} else {
 			outdated, err = client.GetOutdated()
-			if err != nil {
-				fmt.Printf("Error checking outdated: %v\n", err)
-				os.Exit(1)
-			}
+		}
+		if err != nil {
+			exitWithError("Error checking outdated", err)
 		}
 
-		if len(pinned) > 0 {
-			var filtered []brew.OutdatedPackage
-			for _, pkg := range outdated {
-				if pinned[pkg.Name] {
-					fmt.Printf("⏭️  Skipping pinned package: %s\n", pkg.Name)
-					continue
+		var filtered []brew.OutdatedPackage
+		for _, pkg := range outdated {
+			switch {
+			case pinned[pkg.Name]:
+				if rec != nil {
+					rec.record(pkg.Name, brew.MutationPhaseComplete, brew.MutationStatusSkipped, "pinned")
+				} else {
+					fmt.Fprintf(stdout, "⏭️  Skipping pinned package: %s\n", pkg.Name)
 				}
+			case pkg.Held:
+				reason := fmt.Sprintf("held back: %s is outside constraint %q", pkg.NewVersion, pkg.Constraint)
+				if rec != nil {
+					rec.record(pkg.Name, brew.MutationPhaseComplete, brew.MutationStatusSkipped, reason)
+				} else {
+					fmt.Fprintf(stdout, "⏸️  Holding %s at %s (%s)\n", pkg.Name, pkg.CurrentVersion, reason)
+				}
+			default:
 				filtered = append(filtered, pkg)
 			}
-			outdated = filtered
 		}
+		outdated = filtered
 
 		if len(outdated) == 0 {
-			fmt.Println("✅ All packages up to date or pinned.")
+			if dryRun && jsonOutput {
+				printJSON(&brew.UpgradePlan{Packages: []brew.UpgradePlanEntry{}})
+				return
+			}
+			finishMutation(rec, "upgrade", args, nil, "", "✅ All packages up to date, pinned or held.")
+			forgetUpgraded(args)
 			return
 		}
 
-		if err := client.UpgradeNative(nil, outdated); err != nil {
-			fmt.Printf("Error upgrading: %v\n", err)
+		if dryRun || upgradeInteractive {
+			plan, err := client.PlanUpgrade(cmd.Context(), outdated)
+			if err != nil {
+				exitWithError("Error planning upgrade", err)
+			}
+			if dryRun {
+				if jsonOutput {
+					printJSON(plan)
+					return
+				}
+				writeUpgradePlan(stdout, plan)
+				fmt.Fprintln(stdout, "\n💡 Dry run - nothing was upgraded.")
+				return
+			}
+			outdated, err = selectUpgrades(plan, outdated, newPrompter())
+			if err != nil {
+				exitWithError("Error", err)
+			}
+			if len(outdated) == 0 {
+				fmt.Fprintln(stdout, "Nothing selected.")
+				return
+			}
+		}
+
+		upgraded := make([]string, len(outdated))
+		for i, pkg := range outdated {
+			upgraded[i] = pkg.Name
+		}
+
+		// Services are stopped once the lock is held and the packages are
+		// known, and only those of the packages being upgraded.
+		stopped, ok := stopServicesFor("upgrade", servicesBacking(runningHomebrewServices(svcMgr), upgraded), upgradeForce, svcMgr, newPrompter())
+		if !ok {
 			os.Exit(1)
 		}
-		fmt.Println("✅ Upgrade complete!")
+		restartServices := sync.OnceFunc(func() { startServices(stopped, svcMgr) })
+		defer restartServices()
+
+		ctx, stopInterrupt := interruptContext(cmd.Context())
+		defer stopInterrupt()
+
+		stopProgress := startProgressDisplay(client, cfg.ShowProgress, upgradeQuiet)
+		err = client.UpgradeNative(ctx, nil, outdated)
+		stopProgress()
+		cleanupAfterInterrupt(ctx, client)
+		// finishMutation exits on failure, which skips deferred calls.
+		restartServices()
+		printRunSummary(started)
+		finishMutation(rec, "upgrade", args, err, "Error upgrading", "✅ Upgrade complete!")
+		forgetUpgraded(upgraded)
 	},
 }
 
+// servicesToUpgrade returns the running services whose formula an upgrade
+// of args, or of everything when args is empty, would replace. Pinned and
+// held formulae are left alone, so their services are not included.
+func servicesToUpgrade(mgr services.ServiceManager, args []string, pinned map[string]bool) []services.Service {
+	running := runningHomebrewServices(mgr)
+	if len(running) == 0 {
+		return nil
+	}
+	if len(args) == 0 {
+		for _, svc := range running {
+			args = append(args, services.FormulaName(svc.Name))
+		}
+	}
+	client, err := newBrewClient()
+	if err != nil {
+		return nil
+	}
+	outdated, err := client.GetOutdatedForPackages(args)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, pkg := range outdated {
+		if !pinned[pkg.Name] && !pkg.Held {
+			names = append(names, pkg.Name)
+		}
+	}
+	return servicesBacking(running, names)
+}
+
+// forgetUpgraded drops upgraded packages from the outdated record, or the
+// whole record when names is empty, so the outdated hint stops naming them.
+func forgetUpgraded(names []string) {
+	if err := brew.ForgetOutdated(names...); err != nil {
+		fmt.Fprintf(stderr, "Warning: failed to update the outdated record: %v\n", err)
+	}
+}
+
+// writeUpgradePlan prints plan as a table followed by the total download
+// size and any packages whose metadata could not be fetched.
+func writeUpgradePlan(out io.Writer, plan *brew.UpgradePlan) {
+	if len(plan.Packages) == 0 {
+		fmt.Fprintln(out, "✅ All packages up to date.")
+		return
+	}
+	fmt.Fprintf(out, "📋 %d package(s) to upgrade:\n", len(plan.Packages))
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCURRENT\t\tNEW\tDOWNLOAD")
+	for _, pkg := range plan.Packages {
+		fmt.Fprintf(w, "%s\t%s\t→\t%s\t%s\n", pkg.Name, pkg.CurrentVersion, pkg.NewVersion, planDownloadSize(pkg))
+	}
+	w.Flush()
+
+	total := fmt.Sprintf("\n⬇️  Total download: %s", progress.FormatBytes(plan.DownloadSize))
+	if plan.UnknownSizes > 0 {
+		total += fmt.Sprintf(" (plus %d of unknown size)", plan.UnknownSizes)
+	}
+	fmt.Fprintln(out, total)
+	for _, pkg := range plan.Packages {
+		if pkg.Error != "" {
+			fmt.Fprintf(out, "  ⚠️  %s: %s\n", pkg.Name, pkg.Error)
+		}
+	}
+}
+
+func planDownloadSize(pkg brew.UpgradePlanEntry) string {
+	switch {
+	case pkg.Cached:
+		return "cached"
+	case pkg.DownloadSize < 0:
+		return "unknown"
+	default:
+		return progress.FormatBytes(pkg.DownloadSize)
+	}
+}
+
+// selectUpgrades lists plan numbered and asks which packages to upgrade
+// until the answer parses. It returns the chosen packages from outdated;
+// none when the user declines, and an error when no answer can be read.
+func selectUpgrades(plan *brew.UpgradePlan, outdated []brew.OutdatedPackage, in *prompt.Prompter) ([]brew.OutdatedPackage, error) {
+	if len(plan.Packages) == 0 {
+		return nil, nil
+	}
+	for i, pkg := range plan.Packages {
+		fmt.Fprintf(stdout, "  %2d) %s %s → %s (%s)\n", i+1, pkg.Name, pkg.CurrentVersion, pkg.NewVersion, planDownloadSize(pkg))
+	}
+
+	for {
+		line, err := in.Line("Upgrade which packages? e.g. 1 3-5, all or none [all]", "all")
+		if err != nil {
+			return nil, err
+		}
+		chosen, parseErr := parseSelection(line, len(plan.Packages))
+		if parseErr != nil {
+			fmt.Fprintf(stdout, "⚠️  %v\n", parseErr)
+			continue
+		}
+
+		names := make(map[string]bool, len(chosen))
+		for _, i := range chosen {
+			names[plan.Packages[i].Name] = true
+		}
+		var selected []brew.OutdatedPackage
+		for _, pkg := range outdated {
+			if names[pkg.Name] {
+				selected = append(selected, pkg)
+			}
+		}
+		return selected, nil
+	}
+}
+
+// parseSelection parses a list of 1-based numbers and ranges ("1 3-5,7")
+// into sorted 0-based indexes below n. An empty answer or "all" selects
+// everything; "none" selects nothing.
+func parseSelection(input string, n int) ([]int, error) {
+	input = strings.ToLower(strings.TrimSpace(input))
+	all := make([]int, n)
+	for i := range all {
+		all[i] = i
+	}
+	switch input {
+	case "", "a", "all":
+		return all, nil
+	case "n", "none", "q":
+		return nil, nil
+	}
+
+	seen := make(map[int]bool)
+	for _, field := range strings.FieldsFunc(input, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+		lo, hi, isRange := strings.Cut(field, "-")
+		first, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number or range", field)
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(hi); err != nil {
+				return nil, fmt.Errorf("%q is not a number or range", field)
+			}
+		}
+		if first < 1 || last > n || first > last {
+			return nil, fmt.Errorf("%q is out of range 1-%d", field, n)
+		}
+		for i := first; i <= last; i++ {
+			seen[i-1] = true
+		}
+	}
+	chosen := make([]int, 0, len(seen))
+	for i := range seen {
+		chosen = append(chosen, i)
+	}
+	sort.Ints(chosen)
+	return chosen, nil
+}
+
 func init() {
+	upgradeCmd.Flags().BoolVarP(&upgradeQuiet, "quiet", "q", false, "Suppress progress bars; only print when each download starts and finishes")
+	// Redeclared locally for brew's -n shorthand; it sets the global --dry-run.
+	upgradeCmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Show what would be upgraded and the download size without changing anything")
+	upgradeCmd.Flags().BoolVarP(&upgradeInteractive, "interactive", "i", false, "Choose which outdated packages to upgrade")
+	upgradeCmd.Flags().BoolVar(&upgradeForce, "force", false, "Upgrade formulae of running services without stopping them")
 	rootCmd.AddCommand(upgradeCmd)
 }
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var usesInstalled bool
+
+var usesCmd = &cobra.Command{
+	Use:   "uses <formula>",
+	Short: "Show formulae that depend on a formula",
+	Long: `Show formulae that list the given formula as a direct dependency.
+
+Use --installed to see which installed formulae would break if it were
+uninstalled.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: singleArg(completeAvailablePackages),
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newBrewClient()
+		if err != nil {
+			fmt.Fprintf(stdout, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+
+		users, err := client.Uses(args[0], usesInstalled)
+		if err != nil {
+			fmt.Fprintf(stdout, "Error resolving dependents: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+
+		if len(users) == 0 {
+			if usesInstalled {
+				fmt.Fprintf(stdout, "No installed formulae depend on %s.\n", args[0])
+			} else {
+				fmt.Fprintf(stdout, "No formulae depend on %s.\n", args[0])
+			}
+			return
+		}
+
+		for _, name := range users {
+			fmt.Fprintln(stdout, name)
+		}
+	},
+}
+
+func init() {
+	usesCmd.Flags().BoolVar(&usesInstalled, "installed", false, "Only show installed formulae")
+	rootCmd.AddCommand(usesCmd)
+}
@@ -12,7 +12,7 @@ var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Print the version number of FastBrew",
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Printf("FastBrew version %s\n", Version)
+		fmt.Fprintf(stdout, "FastBrew version %s\n", Version)
 	},
 }
 
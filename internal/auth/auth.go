@@ -0,0 +1,173 @@
+// Package auth looks up credentials for private taps and bottle
+// registries.
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credential authenticates against one host.
+type Credential struct {
+	// Username is empty for bare tokens.
+	Username string
+	Secret   string
+	// Source names where the credential came from, for diagnostics.
+	Source string
+}
+
+// Header returns the Authorization header value: Basic when a username is
+// set, Bearer otherwise.
+func (c Credential) Header() string {
+	if c.Username != "" {
+		return "Basic " + c.basic()
+	}
+	return "Bearer " + c.Secret
+}
+
+// BasicHeader returns a Basic Authorization header value. Bare tokens use
+// the "x-access-token" user name that GitHub, GHES and GHCR accept.
+func (c Credential) BasicHeader() string {
+	return "Basic " + c.basic()
+}
+
+func (c Credential) basic() string {
+	user := c.Username
+	if user == "" {
+		user = "x-access-token"
+	}
+	return base64.StdEncoding.EncodeToString([]byte(user + ":" + c.Secret))
+}
+
+// ParseCredential parses "user:secret" or a bare token.
+func ParseCredential(value, source string) Credential {
+	if user, secret, ok := strings.Cut(value, ":"); ok {
+		return Credential{Username: user, Secret: secret, Source: source}
+	}
+	return Credential{Secret: value, Source: source}
+}
+
+// Store resolves credentials by host. Lookups check, in order, the
+// FASTBREW_AUTH_<HOST> environment variable (e.g. FASTBREW_AUTH_GHCR_IO),
+// HOMEBREW_GITHUB_API_TOKEN or GITHUB_TOKEN for github.com and ghcr.io,
+// the configured tokens, and finally the git credential helper.
+type Store struct {
+	configured map[string]string
+	// GitHelper enables `git credential fill` lookups.
+	GitHelper bool
+
+	mu    sync.Mutex
+	cache map[string]*Credential
+}
+
+// NewStore returns a store over configured, which maps hosts to
+// "user:secret" or bare tokens.
+func NewStore(configured map[string]string) *Store {
+	normalized := make(map[string]string, len(configured))
+	for host, value := range configured {
+		normalized[strings.ToLower(host)] = value
+	}
+	return &Store{configured: normalized, GitHelper: true, cache: make(map[string]*Credential)}
+}
+
+// Lookup returns the credential for host, if any. A nil store has none.
+func (s *Store) Lookup(host string) (Credential, bool) {
+	if s == nil || host == "" {
+		return Credential{}, false
+	}
+	host = strings.ToLower(host)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cred, ok := s.cache[host]; ok {
+		if cred == nil {
+			return Credential{}, false
+		}
+		return *cred, true
+	}
+
+	cred := s.resolve(host)
+	s.cache[host] = cred
+	if cred == nil {
+		return Credential{}, false
+	}
+	return *cred, true
+}
+
+func (s *Store) resolve(host string) *Credential {
+	envKey := "FASTBREW_AUTH_" + envSuffix(host)
+	if v := os.Getenv(envKey); v != "" {
+		cred := ParseCredential(v, envKey)
+		return &cred
+	}
+	if host == "github.com" || host == "ghcr.io" {
+		for _, key := range []string{"HOMEBREW_GITHUB_API_TOKEN", "GITHUB_TOKEN"} {
+			if v := os.Getenv(key); v != "" {
+				return &Credential{Secret: v, Source: key}
+			}
+		}
+	}
+	if v, ok := s.configured[host]; ok && v != "" {
+		cred := ParseCredential(v, "config credentials."+host)
+		return &cred
+	}
+	if s.GitHelper {
+		if cred, ok := gitCredential(host); ok {
+			return &cred
+		}
+	}
+	return nil
+}
+
+func envSuffix(host string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(host) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// gitCredential asks the configured git credential helper for host
+// without prompting.
+func gitCredential(host string) (Credential, bool) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return Credential{}, false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", "credential", "fill")
+	cmd.Stdin = strings.NewReader("protocol=https\nhost=" + host + "\n\n")
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "SSH_ASKPASS=")
+	out, err := cmd.Output()
+	if err != nil {
+		return Credential{}, false
+	}
+
+	cred := Credential{Source: "git credential helper"}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(scanner.Text(), "=")
+		switch key {
+		case "username":
+			cred.Username = value
+		case "password":
+			cred.Secret = value
+		}
+	}
+	if cred.Secret == "" {
+		return Credential{}, false
+	}
+	return cred, true
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"testing"
+)
+
+func newTestStore(configured map[string]string) *Store {
+	s := NewStore(configured)
+	s.GitHelper = false
+	return s
+}
+
+func TestParseCredential(t *testing.T) {
+	tests := []struct {
+		in         string
+		user, pass string
+		header     string
+	}{
+		{"deploy:s3cret", "deploy", "s3cret", "Basic ZGVwbG95OnMzY3JldA=="},
+		{"ghp_token", "", "ghp_token", "Bearer ghp_token"},
+	}
+	for _, tt := range tests {
+		cred := ParseCredential(tt.in, "test")
+		if cred.Username != tt.user || cred.Secret != tt.pass {
+			t.Errorf("ParseCredential(%q) = %+v", tt.in, cred)
+		}
+		if got := cred.Header(); got != tt.header {
+			t.Errorf("Header() = %q, want %q", got, tt.header)
+		}
+	}
+}
+
+func TestLookupPrecedence(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "from-github-token")
+	t.Setenv("HOMEBREW_GITHUB_API_TOKEN", "")
+	t.Setenv("FASTBREW_AUTH_GHCR_IO", "")
+	s := newTestStore(map[string]string{"GHCR.io": "from-config", "ghe.example.com": "from-config"})
+
+	if cred, _ := s.Lookup("ghcr.io"); cred.Secret != "from-github-token" {
+		t.Errorf("ghcr.io should use GITHUB_TOKEN, got %+v", cred)
+	}
+	if cred, _ := s.Lookup("ghe.example.com"); cred.Secret != "from-config" {
+		t.Errorf("GITHUB_TOKEN must not be sent to other hosts, got %+v", cred)
+	}
+
+	t.Setenv("FASTBREW_AUTH_GHE_EXAMPLE_COM", "ci:from-env")
+	s = newTestStore(map[string]string{"ghe.example.com": "from-config"})
+	if cred, _ := s.Lookup("ghe.example.com"); cred.Username != "ci" || cred.Secret != "from-env" {
+		t.Errorf("host env var should win, got %+v", cred)
+	}
+}
+
+func TestLookupMissing(t *testing.T) {
+	if _, ok := newTestStore(nil).Lookup("example.com"); ok {
+		t.Error("expected no credential")
+	}
+	var s *Store
+	if _, ok := s.Lookup("example.com"); ok {
+		t.Error("nil store should have no credentials")
+	}
+}
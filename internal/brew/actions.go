@@ -8,10 +8,17 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 )
 
 type InstallOptions struct {
 	StrictNative bool
+	// Deps selects which dependency types are installed alongside each
+	// formula; InstalledOnly is ignored.
+	Deps DepsOptions
+	// Lock, when set, installs exactly the locked bottles and fails for
+	// any needed formula the lock does not cover.
+	Lock *InstallLock
 }
 
 func (o InstallOptions) Defaults() InstallOptions {
@@ -23,19 +30,11 @@ func (o InstallOptions) Defaults() InstallOptions {
 
 // Fetch downloads the package bottle/source
 func (c *Client) Fetch(pkg string) error {
-	idx, err := c.LoadIndex()
+	isCask, err := c.IsCask(pkg)
 	if err != nil {
 		return err
 	}
 
-	isCask := false
-	for _, cask := range idx.Casks {
-		if cask.Token == pkg {
-			isCask = true
-			break
-		}
-	}
-
 	if isCask {
 		metadata, err := c.FetchCaskMetadata(pkg)
 		if err != nil {
@@ -50,7 +49,7 @@ func (c *Client) Fetch(pkg string) error {
 		return installer.downloadArtifact(pkg, metadata.URL, destPath, metadata.SHA256, c.ProgressManager)
 	}
 
-	f, err := c.FetchFormula(pkg)
+	f, err := c.FetchFormula(context.Background(), pkg)
 	if err != nil {
 		return err
 	}
@@ -60,51 +59,83 @@ func (c *Client) Fetch(pkg string) error {
 
 // InstallNative performs native installation by resolving deps, downloading bottles, and linking.
 // Also handles cask installation via brew install --cask.
-func (c *Client) InstallNative(packages []string) error {
-	return c.InstallNativeWithOptions(packages, InstallOptions{})
+func (c *Client) InstallNative(ctx context.Context, packages []string) error {
+	return c.InstallNativeWithOptions(ctx, packages, InstallOptions{})
 }
 
-func (c *Client) InstallNativeWithOptions(packages []string, opts InstallOptions) error {
+// InstallNativeWithOptions is InstallNative with options. Cancelling ctx
+// stops metadata fetches and downloads; nothing is extracted once it is
+// cancelled, and ctx.Err() is returned.
+func (c *Client) InstallNativeWithOptions(ctx context.Context, packages []string, opts InstallOptions) (err error) {
+	if c.beginRunReport(MutationOperationInstall) {
+		defer func() { c.finishRunReport(err) }()
+	}
 	opts = opts.Defaults()
-	idx, err := c.LoadIndex()
+	if err := c.ensurePrefix(); err != nil {
+		return err
+	}
+	// Formulae are looked up one by one in the index database rather than
+	// loading the whole index for a handful of packages.
+	lookup, err := c.formulaLookup()
 	if err != nil {
 		return err
 	}
 
-	caskSet := make(map[string]struct{}, len(idx.Casks))
-	for _, cask := range idx.Casks {
-		caskSet[cask.Token] = struct{}{}
-	}
+	packages = c.resolveInstallAliases(packages)
 
-	coreFormulae := c.classifyFormulae(packages, idx)
+	coreFormulae := c.classifyFormulae(packages, lookup)
 
 	var casks []string
+	var unknown []string
 	for _, pkg := range packages {
-		if _, ok := caskSet[pkg]; ok {
-			casks = append(casks, pkg)
+		if cask, ok := c.LookupCask(pkg); ok {
+			casks = append(casks, cask.Token)
+		} else if strings.Count(pkg, "/") == 2 {
+			coreFormulae = append(coreFormulae, pkg)
+		} else if !sliceContains(coreFormulae, pkg) {
+			unknown = append(unknown, pkg)
+		}
+	}
+
+	// Names the API does not know may come from an installed tap.
+	if len(unknown) > 0 {
+		if taps, err := newTapFormulae(c.Credentials); err == nil {
+			for _, pkg := range unknown {
+				if ref := taps.lookupShort(pkg); ref != "" {
+					coreFormulae = append(coreFormulae, ref)
+				}
+			}
 		}
 	}
 
+	// Casks are still installed when only some formulae failed.
+	var partialErr error
 	if len(coreFormulae) > 0 {
-		if err := c.installFormulaeWithIndex(coreFormulae, idx, opts); err != nil {
-			return err
+		if err := c.installFormulaeWithLookup(ctx, coreFormulae, lookup, opts); err != nil {
+			if KindOf(err) != KindPartialFailure {
+				return err
+			}
+			partialErr = err
 		}
 	}
 
 	if len(casks) > 0 {
-		fmt.Printf("🍷 Installing casks: %v\n", casks)
+		c.printf("🍷 Installing casks: %v\n", casks)
 		installer := NewCaskInstaller(c)
 		installer.SetOperation(MutationOperationInstall)
 		for _, cask := range casks {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
 			if err := installer.Install(cask, c.ProgressManager); err != nil {
 				return fmt.Errorf("cask installation failed for %s: %w", cask, err)
 			}
 		}
-		fmt.Println("✅ Casks installed successfully")
+		c.println("✅ Casks installed successfully")
 	}
 
 	c.notifyInvalidation(EventInstalledChanged)
-	return nil
+	return partialErr
 }
 
 type classifiedFormulae struct {
@@ -112,18 +143,15 @@ type classifiedFormulae struct {
 	tapRefs      []TapFormulaRef
 }
 
-func (c *Client) classifyFormulae(packages []string, idx *Index) []string {
-	formulaSet := make(map[string]struct{}, len(idx.Formulae))
-	for _, f := range idx.Formulae {
-		formulaSet[f.Name] = struct{}{}
-	}
-
+// classifyFormulae returns the packages that are core formulae by their
+// current name.
+func (c *Client) classifyFormulae(packages []string, lookup func(name string) (*Formula, bool)) []string {
 	var coreFormulae []string
 	for _, pkg := range packages {
 		if strings.Contains(pkg, "/") {
 			continue
 		}
-		if _, isFormula := formulaSet[pkg]; isFormula {
+		if f, isFormula := lookup(pkg); isFormula && f.Name == pkg {
 			coreFormulae = append(coreFormulae, pkg)
 		}
 	}
@@ -131,25 +159,71 @@ func (c *Client) classifyFormulae(packages []string, idx *Index) []string {
 }
 
 // installFormulae handles formula installation via bottles
-func (c *Client) installFormulaeWithIndex(packages []string, idx *Index, opts InstallOptions) error {
-	fmt.Println("🔍 Resolving dependencies from API...")
+func (c *Client) installFormulaeWithLookup(ctx context.Context, packages []string, lookup func(name string) (*Formula, bool), opts InstallOptions) (err error) {
+	c.println("🔍 Resolving dependencies...")
 
-	formulaMap := make(map[string]Formula)
-	for _, f := range idx.Formulae {
-		formulaMap[f.Name] = f
+	// Tap formulae (user/repo/formula) are read from their tap instead of
+	// the API and installed under their short name.
+	var taps *tapFormulae
+	var tapErr error
+	resolveTap := func(ref string) string {
+		if taps == nil && tapErr == nil {
+			taps, tapErr = newTapFormulae(c.Credentials)
+		}
+		if tapErr != nil {
+			return ""
+		}
+		f, err := taps.load(ref)
+		if err != nil {
+			tapErr = err
+			return ""
+		}
+		return f.Name
+	}
+
+	packages = append([]string(nil), packages...)
+	for i, pkg := range packages {
+		if strings.Contains(pkg, "/") {
+			packages[i] = resolveTap(pkg)
+		}
+	}
+	if tapErr != nil {
+		return tapErr
 	}
 
 	needed := make(map[string]bool)
 	var collectNeeded func(name string)
 	collectNeeded = func(name string) {
+		if strings.Contains(name, "/") {
+			if name = resolveTap(name); name == "" {
+				return
+			}
+		}
 		if needed[name] || c.isInstalled(name) {
 			return
 		}
 		needed[name] = true
 
-		if f, ok := formulaMap[name]; ok {
-			for _, dep := range f.Dependencies {
-				collectNeeded(dep)
+		if taps != nil {
+			if f, ok := taps.formulae[name]; ok {
+				for _, dep := range f.depNames(opts.Deps) {
+					collectNeeded(dep)
+				}
+				return
+			}
+		}
+
+		if opts.Lock != nil {
+			if locked, ok := opts.Lock.Bottles[name]; ok {
+				for _, dep := range locked.Dependencies {
+					collectNeeded(dep)
+				}
+				return
+			}
+		}
+		if f, ok := lookup(name); ok {
+			for _, dep := range f.depList(opts.Deps) {
+				collectNeeded(dep.name)
 			}
 		}
 	}
@@ -157,41 +231,79 @@ func (c *Client) installFormulaeWithIndex(packages []string, idx *Index, opts In
 	for _, pkg := range packages {
 		collectNeeded(pkg)
 	}
+	if tapErr != nil {
+		return tapErr
+	}
+	requested := make(map[string]bool, len(packages))
+	reasons := make(map[string]InstallReason)
+	for _, pkg := range packages {
+		requested[pkg] = true
+		if !needed[pkg] && c.isInstalled(pkg) {
+			c.markInstalledOnRequest(pkg)
+			reasons[pkg] = ReasonExplicit
+		}
+	}
+	defer func() {
+		// Kegs rolled back after a failure are not installed.
+		for name := range reasons {
+			if !c.isInstalled(name) {
+				delete(reasons, name)
+			}
+		}
+		if recordErr := RecordInstallReasons(reasons); recordErr != nil {
+			c.printf("  ⚠️  Failed to record install reasons: %v\n", recordErr)
+		}
+	}()
 
 	if len(needed) == 0 {
-		fmt.Println("✅ All formulae already installed.")
+		c.println("✅ All formulae already installed.")
 		return nil
 	}
 
+	// The index answers for most formulae; only those it has no bottle
+	// for are fetched from the API.
+	formulaDetails := make(map[string]*RemoteFormula)
 	neededList := make([]string, 0, len(needed))
 	for name := range needed {
+		if taps != nil && taps.formulae[name] != nil {
+			continue
+		}
+		if f := c.indexedFormula(name); f != nil {
+			formulaDetails[f.Name] = f
+			c.emitMutation(MutationOperationInstall, name, MutationPhaseMetadata, MutationStatusSucceeded, "metadata from index", 0, 0, "")
+			continue
+		}
 		neededList = append(neededList, name)
 	}
 
-	fmt.Printf("📡 Fetching metadata for %d formulae in parallel...\n", len(neededList))
+	if len(neededList) > 0 {
+		c.printf("📡 Fetching metadata for %d formulae in parallel...\n", len(neededList))
+	}
 
-	const maxWorkers = 10
 	type fetchResult struct {
 		formula *RemoteFormula
 		err     error
 	}
 
 	results := make(chan fetchResult, len(neededList))
-	fetchSem := make(chan struct{}, maxWorkers)
+	sched := c.scheduler()
 	var fetchWg sync.WaitGroup
 
-	ctx := context.Background()
 	for _, name := range neededList {
 		c.emitMutation(MutationOperationInstall, name, MutationPhaseMetadata, MutationStatusQueued, "metadata queued", 0, 0, "")
 		fetchWg.Add(1)
 		go func(n string) {
 			defer fetchWg.Done()
-			fetchSem <- struct{}{}
-			defer func() { <-fetchSem }()
+			release, err := sched.Acquire(ctx)
+			if err != nil {
+				results <- fetchResult{err: err}
+				return
+			}
+			defer release()
 
 			c.emitMutation(MutationOperationInstall, n, MutationPhaseMetadata, MutationStatusRunning, "fetching metadata", 0, 0, "")
 			f, err := retry.WithResult(ctx, func() (*RemoteFormula, error) {
-				return c.FetchFormula(n)
+				return c.FetchFormula(ctx, n)
 			})
 			if err != nil {
 				c.emitMutation(MutationOperationInstall, n, MutationPhaseMetadata, MutationStatusFailed, err.Error(), 0, 0, "")
@@ -204,8 +316,10 @@ func (c *Client) installFormulaeWithIndex(packages []string, idx *Index, opts In
 
 	fetchWg.Wait()
 	close(results)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-	formulaDetails := make(map[string]*RemoteFormula)
 	for res := range results {
 		if res.err != nil {
 			return fmt.Errorf("failed to fetch formula: %w", res.err)
@@ -213,10 +327,26 @@ func (c *Client) installFormulaeWithIndex(packages []string, idx *Index, opts In
 		formulaDetails[res.formula.Name] = res.formula
 	}
 
+	if opts.Lock != nil {
+		for _, f := range formulaDetails {
+			if err := opts.Lock.apply(f); err != nil {
+				return err
+			}
+		}
+	}
+	if taps != nil {
+		for name, f := range taps.formulae {
+			if needed[name] {
+				formulaDetails[name] = f
+			}
+		}
+	}
+
 	visited := make(map[string]bool)
 	var installQueue []*RemoteFormula
 	var buildQueue func(name string)
 	buildQueue = func(name string) {
+		name = shortFormulaName(name)
 		if visited[name] || c.isInstalled(name) {
 			return
 		}
@@ -227,7 +357,7 @@ func (c *Client) installFormulaeWithIndex(packages []string, idx *Index, opts In
 			return
 		}
 
-		for _, dep := range f.Dependencies {
+		for _, dep := range f.depNames(opts.Deps) {
 			buildQueue(dep)
 		}
 		installQueue = append(installQueue, f)
@@ -240,10 +370,18 @@ func (c *Client) installFormulaeWithIndex(packages []string, idx *Index, opts In
 		c.emitMutation(MutationOperationInstall, f.Name, MutationPhaseDownload, MutationStatusQueued, "download queued", 0, 0, "bytes")
 	}
 
-	fmt.Printf("📦 Found %d formulae to install.\n", len(installQueue))
+	c.sweepExtractionTempDirs()
+	if err := c.checkDiskSpace(ctx, installQueue); err != nil {
+		return err
+	}
+
+	c.beginTransaction(MutationOperationInstall, formulaNames(installQueue))
+	defer func() { c.finishTransaction(err) }()
+
+	c.printf("📦 Found %d formulae to install.\n", len(installQueue))
 
 	// Phase 1: Download all bottles in parallel
-	fmt.Printf("⬇️  Downloading %d bottle(s) in parallel...\n", len(installQueue))
+	c.printf("⬇️  Downloading %d bottle(s) in parallel...\n", len(installQueue))
 
 	type downloadResult struct {
 		formula *RemoteFormula
@@ -251,44 +389,55 @@ func (c *Client) installFormulaeWithIndex(packages []string, idx *Index, opts In
 		err     error
 	}
 
+	c.expectDownloads(len(installQueue))
 	dlCh := make(chan downloadResult, len(installQueue))
 	var wg sync.WaitGroup
-	sem := make(chan struct{}, c.getMaxParallel())
 
 	for _, f := range installQueue {
 		wg.Add(1)
 		go func(frm *RemoteFormula) {
 			defer wg.Done()
-			sem <- struct{}{}
-			defer func() { <-sem }()
+			release, err := sched.Acquire(ctx)
+			if err != nil {
+				dlCh <- downloadResult{formula: frm, err: err}
+				return
+			}
+			defer release()
 			c.emitMutation(MutationOperationInstall, frm.Name, MutationPhaseDownload, MutationStatusRunning, "downloading bottle", 0, 0, "bytes")
-			tarPath, err := c.DownloadBottle(frm)
+			start := time.Now()
+			tarPath, err := c.DownloadBottle(ctx, frm)
+			if err == nil {
+				c.recordDownload(frm, tarPath, time.Since(start))
+			}
 			dlCh <- downloadResult{formula: frm, tarPath: tarPath, err: err}
 		}(f)
 	}
 	wg.Wait()
 	close(dlCh)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
 	var downloaded []downloadResult
 	var dlErrors []error
 	for r := range dlCh {
 		if r.err != nil {
 			dlErrors = append(dlErrors, fmt.Errorf("failed to download %s: %w", r.formula.Name, r.err))
-			fmt.Printf("  ❌ Failed to download %s: %v\n", r.formula.Name, r.err)
+			c.printf("  ❌ Failed to download %s: %v\n", r.formula.Name, r.err)
 			c.emitMutation(MutationOperationInstall, r.formula.Name, MutationPhaseDownload, MutationStatusFailed, r.err.Error(), 0, 0, "bytes")
 		} else {
 			downloaded = append(downloaded, r)
-			fmt.Printf("  ✅ Downloaded %s\n", r.formula.Name)
+			c.printf("  ✅ Downloaded %s\n", r.formula.Name)
 			c.emitMutation(MutationOperationInstall, r.formula.Name, MutationPhaseDownload, MutationStatusSucceeded, "downloaded bottle", 0, 0, "bytes")
 		}
 	}
 
 	if len(downloaded) == 0 {
-		return fmt.Errorf("%d package(s) failed to download", len(dlErrors))
+		return kindErrorf(SharedKind(dlErrors), "%d package(s) failed to download", len(dlErrors))
 	}
 
 	// Phase 2: Extract bottles (limited concurrency for disk safety)
-	fmt.Printf("📦 Extracting %d bottle(s)...\n", len(downloaded))
+	c.printf("📦 Extracting %d bottle(s)...\n", len(downloaded))
 
 	type extractResult struct {
 		formula *RemoteFormula
@@ -304,8 +453,16 @@ func (c *Client) installFormulaeWithIndex(packages []string, idx *Index, opts In
 			defer wg.Done()
 			extractSem <- struct{}{}
 			defer func() { <-extractSem }()
+			if err := c.RunHooks(HookPreInstall, c.formulaHookEnv(d.formula, "")); err != nil {
+				exCh <- extractResult{formula: d.formula, err: err}
+				return
+			}
 			c.emitMutation(MutationOperationInstall, d.formula.Name, MutationPhaseExtract, MutationStatusRunning, "extracting bottle", 0, 0, "")
-			err := c.ExtractAndInstallBottle(d.formula, d.tarPath)
+			start := time.Now()
+			err := c.extractAndInstallBottle(d.formula, d.tarPath, !requested[d.formula.Name])
+			if err == nil {
+				c.recordExtract(d.formula, time.Since(start))
+			}
 			exCh <- extractResult{formula: d.formula, err: err}
 		}(dl)
 	}
@@ -316,27 +473,47 @@ func (c *Client) installFormulaeWithIndex(packages []string, idx *Index, opts In
 	for r := range exCh {
 		if r.err != nil {
 			installErrors = append(installErrors, fmt.Errorf("failed to extract %s: %w", r.formula.Name, r.err))
-			fmt.Printf("  ❌ Failed to extract %s: %v\n", r.formula.Name, r.err)
+			c.printf("  ❌ Failed to extract %s: %v\n", r.formula.Name, r.err)
 			c.emitMutation(MutationOperationInstall, r.formula.Name, MutationPhaseExtract, MutationStatusFailed, r.err.Error(), 0, 0, "")
 		} else {
-			fmt.Printf("  ✅ Extracted %s\n", r.formula.Name)
+			c.printf("  ✅ Extracted %s\n", r.formula.Name)
 			c.emitMutation(MutationOperationInstall, r.formula.Name, MutationPhaseExtract, MutationStatusSucceeded, "extracted bottle", 0, 0, "")
+			if requested[r.formula.Name] {
+				reasons[r.formula.Name] = ReasonExplicit
+			} else {
+				reasons[r.formula.Name] = ReasonDependency
+			}
 		}
 	}
 
 	allErrors := append(dlErrors, installErrors...)
 	if len(allErrors) > 0 {
 		for _, e := range allErrors {
-			fmt.Printf("  ⚠️  %v\n", e)
+			c.printf("  ⚠️  %v\n", e)
 		}
 		if len(downloaded) == len(dlErrors)+len(installErrors) {
-			return fmt.Errorf("%d package(s) failed to install", len(allErrors))
+			return kindErrorf(SharedKind(allErrors), "%d package(s) failed to install", len(allErrors))
 		}
 	}
 
+	if err := c.linkFormulae(installQueue, MutationOperationInstall); err != nil {
+		return err
+	}
+	c.runPostHooks(HookPostInstall, installQueue, nil)
+
+	c.printCaveats(installQueue)
+	if len(allErrors) > 0 {
+		return kindErrorf(KindPartialFailure, "%d package(s) failed to install", len(allErrors))
+	}
+	return nil
+}
+
+// linkFormulae links freshly extracted kegs: keg-only formulae only get
+// their opt link, everything else is linked into the prefix.
+func (c *Client) linkFormulae(queue []*RemoteFormula, operation string) error {
 	var linkQueue []*RemoteFormula
 	var kegOnlyQueue []*RemoteFormula
-	for _, f := range installQueue {
+	for _, f := range queue {
 		if f.KegOnly {
 			kegOnlyQueue = append(kegOnlyQueue, f)
 		} else {
@@ -345,49 +522,43 @@ func (c *Client) installFormulaeWithIndex(packages []string, idx *Index, opts In
 	}
 
 	for _, f := range kegOnlyQueue {
-		c.emitMutation(MutationOperationInstall, f.Name, MutationPhaseLink, MutationStatusRunning, "linking keg-only package", 0, 0, "")
+		c.emitMutation(operation, f.Name, MutationPhaseLink, MutationStatusRunning, "linking keg-only package", 0, 0, "")
 		optDir := filepath.Join(c.Prefix, "opt")
 		optLink := filepath.Join(optDir, f.Name)
 		os.MkdirAll(optDir, 0755)
-		if existing, err := os.Lstat(optLink); err == nil {
-			if existing.Mode()&os.ModeSymlink != 0 {
-				os.Remove(optLink)
-			}
-		}
 		cellarPath := filepath.Join(c.Prefix, "Cellar", f.Name, f.Versions.Stable)
-		if err := os.Symlink(cellarPath, optLink); err != nil {
-			c.emitMutation(MutationOperationInstall, f.Name, MutationPhaseLink, MutationStatusFailed, err.Error(), 0, 0, "")
+		if err := c.replaceSymlink(f.Name, cellarPath, optLink); err != nil {
+			c.emitMutation(operation, f.Name, MutationPhaseLink, MutationStatusFailed, err.Error(), 0, 0, "")
+			c.rollbackPackage(f.Name)
 			continue
 		}
-		c.emitMutation(MutationOperationInstall, f.Name, MutationPhaseLink, MutationStatusSucceeded, "keg-only link ready", 0, 0, "")
-		fmt.Printf("  🔗 %s (keg-only) → opt/%s\n", f.Name, f.Name)
-	}
-
-	fmt.Println("🔗 Linking binaries...")
-	if err := c.linkParallel(linkQueue, MutationOperationInstall); err != nil {
-		return err
+		c.markPackageComplete(f.Name)
+		c.recordLinks(f, 1)
+		c.emitMutation(operation, f.Name, MutationPhaseLink, MutationStatusSucceeded, "keg-only link ready", 0, 0, "")
+		c.printf("  🔗 %s (keg-only) → opt/%s\n", f.Name, f.Name)
 	}
 
-	return nil
+	c.println("🔗 Linking binaries...")
+	return c.linkParallel(linkQueue, operation)
 }
 
 // installFormulae handles formula installation via bottles
 func (c *Client) installFormulae(packages []string) error {
-	idx, err := c.LoadIndex()
+	lookup, err := c.formulaLookup()
 	if err != nil {
 		return fmt.Errorf("failed to load index: %w", err)
 	}
-	return c.installFormulaeWithIndex(packages, idx, InstallOptions{})
+	return c.installFormulaeWithLookup(context.Background(), packages, lookup, InstallOptions{})
 }
 
 func (c *Client) linkParallel(installQueue []*RemoteFormula, operation string) error {
 	conflictTracker := NewConflictTracker()
 
-	fmt.Println("  📋 Detecting conflicts...")
+	c.println("  📋 Detecting conflicts...")
 	for _, f := range installQueue {
 		result, err := c.LinkDryRun(f.Name, f.Versions.Stable)
 		if err != nil {
-			fmt.Printf("  ⚠️  Error checking %s: %v\n", f.Name, err)
+			c.printf("  ⚠️  Error checking %s: %v\n", f.Name, err)
 			continue
 		}
 
@@ -410,7 +581,7 @@ func (c *Client) linkParallel(installQueue []*RemoteFormula, operation string) e
 	}
 
 	if len(parallelQueue) > 0 {
-		fmt.Printf("  🔗 Linking %d packages in parallel...\n", len(parallelQueue))
+		c.printf("  🔗 Linking %d packages in parallel...\n", len(parallelQueue))
 
 		var linkWg sync.WaitGroup
 		linkSem := make(chan struct{}, c.getMaxParallel())
@@ -423,15 +594,19 @@ func (c *Client) linkParallel(installQueue []*RemoteFormula, operation string) e
 				c.emitMutation(operation, frm.Name, MutationPhaseLink, MutationStatusRunning, "linking package", 0, 0, "")
 				result, err := c.Link(frm.Name, frm.Versions.Stable)
 				if err != nil {
-					fmt.Printf("  ❌ Failed to link %s: %v\n", frm.Name, err)
+					c.printf("  ❌ Failed to link %s: %v\n", frm.Name, err)
 					c.emitMutation(operation, frm.Name, MutationPhaseLink, MutationStatusFailed, err.Error(), 0, 0, "")
+					c.rollbackPackage(frm.Name)
 					return
 				}
 				if result.Success {
-					fmt.Printf("  ✅ Linked %s\n", frm.Name)
+					c.printf("  ✅ Linked %s\n", frm.Name)
+					c.markPackageComplete(frm.Name)
+					c.recordLinks(frm, len(result.Binaries))
 					c.emitMutation(operation, frm.Name, MutationPhaseLink, MutationStatusSucceeded, "linked successfully", 0, 0, "")
 				} else {
 					c.emitMutation(operation, frm.Name, MutationPhaseLink, MutationStatusFailed, "link completed with errors", 0, 0, "")
+					c.rollbackPackage(frm.Name)
 				}
 			}(f)
 		}
@@ -439,7 +614,7 @@ func (c *Client) linkParallel(installQueue []*RemoteFormula, operation string) e
 	}
 
 	if len(sequentialQueue) > 0 {
-		fmt.Printf("  🔄 Linking %d packages with conflicts sequentially...\n", len(sequentialQueue))
+		c.printf("  🔄 Linking %d packages with conflicts sequentially...\n", len(sequentialQueue))
 
 		sequentialTracker := NewConflictTracker()
 
@@ -453,42 +628,46 @@ func (c *Client) linkParallel(installQueue []*RemoteFormula, operation string) e
 			c.emitMutation(operation, f.Name, MutationPhaseLink, MutationStatusRunning, "linking package", 0, 0, "")
 			result, err := c.Link(f.Name, f.Versions.Stable)
 			if err != nil {
-				fmt.Printf("  ❌ Failed to link %s: %v\n", f.Name, err)
+				c.printf("  ❌ Failed to link %s: %v\n", f.Name, err)
 				c.emitMutation(operation, f.Name, MutationPhaseLink, MutationStatusFailed, err.Error(), 0, 0, "")
+				c.rollbackPackage(f.Name)
 				continue
 			}
 
 			for _, binary := range result.Binaries {
 				if conflictPkg := sequentialTracker.CheckAndTrack(binary, f.Name); conflictPkg != "" {
-					fmt.Printf("  ⚠️  Binary '%s' already linked by package '%s', skipping '%s'\n",
+					c.printf("  ⚠️  Binary '%s' already linked by package '%s', skipping '%s'\n",
 						binary, conflictPkg, f.Name)
 				}
 			}
 
 			if result.Success {
-				fmt.Printf("  ✅ Linked %s\n", f.Name)
+				c.printf("  ✅ Linked %s\n", f.Name)
+				c.markPackageComplete(f.Name)
+				c.recordLinks(f, len(result.Binaries))
 				c.emitMutation(operation, f.Name, MutationPhaseLink, MutationStatusSucceeded, "linked successfully", 0, 0, "")
 			} else {
 				c.emitMutation(operation, f.Name, MutationPhaseLink, MutationStatusFailed, "link completed with errors", 0, 0, "")
+				c.rollbackPackage(f.Name)
 			}
 		}
 	}
 
 	conflicts := conflictTracker.GetConflicts()
 	if len(conflicts) > 0 {
-		fmt.Println("\n⚠️  Binary conflicts detected:")
+		c.println("\n⚠️  Binary conflicts detected:")
 
 		// Group conflicts by binary
 		conflictsByBinary := make(map[string][]BinaryConflict)
-		for _, c := range conflicts {
-			conflictsByBinary[c.BinaryName] = append(conflictsByBinary[c.BinaryName], c)
+		for _, conflict := range conflicts {
+			conflictsByBinary[conflict.BinaryName] = append(conflictsByBinary[conflict.BinaryName], conflict)
 		}
 
 		for binary, conflictList := range conflictsByBinary {
 			packages := make(map[string]bool)
-			for _, c := range conflictList {
-				packages[c.FirstPkg] = true
-				packages[c.SecondPkg] = true
+			for _, conflict := range conflictList {
+				packages[conflict.FirstPkg] = true
+				packages[conflict.SecondPkg] = true
 			}
 
 			pkgList := make([]string, 0, len(packages))
@@ -496,15 +675,15 @@ func (c *Client) linkParallel(installQueue []*RemoteFormula, operation string) e
 				pkgList = append(pkgList, pkg)
 			}
 
-			fmt.Printf("  • Binary '%s' - packages: %s\n", binary, strings.Join(pkgList, ", "))
+			c.printf("  • Binary '%s' - packages: %s\n", binary, strings.Join(pkgList, ", "))
 		}
 
-		fmt.Println("\n💡 To resolve conflicts, run:")
+		c.println("\n💡 To resolve conflicts, run:")
 		for binary, conflictList := range conflictsByBinary {
 			if len(conflictList) > 0 {
-				c := conflictList[0]
-				fmt.Printf("  • brew unlink %s && fastbrew link %s  (for binary '%s')\n",
-					c.FirstPkg, c.SecondPkg, binary)
+				conflict := conflictList[0]
+				c.printf("  • brew unlink %s && fastbrew link %s  (for binary '%s')\n",
+					conflict.FirstPkg, conflict.SecondPkg, binary)
 			}
 		}
 	}
@@ -531,16 +710,17 @@ func (c *Client) isInstalled(name string) bool {
 
 // ResolveDeps returns a list of recursive dependencies for the given packages using the cached Index
 func (c *Client) ResolveDeps(packages []string) ([]string, error) {
-	idx, err := c.LoadIndex()
+	return c.ResolveDepsWithOptions(packages, DepsOptions{})
+}
+
+// ResolveDepsWithOptions is ResolveDeps following the dependency types opts
+// selects. InstalledOnly is ignored.
+func (c *Client) ResolveDepsWithOptions(packages []string, opts DepsOptions) ([]string, error) {
+	lookup, err := c.formulaLookup()
 	if err != nil {
 		return nil, fmt.Errorf("failed to load index for dependency resolution: %w", err)
 	}
 
-	formulaMap := make(map[string]Formula)
-	for _, f := range idx.Formulae {
-		formulaMap[f.Name] = f
-	}
-
 	visited := make(map[string]bool)
 	var deps []string
 
@@ -551,14 +731,14 @@ func (c *Client) ResolveDeps(packages []string) ([]string, error) {
 		}
 		visited[name] = true
 
-		f, exists := formulaMap[name]
+		f, exists := lookup(name)
 		if !exists {
 			return
 		}
 
-		for _, dep := range f.Dependencies {
-			resolve(dep)
-			deps = append(deps, dep)
+		for _, dep := range f.depList(opts) {
+			resolve(dep.name)
+			deps = append(deps, dep.name)
 		}
 	}
 
@@ -569,16 +749,43 @@ func (c *Client) ResolveDeps(packages []string) ([]string, error) {
 	return unique(deps), nil
 }
 
+// directDepsLookup returns a resolver for a formula's direct dependencies.
+// An index already held in memory is used as-is; otherwise lookups go to the
+// index database, falling back to loading the full index.
+func (c *Client) directDepsLookup() (func(name string) ([]string, bool), error) {
+	if c.index == nil {
+		if db, err := c.OpenIndexDB(); err == nil {
+			return func(name string) ([]string, bool) {
+				deps, err := db.Dependencies(name)
+				return deps, err == nil
+			}, nil
+		}
+	}
+
+	idx, err := c.LoadIndex()
+	if err != nil {
+		return nil, err
+	}
+	formulaMap := make(map[string][]string, len(idx.Formulae))
+	for _, f := range idx.Formulae {
+		formulaMap[f.Name] = f.Dependencies
+	}
+	return func(name string) ([]string, bool) {
+		deps, ok := formulaMap[name]
+		return deps, ok
+	}, nil
+}
+
 // UpgradeParallel identifies outdated packages and upgrades them natively
 func (c *Client) UpgradeParallel(packages []string) error {
-	fmt.Println("🔍 Checking for outdated packages...")
+	c.println("🔍 Checking for outdated packages...")
 	outdated, err := c.GetOutdated()
 	if err != nil {
 		return err
 	}
 
 	if len(outdated) == 0 {
-		fmt.Println("✅ All packages are up to date.")
+		c.println("✅ All packages are up to date.")
 		return nil
 	}
 
@@ -587,18 +794,22 @@ func (c *Client) UpgradeParallel(packages []string) error {
 		outdatedNames = append(outdatedNames, pkg.Name)
 	}
 
-	fmt.Printf("📦 Found %d packages to upgrade. Fetching in parallel...\n", len(outdatedNames))
+	c.printf("📦 Found %d packages to upgrade. Fetching in parallel...\n", len(outdatedNames))
 
 	var wg sync.WaitGroup
-	sem := make(chan struct{}, c.getMaxParallel())
+	sched := c.scheduler()
 	fetchErrChan := make(chan error, len(outdatedNames))
 	for _, pkg := range outdatedNames {
 		wg.Add(1)
 		go func(p string) {
 			defer wg.Done()
-			sem <- struct{}{}
-			defer func() { <-sem }()
-			fmt.Printf("  ⬇️  Fetching update for %s...\n", p)
+			release, err := sched.Acquire(context.Background())
+			if err != nil {
+				fetchErrChan <- err
+				return
+			}
+			defer release()
+			c.printf("  ⬇️  Fetching update for %s...\n", p)
 			if err := c.Fetch(p); err != nil {
 				fetchErrChan <- fmt.Errorf("failed to fetch %s: %w", p, err)
 			}
@@ -611,7 +822,15 @@ func (c *Client) UpgradeParallel(packages []string) error {
 		return err
 	}
 
-	return c.UpgradeNative(nil, outdated)
+	return c.UpgradeNative(context.Background(), nil, outdated)
+}
+
+func formulaNames(formulae []*RemoteFormula) []string {
+	names := make([]string, len(formulae))
+	for i, f := range formulae {
+		names[i] = f.Name
+	}
+	return names
 }
 
 func unique(slice []string) []string {
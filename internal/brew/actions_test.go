@@ -143,8 +143,8 @@ func TestClient_ResolveDeps(t *testing.T) {
 			},
 		},
 	}
-	// Manually set indexOnce to loaded state if needed, but here we just set c.index directly
-	client.indexOnce.Do(func() {}) // Mark as done
+	// Manually mark the index as loaded if needed, but here we just set c.index directly
+	client.indexLoaded = true // Mark as done
 
 	deps, err := client.ResolveDeps([]string{"a"})
 	if err != nil {
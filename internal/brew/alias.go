@@ -0,0 +1,70 @@
+package brew
+
+// otherNames returns the aliases and old names of f, aliases first.
+func (f Formula) otherNames() []string {
+	if len(f.OldNames) == 0 {
+		return f.Aliases
+	}
+	return append(append([]string{}, f.Aliases...), f.OldNames...)
+}
+
+// formulaAliases maps the aliases and old names of the indexed formulae to
+// their current names. Where two formulae claim the same name the first
+// one wins, and formula names are never shadowed.
+func (idx *Index) formulaAliases() map[string]string {
+	names := make(map[string]bool, len(idx.Formulae))
+	for _, f := range idx.Formulae {
+		names[f.Name] = true
+	}
+	aliases := make(map[string]string)
+	for _, f := range idx.Formulae {
+		for _, alias := range f.otherNames() {
+			if _, ok := aliases[alias]; !ok && !names[alias] {
+				aliases[alias] = f.Name
+			}
+		}
+	}
+	return aliases
+}
+
+// ResolveFormulaName returns the current name of the formula name is an
+// alias or old name of, such as python@3.13 for "python", and whether name
+// was one. Other names are returned unchanged.
+func (c *Client) ResolveFormulaName(name string) (string, bool) {
+	if c.index == nil {
+		if db, err := c.OpenIndexDB(); err == nil {
+			if db.HasFormula(name) {
+				return name, false
+			}
+			if canonical, ok := db.ResolveAlias(name); ok {
+				return canonical, true
+			}
+			return name, false
+		}
+	}
+	idx, err := c.LoadIndex()
+	if err != nil {
+		return name, false
+	}
+	if canonical, ok := idx.formulaAliases()[name]; ok {
+		return canonical, true
+	}
+	return name, false
+}
+
+// resolveInstallAliases replaces the aliases and old names among packages
+// with the formulae they stand for. Names of formulae and casks are kept.
+func (c *Client) resolveInstallAliases(packages []string) []string {
+	resolved := make([]string, len(packages))
+	for i, pkg := range packages {
+		resolved[i] = pkg
+		if _, isCask := c.LookupCask(pkg); isCask {
+			continue
+		}
+		if canonical, ok := c.ResolveFormulaName(pkg); ok {
+			c.printf("➡️  %s is %s\n", pkg, canonical)
+			resolved[i] = canonical
+		}
+	}
+	return resolved
+}
@@ -0,0 +1,116 @@
+package brew
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func aliasTestFormulae() []Formula {
+	return []Formula{
+		{Name: "python@3.13", Aliases: []string{"python", "python3"}, Versions: FormulaVersions{Stable: "3.13.1"}},
+		{Name: "python@3.12", Aliases: []string{"python3"}, Versions: FormulaVersions{Stable: "3.12.8"}},
+		{Name: "podman", OldNames: []string{"podman-remote"}, Versions: FormulaVersions{Stable: "5.3.1"}},
+		{Name: "jq", OldNames: []string{"podman"}},
+	}
+}
+
+func TestResolveFormulaName(t *testing.T) {
+	client := newDepsTestClient(t, aliasTestFormulae())
+
+	tests := []struct {
+		name, want string
+		resolved   bool
+	}{
+		{"python", "python@3.13", true},
+		{"python3", "python@3.13", true},
+		{"podman-remote", "podman", true},
+		{"podman", "podman", false},
+		{"wget", "wget", false},
+	}
+	for _, tt := range tests {
+		got, resolved := client.ResolveFormulaName(tt.name)
+		if got != tt.want || resolved != tt.resolved {
+			t.Errorf("ResolveFormulaName(%q) = %q, %v; want %q, %v", tt.name, got, resolved, tt.want, tt.resolved)
+		}
+	}
+
+	lookup, err := client.formulaLookup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f, ok := lookup("python"); !ok || f.Name != "python@3.13" {
+		t.Errorf("formulaLookup(python) = %v, %v", f, ok)
+	}
+
+	got := client.resolveInstallAliases([]string{"python", "jq", "podman-remote"})
+	if want := []string{"python@3.13", "jq", "podman"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("resolveInstallAliases = %v, want %v", got, want)
+	}
+}
+
+func TestIndexDBAliases(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.db")
+	var formulae []formulaRecord
+	for _, f := range aliasTestFormulae() {
+		formulae = append(formulae, formulaRecord{Formula: f})
+	}
+	if err := BuildIndexDB(path, formulae, nil); err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenIndexDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if name, ok := db.ResolveAlias("python3"); !ok || name != "python@3.13" {
+		t.Errorf("ResolveAlias(python3) = %q, %v", name, ok)
+	}
+	f, err := db.Formula("podman-remote")
+	if err != nil || f.Name != "podman" {
+		t.Errorf("Formula(podman-remote) = %v, %v", f, err)
+	}
+	if f, err := db.Formula("podman"); err != nil || f.Name != "podman" {
+		t.Errorf("a formula name must not resolve as another formula's old name, got %v, %v", f, err)
+	}
+
+	items, err := db.SearchItems()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ranked := rankSearch("python", items, SearchOptions{}); len(ranked) == 0 || ranked[0].Name != "python@3.13" {
+		t.Errorf("expected the python alias to rank python@3.13 first, got %v", ranked)
+	}
+}
+
+func TestGetOutdatedRenamedFormula(t *testing.T) {
+	prefix := t.TempDir()
+	cellar := filepath.Join(prefix, "Cellar")
+	if err := os.MkdirAll(filepath.Join(cellar, "podman-remote", "4.9.0"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	client := &Client{Prefix: prefix, Cellar: cellar, index: &Index{Formulae: aliasTestFormulae()}}
+	client.indexLoaded = true
+
+	outdated, err := client.GetOutdated()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outdated) != 1 || outdated[0].Name != "podman-remote" || outdated[0].NewVersion != "5.3.1" || outdated[0].RenamedTo != "podman" {
+		t.Fatalf("unexpected outdated packages %+v", outdated)
+	}
+
+	// Once installed under the new name, the old keg is not reported.
+	if err := os.MkdirAll(filepath.Join(cellar, "podman", "5.3.1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	outdated, err = client.GetOutdated()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outdated) != 0 {
+		t.Errorf("expected nothing outdated, got %+v", outdated)
+	}
+}
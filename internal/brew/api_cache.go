@@ -0,0 +1,106 @@
+package brew
+
+import (
+	"context"
+	"fastbrew/internal/atomicfile"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// apiCacheTTL is how long a cached formula or cask response is used
+// without asking the API whether it changed.
+const apiCacheTTL = 24 * time.Hour
+
+// apiCachePath returns where the response for one formula or cask is
+// kept, or "" when name cannot be cached (tap formulae are not served by
+// the API).
+func (c *Client) apiCachePath(kind, name string) string {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return ""
+	}
+	cacheDir, err := c.GetCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(cacheDir, "api", kind, name+".json")
+}
+
+// fetchAPIJSON returns the body and status of a GET of url, the JSON API
+// document for the formula or cask name. Successful responses are cached
+// under the cache dir: a response checked within apiCacheTTL is returned
+// from disk, and an older one is revalidated with its ETag, so the same
+// metadata is downloaded once a day at most. DisableAPICache skips the
+// cache entirely.
+func (c *Client) fetchAPIJSON(ctx context.Context, url, kind, name string) ([]byte, int, error) {
+	path := ""
+	if !c.DisableAPICache {
+		path = c.apiCachePath(kind, name)
+	}
+
+	var cached []byte
+	var meta indexCacheMetadata
+	if path != "" {
+		meta = loadIndexCacheMetadata(path + ".meta.json")
+		if data, err := os.ReadFile(path); err == nil {
+			cached = data
+			if time.Since(meta.CheckedAt) < apiCacheTTL {
+				c.logger().Debug("api cache hit", "kind", kind, "name", name)
+				return cached, http.StatusOK, nil
+			}
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if cached != nil {
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
+
+	resp, err := c.scheduler().Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		c.logger().Debug("api cache revalidated", "kind", kind, "name", name)
+		c.saveAPICacheMetadata(path, meta, resp.Header)
+		return cached, http.StatusOK, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode, nil
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, kindErrorf(KindNetwork, "failed to read response body: %w", err)
+	}
+	if path != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+			if err := atomicfile.WriteFile(path, data, 0644); err == nil {
+				c.saveAPICacheMetadata(path, indexCacheMetadata{}, resp.Header)
+			}
+		}
+	}
+	return data, http.StatusOK, nil
+}
+
+func (c *Client) saveAPICacheMetadata(path string, meta indexCacheMetadata, header http.Header) {
+	meta.ETag = coalesceHeader(header.Get("ETag"), meta.ETag)
+	meta.LastModified = coalesceHeader(header.Get("Last-Modified"), meta.LastModified)
+	meta.CheckedAt = time.Now()
+	if err := saveIndexCacheMetadata(path+".meta.json", meta); err != nil && c.Verbose {
+		c.printf("⚠️  Failed to save API cache metadata: %v\n", err)
+	}
+}
@@ -0,0 +1,106 @@
+package brew
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newAPIServer(t *testing.T, body string) (*httptest.Server, *int32, *int32) {
+	t.Helper()
+	var requests, notModified int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests, &notModified
+}
+
+func TestFetchAPIJSONCachesResponses(t *testing.T) {
+	server, requests, _ := newAPIServer(t, `{"name":"wget"}`)
+	c := &Client{CacheDir: t.TempDir()}
+
+	for i := 0; i < 2; i++ {
+		data, status, err := c.fetchAPIJSON(context.Background(), server.URL+"/wget.json", "formula", "wget")
+		if err != nil || status != http.StatusOK {
+			t.Fatalf("fetch %d: status %d, err %v", i, status, err)
+		}
+		if string(data) != `{"name":"wget"}` {
+			t.Fatalf("fetch %d: body = %q", i, data)
+		}
+	}
+	if got := atomic.LoadInt32(requests); got != 1 {
+		t.Fatalf("requests = %d, want 1", got)
+	}
+}
+
+func TestFetchAPIJSONRevalidatesStaleEntries(t *testing.T) {
+	server, requests, notModified := newAPIServer(t, `{"name":"wget"}`)
+	c := &Client{CacheDir: t.TempDir()}
+
+	if _, _, err := c.fetchAPIJSON(context.Background(), server.URL+"/wget.json", "formula", "wget"); err != nil {
+		t.Fatal(err)
+	}
+	path := c.apiCachePath("formula", "wget")
+	meta := loadIndexCacheMetadata(path + ".meta.json")
+	meta.CheckedAt = time.Now().Add(-2 * apiCacheTTL)
+	if err := saveIndexCacheMetadata(path+".meta.json", meta); err != nil {
+		t.Fatal(err)
+	}
+
+	data, status, err := c.fetchAPIJSON(context.Background(), server.URL+"/wget.json", "formula", "wget")
+	if err != nil || status != http.StatusOK || string(data) != `{"name":"wget"}` {
+		t.Fatalf("revalidated fetch: %q, status %d, err %v", data, status, err)
+	}
+	if got := atomic.LoadInt32(requests); got != 2 {
+		t.Fatalf("requests = %d, want 2", got)
+	}
+	if got := atomic.LoadInt32(notModified); got != 1 {
+		t.Fatalf("304 responses = %d, want 1", got)
+	}
+	if meta := loadIndexCacheMetadata(path + ".meta.json"); time.Since(meta.CheckedAt) > time.Minute {
+		t.Errorf("CheckedAt not refreshed: %v", meta.CheckedAt)
+	}
+}
+
+func TestFetchAPIJSONDisabled(t *testing.T) {
+	server, requests, _ := newAPIServer(t, `{"token":"firefox"}`)
+	c := &Client{CacheDir: t.TempDir(), DisableAPICache: true}
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := c.fetchAPIJSON(context.Background(), server.URL+"/firefox.json", "cask", "firefox"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := atomic.LoadInt32(requests); got != 2 {
+		t.Fatalf("requests = %d, want 2", got)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(c.CacheDir, "api", "*", "*")); len(matches) != 0 {
+		t.Errorf("cache files written with the cache disabled: %v", matches)
+	}
+}
+
+func TestFetchAPIJSONDoesNotCacheErrors(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(server.Close)
+	c := &Client{CacheDir: t.TempDir()}
+
+	_, status, err := c.fetchAPIJSON(context.Background(), server.URL+"/nope.json", "formula", "nope")
+	if err != nil || status != http.StatusNotFound {
+		t.Fatalf("status %d, err %v, want 404", status, err)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(c.CacheDir, "api", "formula", "*")); len(matches) != 0 {
+		t.Errorf("error response cached: %v", matches)
+	}
+}
@@ -0,0 +1,528 @@
+package brew
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// AttestationAPIURL lists GitHub build provenance attestations by subject digest.
+	AttestationAPIURL = "https://api.github.com/repos/Homebrew/homebrew-core/attestations"
+
+	// attestationSignerPrefix is the identity of the homebrew-core workflow
+	// that publishes and attests bottles, on any of its branches.
+	attestationSignerPrefix = "https://github.com/Homebrew/homebrew-core/.github/workflows/publish-commit-bottles.yml@refs/heads/"
+
+	// attestationIssuer is the OIDC issuer Fulcio saw the workflow's token from.
+	attestationIssuer = "https://token.actions.githubusercontent.com"
+
+	inTotoPayloadType = "application/vnd.in-toto+json"
+)
+
+// ErrAttestationVerification is matched by every AttestationError.
+var ErrAttestationVerification = errors.New("attestation verification failed")
+
+// AttestationError describes why a bottle's provenance could not be verified.
+type AttestationError struct {
+	Name   string
+	Digest string
+	Reason string
+}
+
+func (e AttestationError) Error() string {
+	return fmt.Sprintf("attestation verification failed for %s (sha256:%s): %s", e.Name, e.Digest, e.Reason)
+}
+
+func (e AttestationError) Is(target error) bool {
+	return target == ErrAttestationVerification
+}
+
+type attestationResponse struct {
+	Attestations []struct {
+		Bundle sigstoreBundle `json:"bundle"`
+	} `json:"attestations"`
+}
+
+type sigstoreBundle struct {
+	MediaType            string `json:"mediaType"`
+	VerificationMaterial struct {
+		TlogEntries []tlogEntry `json:"tlogEntries"`
+		Certificate *struct {
+			RawBytes string `json:"rawBytes"`
+		} `json:"certificate,omitempty"`
+		X509CertificateChain *struct {
+			Certificates []struct {
+				RawBytes string `json:"rawBytes"`
+			} `json:"certificates"`
+		} `json:"x509CertificateChain,omitempty"`
+	} `json:"verificationMaterial"`
+	DSSEEnvelope *dsseEnvelope `json:"dsseEnvelope"`
+}
+
+// tlogEntry is the bundle's record of the signature in the Rekor log.
+type tlogEntry struct {
+	LogIndex string `json:"logIndex"`
+	LogID    struct {
+		KeyID string `json:"keyId"`
+	} `json:"logId"`
+	KindVersion struct {
+		Kind    string `json:"kind"`
+		Version string `json:"version"`
+	} `json:"kindVersion"`
+	IntegratedTime   string `json:"integratedTime"`
+	InclusionPromise *struct {
+		SignedEntryTimestamp string `json:"signedEntryTimestamp"`
+	} `json:"inclusionPromise"`
+	CanonicalizedBody string `json:"canonicalizedBody"`
+}
+
+// rekorDSSEBody is the canonicalized body of a dsse v0.0.1 Rekor entry.
+type rekorDSSEBody struct {
+	Kind string `json:"kind"`
+	Spec struct {
+		PayloadHash struct {
+			Algorithm string `json:"algorithm"`
+			Value     string `json:"value"`
+		} `json:"payloadHash"`
+		Signatures []struct {
+			Signature string `json:"signature"`
+			Verifier  string `json:"verifier"`
+		} `json:"signatures"`
+	} `json:"spec"`
+}
+
+type dsseEnvelope struct {
+	Payload     string `json:"payload"`
+	PayloadType string `json:"payloadType"`
+	Signatures  []struct {
+		Sig string `json:"sig"`
+	} `json:"signatures"`
+}
+
+type inTotoStatement struct {
+	Type          string `json:"_type"`
+	PredicateType string `json:"predicateType"`
+	Subject       []struct {
+		Name   string            `json:"name"`
+		Digest map[string]string `json:"digest"`
+	} `json:"subject"`
+}
+
+// VerifyBottleAttestation fetches the GitHub build provenance attestations for
+// a bottle digest and checks that at least one is a valid DSSE envelope whose
+// in-toto subject matches the digest. The envelope must be signed by a
+// certificate the Sigstore public-good Fulcio CA issued to the homebrew-core
+// bottle workflow, and recorded in the Rekor log while the certificate was
+// valid.
+func (c *Client) VerifyBottleAttestation(ctx context.Context, name, digest string) error {
+	trust, err := publicGoodTrustRoot()
+	if err != nil {
+		return AttestationError{Name: name, Digest: digest, Reason: err.Error()}
+	}
+	bundles, err := c.fetchAttestationBundles(ctx, digest)
+	if err != nil {
+		return AttestationError{Name: name, Digest: digest, Reason: err.Error()}
+	}
+	if len(bundles) == 0 {
+		return AttestationError{Name: name, Digest: digest, Reason: "no attestations published"}
+	}
+
+	var lastErr error
+	for _, bundle := range bundles {
+		if lastErr = verifyAttestationBundle(bundle, digest, trust); lastErr == nil {
+			return nil
+		}
+	}
+	return AttestationError{Name: name, Digest: digest, Reason: lastErr.Error()}
+}
+
+func (c *Client) fetchAttestationBundles(ctx context.Context, digest string) ([]sigstoreBundle, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	url := fmt.Sprintf("%s/sha256:%s", AttestationAPIURL, digest)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if token := githubAPIToken(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.scheduler().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch attestations: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, kindErrorf(KindNetwork, "attestation api returned %s", resp.Status)
+	}
+
+	var result attestationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse attestations: %w", err)
+	}
+
+	bundles := make([]sigstoreBundle, 0, len(result.Attestations))
+	for _, a := range result.Attestations {
+		bundles = append(bundles, a.Bundle)
+	}
+	return bundles, nil
+}
+
+func githubAPIToken() string {
+	for _, key := range []string{"HOMEBREW_GITHUB_API_TOKEN", "GITHUB_TOKEN"} {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// verifyAttestationBundle checks a single Sigstore bundle against the expected
+// bottle digest: the signing certificate chains to trust's CA and names the
+// bottle workflow, the envelope is signed with it, and the Rekor entry
+// covering the signature was logged while the certificate was valid.
+func verifyAttestationBundle(bundle sigstoreBundle, digest string, trust *sigstoreTrustRoot) error {
+	env := bundle.DSSEEnvelope
+	if env == nil {
+		return fmt.Errorf("bundle has no DSSE envelope")
+	}
+	if env.PayloadType != inTotoPayloadType {
+		return fmt.Errorf("unexpected payload type %q", env.PayloadType)
+	}
+	if len(env.Signatures) == 0 {
+		return fmt.Errorf("envelope has no signatures")
+	}
+
+	cert, err := bundleLeafCertificate(bundle)
+	if err != nil {
+		return err
+	}
+	if !certificateHasSigner(cert, attestationSignerPrefix) {
+		return fmt.Errorf("certificate is not issued to the homebrew-core bottle workflow")
+	}
+	if issuer := certificateIssuer(cert); issuer != attestationIssuer {
+		return fmt.Errorf("certificate was issued for a token from %q, not %s", issuer, attestationIssuer)
+	}
+	pub, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("unsupported certificate key type %T", cert.PublicKey)
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(env.Payload)
+	if err != nil {
+		return fmt.Errorf("invalid envelope payload: %w", err)
+	}
+
+	digestPAE := hashForCurve(pub)
+	digestPAE.Write(dssePAE(env.PayloadType, payload))
+	sum := digestPAE.Sum(nil)
+
+	var signature string
+	for _, s := range env.Signatures {
+		sig, err := base64.StdEncoding.DecodeString(s.Sig)
+		if err != nil {
+			continue
+		}
+		if ecdsa.VerifyASN1(pub, sum, sig) {
+			signature = s.Sig
+			break
+		}
+	}
+	if signature == "" {
+		return fmt.Errorf("envelope signature does not match certificate")
+	}
+
+	signedAt, err := trust.verifyTlogEntries(bundle.VerificationMaterial.TlogEntries, cert, payload, signature)
+	if err != nil {
+		return err
+	}
+	if err := trust.verifyCertificate(cert, signedAt); err != nil {
+		return err
+	}
+
+	var stmt inTotoStatement
+	if err := json.Unmarshal(payload, &stmt); err != nil {
+		return fmt.Errorf("invalid in-toto statement: %w", err)
+	}
+	for _, subject := range stmt.Subject {
+		if strings.EqualFold(subject.Digest["sha256"], digest) {
+			return nil
+		}
+	}
+	return fmt.Errorf("no attestation subject matches bottle digest")
+}
+
+func bundleLeafCertificate(bundle sigstoreBundle) (*x509.Certificate, error) {
+	var raw string
+	vm := bundle.VerificationMaterial
+	if vm.Certificate != nil {
+		raw = vm.Certificate.RawBytes
+	} else if vm.X509CertificateChain != nil && len(vm.X509CertificateChain.Certificates) > 0 {
+		raw = vm.X509CertificateChain.Certificates[0].RawBytes
+	}
+	if raw == "" {
+		return nil, fmt.Errorf("bundle has no signing certificate")
+	}
+
+	der, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate encoding: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing certificate: %w", err)
+	}
+	return cert, nil
+}
+
+func certificateHasSigner(cert *x509.Certificate, prefix string) bool {
+	for _, uri := range cert.URIs {
+		if strings.HasPrefix(uri.String(), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// oidFulcioIssuer and oidFulcioIssuerV2 are the Fulcio certificate
+// extensions naming the OIDC issuer, as a raw string and DER-encoded.
+var (
+	oidFulcioIssuer   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidFulcioIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// certificateIssuer returns the OIDC issuer Fulcio recorded in cert, or ""
+// when it has none.
+func certificateIssuer(cert *x509.Certificate) string {
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidFulcioIssuerV2):
+			var issuer string
+			if _, err := asn1.UnmarshalWithParams(ext.Value, &issuer, "utf8"); err == nil {
+				return issuer
+			}
+		case ext.Id.Equal(oidFulcioIssuer):
+			return string(ext.Value)
+		}
+	}
+	return ""
+}
+
+func hashForCurve(pub *ecdsa.PublicKey) hash.Hash {
+	if pub.Curve.Params().BitSize > 256 {
+		return sha512.New384()
+	}
+	return sha256.New()
+}
+
+// dssePAE returns the DSSE pre-authentication encoding that signatures cover.
+func dssePAE(payloadType string, payload []byte) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "DSSEv1 %d %s %d ", len(payloadType), payloadType, len(payload))
+	buf.Write(payload)
+	return buf.Bytes()
+}
+
+// sigstoreTrustRoot is what attestations are checked against: the Fulcio
+// CA that issues the signing certificates and the Rekor log they are
+// recorded in.
+type sigstoreTrustRoot struct {
+	roots         *x509.CertPool
+	intermediates *x509.CertPool
+	rekorKey      *ecdsa.PublicKey
+	// rekorLogID is the SHA-256 of rekorKey's DER encoding, as Rekor
+	// identifies itself in entries.
+	rekorLogID []byte
+}
+
+// The Sigstore public-good instance's trust root, from its signed TUF
+// repository's trusted_root.json: the Fulcio root and intermediate in use
+// since April 2022 and the Rekor log key.
+const (
+	fulcioRootCertificate         = "MIIB9zCCAXygAwIBAgIUALZNAPFdxHPwjeDloDwyYChAO/4wCgYIKoZIzj0EAwMwKjEVMBMGA1UEChMMc2lnc3RvcmUuZGV2MREwDwYDVQQDEwhzaWdzdG9yZTAeFw0yMTEwMDcxMzU2NTlaFw0zMTEwMDUxMzU2NThaMCoxFTATBgNVBAoTDHNpZ3N0b3JlLmRldjERMA8GA1UEAxMIc2lnc3RvcmUwdjAQBgcqhkjOPQIBBgUrgQQAIgNiAAT7XeFT4rb3PQGwS4IajtLk3/OlnpgangaBclYpsYBr5i+4ynB07ceb3LP0OIOZdxexX69c5iVuyJRQ+Hz05yi+UF3uBWAlHpiS5sh0+H2GHE7SXrk1EC5m1Tr19L9gg92jYzBhMA4GA1UdDwEB/wQEAwIBBjAPBgNVHRMBAf8EBTADAQH/MB0GA1UdDgQWBBRYwB5fkUWlZql6zJChkyLQKsXF+jAfBgNVHSMEGDAWgBRYwB5fkUWlZql6zJChkyLQKsXF+jAKBggqhkjOPQQDAwNpADBmAjEAj1nHeXZp+13NWBNa+EDsDP8G1WWg1tCMWP/WHPqpaVo0jhsweNFZgSs0eE7wYI4qAjEA2WB9ot98sIkoF3vZYdd3/VtWB5b9TNMea7Ix/stJ5TfcLLeABLE4BNJOsQ4vnBHJ"
+	fulcioIntermediateCertificate = "MIICGjCCAaGgAwIBAgIUALnViVfnU0brJasmRkHrn/UnfaQwCgYIKoZIzj0EAwMwKjEVMBMGA1UEChMMc2lnc3RvcmUuZGV2MREwDwYDVQQDEwhzaWdzdG9yZTAeFw0yMjA0MTMyMDA2MTVaFw0zMTEwMDUxMzU2NThaMDcxFTATBgNVBAoTDHNpZ3N0b3JlLmRldjEeMBwGA1UEAxMVc2lnc3RvcmUtaW50ZXJtZWRpYXRlMHYwEAYHKoZIzj0CAQYFK4EEACIDYgAE8RVS/ysH+NOvuDZyPIZtilgUF9NlarYpAd9HP1vBBH1U5CV77LSS7s0ZiH4nE7Hv7ptS6LvvR/STk798LVgMzLlJ4HeIfF3tHSaexLcYpSASr1kS0N/RgBJz/9jWCiXno3sweTAOBgNVHQ8BAf8EBAMCAQYwEwYDVR0lBAwwCgYIKwYBBQUHAwMwEgYDVR0TAQH/BAgwBgEB/wIBADAdBgNVHQ4EFgQU39Ppz1YkEZb5qNjpKFWixi4YZD8wHwYDVR0jBBgwFoAUWMAeX5FFpWapesyQoZMi0CrFxfowCgYIKoZIzj0EAwMDZwAwZAIwPCsQK4DYiZYDPIaDi5HFKnfxXx6ASSVmERfsynYBiX2X6SJRnZU84/9DZdnFvvxmAjBOt6QpBlc4J/0DxvkTCqpclvziL6BCCPnjdlIB3Pu3BxsPmygUY7Ii2zbdCdliiow="
+	rekorPublicKey                = "MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAE2G2Y+2tabdTV5BcGiBIx0a9fAFwrkBbmLSGtks4L3qX6yYY0zufBnhC8Ur/iy55GhWP/9A/bY2LhC30M9+RYtw=="
+)
+
+var publicGoodTrustRoot = sync.OnceValues(func() (*sigstoreTrustRoot, error) {
+	root, err := parseBase64Certificate(fulcioRootCertificate)
+	if err != nil {
+		return nil, err
+	}
+	intermediate, err := parseBase64Certificate(fulcioIntermediateCertificate)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := base64.StdEncoding.DecodeString(rekorPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(keyDER)
+	if err != nil {
+		return nil, err
+	}
+	return newSigstoreTrustRoot([]*x509.Certificate{root}, []*x509.Certificate{intermediate}, key.(*ecdsa.PublicKey))
+})
+
+func newSigstoreTrustRoot(roots, intermediates []*x509.Certificate, rekorKey *ecdsa.PublicKey) (*sigstoreTrustRoot, error) {
+	keyDER, err := x509.MarshalPKIXPublicKey(rekorKey)
+	if err != nil {
+		return nil, err
+	}
+	logID := sha256.Sum256(keyDER)
+	trust := &sigstoreTrustRoot{
+		roots:         x509.NewCertPool(),
+		intermediates: x509.NewCertPool(),
+		rekorKey:      rekorKey,
+		rekorLogID:    logID[:],
+	}
+	for _, cert := range roots {
+		trust.roots.AddCert(cert)
+	}
+	for _, cert := range intermediates {
+		trust.intermediates.AddCert(cert)
+	}
+	return trust, nil
+}
+
+func parseBase64Certificate(raw string) (*x509.Certificate, error) {
+	der, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+// verifyCertificate checks that cert chains to the Fulcio CA for code
+// signing and was valid at signedAt. Fulcio certificates last minutes, so
+// the time comes from the Rekor entry rather than the clock.
+func (t *sigstoreTrustRoot) verifyCertificate(cert *x509.Certificate, signedAt time.Time) error {
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots:         t.roots,
+		Intermediates: t.intermediates,
+		CurrentTime:   signedAt,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	})
+	if err != nil {
+		return fmt.Errorf("signing certificate is not from the Sigstore CA: %w", err)
+	}
+	return nil
+}
+
+// verifyTlogEntries finds the Rekor entry recording signature over payload
+// by cert and returns when it was logged. The entry's signed entry
+// timestamp must verify against the pinned Rekor key.
+func (t *sigstoreTrustRoot) verifyTlogEntries(entries []tlogEntry, cert *x509.Certificate, payload []byte, signature string) (time.Time, error) {
+	if len(entries) == 0 {
+		return time.Time{}, fmt.Errorf("bundle has no transparency log entry")
+	}
+	var lastErr error
+	for _, entry := range entries {
+		var signedAt time.Time
+		if signedAt, lastErr = t.verifyTlogEntry(entry, cert, payload, signature); lastErr == nil {
+			return signedAt, nil
+		}
+	}
+	return time.Time{}, lastErr
+}
+
+func (t *sigstoreTrustRoot) verifyTlogEntry(entry tlogEntry, cert *x509.Certificate, payload []byte, signature string) (time.Time, error) {
+	logID, err := base64.StdEncoding.DecodeString(entry.LogID.KeyID)
+	if err != nil || !bytes.Equal(logID, t.rekorLogID) {
+		return time.Time{}, fmt.Errorf("transparency log entry is not from the Rekor log")
+	}
+	if entry.InclusionPromise == nil {
+		return time.Time{}, fmt.Errorf("transparency log entry has no signed entry timestamp")
+	}
+	integratedTime, err := strconv.ParseInt(entry.IntegratedTime, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid transparency log time %q", entry.IntegratedTime)
+	}
+	logIndex, err := strconv.ParseInt(entry.LogIndex, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid transparency log index %q", entry.LogIndex)
+	}
+
+	// Rekor signs the canonical JSON of these fields; json.Marshal sorts
+	// map keys, which is all canonicalisation asks of them.
+	promised, err := json.Marshal(map[string]any{
+		"body":           entry.CanonicalizedBody,
+		"integratedTime": integratedTime,
+		"logID":          hex.EncodeToString(logID),
+		"logIndex":       logIndex,
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	set, err := base64.StdEncoding.DecodeString(entry.InclusionPromise.SignedEntryTimestamp)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid signed entry timestamp: %w", err)
+	}
+	promisedSum := sha256.Sum256(promised)
+	if !ecdsa.VerifyASN1(t.rekorKey, promisedSum[:], set) {
+		return time.Time{}, fmt.Errorf("transparency log entry is not signed by Rekor")
+	}
+
+	if err := checkRekorBody(entry, cert, payload, signature); err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(integratedTime, 0), nil
+}
+
+// checkRekorBody makes sure the logged entry is this envelope's: the same
+// payload, signed with the same signature by the same certificate.
+func checkRekorBody(entry tlogEntry, cert *x509.Certificate, payload []byte, signature string) error {
+	if entry.KindVersion.Kind != "dsse" {
+		return fmt.Errorf("unsupported transparency log entry kind %q", entry.KindVersion.Kind)
+	}
+	raw, err := base64.StdEncoding.DecodeString(entry.CanonicalizedBody)
+	if err != nil {
+		return fmt.Errorf("invalid transparency log entry body: %w", err)
+	}
+	var body rekorDSSEBody
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return fmt.Errorf("invalid transparency log entry body: %w", err)
+	}
+	payloadSum := sha256.Sum256(payload)
+	if body.Kind != "dsse" || body.Spec.PayloadHash.Algorithm != "sha256" || !strings.EqualFold(body.Spec.PayloadHash.Value, hex.EncodeToString(payloadSum[:])) {
+		return fmt.Errorf("transparency log entry is for a different payload")
+	}
+	for _, s := range body.Spec.Signatures {
+		if s.Signature != signature {
+			continue
+		}
+		verifier, err := base64.StdEncoding.DecodeString(s.Verifier)
+		if err != nil {
+			continue
+		}
+		if block, _ := pem.Decode(verifier); block != nil && bytes.Equal(block.Bytes, cert.Raw) {
+			return nil
+		}
+	}
+	return fmt.Errorf("transparency log entry is for a different signature")
+}
@@ -0,0 +1,283 @@
+package brew
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// testSigstore is a stand-in for Fulcio and Rekor: a CA issuing signing
+// certificates and a log key signing entry timestamps.
+type testSigstore struct {
+	ca       *x509.Certificate
+	caKey    *ecdsa.PrivateKey
+	rekorKey *ecdsa.PrivateKey
+	trust    *sigstoreTrustRoot
+}
+
+func newTestSigstore(t *testing.T) *testSigstore {
+	t.Helper()
+	caKey := newTestKey(t)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "sigstore"},
+		NotBefore:             time.Now().Add(-24 * time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create CA certificate: %v", err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rekorKey := newTestKey(t)
+	trust, err := newSigstoreTrustRoot([]*x509.Certificate{ca}, nil, &rekorKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testSigstore{ca: ca, caKey: caKey, rekorKey: rekorKey, trust: trust}
+}
+
+func newTestKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	return key
+}
+
+// bundle returns an attestation for digest signed by a certificate the
+// CA issued to signerURI, and logged with a signed entry timestamp.
+func (s *testSigstore) bundle(t *testing.T, signerURI, digest string) sigstoreBundle {
+	t.Helper()
+
+	key := newTestKey(t)
+	uri, err := url.Parse(signerURI)
+	if err != nil {
+		t.Fatalf("Failed to parse signer URI: %v", err)
+	}
+	issuer, err := asn1.MarshalWithParams(attestationIssuer, "utf8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		NotBefore:       time.Now().Add(-5 * time.Minute),
+		NotAfter:        time.Now().Add(5 * time.Minute),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		URIs:            []*url.URL{uri},
+		ExtraExtensions: []pkix.Extension{{Id: oidFulcioIssuerV2, Value: issuer}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, s.ca, &key.PublicKey, s.caKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+
+	stmt := map[string]interface{}{
+		"_type":         "https://in-toto.io/Statement/v1",
+		"predicateType": "https://slsa.dev/provenance/v1",
+		"subject": []map[string]interface{}{
+			{"name": "wget--1.24.5.arm64_sonoma.bottle.tar.gz", "digest": map[string]string{"sha256": digest}},
+		},
+	}
+	payload, err := json.Marshal(stmt)
+	if err != nil {
+		t.Fatalf("Failed to encode statement: %v", err)
+	}
+	sum := sha256.Sum256(dssePAE(inTotoPayloadType, payload))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, sum[:])
+	if err != nil {
+		t.Fatalf("Failed to sign payload: %v", err)
+	}
+	encodedSig := base64.StdEncoding.EncodeToString(sig)
+
+	var bundle sigstoreBundle
+	bundle.VerificationMaterial.Certificate = &struct {
+		RawBytes string `json:"rawBytes"`
+	}{RawBytes: base64.StdEncoding.EncodeToString(der)}
+	bundle.DSSEEnvelope = &dsseEnvelope{
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		PayloadType: inTotoPayloadType,
+		Signatures: []struct {
+			Sig string `json:"sig"`
+		}{{Sig: encodedSig}},
+	}
+	bundle.VerificationMaterial.TlogEntries = []tlogEntry{s.logEntry(t, der, payload, encodedSig)}
+	return bundle
+}
+
+// logEntry is the Rekor dsse entry for an envelope, as the log would
+// return it.
+func (s *testSigstore) logEntry(t *testing.T, certDER, payload []byte, sig string) tlogEntry {
+	t.Helper()
+	payloadSum := sha256.Sum256(payload)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	body, err := json.Marshal(map[string]any{
+		"apiVersion": "0.0.1",
+		"kind":       "dsse",
+		"spec": map[string]any{
+			"payloadHash": map[string]string{"algorithm": "sha256", "value": hex.EncodeToString(payloadSum[:])},
+			"signatures":  []map[string]string{{"signature": sig, "verifier": base64.StdEncoding.EncodeToString(certPEM)}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var entry tlogEntry
+	entry.LogIndex = "42"
+	entry.LogID.KeyID = base64.StdEncoding.EncodeToString(s.trust.rekorLogID)
+	entry.KindVersion.Kind, entry.KindVersion.Version = "dsse", "0.0.1"
+	entry.IntegratedTime = strconv.FormatInt(time.Now().Unix(), 10)
+	entry.CanonicalizedBody = base64.StdEncoding.EncodeToString(body)
+	s.signEntry(t, &entry)
+	return entry
+}
+
+// signEntry gives entry a signed entry timestamp over its current fields.
+func (s *testSigstore) signEntry(t *testing.T, entry *tlogEntry) {
+	t.Helper()
+	integratedTime, _ := strconv.ParseInt(entry.IntegratedTime, 10, 64)
+	promised, err := json.Marshal(map[string]any{
+		"body":           entry.CanonicalizedBody,
+		"integratedTime": integratedTime,
+		"logID":          hex.EncodeToString(s.trust.rekorLogID),
+		"logIndex":       42,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(promised)
+	set, err := ecdsa.SignASN1(rand.Reader, s.rekorKey, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry.InclusionPromise = &struct {
+		SignedEntryTimestamp string `json:"signedEntryTimestamp"`
+	}{SignedEntryTimestamp: base64.StdEncoding.EncodeToString(set)}
+}
+
+func TestVerifyAttestationBundle(t *testing.T) {
+	const digest = "4f5e6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c1d2e3f4a5b6c7d8e9f0a1b2c3d4e5f"
+	const signer = "https://github.com/Homebrew/homebrew-core/.github/workflows/publish-commit-bottles.yml@refs/heads/master"
+	sigstore := newTestSigstore(t)
+
+	bundle := sigstore.bundle(t, signer, digest)
+	if err := verifyAttestationBundle(bundle, digest, sigstore.trust); err != nil {
+		t.Fatalf("Expected valid attestation, got %v", err)
+	}
+
+	if err := verifyAttestationBundle(bundle, "deadbeef", sigstore.trust); err == nil {
+		t.Error("Expected digest mismatch to fail verification")
+	}
+
+	for _, uri := range []string{
+		"https://github.com/evil/repo/.github/workflows/x.yml@refs/heads/main",
+		"https://github.com/Homebrew/homebrew-cask/.github/workflows/publish-commit-bottles.yml@refs/heads/master",
+		"https://github.com/Homebrew/homebrew-core/.github/workflows/tests.yml@refs/heads/master",
+	} {
+		untrusted := sigstore.bundle(t, uri, digest)
+		if err := verifyAttestationBundle(untrusted, digest, sigstore.trust); err == nil {
+			t.Errorf("Expected signer %s to fail verification", uri)
+		}
+	}
+
+	tampered := sigstore.bundle(t, signer, digest)
+	other := sigstore.bundle(t, signer, digest)
+	tampered.DSSEEnvelope.Signatures = other.DSSEEnvelope.Signatures
+	if err := verifyAttestationBundle(tampered, digest, sigstore.trust); err == nil {
+		t.Error("Expected signature from a different key to fail verification")
+	}
+}
+
+func TestVerifyAttestationBundleChecksTrustRoot(t *testing.T) {
+	const digest = "4f5e6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c1d2e3f4a5b6c7d8e9f0a1b2c3d4e5f"
+	const signer = "https://github.com/Homebrew/homebrew-core/.github/workflows/publish-commit-bottles.yml@refs/heads/main"
+	sigstore := newTestSigstore(t)
+
+	// A certificate from any other CA, self-signed included, is refused
+	// even when everything else checks out.
+	forged := newTestSigstore(t)
+	forged.rekorKey, forged.trust.rekorKey = sigstore.rekorKey, sigstore.trust.rekorKey
+	if err := verifyAttestationBundle(forged.bundle(t, signer, digest), digest, sigstore.trust); err == nil {
+		t.Error("Expected a certificate from another CA to fail verification")
+	}
+
+	unlogged := sigstore.bundle(t, signer, digest)
+	unlogged.VerificationMaterial.TlogEntries = nil
+	if err := verifyAttestationBundle(unlogged, digest, sigstore.trust); err == nil {
+		t.Error("Expected a bundle without a log entry to fail verification")
+	}
+
+	badPromise := sigstore.bundle(t, signer, digest)
+	badPromise.VerificationMaterial.TlogEntries[0].IntegratedTime = strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	if err := verifyAttestationBundle(badPromise, digest, sigstore.trust); err == nil {
+		t.Error("Expected an entry changed after Rekor signed it to fail verification")
+	}
+
+	// Logged outside the certificate's few minutes of validity.
+	late := sigstore.bundle(t, signer, digest)
+	late.VerificationMaterial.TlogEntries[0].IntegratedTime = strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	sigstore.signEntry(t, &late.VerificationMaterial.TlogEntries[0])
+	if err := verifyAttestationBundle(late, digest, sigstore.trust); err == nil {
+		t.Error("Expected an entry logged after the certificate expired to fail verification")
+	}
+
+	// A genuine log entry for a different envelope.
+	other := sigstore.bundle(t, signer, digest)
+	mixed := sigstore.bundle(t, signer, digest)
+	mixed.VerificationMaterial.TlogEntries = other.VerificationMaterial.TlogEntries
+	if err := verifyAttestationBundle(mixed, digest, sigstore.trust); err == nil {
+		t.Error("Expected a log entry for another envelope to fail verification")
+	}
+}
+
+func TestPublicGoodTrustRoot(t *testing.T) {
+	trust, err := publicGoodTrustRoot()
+	if err != nil {
+		t.Fatalf("Failed to load the pinned trust root: %v", err)
+	}
+	intermediate, err := parseBase64Certificate(fulcioIntermediateCertificate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := intermediate.Verify(x509.VerifyOptions{Roots: trust.roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+		t.Errorf("Fulcio intermediate does not chain to the pinned root: %v", err)
+	}
+	const rekorLogID = "wNI9atQGlz+VWfO6LRygH4QUfY/8W4RFwiT5i5WRgB0="
+	if got := base64.StdEncoding.EncodeToString(trust.rekorLogID); got != rekorLogID {
+		t.Errorf("Rekor log ID = %s, want %s", got, rekorLogID)
+	}
+}
+
+func TestAttestationErrorIs(t *testing.T) {
+	err := error(AttestationError{Name: "wget", Digest: "abc", Reason: "no attestations published"})
+	if !errors.Is(err, ErrAttestationVerification) {
+		t.Error("Expected AttestationError to match ErrAttestationVerification")
+	}
+	var attErr AttestationError
+	if !errors.As(err, &attErr) || attErr.Name != "wget" {
+		t.Errorf("Expected errors.As to extract AttestationError, got %+v", attErr)
+	}
+}
@@ -0,0 +1,283 @@
+package brew
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Benchmark tools and cache states, as reported in BenchmarkRun.
+const (
+	BenchmarkToolFastbrew = "fastbrew"
+	BenchmarkToolBrew     = "brew"
+	BenchmarkCacheCold    = "cold"
+	BenchmarkCacheWarm    = "warm"
+)
+
+// BenchmarkOptions configures Client.Benchmark.
+type BenchmarkOptions struct {
+	Deps DepsOptions
+	// BrewPath, when set, is the brew executable also timed installing the
+	// same formulae.
+	BrewPath string
+}
+
+// BenchmarkRun is one timed install of the benchmarked formulae into an
+// empty prefix.
+type BenchmarkRun struct {
+	Tool  string `json:"tool"`
+	Cache string `json:"cache"`
+	// Duration is nanoseconds in JSON.
+	Duration time.Duration `json:"duration_ns"`
+	// Kegs is how many formulae, dependencies included, were installed.
+	Kegs int `json:"kegs"`
+	// BytesTransferred is only measured for fastbrew runs.
+	BytesTransferred int64  `json:"bytes_transferred,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
+
+// BenchmarkReport is the result of Client.Benchmark.
+type BenchmarkReport struct {
+	Formulae []string       `json:"formulae"`
+	Runs     []BenchmarkRun `json:"runs"`
+}
+
+// Run returns the run of tool with the given cache state, if there is one.
+func (r *BenchmarkReport) Run(tool, cache string) (BenchmarkRun, bool) {
+	for _, run := range r.Runs {
+		if run.Tool == tool && run.Cache == cache {
+			return run, true
+		}
+	}
+	return BenchmarkRun{}, false
+}
+
+// Benchmark times installing names with fastbrew's native pipeline into a
+// throwaway prefix, first with an empty download cache and then again into
+// a fresh prefix reusing that cache. With opts.BrewPath, `brew install`
+// is timed the same way. The package index is loaded before timing starts
+// and everything is removed afterwards; the real prefix, cache and state
+// are never touched.
+//
+// Install reasons and reports are written under the state directory, so
+// XDG_STATE_HOME points into the scratch directory while Benchmark runs:
+// nothing else in the process may use the state directory meanwhile.
+func (c *Client) Benchmark(ctx context.Context, names []string, opts BenchmarkOptions) (*BenchmarkReport, error) {
+	if err := c.EnsureFreshJSONs(); err != nil {
+		return nil, err
+	}
+	// Cask artifacts are installed outside the prefix, e.g. into
+	// /Applications, so casks cannot be benchmarked in a scratch prefix.
+	for _, name := range names {
+		if cask, ok := c.LookupCask(name); ok {
+			return nil, fmt.Errorf("%s is a cask; only formulae can be benchmarked", cask.Token)
+		}
+	}
+	work, err := os.MkdirTemp("", "fastbrew-benchmark-")
+	if err != nil {
+		return nil, err
+	}
+	defer c.removeBenchmarkDir(work)
+
+	restore, err := setenvTemporarily("XDG_STATE_HOME", filepath.Join(work, "state"))
+	if err != nil {
+		return nil, err
+	}
+	defer restore()
+
+	cacheDir := filepath.Join(work, "cache")
+	if err := c.copyIndexTo(cacheDir); err != nil {
+		return nil, err
+	}
+
+	report := &BenchmarkReport{Formulae: names}
+	for _, cache := range []string{BenchmarkCacheCold, BenchmarkCacheWarm} {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		prefix := filepath.Join(work, "fastbrew-"+cache)
+		c.printf("⏱️  fastbrew, %s cache...\n", cache)
+		report.Runs = append(report.Runs, c.benchmarkFastbrew(ctx, names, opts.Deps, prefix, cacheDir, cache))
+	}
+
+	if opts.BrewPath != "" {
+		brewCache := filepath.Join(work, "brew-cache")
+		for _, cache := range []string{BenchmarkCacheCold, BenchmarkCacheWarm} {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			prefix := filepath.Join(work, "brew-"+cache)
+			c.printf("⏱️  brew, %s cache...\n", cache)
+			report.Runs = append(report.Runs, benchmarkBrew(ctx, opts.BrewPath, names, prefix, brewCache, cache))
+		}
+	}
+	return report, nil
+}
+
+// benchmarkFastbrew installs names into prefix with a client sharing c's
+// network settings and timing the install alone.
+func (c *Client) benchmarkFastbrew(ctx context.Context, names []string, deps DepsOptions, prefix, cacheDir, cache string) BenchmarkRun {
+	run := BenchmarkRun{Tool: BenchmarkToolFastbrew, Cache: cache}
+	if err := os.MkdirAll(filepath.Join(prefix, "Cellar"), 0755); err != nil {
+		run.Error = err.Error()
+		return run
+	}
+	bc := newClientAt(prefix)
+	bc.Out = io.Discard
+	bc.CacheDir = cacheDir
+	bc.TransactionDir = filepath.Join(prefix, "transactions")
+	// The user's hook scripts are not run; prefix has none.
+	bc.HooksDir = filepath.Join(prefix, "hooks")
+	bc.MaxParallel = c.MaxParallel
+	bc.RetryAttempts = c.RetryAttempts
+	bc.ExtractWorkers = c.ExtractWorkers
+	bc.Mirrors = c.Mirrors
+	bc.Credentials = c.Credentials
+	bc.Scheduler = c.Scheduler
+	bc.Logger = c.Logger
+	if _, err := bc.LoadIndex(); err != nil {
+		run.Error = err.Error()
+		return run
+	}
+	if _, err := bc.OpenIndexDB(); err != nil {
+		run.Error = err.Error()
+		return run
+	}
+
+	start := time.Now()
+	err := bc.InstallNativeWithOptions(ctx, names, InstallOptions{StrictNative: true, Deps: deps})
+	run.Duration = time.Since(start)
+	run.BytesTransferred = bc.transferred.Load()
+	run.Kegs = countKegs(bc.Cellar)
+	if err != nil {
+		run.Error = err.Error()
+	}
+	return run
+}
+
+// benchmarkBrew runs `brew install` through a link to brewPath inside
+// prefix. brew takes its prefix from where it is run from, so it installs
+// there, with its download cache in cacheDir.
+func benchmarkBrew(ctx context.Context, brewPath string, names []string, prefix, cacheDir, cache string) BenchmarkRun {
+	run := BenchmarkRun{Tool: BenchmarkToolBrew, Cache: cache}
+	target, err := filepath.EvalSymlinks(brewPath)
+	if err == nil {
+		err = os.MkdirAll(filepath.Join(prefix, "bin"), 0755)
+	}
+	brew := filepath.Join(prefix, "bin", "brew")
+	if err == nil {
+		err = os.Symlink(target, brew)
+	}
+	if err != nil {
+		run.Error = err.Error()
+		return run
+	}
+
+	cmd := exec.CommandContext(ctx, brew, append([]string{"install", "--formula"}, names...)...)
+	cmd.Env = append(withoutEnv(os.Environ(), "HOMEBREW_PREFIX", "HOMEBREW_CELLAR", "HOMEBREW_REPOSITORY", "HOMEBREW_CACHE"),
+		"HOMEBREW_CACHE="+cacheDir,
+		"HOMEBREW_NO_AUTO_UPDATE=1",
+		"HOMEBREW_NO_INSTALL_CLEANUP=1",
+		"HOMEBREW_NO_ANALYTICS=1",
+		"HOMEBREW_NO_ENV_HINTS=1",
+	)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	start := time.Now()
+	err = cmd.Run()
+	run.Duration = time.Since(start)
+	run.Kegs = countKegs(filepath.Join(prefix, "Cellar"))
+	if err != nil {
+		run.Error = err.Error()
+		if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); lines[len(lines)-1] != "" {
+			run.Error += ": " + lines[len(lines)-1]
+		}
+	}
+	return run
+}
+
+// copyIndexTo seeds dir with c's cached package index, so a benchmark
+// starting from an empty download cache does not also download the index.
+func (c *Client) copyIndexTo(dir string) error {
+	src, err := c.GetCacheDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, name := range []string{"formula.json.zst", "cask.json.zst", "formula.json.zst.meta.json", "cask.json.zst.meta.json"} {
+		data, err := os.ReadFile(filepath.Join(src, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func countKegs(cellar string) int {
+	entries, err := os.ReadDir(cellar)
+	if err != nil {
+		return 0
+	}
+	n := 0
+	for _, entry := range entries {
+		if entry.IsDir() && len(kegVersions(filepath.Join(cellar, entry.Name()))) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func withoutEnv(env []string, keys ...string) []string {
+	return slices.DeleteFunc(slices.Clone(env), func(kv string) bool {
+		key, _, _ := strings.Cut(kv, "=")
+		return slices.Contains(keys, key)
+	})
+}
+
+// setenvTemporarily sets key to value and returns a function restoring
+// its previous value.
+func setenvTemporarily(key, value string) (func(), error) {
+	old, had := os.LookupEnv(key)
+	if err := os.Setenv(key, value); err != nil {
+		return nil, err
+	}
+	return func() {
+		if had {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	}, nil
+}
+
+// removeBenchmarkDir removes dir, first making read-only directories
+// inside it (common in bottles) writable.
+func (c *Client) removeBenchmarkDir(dir string) {
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			os.Chmod(path, 0755)
+		}
+		return nil
+	})
+	if err := os.RemoveAll(dir); err != nil {
+		c.printf("⚠️  Failed to remove %s: %v\n", dir, err)
+	}
+}
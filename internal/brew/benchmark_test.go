@@ -0,0 +1,84 @@
+package brew
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newBenchmarkTestClient returns a client whose index has one formula,
+// hello, with a bottle served by a test server, and the bottle's size.
+func newBenchmarkTestClient(t *testing.T) (*Client, int64) {
+	t.Helper()
+	platform, err := GetPlatform()
+	if err != nil {
+		t.Skip(err)
+	}
+	bottle, err := os.ReadFile(writeTestBottle(t, t.TempDir(), "hello.tar.gz", "hello", "1.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, _ := newBottleServer(t, bottle)
+
+	cacheDir := t.TempDir()
+	formulae := `[{"name": "hello", "versions": {"stable": "1.0"},
+		"bottle": {"stable": {"files": {"` + platform + `": {"url": "` + srv.URL + `/hello.tar.gz", "sha256": "` + sha256Hex(bottle) + `"}}}}}]`
+	casks := `[{"token": "firefox", "version": "128.0"}]`
+	if err := os.WriteFile(filepath.Join(cacheDir, "formula.json.zst"), []byte(formulae), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(cacheDir, "cask.json.zst"), []byte(casks), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return &Client{Prefix: t.TempDir(), CacheDir: cacheDir, Out: io.Discard}, int64(len(bottle))
+}
+
+func TestBenchmarkColdAndWarm(t *testing.T) {
+	state := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", state)
+	c, size := newBenchmarkTestClient(t)
+
+	report, err := c.Benchmark(context.Background(), []string{"hello"}, BenchmarkOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cold, ok := report.Run(BenchmarkToolFastbrew, BenchmarkCacheCold)
+	if !ok || cold.Error != "" || cold.Kegs != 1 || cold.BytesTransferred != size {
+		t.Errorf("cold run = %+v, want 1 keg and %d bytes", cold, size)
+	}
+	warm, ok := report.Run(BenchmarkToolFastbrew, BenchmarkCacheWarm)
+	if !ok || warm.Error != "" || warm.Kegs != 1 || warm.BytesTransferred != 0 {
+		t.Errorf("warm run = %+v, want 1 keg from the cache", warm)
+	}
+	if _, ok := report.Run(BenchmarkToolBrew, BenchmarkCacheCold); ok {
+		t.Error("brew was timed without BrewPath")
+	}
+
+	if got := os.Getenv("XDG_STATE_HOME"); got != state {
+		t.Errorf("XDG_STATE_HOME = %q after the benchmark, want %q", got, state)
+	}
+	if entries, _ := os.ReadDir(state); len(entries) != 0 {
+		t.Errorf("benchmark wrote to the real state directory: %v", entries)
+	}
+	if _, err := os.Stat(filepath.Join(c.Prefix, "Cellar", "hello")); !os.IsNotExist(err) {
+		t.Errorf("benchmark installed into the real prefix: %v", err)
+	}
+}
+
+func TestBenchmarkRejectsCasks(t *testing.T) {
+	c, _ := newBenchmarkTestClient(t)
+	_, err := c.Benchmark(context.Background(), []string{"hello", "firefox"}, BenchmarkOptions{})
+	if err == nil || !strings.Contains(err.Error(), "firefox is a cask") {
+		t.Errorf("Benchmark() error = %v, want firefox rejected as a cask", err)
+	}
+}
+
+func TestWithoutEnv(t *testing.T) {
+	got := withoutEnv([]string{"HOMEBREW_PREFIX=/opt/homebrew", "PATH=/bin", "HOMEBREW_PREFIXED=x"}, "HOMEBREW_PREFIX")
+	if strings.Join(got, " ") != "PATH=/bin HOMEBREW_PREFIXED=x" {
+		t.Errorf("withoutEnv() = %v", got)
+	}
+}
@@ -0,0 +1,203 @@
+package brew
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// binaryHost describes the machine installed binaries must run on.
+type binaryHost struct {
+	goarch string
+	musl   bool
+}
+
+func currentBinaryHost() binaryHost {
+	host := binaryHost{goarch: runtime.GOARCH}
+	if runtime.GOOS == "linux" {
+		matches, _ := filepath.Glob("/lib/ld-musl-*.so.1")
+		host.musl = len(matches) > 0
+	}
+	return host
+}
+
+var errNotExecutable = errors.New("not a Mach-O or ELF binary")
+
+// inspectBinaryArch reads the Mach-O or ELF header of path and describes why
+// it cannot run on host, or returns "" when it can. Files that are neither
+// format return errNotExecutable.
+func inspectBinaryArch(path string, host binaryHost) (string, error) {
+	if fat, err := macho.OpenFat(path); err == nil {
+		defer fat.Close()
+		var archs []string
+		for _, arch := range fat.Arches {
+			if machoCPUMatches(arch.Cpu, host.goarch) {
+				return "", nil
+			}
+			archs = append(archs, machoCPUName(arch.Cpu))
+		}
+		return fmt.Sprintf("universal binary without %s (has %s)", archName(host.goarch), strings.Join(archs, ", ")), nil
+	}
+
+	if f, err := macho.Open(path); err == nil {
+		defer f.Close()
+		if machoCPUMatches(f.Cpu, host.goarch) {
+			return "", nil
+		}
+		return fmt.Sprintf("built for %s, host is %s", machoCPUName(f.Cpu), archName(host.goarch)), nil
+	}
+
+	f, err := elf.Open(path)
+	if err != nil {
+		return "", errNotExecutable
+	}
+	defer f.Close()
+
+	if want, ok := elfMachines[host.goarch]; ok && f.Machine != want {
+		return fmt.Sprintf("built for %s, host is %s", elfMachineName(f.Machine), archName(host.goarch)), nil
+	}
+
+	if host.musl {
+		interp := elfInterpreter(f)
+		if interp != "" && !strings.Contains(interp, "musl") {
+			if _, err := os.Stat(interp); err != nil {
+				return fmt.Sprintf("glibc binary on a musl system (loader %s not found)", interp), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+var elfMachines = map[string]elf.Machine{
+	"amd64": elf.EM_X86_64,
+	"arm64": elf.EM_AARCH64,
+	"386":   elf.EM_386,
+	"arm":   elf.EM_ARM,
+}
+
+func elfMachineName(m elf.Machine) string {
+	for goarch, machine := range elfMachines {
+		if machine == m {
+			return archName(goarch)
+		}
+	}
+	return m.String()
+}
+
+func elfInterpreter(f *elf.File) string {
+	for _, prog := range f.Progs {
+		if prog.Type != elf.PT_INTERP {
+			continue
+		}
+		data := make([]byte, prog.Filesz)
+		if _, err := prog.ReadAt(data, 0); err != nil {
+			return ""
+		}
+		return strings.TrimRight(string(data), "\x00")
+	}
+	return ""
+}
+
+func machoCPUMatches(cpu macho.Cpu, goarch string) bool {
+	switch goarch {
+	case "arm64":
+		return cpu == macho.CpuArm64
+	case "amd64":
+		return cpu == macho.CpuAmd64
+	}
+	return true
+}
+
+func machoCPUName(cpu macho.Cpu) string {
+	switch cpu {
+	case macho.CpuArm64:
+		return "arm64"
+	case macho.CpuAmd64:
+		return "x86_64"
+	}
+	return cpu.String()
+}
+
+// archName maps a GOARCH value to the name Homebrew uses in bottle tags.
+func archName(goarch string) string {
+	switch goarch {
+	case "amd64":
+		return "x86_64"
+	case "386":
+		return "i386"
+	}
+	return goarch
+}
+
+// currentKegVersion returns the linked version of an installed formula, or
+// its newest version when it is not linked.
+func (c *Client) currentKegVersion(name string) string {
+	if v := c.linkedVersion(name); v != "" {
+		return v
+	}
+	entries, err := os.ReadDir(filepath.Join(c.Cellar, name))
+	if err != nil {
+		return ""
+	}
+	var versions []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			versions = append(versions, entry.Name())
+		}
+	}
+	if len(versions) == 0 {
+		return ""
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versionCompare(versions[i], versions[j]) > 0
+	})
+	return versions[0]
+}
+
+// findArchMismatches checks the bin and sbin executables of every installed
+// keg and reports the first incompatible binary of each formula.
+func (c *Client) findArchMismatches(host binaryHost) []string {
+	entries, err := os.ReadDir(c.Cellar)
+	if err != nil {
+		return nil
+	}
+
+	var mismatches []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		version := c.currentKegVersion(name)
+		if version == "" {
+			continue
+		}
+
+	kegDirs:
+		for _, sub := range []string{"bin", "sbin"} {
+			dir := filepath.Join(c.Cellar, name, version, sub)
+			files, err := os.ReadDir(dir)
+			if err != nil {
+				continue
+			}
+			for _, file := range files {
+				if !file.Type().IsRegular() {
+					continue
+				}
+				problem, err := inspectBinaryArch(filepath.Join(dir, file.Name()), host)
+				if err != nil || problem == "" {
+					continue
+				}
+				mismatches = append(mismatches, fmt.Sprintf("%s %s: %s/%s %s", name, version, sub, file.Name(), problem))
+				break kegDirs
+			}
+		}
+	}
+	return mismatches
+}
@@ -0,0 +1,70 @@
+package brew
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func otherArch() string {
+	if runtime.GOARCH == "arm64" {
+		return "amd64"
+	}
+	return "arm64"
+}
+
+func TestInspectBinaryArch(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Skipf("cannot locate test binary: %v", err)
+	}
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("test binary is neither ELF nor Mach-O")
+	}
+
+	problem, err := inspectBinaryArch(exe, binaryHost{goarch: runtime.GOARCH})
+	if err != nil || problem != "" {
+		t.Errorf("native binary reported %q, %v", problem, err)
+	}
+
+	problem, err = inspectBinaryArch(exe, binaryHost{goarch: otherArch()})
+	if err != nil || !strings.Contains(problem, "built for "+archName(runtime.GOARCH)) {
+		t.Errorf("foreign host reported %q, %v", problem, err)
+	}
+
+	script := filepath.Join(t.TempDir(), "script")
+	os.WriteFile(script, []byte("#!/bin/sh\necho hi\n"), 0755)
+	if _, err := inspectBinaryArch(script, binaryHost{goarch: runtime.GOARCH}); !errors.Is(err, errNotExecutable) {
+		t.Errorf("script should not be inspected, got %v", err)
+	}
+}
+
+func TestFindArchMismatches(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil || (runtime.GOOS != "linux" && runtime.GOOS != "darwin") {
+		t.Skip("no native test binary available")
+	}
+
+	prefix := t.TempDir()
+	client := &Client{Prefix: prefix, Cellar: filepath.Join(prefix, "Cellar")}
+	binDir := filepath.Join(client.Cellar, "hello", "2.12", "bin")
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := copyFile(exe, filepath.Join(binDir, "hello")); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(binDir, "hello-wrapper"), []byte("#!/bin/sh\n"), 0755)
+
+	if got := client.findArchMismatches(binaryHost{goarch: runtime.GOARCH}); len(got) != 0 {
+		t.Errorf("expected no mismatches on native host, got %v", got)
+	}
+
+	got := client.findArchMismatches(binaryHost{goarch: otherArch()})
+	if len(got) != 1 || !strings.HasPrefix(got[0], "hello 2.12: bin/hello ") {
+		t.Errorf("expected hello to be flagged, got %v", got)
+	}
+}
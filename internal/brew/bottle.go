@@ -3,13 +3,14 @@ package brew
 import (
 	"archive/tar"
 	"bufio"
-	"compress/gzip"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
-	"fastbrew/internal/httpclient"
+	"fastbrew/internal/auth"
+	"fastbrew/internal/oci"
 	"fastbrew/internal/progress"
 	"fastbrew/internal/resume"
+	"fastbrew/internal/retry"
 	"fmt"
 	"io"
 	"math/rand/v2"
@@ -18,20 +19,21 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/klauspost/compress/gzip"
 	"github.com/klauspost/compress/zstd"
 )
 
 // DownloadBottle downloads the bottle for a formula and returns the path to the cached tarball.
-// It does not print any output.
-func (c *Client) DownloadBottle(f *RemoteFormula) (string, error) {
+// It does not print any output. Cancelling ctx stops the download, keeping
+// the partial file for the next attempt to resume.
+func (c *Client) DownloadBottle(ctx context.Context, f *RemoteFormula) (string, error) {
 	bottleURL, sha256Sum, err := f.GetBottleInfo()
 	if err != nil {
 		return "", err
 	}
-
-	cacheDir, _ := c.GetCacheDir()
-	tarPath := filepath.Join(cacheDir, fmt.Sprintf("%s-%s.bottle", f.Name, f.Versions.Stable))
+	bottleURL = c.mirrorURL(bottleURL)
 
 	var tracker progress.ProgressTracker
 	if c.ProgressManager != nil {
@@ -39,25 +41,51 @@ func (c *Client) DownloadBottle(f *RemoteFormula) (string, error) {
 		defer c.ProgressManager.Unregister(f.Name)
 	}
 
-	if err := c.DownloadWithProgress(bottleURL, tarPath, sha256Sum, tracker); err != nil {
-		return "", err
+	filename := f.bottleCacheName()
+	tarPath, err := c.fetchCached(ctx, bottleURL, filename, sha256Sum, tracker)
+	if err != nil {
+		if tarPath, err = c.fetchBottleFromRegistry(ctx, f, filename, sha256Sum, tracker, err); err != nil {
+			return "", err
+		}
+	}
+
+	if c.VerifyAttestations {
+		if err := c.VerifyBottleAttestation(ctx, f.Name, sha256Sum); err != nil {
+			return "", err
+		}
 	}
 
 	return tarPath, nil
 }
 
+// bottleCacheName is the name f's bottle is cached under.
+func (f *RemoteFormula) bottleCacheName() string {
+	return fmt.Sprintf("%s-%s.bottle", f.Name, f.Versions.Stable)
+}
+
 // ExtractAndInstallBottle extracts a previously downloaded bottle tarball into the Cellar.
+// The keg's receipt keeps the install reason of any version already installed.
 // It does not print any output.
 func (c *Client) ExtractAndInstallBottle(f *RemoteFormula, tarPath string) error {
+	return c.extractAndInstallBottle(f, tarPath, c.installedAsDependency(f.Name))
+}
+
+// extractAndInstallBottle is ExtractAndInstallBottle with the install reason
+// to record in the keg's INSTALL_RECEIPT.json.
+func (c *Client) extractAndInstallBottle(f *RemoteFormula, tarPath string, asDependency bool) error {
 	cellarPath := filepath.Join(c.Prefix, "Cellar")
 
-	tmpDir := filepath.Join(cellarPath, fmt.Sprintf(".fastbrew-tmp-%s-%d", f.Name, rand.IntN(1000000)))
+	tmpDir := filepath.Join(cellarPath, fmt.Sprintf("%s%s-%d", extractTempPrefix, f.Name, rand.IntN(1000000)))
 	if err := os.MkdirAll(tmpDir, 0755); err != nil {
 		return fmt.Errorf("failed to create temp dir: %w", err)
 	}
 	defer os.RemoveAll(tmpDir)
+	// A run killed mid-extraction skips the deferred removal; rollback of
+	// its journal, cleanup and doctor --fix remove the directory instead.
+	c.recordStep(TransactionStep{Package: f.Name, Kind: StepTempDirCreated, Path: tmpDir})
 
-	if err := ExtractBottle(tarPath, tmpDir, c.Prefix); err != nil {
+	written, err := c.extractBottleCached(tarPath, tmpDir)
+	if err != nil {
 		return fmt.Errorf("extraction failed: %w", err)
 	}
 
@@ -72,6 +100,17 @@ func (c *Client) ExtractAndInstallBottle(f *RemoteFormula, tarPath string) error
 		}
 	}
 
+	manifest := newKegManifest(tmpDir, extractedPkgDir, written)
+	if err := verifyExtractedKeg(extractedPkgDir, manifest); err != nil {
+		return fmt.Errorf("extracted keg failed verification: %w", err)
+	}
+	if err := writeKegReceipt(extractedPkgDir, f, asDependency, time.Now()); err != nil {
+		return err
+	}
+	if err := manifest.write(extractedPkgDir); err != nil {
+		return err
+	}
+
 	finalPkgDir := filepath.Join(cellarPath, f.Name)
 	if err := os.MkdirAll(finalPkgDir, 0755); err != nil {
 		return fmt.Errorf("failed to create package dir: %w", err)
@@ -102,14 +141,81 @@ func (c *Client) ExtractAndInstallBottle(f *RemoteFormula, tarPath string) error
 	// Success: remove backup
 	if hasExisting {
 		_ = os.RemoveAll(backupDir)
+	} else {
+		c.recordStep(TransactionStep{Package: f.Name, Kind: StepKegCreated, Path: finalVersionDir})
 	}
 
 	return nil
 }
 
+// extractTempPrefix starts the names of the Cellar directories bottles are
+// extracted into before being moved into place.
+const extractTempPrefix = ".fastbrew-tmp-"
+
+// staleTempDirAge is how old an extraction directory must be to be removed
+// while another install may be running; no extraction takes this long.
+const staleTempDirAge = time.Hour
+
+// RemoveExtractionTempDirs deletes extraction directories left in the
+// Cellar, such as by an install that was killed mid-extraction. It must
+// only be called while no install is running.
+func (c *Client) RemoveExtractionTempDirs() error {
+	_, err := c.removeExtractionTempDirs(0)
+	return err
+}
+
+// RemoveStaleExtractionTempDirs deletes the extraction directories left in
+// the Cellar more than an hour ago, which no running install still uses,
+// and returns their paths.
+func (c *Client) RemoveStaleExtractionTempDirs() ([]string, error) {
+	return c.removeExtractionTempDirs(staleTempDirAge)
+}
+
+// sweepExtractionTempDirs removes stale extraction directories before an
+// install or upgrade extracts bottles, logging any it cannot remove.
+func (c *Client) sweepExtractionTempDirs() {
+	dirs, err := c.RemoveStaleExtractionTempDirs()
+	if len(dirs) > 0 {
+		c.logger().Info("removed stale extraction directories", "dirs", dirs)
+	}
+	if err != nil {
+		c.logger().Warn("could not remove stale extraction directories", "error", err)
+	}
+}
+
+func (c *Client) removeExtractionTempDirs(olderThan time.Duration) ([]string, error) {
+	dirs, err := c.extractionTempDirs(olderThan)
+	if err != nil {
+		return nil, err
+	}
+	for i, dir := range dirs {
+		if err := os.RemoveAll(dir); err != nil {
+			return dirs[:i], fmt.Errorf("failed to remove %s: %w", dir, err)
+		}
+	}
+	return dirs, nil
+}
+
+// extractionTempDirs returns the extraction directories in the Cellar last
+// modified more than olderThan ago.
+func (c *Client) extractionTempDirs(olderThan time.Duration) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(c.Prefix, "Cellar", extractTempPrefix+"*"))
+	if err != nil {
+		return nil, err
+	}
+	cutoff := time.Now().Add(-olderThan)
+	var dirs []string
+	for _, dir := range matches {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() && !info.ModTime().After(cutoff) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs, nil
+}
+
 // InstallBottle downloads and extracts a bottle for the given formula (legacy wrapper).
 func (c *Client) InstallBottle(f *RemoteFormula) error {
-	tarPath, err := c.DownloadBottle(f)
+	tarPath, err := c.DownloadBottle(context.Background(), f)
 	if err != nil {
 		return err
 	}
@@ -118,18 +224,72 @@ func (c *Client) InstallBottle(f *RemoteFormula) error {
 
 // DownloadAndVerify downloads the file and checks generic SHA256
 func (c *Client) DownloadAndVerify(url, dest, expectedSHA string) error {
-	return c.DownloadWithProgress(url, dest, expectedSHA, nil)
+	return c.DownloadWithProgress(context.Background(), url, dest, expectedSHA, nil)
 }
 
-// DownloadWithProgress downloads a file with optional progress tracking and resume support
-func (c *Client) DownloadWithProgress(url, dest, expectedSHA string, tracker progress.ProgressTracker) error {
+// downloadRetryConfig retries bottle downloads. Bottles are large, so the
+// backoff is longer than for API calls; each retry resumes from the bytes
+// already written.
+var downloadRetryConfig = retry.Config{
+	MaxAttempts:  4,
+	InitialDelay: time.Second,
+	Multiplier:   2.0,
+	JitterFactor: 0.2,
+}
+
+// DownloadWithProgress downloads a file with optional progress tracking and resume support.
+// Transient failures are retried, continuing any partial download. When ctx
+// is cancelled the partial file and its resume metadata are kept, so the
+// next download of dest continues where this one stopped.
+func (c *Client) DownloadWithProgress(ctx context.Context, url, dest, expectedSHA string, tracker progress.ProgressTracker) error {
 	if _, err := os.Stat(dest); err == nil {
 		if verifyChecksum(dest, expectedSHA) == nil {
 			return nil
 		}
-		os.Remove(dest)
+		// Keep partial downloads the resume manager knows about so they
+		// are continued rather than restarted.
+		cacheDir, _ := c.GetCacheDir()
+		if !resume.NewResumeManager(cacheDir).Exists(dest) {
+			os.Remove(dest)
+		}
 	}
 
+	host := hostOf(url)
+	cfg := downloadRetryConfig
+	if c.RetryAttempts > 0 {
+		cfg.MaxAttempts = c.RetryAttempts
+	}
+	attempt := 0
+	return retry.DoWithConfig(ctx, cfg, func() error {
+		attempt++
+		err := c.breaker().Do(host, func() error {
+			_, _, err := c.downloadToFile(ctx, url, dest, downloadOptions{
+				ExpectedSHA: expectedSHA,
+				Tracker:     tracker,
+			})
+			return err
+		})
+		if err != nil && retry.IsRetryable(err) && attempt < cfg.MaxAttempts {
+			c.logger().Warn("retrying download", "url", url, "attempt", attempt, "error", err)
+		}
+		return err
+	})
+}
+
+// downloadOptions tune downloadToFile.
+type downloadOptions struct {
+	// ExpectedSHA is verified after the download when non-empty.
+	ExpectedSHA string
+	Tracker     progress.ProgressTracker
+	// Header is sent on fresh (non-resumed) requests, e.g. conditional
+	// If-None-Match headers. A 304 reply leaves dest untouched.
+	Header http.Header
+}
+
+// downloadToFile fetches url into dest, continuing a previous partial
+// download recorded by the resume manager when possible. It returns the
+// response headers and whether the server replied 304 Not Modified.
+func (c *Client) downloadToFile(ctx context.Context, url, dest string, opts downloadOptions) (http.Header, bool, error) {
 	cacheDir, _ := c.GetCacheDir()
 	rm := resume.NewResumeManager(cacheDir)
 
@@ -139,7 +299,7 @@ func (c *Client) DownloadWithProgress(url, dest, expectedSHA string, tracker pro
 	if rm.Exists(dest) {
 		var err error
 		pd, err = rm.Load(dest)
-		if err == nil && pd.URL == url && resume.CanResume(pd.State) {
+		if err == nil && pd.URL == url && c.canResume(ctx, url, pd) {
 			if info, statErr := os.Stat(dest); statErr == nil {
 				startByte = info.Size()
 			}
@@ -150,78 +310,71 @@ func (c *Client) DownloadWithProgress(url, dest, expectedSHA string, tracker pro
 		}
 	}
 
-	var out *os.File
-	var err error
-	if startByte > 0 {
-		out, err = os.OpenFile(dest, os.O_APPEND|os.O_WRONLY, 0644)
-	} else {
-		out, err = os.Create(dest)
-	}
-	if err != nil {
-		return err
-	}
-	defer out.Close()
-
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return err
+		return nil, false, err
 	}
 
+	c.logger().Debug("download started", "url", url, "dest", dest, "resume_from", startByte)
 	if startByte > 0 {
 		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", startByte))
+	} else {
+		for key, values := range opts.Header {
+			for _, v := range values {
+				req.Header.Add(key, v)
+			}
+		}
 	}
 
-	httpClient := httpclient.Get()
-	resp, err := httpClient.Do(req)
+	resp, err := c.doAuthorized(ctx, req)
 	if err != nil {
-		return err
-	}
-
-	if resp.StatusCode == 401 {
-		authHeader := resp.Header.Get("Www-Authenticate")
-		if authHeader != "" {
-			token, tokenErr := getGHCRToken(authHeader)
-			if tokenErr != nil {
-				resp.Body.Close()
-				return fmt.Errorf("failed to get ghcr token: %w", tokenErr)
-			}
-			req.Header.Set("Authorization", "Bearer "+token)
-			resp.Body.Close()
-			resp, err = httpClient.Do(req)
-			if err != nil {
-				return err
-			}
-		}
+		return nil, false, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == 200 && startByte > 0 {
-		out.Close()
-		out, err = os.Create(dest)
-		if err != nil {
-			return err
-		}
-		defer out.Close()
-		startByte = 0
+	if resp.StatusCode == http.StatusNotModified && startByte == 0 {
+		return resp.Header, true, nil
 	}
 
 	if resp.StatusCode == 206 {
 		contentRange := resp.Header.Get("Content-Range")
 		expectedPrefix := fmt.Sprintf("bytes %d-", startByte)
+		etag := resp.Header.Get("ETag")
+		if pd != nil && pd.ETag != "" && etag != "" && etag != pd.ETag {
+			// The file changed since the partial was written; appending
+			// would splice two versions together. Start over next attempt.
+			rm.Delete(dest)
+			os.Remove(dest)
+			return nil, false, fmt.Errorf("remote file changed while resuming %s", url)
+		}
 		if contentRange != "" && !strings.HasPrefix(contentRange, expectedPrefix) {
-			out.Close()
-			out, err = os.Create(dest)
-			if err != nil {
-				return err
-			}
-			defer out.Close()
 			startByte = 0
 		}
+	} else {
+		startByte = 0
 	}
 
 	if resp.StatusCode != 200 && resp.StatusCode != 206 {
-		return fmt.Errorf("download failed: %s", resp.Status)
+		c.logger().Warn("download failed", "url", url, "status", resp.StatusCode)
+		err := kindErrorf(KindNetwork, "download failed: %s", resp.Status)
+		if isPermanentStatus(resp.StatusCode) {
+			err = retry.NonRetryable(err)
+		} else if delay, ok := retry.ParseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			err = retry.After(err, delay)
+		}
+		return nil, false, err
+	}
+
+	var out *os.File
+	if startByte > 0 {
+		out, err = os.OpenFile(dest, os.O_APPEND|os.O_WRONLY, 0644)
+	} else {
+		out, err = os.Create(dest)
+	}
+	if err != nil {
+		return nil, false, err
 	}
+	defer out.Close()
 
 	totalSize := resp.ContentLength + startByte
 	if pd == nil {
@@ -235,8 +388,8 @@ func (c *Client) DownloadWithProgress(url, dest, expectedSHA string, tracker pro
 		rm.Save(pd)
 	}
 
-	if tracker != nil {
-		tracker.Start(totalSize)
+	if opts.Tracker != nil {
+		opts.Tracker.Start(totalSize)
 	}
 
 	buf := make([]byte, 1024*1024)
@@ -244,48 +397,54 @@ func (c *Client) DownloadWithProgress(url, dest, expectedSHA string, tracker pro
 	bufferedWriter := bufio.NewWriterSize(out, 1024*1024)
 	downloaded := startByte
 
+	failPartial := func() {
+		if pd != nil {
+			bufferedWriter.Flush()
+			pd.DownloadedBytes = downloaded
+			pd.UpdateState(resume.StateFailed)
+			rm.Save(pd)
+		}
+	}
+
 	for {
 		n, readErr := bufferedReader.Read(buf)
 		if n > 0 {
 			if _, writeErr := bufferedWriter.Write(buf[:n]); writeErr != nil {
-				if pd != nil {
-					pd.DownloadedBytes = downloaded
-					pd.UpdateState(resume.StateFailed)
-					rm.Save(pd)
-				}
-				return writeErr
+				failPartial()
+				return nil, false, writeErr
 			}
 			downloaded += int64(n)
+			c.transferred.Add(int64(n))
 
-			if tracker != nil {
-				tracker.Update(downloaded)
+			if opts.Tracker != nil {
+				opts.Tracker.Update(downloaded)
 			}
 		}
 		if readErr == io.EOF {
 			break
 		}
 		if readErr != nil {
-			if pd != nil {
-				pd.DownloadedBytes = downloaded
-				pd.UpdateState(resume.StateFailed)
-				rm.Save(pd)
-			}
-			return readErr
+			failPartial()
+			c.logger().Warn("download interrupted", "url", url, "bytes", downloaded, "error", readErr)
+			return nil, false, readErr
 		}
 	}
 
 	if err := bufferedWriter.Flush(); err != nil {
-		return err
+		return nil, false, err
 	}
 	out.Close()
 
-	if err := verifyChecksum(dest, expectedSHA); err != nil {
-		if pd != nil {
-			pd.UpdateState(resume.StateFailed)
-			rm.Save(pd)
+	if opts.ExpectedSHA != "" {
+		if err := verifyChecksum(dest, opts.ExpectedSHA); err != nil {
+			if pd != nil {
+				pd.UpdateState(resume.StateFailed)
+				rm.Save(pd)
+			}
+			os.Remove(dest)
+			c.logger().Warn("checksum mismatch", "url", url, "dest", dest, "error", err)
+			return nil, false, kindErrorf(KindChecksum, "checksum mismatch: %w", err)
 		}
-		os.Remove(dest)
-		return fmt.Errorf("checksum mismatch: %w", err)
 	}
 
 	if pd != nil {
@@ -294,60 +453,114 @@ func (c *Client) DownloadWithProgress(url, dest, expectedSHA string, tracker pro
 		rm.Delete(dest)
 	}
 
-	if tracker != nil {
-		tracker.Complete()
+	if opts.Tracker != nil {
+		opts.Tracker.Complete()
 	}
+	c.logger().Debug("download finished", "url", url, "dest", dest, "bytes", downloaded)
 
-	return nil
+	return resp.Header, false, nil
 }
 
-// getGHCRToken parses the Www-Authenticate header and fetches a bearer token
-// Header format: Bearer realm="https://ghcr.io/token",service="ghcr.io",scope="repository:homebrew/core/cowsay:pull"
-func getGHCRToken(authHeader string) (string, error) {
-	authHeader = strings.TrimSpace(authHeader)
-	if strings.HasPrefix(authHeader, "Bearer ") {
-		authHeader = authHeader[7:]
-	}
-
-	params := make(map[string]string)
-	for _, part := range strings.Split(authHeader, ",") {
-		part = strings.TrimSpace(part)
-		idx := strings.Index(part, "=")
-		if idx < 0 {
-			continue
+// doAuthorized sends req through the download scheduler, answering a 401
+// once. Registries (ghcr.io, a registry mirror, or a private OCI registry)
+// issue bearer challenges and hand out tokens, using the host's credential
+// when one is configured; the tokens are shared with the other downloads
+// of this run until they expire, rather than requested anew for every
+// bottle. Other hosts get the credential directly; without one the 401 is
+// returned to the caller.
+func (c *Client) doAuthorized(ctx context.Context, req *http.Request) (*http.Response, error) {
+	tokens := c.registryClient().Tokens
+	scope := oci.TokenScope(req.URL.String())
+	cached := tokens.Cached(scope)
+	if cached != "" {
+		req.Header.Set("Authorization", "Bearer "+cached)
+	}
+
+	sched := c.scheduler()
+	resp, err := sched.Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	authHeader := resp.Header.Get("Www-Authenticate")
+	cred, hasCred := c.Credentials.Lookup(req.URL.Hostname())
+	var authorization string
+	if strings.HasPrefix(strings.TrimSpace(authHeader), "Bearer ") {
+		var credPtr *auth.Credential
+		if hasCred {
+			credPtr = &cred
 		}
-		key := strings.TrimSpace(part[:idx])
-		value := strings.Trim(strings.TrimSpace(part[idx+1:]), "\"")
-		params[key] = value
+		token, tokenErr := tokens.Token(ctx, c.httpClient(), scope, authHeader, credPtr, cached)
+		if tokenErr != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to get registry token: %w", tokenErr)
+		}
+		authorization = "Bearer " + token
+	} else if hasCred {
+		authorization = cred.Header()
 	}
-
-	realm := params["realm"]
-	if realm == "" {
-		return "", fmt.Errorf("could not find realm in Www-Authenticate")
+	if authorization == "" {
+		return resp, nil
 	}
+	c.logger().Debug("retrying request with credentials", "url", req.URL.String(), "method", req.Method, "host", req.URL.Hostname())
+	req.Header.Set("Authorization", authorization)
+	resp.Body.Close()
+	return sched.Do(req)
+}
 
-	service := params["service"]
-	scope := params["scope"]
+// canResume reports whether the partial download pd of url can be
+// continued: it is intact and, as far as a HEAD request tells, the remote
+// file has the same ETag, Last-Modified date and size as when the partial
+// was written. When the remote cannot be checked the partial is resumed
+// anyway; the ETag of the ranged response is compared again.
+func (c *Client) canResume(ctx context.Context, url string, pd *resume.PartialDownload) bool {
+	if !resume.CanResume(pd.State) {
+		return false
+	}
 
-	tokenURL := fmt.Sprintf("%s?service=%s&scope=%s", realm,
-		url.QueryEscape(service), url.QueryEscape(scope))
-	resp, err := httpclient.Get().Get(tokenURL)
+	var etag, lastModified string
+	size := int64(-1)
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
 	if err != nil {
-		return "", err
+		return false
+	}
+	resp, err := c.doAuthorized(ctx, req)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			etag, lastModified, size = resp.Header.Get("ETag"), resp.Header.Get("Last-Modified"), resp.ContentLength
+		} else {
+			err = fmt.Errorf("HEAD returned %s", resp.Status)
+		}
+	}
+	if err != nil {
+		c.logger().Debug("could not check remote before resuming", "url", url, "error", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("failed to get token from %s: %s", tokenURL, resp.Status)
+	if !resume.CanResumeDownload(pd, lastModified, etag) || resume.CheckRemoteSizeChanged(pd, size) {
+		c.logger().Info("discarding stale partial download", "url", url, "dest", pd.LocalPath)
+		return false
 	}
+	return true
+}
 
-	var result struct {
-		Token string `json:"token"`
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", err
+	return u.Host
+}
+
+// isPermanentStatus reports whether a failed download should not be
+// retried: client errors, except 401 (a registry token may have expired),
+// 408 and 429.
+func isPermanentStatus(code int) bool {
+	switch code {
+	case http.StatusUnauthorized, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
 	}
-	return result.Token, nil
+	return code >= 400 && code < 500
 }
 
 func verifyChecksum(path, expected string) error {
@@ -372,108 +585,129 @@ func verifyChecksum(path, expected string) error {
 // ExtractBottle extracts a bottle archive (gzip or zstd compressed tar) to cellarDir.
 // The tarball structure is `name/version/...`, extracted relative to cellarDir.
 func ExtractBottle(tarPath, cellarDir, prefixDir string) error {
-	f, err := os.Open(tarPath)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
+	_, err := extractBottle(tarPath, cellarDir, prefixDir, defaultExtractWorkers())
+	return err
+}
 
-	br := bufio.NewReaderSize(f, 1024*1024)
-	magic, err := br.Peek(4)
+// extractBottle is ExtractBottle, also returning what was written: every
+// file, symlink and hard link, with paths as named in the archive. With
+// more than one worker, decompression runs ahead of the tar reader and
+// regular files up to maxBufferedExtractFile are written by a pool of
+// workers.
+func extractBottle(tarPath, cellarDir, prefixDir string, workers int) ([]KegFile, error) {
+	tr, closeArchive, err := openBottleArchive(tarPath, workers)
 	if err != nil {
-		return fmt.Errorf("failed to detect compression format: %w", err)
+		return nil, err
 	}
+	defer closeArchive()
 
-	var decompReader io.Reader
-	var decompCloser io.Closer
+	pool := newFileWriterPool(workers)
+	defer pool.close()
 
-	if len(magic) >= 2 && magic[0] == 0x1f && magic[1] == 0x8b {
-		gzr, err := gzip.NewReader(br)
+	extractBuf := make([]byte, 1024*1024)
+	var written []KegFile
+	hashes := make(map[string]KegFile)
+	// collect waits for queued files and records their hashes.
+	collect := func() error {
+		results, err := pool.wait()
 		if err != nil {
 			return err
 		}
-		decompReader = gzr
-		decompCloser = gzr
-	} else if len(magic) >= 4 && magic[0] == 0x28 && magic[1] == 0xb5 && magic[2] == 0x2f && magic[3] == 0xfd {
-		zr, err := zstd.NewReader(br)
-		if err != nil {
-			return err
+		for _, r := range results {
+			written[r.index] = r.file
+			hashes[filepath.Clean(r.file.Path)] = r.file
 		}
-		decompReader = zr
-		decompCloser = zr.IOReadCloser()
-	} else {
-		return fmt.Errorf("unsupported compression format (magic: %x)", magic)
+		return nil
 	}
-	defer decompCloser.Close()
-
-	tr := tar.NewReader(decompReader)
-	extractBuf := make([]byte, 1024*1024)
 
 	for {
+		if err := pool.err(); err != nil {
+			return nil, err
+		}
 		header, err := tr.Next()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		// Security: prevent ZipSlip
 		// The target path is cellarDir joined with header.Name
 		target := filepath.Join(cellarDir, header.Name)
 		if !strings.HasPrefix(target, filepath.Clean(cellarDir)+string(os.PathSeparator)) {
-			return fmt.Errorf("illegal file path in tar: %s", header.Name)
+			return nil, fmt.Errorf("illegal file path in tar: %s", header.Name)
+		}
+		// An entry replacing a file that is still queued waits for it.
+		if pool.queued(target) {
+			if err := collect(); err != nil {
+				return nil, err
+			}
 		}
 
 		switch header.Typeflag {
 		case tar.TypeDir:
 			if err := os.MkdirAll(target, 0755); err != nil {
-				return fmt.Errorf("failed to create directory %s: %w", target, err)
+				return nil, fmt.Errorf("failed to create directory %s: %w", target, err)
 			}
 		case tar.TypeReg:
 			dir := filepath.Dir(target)
 			if err := os.MkdirAll(dir, 0755); err != nil {
-				return fmt.Errorf("failed to create directory for %s: %w", target, err)
-			}
-			outFile, err := os.OpenFile(target, os.O_CREATE|os.O_RDWR|os.O_TRUNC, os.FileMode(header.Mode)&0777)
-			if err != nil {
-				return fmt.Errorf("failed to create file %s: %w", target, err)
+				return nil, fmt.Errorf("failed to create directory for %s: %w", target, err)
 			}
-			if _, err := io.CopyBuffer(outFile, tr, extractBuf); err != nil {
-				outFile.Close()
-				return fmt.Errorf("failed to write file %s: %w", target, err)
+			mode := os.FileMode(header.Mode) & 0777
+			if pool.accepts(header.Size) {
+				data := make([]byte, header.Size)
+				if _, err := io.ReadFull(tr, data); err != nil {
+					return nil, fmt.Errorf("failed to read %s from archive: %w", header.Name, err)
+				}
+				pool.submit(fileWriteJob{index: len(written), name: header.Name, target: target, mode: mode, data: data})
+				written = append(written, KegFile{Path: header.Name})
+				continue
 			}
-			if err := outFile.Close(); err != nil {
-				return fmt.Errorf("failed to close file %s: %w", target, err)
+			file, err := writeExtractedFile(header.Name, target, mode, tr, header.Size, extractBuf)
+			if err != nil {
+				return nil, err
 			}
+			hashes[filepath.Clean(header.Name)] = file
+			written = append(written, file)
 		case tar.TypeSymlink:
 			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
-				return fmt.Errorf("failed to create directory for symlink %s: %w", target, err)
+				return nil, fmt.Errorf("failed to create directory for symlink %s: %w", target, err)
 			}
 			if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
-				return fmt.Errorf("failed to remove existing %s: %w", target, err)
+				return nil, fmt.Errorf("failed to remove existing %s: %w", target, err)
 			}
 			linkTarget := header.Linkname
 			if !isSafeSymlink(cellarDir, prefixDir, target, linkTarget) {
-				return fmt.Errorf("unsafe symlink target %q for %s", linkTarget, header.Name)
+				return nil, fmt.Errorf("unsafe symlink target %q for %s", linkTarget, header.Name)
 			}
 			if err := os.Symlink(linkTarget, target); err != nil {
-				return fmt.Errorf("failed to create symlink %s: %w", target, err)
+				return nil, fmt.Errorf("failed to create symlink %s: %w", target, err)
 			}
+			written = append(written, KegFile{Path: header.Name, Link: linkTarget})
 		case tar.TypeLink:
+			// The file linked to may still be queued.
+			if err := collect(); err != nil {
+				return nil, err
+			}
 			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
-				return fmt.Errorf("failed to create directory for hard link %s: %w", target, err)
+				return nil, fmt.Errorf("failed to create directory for hard link %s: %w", target, err)
 			}
 			if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
-				return fmt.Errorf("failed to remove existing %s: %w", target, err)
+				return nil, fmt.Errorf("failed to remove existing %s: %w", target, err)
 			}
 			linkTarget := filepath.Join(cellarDir, header.Linkname)
 			if !strings.HasPrefix(linkTarget, filepath.Clean(cellarDir)+string(os.PathSeparator)) {
-				return fmt.Errorf("illegal hard link target %q for %s", header.Linkname, header.Name)
+				return nil, fmt.Errorf("illegal hard link target %q for %s", header.Linkname, header.Name)
 			}
 			if err := os.Link(linkTarget, target); err != nil {
-				return fmt.Errorf("failed to create hard link %s: %w", target, err)
+				return nil, fmt.Errorf("failed to create hard link %s: %w", target, err)
 			}
+			// A hard link is another name for a file already written.
+			file := hashes[filepath.Clean(header.Linkname)]
+			file.Path = header.Name
+			written = append(written, file)
 		case tar.TypeChar, tar.TypeBlock:
 			fmt.Printf("Warning: skipping device file %s\n", header.Name)
 		default:
@@ -482,7 +716,84 @@ func ExtractBottle(tarPath, cellarDir, prefixDir string) error {
 			}
 		}
 	}
-	return nil
+	if err := collect(); err != nil {
+		return nil, err
+	}
+	return written, nil
+}
+
+// writeExtractedFile writes size bytes from r to target and returns the
+// file's manifest entry under name.
+func writeExtractedFile(name, target string, mode os.FileMode, r io.Reader, size int64, buf []byte) (KegFile, error) {
+	outFile, err := os.OpenFile(target, os.O_CREATE|os.O_RDWR|os.O_TRUNC, mode)
+	if err != nil {
+		return KegFile{}, fmt.Errorf("failed to create file %s: %w", target, err)
+	}
+	hasher := sha256.New()
+	written, err := io.CopyBuffer(io.MultiWriter(outFile, hasher), r, buf)
+	if err != nil {
+		outFile.Close()
+		return KegFile{}, fmt.Errorf("failed to write file %s: %w", target, err)
+	}
+	if err := outFile.Close(); err != nil {
+		return KegFile{}, fmt.Errorf("failed to close file %s: %w", target, err)
+	}
+	if written != size {
+		return KegFile{}, fmt.Errorf("short write for %s: %d of %d bytes", target, written, size)
+	}
+	return KegFile{Path: name, Size: written, SHA256: hex.EncodeToString(hasher.Sum(nil))}, nil
+}
+
+// openBottleArchive opens a gzip or zstd compressed bottle tarball. The
+// returned function closes it. zstd decodes up to workers blocks at once;
+// gzip streams cannot be split, so with more than one worker they are
+// decompressed ahead of the reader in a goroutine of their own.
+func openBottleArchive(tarPath string, workers int) (*tar.Reader, func(), error) {
+	f, err := os.Open(tarPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	br := bufio.NewReaderSize(f, 1024*1024)
+	magic, err := br.Peek(4)
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("failed to detect compression format: %w", err)
+	}
+
+	var decompReader io.Reader
+	var decompCloser io.Closer
+
+	if len(magic) >= 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzr, err := gzip.NewReader(br)
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		decompReader = gzr
+		decompCloser = gzr
+		if workers > 1 {
+			ahead := newReadAhead(gzr)
+			decompReader = ahead
+			decompCloser = multiCloser{ahead, gzr}
+		}
+	} else if len(magic) >= 4 && magic[0] == 0x28 && magic[1] == 0xb5 && magic[2] == 0x2f && magic[3] == 0xfd {
+		zr, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(max(workers, 1)))
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		decompReader = zr
+		decompCloser = zr.IOReadCloser()
+	} else {
+		f.Close()
+		return nil, nil, fmt.Errorf("unsupported compression format (magic: %x)", magic)
+	}
+
+	return tar.NewReader(decompReader), func() {
+		decompCloser.Close()
+		f.Close()
+	}, nil
 }
 
 func isSafeSymlink(cellarDir, prefixDir, target, linkname string) bool {
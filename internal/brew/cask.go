@@ -13,10 +13,9 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
-	"sync"
 	"time"
 
-	"fastbrew/internal/httpclient"
+	"fastbrew/internal/atomicfile"
 	"fastbrew/internal/progress"
 )
 
@@ -30,6 +29,9 @@ type CaskArtifact struct {
 	Dmg     []interface{} `json:"dmg,omitempty"`
 	Ruby    []interface{} `json:"ruby,omitempty"`
 	Script  []interface{} `json:"script,omitempty"`
+	// Zap lists what `uninstall --zap` removes besides the app: stanzas
+	// such as {"trash": [...], "rmdir": [...]}.
+	Zap []interface{} `json:"zap,omitempty"`
 }
 
 type CaskDependsOn struct {
@@ -117,27 +119,20 @@ func (c *Client) FetchCaskMetadata(name string) (*CaskMetadata, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request for cask %s: %w", name, err)
-	}
-
-	httpClient := httpclient.Get()
-	resp, err := httpClient.Do(req)
+	data, status, err := c.fetchAPIJSON(ctx, url, "cask", name)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch cask %s: %w", name, err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode == 404 {
-		return nil, fmt.Errorf("cask %s not found on API", name)
+	if status == 404 {
+		return nil, kindErrorf(KindNotFound, "cask %s not found on API", name)
 	}
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("API returned status %d for cask %s", resp.StatusCode, name)
+	if status != 200 {
+		return nil, kindErrorf(KindNetwork, "API returned status %d for cask %s", status, name)
 	}
 
 	var metadata CaskMetadata
-	if err := json.NewDecoder(resp.Body).Decode(&metadata); err != nil {
+	if err := json.Unmarshal(data, &metadata); err != nil {
 		return nil, fmt.Errorf("failed to parse cask JSON for %s: %w", name, err)
 	}
 
@@ -174,15 +169,14 @@ func (ci *CaskInstaller) downloadArtifact(packageName, url, destPath, expectedSH
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
-	httpClient := httpclient.Get()
-	resp, err := httpClient.Do(req)
+	resp, err := ci.client.scheduler().Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to download: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		return fmt.Errorf("download returned status %d", resp.StatusCode)
+		return kindErrorf(KindNetwork, "download returned status %d", resp.StatusCode)
 	}
 
 	tmpPath := destPath + ".tmp"
@@ -234,7 +228,7 @@ func (ci *CaskInstaller) downloadArtifact(packageName, url, destPath, expectedSH
 	actualSHA256 := hex.EncodeToString(hasher.Sum(nil))
 	if expectedSHA256 != "" && actualSHA256 != expectedSHA256 {
 		os.Remove(tmpPath)
-		err := fmt.Errorf("SHA256 mismatch: expected %s, got %s", expectedSHA256, actualSHA256)
+		err := kindErrorf(KindChecksum, "SHA256 mismatch: expected %s, got %s", expectedSHA256, actualSHA256)
 		if tracker != nil {
 			tracker.Error(err)
 		}
@@ -263,6 +257,9 @@ func (ci *CaskInstaller) downloadArtifact(packageName, url, destPath, expectedSH
 }
 
 func (ci *CaskInstaller) Install(name string, p *progress.Manager) error {
+	if err := ci.client.ensurePrefix(); err != nil {
+		return err
+	}
 	operation := ci.currentOperation()
 	ci.client.emitMutation(operation, name, MutationPhaseMetadata, MutationStatusRunning, "fetching cask metadata", 0, 0, "")
 	metadata, err := ci.client.FetchCaskMetadata(name)
@@ -274,7 +271,7 @@ func (ci *CaskInstaller) Install(name string, p *progress.Manager) error {
 	ci.metadata = metadata
 
 	if metadata.Deprecated {
-		fmt.Printf("⚠️  Cask %s is deprecated\n", name)
+		ci.client.printf("⚠️  Cask %s is deprecated\n", name)
 	}
 	if metadata.Disabled {
 		ci.client.emitMutation(operation, name, MutationPhaseInstall, MutationStatusFailed, "cask disabled", 0, 0, "")
@@ -286,9 +283,21 @@ func (ci *CaskInstaller) Install(name string, p *progress.Manager) error {
 		return err
 	}
 
+	preHook, postHook := caskHookEvents(operation)
+	hookEnv := HookEnv{Package: name, Version: metadata.Version, Keg: versionDir, IsCask: true}
+	if operation == MutationOperationUpgrade {
+		_, hookEnv.OldVersion, _ = ci.IsInstalled(name)
+	}
+	if preHook != "" {
+		if err := ci.client.RunHooks(preHook, hookEnv); err != nil {
+			ci.client.emitMutation(operation, name, MutationPhaseInstall, MutationStatusFailed, err.Error(), 0, 0, "")
+			return err
+		}
+	}
+
 	artifactPath := filepath.Join(versionDir, filepath.Base(metadata.URL))
 	if _, err := os.Stat(artifactPath); os.IsNotExist(err) {
-		fmt.Printf("📥 Downloading %s %s...\n", name, metadata.Version)
+		ci.client.printf("📥 Downloading %s %s...\n", name, metadata.Version)
 		ci.client.emitMutation(operation, name, MutationPhaseDownload, MutationStatusRunning, "downloading artifact", 0, 0, "bytes")
 		if err := ci.downloadArtifact(name, metadata.URL, artifactPath, metadata.SHA256, p); err != nil {
 			ci.client.emitMutation(operation, name, MutationPhaseDownload, MutationStatusFailed, err.Error(), 0, 0, "bytes")
@@ -296,7 +305,7 @@ func (ci *CaskInstaller) Install(name string, p *progress.Manager) error {
 		}
 		ci.client.emitMutation(operation, name, MutationPhaseDownload, MutationStatusSucceeded, "download complete", 0, 0, "bytes")
 	} else {
-		fmt.Printf("📦 Using cached artifact for %s %s\n", name, metadata.Version)
+		ci.client.printf("📦 Using cached artifact for %s %s\n", name, metadata.Version)
 		ci.client.emitMutation(operation, name, MutationPhaseDownload, MutationStatusSkipped, "using cached artifact", 0, 0, "bytes")
 	}
 
@@ -312,7 +321,10 @@ func (ci *CaskInstaller) Install(name string, p *progress.Manager) error {
 	}
 	ci.client.emitMutation(operation, name, MutationPhaseComplete, MutationStatusSucceeded, "cask install complete", 0, 0, "")
 
-	fmt.Printf("✅ %s %s installed successfully!\n", name, metadata.Version)
+	ci.client.printf("✅ %s %s installed successfully!\n", name, metadata.Version)
+	if postHook != "" {
+		ci.client.RunHooks(postHook, hookEnv)
+	}
 	ci.client.notifyInvalidation(EventInstalledChanged)
 	ci.client.notifyInvalidation(EventServiceChanged)
 	return nil
@@ -390,7 +402,7 @@ func (ci *CaskInstaller) installApp(artifactPath string, apps []interface{}) err
 	default:
 		// Fallback to trying dmg if we really don't know, since many casks are DMGs without extensions
 		if ext == "" {
-			fmt.Printf("⚠️ Unknown artifact format, trying to mount as DMG: %s\n", artifactPath)
+			ci.client.printf("⚠️ Unknown artifact format, trying to mount as DMG: %s\n", artifactPath)
 			return ci.mountAndInstallApp(artifactPath, apps)
 		}
 		return fmt.Errorf("unsupported app artifact format: %s", ext)
@@ -398,7 +410,7 @@ func (ci *CaskInstaller) installApp(artifactPath string, apps []interface{}) err
 }
 
 func (ci *CaskInstaller) mountAndInstallApp(dmgPath string, apps []interface{}) error {
-	fmt.Printf("🔧 Mounting DMG: %s\n", dmgPath)
+	ci.client.printf("🔧 Mounting DMG: %s\n", dmgPath)
 
 	mountPoint, err := ci.mountDmg(dmgPath)
 	if err != nil {
@@ -419,8 +431,7 @@ func (ci *CaskInstaller) mountAndInstallApp(dmgPath string, apps []interface{})
 }
 
 func (ci *CaskInstaller) mountDmg(dmgPath string) (string, error) {
-	cmd := exec.Command("hdiutil", "attach", dmgPath, "-nobrowse", "-readonly", "-plist")
-	output, err := cmd.Output()
+	output, err := ci.client.runner().Run("hdiutil", "attach", dmgPath, "-nobrowse", "-readonly", "-plist")
 	if err != nil {
 		return "", fmt.Errorf("hdiutil attach failed: %w", err)
 	}
@@ -486,8 +497,7 @@ func findMountPointInPlist(data []byte) (string, error) {
 }
 
 func (ci *CaskInstaller) detachDmg(mountPoint string) error {
-	cmd := exec.Command("hdiutil", "detach", mountPoint)
-	if err := cmd.Run(); err != nil {
+	if _, err := ci.client.runner().Run("hdiutil", "detach", mountPoint); err != nil {
 		return fmt.Errorf("hdiutil detach failed: %w", err)
 	}
 	return nil
@@ -601,7 +611,7 @@ func copyDirRecursive(src, dst string, files *[]string) error {
 }
 
 func (ci *CaskInstaller) extractAndInstallApp(zipPath string, apps []interface{}) error {
-	fmt.Printf("🔧 Extracting and installing app from ZIP: %s\n", zipPath)
+	ci.client.printf("🔧 Extracting and installing app from ZIP: %s\n", zipPath)
 
 	tmpDir, err := os.MkdirTemp("", "fastbrew-cask-zip-*")
 	if err != nil {
@@ -690,7 +700,7 @@ func (ci *CaskInstaller) installFromExtractedDir(tmpDir string, apps []interface
 }
 
 func (ci *CaskInstaller) installPkg(artifactPath string, pkgs []interface{}) error {
-	fmt.Printf("🔧 Installing PKG: %s\n", artifactPath)
+	ci.client.printf("🔧 Installing PKG: %s\n", artifactPath)
 
 	var installedFiles []string
 	var pkgIDs []string
@@ -723,8 +733,7 @@ func (ci *CaskInstaller) installPkg(artifactPath string, pkgs []interface{}) err
 }
 
 func (ci *CaskInstaller) installSinglePkg(pkgPath string) ([]string, string, error) {
-	cmd := exec.Command("installer", "-pkg", pkgPath, "-target", "/", "-plist")
-	output, err := cmd.Output()
+	output, err := ci.client.runner().Run("installer", "-pkg", pkgPath, "-target", "/", "-plist")
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			stderr := string(exitErr.Stderr)
@@ -764,7 +773,7 @@ func (ci *CaskInstaller) installSinglePkg(pkgPath string) ([]string, string, err
 }
 
 func (ci *CaskInstaller) installBinary(artifactPath string, binaries []interface{}) error {
-	fmt.Printf("🔧 Installing binary: %s\n", artifactPath)
+	ci.client.printf("🔧 Installing binary: %s\n", artifactPath)
 
 	var installedFiles []string
 
@@ -866,7 +875,7 @@ func (ci *CaskInstaller) saveEnhancedReceipt(receipt InstallReceipt) error {
 		return fmt.Errorf("failed to marshal receipt: %w", err)
 	}
 
-	return os.WriteFile(receiptPath, data, 0644)
+	return atomicfile.WriteFile(receiptPath, data, 0644)
 }
 
 func (ci *CaskInstaller) writeReceipt(name string, metadata *CaskMetadata) error {
@@ -881,11 +890,28 @@ func (ci *CaskInstaller) writeReceipt(name string, metadata *CaskMetadata) error
 		return fmt.Errorf("failed to marshal receipt: %w", err)
 	}
 
-	return os.WriteFile(receiptPath, data, 0644)
+	return atomicfile.WriteFile(receiptPath, data, 0644)
 }
 
-func (ci *CaskInstaller) Uninstall(name string) error {
+func (ci *CaskInstaller) Uninstall(name string) (err error) {
 	operation := ci.currentOperation()
+	preHook, postHook := caskHookEvents(operation)
+	if preHook != "" {
+		hookEnv := HookEnv{Package: name, IsCask: true}
+		_, hookEnv.Version, _ = ci.IsInstalled(name)
+		if caskDir, err := ci.getCaskDir(); err == nil && hookEnv.Version != "" {
+			hookEnv.Keg = filepath.Join(caskDir, name, hookEnv.Version)
+		}
+		if err := ci.client.RunHooks(preHook, hookEnv); err != nil {
+			ci.client.emitMutation(operation, name, MutationPhaseUninstall, MutationStatusFailed, err.Error(), 0, 0, "")
+			return err
+		}
+		defer func() {
+			if err == nil {
+				ci.client.RunHooks(postHook, hookEnv)
+			}
+		}()
+	}
 	ci.client.emitMutation(operation, name, MutationPhaseUninstall, MutationStatusRunning, "uninstalling cask", 0, 0, "")
 
 	receipt, err := ci.loadEnhancedReceipt(name)
@@ -894,14 +920,16 @@ func (ci *CaskInstaller) Uninstall(name string) error {
 			ci.client.emitMutation(operation, name, MutationPhaseUninstall, MutationStatusFailed, legacyErr.Error(), 0, 0, "")
 			return legacyErr
 		}
+		if operation == MutationOperationUninstall {
+			ci.client.forgetUninstalled(name)
+		}
 		ci.client.emitMutation(operation, name, MutationPhaseComplete, MutationStatusSucceeded, "cask uninstall complete", 0, 0, "")
 		return nil
 	}
 
 	if receipt.PkgReceiptIDs != nil && len(receipt.PkgReceiptIDs) > 0 {
 		for _, pkgID := range receipt.PkgReceiptIDs {
-			cmd := exec.Command("pkgutil", "--forget", pkgID)
-			if err := cmd.Run(); err != nil {
+			if _, err := ci.client.runner().Run("pkgutil", "--forget", pkgID); err != nil {
 				fmt.Fprintf(os.Stderr, "Warning: failed to forget pkg %s: %v\n", pkgID, err)
 			}
 		}
@@ -932,8 +960,11 @@ func (ci *CaskInstaller) Uninstall(name string) error {
 		}
 	}
 
+	if operation == MutationOperationUninstall {
+		ci.client.forgetUninstalled(name)
+	}
 	ci.client.emitMutation(operation, name, MutationPhaseComplete, MutationStatusSucceeded, "cask uninstall complete", 0, 0, "")
-	fmt.Printf("✅ %s uninstalled successfully!\n", name)
+	ci.client.printf("✅ %s uninstalled successfully!\n", name)
 	ci.client.notifyInvalidation(EventInstalledChanged)
 	ci.client.notifyInvalidation(EventServiceChanged)
 	return nil
@@ -983,7 +1014,7 @@ func (ci *CaskInstaller) legacyUninstall(name string) error {
 		}
 	}
 
-	fmt.Printf("✅ %s uninstalled successfully!\n", name)
+	ci.client.printf("✅ %s uninstalled successfully!\n", name)
 	ci.client.notifyInvalidation(EventInstalledChanged)
 	ci.client.notifyInvalidation(EventServiceChanged)
 	return nil
@@ -1020,28 +1051,26 @@ func (ci *CaskInstaller) IsInstalled(name string) (bool, string, error) {
 	return false, "", nil
 }
 
-var (
-	caskMetadataCache  = make(map[string]*CaskMetadata)
-	caskMetadataMutex  sync.RWMutex
-	caskMetadataExpiry = time.Hour
-)
-
+// GetCaskMetadata returns a cask's metadata, fetched once per client.
 func (c *Client) GetCaskMetadata(name string) (*CaskMetadata, error) {
-	caskMetadataMutex.RLock()
-	if cached, ok := caskMetadataCache[name]; ok {
-		caskMetadataMutex.RUnlock()
+	c.caskMetaMu.RLock()
+	if cached, ok := c.caskMeta[name]; ok {
+		c.caskMetaMu.RUnlock()
 		return cached, nil
 	}
-	caskMetadataMutex.RUnlock()
+	c.caskMetaMu.RUnlock()
 
 	metadata, err := c.FetchCaskMetadata(name)
 	if err != nil {
 		return nil, err
 	}
 
-	caskMetadataMutex.Lock()
-	caskMetadataCache[name] = metadata
-	caskMetadataMutex.Unlock()
+	c.caskMetaMu.Lock()
+	if c.caskMeta == nil {
+		c.caskMeta = make(map[string]*CaskMetadata)
+	}
+	c.caskMeta[name] = metadata
+	c.caskMetaMu.Unlock()
 
 	return metadata, nil
 }
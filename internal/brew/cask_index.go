@@ -0,0 +1,57 @@
+package brew
+
+// caskIndex returns the indexed casks by token, old tokens included. It is
+// built once per client from the index already in memory, or from the cask
+// index alone, so cask checks neither rescan the index nor go to the
+// network.
+func (c *Client) caskIndex() (map[string]*Cask, error) {
+	c.casksMu.Lock()
+	defer c.casksMu.Unlock()
+	if !c.casksLoaded {
+		c.casks, c.casksErr = c.loadCaskIndex()
+		c.casksLoaded = true
+	}
+	return c.casks, c.casksErr
+}
+
+func (c *Client) loadCaskIndex() (map[string]*Cask, error) {
+	var casks []Cask
+	if c.index != nil {
+		casks = c.index.Casks
+	} else {
+		var err error
+		if casks, err = c.loadCaskIndexDirect(); err != nil {
+			return nil, err
+		}
+	}
+	byToken := make(map[string]*Cask, len(casks))
+	for i := range casks {
+		byToken[casks[i].Token] = &casks[i]
+	}
+	for i := range casks {
+		for _, old := range casks[i].OldTokens {
+			if _, ok := byToken[old]; !ok {
+				byToken[old] = &casks[i]
+			}
+		}
+	}
+	return byToken, nil
+}
+
+// LookupCask returns the indexed cask with the given token or old token.
+// Unless the casks are already in memory it asks the index database, so
+// checking a few names does not load the whole cask index.
+func (c *Client) LookupCask(token string) (*Cask, bool) {
+	if c.index == nil && c.casks == nil {
+		if db, err := c.OpenIndexDB(); err == nil {
+			cask, err := db.Cask(token)
+			return cask, err == nil
+		}
+	}
+	casks, err := c.caskIndex()
+	if err != nil {
+		return nil, false
+	}
+	cask, ok := casks[token]
+	return cask, ok
+}
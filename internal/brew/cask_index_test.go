@@ -0,0 +1,39 @@
+package brew
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestLookupCask(t *testing.T) {
+	var casks []Cask
+	data := `[
+		{"token": "visual-studio-code", "version": "1.96.2", "auto_updates": true,
+		 "old_tokens": ["vscode"],
+		 "artifacts": [{"app": ["Visual Studio Code.app"]}, {"binary": ["code"]}],
+		 "depends_on": {"macos": {">=": ["10.15"]}}},
+		{"token": "iterm2", "version": "3.5.10"}
+	]`
+	if err := json.Unmarshal([]byte(data), &casks); err != nil {
+		t.Fatal(err)
+	}
+	client := &Client{index: &Index{Casks: casks}}
+	client.indexLoaded = true
+
+	cask, ok := client.LookupCask("vscode")
+	if !ok || cask.Token != "visual-studio-code" {
+		t.Fatalf("LookupCask(vscode) = %v, %v", cask, ok)
+	}
+	if !cask.AutoUpdates || len(cask.Artifacts) != 2 || cask.DependsOn.MacOS == nil {
+		t.Errorf("cask metadata not indexed: %+v", cask)
+	}
+	if isCask, err := client.IsCask("iterm2"); err != nil || !isCask {
+		t.Errorf("IsCask(iterm2) = %v, %v", isCask, err)
+	}
+	if isCask, _ := client.IsCask("wget"); isCask {
+		t.Error("wget is not a cask")
+	}
+	if v, ok := client.caskVersion("iterm2"); !ok || v.Version != "3.5.10" {
+		t.Errorf("caskVersion(iterm2) = %+v, %v", v, ok)
+	}
+}
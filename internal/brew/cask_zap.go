@@ -0,0 +1,191 @@
+package brew
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ZapPlan is what zapping a cask removes besides its Caskroom entry and
+// installed artifacts: the paths its zap stanza names that exist.
+type ZapPlan struct {
+	Token string `json:"token"`
+	// Trash paths are removed with everything in them. Homebrew moves
+	// them to the Trash; fastbrew deletes them.
+	Trash []string `json:"trash"`
+	// Rmdir paths are removed only when empty.
+	Rmdir []string `json:"rmdir,omitempty"`
+	// Refused paths are named by the stanza but too broad to remove,
+	// such as ~/Library itself.
+	Refused []string `json:"refused,omitempty"`
+	// Unsupported lists zap directives other than trash, delete and
+	// rmdir (launchctl, pkgutil, ...), which are not carried out.
+	Unsupported []string `json:"unsupported,omitempty"`
+}
+
+// Empty reports whether the plan removes nothing.
+func (p *ZapPlan) Empty() bool {
+	return len(p.Trash) == 0 && len(p.Rmdir) == 0
+}
+
+// PlanZap reads the zap stanza of the cask token from the API and returns
+// the paths zapping it would remove.
+func (ci *CaskInstaller) PlanZap(token string) (*ZapPlan, error) {
+	metadata, err := ci.client.FetchCaskMetadata(token)
+	if err != nil {
+		return nil, err
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	return planZap(metadata, home), nil
+}
+
+func planZap(metadata *CaskMetadata, home string) *ZapPlan {
+	plan := &ZapPlan{Token: metadata.Token, Trash: []string{}}
+	seen := make(map[string]bool)
+	add := func(list *[]string, pattern string) {
+		for _, path := range expandZapPath(pattern, home) {
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+			if protectedZapPath(path, home) {
+				plan.Refused = append(plan.Refused, path)
+				continue
+			}
+			*list = append(*list, path)
+		}
+	}
+
+	for _, artifact := range metadata.Artifacts {
+		for _, raw := range artifact.Zap {
+			stanza, ok := raw.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for key, value := range stanza {
+				switch key {
+				case "trash", "delete":
+					for _, pattern := range stringList(value) {
+						add(&plan.Trash, pattern)
+					}
+				case "rmdir":
+					for _, pattern := range stringList(value) {
+						add(&plan.Rmdir, pattern)
+					}
+				default:
+					if !sliceContains(plan.Unsupported, key) {
+						plan.Unsupported = append(plan.Unsupported, key)
+					}
+				}
+			}
+		}
+	}
+	sort.Strings(plan.Trash)
+	// Deepest first, so a directory emptied by removing its children
+	// goes too.
+	sort.Slice(plan.Rmdir, func(i, j int) bool { return len(plan.Rmdir[i]) > len(plan.Rmdir[j]) })
+	sort.Strings(plan.Unsupported)
+	return plan
+}
+
+// Zap removes the paths in plan. Failures, typically permission errors
+// on /Library paths, are reported and the rest is still removed.
+func (ci *CaskInstaller) Zap(plan *ZapPlan) error {
+	var errs []error
+	for _, path := range plan.Trash {
+		if err := os.RemoveAll(path); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ci.client.printf("  🗑️  %s\n", path)
+	}
+	for _, path := range plan.Rmdir {
+		if err := os.Remove(path); err != nil {
+			if !os.IsNotExist(err) && !isDirNotEmpty(path) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		ci.client.printf("  🗑️  %s\n", path)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to zap %s: %w", plan.Token, errors.Join(errs...))
+	}
+	return nil
+}
+
+// expandZapPath expands a leading ~ and glob patterns, returning the
+// existing absolute paths pattern names.
+func expandZapPath(pattern, home string) []string {
+	if pattern == "~" || strings.HasPrefix(pattern, "~/") {
+		pattern = filepath.Join(home, pattern[1:])
+	}
+	if !filepath.IsAbs(pattern) {
+		return nil
+	}
+	matches, err := filepath.Glob(filepath.Clean(pattern))
+	if err != nil {
+		return nil
+	}
+	return matches
+}
+
+// protectedZapDirs are directories no cask owns, relative to the home
+// directory or, when absolute, the root.
+var protectedZapDirs = []string{
+	"Applications", "Desktop", "Documents", "Downloads", "Library",
+	"Library/Application Support", "Library/Caches", "Library/Containers",
+	"Library/Group Containers", "Library/LaunchAgents", "Library/LaunchDaemons",
+	"Library/Logs", "Library/Preferences", "Library/Saved Application State",
+	"/Applications", "/Library", "/Library/Application Support", "/Library/Caches",
+	"/Library/LaunchAgents", "/Library/LaunchDaemons", "/Library/Preferences",
+	"/System", "/Users", "/bin", "/etc", "/private", "/sbin", "/usr", "/var",
+}
+
+// protectedZapPath reports whether path is too broad to remove: the root,
+// the home directory or one of its parents, or a shared directory.
+func protectedZapPath(path, home string) bool {
+	path = filepath.Clean(path)
+	home = filepath.Clean(home)
+	if path == home || strings.HasPrefix(home, path+string(filepath.Separator)) || path == string(filepath.Separator) {
+		return true
+	}
+	for _, dir := range protectedZapDirs {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(home, dir)
+		}
+		if path == filepath.Clean(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// stringList returns a stanza value that is a string or a list of
+// strings as a list.
+func stringList(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var out []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func isDirNotEmpty(path string) bool {
+	entries, err := os.ReadDir(path)
+	return err == nil && len(entries) > 0
+}
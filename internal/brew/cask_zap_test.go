@@ -0,0 +1,89 @@
+package brew
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPlanAndZap(t *testing.T) {
+	home := t.TempDir()
+	for _, dir := range []string{
+		"Library/Application Support/Foo/data",
+		"Library/Preferences",
+		"Library/Foo Helper",
+		"Library/Foo Shared",
+	} {
+		if err := os.MkdirAll(filepath.Join(home, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"Library/Preferences/com.foo.app.plist", "Library/Preferences/com.foo.helper.plist", "Library/Foo Shared/keep"} {
+		if err := os.WriteFile(filepath.Join(home, file), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	metadata := &CaskMetadata{Token: "foo", Artifacts: []CaskArtifact{
+		{App: []interface{}{"Foo.app"}},
+		{Zap: []interface{}{map[string]interface{}{
+			"trash":     []interface{}{"~/Library/Application Support/Foo", "~/Library/Preferences/com.foo.*.plist", "~/Library", "~/Library/Caches/Foo"},
+			"rmdir":     []interface{}{"~/Library/Foo Helper", "~/Library/Foo Shared"},
+			"launchctl": "com.foo.agent",
+		}}},
+	}}
+
+	plan := planZap(metadata, home)
+	lib := filepath.Join(home, "Library")
+	wantTrash := []string{
+		filepath.Join(lib, "Application Support/Foo"),
+		filepath.Join(lib, "Preferences/com.foo.app.plist"),
+		filepath.Join(lib, "Preferences/com.foo.helper.plist"),
+	}
+	if !reflect.DeepEqual(plan.Trash, wantTrash) {
+		t.Errorf("Trash = %v, want %v (missing paths skipped)", plan.Trash, wantTrash)
+	}
+	if !reflect.DeepEqual(plan.Refused, []string{lib}) {
+		t.Errorf("Refused = %v, want ~/Library", plan.Refused)
+	}
+	if !reflect.DeepEqual(plan.Unsupported, []string{"launchctl"}) {
+		t.Errorf("Unsupported = %v", plan.Unsupported)
+	}
+
+	ci := NewCaskInstaller(&Client{Out: io.Discard})
+	if err := ci.Zap(plan); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range append(wantTrash, filepath.Join(lib, "Foo Helper")) {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s still exists: %v", path, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(lib, "Foo Shared", "keep")); err != nil {
+		t.Errorf("non-empty rmdir target was removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(lib, "Preferences")); err != nil {
+		t.Errorf("shared directory was removed: %v", err)
+	}
+}
+
+func TestProtectedZapPath(t *testing.T) {
+	home := "/Users/me"
+	for path, want := range map[string]bool{
+		"/":                                true,
+		"/Users":                           true,
+		"/Users/me":                        true,
+		"/Users/me/Library/Preferences":    true,
+		"/Users/me/Library/Preferences/":   true,
+		"/Library":                         true,
+		"/Users/me/Library/Caches/Firefox": false,
+		"/Users/me/.mozilla":               false,
+		"/Library/Application Support/Foo": false,
+	} {
+		if got := protectedZapPath(path, home); got != want {
+			t.Errorf("protectedZapPath(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package brew
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// expandCaveats substitutes the prefix placeholders the formula API uses in
+// caveat text with this installation's paths.
+func (c *Client) expandCaveats(text string) string {
+	cellar := c.Cellar
+	if cellar == "" {
+		cellar = filepath.Join(c.Prefix, "Cellar")
+	}
+	r := strings.NewReplacer(
+		"$HOMEBREW_PREFIX", c.Prefix,
+		"$HOMEBREW_CELLAR", cellar,
+		"@@HOMEBREW_PREFIX@@", c.Prefix,
+		"@@HOMEBREW_CELLAR@@", cellar,
+	)
+	return strings.TrimRight(r.Replace(text), "\n")
+}
+
+// formulaCaveats returns the caveats to show after installing f, including
+// Homebrew's standard keg-only notice.
+func (c *Client) formulaCaveats(f *RemoteFormula) string {
+	var parts []string
+	if text := strings.TrimSpace(f.Caveats); text != "" {
+		parts = append(parts, c.expandCaveats(f.Caveats))
+	}
+	if f.KegOnly {
+		parts = append(parts, f.Name+" is keg-only, which means it was not symlinked into "+c.Prefix+".\n\n"+
+			"If you need to have "+f.Name+" first in your PATH, run:\n"+
+			"  export PATH=\""+filepath.Join(c.Prefix, "opt", f.Name, "bin")+":$PATH\"")
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// printCaveats prints an aggregated Caveats section for the formulae whose
+// kegs made it into the Cellar.
+func (c *Client) printCaveats(formulae []*RemoteFormula) {
+	printed := false
+	for _, f := range formulae {
+		text := c.formulaCaveats(f)
+		if text == "" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(c.Cellar, f.Name, f.Versions.Stable)); err != nil {
+			continue
+		}
+		if !printed {
+			c.println("\n📝 Caveats")
+			printed = true
+		}
+		c.printf("==> %s\n%s\n", f.Name, text)
+	}
+}
@@ -0,0 +1,50 @@
+package brew
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandCaveats(t *testing.T) {
+	c := &Client{Prefix: "/opt/homebrew", Cellar: "/opt/homebrew/Cellar"}
+	got := c.expandCaveats("Config: $HOMEBREW_PREFIX/etc/redis.conf\nKeg: @@HOMEBREW_CELLAR@@/redis\n")
+	want := "Config: /opt/homebrew/etc/redis.conf\nKeg: /opt/homebrew/Cellar/redis"
+	if got != want {
+		t.Errorf("expandCaveats() = %q, want %q", got, want)
+	}
+}
+
+func TestPrintCaveatsOnlyForInstalledKegs(t *testing.T) {
+	prefix := t.TempDir()
+	var out bytes.Buffer
+	c := &Client{Prefix: prefix, Cellar: filepath.Join(prefix, "Cellar"), Out: &out}
+
+	if err := os.MkdirAll(filepath.Join(c.Cellar, "redis", "7.2.4"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	c.printCaveats([]*RemoteFormula{
+		{Name: "redis", Versions: Versions{Stable: "7.2.4"}, Caveats: "Start with $HOMEBREW_PREFIX/bin/redis-server"},
+		{Name: "postgresql", Versions: Versions{Stable: "16.1"}, Caveats: "not installed"},
+		{Name: "jq", Versions: Versions{Stable: "1.7"}},
+	})
+
+	text := out.String()
+	if !strings.Contains(text, "==> redis") || !strings.Contains(text, prefix+"/bin/redis-server") {
+		t.Errorf("Expected expanded redis caveats, got %q", text)
+	}
+	if strings.Contains(text, "postgresql") {
+		t.Errorf("Expected caveats for packages missing from the Cellar to be skipped, got %q", text)
+	}
+}
+
+func TestFormulaCaveatsKegOnly(t *testing.T) {
+	c := &Client{Prefix: "/usr/local"}
+	text := c.formulaCaveats(&RemoteFormula{Name: "openssl@3", KegOnly: true})
+	if !strings.Contains(text, "keg-only") || !strings.Contains(text, "/usr/local/opt/openssl@3/bin") {
+		t.Errorf("Expected keg-only notice, got %q", text)
+	}
+}
@@ -0,0 +1,255 @@
+package brew
+
+import (
+	"fastbrew/internal/resume"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	CleanupKindKeg     = "keg"
+	CleanupKindCache   = "cache"
+	CleanupKindResume  = "resume"
+	CleanupKindSymlink = "symlink"
+	// CleanupKindTemp is a directory left by an interrupted extraction.
+	CleanupKindTemp = "temp"
+)
+
+// CleanupOptions controls what Cleanup removes.
+type CleanupOptions struct {
+	// KeepVersions is the number of newest versions of each formula that
+	// are always kept. Values below 1 are treated as 1.
+	KeepVersions int
+	// MaxAge keeps older keg versions that are younger than this, and limits
+	// cache and resume-metadata pruning to files older than it. Zero removes
+	// regardless of age.
+	MaxAge time.Duration
+	// MaxCacheSize trims the download cache to this many bytes, least
+	// recently used first, when MaxAge leaves it larger. Zero means no
+	// limit.
+	MaxCacheSize int64
+	// Skip lists formulae whose kegs are left alone, e.g. pinned packages.
+	Skip map[string]bool
+	// DryRun reports what would be removed without touching the disk.
+	DryRun bool
+}
+
+// CleanupItem is one path removed (or, in a dry run, due for removal).
+type CleanupItem struct {
+	Kind    string
+	Package string
+	Version string
+	Path    string
+	Bytes   int64
+}
+
+// CleanupReport lists everything Cleanup removed.
+type CleanupReport struct {
+	Items []CleanupItem
+}
+
+// BytesReclaimed returns the total size of the removed items.
+func (r *CleanupReport) BytesReclaimed() int64 {
+	var total int64
+	for _, item := range r.Items {
+		total += item.Bytes
+	}
+	return total
+}
+
+// Cleanup removes outdated keg versions, empty formula directories and the
+// directories of extractions interrupted over an hour ago from the Cellar,
+// old downloaded bottles, blobs and stale resume metadata from
+// the cache, and broken symlinks under the prefix.
+func (c *Client) Cleanup(opts CleanupOptions) (*CleanupReport, error) {
+	if opts.KeepVersions < 1 {
+		opts.KeepVersions = 1
+	}
+	report := &CleanupReport{}
+	now := time.Now()
+
+	if err := c.cleanupKegs(opts, now, report); err != nil {
+		return report, err
+	}
+	c.cleanupTempDirs(opts, report)
+	if cacheDir, err := c.GetCacheDir(); err == nil {
+		c.cleanupCache(cacheDir, opts, now, report)
+		c.pruneBlobs(cacheDir, CachePruneOptions{MaxAge: opts.MaxAge, MaxSize: opts.MaxCacheSize, All: opts.MaxAge <= 0, DryRun: opts.DryRun}, now, report)
+	}
+	c.cleanupBrokenSymlinks(opts, report)
+
+	if !opts.DryRun && len(report.Items) > 0 {
+		c.notifyInvalidation(EventInstalledChanged)
+	}
+	return report, nil
+}
+
+func (c *Client) cleanupKegs(opts CleanupOptions, now time.Time, report *CleanupReport) error {
+	entries, err := os.ReadDir(c.Cellar)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || opts.Skip[name] || strings.HasPrefix(name, extractTempPrefix) {
+			continue
+		}
+
+		pkgDir := filepath.Join(c.Cellar, name)
+		versionEntries, err := os.ReadDir(pkgDir)
+		if err != nil {
+			continue
+		}
+		var versions []string
+		for _, v := range versionEntries {
+			if v.IsDir() && !strings.HasPrefix(v.Name(), ".") {
+				versions = append(versions, v.Name())
+			}
+		}
+		if len(versions) == 0 {
+			// Nothing installed; left behind by a partial uninstall.
+			c.cleanupRemove(report, opts.DryRun, CleanupItem{Kind: CleanupKindKeg, Package: name, Path: pkgDir, Bytes: dirSize(pkgDir)})
+			continue
+		}
+		if len(versions) <= opts.KeepVersions {
+			continue
+		}
+		sort.Slice(versions, func(i, j int) bool {
+			return versionCompare(versions[i], versions[j]) > 0
+		})
+
+		linked := c.linkedVersion(name)
+		for _, v := range versions[opts.KeepVersions:] {
+			if v == linked {
+				continue
+			}
+			kegPath := filepath.Join(pkgDir, v)
+			if opts.MaxAge > 0 {
+				if info, err := os.Stat(kegPath); err == nil && now.Sub(info.ModTime()) < opts.MaxAge {
+					continue
+				}
+			}
+			c.cleanupRemove(report, opts.DryRun, CleanupItem{
+				Kind:    CleanupKindKeg,
+				Package: name,
+				Version: v,
+				Path:    kegPath,
+				Bytes:   dirSize(kegPath),
+			})
+		}
+	}
+	return nil
+}
+
+// cleanupTempDirs removes the extraction directories an interrupted install
+// left in the Cellar more than an hour ago.
+func (c *Client) cleanupTempDirs(opts CleanupOptions, report *CleanupReport) {
+	dirs, _ := c.extractionTempDirs(staleTempDirAge)
+	for _, dir := range dirs {
+		c.cleanupRemove(report, opts.DryRun, CleanupItem{Kind: CleanupKindTemp, Path: dir, Bytes: dirSize(dir)})
+	}
+}
+
+// linkedVersion returns the Cellar version opt/<name> points at, or "".
+func (c *Client) linkedVersion(name string) string {
+	target, err := os.Readlink(filepath.Join(c.Prefix, "opt", name))
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(target)
+}
+
+func (c *Client) cleanupCache(cacheDir string, opts CleanupOptions, now time.Time, report *CleanupReport) {
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		return
+	}
+
+	expired := func(info fs.FileInfo) bool {
+		return opts.MaxAge <= 0 || now.Sub(info.ModTime()) >= opts.MaxAge
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || entry.Type()&fs.ModeSymlink != 0 {
+			// Links into the blob store are handled by pruneBlobs.
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(cacheDir, name)
+
+		switch {
+		case strings.HasSuffix(name, resume.ResumeMetadataSuffix):
+			if !expired(info) {
+				continue
+			}
+			c.cleanupRemove(report, opts.DryRun, CleanupItem{Kind: CleanupKindResume, Path: path, Bytes: info.Size()})
+			partial := strings.TrimSuffix(path, resume.ResumeMetadataSuffix)
+			if pInfo, err := os.Stat(partial); err == nil {
+				c.cleanupRemove(report, opts.DryRun, CleanupItem{Kind: CleanupKindResume, Path: partial, Bytes: pInfo.Size()})
+			}
+		case strings.HasSuffix(name, ".bottle"):
+			if !expired(info) {
+				continue
+			}
+			if _, err := os.Stat(path + resume.ResumeMetadataSuffix); err == nil {
+				// Partial download; handled with its resume metadata.
+				continue
+			}
+			c.cleanupRemove(report, opts.DryRun, CleanupItem{Kind: CleanupKindCache, Path: path, Bytes: info.Size()})
+		}
+	}
+}
+
+func (c *Client) cleanupBrokenSymlinks(opts CleanupOptions, report *CleanupReport) {
+	for _, dir := range []string{"bin", "sbin", "lib", "include", "share", "etc", "opt"} {
+		dirPath := filepath.Join(c.Prefix, dir)
+		if _, err := os.Stat(dirPath); err != nil {
+			continue
+		}
+		filepath.WalkDir(dirPath, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.Type()&fs.ModeSymlink == 0 {
+				return nil
+			}
+			if _, err := os.Stat(path); err != nil {
+				c.cleanupRemove(report, opts.DryRun, CleanupItem{Kind: CleanupKindSymlink, Path: path})
+			}
+			return nil
+		})
+	}
+}
+
+func (c *Client) cleanupRemove(report *CleanupReport, dryRun bool, item CleanupItem) {
+	if !dryRun {
+		if err := os.RemoveAll(item.Path); err != nil {
+			c.printf("  ⚠️  Failed to remove %s: %v\n", item.Path, err)
+			return
+		}
+	}
+	report.Items = append(report.Items, item)
+}
+
+func dirSize(path string) int64 {
+	var size int64
+	filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,184 @@
+package brew
+
+import (
+	"fastbrew/internal/paths"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newCleanupTestClient(t *testing.T) (*Client, string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	prefix := t.TempDir()
+	return &Client{Prefix: prefix, Cellar: filepath.Join(prefix, "Cellar")}, paths.CacheDir()
+}
+
+func makeAgedKeg(t *testing.T, client *Client, name, version string, age time.Duration) string {
+	t.Helper()
+	keg := filepath.Join(client.Cellar, name, version)
+	if err := os.MkdirAll(filepath.Join(keg, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(keg, "bin", name), []byte("binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Now().Add(-age)
+	os.Chtimes(keg, mtime, mtime)
+	return keg
+}
+
+func writeAged(t *testing.T, path string, age time.Duration) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Now().Add(-age)
+	os.Chtimes(path, mtime, mtime)
+}
+
+func TestCleanupRemovesOldKegs(t *testing.T) {
+	client, _ := newCleanupTestClient(t)
+	day := 24 * time.Hour
+
+	old := makeAgedKeg(t, client, "wget", "1.9", 30*day)
+	older := makeAgedKeg(t, client, "wget", "1.10", 20*day)
+	latest := makeAgedKeg(t, client, "wget", "1.21", day)
+	pinnedOld := makeAgedKeg(t, client, "node", "18.0.0", 30*day)
+	makeAgedKeg(t, client, "node", "20.0.0", day)
+
+	report, err := client.Cleanup(CleanupOptions{DryRun: true, Skip: map[string]bool{"node": true}})
+	if err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	if len(report.Items) != 2 {
+		t.Fatalf("dry run reported %d items, want 2: %+v", len(report.Items), report.Items)
+	}
+	if _, err := os.Stat(old); err != nil {
+		t.Error("dry run should not remove anything")
+	}
+
+	report, err = client.Cleanup(CleanupOptions{MaxAge: 25 * day, Skip: map[string]bool{"node": true}})
+	if err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	if len(report.Items) != 1 || report.Items[0].Version != "1.9" {
+		t.Fatalf("expected only wget 1.9 removed, got %+v", report.Items)
+	}
+	if report.BytesReclaimed() == 0 {
+		t.Error("expected reclaimed bytes to be counted")
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Error("wget 1.9 should be removed")
+	}
+	for _, keep := range []string{older, latest, pinnedOld} {
+		if _, err := os.Stat(keep); err != nil {
+			t.Errorf("%s should be kept", keep)
+		}
+	}
+}
+
+func TestCleanupKeepsLinkedVersion(t *testing.T) {
+	client, _ := newCleanupTestClient(t)
+
+	linked := makeAgedKeg(t, client, "python", "3.11.0", 0)
+	makeAgedKeg(t, client, "python", "3.12.0", 0)
+	os.MkdirAll(filepath.Join(client.Prefix, "opt"), 0755)
+	if err := os.Symlink(linked, filepath.Join(client.Prefix, "opt", "python")); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := client.Cleanup(CleanupOptions{})
+	if err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	if len(report.Items) != 0 {
+		t.Errorf("linked version should be kept, removed %+v", report.Items)
+	}
+}
+
+func TestCleanupRemovesEmptyCellarDirs(t *testing.T) {
+	client, _ := newCleanupTestClient(t)
+	makeAgedKeg(t, client, "wget", "1.21", 0)
+	empty := filepath.Join(client.Cellar, "curl")
+	if err := os.MkdirAll(empty, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := client.Cleanup(CleanupOptions{})
+	if err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	if len(report.Items) != 1 || report.Items[0].Path != empty || report.Items[0].Version != "" {
+		t.Fatalf("expected only the empty curl directory removed, got %+v", report.Items)
+	}
+	if _, err := os.Stat(empty); !os.IsNotExist(err) {
+		t.Error("empty Cellar directory should be removed")
+	}
+}
+
+func TestCleanupPrunesCache(t *testing.T) {
+	client, cacheDir := newCleanupTestClient(t)
+	day := 24 * time.Hour
+
+	oldBottle := filepath.Join(cacheDir, "wget-1.21.bottle")
+	newBottle := filepath.Join(cacheDir, "jq-1.7.bottle")
+	partial := filepath.Join(cacheDir, "curl-8.0.bottle")
+	index := filepath.Join(cacheDir, "formula.json.zst")
+	writeAged(t, oldBottle, 10*day)
+	writeAged(t, newBottle, day)
+	writeAged(t, partial, 10*day)
+	writeAged(t, partial+".fastbrew-resume", 10*day)
+	writeAged(t, index, 10*day)
+
+	report, err := client.Cleanup(CleanupOptions{MaxAge: 7 * day})
+	if err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+
+	kinds := make(map[string]int)
+	for _, item := range report.Items {
+		kinds[item.Kind]++
+	}
+	if kinds[CleanupKindCache] != 1 || kinds[CleanupKindResume] != 2 {
+		t.Errorf("unexpected cleanup items: %+v", report.Items)
+	}
+	for _, gone := range []string{oldBottle, partial, partial + ".fastbrew-resume"} {
+		if _, err := os.Stat(gone); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed", gone)
+		}
+	}
+	for _, kept := range []string{newBottle, index} {
+		if _, err := os.Stat(kept); err != nil {
+			t.Errorf("%s should be kept", kept)
+		}
+	}
+}
+
+func TestCleanupRemovesStaleExtractionDirs(t *testing.T) {
+	client, _ := newCleanupTestClient(t)
+	stale := makeAgedKeg(t, client, extractTempPrefix+"wget-1", "wget", 0)
+	fresh := makeAgedKeg(t, client, extractTempPrefix+"jq-2", "jq", 0)
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(filepath.Dir(stale), old, old)
+
+	report, err := client.Cleanup(CleanupOptions{})
+	if err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	if len(report.Items) != 1 || report.Items[0].Kind != CleanupKindTemp || report.Items[0].Path != filepath.Dir(stale) {
+		t.Errorf("Expected only the stale extraction directory removed, got %+v", report.Items)
+	}
+	if _, err := os.Stat(filepath.Dir(fresh)); err != nil {
+		t.Errorf("Extraction directory of a running install was removed: %v", err)
+	}
+
+	if dirs, err := client.RemoveStaleExtractionTempDirs(); err != nil || len(dirs) != 0 {
+		t.Errorf("RemoveStaleExtractionTempDirs() = %v, %v after cleanup", dirs, err)
+	}
+}
@@ -1,29 +1,128 @@
 package brew
 
 import (
+	"fastbrew/internal/auth"
+	"fastbrew/internal/cmdrunner"
+	"fastbrew/internal/download"
+	"fastbrew/internal/httpclient"
+	"fastbrew/internal/log"
+	"fastbrew/internal/oci"
+	"fastbrew/internal/paths"
 	"fastbrew/internal/progress"
+	"fastbrew/internal/retry"
 	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Client struct {
-	Prefix          string
-	Cellar          string
-	Verbose         bool
-	MaxParallel     int
+	Prefix      string
+	Cellar      string
+	Verbose     bool
+	MaxParallel int
+	// VerifyAttestations requires a valid build provenance attestation
+	// for every bottle before it is extracted.
+	VerifyAttestations bool
+	// Mirrors maps an upstream host (e.g. "ghcr.io") to the base URL of a
+	// mirror that bottle and index downloads are redirected to.
+	Mirrors map[string]string
+	// Constraints maps a package name to a version constraint expression
+	// (see ParseVersionConstraint). Outdated packages whose new version it
+	// rules out are reported as held and not upgraded.
+	Constraints map[string]string
+	// Credentials authenticates downloads from private registries and
+	// bottle hosts (anonymous when nil).
+	Credentials     *auth.Store
 	ProgressManager *progress.Manager
-	index           *Index
-	indexErr        error
-	indexOnce       sync.Once
-	prefixIndex     *PrefixIndex
-	prefixIndexOnce sync.Once
-	invalidationMu  sync.RWMutex
-	onInvalidation  func(event string)
-	mutationMu      sync.RWMutex
-	onMutation      func(event MutationEvent)
+	// Out receives human-readable progress output (os.Stdout when nil).
+	// Callers that render structured output set it to io.Discard.
+	Out io.Writer
+	// Logger receives the structured debug log (discarded when nil).
+	Logger *slog.Logger
+	// HTTPClient sends the client's requests (httpclient.Get's shared
+	// client when nil). It replaces the Scheduler's client on first use.
+	HTTPClient *http.Client
+	// Runner runs the external tools the client shells out to, such as
+	// hdiutil and brew itself (os/exec when nil).
+	Runner cmdrunner.CommandRunner
+	// Scheduler gates all network fetches. A default honoring MaxParallel
+	// is created on first use when left nil.
+	Scheduler *download.Scheduler
+	// Breaker gives up on hosts that keep failing, across all parallel
+	// downloads. A default is created on first use when left nil.
+	Breaker *retry.Breaker
+	// TransactionDir overrides where install/upgrade journals are kept
+	// (transactions under paths.StateDir by default).
+	TransactionDir string
+	// CacheDir overrides where downloads and the package index are kept
+	// (paths.CacheDir by default).
+	CacheDir string
+	// RetryAttempts overrides how many times a bottle download is tried
+	// (4 by default).
+	RetryAttempts int
+	// IndexSnapshots is how many previous package indexes UpdateIndex
+	// keeps for RollbackIndex (3 when 0).
+	IndexSnapshots int
+	// ExtractWorkers is how many goroutines decompress and write each
+	// bottle's files (one per CPU, up to 8, when 0).
+	ExtractWorkers int
+	// DisableExtractCache unpacks every bottle from its archive instead of
+	// cloning bottles unpacked before from the cache.
+	DisableExtractCache bool
+	// DisableAPICache fetches formula and cask metadata from the API every
+	// time instead of reusing responses cached for a day.
+	DisableAPICache bool
+	// Hooks maps "<event>" and "<event>.<package>" to shell commands run
+	// around installs, upgrades and uninstalls (see RunHooks).
+	Hooks map[string]string
+	// HooksDir overrides where hook scripts are looked up
+	// (DefaultHooksDir by default).
+	HooksDir      string
+	hookMu        sync.Mutex
+	schedulerOnce sync.Once
+	breakerOnce   sync.Once
+	registry      *oci.Client
+	registryOnce  sync.Once
+	// The indexes below are loaded on first use and dropped by
+	// resetIndexState when the cached indexes change, each under its own
+	// mutex so that a reset is safe while other goroutines use them.
+	indexMu        sync.Mutex
+	index          *Index
+	indexErr       error
+	indexLoaded    bool
+	casksMu        sync.Mutex
+	casks          map[string]*Cask
+	casksErr       error
+	casksLoaded    bool
+	indexDBMu      sync.Mutex
+	indexDB        *IndexDB
+	indexDBErr     error
+	indexDBLoaded  bool
+	prefixIndexMu  sync.Mutex
+	prefixIndex    *PrefixIndex
+	caskMetaMu     sync.RWMutex
+	caskMeta       map[string]*CaskMetadata
+	invalidationMu sync.RWMutex
+	onInvalidation func(event string)
+	mutationMu     sync.RWMutex
+	onMutation     func(event MutationEvent)
+	txnMu          sync.Mutex
+	txn            *Transaction
+	reportMu       sync.Mutex
+	report         *runRecorder
+	// transferred counts bytes downloaded by downloadToFile, for reports.
+	transferred atomic.Int64
+	// managedPrefix is set when NewClient found no Homebrew and fell back
+	// to the prefix fastbrew manages, which ensurePrefix creates.
+	managedPrefix bool
 }
 
 const (
@@ -40,23 +139,143 @@ func (c *Client) getMaxParallel() int {
 	return c.MaxParallel
 }
 
+func (c *Client) scheduler() *download.Scheduler {
+	c.schedulerOnce.Do(func() {
+		if c.Scheduler == nil {
+			c.Scheduler = download.NewScheduler(download.Config{MaxConcurrent: c.getMaxParallel()})
+		}
+		if c.HTTPClient != nil {
+			c.Scheduler.SetHTTPClient(c.HTTPClient)
+		}
+	})
+	return c.Scheduler
+}
+
+func (c *Client) runner() cmdrunner.CommandRunner {
+	if c.Runner == nil {
+		return &cmdrunner.DefaultCommandRunner{}
+	}
+	return c.Runner
+}
+
+// httpClient returns the client for requests that bypass the scheduler,
+// such as registry token exchanges.
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return httpclient.Get()
+	}
+	return c.HTTPClient
+}
+
+// Defaults for the download circuit breaker: after this many consecutive
+// failed attempts a host is skipped for the cool-down.
+const (
+	breakerThreshold = 5
+	breakerCooldown  = 30 * time.Second
+)
+
+func (c *Client) breaker() *retry.Breaker {
+	c.breakerOnce.Do(func() {
+		if c.Breaker == nil {
+			c.Breaker = retry.NewBreaker(breakerThreshold, breakerCooldown)
+		}
+	})
+	return c.Breaker
+}
+
+// ClientOptions configures NewClientWithOptions. Left zero, each option
+// takes the default NewClient uses.
+type ClientOptions struct {
+	// Prefix is the Homebrew prefix, detected as NewClient does when empty.
+	Prefix string
+	// CacheDir is where downloads and the package index are kept
+	// (paths.CacheDir when empty).
+	CacheDir string
+	// HTTPClient sends the client's requests (httpclient.Get's shared
+	// client when nil).
+	HTTPClient *http.Client
+	// Logger receives the structured debug log (log.For("brew") when nil).
+	Logger *slog.Logger
+	// Concurrency is how many downloads run at once (4 when 0).
+	Concurrency int
+}
+
+// NewClient returns a client for the Homebrew prefix named by
+// HOMEBREW_PREFIX or found in the standard locations. When there is none,
+// the prefix fastbrew manages itself (paths.PrefixDir) is used, and created
+// by the first install.
 func NewClient() (*Client, error) {
-	if p := os.Getenv("HOMEBREW_PREFIX"); p != "" {
-		return &Client{Prefix: p, Cellar: filepath.Join(p, "Cellar")}, nil
+	return NewClientWithOptions(ClientOptions{})
+}
+
+// NewClientAt returns a client for the Homebrew installation at prefix,
+// skipping detection. An empty prefix is detected as NewClient does.
+func NewClientAt(prefix string) (*Client, error) {
+	return NewClientWithOptions(ClientOptions{Prefix: prefix})
+}
+
+// NewClientWithOptions returns a client configured by opts. Clients share
+// no state but the HTTP client's connection pool, so several can run side
+// by side, each with its own prefix and cache directory.
+func NewClientWithOptions(opts ClientOptions) (*Client, error) {
+	prefix := opts.Prefix
+	managed := false
+	if prefix == "" {
+		prefix, managed = detectPrefix()
+	} else {
+		info, err := os.Stat(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("brew prefix %s: %w", prefix, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("brew prefix %s is not a directory", prefix)
+		}
 	}
 
-	if _, err := os.Stat("/home/linuxbrew/.linuxbrew"); err == nil {
-		return &Client{Prefix: "/home/linuxbrew/.linuxbrew", Cellar: "/home/linuxbrew/.linuxbrew/Cellar"}, nil
+	c := newClientAt(prefix)
+	c.managedPrefix = managed
+	c.CacheDir = opts.CacheDir
+	c.HTTPClient = opts.HTTPClient
+	c.MaxParallel = opts.Concurrency
+	if opts.Logger != nil {
+		c.Logger = opts.Logger
 	}
+	return c, nil
+}
 
-	if _, err := os.Stat("/opt/homebrew"); err == nil {
-		return &Client{Prefix: "/opt/homebrew", Cellar: "/opt/homebrew/Cellar"}, nil
+// detectPrefix finds the Homebrew prefix for NewClient, or falls back to
+// the prefix fastbrew manages itself, reporting managed.
+func detectPrefix() (prefix string, managed bool) {
+	if p := os.Getenv("HOMEBREW_PREFIX"); p != "" {
+		return p, false
+	}
+	for _, candidate := range []struct{ prefix, marker string }{
+		{"/home/linuxbrew/.linuxbrew", "/home/linuxbrew/.linuxbrew"},
+		{"/opt/homebrew", "/opt/homebrew"},
+		{"/usr/local", "/usr/local/Cellar"},
+	} {
+		if _, err := os.Stat(candidate.marker); err == nil {
+			return candidate.prefix, false
+		}
 	}
-	if _, err := os.Stat("/usr/local/Cellar"); err == nil {
-		return &Client{Prefix: "/usr/local", Cellar: "/usr/local/Cellar"}, nil
+
+	// Without Homebrew, fastbrew installs into a prefix of its own.
+	return paths.PrefixDir(), true
+}
+
+func newClientAt(prefix string) *Client {
+	return &Client{
+		Prefix: prefix,
+		Cellar: filepath.Join(prefix, "Cellar"),
+		Logger: log.For("brew"),
 	}
+}
 
-	return nil, fmt.Errorf("could not find brew prefix: no known prefix found. Set HOMEBREW_PREFIX environment variable")
+func (c *Client) logger() *slog.Logger {
+	if c.Logger == nil {
+		return log.Discard()
+	}
+	return c.Logger
 }
 
 // PackageInfo represents minimal info needed for listing/searching
@@ -67,6 +286,15 @@ type PackageInfo struct {
 	Installed   bool   `json:"installed"`
 	Version     string `json:"version"`
 	IsCask      bool   `json:"is_cask"`
+	// InstalledAsDependency is set for formulae pulled in by another
+	// formula rather than requested; see Client.InstallReason.
+	InstalledAsDependency bool `json:"installed_as_dependency,omitempty"`
+	// Versions lists every installed version of a formula, oldest first;
+	// Version is the newest.
+	Versions []string `json:"versions,omitempty"`
+	// LinkedVersion is the version opt/<name> points at, which differs
+	// from Version after `fastbrew switch` to an older one.
+	LinkedVersion string `json:"linked_version,omitempty"`
 }
 
 // ListInstalledNative returns installed packages by scanning Cellar and checking for casks
@@ -74,6 +302,7 @@ func (c *Client) ListInstalledNative() ([]PackageInfo, error) {
 	var packages []PackageInfo
 
 	// 1. Get formulae from Cellar
+	reasons, _ := LoadInstallReasons()
 	if _, err := os.Stat(c.Cellar); err == nil {
 		entries, err := os.ReadDir(c.Cellar)
 		if err != nil {
@@ -86,31 +315,24 @@ func (c *Client) ListInstalledNative() ([]PackageInfo, error) {
 			}
 			name := entry.Name()
 
-			// Get version from subdirectory
-			versionsDir := filepath.Join(c.Cellar, name)
-			vEntries, err := os.ReadDir(versionsDir)
-			if err != nil {
+			versions := kegVersions(filepath.Join(c.Cellar, name))
+			if len(versions) == 0 {
 				continue
 			}
+			sort.Slice(versions, func(a, b int) bool {
+				return versionCompare(versions[a], versions[b]) < 0
+			})
 
-			if len(vEntries) == 0 {
-				continue
-			}
-
-			// Find latest version directory
-			latestVer := vEntries[len(vEntries)-1].Name()
-
-			// Skip hidden/system files if any
-			if strings.HasPrefix(latestVer, ".") {
-				continue
+			pkg := PackageInfo{
+				Name:          name,
+				Version:       versions[len(versions)-1],
+				Versions:      versions,
+				LinkedVersion: c.linkedVersion(name),
+				Installed:     true,
+				IsCask:        false,
 			}
-
-			packages = append(packages, PackageInfo{
-				Name:      name,
-				Version:   latestVer,
-				Installed: true,
-				IsCask:    false,
-			})
+			pkg.InstalledAsDependency = c.installReason(reasons, name) == ReasonDependency
+			packages = append(packages, pkg)
 		}
 	}
 
@@ -158,6 +380,14 @@ func (c *Client) EnableProgress() {
 	}
 }
 
+// expectDownloads tells the progress manager, when enabled, that n
+// downloads are about to be queued, so its aggregate counts them all.
+func (c *Client) expectDownloads(n int) {
+	if c.ProgressManager != nil {
+		c.ProgressManager.Expect(n)
+	}
+}
+
 func (c *Client) DisableProgress() {
 	if c.ProgressManager != nil {
 		c.ProgressManager.Close()
@@ -0,0 +1,130 @@
+package brew
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// countingTransport counts the requests sent through it.
+type countingTransport struct {
+	requests atomic.Int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests.Add(1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// newIndexServer serves a formula index listing the given formula.
+func newIndexServer(t *testing.T, formula string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/cask.json" {
+			_, _ = w.Write([]byte(`[]`))
+			return
+		}
+		fmt.Fprintf(w, `[{"name": %q, "desc": "test formula", "versions": {"stable": "1.0"}}]`, formula)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestNewClientWithOptions(t *testing.T) {
+	prefix := t.TempDir()
+	cacheDir := t.TempDir()
+	transport := &countingTransport{}
+	client, err := NewClientWithOptions(ClientOptions{
+		Prefix:      prefix,
+		CacheDir:    cacheDir,
+		HTTPClient:  &http.Client{Transport: transport},
+		Concurrency: 2,
+	})
+	if err != nil {
+		t.Fatalf("NewClientWithOptions failed: %v", err)
+	}
+	if client.Cellar != filepath.Join(prefix, "Cellar") || client.getMaxParallel() != 2 || client.Logger == nil {
+		t.Errorf("options not applied: cellar=%s parallel=%d", client.Cellar, client.getMaxParallel())
+	}
+	if dir, _ := client.GetCacheDir(); dir != cacheDir {
+		t.Errorf("GetCacheDir() = %s, want %s", dir, cacheDir)
+	}
+
+	client.Out = &bytes.Buffer{}
+	client.Mirrors = map[string]string{"formulae.brew.sh": newIndexServer(t, "wget").URL}
+	if _, err := client.UpdateIndex(IndexUpdateOptions{}); err != nil {
+		t.Fatalf("UpdateIndex failed: %v", err)
+	}
+	if transport.requests.Load() == 0 {
+		t.Error("requests did not go through the configured HTTP client")
+	}
+
+	if _, err := NewClientWithOptions(ClientOptions{Prefix: filepath.Join(prefix, "missing")}); err == nil {
+		t.Error("expected an error for a missing prefix")
+	}
+}
+
+func TestClientsWithSeparateCacheDirs(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	newClient := func(formula string) *Client {
+		client, err := NewClientWithOptions(ClientOptions{Prefix: t.TempDir(), CacheDir: t.TempDir()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		client.Out = &bytes.Buffer{}
+		client.Mirrors = map[string]string{"formulae.brew.sh": newIndexServer(t, formula).URL}
+		return client
+	}
+	clients := map[string]*Client{"wget": newClient("wget"), "jq": newClient("jq")}
+
+	var wg sync.WaitGroup
+	for formula, client := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results, err := client.SearchFuzzyWithIndex(formula)
+			if err != nil || len(results) != 1 || results[0].Name != formula {
+				t.Errorf("search for %s in its own cache = %v, %v", formula, results, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestIndexResetWhileInUse(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	client, err := NewClientWithOptions(ClientOptions{Prefix: t.TempDir(), CacheDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Out = &bytes.Buffer{}
+	client.Mirrors = map[string]string{"formulae.brew.sh": newIndexServer(t, "wget").URL}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if i%2 == 0 {
+				client.resetIndexState()
+				return
+			}
+			if _, err := client.GetPrefixIndex(); err != nil {
+				t.Errorf("GetPrefixIndex failed: %v", err)
+			}
+			if _, err := client.LoadIndex(); err != nil {
+				t.Errorf("LoadIndex failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if ok, err := client.IsCask("wget"); err != nil || ok {
+		t.Errorf("IsCask(wget) = %v, %v after resets", ok, err)
+	}
+}
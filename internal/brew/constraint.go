@@ -0,0 +1,133 @@
+package brew
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VersionConstraint limits which versions of a package upgrades may move
+// to, e.g. "<21", ">=16, <17" or "=16.*". Clauses are separated by commas
+// and must all hold.
+type VersionConstraint struct {
+	expr    string
+	clauses []constraintClause
+}
+
+type constraintClause struct {
+	op      string
+	version string
+	// prefix is set for "16.*": the clause matches 16 and anything
+	// starting with "16.".
+	prefix bool
+}
+
+// constraintOps is ordered so two-character operators are tried first.
+var constraintOps = []string{"<=", ">=", "!=", "==", "<", ">", "="}
+
+// ParseVersionConstraint parses a constraint expression. A clause without
+// an operator means "=".
+func ParseVersionConstraint(expr string) (*VersionConstraint, error) {
+	vc := &VersionConstraint{expr: strings.TrimSpace(expr)}
+	for _, part := range strings.Split(expr, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, fmt.Errorf("invalid constraint %q: empty clause", expr)
+		}
+		clause := constraintClause{op: "="}
+		for _, op := range constraintOps {
+			if rest, ok := strings.CutPrefix(part, op); ok {
+				clause.op, part = op, strings.TrimSpace(rest)
+				break
+			}
+		}
+		if clause.op == "==" {
+			clause.op = "="
+		}
+		if version, ok := strings.CutSuffix(part, ".*"); ok {
+			if clause.op != "=" && clause.op != "!=" {
+				return nil, fmt.Errorf("invalid constraint %q: wildcards only work with = and !=", expr)
+			}
+			clause.prefix, part = true, version
+		}
+		if part == "" || strings.ContainsAny(part, " <>=!*") {
+			return nil, fmt.Errorf("invalid constraint %q: expected an operator (<, <=, >, >=, =, !=) and a version", expr)
+		}
+		clause.version = part
+		vc.clauses = append(vc.clauses, clause)
+	}
+	return vc, nil
+}
+
+// Allows reports whether version satisfies every clause.
+func (vc *VersionConstraint) Allows(version string) bool {
+	for _, clause := range vc.clauses {
+		if !clause.allows(version) {
+			return false
+		}
+	}
+	return true
+}
+
+func (vc *VersionConstraint) String() string {
+	return vc.expr
+}
+
+func (cl constraintClause) allows(version string) bool {
+	if cl.prefix {
+		v := stripRevision(version)
+		match := v == cl.version || strings.HasPrefix(v, cl.version+".")
+		return match == (cl.op == "=")
+	}
+	cmp := versionCompare(version, cl.version)
+	switch cl.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "!=":
+		return cmp != 0
+	default:
+		return cmp == 0
+	}
+}
+
+// MarkHeld flags the packages in outdated whose new version is ruled out
+// by their entry in constraints (package name to expression). Invalid
+// expressions are ignored; `fastbrew config set` rejects them.
+func MarkHeld(outdated []OutdatedPackage, constraints map[string]string) {
+	for i := range outdated {
+		expr, ok := constraints[outdated[i].Name]
+		if !ok {
+			continue
+		}
+		vc, err := ParseVersionConstraint(expr)
+		if err != nil {
+			continue
+		}
+		if !vc.Allows(outdated[i].NewVersion) {
+			outdated[i].Held = true
+			outdated[i].Constraint = vc.String()
+		}
+	}
+}
+
+// holdBack returns the packages in outdated that are not held, reporting
+// each held one and why it is skipped.
+func (c *Client) holdBack(outdated []OutdatedPackage) []OutdatedPackage {
+	MarkHeld(outdated, c.Constraints)
+	var kept []OutdatedPackage
+	for _, pkg := range outdated {
+		if !pkg.Held {
+			kept = append(kept, pkg)
+			continue
+		}
+		reason := fmt.Sprintf("held back: %s is outside constraint %q", pkg.NewVersion, pkg.Constraint)
+		c.printf("⏸️  Holding %s at %s (%s)\n", pkg.Name, pkg.CurrentVersion, reason)
+		c.emitMutation(MutationOperationUpgrade, pkg.Name, MutationPhaseComplete, MutationStatusSkipped, reason, 0, 0, "")
+	}
+	return kept
+}
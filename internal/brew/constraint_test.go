@@ -0,0 +1,89 @@
+package brew
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestVersionConstraintAllows(t *testing.T) {
+	tests := []struct {
+		expr    string
+		version string
+		want    bool
+	}{
+		{"<21", "20.11.1", true},
+		{"<21", "21.0.0", false},
+		{"<21", "22.1.0", false},
+		{"<= 21.2", "21.2", true},
+		{">=16, <17", "16.4_1", true},
+		{">=16, <17", "17.0", false},
+		{"=16.*", "16.4", true},
+		{"=16.*", "16", true},
+		{"=16.*", "160.1", false},
+		{"!=3.12.*", "3.12.1", false},
+		{"!=3.12.*", "3.13.0", true},
+		{"1.7.1", "1.7.1", true},
+		{"==1.7.1", "1.7.2", false},
+		{">1.6", "1.6_1", true},
+	}
+	for _, tt := range tests {
+		vc, err := ParseVersionConstraint(tt.expr)
+		if err != nil {
+			t.Errorf("ParseVersionConstraint(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		if got := vc.Allows(tt.version); got != tt.want {
+			t.Errorf("%q.Allows(%q) = %v, want %v", tt.expr, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestParseVersionConstraintRejectsInvalid(t *testing.T) {
+	for _, expr := range []string{"", "<", "<21,", "<16.*", "=> 3", "<<3", "=1 2"} {
+		if _, err := ParseVersionConstraint(expr); err == nil {
+			t.Errorf("Expected ParseVersionConstraint(%q) to fail", expr)
+		}
+	}
+}
+
+func TestMarkHeld(t *testing.T) {
+	outdated := []OutdatedPackage{
+		{Name: "node", CurrentVersion: "20.11.1", NewVersion: "21.6.1"},
+		{Name: "postgresql@16", CurrentVersion: "16.1", NewVersion: "16.2"},
+		{Name: "jq", CurrentVersion: "1.6", NewVersion: "1.7.1"},
+		{Name: "wget", CurrentVersion: "1.21", NewVersion: "1.24"},
+	}
+	MarkHeld(outdated, map[string]string{
+		"node":          "<21",
+		"postgresql@16": "=16.*",
+		"jq":            "not a constraint",
+	})
+
+	if !outdated[0].Held || outdated[0].Constraint != "<21" {
+		t.Errorf("Expected node to be held by <21, got %+v", outdated[0])
+	}
+	for _, pkg := range outdated[1:] {
+		if pkg.Held {
+			t.Errorf("Expected %s not to be held, got %+v", pkg.Name, pkg)
+		}
+	}
+}
+
+func TestUpgradeNativeSkipsHeldPackages(t *testing.T) {
+	client, _ := newCleanupTestClient(t)
+	var out bytes.Buffer
+	client.Out = &out
+	client.Constraints = map[string]string{"node": "<21"}
+
+	err := client.UpgradeNative(context.Background(), nil, []OutdatedPackage{
+		{Name: "node", CurrentVersion: "20.11.1", NewVersion: "21.6.1"},
+	})
+	if err != nil {
+		t.Fatalf("UpgradeNative failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Holding node at 20.11.1") || !strings.Contains(out.String(), `"<21"`) {
+		t.Errorf("Expected held explanation, got %q", out.String())
+	}
+}
@@ -0,0 +1,235 @@
+package brew
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Dependency types, as listed separately in formula JSON.
+const (
+	DependencyRuntime     = "runtime"
+	DependencyBuild       = "build"
+	DependencyTest        = "test"
+	DependencyRecommended = "recommended"
+	DependencyOptional    = "optional"
+)
+
+// DepsOptions controls which dependencies are followed. By default these
+// match a bottle install by brew: runtime and recommended dependencies.
+type DepsOptions struct {
+	// InstalledOnly drops dependencies that are not installed, along with
+	// everything reachable only through them.
+	InstalledOnly bool
+	// IncludeBuild adds build-time dependencies alongside runtime ones.
+	IncludeBuild bool
+	// IncludeTest adds dependencies only needed by the formula's tests.
+	IncludeTest bool
+	// IncludeOptional adds optional dependencies.
+	IncludeOptional bool
+	// SkipRecommended drops recommended dependencies.
+	SkipRecommended bool
+}
+
+// DepNode is a formula and its dependencies in a dependency tree.
+type DepNode struct {
+	Name      string
+	Installed bool
+	Build     bool
+	// Kind is the dependency type through which the parent needs it.
+	Kind     string
+	Children []*DepNode
+}
+
+// Deps returns the direct dependencies of a formula from the cached index.
+func (c *Client) Deps(name string, opts DepsOptions) ([]string, error) {
+	lookup, err := c.formulaLookup()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load index for dependency resolution: %w", err)
+	}
+	f, ok := lookup(name)
+	if !ok {
+		return nil, kindErrorf(KindNotFound, "formula %s not found in index", name)
+	}
+
+	var deps []string
+	for _, dep := range f.depList(opts) {
+		if opts.InstalledOnly && !c.isInstalled(dep.name) {
+			continue
+		}
+		deps = append(deps, dep.name)
+	}
+	return deps, nil
+}
+
+// DepsTree returns the full dependency tree of a formula from the cached
+// index. A dependency that would close a cycle is listed without children.
+func (c *Client) DepsTree(name string, opts DepsOptions) (*DepNode, error) {
+	lookup, err := c.formulaLookup()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load index for dependency resolution: %w", err)
+	}
+	if _, ok := lookup(name); !ok {
+		return nil, kindErrorf(KindNotFound, "formula %s not found in index", name)
+	}
+
+	onPath := make(map[string]bool)
+	var build func(name, kind string) *DepNode
+	build = func(name, kind string) *DepNode {
+		node := &DepNode{Name: name, Installed: c.isInstalled(name), Build: kind == DependencyBuild, Kind: kind}
+		f, ok := lookup(name)
+		if !ok || onPath[name] {
+			return node
+		}
+		onPath[name] = true
+		defer delete(onPath, name)
+
+		for _, dep := range f.depList(opts) {
+			if opts.InstalledOnly && !c.isInstalled(dep.name) {
+				continue
+			}
+			node.Children = append(node.Children, build(dep.name, dep.kind))
+		}
+		return node
+	}
+
+	return build(name, DependencyRuntime), nil
+}
+
+// Uses returns the formulae that list name as a direct runtime dependency,
+// sorted by name. With installedOnly, only installed formulae are checked,
+// which answers what would break if name were uninstalled.
+func (c *Client) Uses(name string, installedOnly bool) ([]string, error) {
+	var candidates []string
+	var directDeps func(name string) ([]string, bool)
+
+	if installedOnly {
+		installed, err := c.ListInstalledNative()
+		if err != nil {
+			return nil, err
+		}
+		for _, pkg := range installed {
+			if !pkg.IsCask {
+				candidates = append(candidates, pkg.Name)
+			}
+		}
+		directDeps, err = c.directDepsLookup()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load index for dependency resolution: %w", err)
+		}
+	} else {
+		idx, err := c.LoadIndex()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load index for dependency resolution: %w", err)
+		}
+		depsByName := make(map[string][]string, len(idx.Formulae))
+		for _, f := range idx.Formulae {
+			candidates = append(candidates, f.Name)
+			depsByName[f.Name] = f.Dependencies
+		}
+		directDeps = func(name string) ([]string, bool) {
+			deps, ok := depsByName[name]
+			return deps, ok
+		}
+	}
+
+	var users []string
+	for _, candidate := range candidates {
+		deps, _ := directDeps(candidate)
+		for _, dep := range deps {
+			if dep == name {
+				users = append(users, candidate)
+				break
+			}
+		}
+	}
+	sort.Strings(users)
+	return users, nil
+}
+
+type formulaDep struct {
+	name string
+	kind string
+}
+
+// depList returns the dependencies of f that opts selects.
+func (f *Formula) depList(opts DepsOptions) []formulaDep {
+	return dependencyList(opts, f.Dependencies, f.RecommendedDependencies, f.OptionalDependencies, f.BuildDependencies, f.TestDependencies)
+}
+
+// depList returns the dependencies of f that opts selects.
+func (f *RemoteFormula) depList(opts DepsOptions) []formulaDep {
+	return dependencyList(opts, f.Dependencies, f.RecommendedDependencies, f.OptionalDependencies, f.BuildDependencies, f.TestDependencies)
+}
+
+// depNames returns the names of the dependencies of f that opts selects.
+func (f *RemoteFormula) depNames(opts DepsOptions) []string {
+	deps := f.depList(opts)
+	names := make([]string, len(deps))
+	for i, dep := range deps {
+		names[i] = dep.name
+	}
+	return names
+}
+
+// dependencyList merges dependency lists in the order runtime,
+// recommended, optional, build, test, keeping each name once under the
+// first type that lists it.
+func dependencyList(opts DepsOptions, runtime, recommended, optional, build, test []string) []formulaDep {
+	deps := make([]formulaDep, 0, len(runtime)+len(recommended))
+	seen := make(map[string]bool, len(runtime)+len(recommended))
+	add := func(names []string, kind string) {
+		for _, name := range names {
+			if !seen[name] {
+				seen[name] = true
+				deps = append(deps, formulaDep{name: name, kind: kind})
+			}
+		}
+	}
+
+	add(runtime, DependencyRuntime)
+	if !opts.SkipRecommended {
+		add(recommended, DependencyRecommended)
+	}
+	if opts.IncludeOptional {
+		add(optional, DependencyOptional)
+	}
+	if opts.IncludeBuild {
+		add(build, DependencyBuild)
+	}
+	if opts.IncludeTest {
+		add(test, DependencyTest)
+	}
+	return deps
+}
+
+// formulaLookup returns a resolver for indexed formulae, which also accepts
+// aliases and old names. An index already held in memory is used as-is;
+// otherwise lookups go to the index database, falling back to loading the
+// full index.
+func (c *Client) formulaLookup() (func(name string) (*Formula, bool), error) {
+	if c.index == nil {
+		if db, err := c.OpenIndexDB(); err == nil {
+			return func(name string) (*Formula, bool) {
+				f, err := db.Formula(name)
+				return f, err == nil
+			}, nil
+		}
+	}
+
+	idx, err := c.LoadIndex()
+	if err != nil {
+		return nil, err
+	}
+	formulaMap := make(map[string]*Formula, len(idx.Formulae))
+	for i := range idx.Formulae {
+		formulaMap[idx.Formulae[i].Name] = &idx.Formulae[i]
+	}
+	aliases := idx.formulaAliases()
+	return func(name string) (*Formula, bool) {
+		f, ok := formulaMap[name]
+		if !ok {
+			f, ok = formulaMap[aliases[name]]
+		}
+		return f, ok
+	}, nil
+}
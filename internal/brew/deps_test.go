@@ -0,0 +1,187 @@
+package brew
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newDepsTestClient(t *testing.T, formulae []Formula, installed ...string) *Client {
+	t.Helper()
+	prefix := t.TempDir()
+	client := &Client{
+		Prefix: prefix,
+		Cellar: filepath.Join(prefix, "Cellar"),
+		index:  &Index{Formulae: formulae, Casks: []Cask{}},
+	}
+	client.indexLoaded = true
+	for _, name := range installed {
+		if err := os.MkdirAll(filepath.Join(client.Cellar, name, "1.0"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return client
+}
+
+func depsTestFormulae() []Formula {
+	return []Formula{
+		{Name: "git", Dependencies: []string{"curl", "pcre2"}, BuildDependencies: []string{"pkgconf"}},
+		{Name: "curl", Dependencies: []string{"openssl@3"}},
+		{Name: "openssl@3", Dependencies: []string{"ca-certificates"}},
+		{Name: "ca-certificates"},
+		{Name: "pcre2"},
+		{Name: "pkgconf"},
+	}
+}
+
+func TestDeps(t *testing.T) {
+	client := newDepsTestClient(t, depsTestFormulae(), "pcre2")
+
+	tests := []struct {
+		opts DepsOptions
+		want []string
+	}{
+		{DepsOptions{}, []string{"curl", "pcre2"}},
+		{DepsOptions{IncludeBuild: true}, []string{"curl", "pcre2", "pkgconf"}},
+		{DepsOptions{InstalledOnly: true}, []string{"pcre2"}},
+	}
+	for _, tt := range tests {
+		got, err := client.Deps("git", tt.opts)
+		if err != nil {
+			t.Fatalf("Deps(%+v) failed: %v", tt.opts, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Deps(%+v) = %v, want %v", tt.opts, got, tt.want)
+		}
+	}
+
+	if _, err := client.Deps("missing", DepsOptions{}); err == nil {
+		t.Error("expected error for unknown formula")
+	}
+}
+
+func TestDepsTree(t *testing.T) {
+	client := newDepsTestClient(t, depsTestFormulae(), "curl")
+
+	root, err := client.DepsTree("git", DepsOptions{IncludeBuild: true})
+	if err != nil {
+		t.Fatalf("DepsTree failed: %v", err)
+	}
+	if len(root.Children) != 3 {
+		t.Fatalf("expected 3 children of git, got %d", len(root.Children))
+	}
+	curl := root.Children[0]
+	if curl.Name != "curl" || !curl.Installed {
+		t.Errorf("first child = %+v, want installed curl", curl)
+	}
+	if len(curl.Children) != 1 || curl.Children[0].Name != "openssl@3" || len(curl.Children[0].Children) != 1 {
+		t.Errorf("curl subtree not resolved: %+v", curl.Children)
+	}
+	if pkgconf := root.Children[2]; pkgconf.Name != "pkgconf" || !pkgconf.Build {
+		t.Errorf("last child = %+v, want build dependency pkgconf", pkgconf)
+	}
+
+	installedOnly, err := client.DepsTree("git", DepsOptions{InstalledOnly: true})
+	if err != nil {
+		t.Fatalf("DepsTree failed: %v", err)
+	}
+	if len(installedOnly.Children) != 1 || len(installedOnly.Children[0].Children) != 0 {
+		t.Errorf("installed-only tree should contain just curl, got %+v", installedOnly.Children)
+	}
+}
+
+func TestDepsDependencyTypes(t *testing.T) {
+	client := newDepsTestClient(t, []Formula{
+		{
+			Name:                    "ffmpeg",
+			Dependencies:            []string{"x264"},
+			RecommendedDependencies: []string{"lame"},
+			OptionalDependencies:    []string{"rtmpdump"},
+			BuildDependencies:       []string{"nasm"},
+			TestDependencies:        []string{"x264", "bats"},
+		},
+		{Name: "x264"},
+		{Name: "lame", BuildDependencies: []string{"nasm"}},
+		{Name: "rtmpdump"},
+		{Name: "nasm"},
+		{Name: "bats"},
+	})
+
+	tests := []struct {
+		opts DepsOptions
+		want []string
+	}{
+		{DepsOptions{}, []string{"x264", "lame"}},
+		{DepsOptions{SkipRecommended: true}, []string{"x264"}},
+		{DepsOptions{IncludeOptional: true}, []string{"x264", "lame", "rtmpdump"}},
+		{DepsOptions{IncludeBuild: true}, []string{"x264", "lame", "nasm"}},
+		{DepsOptions{IncludeTest: true}, []string{"x264", "lame", "bats"}},
+	}
+	for _, tt := range tests {
+		got, err := client.Deps("ffmpeg", tt.opts)
+		if err != nil {
+			t.Fatalf("Deps(%+v) failed: %v", tt.opts, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Deps(%+v) = %v, want %v", tt.opts, got, tt.want)
+		}
+	}
+
+	root, err := client.DepsTree("ffmpeg", DepsOptions{IncludeBuild: true})
+	if err != nil {
+		t.Fatalf("DepsTree failed: %v", err)
+	}
+	if lame := root.Children[1]; lame.Kind != DependencyRecommended || len(lame.Children) != 1 || lame.Children[0].Kind != DependencyBuild {
+		t.Errorf("lame = %+v, want recommended with a build dependency", lame)
+	}
+
+	resolved, err := client.ResolveDepsWithOptions([]string{"ffmpeg"}, DepsOptions{SkipRecommended: true, IncludeBuild: true})
+	if err != nil {
+		t.Fatalf("ResolveDepsWithOptions failed: %v", err)
+	}
+	if want := []string{"x264", "nasm"}; !reflect.DeepEqual(resolved, want) {
+		t.Errorf("ResolveDepsWithOptions = %v, want %v", resolved, want)
+	}
+}
+
+func TestDepsTreeCycle(t *testing.T) {
+	client := newDepsTestClient(t, []Formula{
+		{Name: "a", Dependencies: []string{"b"}},
+		{Name: "b", Dependencies: []string{"a"}},
+	})
+
+	root, err := client.DepsTree("a", DepsOptions{})
+	if err != nil {
+		t.Fatalf("DepsTree failed: %v", err)
+	}
+	if len(root.Children) != 1 || len(root.Children[0].Children) != 1 || root.Children[0].Children[0].Children != nil {
+		t.Errorf("cycle should stop at the repeated formula, got %+v", root.Children)
+	}
+}
+
+func TestUses(t *testing.T) {
+	formulae := append(depsTestFormulae(), Formula{Name: "wget", Dependencies: []string{"openssl@3"}})
+	client := newDepsTestClient(t, formulae, "curl", "openssl@3")
+
+	got, err := client.Uses("openssl@3", false)
+	if err != nil {
+		t.Fatalf("Uses failed: %v", err)
+	}
+	if want := []string{"curl", "wget"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Uses(openssl@3) = %v, want %v", got, want)
+	}
+
+	got, err = client.Uses("openssl@3", true)
+	if err != nil {
+		t.Fatalf("Uses failed: %v", err)
+	}
+	if want := []string{"curl"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Uses(openssl@3, installed) = %v, want %v", got, want)
+	}
+
+	got, _ = client.Uses("pkgconf", false)
+	if len(got) != 0 {
+		t.Errorf("build dependencies should not count as uses, got %v", got)
+	}
+}
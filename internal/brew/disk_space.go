@@ -0,0 +1,169 @@
+package brew
+
+import (
+	"context"
+	"errors"
+	"fastbrew/internal/progress"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// extractedSizeRatio estimates how much room a bottle takes in the Cellar
+// per byte of its download: bottles are gzipped tarballs, which rarely
+// unpack to more than three times their size.
+const extractedSizeRatio = 3
+
+// DiskSpaceError is returned when a filesystem has less free space than an
+// install or upgrade is estimated to need.
+type DiskSpaceError struct {
+	// Path is the directory (the cache or the Cellar) on the filesystem.
+	Path      string
+	Needed    uint64
+	Available uint64
+}
+
+func (e *DiskSpaceError) Error() string {
+	return fmt.Sprintf("not enough disk space for %s: about %s needed, %s available (free some space or run 'fastbrew cleanup')",
+		e.Path, progress.FormatBytes(int64(e.Needed)), progress.FormatBytes(int64(e.Available)))
+}
+
+// spaceNeed is the room needed under a directory.
+type spaceNeed struct {
+	path  string
+	bytes uint64
+}
+
+// checkDiskSpace estimates the room downloading formulae takes in the cache
+// and extracting them takes in the Cellar, and returns a DiskSpaceError when
+// their filesystem has less free. Bottles of unknown size are left out, and
+// nothing is checked where the free space cannot be read.
+func (c *Client) checkDiskSpace(ctx context.Context, formulae []*RemoteFormula) error {
+	cacheDir, err := c.GetCacheDir()
+	if err != nil {
+		return nil
+	}
+
+	var (
+		mu                sync.Mutex
+		download, extract uint64
+		wg                sync.WaitGroup
+		sem               = make(chan struct{}, c.getMaxParallel())
+	)
+	for _, f := range formulae {
+		wg.Add(1)
+		go func(f *RemoteFormula) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			size, cached := c.bottleSize(ctx, f)
+			if size < 0 {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if !cached {
+				download += uint64(size)
+			}
+			extract += uint64(size) * extractedSizeRatio
+		}(f)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err = checkSpace([]spaceNeed{{cacheDir, download}, {c.Cellar, extract}}, diskSpace)
+	var spaceErr *DiskSpaceError
+	if err != nil && !errors.As(err, &spaceErr) {
+		c.logger().Debug("free disk space unavailable", "error", err)
+		return nil
+	}
+	return err
+}
+
+// checkSpace compares needs with the free space stat reports, adding up the
+// needs of directories on the same filesystem.
+func checkSpace(needs []spaceNeed, stat func(path string) (free, device uint64, err error)) error {
+	type filesystem struct {
+		path       string
+		free, need uint64
+	}
+	var filesystems []*filesystem
+	byDevice := map[uint64]*filesystem{}
+	for _, need := range needs {
+		if need.bytes == 0 {
+			continue
+		}
+		free, device, err := stat(need.path)
+		if err != nil {
+			return err
+		}
+		fs := byDevice[device]
+		if fs == nil {
+			fs = &filesystem{path: need.path, free: free}
+			byDevice[device] = fs
+			filesystems = append(filesystems, fs)
+		}
+		fs.need += need.bytes
+	}
+	for _, fs := range filesystems {
+		if fs.need > fs.free {
+			return &DiskSpaceError{Path: fs.path, Needed: fs.need, Available: fs.free}
+		}
+	}
+	return nil
+}
+
+// bottleSize returns the size of f's bottle and whether it is already
+// cached, or -1 when the size is not known. An uncached bottle's size comes
+// from its registry manifest, or else from a HEAD request.
+func (c *Client) bottleSize(ctx context.Context, f *RemoteFormula) (int64, bool) {
+	bottleURL, sha256Sum, err := f.GetBottleInfo()
+	if err != nil {
+		return -1, false
+	}
+	if cacheDir, err := c.GetCacheDir(); err == nil && sha256Sum != "" {
+		if info, err := os.Stat(filepath.Join(cacheDir, blobDirName, sha256Sum)); err == nil {
+			return info.Size(), true
+		}
+	}
+	if size, _ := c.bottleDownloadSize(ctx, f); size > 0 {
+		return size, false
+	}
+	return c.remoteSize(ctx, c.mirrorURL(bottleURL)), false
+}
+
+// remoteSize returns the Content-Length a HEAD request for url reports, or
+// -1 when it fails or reports none.
+func (c *Client) remoteSize(ctx context.Context, url string) int64 {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return -1
+	}
+	resp, err := c.doAuthorized(ctx, req)
+	if err != nil {
+		return -1
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return -1
+	}
+	return resp.ContentLength
+}
+
+// existingAncestor returns path, or its closest parent that exists.
+func existingAncestor(path string) string {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
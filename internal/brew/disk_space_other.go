@@ -0,0 +1,10 @@
+//go:build !unix
+
+package brew
+
+import "errors"
+
+// diskSpace is not supported where statfs is missing.
+func diskSpace(path string) (free, device uint64, err error) {
+	return 0, 0, errors.ErrUnsupported
+}
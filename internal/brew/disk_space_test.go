@@ -0,0 +1,42 @@
+package brew
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckSpace(t *testing.T) {
+	stat := func(devices map[string]uint64) func(string) (uint64, uint64, error) {
+		return func(path string) (uint64, uint64, error) {
+			return 1000, devices[path], nil
+		}
+	}
+	needs := []spaceNeed{{"/cache", 400}, {"/Cellar", 700}}
+
+	if err := checkSpace(needs, stat(map[string]uint64{"/cache": 1, "/Cellar": 2})); err != nil {
+		t.Errorf("separate filesystems with room: %v", err)
+	}
+
+	err := checkSpace(needs, stat(map[string]uint64{"/cache": 1, "/Cellar": 1}))
+	var spaceErr *DiskSpaceError
+	if !errors.As(err, &spaceErr) || spaceErr.Path != "/cache" || spaceErr.Needed != 1100 || spaceErr.Available != 1000 {
+		t.Errorf("shared filesystem: got %v", err)
+	}
+
+	failing := func(string) (uint64, uint64, error) { return 0, 0, errors.ErrUnsupported }
+	if err := checkSpace([]spaceNeed{{"/cache", 0}}, failing); err != nil {
+		t.Errorf("nothing needed should not stat: %v", err)
+	}
+}
+
+func TestDiskSpaceOfMissingDirectory(t *testing.T) {
+	dir := t.TempDir()
+	free, device, err := diskSpace(filepath.Join(dir, "Cellar", "wget"))
+	if err != nil {
+		t.Fatalf("diskSpace failed: %v", err)
+	}
+	if _, parentDevice, _ := diskSpace(dir); free == 0 || device != parentDevice {
+		t.Errorf("diskSpace() = %d free on device %d, want the parent's device %d", free, device, parentDevice)
+	}
+}
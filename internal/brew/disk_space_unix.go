@@ -0,0 +1,21 @@
+//go:build unix
+
+package brew
+
+import "golang.org/x/sys/unix"
+
+// diskSpace returns the bytes available to this user on the filesystem
+// holding path, or its closest existing parent, and that filesystem's
+// device number.
+func diskSpace(path string) (free, device uint64, err error) {
+	path = existingAncestor(path)
+	var fs unix.Statfs_t
+	if err := unix.Statfs(path, &fs); err != nil {
+		return 0, 0, err
+	}
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return 0, 0, err
+	}
+	return uint64(fs.Bavail) * uint64(fs.Bsize), uint64(st.Dev), nil
+}
@@ -1,7 +1,13 @@
 package brew
 
 import (
+	"context"
+	"errors"
+	"fastbrew/internal/log"
+	"fastbrew/internal/paths"
+	"fastbrew/internal/progress"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -25,12 +31,22 @@ type CheckResult struct {
 	Message    string
 	Suggestion string
 	Details    []string
+	// Fixed is set when the check repaired what it found (Doctor.Fix).
+	Fixed bool
 }
 
 type Doctor struct {
 	client  *Client
 	verbose bool
 	cache   map[string]interface{}
+	logger  *slog.Logger
+
+	// Verify adds a check of every keg against the manifest recorded when
+	// it was extracted, hashing all files. It is slow on large Cellars.
+	Verify bool
+	// Fix makes checks that can repair what they find do so: dangling opt
+	// links, empty Cellar directories and superseded kegs are removed.
+	Fix bool
 }
 
 func NewDoctor(client *Client, verbose bool) *Doctor {
@@ -38,12 +54,12 @@ func NewDoctor(client *Client, verbose bool) *Doctor {
 		client:  client,
 		verbose: verbose,
 		cache:   make(map[string]interface{}),
+		logger:  log.For("doctor"),
 	}
 }
 
 func (d *Doctor) RunDiagnostics() []CheckResult {
 	var wg sync.WaitGroup
-	results := make([]CheckResult, 9)
 	var mu sync.Mutex
 
 	type checkFunc struct {
@@ -62,13 +78,31 @@ func (d *Doctor) RunDiagnostics() []CheckResult {
 		{6, "Unlinked keg-only", d.checkUnlinkedKegOnly},
 		{7, "PATH configuration", d.checkPathConfiguration},
 		{8, "Cache integrity", d.checkCacheIntegrity},
+		{9, "Binary architecture", d.checkBinaryArchitecture},
+		{10, "Data directories", d.checkDataDirectories},
+		{11, "Dangling opt links", d.checkDanglingOptLinks},
+		{12, "Empty Cellar directories", d.checkEmptyCellarDirs},
+		{13, "Unreferenced kegs", d.checkUnreferencedKegs},
+		{14, "Partial downloads", d.checkPartialDownloads},
+		{15, "Shims", d.checkShims},
+		{16, "Extraction directories", d.checkExtractionTempDirs},
 	}
+	if d.Verify {
+		checks = append(checks, checkFunc{len(checks), "Keg integrity", d.checkKegIntegrity})
+	}
+	results := make([]CheckResult, len(checks))
 
 	for _, check := range checks {
 		wg.Add(1)
 		go func(cf checkFunc) {
 			defer wg.Done()
+			start := time.Now()
 			result := cf.fn()
+			level := slog.LevelDebug
+			if result.Status == StatusWarning || result.Status == StatusError {
+				level = slog.LevelWarn
+			}
+			d.logger.Log(context.Background(), level, "check finished", "check", cf.name, "status", string(result.Status), "message", result.Message, "duration", time.Since(start))
 			mu.Lock()
 			results[cf.index] = result
 			mu.Unlock()
@@ -76,11 +110,26 @@ func (d *Doctor) RunDiagnostics() []CheckResult {
 	}
 
 	wg.Wait()
+	if d.Fix {
+		for _, r := range results {
+			if r.Fixed {
+				d.client.notifyInvalidation(EventInstalledChanged)
+				break
+			}
+		}
+	}
 	return results
 }
 
 func (d *Doctor) checkHomebrewInstallation() CheckResult {
 	if _, err := os.Stat(d.client.Prefix); os.IsNotExist(err) {
+		if d.client.managedPrefix {
+			return CheckResult{
+				Name:    "Homebrew installation",
+				Status:  StatusInfo,
+				Message: fmt.Sprintf("Homebrew not found; the first install creates a fastbrew-managed prefix at %s", d.client.Prefix),
+			}
+		}
 		return CheckResult{
 			Name:       "Homebrew installation",
 			Status:     StatusError,
@@ -98,6 +147,13 @@ func (d *Doctor) checkHomebrewInstallation() CheckResult {
 		}
 	}
 
+	if IsManagedPrefix(d.client.Prefix) {
+		return CheckResult{
+			Name:    "Homebrew installation",
+			Status:  StatusOK,
+			Message: fmt.Sprintf("Using the fastbrew-managed prefix at %s", d.client.Prefix),
+		}
+	}
 	return CheckResult{
 		Name:    "Homebrew installation",
 		Status:  StatusOK,
@@ -224,9 +280,11 @@ func (d *Doctor) checkOutdatedIndex() CheckResult {
 	}
 }
 
+// lowDiskSpace is the free space below which doctor warns.
+const lowDiskSpace = 1 << 30
+
 func (d *Doctor) checkDiskSpace() CheckResult {
-	cmd := exec.Command("df", "-h", d.client.Prefix)
-	output, err := cmd.Output()
+	free, _, err := diskSpace(d.client.Prefix)
 	if err != nil {
 		return CheckResult{
 			Name:    "Disk space",
@@ -234,30 +292,19 @@ func (d *Doctor) checkDiskSpace() CheckResult {
 			Message: "Unable to check disk space",
 		}
 	}
-
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	if len(lines) < 2 {
+	available := progress.FormatBytes(int64(free))
+	if free < lowDiskSpace {
 		return CheckResult{
-			Name:    "Disk space",
-			Status:  StatusOK,
-			Message: "Unable to parse disk space",
-		}
-	}
-
-	fields := strings.Fields(lines[len(lines)-1])
-	if len(fields) >= 4 {
-		available := fields[3]
-		return CheckResult{
-			Name:    "Disk space",
-			Status:  StatusOK,
-			Message: fmt.Sprintf("%s available", available),
+			Name:       "Disk space",
+			Status:     StatusWarning,
+			Message:    fmt.Sprintf("Only %s available", available),
+			Suggestion: "Run: fastbrew cleanup",
 		}
 	}
-
 	return CheckResult{
 		Name:    "Disk space",
 		Status:  StatusOK,
-		Message: "Disk space check completed",
+		Message: fmt.Sprintf("%s available", available),
 	}
 }
 
@@ -410,18 +457,19 @@ func (d *Doctor) checkPathConfiguration() CheckResult {
 	}
 
 	if !found {
-		return CheckResult{
-			Name:       "PATH configuration",
-			Status:     StatusWarning,
-			Message:    fmt.Sprintf("%s not in PATH", binPath),
-			Suggestion: fmt.Sprintf("Add 'export PATH=\"%s:$PATH\"' to your shell config", binPath),
-		}
+		return d.shellRCResult(binPath)
 	}
 
+	// The shim directory belongs before bin, so it does not count.
+	shimDir := d.client.ShimDir()
 	idx := -1
-	for i, p := range paths {
-		if strings.TrimSpace(p) == binPath {
-			idx = i
+	for _, p := range paths {
+		p = strings.TrimSpace(p)
+		if p == shimDir {
+			continue
+		}
+		idx++
+		if p == binPath {
 			break
 		}
 	}
@@ -442,51 +490,498 @@ func (d *Doctor) checkPathConfiguration() CheckResult {
 	}
 }
 
-func (d *Doctor) checkCacheIntegrity() CheckResult {
-	cacheDir, err := d.client.GetCacheDir()
+// shellRCResult reports a prefix missing from PATH, naming the rc file of
+// the user's shell. With Fix the shellenv block is appended to it.
+func (d *Doctor) shellRCResult(binPath string) CheckResult {
+	const name = "PATH configuration"
+	shell := DetectShell()
+	home, err := os.UserHomeDir()
 	if err != nil {
 		return CheckResult{
-			Name:    "Cache integrity",
-			Status:  StatusOK,
-			Message: "Cache directory not available",
+			Name:       name,
+			Status:     StatusWarning,
+			Message:    fmt.Sprintf("%s not in PATH", binPath),
+			Suggestion: "Add the output of 'fastbrew shellenv' to your shell config",
 		}
 	}
+	rc := ShellRCFile(shell, home)
+
+	if shellRCConfigured(rc, d.client.Prefix) {
+		return CheckResult{
+			Name:       name,
+			Status:     StatusWarning,
+			Message:    fmt.Sprintf("%s not in PATH, though %s sets it up", binPath, rc),
+			Suggestion: "Restart your shell, or run: source " + rc,
+		}
+	}
+	if d.Fix {
+		if err := appendShellEnv(rc, d.client.Prefix, shell); err != nil {
+			return CheckResult{
+				Name:       name,
+				Status:     StatusError,
+				Message:    fmt.Sprintf("%s not in PATH and %s could not be updated: %v", binPath, rc, err),
+				Suggestion: fmt.Sprintf("Add the output of 'fastbrew shellenv %s' to %s", shell, rc),
+			}
+		}
+		return CheckResult{
+			Name:       name,
+			Status:     StatusOK,
+			Message:    fmt.Sprintf("Added shellenv to %s; restart your shell to use it", rc),
+			Suggestion: "Run: source " + rc,
+			Fixed:      true,
+		}
+	}
+	return CheckResult{
+		Name:       name,
+		Status:     StatusWarning,
+		Message:    fmt.Sprintf("%s not in PATH (%s, %s)", binPath, shell, rc),
+		Suggestion: fmt.Sprintf("Run: fastbrew shellenv %s >> %s (or fastbrew doctor --fix)", shell, rc),
+	}
+}
+
+func (d *Doctor) checkCacheIntegrity() CheckResult {
+	const name = "Cache integrity"
+	validator, err := d.client.CacheValidator()
+	if err != nil {
+		return CheckResult{Name: name, Status: StatusOK, Message: "Cache directory not available"}
+	}
+	if d.Fix {
+		return d.repairCache(validator)
+	}
 
-	validator := NewCacheValidator(cacheDir)
 	statuses, err := validator.ValidateAll()
 	if err != nil {
+		return CheckResult{Name: name, Status: StatusOK, Message: "Unable to validate cache files"}
+	}
+	var details []string
+	for _, status := range statuses {
+		// Resume metadata of deleted partial files is checkPartialDownloads'.
+		if !status.Valid && status.Error != errPartialFileMissing {
+			details = append(details, fmt.Sprintf("%s (%s): %v", filepath.Base(status.Path), status.Artifact, status.Error))
+		}
+	}
+	if len(details) > 0 {
 		return CheckResult{
-			Name:    "Cache integrity",
+			Name:       name,
+			Status:     StatusError,
+			Message:    fmt.Sprintf("%d cache file(s) corrupted or invalid", len(details)),
+			Suggestion: "Run: fastbrew doctor --fix (replaces only the broken files)",
+			Details:    details,
+		}
+	}
+	return CheckResult{Name: name, Status: StatusOK, Message: "All cache files valid"}
+}
+
+// repairCache is checkCacheIntegrity with Doctor.Fix.
+func (d *Doctor) repairCache(validator *CacheValidator) CheckResult {
+	const name = "Cache integrity"
+	repairs, err := validator.Repair()
+	if err != nil {
+		return CheckResult{Name: name, Status: StatusOK, Message: "Unable to validate cache files"}
+	}
+	var details, failed []string
+	for _, repair := range repairs {
+		if repair.Problem == errPartialFileMissing {
+			continue
+		}
+		if repair.Err != nil {
+			failed = append(failed, fmt.Sprintf("%s (%s): %v", filepath.Base(repair.Path), repair.Artifact, repair.Err))
+			continue
+		}
+		details = append(details, fmt.Sprintf("%s (%s): %s", filepath.Base(repair.Path), repair.Artifact, repair.Action))
+	}
+	if len(failed) > 0 {
+		return CheckResult{
+			Name:       name,
+			Status:     StatusError,
+			Message:    fmt.Sprintf("Repaired %d of %d broken cache file(s)", len(details), len(details)+len(failed)),
+			Suggestion: "Check your network connection and run: fastbrew doctor --fix",
+			Details:    append(failed, details...),
+			Fixed:      len(details) > 0,
+		}
+	}
+	if len(details) == 0 {
+		return CheckResult{Name: name, Status: StatusOK, Message: "All cache files valid"}
+	}
+	return CheckResult{
+		Name:    name,
+		Status:  StatusOK,
+		Message: fmt.Sprintf("Repaired %d broken cache file(s)", len(details)),
+		Details: details,
+		Fixed:   true,
+	}
+}
+
+// checkPartialDownloads finds resume metadata whose partial file is gone,
+// left behind when the file was deleted by hand.
+func (d *Doctor) checkPartialDownloads() CheckResult {
+	const name = "Partial downloads"
+	downloads, err := d.client.PartialDownloads()
+	if err != nil {
+		return CheckResult{Name: name, Status: StatusOK, Message: "Unable to read resume metadata"}
+	}
+	var stale []string
+	for _, pd := range downloads {
+		if _, err := os.Stat(pd.LocalPath); os.IsNotExist(err) {
+			stale = append(stale, pd.MetadataPath())
+		}
+	}
+	if len(stale) == 0 {
+		return CheckResult{Name: name, Status: StatusOK, Message: fmt.Sprintf("%d partial download(s) can be resumed", len(downloads))}
+	}
+	if d.Fix {
+		return d.fixByRemoving(name, "stale resume metadata file(s)", stale)
+	}
+	return CheckResult{
+		Name:       name,
+		Status:     StatusWarning,
+		Message:    fmt.Sprintf("%d resume metadata file(s) refer to missing partial downloads", len(stale)),
+		Suggestion: "Run: fastbrew downloads clear (or fastbrew doctor --fix)",
+		Details:    stale,
+	}
+}
+
+// checkExtractionTempDirs flags directories left in the Cellar by
+// extractions interrupted over an hour ago.
+func (d *Doctor) checkExtractionTempDirs() CheckResult {
+	const name = "Extraction directories"
+	dirs, err := d.client.extractionTempDirs(staleTempDirAge)
+	if err != nil {
+		return CheckResult{Name: name, Status: StatusOK, Message: "Unable to read the Cellar"}
+	}
+	if len(dirs) == 0 {
+		return CheckResult{Name: name, Status: StatusOK, Message: "No interrupted extractions"}
+	}
+	if d.Fix {
+		return d.fixByRemoving(name, "directory(s) of interrupted extractions", dirs)
+	}
+	return CheckResult{
+		Name:       name,
+		Status:     StatusWarning,
+		Message:    fmt.Sprintf("%d interrupted extraction(s) left directories in the Cellar", len(dirs)),
+		Suggestion: "Run: fastbrew cleanup (or fastbrew doctor --fix)",
+		Details:    dirs,
+	}
+}
+
+// checkDataDirectories flags data left in ~/.fastbrew next to the XDG
+// directories, which happens when both held data at migration time.
+func (d *Doctor) checkDataDirectories() CheckResult {
+	leftovers := paths.Leftovers()
+	if len(leftovers) == 0 {
+		return CheckResult{
+			Name:    "Data directories",
 			Status:  StatusOK,
-			Message: "Unable to validate cache files",
+			Message: fmt.Sprintf("Using %s, %s and %s", paths.ConfigDir(), paths.CacheDir(), paths.StateDir()),
+		}
+	}
+
+	details := make([]string, 0, len(leftovers))
+	for _, m := range leftovers {
+		if m.To == "" {
+			details = append(details, m.From)
+		} else {
+			details = append(details, fmt.Sprintf("%s (in use: %s)", m.From, m.To))
 		}
 	}
+	return CheckResult{
+		Name:       "Data directories",
+		Status:     StatusWarning,
+		Message:    fmt.Sprintf("%d item(s) in %s are ignored in favor of the XDG directories", len(leftovers), paths.LegacyDir()),
+		Suggestion: fmt.Sprintf("Merge anything still needed into the location in use, then remove %s", paths.LegacyDir()),
+		Details:    details,
+	}
+}
 
-	var invalid []string
+// checkKegIntegrity verifies installed kegs against their manifests. Kegs
+// without one, from older releases or Homebrew itself, are counted but not
+// flagged.
+func (d *Doctor) checkKegIntegrity() CheckResult {
+	kegs, _ := filepath.Glob(filepath.Join(d.client.Cellar, "*", "*"))
+	var verified, unverifiable int
 	var details []string
-	for _, status := range statuses {
-		if !status.Valid {
-			invalid = append(invalid, filepath.Base(status.Path))
-			if status.Error != nil {
-				details = append(details, fmt.Sprintf("%s: %s", filepath.Base(status.Path), status.Error.Error()))
-			}
+	for _, kegDir := range kegs {
+		if info, err := os.Stat(kegDir); err != nil || !info.IsDir() {
+			continue
+		}
+		name, version := filepath.Base(filepath.Dir(kegDir)), filepath.Base(kegDir)
+		err := d.client.VerifyKeg(name, version)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			unverifiable++
+		case err != nil:
+			details = append(details, fmt.Sprintf("%s %s: %v", name, version, err))
+		default:
+			verified++
 		}
 	}
 
-	if len(invalid) > 0 {
+	if len(details) > 0 {
 		return CheckResult{
-			Name:       "Cache integrity",
+			Name:       "Keg integrity",
 			Status:     StatusError,
-			Message:    fmt.Sprintf("%d cache file(s) corrupted or invalid", len(invalid)),
-			Suggestion: "Run: fastbrew update",
+			Message:    fmt.Sprintf("%d keg(s) differ from their bottles", len(details)),
+			Suggestion: "Run: fastbrew reinstall <package>",
 			Details:    details,
 		}
 	}
 
+	message := fmt.Sprintf("%d keg(s) match their bottles", verified)
+	if unverifiable > 0 {
+		message += fmt.Sprintf(", %d without a manifest", unverifiable)
+	}
+	return CheckResult{
+		Name:    "Keg integrity",
+		Status:  StatusOK,
+		Message: message,
+	}
+}
+
+// checkDanglingOptLinks finds opt/<name> links whose keg was removed.
+func (d *Doctor) checkDanglingOptLinks() CheckResult {
+	const name = "Dangling opt links"
+	var dangling []string
+	entries, _ := os.ReadDir(filepath.Join(d.client.Prefix, "opt"))
+	for _, entry := range entries {
+		link := filepath.Join(d.client.Prefix, "opt", entry.Name())
+		if entry.Type()&os.ModeSymlink == 0 {
+			continue
+		}
+		if _, err := os.Stat(link); err != nil {
+			dangling = append(dangling, link)
+		}
+	}
+	if len(dangling) == 0 {
+		return CheckResult{Name: name, Status: StatusOK, Message: "None found"}
+	}
+	if d.Fix {
+		return d.fixByRemoving(name, "dangling opt link(s)", dangling)
+	}
+	return CheckResult{
+		Name:       name,
+		Status:     StatusWarning,
+		Message:    fmt.Sprintf("%d opt link(s) point to removed kegs", len(dangling)),
+		Suggestion: "Run: fastbrew cleanup (or fastbrew doctor --fix)",
+		Details:    dangling,
+	}
+}
+
+// checkShims verifies every shim's target still exists and that running
+// the shim's name from PATH finds the shim rather than another binary.
+// With Fix, shims whose target is gone are removed.
+func (d *Doctor) checkShims() CheckResult {
+	const name = "Shims"
+	shims, err := d.client.Shims()
+	if err != nil {
+		return CheckResult{Name: name, Status: StatusWarning, Message: fmt.Sprintf("Unable to read %s: %v", d.client.ShimDir(), err)}
+	}
+	if len(shims) == 0 {
+		return CheckResult{Name: name, Status: StatusOK, Message: "No shims"}
+	}
+
+	var broken, brokenPaths, shadowed []string
+	for _, shim := range shims {
+		if shim.Broken() {
+			broken = append(broken, fmt.Sprintf("%s -> %s", shim.Name, shim.Target))
+			brokenPaths = append(brokenPaths, shim.Path)
+			continue
+		}
+		if found, err := exec.LookPath(shim.Name); err != nil || filepath.Clean(found) != shim.Path {
+			if err != nil {
+				found = "nothing"
+			}
+			shadowed = append(shadowed, fmt.Sprintf("%s resolves to %s", shim.Name, found))
+		}
+	}
+
+	if len(broken) > 0 {
+		if d.Fix {
+			return d.fixByRemoving(name, "broken shim(s)", brokenPaths)
+		}
+		return CheckResult{
+			Name:       name,
+			Status:     StatusWarning,
+			Message:    fmt.Sprintf("%d shim(s) point to missing binaries", len(broken)),
+			Suggestion: "Run: fastbrew shims remove <shim> (or fastbrew doctor --fix)",
+			Details:    broken,
+		}
+	}
+	if len(shadowed) > 0 {
+		return CheckResult{
+			Name:       name,
+			Status:     StatusWarning,
+			Message:    fmt.Sprintf("%d shim(s) are not found first in PATH", len(shadowed)),
+			Suggestion: fmt.Sprintf("Put %s at the front of PATH: export PATH=\"%s:$PATH\"", d.client.ShimDir(), d.client.ShimDir()),
+			Details:    shadowed,
+		}
+	}
+	return CheckResult{Name: name, Status: StatusOK, Message: fmt.Sprintf("%d shim(s) resolve", len(shims))}
+}
+
+// checkEmptyCellarDirs finds Cellar/<name> directories without any version
+// directory, left behind by interrupted or partial uninstalls.
+func (d *Doctor) checkEmptyCellarDirs() CheckResult {
+	const name = "Empty Cellar directories"
+	var empty []string
+	for _, pkg := range cellarPackages(d.client.Cellar) {
+		if len(kegVersions(filepath.Join(d.client.Cellar, pkg))) == 0 {
+			empty = append(empty, filepath.Join(d.client.Cellar, pkg))
+		}
+	}
+	if len(empty) == 0 {
+		return CheckResult{Name: name, Status: StatusOK, Message: "None found"}
+	}
+	if d.Fix {
+		return d.fixByRemoving(name, "empty Cellar director(ies)", empty)
+	}
+	return CheckResult{
+		Name:       name,
+		Status:     StatusWarning,
+		Message:    fmt.Sprintf("%d Cellar director(ies) hold no installed version", len(empty)),
+		Suggestion: "Run: fastbrew cleanup (or fastbrew doctor --fix)",
+		Details:    empty,
+	}
+}
+
+// checkUnreferencedKegs finds kegs no opt link points to. Those of a
+// formula whose opt link points to another version are superseded and can
+// be removed; the others belong to formulae that are not linked at all.
+// Pinned formulae are skipped, as cleanup skips them.
+func (d *Doctor) checkUnreferencedKegs() CheckResult {
+	const name = "Unreferenced kegs"
+	pinned, _ := LoadPinned()
+	var superseded, unlinked []string
+	for _, pkg := range cellarPackages(d.client.Cellar) {
+		if pinned[pkg] {
+			continue
+		}
+		linked := d.client.linkedVersion(pkg)
+		live := linked != ""
+		if live {
+			_, err := os.Stat(filepath.Join(d.client.Cellar, pkg, linked))
+			live = err == nil
+		}
+		for _, version := range kegVersions(filepath.Join(d.client.Cellar, pkg)) {
+			switch {
+			case version == linked:
+			case live:
+				superseded = append(superseded, filepath.Join(d.client.Cellar, pkg, version))
+			default:
+				unlinked = append(unlinked, filepath.Join(d.client.Cellar, pkg, version))
+			}
+		}
+	}
+	if len(superseded) == 0 && len(unlinked) == 0 {
+		return CheckResult{Name: name, Status: StatusOK, Message: "Every keg is referenced by its opt link"}
+	}
+
+	if d.Fix && len(superseded) > 0 {
+		result := d.fixByRemoving(name, "superseded keg(s)", superseded)
+		if len(unlinked) > 0 && result.Status == StatusOK {
+			result.Status = StatusWarning
+			result.Message += fmt.Sprintf("; %d keg(s) of unlinked formulae left in place", len(unlinked))
+			result.Suggestion = "Run: fastbrew link <package>, or fastbrew uninstall <package> if it is not needed"
+			result.Details = append(result.Details, unlinked...)
+		}
+		return result
+	}
+
+	var parts []string
+	if len(superseded) > 0 {
+		parts = append(parts, fmt.Sprintf("%d superseded by a linked version", len(superseded)))
+	}
+	if len(unlinked) > 0 {
+		parts = append(parts, fmt.Sprintf("%d of formulae without an opt link", len(unlinked)))
+	}
+	suggestion := "Run: fastbrew cleanup (or fastbrew doctor --fix) to remove superseded versions"
+	if len(unlinked) > 0 {
+		suggestion += "; fastbrew link <package> for formulae without an opt link"
+	}
+	return CheckResult{
+		Name:       name,
+		Status:     StatusWarning,
+		Message:    fmt.Sprintf("%d keg(s) not referenced by an opt link: %s", len(superseded)+len(unlinked), strings.Join(parts, ", ")),
+		Suggestion: suggestion,
+		Details:    append(superseded, unlinked...),
+	}
+}
+
+// fixByRemoving removes paths for a check run with Doctor.Fix. what
+// describes them in the message, e.g. "dangling opt link(s)".
+func (d *Doctor) fixByRemoving(name, what string, paths []string) CheckResult {
+	var failed []string
+	for _, path := range paths {
+		if err := os.RemoveAll(path); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", path, err))
+		}
+	}
+	removed := len(paths) - len(failed)
+	if len(failed) > 0 {
+		return CheckResult{
+			Name:       name,
+			Status:     StatusError,
+			Message:    fmt.Sprintf("Removed %d of %d %s", removed, len(paths), what),
+			Suggestion: "Check permissions on the prefix and run: fastbrew doctor --fix",
+			Details:    failed,
+			Fixed:      removed > 0,
+		}
+	}
+	return CheckResult{
+		Name:    name,
+		Status:  StatusOK,
+		Message: fmt.Sprintf("Removed %d %s", removed, what),
+		Details: paths,
+		Fixed:   true,
+	}
+}
+
+// cellarPackages lists the formula directories in cellar, skipping
+// fastbrew's temporary extraction directories.
+func cellarPackages(cellar string) []string {
+	entries, _ := os.ReadDir(cellar)
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
+	}
+	return names
+}
+
+// kegVersions lists the version directories of a formula's Cellar
+// directory.
+func kegVersions(pkgDir string) []string {
+	entries, _ := os.ReadDir(pkgDir)
+	var versions []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			versions = append(versions, entry.Name())
+		}
+	}
+	return versions
+}
+
+func (d *Doctor) checkBinaryArchitecture() CheckResult {
+	host := currentBinaryHost()
+	mismatches := d.client.findArchMismatches(host)
+	if len(mismatches) > 0 {
+		return CheckResult{
+			Name:       "Binary architecture",
+			Status:     StatusError,
+			Message:    fmt.Sprintf("%d package(s) built for the wrong platform", len(mismatches)),
+			Suggestion: "Run: fastbrew reinstall <package> to fetch the bottle for this machine",
+			Details:    mismatches,
+		}
+	}
+
+	hostDesc := archName(host.goarch)
+	if host.musl {
+		hostDesc += " (musl)"
+	}
 	return CheckResult{
-		Name:    "Cache integrity",
+		Name:    "Binary architecture",
 		Status:  StatusOK,
-		Message: "All cache files valid",
+		Message: fmt.Sprintf("All installed binaries match %s", hostDesc),
 	}
 }
 
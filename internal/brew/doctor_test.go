@@ -0,0 +1,129 @@
+package brew
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newCellarProblems sets up a prefix with one of each problem the Cellar
+// checks look for, next to a healthy keg.
+func newCellarProblems(t *testing.T) *Client {
+	t.Helper()
+	client, _ := newCleanupTestClient(t)
+	optDir := filepath.Join(client.Prefix, "opt")
+	if err := os.MkdirAll(optDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	makeKeg(t, client, "wget", "1.20")
+	makeKeg(t, client, "wget", "1.21")
+	makeKeg(t, client, "jq", "1.7")
+	if err := os.MkdirAll(filepath.Join(client.Cellar, "curl"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, target := range map[string]string{
+		"wget": filepath.Join(client.Cellar, "wget", "1.21"),
+		"git":  filepath.Join(client.Cellar, "git", "2.40"),
+	} {
+		if err := os.Symlink(target, filepath.Join(optDir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return client
+}
+
+func TestDoctorCellarChecksReportProblems(t *testing.T) {
+	client := newCellarProblems(t)
+	d := NewDoctor(client, false)
+
+	tests := []struct {
+		result  CheckResult
+		details []string
+	}{
+		{d.checkDanglingOptLinks(), []string{filepath.Join(client.Prefix, "opt", "git")}},
+		{d.checkEmptyCellarDirs(), []string{filepath.Join(client.Cellar, "curl")}},
+		{d.checkUnreferencedKegs(), []string{
+			filepath.Join(client.Cellar, "wget", "1.20"),
+			filepath.Join(client.Cellar, "jq", "1.7"),
+		}},
+	}
+	for _, tt := range tests {
+		if tt.result.Status != StatusWarning || tt.result.Fixed {
+			t.Errorf("%s: status %s (fixed %v), want an unfixed warning", tt.result.Name, tt.result.Status, tt.result.Fixed)
+		}
+		if len(tt.result.Details) != len(tt.details) {
+			t.Errorf("%s: details %q, want %q", tt.result.Name, tt.result.Details, tt.details)
+			continue
+		}
+		for i, want := range tt.details {
+			if tt.result.Details[i] != want {
+				t.Errorf("%s: details %q, want %q", tt.result.Name, tt.result.Details, tt.details)
+				break
+			}
+		}
+	}
+	if _, err := os.Stat(filepath.Join(client.Cellar, "wget", "1.20")); err != nil {
+		t.Error("checks without Fix must not remove anything")
+	}
+}
+
+func TestDoctorCellarChecksFix(t *testing.T) {
+	client := newCellarProblems(t)
+	d := NewDoctor(client, false)
+	d.Fix = true
+
+	for _, result := range []CheckResult{d.checkDanglingOptLinks(), d.checkEmptyCellarDirs()} {
+		if result.Status != StatusOK || !result.Fixed {
+			t.Errorf("%s: status %s (fixed %v), want fixed", result.Name, result.Status, result.Fixed)
+		}
+	}
+	unreferenced := d.checkUnreferencedKegs()
+	if !unreferenced.Fixed || unreferenced.Status != StatusWarning {
+		t.Errorf("unreferenced kegs: status %s (fixed %v), want a fixed warning for jq", unreferenced.Status, unreferenced.Fixed)
+	}
+
+	for _, gone := range []string{
+		filepath.Join(client.Prefix, "opt", "git"),
+		filepath.Join(client.Cellar, "curl"),
+		filepath.Join(client.Cellar, "wget", "1.20"),
+	} {
+		if _, err := os.Lstat(gone); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed", gone)
+		}
+	}
+	for _, kept := range []string{
+		filepath.Join(client.Prefix, "opt", "wget"),
+		filepath.Join(client.Cellar, "wget", "1.21"),
+		filepath.Join(client.Cellar, "jq", "1.7"),
+	} {
+		if _, err := os.Lstat(kept); err != nil {
+			t.Errorf("%s should be kept: %v", kept, err)
+		}
+	}
+
+	d.Fix = false
+	for _, result := range []CheckResult{d.checkDanglingOptLinks(), d.checkEmptyCellarDirs()} {
+		if result.Status != StatusOK {
+			t.Errorf("%s after fix: %s %s", result.Name, result.Status, result.Message)
+		}
+	}
+}
+
+func TestDoctorUnreferencedKegsSkipsPinned(t *testing.T) {
+	client := newCellarProblems(t)
+	if err := SavePinned(map[string]bool{"wget": true, "jq": true}); err != nil {
+		t.Fatal(err)
+	}
+	if result := NewDoctor(client, false).checkUnreferencedKegs(); result.Status != StatusOK {
+		t.Errorf("pinned formulae should be skipped, got %s: %q", result.Message, result.Details)
+	}
+}
+
+func TestDoctorDiskSpace(t *testing.T) {
+	client := &Client{Prefix: filepath.Join(t.TempDir(), "not", "created")}
+	result := NewDoctor(client, false).checkDiskSpace()
+	if result.Status == StatusError || !strings.HasSuffix(result.Message, " available") {
+		t.Errorf("checkDiskSpace() = %s %q", result.Status, result.Message)
+	}
+}
@@ -0,0 +1,334 @@
+package brew
+
+import (
+	"context"
+	"fastbrew/internal/progress"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Downloaded bottles are stored once per content hash under
+// <cache>/blobs/<sha256>. The familiar <name>-<version>.bottle names in the
+// cache directory are symlinks into the blob store, so the same bottle
+// fetched under another name (a different tap, a reinstall after a rename)
+// resolves to the existing blob without a download.
+const blobDirName = "blobs"
+
+// CacheBlob is one content-addressed download in the cache.
+type CacheBlob struct {
+	SHA256 string
+	Path   string
+	Size   int64
+	// LastUsed is updated whenever the blob is served from the cache.
+	LastUsed time.Time
+	// Names are the friendly cache entries that link to the blob.
+	Names []string
+}
+
+// CachePruneOptions controls PruneDownloadCache. Dangling names and blobs
+// without any name are always removed.
+type CachePruneOptions struct {
+	// MaxAge also removes blobs not used for this long, with their names.
+	MaxAge time.Duration
+	// MaxSize then removes the least recently used blobs until the rest
+	// fit in this many bytes.
+	MaxSize int64
+	// All removes every blob.
+	All    bool
+	DryRun bool
+}
+
+// fetchCached downloads url into the content-addressed cache and returns the
+// path of its friendly name. When expectedSHA is already in the blob store
+// the name is linked to it and nothing is downloaded.
+func (c *Client) fetchCached(ctx context.Context, url, filename, expectedSHA string, tracker progress.ProgressTracker) (string, error) {
+	cacheDir, err := c.GetCacheDir()
+	if err != nil {
+		return "", err
+	}
+	linkPath := filepath.Join(cacheDir, filename)
+
+	if expectedSHA != "" {
+		if blob, ok := c.cachedBlob(cacheDir, expectedSHA); ok {
+			if err := linkBlob(linkPath, blob); err == nil {
+				c.logger().Debug("download cache hit", "name", filename, "sha256", expectedSHA)
+				return linkPath, nil
+			}
+		}
+	}
+
+	// Never download through an existing link: it would write into a blob
+	// that other names share.
+	if info, err := os.Lstat(linkPath); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		os.Remove(linkPath)
+	}
+	if err := c.DownloadWithProgress(ctx, url, linkPath, expectedSHA, tracker); err != nil {
+		return "", err
+	}
+
+	if err := c.adoptBlob(cacheDir, linkPath, expectedSHA); err != nil {
+		// The plain file is still a valid download; it just is not shared.
+		c.logger().Warn("failed to move download into blob store", "path", linkPath, "error", err)
+	}
+	return linkPath, nil
+}
+
+// cachedBlob returns the blob for sha when present and intact, marking it
+// as used. A corrupt blob is removed.
+func (c *Client) cachedBlob(cacheDir, sha string) (string, bool) {
+	blob := filepath.Join(cacheDir, blobDirName, sha)
+	if _, err := os.Stat(blob); err != nil {
+		return "", false
+	}
+	if err := verifyChecksum(blob, sha); err != nil {
+		c.logger().Warn("removing corrupt cache blob", "path", blob, "error", err)
+		os.Remove(blob)
+		return "", false
+	}
+	now := time.Now()
+	os.Chtimes(blob, now, now)
+	return blob, true
+}
+
+// hasCachedBlob reports whether the blob for sha is in the cache.
+func (c *Client) hasCachedBlob(sha string) bool {
+	cacheDir, err := c.GetCacheDir()
+	if err != nil || sha == "" {
+		return false
+	}
+	_, err = os.Stat(filepath.Join(cacheDir, blobDirName, sha))
+	return err == nil
+}
+
+// evictCached removes filename, the blob for sha and the bottle unpacked
+// from it from the cache so the next fetch downloads them again. Other
+// names sharing the blob dangle until they are fetched or pruned.
+func (c *Client) evictCached(filename, sha string) error {
+	cacheDir, err := c.GetCacheDir()
+	if err != nil {
+		return err
+	}
+	paths := []string{filepath.Join(cacheDir, filename)}
+	if sha != "" {
+		paths = append(paths, filepath.Join(cacheDir, blobDirName, sha))
+	}
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if sha != "" {
+		return os.RemoveAll(filepath.Join(cacheDir, extractCacheDirName, sha))
+	}
+	return nil
+}
+
+// adoptBlob moves a completed download into the blob store and replaces it
+// with a link. When sha is empty the file is hashed first.
+func (c *Client) adoptBlob(cacheDir, path, sha string) error {
+	if sha == "" {
+		var err error
+		if sha, err = fileSHA256(path); err != nil {
+			return err
+		}
+	}
+
+	blobDir := filepath.Join(cacheDir, blobDirName)
+	if err := os.MkdirAll(blobDir, 0755); err != nil {
+		return err
+	}
+	blob := filepath.Join(blobDir, sha)
+	if _, err := os.Stat(blob); err == nil {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	} else if err := os.Rename(path, blob); err != nil {
+		return err
+	}
+	return linkBlob(path, blob)
+}
+
+// linkBlob points linkPath at blob, replacing whatever was there. Links are
+// relative so the cache directory can be moved. Where symlinks are not
+// available a hard link is used instead.
+func linkBlob(linkPath, blob string) error {
+	target, err := filepath.Rel(filepath.Dir(linkPath), blob)
+	if err != nil {
+		return err
+	}
+	if current, err := os.Readlink(linkPath); err == nil && current == target {
+		return nil
+	}
+
+	tmp := fmt.Sprintf("%s.link-%d", linkPath, os.Getpid())
+	os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		if linkErr := os.Link(blob, tmp); linkErr != nil {
+			return err
+		}
+	}
+	if err := os.Rename(tmp, linkPath); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// ListDownloadCache returns every blob in the download cache, most recently
+// used first.
+func (c *Client) ListDownloadCache() ([]CacheBlob, error) {
+	cacheDir, err := c.GetCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	blobs, _, err := scanDownloadCache(cacheDir)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]CacheBlob, 0, len(blobs))
+	for _, blob := range blobs {
+		sort.Strings(blob.Names)
+		out = append(out, *blob)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].LastUsed.Equal(out[j].LastUsed) {
+			return out[i].SHA256 < out[j].SHA256
+		}
+		return out[i].LastUsed.After(out[j].LastUsed)
+	})
+	return out, nil
+}
+
+// PruneDownloadCache removes dangling cache names, blobs no name refers to,
+// and, per opts, blobs past their maximum age or beyond the size limit.
+func (c *Client) PruneDownloadCache(opts CachePruneOptions) (*CleanupReport, error) {
+	cacheDir, err := c.GetCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	report := &CleanupReport{}
+	if err := c.pruneBlobs(cacheDir, opts, time.Now(), report); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+func (c *Client) pruneBlobs(cacheDir string, opts CachePruneOptions, now time.Time, report *CleanupReport) error {
+	blobs, dangling, err := scanDownloadCache(cacheDir)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range dangling {
+		c.cleanupRemove(report, opts.DryRun, CleanupItem{Kind: CleanupKindSymlink, Path: path})
+	}
+
+	shas := make([]string, 0, len(blobs))
+	for sha := range blobs {
+		shas = append(shas, sha)
+	}
+	sort.Strings(shas)
+
+	var kept []*CacheBlob
+	for _, sha := range shas {
+		blob := blobs[sha]
+		expired := opts.MaxAge > 0 && now.Sub(blob.LastUsed) >= opts.MaxAge
+		if !opts.All && !expired && len(blob.Names) > 0 {
+			kept = append(kept, blob)
+			continue
+		}
+		c.removeBlob(cacheDir, blob, opts.DryRun, report)
+	}
+
+	// Unpacked bottles go with their blob.
+	extracted, _ := os.ReadDir(filepath.Join(cacheDir, extractCacheDirName))
+	for _, entry := range extracted {
+		if _, ok := blobs[entry.Name()]; !ok {
+			path := filepath.Join(cacheDir, extractCacheDirName, entry.Name())
+			c.cleanupRemove(report, opts.DryRun, CleanupItem{Kind: CleanupKindCache, Path: path, Bytes: dirSize(path)})
+		}
+	}
+
+	if opts.MaxSize > 0 {
+		var total int64
+		for _, blob := range kept {
+			total += blob.Size
+		}
+		sort.SliceStable(kept, func(i, j int) bool { return kept[i].LastUsed.Before(kept[j].LastUsed) })
+		for _, blob := range kept {
+			if total <= opts.MaxSize {
+				break
+			}
+			c.removeBlob(cacheDir, blob, opts.DryRun, report)
+			total -= blob.Size
+		}
+	}
+	return nil
+}
+
+// removeBlob removes blob, the names linking to it and the bottle
+// unpacked from it.
+func (c *Client) removeBlob(cacheDir string, blob *CacheBlob, dryRun bool, report *CleanupReport) {
+	for _, name := range blob.Names {
+		c.cleanupRemove(report, dryRun, CleanupItem{Kind: CleanupKindSymlink, Path: filepath.Join(cacheDir, name)})
+	}
+	c.cleanupRemove(report, dryRun, CleanupItem{Kind: CleanupKindCache, Path: blob.Path, Bytes: blob.Size})
+	extracted := filepath.Join(cacheDir, extractCacheDirName, blob.SHA256)
+	if _, err := os.Stat(extracted); err == nil {
+		c.cleanupRemove(report, dryRun, CleanupItem{Kind: CleanupKindCache, Path: extracted, Bytes: dirSize(extracted)})
+	}
+}
+
+// scanDownloadCache indexes the blob store and the names linking into it.
+// Names whose blob is missing are returned as dangling.
+func scanDownloadCache(cacheDir string) (map[string]*CacheBlob, []string, error) {
+	blobs := make(map[string]*CacheBlob)
+	blobDir := filepath.Join(cacheDir, blobDirName)
+
+	entries, err := os.ReadDir(blobDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		blobs[entry.Name()] = &CacheBlob{
+			SHA256:   entry.Name(),
+			Path:     filepath.Join(blobDir, entry.Name()),
+			Size:     info.Size(),
+			LastUsed: info.ModTime(),
+		}
+	}
+
+	names, err := os.ReadDir(cacheDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	var dangling []string
+	for _, entry := range names {
+		if entry.Type()&os.ModeSymlink == 0 {
+			continue
+		}
+		path := filepath.Join(cacheDir, entry.Name())
+		target, err := os.Readlink(path)
+		if err != nil || !strings.HasPrefix(filepath.ToSlash(target), blobDirName+"/") {
+			continue
+		}
+		if blob, ok := blobs[filepath.Base(target)]; ok {
+			blob.Names = append(blob.Names, entry.Name())
+		} else {
+			dangling = append(dangling, path)
+		}
+	}
+	return blobs, dangling, nil
+}
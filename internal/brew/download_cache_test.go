@@ -0,0 +1,176 @@
+package brew
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newBottleServer(t *testing.T, body []byte) (*httptest.Server, *int32) {
+	t.Helper()
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestFetchCachedDeduplicatesByContent(t *testing.T) {
+	client, cacheDir := newCleanupTestClient(t)
+	body := []byte("bottle contents")
+	sha := sha256Hex(body)
+	srv, hits := newBottleServer(t, body)
+
+	first, err := client.fetchCached(context.Background(), srv.URL+"/a", "wget-1.21.bottle", sha, nil)
+	if err != nil {
+		t.Fatalf("first fetch failed: %v", err)
+	}
+	second, err := client.fetchCached(context.Background(), srv.URL+"/b", "wget-tap.bottle", sha, nil)
+	if err != nil {
+		t.Fatalf("second fetch failed: %v", err)
+	}
+
+	if got := atomic.LoadInt32(hits); got != 1 {
+		t.Fatalf("expected a single download, got %d", got)
+	}
+	blob := filepath.Join(cacheDir, blobDirName, sha)
+	for _, path := range []string{first, second} {
+		target, err := os.Readlink(path)
+		if err != nil {
+			t.Fatalf("%s should be a symlink: %v", path, err)
+		}
+		if filepath.Join(cacheDir, target) != blob {
+			t.Errorf("%s points at %s, want %s", path, target, blob)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || string(data) != string(body) {
+			t.Errorf("reading %s through link: %q, %v", path, data, err)
+		}
+	}
+}
+
+func TestFetchCachedWithoutChecksumAdoptsBlob(t *testing.T) {
+	client, cacheDir := newCleanupTestClient(t)
+	body := []byte("tap bottle")
+	srv, _ := newBottleServer(t, body)
+
+	path, err := client.fetchCached(context.Background(), srv.URL, "foo-tap.bottle", "", nil)
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if _, err := os.Readlink(path); err != nil {
+		t.Fatalf("expected a link into the blob store: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, blobDirName, sha256Hex(body))); err != nil {
+		t.Fatalf("expected blob named by content hash: %v", err)
+	}
+}
+
+func TestFetchCachedReplacesCorruptBlob(t *testing.T) {
+	client, cacheDir := newCleanupTestClient(t)
+	body := []byte("good bottle")
+	sha := sha256Hex(body)
+	srv, hits := newBottleServer(t, body)
+
+	writeAged(t, filepath.Join(cacheDir, blobDirName, sha), 0)
+	if _, err := client.fetchCached(context.Background(), srv.URL, "jq-1.7.bottle", sha, nil); err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if got := atomic.LoadInt32(hits); got != 1 {
+		t.Fatalf("expected corrupt blob to be re-downloaded, got %d requests", got)
+	}
+	data, _ := os.ReadFile(filepath.Join(cacheDir, blobDirName, sha))
+	if string(data) != string(body) {
+		t.Fatalf("blob not replaced, got %q", data)
+	}
+}
+
+func TestPruneDownloadCache(t *testing.T) {
+	client, cacheDir := newCleanupTestClient(t)
+	day := 24 * time.Hour
+	blobDir := filepath.Join(cacheDir, blobDirName)
+
+	writeAged(t, filepath.Join(blobDir, "fresh"), day)
+	writeAged(t, filepath.Join(blobDir, "stale"), 30*day)
+	writeAged(t, filepath.Join(blobDir, "orphan"), day)
+	for name, blob := range map[string]string{
+		"jq-1.7.bottle":    "fresh",
+		"wget-1.21.bottle": "stale",
+		"curl-8.0.bottle":  "missing",
+	} {
+		if err := os.Symlink(filepath.Join(blobDirName, blob), filepath.Join(cacheDir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	blobs, err := client.ListDownloadCache()
+	if err != nil {
+		t.Fatalf("ListDownloadCache failed: %v", err)
+	}
+	if len(blobs) != 3 || blobs[len(blobs)-1].SHA256 != "stale" || len(blobs[len(blobs)-1].Names) != 1 {
+		t.Fatalf("unexpected listing: %+v", blobs)
+	}
+
+	report, err := client.PruneDownloadCache(CachePruneOptions{MaxAge: 7 * day})
+	if err != nil {
+		t.Fatalf("PruneDownloadCache failed: %v", err)
+	}
+	if got := report.BytesReclaimed(); got != 8 {
+		t.Errorf("expected 8 bytes reclaimed, got %d (%+v)", got, report.Items)
+	}
+	for _, gone := range []string{"curl-8.0.bottle", "wget-1.21.bottle", "blobs/stale", "blobs/orphan"} {
+		if _, err := os.Lstat(filepath.Join(cacheDir, gone)); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed", gone)
+		}
+	}
+	for _, kept := range []string{"jq-1.7.bottle", "blobs/fresh"} {
+		if _, err := os.Stat(filepath.Join(cacheDir, kept)); err != nil {
+			t.Errorf("%s should be kept: %v", kept, err)
+		}
+	}
+}
+
+func TestPruneDownloadCacheMaxSize(t *testing.T) {
+	client, _ := newCleanupTestClient(t)
+	client.CacheDir = t.TempDir()
+	cacheDir := client.CacheDir
+	day := 24 * time.Hour
+
+	for i, blob := range []string{"newest", "older", "oldest"} {
+		writeAged(t, filepath.Join(cacheDir, blobDirName, blob), time.Duration(i+1)*day)
+		if err := os.Symlink(filepath.Join(blobDirName, blob), filepath.Join(cacheDir, blob+".bottle")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Each blob is 4 bytes; a 9-byte limit keeps the two most recently used.
+	report, err := client.PruneDownloadCache(CachePruneOptions{MaxSize: 9})
+	if err != nil {
+		t.Fatalf("PruneDownloadCache failed: %v", err)
+	}
+	if got := report.BytesReclaimed(); got != 4 {
+		t.Errorf("expected 4 bytes reclaimed, got %d (%+v)", got, report.Items)
+	}
+	if _, err := os.Lstat(filepath.Join(cacheDir, "oldest.bottle")); !os.IsNotExist(err) {
+		t.Error("least recently used download should be removed")
+	}
+	for _, kept := range []string{"newest.bottle", "older.bottle"} {
+		if _, err := os.Stat(filepath.Join(cacheDir, kept)); err != nil {
+			t.Errorf("%s should be kept: %v", kept, err)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package brew
+
+import (
+	"context"
+	"errors"
+	"fastbrew/internal/progress"
+	"fastbrew/internal/resume"
+	"fastbrew/internal/retry"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func fastDownloadRetries(t *testing.T) {
+	t.Helper()
+	saved := downloadRetryConfig
+	downloadRetryConfig.InitialDelay = time.Millisecond
+	t.Cleanup(func() { downloadRetryConfig = saved })
+}
+
+func TestDownloadWithProgressRetriesAfterServiceUnavailable(t *testing.T) {
+	client, _ := newCleanupTestClient(t)
+	fastDownloadRetries(t)
+
+	body := []byte("bottle contents")
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	dest := filepath.Join(t.TempDir(), "bottle")
+	if err := client.DownloadWithProgress(context.Background(), server.URL, dest, sha256Hex(body), nil); err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	if got := atomic.LoadInt32(&hits); got != 2 {
+		t.Fatalf("expected 2 requests, got %d", got)
+	}
+}
+
+func TestDownloadWithProgressResumesInterruptedDownload(t *testing.T) {
+	client, _ := newCleanupTestClient(t)
+	fastDownloadRetries(t)
+
+	body := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	half := len(body) / 2
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			// The partial is checked against the remote before resuming.
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			return
+		}
+		ranges = append(ranges, r.Header.Get("Range"))
+		if len(ranges) == 1 {
+			// Promise the whole body, send half, then drop the connection.
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.Write(body[:half])
+			w.(http.Flusher).Flush()
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", half, len(body)-1, len(body)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(body[half:])
+	}))
+	defer server.Close()
+
+	dest := filepath.Join(t.TempDir(), "bottle")
+	if err := client.DownloadWithProgress(context.Background(), server.URL, dest, sha256Hex(body), nil); err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	if len(ranges) != 2 || ranges[1] != fmt.Sprintf("bytes=%d-", half) {
+		t.Fatalf("expected the retry to resume at byte %d, got ranges %q", half, ranges)
+	}
+	if data, _ := os.ReadFile(dest); string(data) != string(body) {
+		t.Fatalf("unexpected contents %q", data)
+	}
+}
+
+func TestDownloadWithProgressKeepsPartialWhenCancelled(t *testing.T) {
+	client, _ := newCleanupTestClient(t)
+	fastDownloadRetries(t)
+
+	body := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	half := len(body) / 2
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			// The partial is checked against the remote before resuming.
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			return
+		}
+		ranges = append(ranges, r.Header.Get("Range"))
+		if len(ranges) == 1 {
+			// Send half, then stall until the client gives up.
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.Write(body[:half])
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", half, len(body)-1, len(body)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(body[half:])
+	}))
+	defer server.Close()
+
+	dest := filepath.Join(t.TempDir(), "bottle")
+	ctx, cancel := context.WithCancel(context.Background())
+	tracker := cancelOnUpdate{progress.NewProgressTracker("bottle", server.URL, nil), cancel}
+	err := client.DownloadWithProgress(ctx, server.URL, dest, sha256Hex(body), tracker)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	cacheDir, _ := client.GetCacheDir()
+	if !resume.NewResumeManager(cacheDir).Exists(dest) {
+		t.Fatal("resume metadata was not kept for the partial download")
+	}
+
+	if err := client.DownloadWithProgress(context.Background(), server.URL, dest, sha256Hex(body), nil); err != nil {
+		t.Fatalf("resumed download failed: %v", err)
+	}
+	if len(ranges) != 2 || ranges[1] != fmt.Sprintf("bytes=%d-", half) {
+		t.Fatalf("expected the next download to resume at byte %d, got ranges %q", half, ranges)
+	}
+	if data, _ := os.ReadFile(dest); string(data) != string(body) {
+		t.Fatalf("unexpected contents %q", data)
+	}
+}
+
+// cancelOnUpdate cancels a download once its first bytes arrive.
+type cancelOnUpdate struct {
+	progress.ProgressTracker
+	cancel context.CancelFunc
+}
+
+func (t cancelOnUpdate) Update(int64) { t.cancel() }
+
+func TestDownloadWithProgressDoesNotRetryNotFound(t *testing.T) {
+	client, _ := newCleanupTestClient(t)
+	fastDownloadRetries(t)
+
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Retry-After", "120")
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	err := client.DownloadWithProgress(context.Background(), server.URL, filepath.Join(t.TempDir(), "bottle"), "", nil)
+	if err == nil || retry.IsRetryable(err) {
+		t.Fatalf("expected a non-retryable error, got %v", err)
+	}
+	if got := atomic.LoadInt32(&hits); got != 1 {
+		t.Fatalf("expected a single request, got %d", got)
+	}
+}
+
+func TestDownloadWithProgressStopsAtOpenCircuit(t *testing.T) {
+	client, _ := newCleanupTestClient(t)
+	fastDownloadRetries(t)
+	client.Breaker = retry.NewBreaker(2, time.Minute)
+
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	for _, name := range []string{"wget", "jq"} {
+		err := client.DownloadWithProgress(context.Background(), server.URL+"/"+name, filepath.Join(dir, name), "", nil)
+		if !errors.Is(err, retry.ErrCircuitOpen) {
+			t.Fatalf("%s: expected an open circuit, got %v", name, err)
+		}
+	}
+	if got := atomic.LoadInt32(&hits); got != 2 {
+		t.Fatalf("expected the host to be given up on after 2 failures, got %d requests", got)
+	}
+}
+
+func TestDownloadWithProgressDiscardsPartialWhenRemoteChanged(t *testing.T) {
+	client, _ := newCleanupTestClient(t)
+	fastDownloadRetries(t)
+
+	oldBody := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	newBody := []byte("ZYXWVUTSRQPONMLKJIHGFEDCBA9876543210")
+	half := len(oldBody) / 2
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(ranges) == 0 {
+			// Send half of the old file, then drop the connection.
+			ranges = append(ranges, r.Header.Get("Range"))
+			w.Header().Set("ETag", `"old"`)
+			w.Header().Set("Content-Length", strconv.Itoa(len(oldBody)))
+			w.Write(oldBody[:half])
+			w.(http.Flusher).Flush()
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		// The file has been replaced since.
+		w.Header().Set("ETag", `"new"`)
+		w.Header().Set("Content-Length", strconv.Itoa(len(newBody)))
+		if r.Method == http.MethodHead {
+			return
+		}
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Write(newBody)
+	}))
+	defer server.Close()
+
+	dest := filepath.Join(t.TempDir(), "bottle")
+	if err := client.DownloadWithProgress(context.Background(), server.URL, dest, sha256Hex(newBody), nil); err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	if len(ranges) != 2 || ranges[1] != "" {
+		t.Fatalf("expected the stale partial to be discarded and the file fetched whole, got ranges %q", ranges)
+	}
+	if data, _ := os.ReadFile(dest); string(data) != string(newBody) {
+		t.Fatalf("unexpected contents %q", data)
+	}
+}
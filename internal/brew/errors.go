@@ -0,0 +1,142 @@
+package brew
+
+import (
+	"errors"
+	"fastbrew/internal/lockfile"
+	"fastbrew/internal/oci"
+	"fastbrew/internal/retry"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// ErrorKind is the category of a failure, for callers (and scripts, through
+// the exit status) that handle some failures differently from others.
+type ErrorKind string
+
+const (
+	KindOther      ErrorKind = "other"
+	KindNetwork    ErrorKind = "network"
+	KindChecksum   ErrorKind = "checksum"
+	KindNotFound   ErrorKind = "not-found"
+	KindConflict   ErrorKind = "conflict"
+	KindPermission ErrorKind = "permission"
+	// KindPartialFailure is an operation on several packages where some
+	// succeeded and the rest failed.
+	KindPartialFailure ErrorKind = "partial-failure"
+)
+
+// Exit statuses of the fastbrew command per kind of failure. 2 is kept for
+// usage errors and 130 for interrupted commands.
+const (
+	ExitOther          = 1
+	ExitUsage          = 2
+	ExitNotFound       = 3
+	ExitNetwork        = 4
+	ExitChecksum       = 5
+	ExitConflict       = 6
+	ExitPermission     = 7
+	ExitPartialFailure = 8
+)
+
+// ExitCode returns the exit status the fastbrew command ends with for a
+// failure of kind k.
+func (k ErrorKind) ExitCode() int {
+	switch k {
+	case KindNotFound:
+		return ExitNotFound
+	case KindNetwork:
+		return ExitNetwork
+	case KindChecksum:
+		return ExitChecksum
+	case KindConflict:
+		return ExitConflict
+	case KindPermission:
+		return ExitPermission
+	case KindPartialFailure:
+		return ExitPartialFailure
+	}
+	return ExitOther
+}
+
+// Error is an error tagged with its kind. Wrapping it with %w keeps the
+// kind; KindOf finds it.
+type Error struct {
+	Kind ErrorKind
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// kindErrorf formats an error like fmt.Errorf and tags it with kind.
+func kindErrorf(kind ErrorKind, format string, args ...interface{}) error {
+	return &Error{Kind: kind, Err: fmt.Errorf(format, args...)}
+}
+
+// KindOf returns the kind of err: that of the outermost Error in its chain,
+// or else one inferred from well-known errors (permission denied, network
+// failures, held locks, missing formulae). Errors of no known kind are
+// KindOther.
+func KindOf(err error) ErrorKind {
+	if err == nil {
+		return ""
+	}
+	var kindErr *Error
+	if errors.As(err, &kindErr) && kindErr.Kind != "" {
+		return kindErr.Kind
+	}
+
+	var (
+		resolveErr  *ResolveError
+		notFoundErr *FormulaNotFoundError
+		statusErr   *oci.StatusError
+		netErr      net.Error
+		urlErr      *url.Error
+	)
+	switch {
+	case errors.Is(err, os.ErrPermission):
+		return KindPermission
+	case errors.Is(err, lockfile.ErrLocked):
+		return KindConflict
+	case errors.Is(err, ErrAttestationVerification):
+		return KindChecksum
+	case errors.Is(err, ErrIndexDBKeyNotFound), errors.As(err, &notFoundErr):
+		return KindNotFound
+	case errors.As(err, &resolveErr):
+		if len(resolveErr.Candidates) > 0 {
+			return KindOther
+		}
+		return KindNotFound
+	case errors.As(err, &statusErr):
+		if statusErr.StatusCode == http.StatusNotFound {
+			return KindNotFound
+		}
+		return KindNetwork
+	case errors.As(err, &netErr), errors.As(err, &urlErr), errors.Is(err, retry.ErrCircuitOpen):
+		return KindNetwork
+	}
+	return KindOther
+}
+
+// SharedKind returns the kind all of errs have in common, or KindOther when
+// they differ. An operation whose every package failed the same way (e.g.
+// offline) fails with that kind.
+func SharedKind(errs []error) ErrorKind {
+	kind := KindOther
+	for i, err := range errs {
+		k := KindOf(err)
+		if i > 0 && k != kind {
+			return KindOther
+		}
+		kind = k
+	}
+	return kind
+}
@@ -0,0 +1,72 @@
+package brew
+
+import (
+	"errors"
+	"fastbrew/internal/lockfile"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"testing"
+)
+
+func TestKindOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorKind
+	}{
+		{"nil", nil, ""},
+		{"plain", errors.New("boom"), KindOther},
+		{"tagged", kindErrorf(KindChecksum, "checksum mismatch"), KindChecksum},
+		{"wrapped tag", fmt.Errorf("failed to download wget: %w", kindErrorf(KindNetwork, "download failed: 503")), KindNetwork},
+		{"outermost tag wins", kindErrorf(KindPartialFailure, "1 failed: %w", kindErrorf(KindNetwork, "offline")), KindPartialFailure},
+		{"permission", fmt.Errorf("link: %w", &fs.PathError{Op: "open", Path: "/x", Err: fs.ErrPermission}), KindPermission},
+		{"held lock", &lockfile.HeldError{PID: 42}, KindConflict},
+		{"url error", &url.Error{Op: "Get", URL: "https://example.com", Err: errors.New("connection refused")}, KindNetwork},
+		{"missing formula", &FormulaNotFoundError{Ref: "user/tap/nope"}, KindNotFound},
+		{"unresolved ref", &ResolveError{Ref: "nope"}, KindNotFound},
+		{"ambiguous ref", &ResolveError{Ref: "foo", Candidates: []string{"a/b/foo", "c/d/foo"}}, KindOther},
+		{"index miss", ErrIndexDBKeyNotFound, KindNotFound},
+		{"attestation", AttestationError{Name: "wget", Reason: "no attestation"}, KindChecksum},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := KindOf(tt.err); got != tt.want {
+				t.Errorf("KindOf() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorKindExitCodesAreDistinct(t *testing.T) {
+	kinds := []ErrorKind{KindOther, KindNetwork, KindChecksum, KindNotFound, KindConflict, KindPermission, KindPartialFailure}
+	seen := map[int]ErrorKind{ExitUsage: "usage"}
+	for _, kind := range kinds {
+		code := kind.ExitCode()
+		if other, dup := seen[code]; dup {
+			t.Errorf("%s and %s share exit code %d", kind, other, code)
+		}
+		seen[code] = kind
+	}
+	if KindOther.ExitCode() != 1 {
+		t.Errorf("KindOther exits %d, want 1", KindOther.ExitCode())
+	}
+}
+
+func TestSharedKind(t *testing.T) {
+	network := kindErrorf(KindNetwork, "offline")
+	if got := SharedKind([]error{network, fmt.Errorf("wrapped: %w", network)}); got != KindNetwork {
+		t.Errorf("SharedKind(same) = %q, want network", got)
+	}
+	if got := SharedKind([]error{network, kindErrorf(KindChecksum, "bad")}); got != KindOther {
+		t.Errorf("SharedKind(mixed) = %q, want other", got)
+	}
+}
+
+func TestNotInstalledErrorsAreNotFound(t *testing.T) {
+	c := newTransactionTestClient(t)
+	_, err := c.UninstallFormula("missing", UninstallOptions{})
+	if KindOf(err) != KindNotFound {
+		t.Errorf("UninstallFormula(missing) kind = %q, want not-found (%v)", KindOf(err), err)
+	}
+}
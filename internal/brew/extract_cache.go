@@ -0,0 +1,193 @@
+package brew
+
+import (
+	"encoding/json"
+	"errors"
+	"fastbrew/internal/atomicfile"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Bottles are unpacked once into <cache>/extracted/<sha256>, named after
+// the blob they came from. Installing the same bottle again (a reinstall,
+// another prefix sharing the cache, an upgrade back to a cached version)
+// clones the unpacked tree into the Cellar instead of decompressing it:
+// with reflinks where the filesystem supports them (APFS, btrfs, XFS),
+// otherwise with hard links. Receipts and manifests written into a keg
+// afterwards replace files rather than editing them, so the cached copy is
+// not changed through a hard link. When the cache and the Cellar are on
+// different filesystems neither works, and bottles are extracted as usual.
+const extractCacheDirName = "extracted"
+
+// extractCacheTree and extractCacheIndex are the unpacked archive and the
+// list of what it holds inside an extraction cache entry.
+const (
+	extractCacheTree  = "tree"
+	extractCacheIndex = "written.json"
+)
+
+// extractCacheEntry returns the extraction cache entry for the bottle at
+// tarPath. Only bottles in the download cache's blob store have one, as
+// their name is the hash of their contents.
+func (c *Client) extractCacheEntry(tarPath string) (string, bool) {
+	if c.DisableExtractCache {
+		return "", false
+	}
+	cacheDir, err := c.GetCacheDir()
+	if err != nil {
+		return "", false
+	}
+	blob, err := filepath.EvalSymlinks(tarPath)
+	if err != nil {
+		return "", false
+	}
+	blobDir, err := filepath.EvalSymlinks(filepath.Join(cacheDir, blobDirName))
+	if err != nil || filepath.Dir(blob) != blobDir {
+		return "", false
+	}
+	return filepath.Join(cacheDir, extractCacheDirName, filepath.Base(blob)), true
+}
+
+// extractBottleCached is extractBottle into dir, cloning the bottle from
+// the extraction cache when it was unpacked before and adding it to the
+// cache when not. A cache entry that no longer matches its index is
+// dropped and the bottle extracted again.
+func (c *Client) extractBottleCached(tarPath, dir string) ([]KegFile, error) {
+	entry, cacheable := c.extractCacheEntry(tarPath)
+	if cacheable {
+		written, err := loadExtracted(entry, dir)
+		if err == nil {
+			c.logger().Debug("extraction cache hit", "bottle", tarPath, "entry", entry)
+			return written, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			c.logger().Warn("discarding extraction cache entry", "entry", entry, "error", err)
+			os.RemoveAll(entry)
+		}
+		if err := resetDir(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	written, err := extractBottle(tarPath, dir, c.Prefix, c.getExtractWorkers())
+	if err != nil {
+		return nil, err
+	}
+	if cacheable {
+		if err := storeExtracted(entry, dir, written); err != nil {
+			c.logger().Debug("not caching extracted bottle", "entry", entry, "error", err)
+		}
+	}
+	return written, nil
+}
+
+// loadExtracted clones the cache entry's tree into dir and returns what
+// extractBottle wrote when the entry was made. It returns fs.ErrNotExist
+// when there is no entry, and an error when the entry cannot be cloned
+// into dir or its files changed size since.
+func loadExtracted(entry, dir string) ([]KegFile, error) {
+	data, err := os.ReadFile(filepath.Join(entry, extractCacheIndex))
+	if err != nil {
+		return nil, err
+	}
+	var written []KegFile
+	if err := json.Unmarshal(data, &written); err != nil {
+		return nil, fmt.Errorf("invalid index: %w", err)
+	}
+	tree := filepath.Join(entry, extractCacheTree)
+	for _, file := range written {
+		if file.Link != "" {
+			continue
+		}
+		info, err := os.Lstat(filepath.Join(tree, file.Path))
+		if err != nil {
+			return nil, err
+		}
+		if info.Size() != file.Size {
+			return nil, fmt.Errorf("%s: size %d, expected %d", file.Path, info.Size(), file.Size)
+		}
+	}
+	if err := cloneTree(tree, dir); err != nil {
+		return nil, err
+	}
+	return written, nil
+}
+
+// storeExtracted adds the bottle extracted into dir to the cache as entry.
+func storeExtracted(entry, dir string, written []KegFile) error {
+	if err := os.MkdirAll(filepath.Dir(entry), 0755); err != nil {
+		return err
+	}
+	staging, err := os.MkdirTemp(filepath.Dir(entry), filepath.Base(entry)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	if err := cloneTree(dir, filepath.Join(staging, extractCacheTree)); err != nil {
+		return err
+	}
+	data, err := json.Marshal(written)
+	if err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(filepath.Join(staging, extractCacheIndex), data, 0644); err != nil {
+		return err
+	}
+	// Another install of the same bottle may have got there first.
+	if err := os.Rename(staging, entry); err != nil {
+		if _, statErr := os.Stat(entry); statErr == nil {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// cloneTree recreates the directories and symlinks under src in dst and
+// clones every regular file (see cloneFile).
+func cloneTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, 0755)
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			return cloneFile(path, target)
+		default:
+			return nil
+		}
+	})
+}
+
+// cloneFile makes dst a copy-on-write clone of src, or a hard link to it
+// where the filesystem cannot clone.
+func cloneFile(src, dst string) error {
+	if err := reflinkFile(src, dst); err == nil {
+		return nil
+	}
+	return os.Link(src, dst)
+}
+
+// resetDir empties dir, keeping the directory itself.
+func resetDir(dir string) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	return os.MkdirAll(dir, 0755)
+}
@@ -0,0 +1,132 @@
+package brew
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// cacheTestBottle stores a test bottle in the blob store and returns the
+// name linking to it and its extraction cache entry.
+func cacheTestBottle(t *testing.T, cacheDir string) (string, string) {
+	t.Helper()
+	blobDir := filepath.Join(cacheDir, blobDirName)
+	if err := os.MkdirAll(blobDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	bottle := writeTestBottle(t, t.TempDir(), "fbtestpkg.tar.gz", "fbtestpkg", "1.0")
+	sha, err := fileSHA256(bottle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(bottle, filepath.Join(blobDir, sha)); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(cacheDir, "fbtestpkg--1.0.bottle.tar.gz")
+	if err := os.Symlink(filepath.Join(blobDirName, sha), name); err != nil {
+		t.Fatal(err)
+	}
+	return name, filepath.Join(cacheDir, extractCacheDirName, sha)
+}
+
+func TestExtractBottleCached(t *testing.T) {
+	client, cacheDir := newCleanupTestClient(t)
+	tarPath, entry := cacheTestBottle(t, cacheDir)
+
+	first := t.TempDir()
+	if _, err := client.extractBottleCached(tarPath, first); err != nil {
+		t.Fatalf("first extraction failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(entry, extractCacheIndex)); err != nil {
+		t.Fatalf("extracted bottle not cached: %v", err)
+	}
+
+	// With the blob gone only the cache can provide the files.
+	blob, _ := filepath.EvalSymlinks(tarPath)
+	if err := os.WriteFile(blob, []byte("not a bottle"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	second := t.TempDir()
+	written, err := client.extractBottleCached(tarPath, second)
+	if err != nil {
+		t.Fatalf("cached extraction failed: %v", err)
+	}
+	if len(written) == 0 {
+		t.Fatal("cached extraction returned no files")
+	}
+	data, err := os.ReadFile(filepath.Join(second, "fbtestpkg", "1.0", "bin", "fbtestpkg"))
+	if err != nil || string(data) != "#!/bin/sh\necho fbtestpkg\n" {
+		t.Fatalf("unexpected cloned file %q: %v", data, err)
+	}
+}
+
+func TestExtractBottleCachedDiscardsChangedEntry(t *testing.T) {
+	client, cacheDir := newCleanupTestClient(t)
+	tarPath, entry := cacheTestBottle(t, cacheDir)
+
+	if _, err := client.extractBottleCached(tarPath, t.TempDir()); err != nil {
+		t.Fatalf("first extraction failed: %v", err)
+	}
+	cached := filepath.Join(entry, extractCacheTree, "fbtestpkg", "1.0", "bin", "fbtestpkg")
+	if err := os.Remove(cached); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cached, []byte("truncated"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if _, err := client.extractBottleCached(tarPath, dir); err != nil {
+		t.Fatalf("extraction after corruption failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "fbtestpkg", "1.0", "bin", "fbtestpkg"))
+	if err != nil || string(data) != "#!/bin/sh\necho fbtestpkg\n" {
+		t.Fatalf("expected bottle to be extracted again, got %q: %v", data, err)
+	}
+	data, _ = os.ReadFile(cached)
+	if string(data) != "#!/bin/sh\necho fbtestpkg\n" {
+		t.Errorf("expected cache entry to be rebuilt, got %q", data)
+	}
+}
+
+func TestExtractBottleCachedDisabled(t *testing.T) {
+	client, cacheDir := newCleanupTestClient(t)
+	client.DisableExtractCache = true
+	tarPath, entry := cacheTestBottle(t, cacheDir)
+
+	if _, err := client.extractBottleCached(tarPath, t.TempDir()); err != nil {
+		t.Fatalf("extraction failed: %v", err)
+	}
+	if _, err := os.Stat(entry); !os.IsNotExist(err) {
+		t.Error("extraction cache should not be used when disabled")
+	}
+}
+
+func TestPruneDownloadCacheRemovesExtracted(t *testing.T) {
+	client, cacheDir := newCleanupTestClient(t)
+	tarPath, entry := cacheTestBottle(t, cacheDir)
+	if _, err := client.extractBottleCached(tarPath, t.TempDir()); err != nil {
+		t.Fatalf("extraction failed: %v", err)
+	}
+	orphan := filepath.Join(cacheDir, extractCacheDirName, "orphan")
+	if err := os.MkdirAll(orphan, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.PruneDownloadCache(CachePruneOptions{}); err != nil {
+		t.Fatalf("PruneDownloadCache failed: %v", err)
+	}
+	if _, err := os.Stat(entry); err != nil {
+		t.Errorf("entry for a kept blob should stay: %v", err)
+	}
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Error("entry without a blob should be removed")
+	}
+
+	if _, err := client.PruneDownloadCache(CachePruneOptions{All: true}); err != nil {
+		t.Fatalf("PruneDownloadCache failed: %v", err)
+	}
+	if _, err := os.Stat(entry); !os.IsNotExist(err) {
+		t.Error("entry should be removed with its blob")
+	}
+}
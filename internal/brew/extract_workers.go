@@ -0,0 +1,228 @@
+package brew
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// maxBufferedExtractFile is the largest file handed to an extraction
+// worker. Larger files are written by the goroutine reading the archive,
+// which keeps memory use to a few buffered files per worker.
+const maxBufferedExtractFile = 4 << 20
+
+// maxDefaultExtractWorkers caps the default number of extraction workers;
+// beyond it the disk rather than the CPU is the limit.
+const maxDefaultExtractWorkers = 8
+
+// defaultExtractWorkers is one extraction worker per CPU, up to
+// maxDefaultExtractWorkers.
+func defaultExtractWorkers() int {
+	return min(runtime.NumCPU(), maxDefaultExtractWorkers)
+}
+
+func (c *Client) getExtractWorkers() int {
+	if c.ExtractWorkers <= 0 {
+		return defaultExtractWorkers()
+	}
+	return c.ExtractWorkers
+}
+
+type fileWriteJob struct {
+	// index is the file's position in the extracted file list.
+	index  int
+	name   string
+	target string
+	mode   os.FileMode
+	data   []byte
+}
+
+type fileWriteResult struct {
+	index int
+	file  KegFile
+}
+
+// fileWriterPool writes buffered regular files on a fixed number of
+// goroutines. Its methods other than the workers' own are called from the
+// goroutine reading the archive.
+type fileWriterPool struct {
+	jobs    chan fileWriteJob
+	workers sync.WaitGroup
+	pending sync.WaitGroup
+	targets map[string]bool
+
+	mu       sync.Mutex
+	firstErr error
+	results  []fileWriteResult
+}
+
+// newFileWriterPool starts n workers. With n below 2 no workers are
+// started and accepts reports false, so every file is written in place.
+func newFileWriterPool(n int) *fileWriterPool {
+	p := &fileWriterPool{targets: make(map[string]bool)}
+	if n < 2 {
+		return p
+	}
+	p.jobs = make(chan fileWriteJob, n)
+	p.workers.Add(n)
+	for range n {
+		go p.work()
+	}
+	return p
+}
+
+func (p *fileWriterPool) work() {
+	defer p.workers.Done()
+	buf := make([]byte, 256*1024)
+	for job := range p.jobs {
+		if p.err() == nil {
+			file, err := writeExtractedFile(job.name, job.target, job.mode, bytes.NewReader(job.data), int64(len(job.data)), buf)
+			p.mu.Lock()
+			if err != nil {
+				p.firstErr = errors.Join(p.firstErr, err)
+			} else {
+				p.results = append(p.results, fileWriteResult{index: job.index, file: file})
+			}
+			p.mu.Unlock()
+		}
+		p.pending.Done()
+	}
+}
+
+// accepts reports whether a file of size bytes should be queued.
+func (p *fileWriterPool) accepts(size int64) bool {
+	return p.jobs != nil && size <= maxBufferedExtractFile
+}
+
+func (p *fileWriterPool) submit(job fileWriteJob) {
+	p.targets[job.target] = true
+	p.pending.Add(1)
+	p.jobs <- job
+}
+
+// queued reports whether target is queued and may not be written yet.
+func (p *fileWriterPool) queued(target string) bool {
+	return p.targets[target]
+}
+
+// err returns the first write error, if any.
+func (p *fileWriterPool) err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.firstErr
+}
+
+// wait blocks until every queued file is written and returns the files
+// written since the last call.
+func (p *fileWriterPool) wait() ([]fileWriteResult, error) {
+	p.pending.Wait()
+	clear(p.targets)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	results := p.results
+	p.results = nil
+	return results, p.firstErr
+}
+
+// close stops the workers once the queued files are written.
+func (p *fileWriterPool) close() {
+	if p.jobs == nil {
+		return
+	}
+	close(p.jobs)
+	p.workers.Wait()
+}
+
+// readAhead decompresses a stream in its own goroutine, keeping up to
+// readAheadChunks chunks ready for the reader.
+type readAhead struct {
+	chunks  chan []byte
+	done    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+	cur     []byte
+	err     error
+	// srcErr ended the source; it is set before chunks is closed.
+	srcErr error
+}
+
+const (
+	readAheadChunks    = 4
+	readAheadChunkSize = 1024 * 1024
+)
+
+func newReadAhead(src io.Reader) *readAhead {
+	r := &readAhead{
+		chunks:  make(chan []byte, readAheadChunks),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go func() {
+		defer close(r.stopped)
+		defer close(r.chunks)
+		for {
+			buf := make([]byte, readAheadChunkSize)
+			n := 0
+			var err error
+			for n < len(buf) && err == nil {
+				var m int
+				m, err = src.Read(buf[n:])
+				n += m
+			}
+			if n > 0 {
+				select {
+				case r.chunks <- buf[:n]:
+				case <-r.done:
+					return
+				}
+			}
+			if err != nil {
+				r.srcErr = err
+				return
+			}
+		}
+	}()
+	return r
+}
+
+func (r *readAhead) Read(p []byte) (int, error) {
+	for len(r.cur) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		chunk, ok := <-r.chunks
+		if !ok {
+			r.err = r.srcErr
+			continue
+		}
+		r.cur = chunk
+	}
+	n := copy(p, r.cur)
+	r.cur = r.cur[n:]
+	return n, nil
+}
+
+// Close stops the decompressing goroutine and waits for it to let go of
+// the source, so the source can be closed next.
+func (r *readAhead) Close() error {
+	r.once.Do(func() { close(r.done) })
+	<-r.stopped
+	return nil
+}
+
+// multiCloser closes each of its closers in order, returning the first
+// error.
+type multiCloser []io.Closer
+
+func (m multiCloser) Close() error {
+	var first error
+	for _, c := range m {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,114 @@
+package brew
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// writeLargeTestBottle writes a bottle with many small files, one file too
+// big for the extraction workers, a hard link, a symlink and a file that
+// appears twice, compressed with gzip or zstd.
+func writeLargeTestBottle(t *testing.T, compression string) string {
+	t.Helper()
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	add := func(name string, data []byte) {
+		tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(data))})
+		tw.Write(data)
+	}
+	tw.WriteHeader(&tar.Header{Name: "big/1.0/lib/", Typeflag: tar.TypeDir, Mode: 0755})
+	for i := range 200 {
+		add(fmt.Sprintf("big/1.0/lib/file%03d", i), bytes.Repeat([]byte{byte(i)}, 1000+i*37))
+	}
+	add("big/1.0/lib/huge", bytes.Repeat([]byte("llvm"), maxBufferedExtractFile/2))
+	add("big/1.0/lib/twice", []byte("first"))
+	add("big/1.0/lib/twice", []byte("second"))
+	tw.WriteHeader(&tar.Header{Name: "big/1.0/lib/hard", Typeflag: tar.TypeLink, Linkname: "big/1.0/lib/file007"})
+	tw.WriteHeader(&tar.Header{Name: "big/1.0/lib/soft", Typeflag: tar.TypeSymlink, Linkname: "file008"})
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "big.bottle.tar")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var w io.WriteCloser
+	if compression == "zstd" {
+		w, _ = zstd.NewWriter(file)
+	} else {
+		w = gzip.NewWriter(file)
+	}
+	if _, err := w.Write(archive.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExtractBottleWithWorkersMatchesSequential(t *testing.T) {
+	for _, compression := range []string{"gzip", "zstd"} {
+		t.Run(compression, func(t *testing.T) {
+			bottle := writeLargeTestBottle(t, compression)
+
+			sequentialDir := t.TempDir()
+			sequential, err := extractBottle(bottle, sequentialDir, sequentialDir, 1)
+			if err != nil {
+				t.Fatalf("sequential extraction failed: %v", err)
+			}
+			parallelDir := t.TempDir()
+			parallel, err := extractBottle(bottle, parallelDir, parallelDir, 4)
+			if err != nil {
+				t.Fatalf("parallel extraction failed: %v", err)
+			}
+
+			if !reflect.DeepEqual(sequential, parallel) {
+				t.Fatalf("manifests differ:\nsequential %v\nparallel   %v", sequential, parallel)
+			}
+			for _, name := range []string{"file000", "file199", "huge", "hard"} {
+				want, _ := os.ReadFile(filepath.Join(sequentialDir, "big", "1.0", "lib", name))
+				got, err := os.ReadFile(filepath.Join(parallelDir, "big", "1.0", "lib", name))
+				if err != nil || !bytes.Equal(got, want) {
+					t.Errorf("%s differs after parallel extraction (err %v)", name, err)
+				}
+			}
+			if data, _ := os.ReadFile(filepath.Join(parallelDir, "big", "1.0", "lib", "twice")); string(data) != "second" {
+				t.Errorf("Expected the later duplicate entry to win, got %q", data)
+			}
+			for _, file := range parallel {
+				if file.Link == "" && file.SHA256 == "" {
+					t.Errorf("Missing hash for %s", file.Path)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractBottleReportsTruncatedArchive(t *testing.T) {
+	bottle := writeLargeTestBottle(t, "gzip")
+	data, err := os.ReadFile(bottle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(bottle, data[:len(data)/2], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if _, err := extractBottle(bottle, dir, dir, 4); err == nil {
+		t.Fatal("Expected a truncated bottle to fail")
+	}
+}
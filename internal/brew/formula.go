@@ -3,9 +3,7 @@ package brew
 import (
 	"context"
 	"encoding/json"
-	"fastbrew/internal/httpclient"
 	"fmt"
-	"net/http"
 	"sort"
 	"strings"
 	"time"
@@ -17,14 +15,19 @@ const FormulaAPIURL = "https://formulae.brew.sh/api/formula"
 
 // RemoteFormula represents the full JSON response from formulae.brew.sh
 type RemoteFormula struct {
-	Name         string   `json:"name"`
-	Desc         string   `json:"desc"`
-	Homepage     string   `json:"homepage"`
-	Versions     Versions `json:"versions"`
-	Revision     int      `json:"revision"`
-	Bottle       Bottle   `json:"bottle"`
-	Dependencies []string `json:"dependencies"`
-	KegOnly      bool     `json:"keg_only"`
+	Name                    string   `json:"name"`
+	Desc                    string   `json:"desc"`
+	Homepage                string   `json:"homepage"`
+	Versions                Versions `json:"versions"`
+	Revision                int      `json:"revision"`
+	Bottle                  Bottle   `json:"bottle"`
+	Dependencies            []string `json:"dependencies"`
+	BuildDependencies       []string `json:"build_dependencies,omitempty"`
+	TestDependencies        []string `json:"test_dependencies,omitempty"`
+	RecommendedDependencies []string `json:"recommended_dependencies,omitempty"`
+	OptionalDependencies    []string `json:"optional_dependencies,omitempty"`
+	KegOnly                 bool     `json:"keg_only"`
+	Caveats                 string   `json:"caveats"`
 }
 
 // FullVersion returns the version string including the revision suffix.
@@ -45,6 +48,7 @@ type Bottle struct {
 }
 
 type BottleStable struct {
+	Rebuild int                   `json:"rebuild"`
 	RootURL string                `json:"root_url"`
 	Files   map[string]BottleFile `json:"files"`
 }
@@ -56,49 +60,76 @@ type BottleFile struct {
 }
 
 // FetchFormula gets metadata for a single package
-func (c *Client) FetchFormula(name string) (*RemoteFormula, error) {
+func (c *Client) FetchFormula(ctx context.Context, name string) (*RemoteFormula, error) {
 	url := fmt.Sprintf("%s/%s.json", FormulaAPIURL, name)
 
 	// Use shared HTTP client with request-specific timeout via context
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request for %s: %w", name, err)
-	}
-
-	httpClient := httpclient.Get()
-	resp, err := httpClient.Do(req)
+	data, status, err := c.fetchAPIJSON(ctx, url, "formula", name)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch formula %s: %w", name, err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode == 404 {
-		return nil, fmt.Errorf("formula %q not found - try 'fastbrew search %s' to find the correct name (e.g., python@3.12 instead of python)", name, name)
+	if status == 404 {
+		// The API only serves formulae under their current name.
+		if canonical, ok := c.ResolveFormulaName(name); ok {
+			return c.FetchFormula(ctx, canonical)
+		}
+		return nil, kindErrorf(KindNotFound, "formula %q not found - try 'fastbrew search %s' to find the correct name (e.g., python@3.12 instead of python)", name, name)
 	}
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("api returned status %d for %s", resp.StatusCode, name)
+	if status != 200 {
+		return nil, kindErrorf(KindNetwork, "api returned status %d for %s", status, name)
 	}
 
 	var f RemoteFormula
-	if err := json.NewDecoder(resp.Body).Decode(&f); err != nil {
+	if err := json.Unmarshal(data, &f); err != nil {
 		return nil, fmt.Errorf("failed to parse formula json for %s: %w", name, err)
 	}
 
 	return &f, nil
 }
 
+// indexedFormula returns name's API record from the index database, or
+// nil when the database is unavailable or has no bottle for this platform
+// and the API must be asked. The database is rebuilt whenever the index
+// changes, so the record matches the index dependencies were resolved
+// from.
+func (c *Client) indexedFormula(name string) *RemoteFormula {
+	db, err := c.OpenIndexDB()
+	if err != nil {
+		return nil
+	}
+	f, err := db.RemoteFormula(name)
+	if err != nil {
+		return nil
+	}
+	if _, _, err := f.bottleFile(); err != nil {
+		return nil
+	}
+	return f
+}
+
 // GetBottleInfo returns the URL and SHA256 for the current platform
 func (f *RemoteFormula) GetBottleInfo() (string, string, error) {
-	platform, err := GetPlatform()
+	_, file, err := f.bottleFile()
 	if err != nil {
 		return "", "", err
 	}
+	return file.URL, file.SHA256, nil
+}
+
+// bottleFile picks the bottle for the current platform, falling back to
+// older macOS releases and then to "all". It returns the bottle tag too.
+func (f *RemoteFormula) bottleFile() (string, BottleFile, error) {
+	platform, err := GetPlatform()
+	if err != nil {
+		return "", BottleFile{}, err
+	}
 
 	if file, ok := f.Bottle.Stable.Files[platform]; ok {
-		return file.URL, file.SHA256, nil
+		return platform, file, nil
 	}
 
 	var candidates []string
@@ -123,12 +154,12 @@ func (f *RemoteFormula) GetBottleInfo() (string, string, error) {
 
 	for _, candidate := range candidates {
 		if file, ok := f.Bottle.Stable.Files[candidate]; ok {
-			return file.URL, file.SHA256, nil
+			return candidate, file, nil
 		}
 	}
 
 	if file, ok := f.Bottle.Stable.Files["all"]; ok {
-		return file.URL, file.SHA256, nil
+		return "all", file, nil
 	}
 
 	available := make([]string, 0, len(f.Bottle.Stable.Files))
@@ -136,5 +167,5 @@ func (f *RemoteFormula) GetBottleInfo() (string, string, error) {
 		available = append(available, k)
 	}
 	sort.Strings(available)
-	return "", "", fmt.Errorf("no bottle available for platform %s (available: %s)", platform, strings.Join(available, ", "))
+	return "", BottleFile{}, fmt.Errorf("no bottle available for platform %s (available: %s)", platform, strings.Join(available, ", "))
 }
@@ -0,0 +1,231 @@
+package brew
+
+import (
+	"fastbrew/internal/paths"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Hook events. Pre hooks run before a package is changed and can stop the
+// change by failing; post hooks run once it succeeded.
+const (
+	HookPreInstall    = "pre-install"
+	HookPostInstall   = "post-install"
+	HookPreUpgrade    = "pre-upgrade"
+	HookPostUpgrade   = "post-upgrade"
+	HookPreUninstall  = "pre-uninstall"
+	HookPostUninstall = "post-uninstall"
+)
+
+// HookEvents lists every hook event in the order they are documented.
+var HookEvents = []string{
+	HookPreInstall, HookPostInstall,
+	HookPreUpgrade, HookPostUpgrade,
+	HookPreUninstall, HookPostUninstall,
+}
+
+// hooksDirName is the directory under the configuration directory holding
+// hook scripts: hooks.d/<event>/ runs for every package and
+// hooks.d/<event>/<package>/ for one.
+const hooksDirName = "hooks.d"
+
+// HookEnv describes the package a hook runs for. Hooks receive it as
+// FASTBREW_* environment variables.
+type HookEnv struct {
+	Package string
+	Version string
+	// OldVersion is the version an upgrade replaces.
+	OldVersion string
+	// Keg is the installed version's directory in the Cellar or Caskroom.
+	Keg    string
+	IsCask bool
+}
+
+// ParseHookKey splits the part of a hooks.<event>[.<package>] config key
+// after the prefix into the event and the package, which is empty for
+// hooks that run for every package.
+func ParseHookKey(key string) (event, pkg string, err error) {
+	event, pkg, _ = strings.Cut(key, ".")
+	if !slices.Contains(HookEvents, event) {
+		return "", "", fmt.Errorf("unknown hook event %q (use one of %s)", event, strings.Join(HookEvents, ", "))
+	}
+	return event, pkg, nil
+}
+
+// DefaultHooksDir is where hook scripts are looked up unless
+// Client.HooksDir is set.
+func DefaultHooksDir() string {
+	return filepath.Join(paths.ConfigDir(), hooksDirName)
+}
+
+// HasHooks reports whether any hook is configured, either in configured
+// (as Client.Hooks) or as a script in DefaultHooksDir.
+func HasHooks(configured map[string]string) bool {
+	if len(configured) > 0 {
+		return true
+	}
+	entries, _ := os.ReadDir(DefaultHooksDir())
+	return len(entries) > 0
+}
+
+func (c *Client) getHooksDir() string {
+	if c.HooksDir != "" {
+		return c.HooksDir
+	}
+	return DefaultHooksDir()
+}
+
+// hook is one command run for an event.
+type hook struct {
+	// name identifies the hook in messages: its config key or script path.
+	name    string
+	command string
+	script  bool
+}
+
+func (h hook) cmd() *exec.Cmd {
+	if h.script {
+		return exec.Command(h.command)
+	}
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", h.command)
+	}
+	return exec.Command("sh", "-c", h.command)
+}
+
+// hooksFor returns the hooks to run for event on pkg: configured commands
+// before scripts, and those for every package before pkg's own.
+func (c *Client) hooksFor(event, pkg string) []hook {
+	var hooks []hook
+	for _, key := range []string{event, event + "." + pkg} {
+		if command := c.Hooks[key]; command != "" {
+			hooks = append(hooks, hook{name: "hooks." + key, command: command})
+		}
+	}
+	dir := filepath.Join(c.getHooksDir(), event)
+	hooks = append(hooks, c.hookScripts(dir)...)
+	return append(hooks, c.hookScripts(filepath.Join(dir, pkg))...)
+}
+
+// hookScripts lists the executable files in dir, sorted by name. Hidden
+// files, directories and files without execute permission are skipped.
+func (c *Client) hookScripts(dir string) []hook {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var hooks []hook
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") || entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if runtime.GOOS != "windows" && info.Mode().Perm()&0111 == 0 {
+			c.logger().Debug("skipping hook that is not executable", "path", path)
+			continue
+		}
+		hooks = append(hooks, hook{name: path, command: path, script: true})
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].name < hooks[j].name })
+	return hooks
+}
+
+// RunHooks runs the hooks registered for event and env.Package, one at a
+// time, with their output sent to Out. A failing pre hook stops the
+// remaining hooks and its error is returned so the caller can leave the
+// package alone; failures of post hooks are reported and nil is returned,
+// as the change has already been made.
+func (c *Client) RunHooks(event string, env HookEnv) error {
+	hooks := c.hooksFor(event, env.Package)
+	if len(hooks) == 0 {
+		return nil
+	}
+	vars := append(os.Environ(),
+		"FASTBREW_HOOK="+event,
+		"FASTBREW_PACKAGE="+env.Package,
+		"FASTBREW_VERSION="+env.Version,
+		"FASTBREW_OLD_VERSION="+env.OldVersion,
+		"FASTBREW_KEG="+env.Keg,
+		"FASTBREW_PREFIX="+c.Prefix,
+		"FASTBREW_CELLAR="+c.Cellar,
+	)
+	if env.IsCask {
+		vars = append(vars, "FASTBREW_CASK=1")
+	}
+
+	// Hooks often edit shared files, so parallel installs take turns.
+	c.hookMu.Lock()
+	defer c.hookMu.Unlock()
+	for _, h := range hooks {
+		c.printf("  🪝 %s %s: %s\n", event, env.Package, h.name)
+		cmd := h.cmd()
+		cmd.Env = vars
+		cmd.Stdout = c.out()
+		cmd.Stderr = c.out()
+		if err := cmd.Run(); err != nil {
+			err = fmt.Errorf("%s hook %s failed: %w", event, h.name, err)
+			if strings.HasPrefix(event, "pre-") {
+				return err
+			}
+			c.printf("  ⚠️  %v\n", err)
+		}
+	}
+	return nil
+}
+
+// formulaHookEnv describes f for hooks run while installing or upgrading
+// it from oldVersion (empty for installs).
+func (c *Client) formulaHookEnv(f *RemoteFormula, oldVersion string) HookEnv {
+	return HookEnv{
+		Package:    f.Name,
+		Version:    f.Versions.Stable,
+		OldVersion: oldVersion,
+		Keg:        filepath.Join(c.Cellar, f.Name, f.Versions.Stable),
+	}
+}
+
+// InstalledHookEnv describes the installed formula name, at its linked or
+// newest version, for uninstall hooks.
+func (c *Client) InstalledHookEnv(name string) HookEnv {
+	version := c.currentKegVersion(name)
+	env := HookEnv{Package: name, Version: version}
+	if version != "" {
+		env.Keg = filepath.Join(c.Cellar, name, version)
+	}
+	return env
+}
+
+// runPostHooks runs the post hooks for event on every formula in queue
+// that was installed and linked. oldVersions holds the versions upgrades
+// replaced.
+func (c *Client) runPostHooks(event string, queue []*RemoteFormula, oldVersions map[string]string) {
+	for _, f := range queue {
+		if c.packageCompleted(f.Name) {
+			c.RunHooks(event, c.formulaHookEnv(f, oldVersions[f.Name]))
+		}
+	}
+}
+
+// caskHookEvents returns the pre and post hook events for a cask
+// operation. Reinstalls run no hooks.
+func caskHookEvents(operation string) (pre, post string) {
+	switch operation {
+	case MutationOperationInstall:
+		return HookPreInstall, HookPostInstall
+	case MutationOperationUpgrade:
+		return HookPreUpgrade, HookPostUpgrade
+	case MutationOperationUninstall:
+		return HookPreUninstall, HookPostUninstall
+	}
+	return "", ""
+}
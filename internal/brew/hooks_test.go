@@ -0,0 +1,146 @@
+package brew
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func newHookTestClient(t *testing.T) (*Client, *strings.Builder) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("hook tests use sh scripts")
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	client, _ := newCleanupTestClient(t)
+	client.HooksDir = t.TempDir()
+	out := &strings.Builder{}
+	client.Out = out
+	return client, out
+}
+
+func writeHookScript(t *testing.T, path, body string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseHookKey(t *testing.T) {
+	event, pkg, err := ParseHookKey("post-install.python@3.12")
+	if err != nil || event != HookPostInstall || pkg != "python@3.12" {
+		t.Errorf("got %q, %q, %v", event, pkg, err)
+	}
+	event, pkg, err = ParseHookKey("pre-uninstall")
+	if err != nil || event != HookPreUninstall || pkg != "" {
+		t.Errorf("got %q, %q, %v", event, pkg, err)
+	}
+	if _, _, err := ParseHookKey("after-install"); err == nil {
+		t.Error("expected an unknown event to be rejected")
+	}
+}
+
+func TestRunHooksOrderAndEnvironment(t *testing.T) {
+	client, _ := newHookTestClient(t)
+	log := filepath.Join(t.TempDir(), "log")
+	client.Hooks = map[string]string{
+		HookPostUpgrade:           `echo "config-all $FASTBREW_PACKAGE $FASTBREW_OLD_VERSION $FASTBREW_VERSION" >> ` + log,
+		HookPostUpgrade + ".wget": `echo "config-wget $FASTBREW_KEG" >> ` + log,
+		HookPostUpgrade + ".curl": `echo "config-curl" >> ` + log,
+	}
+	dir := filepath.Join(client.HooksDir, HookPostUpgrade)
+	writeHookScript(t, filepath.Join(dir, "20-second"), `echo "script-2 $FASTBREW_HOOK" >> `+log)
+	writeHookScript(t, filepath.Join(dir, "10-first"), `echo "script-1" >> `+log)
+	writeHookScript(t, filepath.Join(dir, "wget", "only"), `echo "script-wget" >> `+log)
+	if err := os.WriteFile(filepath.Join(dir, "disabled"), []byte("#!/bin/sh\necho disabled >> "+log+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	env := HookEnv{Package: "wget", Version: "1.25.0", OldVersion: "1.24.5", Keg: "/cellar/wget/1.25.0"}
+	if err := client.RunHooks(HookPostUpgrade, env); err != nil {
+		t.Fatalf("RunHooks failed: %v", err)
+	}
+
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "config-all wget 1.24.5 1.25.0\nconfig-wget /cellar/wget/1.25.0\nscript-1\nscript-2 post-upgrade\nscript-wget\n"
+	if string(data) != want {
+		t.Errorf("hooks ran as\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestRunHooksFailures(t *testing.T) {
+	client, out := newHookTestClient(t)
+	client.Hooks = map[string]string{
+		HookPreInstall:  "exit 3",
+		HookPostInstall: "exit 4",
+	}
+	writeHookScript(t, filepath.Join(client.HooksDir, HookPreInstall, "after"), "echo should-not-run")
+
+	err := client.RunHooks(HookPreInstall, HookEnv{Package: "jq"})
+	if err == nil || !strings.Contains(err.Error(), "hooks.pre-install") {
+		t.Fatalf("expected the failing pre hook to be returned, got %v", err)
+	}
+	if strings.Contains(out.String(), "should-not-run") {
+		t.Error("hooks after a failing pre hook should not run")
+	}
+
+	if err := client.RunHooks(HookPostInstall, HookEnv{Package: "jq"}); err != nil {
+		t.Errorf("post hook failures should only be reported, got %v", err)
+	}
+	if !strings.Contains(out.String(), "post-install hook hooks.post-install failed") {
+		t.Errorf("expected the post hook failure to be reported, got %q", out.String())
+	}
+}
+
+func TestInstallLocalBottleRunsHooks(t *testing.T) {
+	client, _ := newHookTestClient(t)
+	log := filepath.Join(t.TempDir(), "log")
+	client.Hooks = map[string]string{
+		HookPreInstall:  `test ! -d "$FASTBREW_KEG" && echo "pre $FASTBREW_PACKAGE $FASTBREW_VERSION" >> ` + log,
+		HookPostInstall: `test -x "$FASTBREW_KEG/bin/$FASTBREW_PACKAGE" && echo "post $FASTBREW_PACKAGE" >> ` + log,
+	}
+	path := writeTestBottle(t, t.TempDir(), "fbtestpkg--1.2.3.x86_64_linux.bottle.tar.gz", "fbtestpkg", "1.2.3")
+
+	sha, err := fileSHA256(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.InstallLocalBottles([]string{path}, LocalBottleOptions{SHA256: sha}); err != nil {
+		t.Fatalf("InstallLocalBottles failed: %v", err)
+	}
+	data, _ := os.ReadFile(log)
+	if string(data) != "pre fbtestpkg 1.2.3\npost fbtestpkg\n" {
+		t.Errorf("unexpected hook log %q", data)
+	}
+}
+
+func TestInstallLocalBottleStoppedByPreHook(t *testing.T) {
+	client, _ := newHookTestClient(t)
+	client.Hooks = map[string]string{HookPreInstall + ".fbtestpkg": "exit 1"}
+	path := writeTestBottle(t, t.TempDir(), "fbtestpkg--1.2.3.x86_64_linux.bottle.tar.gz", "fbtestpkg", "1.2.3")
+
+	sha, err := fileSHA256(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.InstallLocalBottles([]string{path}, LocalBottleOptions{SHA256: sha})
+	if err == nil || !strings.Contains(err.Error(), "pre-install hook") {
+		t.Fatalf("expected the failing pre-install hook to stop the install, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(client.Cellar, "fbtestpkg")); !os.IsNotExist(err) {
+		t.Error("package should not be installed")
+	}
+}
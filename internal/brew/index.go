@@ -5,9 +5,10 @@ import (
 	"context"
 	"encoding/gob"
 	"encoding/json"
-	"fastbrew/internal/httpclient"
+	"fastbrew/internal/atomicfile"
+	"fastbrew/internal/paths"
+	"fastbrew/internal/retry"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -27,13 +28,23 @@ type FormulaVersions struct {
 }
 
 type Formula struct {
-	Name         string          `json:"name"`
-	Desc         string          `json:"desc"`
-	Homepage     string          `json:"homepage"`
-	Versions     FormulaVersions `json:"versions"`
-	Revision     int             `json:"revision"`
-	Installed    []interface{}   `json:"installed"`
-	Dependencies []string        `json:"dependencies"`
+	Name     string          `json:"name"`
+	Desc     string          `json:"desc"`
+	Homepage string          `json:"homepage"`
+	Versions FormulaVersions `json:"versions"`
+	Revision int             `json:"revision"`
+	// License is an SPDX-style expression such as "Apache-2.0 or MIT".
+	License string `json:"license,omitempty"`
+	// Aliases are other names the formula answers to, such as "python" for
+	// python@3.13; OldNames are names it had before being renamed.
+	Aliases                 []string      `json:"aliases,omitempty"`
+	OldNames                []string      `json:"oldnames,omitempty"`
+	Installed               []interface{} `json:"installed"`
+	Dependencies            []string      `json:"dependencies"`
+	BuildDependencies       []string      `json:"build_dependencies,omitempty"`
+	TestDependencies        []string      `json:"test_dependencies,omitempty"`
+	RecommendedDependencies []string      `json:"recommended_dependencies,omitempty"`
+	OptionalDependencies    []string      `json:"optional_dependencies,omitempty"`
 }
 
 // FullVersion returns the version string including the revision suffix.
@@ -51,6 +62,11 @@ type Cask struct {
 	Desc     string `json:"desc"`
 	Homepage string `json:"homepage"`
 	Version  string `json:"version"`
+	// OldTokens are tokens the cask had before being renamed.
+	OldTokens   []string       `json:"old_tokens,omitempty"`
+	AutoUpdates bool           `json:"auto_updates"`
+	Artifacts   []CaskArtifact `json:"artifacts,omitempty"`
+	DependsOn   CaskDependsOn  `json:"depends_on"`
 }
 
 type Index struct {
@@ -59,36 +75,40 @@ type Index struct {
 }
 
 type SearchItem struct {
-	Name   string
-	Desc   string
-	IsCask bool
+	Name    string
+	Desc    string
+	IsCask  bool
+	Aliases []string
 }
 
 type indexCacheMetadata struct {
 	ETag         string `json:"etag,omitempty"`
 	LastModified string `json:"last_modified,omitempty"`
+	// CheckedAt is when the server last confirmed or replaced the index.
+	CheckedAt time.Time `json:"checked_at,omitempty"`
 }
 
 // IsCask checks if a package name is a cask by looking it up in the index
 func (c *Client) IsCask(name string) (bool, error) {
-	idx, err := c.LoadIndex()
+	if c.index == nil && c.casks == nil {
+		if db, err := c.OpenIndexDB(); err == nil {
+			return db.HasCask(name), nil
+		}
+	}
+	casks, err := c.caskIndex()
 	if err != nil {
 		return false, err
 	}
-	for _, cask := range idx.Casks {
-		if cask.Token == name {
-			return true, nil
-		}
-	}
-	return false, nil
+	_, ok := casks[name]
+	return ok, nil
 }
 
+// GetCacheDir returns the cache directory, creating it if needed.
 func (c *Client) GetCacheDir() (string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", err
+	dir := c.CacheDir
+	if dir == "" {
+		dir = paths.CacheDir()
 	}
-	dir := filepath.Join(home, ".fastbrew", "cache")
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return "", err
 	}
@@ -104,7 +124,7 @@ func (c *Client) cleanupOldUncompressedFiles(cacheDir string) {
 			if _, err := os.Stat(zstPath); err == nil {
 				os.Remove(path)
 				if c.Verbose {
-					fmt.Printf("🧹 Cleaned up old uncompressed file: %s\n", file)
+					c.printf("🧹 Cleaned up old uncompressed file: %s\n", file)
 				}
 			}
 		}
@@ -123,47 +143,45 @@ func decompressFile(data []byte) ([]byte, error) {
 }
 
 func (c *Client) LoadIndex() (*Index, error) {
-	c.indexOnce.Do(func() {
-		// Re-check inside Do in case another goroutine already loaded it.
-		if c.index != nil {
-			return
-		}
-		var formulae []Formula
-		var casks []Cask
-		var fErr, cErr error
-
-		var wg sync.WaitGroup
-		wg.Add(2)
-		go func() {
-			defer wg.Done()
-			formulae, fErr = c.loadFormulaIndexDirect()
-		}()
-		go func() {
-			defer wg.Done()
-			casks, cErr = c.loadCaskIndexDirect()
-		}()
-		wg.Wait()
-
-		if fErr != nil {
-			c.indexErr = fErr
-			return
-		}
-		if cErr != nil {
-			c.indexErr = cErr
-			return
-		}
-
-		c.index = &Index{
-			Formulae: formulae,
-			Casks:    casks,
-		}
-	})
+	c.indexMu.Lock()
+	defer c.indexMu.Unlock()
+	// An index already set, e.g. by LoadFormulaIndex, is kept.
+	if !c.indexLoaded && c.index == nil {
+		c.index, c.indexErr = c.loadIndex()
+	}
+	c.indexLoaded = true
 	if c.indexErr != nil {
 		return nil, c.indexErr
 	}
 	return c.index, nil
 }
 
+func (c *Client) loadIndex() (*Index, error) {
+	var formulae []Formula
+	var casks []Cask
+	var fErr, cErr error
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		formulae, fErr = c.loadFormulaIndexDirect()
+	}()
+	go func() {
+		defer wg.Done()
+		casks, cErr = c.loadCaskIndexDirect()
+	}()
+	wg.Wait()
+
+	if fErr != nil {
+		return nil, fErr
+	}
+	if cErr != nil {
+		return nil, cErr
+	}
+	return &Index{Formulae: formulae, Casks: casks}, nil
+}
+
 func (c *Client) LoadFormulaIndex() ([]Formula, error) {
 	// If a partial in-memory index exists with no formulae, load them from disk.
 	if c.index != nil && len(c.index.Formulae) == 0 {
@@ -211,7 +229,7 @@ func (c *Client) loadFormulaIndexDirect() ([]Formula, error) {
 
 	fPath := filepath.Join(cacheDir, "formula.json.zst")
 	var formulae []Formula
-	if err := loadJSON(fPath, &formulae); err != nil {
+	if err := c.loadIndexJSON(fPath, FormulaAPI, "Formula", &formulae); err != nil {
 		return nil, err
 	}
 
@@ -231,7 +249,7 @@ func (c *Client) loadCaskIndexDirect() ([]Cask, error) {
 
 	cPath := filepath.Join(cacheDir, "cask.json.zst")
 	var casks []Cask
-	if err := loadJSON(cPath, &casks); err != nil {
+	if err := c.loadIndexJSON(cPath, CaskAPI, "Cask", &casks); err != nil {
 		return nil, err
 	}
 
@@ -247,7 +265,7 @@ func (c *Client) ensureFreshFormulaJSON() error {
 	fPath := filepath.Join(cacheDir, "formula.json.zst")
 	if shouldUpdate(fPath) {
 		if c.Verbose {
-			fmt.Println("🔄 Updating Formula index...")
+			c.println("🔄 Updating Formula index...")
 		}
 		if _, err := c.downloadAndCompress(FormulaAPI, fPath, "Formula"); err != nil {
 			return err
@@ -265,7 +283,7 @@ func (c *Client) ensureFreshCaskJSON() error {
 	cPath := filepath.Join(cacheDir, "cask.json.zst")
 	if shouldUpdate(cPath) {
 		if c.Verbose {
-			fmt.Println("🔄 Updating Cask index...")
+			c.println("🔄 Updating Cask index...")
 		}
 		if _, err := c.downloadAndCompress(CaskAPI, cPath, "Cask"); err != nil {
 			return err
@@ -275,13 +293,14 @@ func (c *Client) ensureFreshCaskJSON() error {
 }
 
 func (c *Client) LoadIndexLegacy() (*Index, error) {
-	c.indexOnce.Do(func() {
-		if err := c.EnsureFreshJSONs(); err != nil {
-			c.indexErr = err
-			return
+	c.indexMu.Lock()
+	defer c.indexMu.Unlock()
+	if !c.indexLoaded {
+		c.indexLoaded = true
+		if c.indexErr = c.EnsureFreshJSONs(); c.indexErr == nil {
+			c.index, c.indexErr = c.LoadRawIndex()
 		}
-		c.index, c.indexErr = c.LoadRawIndex()
-	})
+	}
 	if c.indexErr != nil {
 		return nil, c.indexErr
 	}
@@ -300,75 +319,32 @@ func (c *Client) LoadRawIndex() (*Index, error) {
 	cPath := filepath.Join(cacheDir, "cask.json.zst")
 
 	var idx Index
-	if err := loadJSON(fPath, &idx.Formulae); err != nil {
+	if err := c.loadIndexJSON(fPath, FormulaAPI, "Formula", &idx.Formulae); err != nil {
 		return nil, err
 	}
-	_ = loadJSON(cPath, &idx.Casks)
+	_ = c.loadIndexJSON(cPath, CaskAPI, "Cask", &idx.Casks)
 
 	return &idx, nil
 }
 
-func (c *Client) ForceRefreshIndex() (bool, error) {
+// RawIndexJSON returns the formula index, or the cask index when cask is
+// set, as the JSON array the Homebrew API serves. The cached copy is
+// refreshed first when it is stale.
+func (c *Client) RawIndexJSON(cask bool) ([]byte, error) {
 	cacheDir, err := c.GetCacheDir()
 	if err != nil {
-		return false, err
-	}
-
-	fmt.Println("🔄 Refreshing package index...")
-
-	var wg sync.WaitGroup
-	errCh := make(chan error, 2)
-	changedCh := make(chan bool, 2)
-
-	wg.Add(2)
-	go func() {
-		defer wg.Done()
-		changed, err := c.downloadAndCompress(FormulaAPI, filepath.Join(cacheDir, "formula.json.zst"), "Formula")
-		if err != nil {
-			errCh <- err
-			return
-		}
-		changedCh <- changed
-	}()
-	go func() {
-		defer wg.Done()
-		changed, err := c.downloadAndCompress(CaskAPI, filepath.Join(cacheDir, "cask.json.zst"), "Cask")
-		if err != nil {
-			errCh <- err
-			return
-		}
-		changedCh <- changed
-	}()
-	wg.Wait()
-	close(errCh)
-	close(changedCh)
-
-	if len(errCh) > 0 {
-		return false, <-errCh
+		return nil, err
 	}
-
-	anyChanged := false
-	for changed := range changedCh {
-		if changed {
-			anyChanged = true
-		}
+	path := filepath.Join(cacheDir, "formula.json.zst")
+	ensure := c.ensureFreshFormulaJSON
+	if cask {
+		path = filepath.Join(cacheDir, "cask.json.zst")
+		ensure = c.ensureFreshCaskJSON
 	}
-
-	if !anyChanged {
-		return false, nil
+	if err := ensure(); err != nil {
+		return nil, err
 	}
-
-	os.Remove(filepath.Join(cacheDir, "search.gob.zst"))
-	os.Remove(filepath.Join(cacheDir, "prefix_index.gob"))
-
-	c.prefixIndex = nil
-	c.index = nil
-	c.indexErr = nil
-	c.indexOnce = sync.Once{}
-	c.prefixIndexOnce = sync.Once{}
-	c.notifyInvalidation(EventIndexRefreshed)
-
-	return true, nil
+	return readCachedIndexData(path)
 }
 
 func (c *Client) EnsureFreshJSONs() error {
@@ -382,7 +358,7 @@ func (c *Client) EnsureFreshJSONs() error {
 
 	if shouldUpdate(fPath) {
 		if c.Verbose {
-			fmt.Println("🔄 Updating Formula index...")
+			c.println("🔄 Updating Formula index...")
 		}
 		if _, err := c.downloadAndCompress(FormulaAPI, fPath, "Formula"); err != nil {
 			return err
@@ -390,7 +366,7 @@ func (c *Client) EnsureFreshJSONs() error {
 	}
 	if shouldUpdate(cPath) {
 		if c.Verbose {
-			fmt.Println("🔄 Updating Cask index...")
+			c.println("🔄 Updating Cask index...")
 		}
 		if _, err := c.downloadAndCompress(CaskAPI, cPath, "Cask"); err != nil {
 			return err
@@ -400,58 +376,61 @@ func (c *Client) EnsureFreshJSONs() error {
 }
 
 func (c *Client) downloadAndCompress(url, path, label string) (bool, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	url = c.mirrorURL(url)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return false, err
-	}
-
 	metaPath := path + ".meta.json"
 	meta := loadIndexCacheMetadata(metaPath)
+	conditional := http.Header{}
 	if meta.ETag != "" {
-		req.Header.Set("If-None-Match", meta.ETag)
+		conditional.Set("If-None-Match", meta.ETag)
 	}
 	if meta.LastModified != "" {
-		req.Header.Set("If-Modified-Since", meta.LastModified)
+		conditional.Set("If-Modified-Since", meta.LastModified)
 	}
 
-	httpClient := httpclient.Get()
-	resp, err := httpClient.Do(req)
+	// The raw JSON lands in a resumable partial file first, so a dropped
+	// connection on the large formula index picks up where it stopped.
+	partialPath := path + ".download"
+	var header http.Header
+	var notModified bool
+	err := retry.Do(ctx, func() error {
+		var err error
+		header, notModified, err = c.downloadToFile(ctx, url, partialPath, downloadOptions{Header: conditional})
+		return err
+	})
 	if err != nil {
-		return false, err
+		return false, fmt.Errorf("failed to fetch %s index: %w", label, err)
 	}
-	defer resp.Body.Close()
+	meta.CheckedAt = time.Now()
 
-	if resp.StatusCode == http.StatusNotModified {
+	if notModified {
 		if c.Verbose {
-			fmt.Printf("✅ %s index is already up-to-date\n", label)
+			c.printf("✅ %s index is already up-to-date\n", label)
 		}
-		meta.ETag = coalesceHeader(resp.Header.Get("ETag"), meta.ETag)
-		meta.LastModified = coalesceHeader(resp.Header.Get("Last-Modified"), meta.LastModified)
+		meta.ETag = coalesceHeader(header.Get("ETag"), meta.ETag)
+		meta.LastModified = coalesceHeader(header.Get("Last-Modified"), meta.LastModified)
 		if err := saveIndexCacheMetadata(metaPath, meta); err != nil && c.Verbose {
-			fmt.Printf("⚠️  Failed to save %s index metadata: %v\n", label, err)
+			c.printf("⚠️  Failed to save %s index metadata: %v\n", label, err)
 		}
 		return false, nil
 	}
-	if resp.StatusCode != http.StatusOK {
-		return false, fmt.Errorf("failed to fetch %s index: %s", label, resp.Status)
-	}
 
-	data, err := io.ReadAll(resp.Body)
+	data, err := os.ReadFile(partialPath)
+	os.Remove(partialPath)
 	if err != nil {
 		return false, fmt.Errorf("failed to read response body: %w", err)
 	}
 
 	if existingData, err := readCachedIndexData(path); err == nil && bytes.Equal(existingData, data) {
 		if c.Verbose {
-			fmt.Printf("✅ %s index unchanged, skipping write\n", label)
+			c.printf("✅ %s index unchanged, skipping write\n", label)
 		}
-		meta.ETag = coalesceHeader(resp.Header.Get("ETag"), meta.ETag)
-		meta.LastModified = coalesceHeader(resp.Header.Get("Last-Modified"), meta.LastModified)
+		meta.ETag = coalesceHeader(header.Get("ETag"), meta.ETag)
+		meta.LastModified = coalesceHeader(header.Get("Last-Modified"), meta.LastModified)
 		if err := saveIndexCacheMetadata(metaPath, meta); err != nil && c.Verbose {
-			fmt.Printf("⚠️  Failed to save %s index metadata: %v\n", label, err)
+			c.printf("⚠️  Failed to save %s index metadata: %v\n", label, err)
 		}
 		return false, nil
 	}
@@ -460,28 +439,28 @@ func (c *Client) downloadAndCompress(url, path, label string) (bool, error) {
 
 	compressed, err := compressFile(data)
 	if err != nil {
-		if err := os.WriteFile(path, data, 0644); err != nil {
+		if err := atomicfile.WriteFile(path, data, 0644); err != nil {
 			return false, fmt.Errorf("failed to write file: %w", err)
 		}
 		if c.Verbose {
-			fmt.Printf("⚠️  %s index stored uncompressed (%d bytes)\n", label, originalSize)
+			c.printf("⚠️  %s index stored uncompressed (%d bytes)\n", label, originalSize)
 		}
 	} else {
-		if err := os.WriteFile(path, compressed, 0644); err != nil {
+		if err := atomicfile.WriteFile(path, compressed, 0644); err != nil {
 			return false, fmt.Errorf("failed to write compressed file: %w", err)
 		}
 
 		if c.Verbose {
 			ratio := float64(originalSize-len(compressed)) / float64(originalSize) * 100
-			fmt.Printf("✅ %s index compressed: %d → %d bytes (%.1f%% reduction)\n",
+			c.printf("✅ %s index compressed: %d → %d bytes (%.1f%% reduction)\n",
 				label, originalSize, len(compressed), ratio)
 		}
 	}
 
-	meta.ETag = coalesceHeader(resp.Header.Get("ETag"), meta.ETag)
-	meta.LastModified = coalesceHeader(resp.Header.Get("Last-Modified"), meta.LastModified)
+	meta.ETag = coalesceHeader(header.Get("ETag"), meta.ETag)
+	meta.LastModified = coalesceHeader(header.Get("Last-Modified"), meta.LastModified)
 	if err := saveIndexCacheMetadata(metaPath, meta); err != nil && c.Verbose {
-		fmt.Printf("⚠️  Failed to save %s index metadata: %v\n", label, err)
+		c.printf("⚠️  Failed to save %s index metadata: %v\n", label, err)
 	}
 
 	return true, nil
@@ -497,109 +476,130 @@ func (c *Client) GetSearchIndex() ([]SearchItem, error) {
 		return nil, err
 	}
 	gobPath := filepath.Join(cacheDir, "search.gob.zst")
-	prefixIndexPath := filepath.Join(cacheDir, "prefix_index.gob")
+	prefixIndexPath := filepath.Join(cacheDir, prefixIndexFileName)
 	fPath := filepath.Join(cacheDir, "formula.json.zst")
 	cPath := filepath.Join(cacheDir, "cask.json.zst")
 
 	if isFreshAgainst(gobPath, fPath, cPath) {
 		if items, loadErr := loadSearchItemsFromGob(gobPath); loadErr == nil {
 			if !isFreshAgainst(prefixIndexPath, fPath, cPath) {
-				prefixIdx := NewPrefixIndex()
-				if buildErr := prefixIdx.BuildIndex(items); buildErr == nil {
-					if saveErr := prefixIdx.Save(prefixIndexPath); saveErr != nil && c.Verbose {
-						fmt.Printf("⚠️  Failed to save prefix index: %v\n", saveErr)
-					}
-				}
+				c.refreshPrefixIndex(prefixIndexPath, items)
 			}
 			return items, nil
 		}
 	}
 
-	idx, err := c.LoadRawIndex()
-	if err != nil {
-		return nil, err
+	var items []SearchItem
+	if db, dbErr := c.OpenIndexDB(); dbErr == nil {
+		items, _ = db.SearchItems()
 	}
+	if items == nil {
+		idx, err := c.LoadRawIndex()
+		if err != nil {
+			return nil, err
+		}
 
-	items := make([]SearchItem, 0, len(idx.Formulae)+len(idx.Casks))
-	for _, f := range idx.Formulae {
-		items = append(items, SearchItem{Name: f.Name, Desc: f.Desc, IsCask: false})
-	}
-	for _, cask := range idx.Casks {
-		items = append(items, SearchItem{Name: cask.Token, Desc: cask.Desc, IsCask: true})
+		items = make([]SearchItem, 0, len(idx.Formulae)+len(idx.Casks))
+		for _, f := range idx.Formulae {
+			items = append(items, SearchItem{Name: f.Name, Desc: f.Desc, IsCask: false, Aliases: f.Aliases})
+		}
+		for _, cask := range idx.Casks {
+			items = append(items, SearchItem{Name: cask.Token, Desc: cask.Desc, IsCask: true})
+		}
 	}
 
 	var buf bytes.Buffer
 	if err := gob.NewEncoder(&buf).Encode(items); err == nil {
 		gobData := buf.Bytes()
 		if compressed, err := compressFile(gobData); err == nil {
-			if err := os.WriteFile(gobPath, compressed, 0644); err == nil && c.Verbose {
+			if err := atomicfile.WriteFile(gobPath, compressed, 0644); err == nil && c.Verbose {
 				ratio := float64(len(gobData)-len(compressed)) / float64(len(gobData)) * 100
-				fmt.Printf("✅ Search index compressed: %d → %d bytes (%.1f%% reduction)\n",
+				c.printf("✅ Search index compressed: %d → %d bytes (%.1f%% reduction)\n",
 					len(gobData), len(compressed), ratio)
 			}
 		} else {
-			os.WriteFile(gobPath, gobData, 0644)
+			atomicfile.WriteFile(gobPath, gobData, 0644)
 		}
 	}
 
-	prefixIdx := NewPrefixIndex()
-	if err := prefixIdx.BuildIndex(items); err == nil {
-		if err := prefixIdx.Save(prefixIndexPath); err == nil && c.Verbose {
-			prefixCount, totalItems, avgBucket := prefixIdx.Stats()
-			fmt.Printf("✅ Prefix index built: %d prefixes, %d items, avg bucket %.1f\n",
-				prefixCount, totalItems, avgBucket)
-		}
-	}
+	c.refreshPrefixIndex(prefixIndexPath, items)
 
 	return items, nil
 }
 
-func (c *Client) GetPrefixIndex() (*PrefixIndex, error) {
-	var err error
-	c.prefixIndexOnce.Do(func() {
-		cacheDir, cacheErr := c.GetCacheDir()
-		if cacheErr != nil {
-			err = cacheErr
-			return
+// refreshPrefixIndex brings the prefix index saved at path in line with
+// items and saves it. An existing index is updated in place, so only
+// packages added or changed since it was saved are re-indexed; without one
+// the index is built from scratch.
+func (c *Client) refreshPrefixIndex(path string, items []SearchItem) *PrefixIndex {
+	prefixIdx := NewPrefixIndex()
+	if prefixIdx.Load(path) == nil {
+		if !prefixIdx.Update(items) {
+			// Unchanged; touch it so it counts as fresh again.
+			now := time.Now()
+			os.Chtimes(path, now, now)
+			return prefixIdx
 		}
-		prefixIndexPath := filepath.Join(cacheDir, "prefix_index.gob")
-		fPath := filepath.Join(cacheDir, "formula.json.zst")
-		cPath := filepath.Join(cacheDir, "cask.json.zst")
-
-		c.prefixIndex = NewPrefixIndex()
+	} else if err := prefixIdx.BuildIndex(items); err != nil {
+		return nil
+	}
 
-		if isFreshAgainst(prefixIndexPath, fPath, cPath) {
-			if loadErr := c.prefixIndex.Load(prefixIndexPath); loadErr == nil {
-				if c.Verbose {
-					prefixCount, totalItems, avgBucket := c.prefixIndex.Stats()
-					fmt.Printf("✅ Prefix index loaded: %d prefixes, %d items, avg bucket %.1f\n",
-						prefixCount, totalItems, avgBucket)
-				}
-				return
-			}
+	if err := prefixIdx.Save(path); err != nil {
+		if c.Verbose {
+			c.printf("⚠️  Failed to save prefix index: %v\n", err)
 		}
+	} else if c.Verbose {
+		prefixCount, totalItems, avgBucket := prefixIdx.Stats()
+		c.printf("✅ Prefix index built: %d grams, %d items, avg bucket %.1f\n",
+			prefixCount, totalItems, avgBucket)
+	}
+	return prefixIdx
+}
 
-		items, getErr := c.GetSearchIndex()
-		if getErr != nil {
-			err = getErr
-			return
-		}
+// GetPrefixIndex returns the prefix index, loading or building it on first
+// use. A failed load is tried again on the next call.
+func (c *Client) GetPrefixIndex() (*PrefixIndex, error) {
+	c.prefixIndexMu.Lock()
+	defer c.prefixIndexMu.Unlock()
+	if c.prefixIndex != nil {
+		return c.prefixIndex, nil
+	}
 
-		if buildErr := c.prefixIndex.BuildIndex(items); buildErr != nil {
-			err = buildErr
-			return
-		}
+	cacheDir, err := c.GetCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	prefixIndexPath := filepath.Join(cacheDir, prefixIndexFileName)
+	fPath := filepath.Join(cacheDir, "formula.json.zst")
+	cPath := filepath.Join(cacheDir, "cask.json.zst")
 
-		if saveErr := c.prefixIndex.Save(prefixIndexPath); saveErr != nil && c.Verbose {
-			fmt.Printf("⚠️  Failed to save prefix index: %v\n", saveErr)
+	if isFreshAgainst(prefixIndexPath, fPath, cPath) {
+		prefixIdx := NewPrefixIndex()
+		if loadErr := prefixIdx.Load(prefixIndexPath); loadErr == nil {
+			if c.Verbose {
+				prefixCount, totalItems, avgBucket := prefixIdx.Stats()
+				c.printf("✅ Prefix index loaded: %d grams, %d items, avg bucket %.1f\n",
+					prefixCount, totalItems, avgBucket)
+			}
+			c.prefixIndex = prefixIdx
+			return prefixIdx, nil
 		}
-	})
+	}
 
+	items, err := c.GetSearchIndex()
 	if err != nil {
 		return nil, err
 	}
 
-	return c.prefixIndex, nil
+	// GetSearchIndex refreshed the saved index unless it was fresh.
+	prefixIdx := NewPrefixIndex()
+	if prefixIdx.Load(prefixIndexPath) != nil {
+		if err := prefixIdx.BuildIndex(items); err != nil {
+			return nil, err
+		}
+	}
+	c.prefixIndex = prefixIdx
+	return prefixIdx, nil
 }
 
 func (c *Client) SearchFuzzyWithIndex(query string) ([]SearchItem, error) {
@@ -609,17 +609,18 @@ func (c *Client) SearchFuzzyWithIndex(query string) ([]SearchItem, error) {
 	}
 
 	matches := prefixIdx.SearchFuzzy(query)
-	if matches == nil {
+	tapMatches := searchTapItems(query, c.TapSearchItems())
+	if matches == nil && tapMatches == nil {
 		return []SearchItem{}, nil
 	}
 
 	items := prefixIdx.GetItems()
-	result := make([]SearchItem, len(matches))
+	result := make([]SearchItem, len(matches), len(matches)+len(tapMatches))
 	for i, match := range matches {
 		result[i] = items[match.Index]
 	}
 
-	return result, nil
+	return append(result, tapMatches...), nil
 }
 
 func isFresh(target, source string) bool {
@@ -634,12 +635,19 @@ func isFresh(target, source string) bool {
 	return tInfo.ModTime().After(sInfo.ModTime())
 }
 
+// shouldUpdate reports whether the index at path is due for a check. The
+// last check is taken from its metadata, so a 304 restarts the clock
+// without rewriting the index and invalidating everything built from it.
 func shouldUpdate(path string) bool {
 	info, err := os.Stat(path)
 	if err != nil {
 		return true
 	}
-	return time.Since(info.ModTime()) > 24*time.Hour
+	checked := loadIndexCacheMetadata(path + ".meta.json").CheckedAt
+	if checked.IsZero() {
+		checked = info.ModTime()
+	}
+	return time.Since(checked) > 24*time.Hour
 }
 
 func isFreshAgainst(target string, sources ...string) bool {
@@ -699,7 +707,7 @@ func saveIndexCacheMetadata(path string, meta indexCacheMetadata) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0644)
+	return atomicfile.WriteFile(path, data, 0644)
 }
 
 func coalesceHeader(newValue, fallback string) string {
@@ -709,6 +717,34 @@ func coalesceHeader(newValue, fallback string) string {
 	return fallback
 }
 
+// loadIndexJSON parses the cached index at path into v. A cache file that
+// no longer parses is removed, with its metadata so the request is not
+// answered with 304, and downloaded from url again.
+func (c *Client) loadIndexJSON(path, url, label string, v interface{}) error {
+	err := loadJSON(path, v)
+	if err == nil || os.IsNotExist(err) {
+		return err
+	}
+	c.logger().Warn("corrupt index cache, downloading again", "path", path, "error", err)
+	c.printf("⚠️  %s index cache is corrupt, downloading it again...\n", label)
+	os.Remove(path)
+	os.Remove(path + ".meta.json")
+	_, err = c.downloadAndCompress(url, path, label)
+	if err == nil {
+		if err = loadJSON(path, v); err == nil {
+			return nil
+		}
+	}
+	// No good copy could be downloaded; fall back to the last snapshot.
+	if snapshot, restoreErr := restoreLatestIndexFiles(filepath.Dir(path)); restoreErr == nil {
+		if loadJSON(path, v) == nil {
+			c.printf("↩️  Using the %s index from %s instead\n", label, snapshot.Created.Local().Format(time.DateTime))
+			return nil
+		}
+	}
+	return err
+}
+
 func loadJSON(path string, v interface{}) error {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -723,28 +759,12 @@ func loadJSON(path string, v interface{}) error {
 	return json.Unmarshal(decompressed, v)
 }
 
-func downloadFile(url, path string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return err
-	}
-
-	httpClient := httpclient.Get()
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	out, err := os.Create(path)
-	if err != nil {
+// downloadFile fetches url into path with retries, resuming partial
+// transfers between attempts.
+func (c *Client) downloadFile(url, path string) error {
+	ctx := context.Background()
+	return retry.Do(ctx, func() error {
+		_, _, err := c.downloadToFile(ctx, url, path, downloadOptions{})
 		return err
-	}
-	defer out.Close()
-
-	_, err = io.Copy(out, resp.Body)
-	return err
+	})
 }
@@ -0,0 +1,398 @@
+package brew
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIndexDB_BuildAndLookup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.db")
+
+	formulae := []formulaRecord{
+		{
+			Formula: Formula{Name: "wget", Desc: "Internet file retriever", Versions: FormulaVersions{Stable: "1.24.5"}, Revision: 1, Dependencies: []string{"openssl@3", "libidn2"}},
+			Bottle: Bottle{Stable: BottleStable{Files: map[string]BottleFile{
+				"arm64_sonoma": {URL: "https://ghcr.io/v2/homebrew/core/wget/blobs/sha256:abc", SHA256: "abc"},
+			}}},
+		},
+		{Formula: Formula{Name: "openssl@3", Desc: "Cryptography and SSL/TLS Toolkit", Versions: FormulaVersions{Stable: "3.3.1"}}},
+	}
+	casks := []Cask{{Token: "firefox", Desc: "Web browser", Version: "128.0"}}
+
+	if err := BuildIndexDB(path, formulae, casks); err != nil {
+		t.Fatalf("BuildIndexDB failed: %v", err)
+	}
+
+	db, err := OpenIndexDB(path)
+	if err != nil {
+		t.Fatalf("OpenIndexDB failed: %v", err)
+	}
+	defer db.Close()
+
+	f, err := db.Formula("wget")
+	if err != nil {
+		t.Fatalf("Formula lookup failed: %v", err)
+	}
+	if f.FullVersion() != "1.24.5_1" {
+		t.Errorf("Expected version 1.24.5_1, got %s", f.FullVersion())
+	}
+
+	deps, err := db.Dependencies("wget")
+	if err != nil {
+		t.Fatalf("Dependencies lookup failed: %v", err)
+	}
+	if len(deps) != 2 || deps[0] != "openssl@3" {
+		t.Errorf("Unexpected dependencies: %v", deps)
+	}
+
+	bottle, err := db.Bottle("wget")
+	if err != nil {
+		t.Fatalf("Bottle lookup failed: %v", err)
+	}
+	if bottle.Stable.Files["arm64_sonoma"].SHA256 != "abc" {
+		t.Errorf("Unexpected bottle data: %+v", bottle)
+	}
+	if _, err := db.Bottle("openssl@3"); !errors.Is(err, ErrIndexDBKeyNotFound) {
+		t.Errorf("Expected ErrIndexDBKeyNotFound for formula without bottles, got %v", err)
+	}
+
+	cask, err := db.Cask("firefox")
+	if err != nil {
+		t.Fatalf("Cask lookup failed: %v", err)
+	}
+	if cask.Version != "128.0" {
+		t.Errorf("Expected cask version 128.0, got %s", cask.Version)
+	}
+
+	if _, err := db.Formula("missing"); !errors.Is(err, ErrIndexDBKeyNotFound) {
+		t.Errorf("Expected ErrIndexDBKeyNotFound, got %v", err)
+	}
+	if !db.HasFormula("openssl@3") || db.HasFormula("firefox") || !db.HasCask("firefox") {
+		t.Error("HasFormula/HasCask returned unexpected results")
+	}
+
+	items, err := db.SearchItems()
+	if err != nil {
+		t.Fatalf("SearchItems failed: %v", err)
+	}
+	if len(items) != 3 {
+		t.Errorf("Expected 3 search items, got %d", len(items))
+	}
+
+	nFormulae, nCasks := db.Stats()
+	if nFormulae != 2 || nCasks != 1 {
+		t.Errorf("Expected stats 2/1, got %d/%d", nFormulae, nCasks)
+	}
+}
+
+func TestIndexDB_RejectsInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.db")
+	if err := os.WriteFile(path, []byte("not a database at all"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	if _, err := OpenIndexDB(path); err == nil {
+		t.Error("Expected error opening invalid index database")
+	}
+}
+
+func TestIndexDB_RebuildWhileOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.db")
+	old := []formulaRecord{{Formula: Formula{Name: "jq", Versions: FormulaVersions{Stable: "1.7"}}}}
+	if err := BuildIndexDB(path, old, nil); err != nil {
+		t.Fatalf("BuildIndexDB failed: %v", err)
+	}
+	db, err := OpenIndexDB(path)
+	if err != nil {
+		t.Fatalf("OpenIndexDB failed: %v", err)
+	}
+	defer db.Close()
+
+	rebuilt := []formulaRecord{{Formula: Formula{Name: "jq", Versions: FormulaVersions{Stable: "1.7.1"}}}}
+	if err := BuildIndexDB(path, rebuilt, nil); err != nil {
+		t.Fatalf("rebuilding an open database failed: %v", err)
+	}
+	if f, err := db.Formula("jq"); err != nil || f.Versions.Stable != "1.7" {
+		t.Errorf("open handle should keep reading the old file, got %+v, %v", f, err)
+	}
+	fresh, err := OpenIndexDB(path)
+	if err != nil {
+		t.Fatalf("OpenIndexDB failed: %v", err)
+	}
+	defer fresh.Close()
+	if f, err := fresh.Formula("jq"); err != nil || f.Versions.Stable != "1.7.1" {
+		t.Errorf("reopened database should have the rebuilt record, got %+v, %v", f, err)
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".index.db.tmp-*")); len(leftovers) != 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+}
+
+// buildLargeIndexDB writes a database whose formulae table spans several
+// pages of its own, returning its path and the formula names.
+func buildLargeIndexDB(t *testing.T) (string, []string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "index.db")
+	var formulae []formulaRecord
+	var names []string
+	for i := 0; i < 300; i++ {
+		name := fmt.Sprintf("formula-%03d", i)
+		names = append(names, name)
+		formulae = append(formulae, formulaRecord{Formula: Formula{Name: name, Desc: strings.Repeat("d", 40), Versions: FormulaVersions{Stable: "1.0"}}})
+	}
+	if err := BuildIndexDB(path, formulae, nil); err != nil {
+		t.Fatalf("BuildIndexDB failed: %v", err)
+	}
+	return path, names
+}
+
+// corruptLeafEntry points the first element of the leaf page holding key
+// far past the end of the file, as a damaged directory entry would.
+func corruptLeafEntry(t *testing.T, path, key string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pageSize := os.Getpagesize()
+	at := bytes.Index(data[2*pageSize:], []byte(`{"name":"`+key+`"`))
+	if at < 0 {
+		t.Fatalf("record of %s not found", key)
+	}
+	page := (2*pageSize + at) / pageSize * pageSize
+	// Page header: id (8), flags (2), count (2), overflow (4); then leaf
+	// elements of flags (4), pos (4), key size (4), value size (4).
+	binary.LittleEndian.PutUint32(data[page+16+4:], 0x7fffffff)
+	binary.LittleEndian.PutUint32(data[page+16+8:], 0x7fffffff)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIndexDB_CorruptEntryFailsLookups(t *testing.T) {
+	path, names := buildLargeIndexDB(t)
+	corruptLeafEntry(t, path, names[150])
+
+	status := NewCacheValidator(filepath.Dir(path)).ValidateIndexDB()
+	if status.Valid || !errors.Is(status.Error, ErrIndexDBCorrupt) {
+		t.Errorf("validation should report the damaged page: %+v", status)
+	}
+
+	db, err := OpenIndexDB(path)
+	if err != nil {
+		t.Fatalf("OpenIndexDB failed: %v", err)
+	}
+	defer db.Close()
+	var corrupt bool
+	for _, name := range names {
+		_, err := db.Formula(name)
+		switch {
+		case errors.Is(err, ErrIndexDBCorrupt):
+			corrupt = true
+		case err != nil && !errors.Is(err, ErrIndexDBKeyNotFound):
+			t.Fatalf("Formula(%s) = %v, want a corruption error", name, err)
+		}
+	}
+	if !corrupt {
+		t.Error("expected lookups through the damaged page to report corruption")
+	}
+}
+
+func TestIndexDB_RejectsTruncatedFile(t *testing.T) {
+	path, _ := buildLargeIndexDB(t)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, info.Size()/2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenIndexDB(path); !errors.Is(err, ErrIndexDBCorrupt) {
+		t.Errorf("OpenIndexDB = %v, want a truncation error", err)
+	}
+}
+
+func TestClientRebuildsCorruptIndexDB(t *testing.T) {
+	cacheDir := t.TempDir()
+	var formulae []string
+	for i := 0; i < 300; i++ {
+		formulae = append(formulae, fmt.Sprintf(`{"name": "formula-%03d", "desc": "%s", "versions": {"stable": "1.0"}}`, i, strings.Repeat("d", 40)))
+	}
+	if err := os.WriteFile(filepath.Join(cacheDir, "formula.json.zst"), []byte("["+strings.Join(formulae, ",")+"]"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(cacheDir, "cask.json.zst"), []byte("[]"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	client := &Client{CacheDir: cacheDir, Out: io.Discard}
+
+	db, err := client.OpenIndexDB()
+	if err != nil {
+		t.Fatalf("OpenIndexDB failed: %v", err)
+	}
+	corruptLeafEntry(t, filepath.Join(cacheDir, indexDBFileName), "formula-150")
+	for i := 0; i < 300 && !db.corrupt.Load(); i++ {
+		db.Formula(fmt.Sprintf("formula-%03d", i))
+	}
+	if !db.corrupt.Load() {
+		t.Fatal("expected a lookup to find the damage")
+	}
+
+	rebuilt, err := client.OpenIndexDB()
+	if err != nil {
+		t.Fatalf("reopening after corruption failed: %v", err)
+	}
+	if rebuilt == db {
+		t.Fatal("expected the corrupt database to be replaced")
+	}
+	if f, err := rebuilt.Formula("formula-150"); err != nil || f.Name != "formula-150" {
+		t.Errorf("rebuilt database lookup = %+v, %v", f, err)
+	}
+}
+
+func TestClientRebuildsIndexDBBuiltFromOtherJSON(t *testing.T) {
+	cacheDir := t.TempDir()
+	fPath := filepath.Join(cacheDir, "formula.json.zst")
+	if err := os.WriteFile(fPath, []byte(`[{"name": "jq", "versions": {"stable": "1.7"}}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(cacheDir, "cask.json.zst"), []byte("[]"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&Client{CacheDir: cacheDir, Out: io.Discard}).OpenIndexDB(); err != nil {
+		t.Fatalf("OpenIndexDB failed: %v", err)
+	}
+
+	// An update replaces the index while the database written from the old
+	// one is still newer than it, as a rebuild that finished late leaves it.
+	if err := os.WriteFile(fPath, []byte(`[{"name": "jq", "versions": {"stable": "1.7.1"}}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(cacheDir, indexDBFileName), later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := (&Client{CacheDir: cacheDir, Out: io.Discard}).OpenIndexDB()
+	if err != nil {
+		t.Fatalf("OpenIndexDB failed: %v", err)
+	}
+	if f, err := db.Formula("jq"); err != nil || f.Versions.Stable != "1.7.1" {
+		t.Errorf("Formula(jq) = %+v, %v, want the version from the new index", f, err)
+	}
+}
+
+func TestIndexedFormula(t *testing.T) {
+	platform, err := GetPlatform()
+	if err != nil {
+		t.Skip(err)
+	}
+	cacheDir := t.TempDir()
+	formulae := `[
+		{"name": "jq", "versions": {"stable": "1.7.1"}, "revision": 1, "dependencies": ["oniguruma"],
+		 "keg_only": true, "caveats": "Read the manual",
+		 "bottle": {"stable": {"rebuild": 0, "files": {"` + platform + `": {"url": "https://ghcr.io/v2/homebrew/core/jq/blobs/sha256:abc", "sha256": "abc"}}}}},
+		{"name": "source-only", "versions": {"stable": "2.0"}}
+	]`
+	if err := os.WriteFile(filepath.Join(cacheDir, "formula.json.zst"), []byte(formulae), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(cacheDir, "cask.json.zst"), []byte("[]"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	client := &Client{CacheDir: cacheDir}
+
+	f := client.indexedFormula("jq")
+	if f == nil {
+		t.Fatal("expected jq to be answered from the index")
+	}
+	if f.FullVersion() != "1.7.1_1" || !f.KegOnly || f.Caveats != "Read the manual" || len(f.Dependencies) != 1 {
+		t.Errorf("unexpected formula %+v", f)
+	}
+	if url, sha, err := f.GetBottleInfo(); err != nil || sha != "abc" || url == "" {
+		t.Errorf("GetBottleInfo = %q, %q, %v", url, sha, err)
+	}
+	if client.indexedFormula("source-only") != nil {
+		t.Error("a formula without a bottle for this platform must be fetched from the API")
+	}
+}
+
+func TestIndexDB_CaskOldTokensAndClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.db")
+	casks := []Cask{
+		{Token: "visual-studio-code", OldTokens: []string{"vscode"}, Version: "1.95.0"},
+		{Token: "firefox", Version: "128.0"},
+	}
+	if err := BuildIndexDB(path, nil, casks); err != nil {
+		t.Fatalf("BuildIndexDB failed: %v", err)
+	}
+	db, err := OpenIndexDB(path)
+	if err != nil {
+		t.Fatalf("OpenIndexDB failed: %v", err)
+	}
+
+	cask, err := db.Cask("vscode")
+	if err != nil || cask.Token != "visual-studio-code" {
+		t.Errorf("Cask(vscode) = %+v, %v; want visual-studio-code", cask, err)
+	}
+	if !db.HasCask("vscode") || db.HasCask("chrome") {
+		t.Error("HasCask returned unexpected results")
+	}
+	if nFormulae, nCasks := db.Stats(); nFormulae != 0 || nCasks != 2 {
+		t.Errorf("Expected stats 0/2, got %d/%d", nFormulae, nCasks)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := db.Cask("firefox"); err == nil {
+		t.Error("Expected lookups on a closed database to fail")
+	}
+	if db.HasCask("firefox") {
+		t.Error("Expected HasCask on a closed database to be false")
+	}
+}
+
+func TestInstallLookupsLeaveIndexUnloaded(t *testing.T) {
+	cacheDir := t.TempDir()
+	formulae := `[
+		{"name": "python@3.13", "aliases": ["python"], "versions": {"stable": "3.13.1"}},
+		{"name": "jq", "versions": {"stable": "1.7.1"}}
+	]`
+	casks := `[{"token": "visual-studio-code", "old_tokens": ["vscode"], "version": "1.95.0"}]`
+	if err := os.WriteFile(filepath.Join(cacheDir, "formula.json.zst"), []byte(formulae), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(cacheDir, "cask.json.zst"), []byte(casks), 0644); err != nil {
+		t.Fatal(err)
+	}
+	client := &Client{CacheDir: cacheDir, Out: io.Discard}
+
+	packages := client.resolveInstallAliases([]string{"python", "jq", "vscode"})
+	if want := []string{"python@3.13", "jq", "vscode"}; !reflect.DeepEqual(packages, want) {
+		t.Errorf("resolveInstallAliases = %v, want %v", packages, want)
+	}
+	lookup, err := client.formulaLookup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := client.classifyFormulae(packages, lookup); !reflect.DeepEqual(got, []string{"python@3.13", "jq"}) {
+		t.Errorf("classifyFormulae = %v", got)
+	}
+	if isCask, err := client.IsCask("vscode"); err != nil || !isCask {
+		t.Errorf("IsCask(vscode) = %v, %v", isCask, err)
+	}
+
+	if client.index != nil || client.casks != nil {
+		t.Error("expected install lookups to go to the index database without loading the index")
+	}
+}
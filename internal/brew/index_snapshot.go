@@ -0,0 +1,299 @@
+package brew
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	// indexSnapshotDirName holds copies of the previous indexes, one
+	// directory per update, for RollbackIndex.
+	indexSnapshotDirName = "index-snapshots"
+	// indexSnapshotTimeFormat names snapshot directories so they sort by age.
+	indexSnapshotTimeFormat = "20060102T150405.000000000Z"
+	defaultIndexSnapshots   = 3
+)
+
+// indexSnapshotFiles are the cached indexes a snapshot keeps, with their
+// cache metadata.
+var indexSnapshotFiles = []string{
+	"formula.json.zst", "formula.json.zst.meta.json",
+	"cask.json.zst", "cask.json.zst.meta.json",
+}
+
+// IndexSnapshot is a copy of the package indexes as they were before an
+// update.
+type IndexSnapshot struct {
+	ID      string    `json:"id"`
+	Path    string    `json:"path"`
+	Created time.Time `json:"created"`
+}
+
+func (c *Client) indexSnapshotsToKeep() int {
+	if c.IndexSnapshots <= 0 {
+		return defaultIndexSnapshots
+	}
+	return c.IndexSnapshots
+}
+
+// ListIndexSnapshots returns the kept snapshots, newest first.
+func (c *Client) ListIndexSnapshots() ([]IndexSnapshot, error) {
+	cacheDir, err := c.GetCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return listIndexSnapshots(cacheDir)
+}
+
+func listIndexSnapshots(cacheDir string) ([]IndexSnapshot, error) {
+	dir := filepath.Join(cacheDir, indexSnapshotDirName)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []IndexSnapshot
+	for _, entry := range entries {
+		created, err := time.Parse(indexSnapshotTimeFormat, entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		snapshots = append(snapshots, IndexSnapshot{ID: entry.Name(), Path: filepath.Join(dir, entry.Name()), Created: created})
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Created.After(snapshots[j].Created) })
+	return snapshots, nil
+}
+
+// snapshotIndex copies the cached indexes into a new snapshot. Indexes are
+// replaced atomically, so the copies are hard links where possible. It
+// returns nil when there is no index yet.
+func (c *Client) snapshotIndex(cacheDir string) (*IndexSnapshot, error) {
+	if _, err := os.Stat(filepath.Join(cacheDir, "formula.json.zst")); err != nil {
+		return nil, nil
+	}
+	now := time.Now().UTC()
+	snapshot := &IndexSnapshot{ID: now.Format(indexSnapshotTimeFormat), Created: now}
+	snapshot.Path = filepath.Join(cacheDir, indexSnapshotDirName, snapshot.ID)
+	if err := os.MkdirAll(snapshot.Path, 0755); err != nil {
+		return nil, err
+	}
+	for _, name := range indexSnapshotFiles {
+		if err := linkOrCopy(filepath.Join(cacheDir, name), filepath.Join(snapshot.Path, name)); err != nil && !os.IsNotExist(err) {
+			os.RemoveAll(snapshot.Path)
+			return nil, fmt.Errorf("failed to snapshot %s: %w", name, err)
+		}
+	}
+	return snapshot, nil
+}
+
+// linkOrCopy hard links src to dst, copying it when linking fails.
+func linkOrCopy(src, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// pruneIndexSnapshots removes all but the newest keep snapshots.
+func pruneIndexSnapshots(cacheDir string, keep int) {
+	snapshots, _ := listIndexSnapshots(cacheDir)
+	for i := keep; i < len(snapshots); i++ {
+		os.RemoveAll(snapshots[i].Path)
+	}
+}
+
+// restoreIndexSnapshot puts the snapshot's indexes back in the cache and
+// drops everything built from the replaced ones. The snapshot is used up.
+func (c *Client) restoreIndexSnapshot(cacheDir string, snapshot IndexSnapshot) error {
+	if err := restoreIndexFiles(cacheDir, snapshot); err != nil {
+		return err
+	}
+	c.resetIndexCaches(cacheDir)
+	return nil
+}
+
+// restoreIndexFiles moves the snapshot's files back into the cache.
+// Indexes built from the replaced ones count as stale from then on.
+func restoreIndexFiles(cacheDir string, snapshot IndexSnapshot) error {
+	if err := validateIndexFile(filepath.Join(snapshot.Path, "formula.json.zst")); err != nil {
+		return fmt.Errorf("index snapshot %s is unusable: %w", snapshot.ID, err)
+	}
+	for _, name := range indexSnapshotFiles {
+		dst := filepath.Join(cacheDir, name)
+		src := filepath.Join(snapshot.Path, name)
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			os.Remove(dst)
+			continue
+		}
+		if err := os.Rename(src, dst); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", name, err)
+		}
+	}
+	os.RemoveAll(snapshot.Path)
+
+	// Count the restored indexes as just checked, so they are not replaced
+	// by a download before the next update, and as newer than the prefix
+	// index, so it is brought in line with them.
+	now := time.Now()
+	for _, name := range []string{"formula.json.zst", "cask.json.zst"} {
+		path := filepath.Join(cacheDir, name)
+		if os.Chtimes(path, now, now) != nil {
+			continue
+		}
+		meta := loadIndexCacheMetadata(path + ".meta.json")
+		meta.CheckedAt = now
+		saveIndexCacheMetadata(path+".meta.json", meta)
+	}
+	return nil
+}
+
+// restoreLatestIndexFiles moves the newest usable snapshot back into the
+// cache, for a cached index that is corrupt and cannot be downloaded again.
+// It leaves the Client's in-memory state alone, as it runs while indexes
+// are being loaded.
+func restoreLatestIndexFiles(cacheDir string) (*IndexSnapshot, error) {
+	snapshots, err := listIndexSnapshots(cacheDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, snapshot := range snapshots {
+		if err := restoreIndexFiles(cacheDir, snapshot); err == nil {
+			return &snapshot, nil
+		}
+	}
+	return nil, kindErrorf(KindNotFound, "no usable index snapshot")
+}
+
+// RollbackIndex replaces the cached indexes with the newest snapshot, for
+// an update that brought a broken index. Each rollback goes one snapshot
+// further back. The next update downloads the current index again.
+func (c *Client) RollbackIndex() (*IndexSnapshot, error) {
+	cacheDir, err := c.GetCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := listIndexSnapshots(cacheDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) == 0 {
+		return nil, kindErrorf(KindNotFound, "no index snapshot to roll back to")
+	}
+	if err := c.restoreIndexSnapshot(cacheDir, snapshots[0]); err != nil {
+		return nil, err
+	}
+	return &snapshots[0], nil
+}
+
+// errEmptyIndex is returned by validateIndexFile for an index that parses
+// but lists nothing, as a truncated or misdirected download can.
+var errEmptyIndex = errors.New("index is empty")
+
+// validateIndexes checks freshly downloaded formula and cask indexes. The
+// cask index may be empty, as it is on Linux mirrors without casks.
+func validateIndexes(formulaPath, caskPath string) error {
+	if err := validateIndexFile(formulaPath); err != nil {
+		return fmt.Errorf("formula index: %w", err)
+	}
+	if err := validateIndexFile(caskPath); err != nil && err != errEmptyIndex {
+		return fmt.Errorf("cask index: %w", err)
+	}
+	return nil
+}
+
+// validateIndexFile checks that a cached index, compressed or not, parses
+// and lists packages that all have a name.
+func validateIndexFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if decompressed, err := decompressFile(data); err == nil {
+		data = decompressed
+	}
+	var entries []indexEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return errEmptyIndex
+	}
+	for i, e := range entries {
+		if !e.named() {
+			return fmt.Errorf("entry %d has no name", i)
+		}
+	}
+	return nil
+}
+
+// indexEntry is the part of a formula or cask index entry that validation
+// looks at. A cask's name is a list of display names, so only its token is
+// read.
+type indexEntry struct {
+	Name  json.RawMessage `json:"name"`
+	Token string          `json:"token"`
+}
+
+func (e indexEntry) named() bool {
+	if e.Token != "" {
+		return true
+	}
+	var name string
+	return json.Unmarshal(e.Name, &name) == nil && name != ""
+}
+
+// resetIndexCaches drops the indexes built from the cached ones, on disk
+// and in memory, after the cached indexes were replaced.
+func (c *Client) resetIndexCaches(cacheDir string) {
+	// The prefix index is kept: it is stale now and is updated for just
+	// the changed packages on next use.
+	os.Remove(filepath.Join(cacheDir, "search.gob.zst"))
+	os.Remove(filepath.Join(cacheDir, legacyPrefixIndexFileName))
+	os.Remove(filepath.Join(cacheDir, indexDBFileName))
+	c.resetIndexState()
+}
+
+// resetIndexState drops the indexes loaded into memory, so the next use
+// reads them from the cache again.
+func (c *Client) resetIndexState() {
+	c.resetIndexDB()
+
+	c.prefixIndexMu.Lock()
+	c.prefixIndex = nil
+	c.prefixIndexMu.Unlock()
+
+	c.indexMu.Lock()
+	c.index = nil
+	c.indexErr = nil
+	c.indexLoaded = false
+	c.indexMu.Unlock()
+
+	c.casksMu.Lock()
+	c.casks = nil
+	c.casksErr = nil
+	c.casksLoaded = false
+	c.casksMu.Unlock()
+
+	c.notifyInvalidation(EventIndexRefreshed)
+}
@@ -0,0 +1,190 @@
+package brew
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// IndexUpdateOptions tune UpdateIndex.
+type IndexUpdateOptions struct {
+	// Force downloads both indexes in full instead of asking the server
+	// whether they changed.
+	Force bool
+}
+
+// IndexUpdate reports what an index update changed.
+type IndexUpdate struct {
+	// Changed is true when either index was replaced.
+	Changed bool
+	// Added, Updated and Removed list formulae whose presence or version
+	// changed since the previous index. They are empty on the first update.
+	Added   []string
+	Updated []string
+	Removed []string
+}
+
+// FormulaeChanged returns how many formulae were added, updated or removed.
+func (u *IndexUpdate) FormulaeChanged() int {
+	return len(u.Added) + len(u.Updated) + len(u.Removed)
+}
+
+// UpdateIndex checks the formula and cask indexes for changes now,
+// regardless of their age. The requests are conditional on the cached
+// ETag and Last-Modified, so an unchanged index costs a 304 and no
+// transfer. The Homebrew API has no incremental endpoint; when the formula
+// index did change it is compared with the previous copy to report which
+// formulae changed.
+func (c *Client) UpdateIndex(opts IndexUpdateOptions) (*IndexUpdate, error) {
+	cacheDir, err := c.GetCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	fPath := filepath.Join(cacheDir, "formula.json.zst")
+	cPath := filepath.Join(cacheDir, "cask.json.zst")
+
+	// The compressed index is a few MB; keep it to compare against rather
+	// than parsing it up front when nothing may have changed.
+	previous, _ := os.ReadFile(fPath)
+	snapshot, err := c.snapshotIndex(cacheDir)
+	if err != nil {
+		c.logger().Warn("could not snapshot the index", "error", err)
+	}
+	if opts.Force {
+		os.Remove(fPath + ".meta.json")
+		os.Remove(cPath + ".meta.json")
+	}
+
+	c.println("🔄 Refreshing package index...")
+
+	var wg sync.WaitGroup
+	var fChanged, cChanged bool
+	var fErr, cErr error
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		fChanged, fErr = c.downloadAndCompress(FormulaAPI, fPath, "Formula")
+	}()
+	go func() {
+		defer wg.Done()
+		cChanged, cErr = c.downloadAndCompress(CaskAPI, cPath, "Cask")
+	}()
+	wg.Wait()
+	if !fChanged && !cChanged && snapshot != nil {
+		// Nothing was replaced, so there is nothing to roll back from.
+		os.RemoveAll(snapshot.Path)
+		snapshot = nil
+	}
+	if fErr != nil {
+		return nil, fErr
+	}
+	if cErr != nil {
+		return nil, cErr
+	}
+	if !fChanged && !cChanged {
+		return &IndexUpdate{}, nil
+	}
+	if err := validateIndexes(fPath, cPath); err != nil {
+		return nil, c.rollBackBrokenIndex(cacheDir, snapshot, err)
+	}
+	pruneIndexSnapshots(cacheDir, c.indexSnapshotsToKeep())
+
+	update := &IndexUpdate{Changed: true}
+
+	if fChanged && previous != nil {
+		before, err := formulaVersionsFromData(previous)
+		if err == nil {
+			if after, err := formulaVersionsFromFile(fPath); err == nil {
+				update.Added, update.Updated, update.Removed = diffFormulaVersions(before, after)
+			}
+		}
+	}
+
+	c.resetIndexCaches(cacheDir)
+	return update, nil
+}
+
+// rollBackBrokenIndex restores snapshot after an update downloaded an index
+// that does not parse, and returns the error to report.
+func (c *Client) rollBackBrokenIndex(cacheDir string, snapshot *IndexSnapshot, cause error) error {
+	c.logger().Warn("downloaded index is corrupt", "error", cause)
+	if snapshot == nil {
+		return fmt.Errorf("downloaded index is corrupt: %w", cause)
+	}
+	if err := c.restoreIndexSnapshot(cacheDir, *snapshot); err != nil {
+		return fmt.Errorf("downloaded index is corrupt (%v) and restoring the previous one failed: %w", cause, err)
+	}
+	c.println("↩️  Downloaded index is corrupt; restored the previous index")
+	return fmt.Errorf("downloaded index is corrupt, kept the previous index: %w", cause)
+}
+
+// RebuildIndexes builds the index database and the search and prefix
+// indexes from the cached indexes. UpdateIndex drops them when the index
+// changes; rebuilding them right after, rather than on next use, keeps the
+// first search or install after an update fast.
+func (c *Client) RebuildIndexes() error {
+	if _, err := c.OpenIndexDB(); err != nil {
+		return err
+	}
+	_, err := c.GetPrefixIndex()
+	return err
+}
+
+// formulaVersion is the part of a formula.json entry needed to tell
+// whether the formula changed.
+type formulaVersion struct {
+	Name     string          `json:"name"`
+	Versions FormulaVersions `json:"versions"`
+	Revision int             `json:"revision"`
+}
+
+func formulaVersionsFromFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return formulaVersionsFromData(data)
+}
+
+// formulaVersionsFromData maps each formula in a cached index, compressed
+// or not, to its full version.
+func formulaVersionsFromData(data []byte) (map[string]string, error) {
+	if decompressed, err := decompressFile(data); err == nil {
+		data = decompressed
+	}
+	var entries []formulaVersion
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	versions := make(map[string]string, len(entries))
+	for _, e := range entries {
+		versions[e.Name] = Formula{Versions: e.Versions, Revision: e.Revision}.FullVersion()
+	}
+	return versions, nil
+}
+
+// diffFormulaVersions compares two name-to-version maps and returns the
+// sorted names that were added, changed version, or were removed.
+func diffFormulaVersions(before, after map[string]string) (added, updated, removed []string) {
+	for name, version := range after {
+		old, ok := before[name]
+		switch {
+		case !ok:
+			added = append(added, name)
+		case old != version:
+			updated = append(updated, name)
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(updated)
+	sort.Strings(removed)
+	return added, updated, removed
+}
@@ -2,11 +2,14 @@ package brew
 
 import (
 	"bytes"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
+	"time"
 )
 
 func TestDownloadAndCompressSkipsUnchangedWithETag(t *testing.T) {
@@ -125,3 +128,238 @@ func TestSplitIndexCacheLoadsMissingSideWhenPartialInMemoryState(t *testing.T) {
 		t.Fatalf("expected formula cache to load from disk, got %+v", formulae)
 	}
 }
+
+func TestDownloadAndCompressResumesInterruptedTransfer(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	client := &Client{}
+	cacheDir, err := client.GetCacheDir()
+	if err != nil {
+		t.Fatalf("GetCacheDir failed: %v", err)
+	}
+	indexPath := filepath.Join(cacheDir, "formula.json.zst")
+
+	payload := bytes.Repeat([]byte("fastbrew-index-data-"), 500)
+	half := len(payload) / 2
+
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+			return
+		}
+		rangeHeader := r.Header.Get("Range")
+		ranges = append(ranges, rangeHeader)
+		if rangeHeader == "" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+			w.WriteHeader(http.StatusOK)
+			w.Write(payload[:half])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+
+		var start int
+		if _, err := fmt.Sscanf(rangeHeader, "bytes=%d-", &start); err != nil {
+			t.Errorf("unexpected Range header %q", rangeHeader)
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(payload)-1, len(payload)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(payload[start:])
+	}))
+	defer server.Close()
+
+	changed, err := client.downloadAndCompress(server.URL, indexPath, "Formula")
+	if err != nil {
+		t.Fatalf("downloadAndCompress failed: %v", err)
+	}
+	if !changed {
+		t.Fatalf("expected changed=true")
+	}
+	if len(ranges) != 2 || ranges[1] != fmt.Sprintf("bytes=%d-", half) {
+		t.Fatalf("expected a fresh request followed by a resumed one, got %q", ranges)
+	}
+
+	data, err := readCachedIndexData(indexPath)
+	if err != nil {
+		t.Fatalf("readCachedIndexData failed: %v", err)
+	}
+	if !bytes.Equal(data, payload) {
+		t.Fatalf("resumed index does not match payload (%d vs %d bytes)", len(data), len(payload))
+	}
+	if _, err := os.Stat(indexPath + ".download"); !os.IsNotExist(err) {
+		t.Errorf("expected partial download to be cleaned up, stat err = %v", err)
+	}
+}
+
+func TestLoadIndexJSONRedownloadsCorruptCache(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	client := &Client{}
+	cacheDir, err := client.GetCacheDir()
+	if err != nil {
+		t.Fatalf("GetCacheDir failed: %v", err)
+	}
+	indexPath := filepath.Join(cacheDir, "formula.json.zst")
+	if err := os.WriteFile(indexPath, []byte(`[{"name": "wg`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveIndexCacheMetadata(indexPath+".meta.json", indexCacheMetadata{ETag: `"v1"`}); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"name": "wget"}]`))
+	}))
+	defer server.Close()
+
+	var formulae []Formula
+	if err := client.loadIndexJSON(indexPath, server.URL, "Formula", &formulae); err != nil {
+		t.Fatalf("loadIndexJSON failed: %v", err)
+	}
+	if len(formulae) != 1 || formulae[0].Name != "wget" {
+		t.Fatalf("unexpected formulae after recovery: %+v", formulae)
+	}
+}
+
+func TestUpdateIndexReportsChangedFormulae(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	formulae := `[{"name": "wget", "versions": {"stable": "1.0"}}, {"name": "jq", "versions": {"stable": "1.7"}}]`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", strconv.Quote(strconv.Itoa(len(formulae))))
+		if r.URL.Path == "/api/cask.json" {
+			_, _ = w.Write([]byte(`[]`))
+			return
+		}
+		_, _ = w.Write([]byte(formulae))
+	}))
+	defer server.Close()
+
+	client := &Client{Out: &bytes.Buffer{}, Mirrors: map[string]string{"formulae.brew.sh": server.URL}}
+	update, err := client.UpdateIndex(IndexUpdateOptions{})
+	if err != nil {
+		t.Fatalf("first UpdateIndex failed: %v", err)
+	}
+	if !update.Changed || update.FormulaeChanged() != 0 {
+		t.Fatalf("first update should change the index without a diff: %+v", update)
+	}
+
+	formulae = `[{"name": "wget", "versions": {"stable": "1.0"}, "revision": 1}, {"name": "curl", "versions": {"stable": "8.0"}}]`
+	update, err = client.UpdateIndex(IndexUpdateOptions{})
+	if err != nil {
+		t.Fatalf("second UpdateIndex failed: %v", err)
+	}
+	if fmt.Sprint(update.Added, update.Updated, update.Removed) != "[curl] [wget] [jq]" {
+		t.Fatalf("unexpected diff: added=%v updated=%v removed=%v", update.Added, update.Updated, update.Removed)
+	}
+
+	if err := client.RebuildIndexes(); err != nil {
+		t.Fatalf("RebuildIndexes failed: %v", err)
+	}
+	cacheDir, _ := client.GetCacheDir()
+	for _, name := range []string{indexDBFileName, "search.gob.zst", prefixIndexFileName} {
+		if _, err := os.Stat(filepath.Join(cacheDir, name)); err != nil {
+			t.Errorf("%s not rebuilt: %v", name, err)
+		}
+	}
+}
+
+func TestUpdateIndexRestoresSnapshotOfCorruptDownload(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	formulae := `[{"name": "wget", "versions": {"stable": "1.0"}}]`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", strconv.Quote(strconv.Itoa(len(formulae))))
+		if r.URL.Path == "/api/cask.json" {
+			_, _ = w.Write([]byte(`[]`))
+			return
+		}
+		_, _ = w.Write([]byte(formulae))
+	}))
+	defer server.Close()
+
+	client := &Client{Out: &bytes.Buffer{}, Mirrors: map[string]string{"formulae.brew.sh": server.URL}}
+	if _, err := client.UpdateIndex(IndexUpdateOptions{}); err != nil {
+		t.Fatalf("first UpdateIndex failed: %v", err)
+	}
+	if snapshots, _ := client.ListIndexSnapshots(); len(snapshots) != 0 {
+		t.Fatalf("first update has no previous index to keep, got %d snapshot(s)", len(snapshots))
+	}
+
+	formulae = `[{"name": "wget", "versions": {"stable": "1.`
+	if _, err := client.UpdateIndex(IndexUpdateOptions{}); err == nil {
+		t.Fatal("expected an error for a truncated index")
+	}
+	cacheDir, _ := client.GetCacheDir()
+	versions, err := formulaVersionsFromFile(filepath.Join(cacheDir, "formula.json.zst"))
+	if err != nil || versions["wget"] != "1.0" {
+		t.Fatalf("previous index not restored: %v %v", versions, err)
+	}
+	if snapshots, _ := client.ListIndexSnapshots(); len(snapshots) != 0 {
+		t.Errorf("restored snapshot should be used up, %d left", len(snapshots))
+	}
+}
+
+func TestRollbackIndex(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	version := 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", strconv.Quote(strconv.Itoa(version)))
+		if r.URL.Path == "/api/cask.json" {
+			_, _ = w.Write([]byte(`[]`))
+			return
+		}
+		fmt.Fprintf(w, `[{"name": "wget", "versions": {"stable": "1.%d"}}]`, version)
+	}))
+	defer server.Close()
+
+	client := &Client{Out: &bytes.Buffer{}, IndexSnapshots: 2, Mirrors: map[string]string{"formulae.brew.sh": server.URL}}
+	for ; version <= 4; version++ {
+		if _, err := client.UpdateIndex(IndexUpdateOptions{}); err != nil {
+			t.Fatalf("UpdateIndex %d failed: %v", version, err)
+		}
+	}
+	snapshots, err := client.ListIndexSnapshots()
+	if err != nil || len(snapshots) != 2 {
+		t.Fatalf("expected 2 kept snapshots, got %d (%v)", len(snapshots), err)
+	}
+
+	cacheDir, _ := client.GetCacheDir()
+	for _, want := range []string{"1.3", "1.2"} {
+		if _, err := client.RollbackIndex(); err != nil {
+			t.Fatalf("RollbackIndex failed: %v", err)
+		}
+		versions, _ := formulaVersionsFromFile(filepath.Join(cacheDir, "formula.json.zst"))
+		if versions["wget"] != want {
+			t.Errorf("after rollback wget = %q, want %s", versions["wget"], want)
+		}
+	}
+	if _, err := client.RollbackIndex(); KindOf(err) != KindNotFound {
+		t.Errorf("expected not-found once snapshots run out, got %v", err)
+	}
+}
+
+func TestShouldUpdateUsesLastCheck(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "formula.json.zst")
+	if err := os.WriteFile(path, []byte(`[]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	os.Chtimes(path, old, old)
+	if !shouldUpdate(path) {
+		t.Fatal("an old index without metadata should be checked")
+	}
+
+	if err := saveIndexCacheMetadata(path+".meta.json", indexCacheMetadata{CheckedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if shouldUpdate(path) {
+		t.Fatal("an index confirmed by a recent 304 should not be checked again")
+	}
+}
@@ -0,0 +1,118 @@
+package brew
+
+import (
+	"encoding/json"
+	"fastbrew/internal/atomicfile"
+	"fastbrew/internal/paths"
+	"os"
+	"path/filepath"
+)
+
+// InstallReason records why a formula is installed.
+type InstallReason string
+
+const (
+	// ReasonExplicit formulae were named on the command line.
+	ReasonExplicit InstallReason = "explicit"
+	// ReasonDependency formulae were pulled in by another formula, and may
+	// be removed by autoremove once nothing needs them.
+	ReasonDependency InstallReason = "dependency"
+)
+
+// InstallReasonsPath returns the file mapping installed formulae to their
+// install reason.
+func InstallReasonsPath() string {
+	return filepath.Join(paths.StateDir(), "install_reasons.json")
+}
+
+// LoadInstallReasons returns the recorded install reasons. A missing file
+// means none are recorded.
+func LoadInstallReasons() (map[string]InstallReason, error) {
+	reasons := make(map[string]InstallReason)
+	data, err := os.ReadFile(InstallReasonsPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return reasons, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &reasons); err != nil {
+		return nil, err
+	}
+	return reasons, nil
+}
+
+// SaveInstallReasons replaces the recorded install reasons.
+func SaveInstallReasons(reasons map[string]InstallReason) error {
+	path := InstallReasonsPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(reasons, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, data, 0644)
+}
+
+// RecordInstallReasons merges updates into the recorded reasons. A formula
+// once installed explicitly stays explicit when it is later pulled in as a
+// dependency.
+func RecordInstallReasons(updates map[string]InstallReason) error {
+	if len(updates) == 0 {
+		return nil
+	}
+	reasons, err := LoadInstallReasons()
+	if err != nil {
+		return err
+	}
+	for name, reason := range updates {
+		if reason == ReasonDependency && reasons[name] == ReasonExplicit {
+			continue
+		}
+		reasons[name] = reason
+	}
+	return SaveInstallReasons(reasons)
+}
+
+// ForgetInstallReasons drops uninstalled formulae from the recorded
+// reasons, so a later reinstall starts afresh.
+func ForgetInstallReasons(names ...string) error {
+	reasons, err := LoadInstallReasons()
+	if err != nil {
+		return err
+	}
+	changed := false
+	for _, name := range names {
+		if _, ok := reasons[name]; ok {
+			delete(reasons, name)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return SaveInstallReasons(reasons)
+}
+
+// InstallReason returns why name is installed: the recorded reason, else
+// the one in its INSTALL_RECEIPT.json, which covers kegs poured by
+// Homebrew. Formulae with neither count as explicit, so nothing is removed
+// on a guess.
+func (c *Client) InstallReason(name string) InstallReason {
+	reasons, err := LoadInstallReasons()
+	if err != nil {
+		reasons = nil
+	}
+	return c.installReason(reasons, name)
+}
+
+func (c *Client) installReason(reasons map[string]InstallReason, name string) InstallReason {
+	if reason, ok := reasons[name]; ok {
+		return reason
+	}
+	if c.receiptSaysDependency(name) {
+		return ReasonDependency
+	}
+	return ReasonExplicit
+}
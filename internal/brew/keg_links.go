@@ -0,0 +1,111 @@
+package brew
+
+import (
+	"encoding/json"
+	"fastbrew/internal/atomicfile"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// kegLinksName is the file, beside a keg's manifest, that records the
+// symlinks Link created in the prefix for it.
+const kegLinksName = ".fastbrew-links.json"
+
+// KegLink is one symlink in the prefix pointing into a keg. Path is
+// relative to the prefix; Target is where the link points.
+type KegLink struct {
+	Path   string `json:"path"`
+	Target string `json:"target"`
+}
+
+// KegLinks lists the links of a linked keg.
+type KegLinks struct {
+	Links []KegLink `json:"links"`
+}
+
+func (l *KegLinks) write(kegDir string) error {
+	sort.Slice(l.Links, func(i, j int) bool { return l.Links[i].Path < l.Links[j].Path })
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal keg links: %w", err)
+	}
+	if err := atomicfile.WriteFile(filepath.Join(kegDir, kegLinksName), data, 0644); err != nil {
+		return fmt.Errorf("failed to write keg links: %w", err)
+	}
+	return nil
+}
+
+// LoadKegLinks reads the links recorded for a keg. It returns
+// os.ErrNotExist for kegs that are not linked, or were linked before links
+// were recorded.
+func LoadKegLinks(kegDir string) (*KegLinks, error) {
+	data, err := os.ReadFile(filepath.Join(kegDir, kegLinksName))
+	if err != nil {
+		return nil, err
+	}
+	var l KegLinks
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, fmt.Errorf("invalid keg links: %w", err)
+	}
+	return &l, nil
+}
+
+// removeKegLinks removes the links recorded for kegDir and then the record.
+// A link is only removed while it still points where it did, so one taken
+// over by another formula since is left alone.
+func (c *Client) removeKegLinks(kegDir string, l *KegLinks) {
+	for _, link := range l.Links {
+		path := filepath.Join(c.Prefix, filepath.FromSlash(link.Path))
+		if target, err := os.Readlink(path); err == nil && target == link.Target {
+			os.Remove(path)
+		}
+	}
+	os.Remove(filepath.Join(kegDir, kegLinksName))
+}
+
+// LinkedFile is a recorded link together with its current state.
+type LinkedFile struct {
+	KegLink
+	Version string `json:"version"`
+	// Status is "ok", "missing" when the link is gone, or "changed" when
+	// it now points elsewhere.
+	Status string `json:"status"`
+}
+
+// Links returns the links recorded for every installed version of name,
+// sorted by path. It returns os.ErrNotExist when none of them has any.
+func (c *Client) Links(name string) ([]LinkedFile, error) {
+	versions := kegVersions(filepath.Join(c.Cellar, name))
+	if len(versions) == 0 {
+		return nil, kindErrorf(KindNotFound, "%s is not installed", name)
+	}
+
+	var files []LinkedFile
+	recorded := false
+	for _, version := range versions {
+		l, err := LoadKegLinks(filepath.Join(c.Cellar, name, version))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		recorded = true
+		for _, link := range l.Links {
+			status := "ok"
+			if target, err := os.Readlink(filepath.Join(c.Prefix, filepath.FromSlash(link.Path))); err != nil {
+				status = "missing"
+			} else if target != link.Target {
+				status = "changed"
+			}
+			files = append(files, LinkedFile{KegLink: link, Version: version, Status: status})
+		}
+	}
+	if !recorded {
+		return nil, os.ErrNotExist
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
@@ -0,0 +1,184 @@
+package brew
+
+import (
+	"encoding/json"
+	"errors"
+	"fastbrew/internal/atomicfile"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// kegManifestName is the file, beside a keg's INSTALL_RECEIPT.json, that
+// records what was extracted into it.
+const kegManifestName = ".fastbrew-manifest.json"
+
+// mutableKegFiles are rewritten by Homebrew after installation, so only
+// their presence is checked.
+var mutableKegFiles = map[string]bool{"INSTALL_RECEIPT.json": true}
+
+// KegFile is one file, symlink or hard link in a keg. Path is relative to
+// the keg; Link is set for symlinks, Size and SHA256 for everything else.
+type KegFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+	Link   string `json:"link,omitempty"`
+}
+
+// KegManifest lists the contents of a keg as extracted from its bottle.
+type KegManifest struct {
+	Files []KegFile `json:"files"`
+}
+
+// newKegManifest builds the manifest of kegDir from the entries extractBottle
+// wrote under extractDir. Entries outside the keg are dropped, and an entry
+// the archive wrote twice keeps only its last version.
+func newKegManifest(extractDir, kegDir string, written []KegFile) *KegManifest {
+	byPath := make(map[string]KegFile, len(written))
+	for _, file := range written {
+		rel, err := filepath.Rel(kegDir, filepath.Join(extractDir, file.Path))
+		if err != nil || rel == "." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+			continue
+		}
+		file.Path = filepath.ToSlash(rel)
+		byPath[file.Path] = file
+	}
+
+	m := &KegManifest{Files: make([]KegFile, 0, len(byPath))}
+	for _, file := range byPath {
+		m.Files = append(m.Files, file)
+	}
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+	return m
+}
+
+func (m *KegManifest) write(kegDir string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal keg manifest: %w", err)
+	}
+	if err := atomicfile.WriteFile(filepath.Join(kegDir, kegManifestName), data, 0644); err != nil {
+		return fmt.Errorf("failed to write keg manifest: %w", err)
+	}
+	return nil
+}
+
+// LoadKegManifest reads the manifest of an installed keg. It returns
+// os.ErrNotExist for kegs installed before manifests were recorded, or by
+// another tool.
+func LoadKegManifest(kegDir string) (*KegManifest, error) {
+	data, err := os.ReadFile(filepath.Join(kegDir, kegManifestName))
+	if err != nil {
+		return nil, err
+	}
+	var m KegManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid keg manifest: %w", err)
+	}
+	return &m, nil
+}
+
+// VerifyKeg checks an installed keg against its manifest, including file
+// hashes. Files added to the keg since installation are not reported. It
+// returns os.ErrNotExist when the keg has no manifest.
+func (c *Client) VerifyKeg(name, version string) error {
+	kegDir := filepath.Join(c.Cellar, name, version)
+	m, err := LoadKegManifest(kegDir)
+	if err != nil {
+		return err
+	}
+	return kegProblems(verifyKeg(kegDir, m, true))
+}
+
+// verifyExtractedKeg checks a freshly extracted keg against the archive:
+// everything in m is present with its recorded size, and nothing else is.
+// Hashes were computed while writing, so they are not checked again.
+func verifyExtractedKeg(kegDir string, m *KegManifest) error {
+	problems := verifyKeg(kegDir, m, false)
+	expected := make(map[string]bool, len(m.Files))
+	for _, file := range m.Files {
+		expected[file.Path] = true
+	}
+	filepath.WalkDir(kegDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(kegDir, path)
+		if rel = filepath.ToSlash(rel); !expected[rel] {
+			problems = append(problems, fmt.Sprintf("%s: not in bottle", rel))
+		}
+		return nil
+	})
+	return kegProblems(problems)
+}
+
+// verifyKeg checks every entry of m in kegDir: files with their recorded
+// size (and hash, when checkHashes is set), symlinks pointing where they
+// did, and executables in bin and sbin still executable.
+func verifyKeg(kegDir string, m *KegManifest, checkHashes bool) []string {
+	var problems []string
+	for _, file := range m.Files {
+		if problem := verifyKegFile(kegDir, file, checkHashes); problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+	return problems
+}
+
+func kegProblems(problems []string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	if len(problems) > 5 {
+		problems = append(problems[:5], fmt.Sprintf("and %d more", len(problems)-5))
+	}
+	return errors.New(strings.Join(problems, "; "))
+}
+
+func verifyKegFile(kegDir string, file KegFile, checkHashes bool) string {
+	path := filepath.Join(kegDir, filepath.FromSlash(file.Path))
+	info, err := os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Sprintf("%s: missing", file.Path)
+		}
+		return fmt.Sprintf("%s: %v", file.Path, err)
+	}
+
+	if file.Link != "" {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return fmt.Sprintf("%s: expected a symlink", file.Path)
+		}
+		if target != file.Link {
+			return fmt.Sprintf("%s: links to %s, expected %s", file.Path, target, file.Link)
+		}
+		return ""
+	}
+
+	if !info.Mode().IsRegular() {
+		return fmt.Sprintf("%s: expected a regular file", file.Path)
+	}
+	if mutableKegFiles[file.Path] {
+		return ""
+	}
+	if info.Size() != file.Size {
+		return fmt.Sprintf("%s: size %d, expected %d", file.Path, info.Size(), file.Size)
+	}
+	if dir, _, _ := strings.Cut(file.Path, "/"); (dir == "bin" || dir == "sbin") && info.Mode()&0111 == 0 {
+		return fmt.Sprintf("%s: not executable", file.Path)
+	}
+	if checkHashes && file.SHA256 != "" {
+		sum, err := fileSHA256(path)
+		if err != nil {
+			return fmt.Sprintf("%s: %v", file.Path, err)
+		}
+		if sum != file.SHA256 {
+			return fmt.Sprintf("%s: content changed", file.Path)
+		}
+	}
+	return ""
+}
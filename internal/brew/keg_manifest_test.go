@@ -0,0 +1,103 @@
+package brew
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExtractRecordsKegManifest(t *testing.T) {
+	client, _ := newCleanupTestClient(t)
+	client.Out = &strings.Builder{}
+	path := writeTestBottle(t, t.TempDir(), "fbtestpkg--1.0.x86_64_linux.bottle.tar.gz", "fbtestpkg", "1.0")
+	sha, err := fileSHA256(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.InstallLocalBottles([]string{path}, LocalBottleOptions{SHA256: sha}); err != nil {
+		t.Fatalf("InstallLocalBottles failed: %v", err)
+	}
+
+	kegDir := filepath.Join(client.Cellar, "fbtestpkg", "1.0")
+	m, err := LoadKegManifest(kegDir)
+	if err != nil {
+		t.Fatalf("manifest not written: %v", err)
+	}
+	if len(m.Files) != 1 || m.Files[0].Path != "bin/fbtestpkg" || m.Files[0].SHA256 == "" {
+		t.Fatalf("unexpected manifest: %+v", m.Files)
+	}
+	if r, err := readKegReceipt(kegDir); err != nil || !r.InstalledOnRequest || r.Time == 0 {
+		t.Fatalf("receipt not written: %+v, %v", r, err)
+	}
+	if err := client.VerifyKeg("fbtestpkg", "1.0"); err != nil {
+		t.Fatalf("fresh keg should verify: %v", err)
+	}
+
+	// Files added later are fine; changed ones are not.
+	writeKegFile(t, filepath.Join(kegDir, "share", "extra"), "added")
+	if err := client.VerifyKeg("fbtestpkg", "1.0"); err != nil {
+		t.Fatalf("added files should not fail verification: %v", err)
+	}
+	bin := filepath.Join(kegDir, "bin", "fbtestpkg")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\necho FBTESTPKG\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.VerifyKeg("fbtestpkg", "1.0"); err == nil || !strings.Contains(err.Error(), "content changed") {
+		t.Fatalf("expected a content change, got %v", err)
+	}
+	os.Chmod(bin, 0644)
+	if err := client.VerifyKeg("fbtestpkg", "1.0"); err == nil || !strings.Contains(err.Error(), "not executable") {
+		t.Fatalf("expected a permission problem, got %v", err)
+	}
+	os.Remove(bin)
+	if err := client.VerifyKeg("fbtestpkg", "1.0"); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Fatalf("expected a missing file, got %v", err)
+	}
+
+	makeKeg(t, client, "fbother", "2.0")
+	if err := client.VerifyKeg("fbother", "2.0"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("keg without a manifest: got %v, want ErrNotExist", err)
+	}
+}
+
+func TestVerifyExtractedKeg(t *testing.T) {
+	extractDir := t.TempDir()
+	kegDir := filepath.Join(extractDir, "fbtestpkg", "1.0")
+	writeKegFile(t, filepath.Join(kegDir, "bin", "tool"), "tool")
+	writeKegFile(t, filepath.Join(kegDir, "lib", "libtool.so.1"), "library")
+	if err := os.Symlink("libtool.so.1", filepath.Join(kegDir, "lib", "libtool.so")); err != nil {
+		t.Fatal(err)
+	}
+	os.Chmod(filepath.Join(kegDir, "bin", "tool"), 0755)
+
+	written := []KegFile{
+		{Path: "fbtestpkg/1.0/bin/tool", Size: 4, SHA256: "x"},
+		{Path: "fbtestpkg/1.0/lib/libtool.so.1", Size: 1, SHA256: "stale"},
+		{Path: "fbtestpkg/1.0/lib/libtool.so.1", Size: 7, SHA256: "y"},
+		{Path: "fbtestpkg/1.0/lib/libtool.so", Link: "libtool.so.1"},
+	}
+	m := newKegManifest(extractDir, kegDir, written)
+	if len(m.Files) != 3 || m.Files[0].Path != "bin/tool" {
+		t.Fatalf("unexpected manifest: %+v", m.Files)
+	}
+	if err := verifyExtractedKeg(kegDir, m); err != nil {
+		t.Fatalf("keg should match the archive: %v", err)
+	}
+
+	writeKegFile(t, filepath.Join(kegDir, "lib", "stray.a"), "stray")
+	if err := verifyExtractedKeg(kegDir, m); err == nil || !strings.Contains(err.Error(), "lib/stray.a: not in bottle") {
+		t.Fatalf("expected the stray file to be reported, got %v", err)
+	}
+}
+
+func writeKegFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,153 @@
+package brew
+
+import (
+	"encoding/json"
+	"fastbrew/internal/atomicfile"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// kegSizeCacheName is the file in the cache directory that remembers keg
+// sizes between runs, so listing does not walk every keg each time.
+const kegSizeCacheName = "keg_sizes.json"
+
+// InstalledPackage is an installed formula or cask with every version
+// present on disk and their combined size.
+type InstalledPackage struct {
+	Name string
+	// Versions lists the installed versions, oldest first. The last one is
+	// the version ListInstalledNative reports.
+	Versions []string
+	// LinkedVersion is the version opt/<name> points at, or "" for casks
+	// and unlinked formulae.
+	LinkedVersion         string
+	IsCask                bool
+	InstalledAsDependency bool
+	// Size is the total size in bytes of all installed versions.
+	Size int64
+}
+
+// Version returns the newest installed version.
+func (p InstalledPackage) Version() string {
+	if len(p.Versions) == 0 {
+		return ""
+	}
+	return p.Versions[len(p.Versions)-1]
+}
+
+// kegSizeEntry is a cached size, valid while the directory's modification
+// time is unchanged. Kegs are not modified after installation, so the top
+// level modification time is enough to notice a reinstall.
+type kegSizeEntry struct {
+	ModTime int64 `json:"mtime"`
+	Size    int64 `json:"size"`
+}
+
+// DescribeInstalled adds every installed version and its size on disk to
+// packages as listed by ListInstalledNative (or the daemon). Sizes are
+// cached in the cache directory and only walked again for kegs that
+// changed.
+func (c *Client) DescribeInstalled(packages []PackageInfo) []InstalledPackage {
+	cachePath := ""
+	if cacheDir, err := c.GetCacheDir(); err == nil {
+		cachePath = filepath.Join(cacheDir, kegSizeCacheName)
+	}
+	cached := loadKegSizes(cachePath)
+	fresh := make(map[string]kegSizeEntry)
+	var mu sync.Mutex
+
+	described := make([]InstalledPackage, len(packages))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, c.getMaxParallel())
+	for i, pkg := range packages {
+		root := filepath.Join(c.Cellar, pkg.Name)
+		if pkg.IsCask {
+			root = filepath.Join(c.Prefix, "Caskroom", pkg.Name)
+		}
+		versions := kegVersions(root)
+		sort.Slice(versions, func(a, b int) bool {
+			return versionCompare(versions[a], versions[b]) < 0
+		})
+		if len(versions) == 0 {
+			versions = []string{pkg.Version}
+		}
+		described[i] = InstalledPackage{
+			Name:                  pkg.Name,
+			Versions:              versions,
+			IsCask:                pkg.IsCask,
+			InstalledAsDependency: pkg.InstalledAsDependency,
+		}
+		if !pkg.IsCask {
+			described[i].LinkedVersion = c.linkedVersion(pkg.Name)
+		}
+
+		wg.Add(1)
+		go func(i int, root string, versions []string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			var total int64
+			for _, version := range versions {
+				dir := filepath.Join(root, version)
+				info, err := os.Stat(dir)
+				if err != nil {
+					continue
+				}
+				entry, ok := cached[dir]
+				if !ok || entry.ModTime != info.ModTime().UnixNano() {
+					entry = kegSizeEntry{ModTime: info.ModTime().UnixNano(), Size: dirSize(dir)}
+				}
+				mu.Lock()
+				fresh[dir] = entry
+				mu.Unlock()
+				total += entry.Size
+			}
+			described[i].Size = total
+		}(i, root, versions)
+	}
+	wg.Wait()
+
+	// Only the kegs seen now are kept, so removed ones drop out.
+	if cachePath != "" && !sameKegSizes(cached, fresh) {
+		if err := saveKegSizes(cachePath, fresh); err != nil {
+			c.logger().Warn("failed to save keg size cache", "path", cachePath, "error", err)
+		}
+	}
+	return described
+}
+
+func loadKegSizes(path string) map[string]kegSizeEntry {
+	sizes := make(map[string]kegSizeEntry)
+	if path == "" {
+		return sizes
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return sizes
+	}
+	// A corrupt cache is rebuilt from scratch.
+	_ = json.Unmarshal(data, &sizes)
+	return sizes
+}
+
+func saveKegSizes(path string, sizes map[string]kegSizeEntry) error {
+	data, err := json.Marshal(sizes)
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, data, 0644)
+}
+
+func sameKegSizes(a, b map[string]kegSizeEntry) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package brew
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDescribeInstalledListsVersionsAndSizes(t *testing.T) {
+	client, cacheDir := newCleanupTestClient(t)
+	makeKeg(t, client, "jq", "1.10")
+	makeKeg(t, client, "jq", "1.9")
+	caskVersion := filepath.Join(client.Prefix, "Caskroom", "firefox", "120.0")
+	if err := os.MkdirAll(caskVersion, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(caskVersion, "Firefox.app"), make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	described := client.DescribeInstalled([]PackageInfo{
+		{Name: "jq", Version: "1.10"},
+		{Name: "firefox", Version: "120.0", IsCask: true},
+	})
+
+	jq := described[0]
+	if len(jq.Versions) != 2 || jq.Versions[0] != "1.9" || jq.Version() != "1.10" {
+		t.Errorf("Expected jq versions [1.9 1.10], got %v", jq.Versions)
+	}
+	if want := int64(2 * len("#!/bin/sh\n")); jq.Size != want {
+		t.Errorf("Expected jq size %d, got %d", want, jq.Size)
+	}
+	if firefox := described[1]; !firefox.IsCask || firefox.Size != 100 {
+		t.Errorf("Expected 100 byte cask, got %+v", firefox)
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, kegSizeCacheName)); err != nil {
+		t.Errorf("Expected keg size cache to be written: %v", err)
+	}
+}
+
+func TestDescribeInstalledUsesCachedSizes(t *testing.T) {
+	client, cacheDir := newCleanupTestClient(t)
+	keg := makeKeg(t, client, "jq", "1.7")
+	info, err := os.Stat(keg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cachePath := filepath.Join(cacheDir, kegSizeCacheName)
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveKegSizes(cachePath, map[string]kegSizeEntry{
+		keg:                    {ModTime: info.ModTime().UnixNano(), Size: 4096},
+		"/gone/Cellar/old/1.0": {ModTime: 1, Size: 1},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	described := client.DescribeInstalled([]PackageInfo{{Name: "jq", Version: "1.7"}})
+	if described[0].Size != 4096 {
+		t.Errorf("Expected cached size 4096, got %d", described[0].Size)
+	}
+	if cached := loadKegSizes(cachePath); len(cached) != 1 {
+		t.Errorf("Expected removed kegs to be dropped from the cache, got %v", cached)
+	}
+
+	// A keg whose modification time changed is measured again.
+	if err := os.WriteFile(filepath.Join(keg, "README"), []byte("hi"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	described = client.DescribeInstalled([]PackageInfo{{Name: "jq", Version: "1.7"}})
+	if want := int64(len("#!/bin/sh\n") + 2); described[0].Size != want {
+		t.Errorf("Expected remeasured size %d, got %d", want, described[0].Size)
+	}
+}
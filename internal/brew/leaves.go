@@ -0,0 +1,183 @@
+package brew
+
+import (
+	"fmt"
+	"path"
+	"sort"
+)
+
+// MissingDeps is an installed formula with runtime dependencies that are
+// not installed.
+type MissingDeps struct {
+	Formula string   `json:"formula"`
+	Missing []string `json:"missing"`
+}
+
+// Leaves returns the installed formulae that no other installed formula
+// depends on, sorted. These are the packages that were asked for rather
+// than pulled in.
+func (c *Client) Leaves() ([]string, error) {
+	installed, err := c.ListInstalledNative()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list installed packages: %w", err)
+	}
+	return c.LeavesOf(installed)
+}
+
+// LeavesOf is Leaves for an already listed set of installed packages.
+func (c *Client) LeavesOf(installed []PackageInfo) ([]string, error) {
+	formulae := installedFormulae(installed)
+	if len(formulae) == 0 {
+		return nil, nil
+	}
+	directDeps, err := c.directDepsLookup()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load index for dependency resolution: %w", err)
+	}
+	return leavesOf(formulae, directDeps), nil
+}
+
+// Orphans returns the installed formulae that were pulled in as
+// dependencies and are no longer needed, directly or through other
+// formulae, by anything installed explicitly. Explicitly installed
+// formulae are never orphans, whether or not something depends on them.
+func (c *Client) Orphans() ([]string, error) {
+	installed, err := c.ListInstalledNative()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list installed packages: %w", err)
+	}
+	formulae := installedFormulae(installed)
+	if len(formulae) == 0 {
+		return nil, nil
+	}
+	directDeps, err := c.directDepsLookup()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load index for dependency resolution: %w", err)
+	}
+	explicit := make(map[string]bool)
+	for _, pkg := range installed {
+		if !pkg.IsCask && !pkg.InstalledAsDependency {
+			explicit[pkg.Name] = true
+		}
+	}
+	return orphansOf(formulae, explicit, directDeps), nil
+}
+
+// Missing reports installed formulae whose runtime dependencies are not
+// installed, according to the cached index. With names, only those
+// formulae are checked. Formulae unknown to the index are skipped.
+func (c *Client) Missing(names ...string) ([]MissingDeps, error) {
+	installed, err := c.ListInstalledNative()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list installed packages: %w", err)
+	}
+	formulae := installedFormulae(installed)
+	check := formulae
+	if len(names) > 0 {
+		check = names
+	}
+	if len(check) == 0 {
+		return nil, nil
+	}
+	directDeps, err := c.directDepsLookup()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load index for dependency resolution: %w", err)
+	}
+	return missingOf(check, formulae, directDeps), nil
+}
+
+func installedFormulae(installed []PackageInfo) []string {
+	var names []string
+	for _, pkg := range installed {
+		if !pkg.IsCask {
+			names = append(names, pkg.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func leavesOf(formulae []string, directDeps func(string) ([]string, bool)) []string {
+	isDependency := make(map[string]bool)
+	for _, name := range formulae {
+		deps, _ := directDeps(name)
+		for _, dep := range deps {
+			isDependency[depName(dep)] = true
+		}
+	}
+
+	var leaves []string
+	for _, name := range formulae {
+		if !isDependency[name] {
+			leaves = append(leaves, name)
+		}
+	}
+	return leaves
+}
+
+func orphansOf(formulae []string, explicit map[string]bool, directDeps func(string) ([]string, bool)) []string {
+	installed := make(map[string]bool, len(formulae))
+	for _, name := range formulae {
+		installed[name] = true
+	}
+
+	needed := make(map[string]bool)
+	var markNeeded func(name string)
+	markNeeded = func(name string) {
+		if needed[name] {
+			return
+		}
+		needed[name] = true
+		deps, _ := directDeps(name)
+		for _, dep := range deps {
+			if dep = depName(dep); installed[dep] {
+				markNeeded(dep)
+			}
+		}
+	}
+	for name := range explicit {
+		if installed[name] {
+			markNeeded(name)
+		}
+	}
+
+	var orphans []string
+	for _, name := range formulae {
+		if !needed[name] {
+			orphans = append(orphans, name)
+		}
+	}
+	return orphans
+}
+
+func missingOf(check, formulae []string, directDeps func(string) ([]string, bool)) []MissingDeps {
+	installed := make(map[string]bool, len(formulae))
+	for _, name := range formulae {
+		installed[name] = true
+	}
+
+	var out []MissingDeps
+	for _, name := range check {
+		deps, ok := directDeps(name)
+		if !ok {
+			continue
+		}
+		var missing []string
+		for _, dep := range deps {
+			if !installed[depName(dep)] {
+				missing = append(missing, dep)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			out = append(out, MissingDeps{Formula: name, Missing: missing})
+		}
+	}
+	return out
+}
+
+// depName strips the tap from a dependency such as "user/repo/name", since
+// kegs in the Cellar are named by formula alone.
+func depName(dep string) string {
+	return path.Base(dep)
+}
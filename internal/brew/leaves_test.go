@@ -0,0 +1,76 @@
+package brew
+
+import (
+	"reflect"
+	"testing"
+)
+
+func depsLookup(graph map[string][]string) func(string) ([]string, bool) {
+	return func(name string) ([]string, bool) {
+		deps, ok := graph[name]
+		return deps, ok
+	}
+}
+
+func TestLeavesAndOrphans(t *testing.T) {
+	graph := depsLookup(map[string][]string{
+		"wget":         {"openssl@3", "libidn2"},
+		"libidn2":      {"libunistring"},
+		"pcre2":        {},
+		"libunistring": {},
+		"openssl@3":    {"ca-certificates"},
+		"xz":           {},
+	})
+	installed := []string{"libidn2", "libunistring", "openssl@3", "pcre2", "wget", "xz"}
+
+	if got, want := leavesOf(installed, graph), []string{"pcre2", "wget", "xz"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("leavesOf = %v, want %v", got, want)
+	}
+
+	explicit := map[string]bool{"pcre2": true, "wget": true, "xz": true}
+	if got := orphansOf(installed, explicit, graph); got != nil {
+		t.Errorf("orphansOf = %v, want none", got)
+	}
+
+	// Leaves installed as dependencies are orphans; an explicit install
+	// is kept, and keeps its dependencies, even when it is a dependency.
+	explicit = map[string]bool{"libidn2": true}
+	want := []string{"openssl@3", "pcre2", "wget", "xz"}
+	if got := orphansOf(installed, explicit, graph); !reflect.DeepEqual(got, want) {
+		t.Errorf("orphansOf = %v, want %v", got, want)
+	}
+
+	// A cycle between leftover dependencies must not count as needed.
+	cyclic := depsLookup(map[string][]string{
+		"jq":        {},
+		"gettext":   {"libintl"},
+		"libintl":   {"gettext"},
+		"oniguruma": {},
+	})
+	explicit = map[string]bool{"jq": true}
+	if got, want := orphansOf([]string{"gettext", "jq", "libintl"}, explicit, cyclic), []string{"gettext", "libintl"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("orphansOf with cycle = %v, want %v", got, want)
+	}
+}
+
+func TestMissingOf(t *testing.T) {
+	graph := depsLookup(map[string][]string{
+		"wget":    {"openssl@3", "libidn2", "homebrew/core/gettext"},
+		"libidn2": {"libunistring"},
+		"jq":      {"oniguruma"},
+	})
+	installed := []string{"gettext", "jq", "libidn2", "local-only", "wget"}
+
+	want := []MissingDeps{
+		{Formula: "jq", Missing: []string{"oniguruma"}},
+		{Formula: "libidn2", Missing: []string{"libunistring"}},
+		{Formula: "wget", Missing: []string{"openssl@3"}},
+	}
+	if got := missingOf(installed, installed, graph); !reflect.DeepEqual(got, want) {
+		t.Errorf("missingOf = %+v, want %+v", got, want)
+	}
+
+	if got := missingOf([]string{"wget"}, installed, graph); !reflect.DeepEqual(got, want[2:]) {
+		t.Errorf("missingOf(wget) = %+v, want %+v", got, want[2:])
+	}
+}
@@ -0,0 +1,198 @@
+package brew
+
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+// LicenseUnknown stands in for the license of formulae the index has no
+// license for, such as tap formulae.
+const LicenseUnknown = "unknown"
+
+// PackageLicense is the license of one formula, as the SPDX-style
+// expression formula.json carries: "MIT", "Apache-2.0 or MIT",
+// "GPL-2.0-only with Classpath-exception-2.0".
+type PackageLicense struct {
+	Name    string `json:"name"`
+	License string `json:"license"`
+	// Denied is set when every way of meeting License uses a denied
+	// license; see LicenseDenied.
+	Denied bool `json:"denied,omitempty"`
+}
+
+// licenseNode is a parsed license expression: a license identifier, or
+// the "or"/"and" of its children.
+type licenseNode struct {
+	op       string
+	id       string
+	children []*licenseNode
+}
+
+// parseLicense parses a license expression. Operators are matched without
+// regard to case, "and" binds tighter than "or", and "with" exceptions are
+// dropped as they only widen the license they follow.
+func parseLicense(expr string) *licenseNode {
+	expr = strings.NewReplacer("(", " ( ", ")", " ) ").Replace(expr)
+	p := &licenseParser{tokens: strings.Fields(expr)}
+	return p.parseOr()
+}
+
+type licenseParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *licenseParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return strings.ToLower(p.tokens[p.pos])
+	}
+	return ""
+}
+
+func (p *licenseParser) parseOr() *licenseNode {
+	return p.parseList("or", p.parseAnd)
+}
+
+func (p *licenseParser) parseAnd() *licenseNode {
+	return p.parseList("and", p.parseTerm)
+}
+
+func (p *licenseParser) parseList(op string, next func() *licenseNode) *licenseNode {
+	node := next()
+	if p.peek() != op {
+		return node
+	}
+	list := &licenseNode{op: op, children: []*licenseNode{node}}
+	for p.peek() == op {
+		p.pos++
+		list.children = append(list.children, next())
+	}
+	return list
+}
+
+func (p *licenseParser) parseTerm() *licenseNode {
+	var node *licenseNode
+	switch p.peek() {
+	case "":
+		return &licenseNode{}
+	case "(":
+		p.pos++
+		node = p.parseOr()
+		if p.peek() == ")" {
+			p.pos++
+		}
+	default:
+		node = &licenseNode{id: p.tokens[p.pos]}
+		p.pos++
+	}
+	if p.peek() == "with" {
+		p.pos += 2
+	}
+	return node
+}
+
+// denied reports whether n cannot be met without a denied license.
+func (n *licenseNode) denied(denied []string) bool {
+	switch n.op {
+	case "or":
+		for _, child := range n.children {
+			if !child.denied(denied) {
+				return false
+			}
+		}
+		return true
+	case "and":
+		return slices.ContainsFunc(n.children, func(child *licenseNode) bool { return child.denied(denied) })
+	}
+	return n.id != "" && slices.ContainsFunc(denied, func(d string) bool { return licenseIDMatches(n.id, d) })
+}
+
+// licenseIDMatches reports whether the license id is the denied one. A
+// denied id without a -only or -or-later suffix covers both, so
+// "GPL-3.0" denies "GPL-3.0-only" and "GPL-3.0-or-later".
+func licenseIDMatches(id, denied string) bool {
+	if strings.EqualFold(id, denied) {
+		return true
+	}
+	base := id
+	for _, suffix := range []string{"-only", "-or-later", "+"} {
+		if len(base) > len(suffix) && strings.EqualFold(base[len(base)-len(suffix):], suffix) {
+			base = base[:len(base)-len(suffix)]
+			break
+		}
+	}
+	return strings.EqualFold(base, denied)
+}
+
+// LicenseDenied reports whether a formula under the license expression
+// expr would have to be used under one of the denied licenses: for
+// "A or B" both must be denied, for "A and B" either. Unknown licenses are
+// never denied.
+func LicenseDenied(expr string, denied []string) bool {
+	if expr == "" || expr == LicenseUnknown || len(denied) == 0 {
+		return false
+	}
+	return parseLicense(expr).denied(denied)
+}
+
+// InstalledLicenses returns the license of every installed formula,
+// sorted by name. Casks have no license metadata and are left out.
+func (c *Client) InstalledLicenses(denied []string) ([]PackageLicense, error) {
+	installed, err := c.ListInstalledNative()
+	if err != nil {
+		return nil, err
+	}
+	lookup, err := c.formulaLookup()
+	if err != nil {
+		return nil, err
+	}
+	var out []PackageLicense
+	for _, pkg := range installed {
+		if pkg.IsCask {
+			continue
+		}
+		out = append(out, packageLicense(pkg.Name, lookup, denied))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// InstallLicenses returns the licenses of the formulae installing names
+// would add: names and the dependencies opts selects, except those already
+// installed. Names the index does not know are reported with
+// LicenseUnknown.
+func (c *Client) InstallLicenses(names []string, opts DepsOptions, denied []string) ([]PackageLicense, error) {
+	lookup, err := c.formulaLookup()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var out []PackageLicense
+	var visit func(name string)
+	visit = func(name string) {
+		if seen[name] || c.isInstalled(name) {
+			return
+		}
+		seen[name] = true
+		out = append(out, packageLicense(name, lookup, denied))
+		if f, ok := lookup(name); ok {
+			for _, dep := range f.depList(opts) {
+				visit(dep.name)
+			}
+		}
+	}
+	for _, name := range names {
+		visit(name)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func packageLicense(name string, lookup func(string) (*Formula, bool), denied []string) PackageLicense {
+	license := LicenseUnknown
+	if f, ok := lookup(name); ok && f.License != "" {
+		license = f.License
+	}
+	return PackageLicense{Name: name, License: license, Denied: LicenseDenied(license, denied)}
+}
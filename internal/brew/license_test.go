@@ -0,0 +1,59 @@
+package brew
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLicenseDenied(t *testing.T) {
+	denied := []string{"GPL-3.0", "AGPL-3.0-only"}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"MIT", false},
+		{"GPL-3.0", true},
+		{"gpl-3.0-or-later", true},
+		{"GPL-3.0+", true},
+		{"GPL-2.0-only", false},
+		{"AGPL-3.0-or-later", false},
+		{"Apache-2.0 or GPL-3.0-only", false},
+		{"GPL-3.0-only OR AGPL-3.0-only", true},
+		{"MIT and GPL-3.0-or-later", true},
+		{"(MIT or GPL-3.0-only) and BSD-3-Clause", false},
+		{"(GPL-3.0-only with GCC-exception-3.1) or (AGPL-3.0-only)", true},
+		{"", false},
+		{LicenseUnknown, false},
+	}
+	for _, tt := range tests {
+		if got := LicenseDenied(tt.expr, denied); got != tt.want {
+			t.Errorf("LicenseDenied(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+	if LicenseDenied("GPL-3.0-only", nil) {
+		t.Error("nothing is denied without a policy")
+	}
+}
+
+func TestInstallLicenses(t *testing.T) {
+	formulae := []Formula{
+		{Name: "app", License: "MIT", Dependencies: []string{"libgpl", "libinstalled"}, BuildDependencies: []string{"tool"}},
+		{Name: "libgpl", License: "GPL-3.0-or-later"},
+		{Name: "libinstalled", License: "GPL-3.0-only"},
+		{Name: "tool", License: "Apache-2.0"},
+	}
+	client := newDepsTestClient(t, formulae, "libinstalled")
+
+	got, err := client.InstallLicenses([]string{"app", "missing"}, DepsOptions{}, []string{"GPL-3.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PackageLicense{
+		{Name: "app", License: "MIT"},
+		{Name: "libgpl", License: "GPL-3.0-or-later", Denied: true},
+		{Name: "missing", License: LicenseUnknown},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("InstallLicenses = %+v, want %+v", got, want)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
 )
@@ -42,12 +43,10 @@ func (c *Client) linkInternal(name, version string, dryRun bool) (*LinkResult, e
 	optLink := filepath.Join(optDir, name)
 	if !dryRun {
 		os.MkdirAll(optDir, 0755)
-		if existing, err := os.Lstat(optLink); err == nil {
-			if existing.Mode()&os.ModeSymlink != 0 {
-				os.Remove(optLink)
-			}
-		}
-		if err := os.Symlink(cellarPath, optLink); err != nil {
+		if existing, err := os.Lstat(optLink); err == nil && existing.Mode()&os.ModeSymlink == 0 {
+			result.Errors = append(result.Errors, fmt.Errorf("opt link %s exists and is not a symlink", optLink))
+			result.Success = false
+		} else if err := c.replaceSymlink(name, cellarPath, optLink); err != nil {
 			result.Errors = append(result.Errors, fmt.Errorf("failed to create opt link: %w", err))
 			result.Success = false
 		}
@@ -58,6 +57,7 @@ func (c *Client) linkInternal(name, version string, dryRun bool) (*LinkResult, e
 		linkDirs = append(linkDirs, "Frameworks")
 	}
 
+	links := &KegLinks{}
 	for _, dir := range linkDirs {
 		srcDir := filepath.Join(cellarPath, dir)
 		if _, err := os.Stat(srcDir); os.IsNotExist(err) {
@@ -67,13 +67,34 @@ func (c *Client) linkInternal(name, version string, dryRun bool) (*LinkResult, e
 		if !dryRun {
 			os.MkdirAll(targetDir, 0755)
 		}
-		c.linkDir(srcDir, targetDir, cellarPath, result, dryRun)
+		c.linkDir(srcDir, targetDir, cellarPath, result, links, dryRun)
+	}
+
+	if !dryRun {
+		// Links recorded by an earlier Link that were not made again this
+		// time point at files the keg no longer has.
+		if previous, err := LoadKegLinks(cellarPath); err == nil {
+			current := make(map[string]bool, len(links.Links))
+			for _, link := range links.Links {
+				current[link.Path] = true
+			}
+			stale := &KegLinks{}
+			for _, link := range previous.Links {
+				if !current[link.Path] {
+					stale.Links = append(stale.Links, link)
+				}
+			}
+			c.removeKegLinks(cellarPath, stale)
+		}
+		if err := links.write(cellarPath); err != nil {
+			result.Errors = append(result.Errors, err)
+		}
 	}
 
 	return result, nil
 }
 
-func (c *Client) linkDir(srcDir, targetDir, cellarPath string, result *LinkResult, dryRun bool) {
+func (c *Client) linkDir(srcDir, targetDir, cellarPath string, result *LinkResult, links *KegLinks, dryRun bool) {
 	filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil
@@ -103,13 +124,11 @@ func (c *Client) linkDir(srcDir, targetDir, cellarPath string, result *LinkResul
 			return nil
 		}
 
-		if _, err := os.Lstat(dst); err == nil {
-			os.Remove(dst)
-		}
-
-		if err := os.Symlink(path, dst); err != nil {
+		if err := c.replaceSymlink(result.Package, path, dst); err != nil {
 			result.Errors = append(result.Errors, fmt.Errorf("failed to link %s: %w", rel, err))
 			result.Success = false
+		} else if linkRel, err := filepath.Rel(c.Prefix, dst); err == nil {
+			links.Links = append(links.Links, KegLink{Path: filepath.ToSlash(linkRel), Target: path})
 		}
 
 		return nil
@@ -188,17 +207,65 @@ func (ct *ConflictTracker) GetAllTrackedBinaries() map[string]string {
 }
 
 func (c *Client) Unlink(name string) error {
+	return c.unlinkKeg(name, false)
+}
+
+// Switch makes version the active version of the installed formula name:
+// the links of whichever version was linked are removed, and opt/<name>
+// and the prefix links are pointed at version's keg instead. The version
+// must already be installed in the Cellar.
+func (c *Client) Switch(name, version string) (*LinkResult, error) {
+	versions := kegVersions(filepath.Join(c.Cellar, name))
+	if len(versions) == 0 {
+		return nil, kindErrorf(KindNotFound, "%s is not installed", name)
+	}
+	if !slices.Contains(versions, version) {
+		return nil, kindErrorf(KindNotFound, "%s %s is not installed (installed: %s)", name, version, strings.Join(versions, ", "))
+	}
+	if err := c.unlinkKeg(name, false); err != nil {
+		return nil, err
+	}
+	result, err := c.Link(name, version)
+	if err != nil {
+		return nil, err
+	}
+	c.notifyInvalidation(EventInstalledChanged)
+	return result, nil
+}
+
+// unlinkKeg removes name's links from the prefix. With keepOpt the opt link
+// is left in place so dependents keep resolving while the keg is replaced.
+func (c *Client) unlinkKeg(name string, keepOpt bool) error {
+	links, err := c.kegPrefixLinks(name, keepOpt)
+	if err != nil {
+		return err
+	}
+	for _, path := range links {
+		os.Remove(path)
+	}
+	for _, version := range kegVersions(filepath.Join(c.Cellar, name)) {
+		os.Remove(filepath.Join(c.Cellar, name, version, kegLinksName))
+	}
+	return nil
+}
+
+// kegPrefixLinks returns the symlinks in the prefix that point into name's
+// kegs, including opt/<name> unless keepOpt is set. Kegs with recorded
+// links contribute exactly those still pointing where they did; others are
+// walked for links pointing into them.
+func (c *Client) kegPrefixLinks(name string, keepOpt bool) ([]string, error) {
 	pkgDir := filepath.Join(c.Cellar, name)
 	cellarPrefix := filepath.Join(c.Cellar, name) + string(filepath.Separator)
 
 	versions, err := os.ReadDir(pkgDir)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	var links []string
 	optLink := filepath.Join(c.Prefix, "opt", name)
-	if info, err := os.Lstat(optLink); err == nil && info.Mode()&os.ModeSymlink != 0 {
-		os.Remove(optLink)
+	if info, err := os.Lstat(optLink); err == nil && info.Mode()&os.ModeSymlink != 0 && !keepOpt {
+		links = append(links, optLink)
 	}
 
 	linkDirs := []string{"bin", "sbin", "lib", "include", "share", "etc"}
@@ -211,20 +278,33 @@ func (c *Client) Unlink(name string) error {
 			continue
 		}
 
+		kegDir := filepath.Join(pkgDir, vEntry.Name())
+		if recorded, err := LoadKegLinks(kegDir); err == nil {
+			for _, link := range recorded.Links {
+				path := filepath.Join(c.Prefix, filepath.FromSlash(link.Path))
+				if target, err := os.Readlink(path); err == nil && target == link.Target {
+					links = append(links, path)
+				}
+			}
+			continue
+		}
+
 		for _, dir := range linkDirs {
 			srcDir := filepath.Join(pkgDir, vEntry.Name(), dir)
 			if _, err := os.Stat(srcDir); os.IsNotExist(err) {
 				continue
 			}
 			targetDir := filepath.Join(c.Prefix, dir)
-			c.unlinkDir(srcDir, targetDir, cellarPrefix)
+			links = c.appendLinksInto(links, srcDir, targetDir, cellarPrefix)
 		}
 	}
 
-	return nil
+	return links, nil
 }
 
-func (c *Client) unlinkDir(srcDir, targetDir, cellarPrefix string) {
+// appendLinksInto appends the links under targetDir that mirror files in
+// srcDir and point into the Cellar directory cellarPrefix.
+func (c *Client) appendLinksInto(links []string, srcDir, targetDir, cellarPrefix string) []string {
 	filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil || info.IsDir() {
 			return nil
@@ -249,7 +329,8 @@ func (c *Client) unlinkDir(srcDir, targetDir, cellarPrefix string) {
 			return nil
 		}
 
-		os.Remove(linkPath)
+		links = append(links, linkPath)
 		return nil
 	})
+	return links
 }
@@ -0,0 +1,124 @@
+package brew
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSwitch(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	c := newTransactionTestClient(t)
+	makeKeg(t, c, "jq", "1.6")
+	makeKeg(t, c, "jq", "1.7.1")
+	if _, err := c.Link("jq", "1.7.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Switch("jq", "1.6"); err != nil {
+		t.Fatalf("Switch failed: %v", err)
+	}
+	if got := c.linkedVersion("jq"); got != "1.6" {
+		t.Errorf("opt link points at %q, want 1.6", got)
+	}
+	target, err := os.Readlink(filepath.Join(c.Prefix, "bin", "jq"))
+	if err != nil || !strings.Contains(target, filepath.Join("jq", "1.6")) {
+		t.Errorf("bin/jq points at %q (%v), want the 1.6 keg", target, err)
+	}
+
+	installed, err := c.ListInstalledNative()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(installed) != 1 {
+		t.Fatalf("expected one package, got %+v", installed)
+	}
+	pkg := installed[0]
+	if pkg.Version != "1.7.1" || pkg.LinkedVersion != "1.6" || !reflect.DeepEqual(pkg.Versions, []string{"1.6", "1.7.1"}) {
+		t.Errorf("unexpected package %+v", pkg)
+	}
+
+	if _, err := c.Switch("jq", "2.0"); err == nil || !strings.Contains(err.Error(), "1.6, 1.7.1") {
+		t.Errorf("expected an error listing installed versions, got %v", err)
+	}
+	if _, err := c.Switch("wget", "1.0"); err == nil {
+		t.Error("expected an error for a formula that is not installed")
+	}
+}
+
+func TestUnlinkRemovesRecordedLinks(t *testing.T) {
+	c := newTransactionTestClient(t)
+	keg := makeKeg(t, c, "jq", "1.7.1")
+	if err := os.MkdirAll(filepath.Join(keg, "share", "man"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(keg, "share", "man", "jq.1"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Link("jq", "1.7.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	links, err := c.Links("jq")
+	if err != nil {
+		t.Fatalf("Links failed: %v", err)
+	}
+	var paths []string
+	for _, link := range links {
+		paths = append(paths, link.Path+" "+link.Status)
+	}
+	if want := []string{"bin/jq ok", "share/man/jq.1 ok"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("Links = %v, want %v", paths, want)
+	}
+
+	// A renamed file is no longer found by walking the keg, and a link
+	// taken over by another formula must be left alone.
+	if err := os.Rename(filepath.Join(keg, "bin", "jq"), filepath.Join(keg, "bin", "jq-renamed")); err != nil {
+		t.Fatal(err)
+	}
+	manLink := filepath.Join(c.Prefix, "share", "man", "jq.1")
+	os.Remove(manLink)
+	if err := os.Symlink(filepath.Join(c.Cellar, "gojq", "0.12", "share", "man", "jq.1"), manLink); err != nil {
+		t.Fatal(err)
+	}
+	if links, _ := c.Links("jq"); links[1].Status != "changed" {
+		t.Errorf("expected the taken-over link to be reported as changed, got %+v", links[1])
+	}
+
+	if err := c.Unlink("jq"); err != nil {
+		t.Fatalf("Unlink failed: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(c.Prefix, "bin", "jq")); !os.IsNotExist(err) {
+		t.Errorf("bin/jq was not removed: %v", err)
+	}
+	if _, err := os.Lstat(manLink); err != nil {
+		t.Errorf("the link owned by another formula was removed: %v", err)
+	}
+	if _, err := c.Links("jq"); !os.IsNotExist(err) {
+		t.Errorf("expected no recorded links after unlinking, got %v", err)
+	}
+}
+
+func TestLinkRemovesStaleRecordedLinks(t *testing.T) {
+	c := newTransactionTestClient(t)
+	keg := makeKeg(t, c, "jq", "1.7.1")
+	if err := os.WriteFile(filepath.Join(keg, "bin", "jq-old"), nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Link("jq", "1.7.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Remove(filepath.Join(keg, "bin", "jq-old"))
+	if _, err := c.Link("jq", "1.7.1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(c.Prefix, "bin", "jq-old")); !os.IsNotExist(err) {
+		t.Errorf("stale link bin/jq-old was not removed: %v", err)
+	}
+	if links, err := c.Links("jq"); err != nil || len(links) != 1 || links[0].Path != "bin/jq" {
+		t.Errorf("Links = %+v, %v; want only bin/jq", links, err)
+	}
+}
@@ -0,0 +1,275 @@
+package brew
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LocalBottleOptions tune InstallLocalBottles.
+type LocalBottleOptions struct {
+	// SHA256 is the expected checksum of the bottle. It can only be given
+	// when installing a single bottle.
+	SHA256 string
+}
+
+// localBottle is a bottle tarball on disk and the keg it contains.
+type localBottle struct {
+	path    string
+	name    string
+	version string
+	formula *RemoteFormula
+}
+
+// FindLocalBottles expands paths into bottle tarballs. Directories
+// contribute every *.bottle*.tar.gz file directly inside them.
+func FindLocalBottles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(path, "*.bottle*.tar.gz"))
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no bottles found in %s", path)
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// readBottleKeg returns the formula name and keg version a bottle installs,
+// taken from its top-level name/version/ directory.
+func readBottleKeg(path string) (string, string, error) {
+	tr, closeArchive, err := openBottleArchive(path, 1)
+	if err != nil {
+		return "", "", err
+	}
+	defer closeArchive()
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", "", err
+		}
+		parts := strings.Split(strings.TrimPrefix(header.Name, "./"), "/")
+		if len(parts) >= 2 && parts[0] != "" && parts[1] != "" {
+			return parts[0], parts[1], nil
+		}
+	}
+	return "", "", fmt.Errorf("%s does not contain a name/version keg", filepath.Base(path))
+}
+
+// bottleTagFromFilename returns the bottle tag of a file named like
+// wget--1.21.4.arm64_sonoma.bottle.tar.gz, or "" if it has none.
+func bottleTagFromFilename(path string) string {
+	base := filepath.Base(path)
+	i := strings.Index(base, ".bottle.")
+	if i < 0 {
+		return ""
+	}
+	stem := base[:i]
+	return stem[strings.LastIndex(stem, ".")+1:]
+}
+
+// sidecarSHA reads the checksum for path from the JSON file `brew bottle
+// --json` writes next to it (wget--1.21.4.arm64_sonoma.bottle.json).
+func sidecarSHA(path string) (string, bool) {
+	base := filepath.Base(path)
+	i := strings.Index(base, ".bottle")
+	if i < 0 {
+		return "", false
+	}
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(path), base[:i]+".bottle.json"))
+	if err != nil {
+		return "", false
+	}
+
+	var doc map[string]struct {
+		Bottle struct {
+			Tags map[string]struct {
+				Filename      string `json:"filename"`
+				LocalFilename string `json:"local_filename"`
+				SHA256        string `json:"sha256"`
+			} `json:"tags"`
+		} `json:"bottle"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return "", false
+	}
+	for _, entry := range doc {
+		for tag, file := range entry.Bottle.Tags {
+			if file.LocalFilename == base || file.Filename == base || tag == bottleTagFromFilename(path) {
+				return file.SHA256, file.SHA256 != ""
+			}
+		}
+	}
+	return "", false
+}
+
+// InstallLocalBottles installs bottle tarballs from disk without
+// downloading them. Each bottle's checksum is verified against, in order,
+// opts.SHA256, the `brew bottle --json` file next to it, or the formula
+// from the API. Dependencies are not installed; missing ones are reported.
+func (c *Client) InstallLocalBottles(paths []string, opts LocalBottleOptions) (err error) {
+	files, err := FindLocalBottles(paths)
+	if err != nil {
+		return err
+	}
+	if opts.SHA256 != "" && len(files) != 1 {
+		return fmt.Errorf("--sha256 applies to a single bottle, got %d", len(files))
+	}
+
+	var bottles []localBottle
+	for _, path := range files {
+		bottle := localBottle{path: path}
+		if bottle.name, bottle.version, err = readBottleKeg(path); err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if bottle.formula, err = c.verifyLocalBottle(bottle, opts.SHA256); err != nil {
+			return err
+		}
+		bottles = append(bottles, bottle)
+	}
+	if err := c.ensurePrefix(); err != nil {
+		return err
+	}
+	return c.installBottleFiles(bottles)
+}
+
+// installBottleFiles extracts and links verified bottles in order, warning
+// about dependencies that are neither installed nor part of the set.
+func (c *Client) installBottleFiles(bottles []localBottle) (err error) {
+	queue := make([]*RemoteFormula, 0, len(bottles))
+	queued := make(map[string]bool, len(bottles))
+	for _, b := range bottles {
+		queue = append(queue, b.formula)
+		queued[b.formula.Name] = true
+	}
+	for _, f := range queue {
+		var missing []string
+		for _, dep := range f.Dependencies {
+			if !queued[dep] && !c.isInstalled(dep) {
+				missing = append(missing, dep)
+			}
+		}
+		if len(missing) > 0 {
+			c.printf("  ⚠️  %s has missing dependencies: %s\n", f.Name, strings.Join(missing, ", "))
+		}
+	}
+
+	c.beginTransaction(MutationOperationInstall, formulaNames(queue))
+	defer func() { c.finishTransaction(err) }()
+
+	c.printf("📦 Extracting %d local bottle(s)...\n", len(queue))
+	var installed []*RemoteFormula
+	for _, bottle := range bottles {
+		f := bottle.formula
+		if err := c.RunHooks(HookPreInstall, c.formulaHookEnv(f, "")); err != nil {
+			c.printf("  ❌ %v\n", err)
+			return err
+		}
+		c.emitMutation(MutationOperationInstall, f.Name, MutationPhaseExtract, MutationStatusRunning, "extracting bottle", 0, 0, "")
+		if err := c.extractAndInstallBottle(f, bottle.path, false); err != nil {
+			c.printf("  ❌ Failed to extract %s: %v\n", f.Name, err)
+			c.emitMutation(MutationOperationInstall, f.Name, MutationPhaseExtract, MutationStatusFailed, err.Error(), 0, 0, "")
+			return fmt.Errorf("failed to extract %s: %w", f.Name, err)
+		}
+		c.printf("  ✅ Extracted %s\n", f.Name)
+		c.emitMutation(MutationOperationInstall, f.Name, MutationPhaseExtract, MutationStatusSucceeded, "extracted bottle", 0, 0, "")
+		installed = append(installed, f)
+	}
+
+	if err := c.linkFormulae(installed, MutationOperationInstall); err != nil {
+		return err
+	}
+	c.runPostHooks(HookPostInstall, installed, nil)
+	reasons := make(map[string]InstallReason, len(installed))
+	for _, f := range installed {
+		reasons[f.Name] = ReasonExplicit
+	}
+	if err := RecordInstallReasons(reasons); err != nil {
+		c.printf("  ⚠️  Failed to record install reasons: %v\n", err)
+	}
+	c.printCaveats(installed)
+	c.notifyInvalidation(EventInstalledChanged)
+	return nil
+}
+
+// verifyLocalBottle checks a bottle's checksum and returns the formula to
+// install it as. Formula metadata from the API supplies keg-only status,
+// dependencies and caveats when it is reachable.
+func (c *Client) verifyLocalBottle(bottle localBottle, expectedSHA string) (*RemoteFormula, error) {
+	actual, err := fileSHA256(bottle.path)
+	if err != nil {
+		return nil, err
+	}
+
+	remote, remoteErr := c.FetchFormula(context.Background(), bottle.name)
+	if remoteErr == nil && remote.FullVersion() != bottle.version {
+		remoteErr = fmt.Errorf("the API has %s %s, the bottle is %s", bottle.name, remote.FullVersion(), bottle.version)
+		remote = nil
+	}
+
+	source := "--sha256"
+	if expectedSHA == "" {
+		if sha, ok := sidecarSHA(bottle.path); ok {
+			expectedSHA, source = sha, "bottle JSON"
+		} else if remote != nil {
+			tag := bottleTagFromFilename(bottle.path)
+			file, ok := remote.Bottle.Stable.Files[tag]
+			if !ok {
+				_, file, err = remote.bottleFile()
+				ok = err == nil
+			}
+			if ok {
+				expectedSHA, source = file.SHA256, "formula"
+			}
+		}
+	}
+	if expectedSHA == "" {
+		reason := "no bottle JSON next to it"
+		if remoteErr != nil {
+			reason += "; formula lookup failed: " + remoteErr.Error()
+		}
+		return nil, fmt.Errorf("cannot verify %s (%s); pass --sha256", filepath.Base(bottle.path), reason)
+	}
+	if !strings.EqualFold(actual, expectedSHA) {
+		return nil, kindErrorf(KindChecksum, "checksum mismatch for %s: expected %s (%s), got %s", filepath.Base(bottle.path), expectedSHA, source, actual)
+	}
+	c.logger().Debug("local bottle verified", "path", bottle.path, "sha256", actual, "source", source)
+
+	if c.VerifyAttestations {
+		if err := c.VerifyBottleAttestation(context.Background(), bottle.name, actual); err != nil {
+			return nil, err
+		}
+	}
+
+	f := &RemoteFormula{Name: bottle.name}
+	if remote != nil {
+		f.Desc = remote.Desc
+		f.Dependencies = remote.Dependencies
+		f.KegOnly = remote.KegOnly
+		f.Caveats = remote.Caveats
+	}
+	// The keg directory already carries any revision suffix.
+	f.Versions.Stable = bottle.version
+	return f, nil
+}
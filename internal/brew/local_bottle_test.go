@@ -0,0 +1,105 @@
+package brew
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestBottle writes a gzip bottle holding name/version/bin/name.
+func writeTestBottle(t *testing.T, dir, filename, name, version string) string {
+	t.Helper()
+	path := filepath.Join(dir, filename)
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+
+	for _, dir := range []string{name + "/", name + "/" + version + "/", name + "/" + version + "/bin/"} {
+		tw.WriteHeader(&tar.Header{Name: dir, Typeflag: tar.TypeDir, Mode: 0755})
+	}
+	body := []byte("#!/bin/sh\necho " + name + "\n")
+	tw.WriteHeader(&tar.Header{Name: name + "/" + version + "/bin/" + name, Typeflag: tar.TypeReg, Mode: 0755, Size: int64(len(body))})
+	tw.Write(body)
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestInstallLocalBottleWithChecksum(t *testing.T) {
+	client, _ := newCleanupTestClient(t)
+	client.Out = &strings.Builder{}
+	path := writeTestBottle(t, t.TempDir(), "fbtestpkg--1.2.3_1.x86_64_linux.bottle.tar.gz", "fbtestpkg", "1.2.3_1")
+	sha, err := fileSHA256(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.InstallLocalBottles([]string{path}, LocalBottleOptions{SHA256: sha}); err != nil {
+		t.Fatalf("InstallLocalBottles failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(client.Cellar, "fbtestpkg", "1.2.3_1", "bin", "fbtestpkg")); err != nil {
+		t.Fatalf("keg not installed: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(client.Prefix, "bin", "fbtestpkg")); err != nil {
+		t.Fatalf("binary not linked: %v", err)
+	}
+}
+
+func TestInstallLocalBottleRejectsChecksumMismatch(t *testing.T) {
+	client, _ := newCleanupTestClient(t)
+	client.Out = &strings.Builder{}
+	path := writeTestBottle(t, t.TempDir(), "fbtestpkg-1.0.all.bottle.tar.gz", "fbtestpkg", "1.0")
+
+	err := client.InstallLocalBottles([]string{path}, LocalBottleOptions{SHA256: strings.Repeat("0", 64)})
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(client.Cellar, "fbtestpkg")); err == nil {
+		t.Fatal("nothing should be installed after a checksum mismatch")
+	}
+}
+
+func TestInstallLocalBottlesFromDirectoryWithBottleJSON(t *testing.T) {
+	client, _ := newCleanupTestClient(t)
+	client.Out = &strings.Builder{}
+	dir := t.TempDir()
+	path := writeTestBottle(t, dir, "fbtestpkg--2.0.arm64_sonoma.bottle.tar.gz", "fbtestpkg", "2.0")
+	sha, _ := fileSHA256(path)
+	sidecar := `{"fbtestpkg":{"bottle":{"tags":{"arm64_sonoma":{"filename":"fbtestpkg-2.0.arm64_sonoma.bottle.tar.gz","local_filename":"fbtestpkg--2.0.arm64_sonoma.bottle.tar.gz","sha256":"` + sha + `"}}}}}`
+	if err := os.WriteFile(filepath.Join(dir, "fbtestpkg--2.0.arm64_sonoma.bottle.json"), []byte(sidecar), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.InstallLocalBottles([]string{dir}, LocalBottleOptions{}); err != nil {
+		t.Fatalf("InstallLocalBottles failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(client.Cellar, "fbtestpkg", "2.0")); err != nil {
+		t.Fatalf("keg not installed: %v", err)
+	}
+}
+
+func TestBottleTagFromFilename(t *testing.T) {
+	tests := map[string]string{
+		"wget--1.21.4.arm64_sonoma.bottle.tar.gz":      "arm64_sonoma",
+		"foo-1.2.3.x86_64_linux.bottle.1.tar.gz":       "x86_64_linux",
+		"/tmp/ca-certificates--2024.all.bottle.tar.gz": "all",
+		"wget.tar.gz": "",
+	}
+	for in, want := range tests {
+		if got := bottleTagFromFilename(in); got != want {
+			t.Errorf("bottleTagFromFilename(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
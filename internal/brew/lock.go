@@ -0,0 +1,111 @@
+package brew
+
+import (
+	"context"
+	"fastbrew/internal/retry"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LockedBottle pins a formula to one exact bottle.
+type LockedBottle struct {
+	Name         string
+	Version      string
+	Revision     int
+	URL          string
+	SHA256       string
+	Dependencies []string
+}
+
+// InstallLock restricts an install to pre-resolved bottles. Every formula
+// the install needs must be listed; anything missing means the lock is out
+// of date.
+type InstallLock struct {
+	Bottles map[string]LockedBottle
+}
+
+// NewInstallLock indexes bottles by formula name.
+func NewInstallLock(bottles []LockedBottle) *InstallLock {
+	lock := &InstallLock{Bottles: make(map[string]LockedBottle, len(bottles))}
+	for _, b := range bottles {
+		lock.Bottles[b.Name] = b
+	}
+	return lock
+}
+
+// apply rewrites f to install the locked version and bottle for the current
+// platform.
+func (l *InstallLock) apply(f *RemoteFormula) error {
+	locked, ok := l.Bottles[f.Name]
+	if !ok {
+		return fmt.Errorf("%s is not in the lock file; the lock is out of date", f.Name)
+	}
+	platform, err := GetPlatform()
+	if err != nil {
+		return err
+	}
+	f.Versions.Stable = locked.Version
+	f.Revision = locked.Revision
+	f.Bottle.Stable.Files = map[string]BottleFile{
+		platform: {URL: locked.URL, SHA256: locked.SHA256},
+	}
+	// Use the locked dependency set so the install matches the lock even
+	// if the formula's dependencies have changed upstream since.
+	f.Dependencies = locked.Dependencies
+	return nil
+}
+
+// ResolveBottles returns the bottle the current index resolves to for each
+// named formula and all of its runtime and recommended dependencies, sorted
+// by name. Tap
+// formulae (user/repo/name) are not resolved.
+func (c *Client) ResolveBottles(names []string) ([]LockedBottle, error) {
+	ctx := context.Background()
+	resolved := make(map[string]LockedBottle)
+
+	var resolve func(name string) error
+	resolve = func(name string) error {
+		if _, done := resolved[name]; done || strings.Contains(name, "/") {
+			return nil
+		}
+		f, err := retry.WithResult(ctx, func() (*RemoteFormula, error) {
+			return c.FetchFormula(ctx, name)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to fetch formula %s: %w", name, err)
+		}
+		url, sha, err := f.GetBottleInfo()
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		deps := f.depNames(DepsOptions{})
+		resolved[name] = LockedBottle{
+			Name:         f.Name,
+			Version:      f.Versions.Stable,
+			Revision:     f.Revision,
+			URL:          url,
+			SHA256:       sha,
+			Dependencies: deps,
+		}
+		for _, dep := range deps {
+			if err := resolve(dep); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, name := range names {
+		if err := resolve(name); err != nil {
+			return nil, err
+		}
+	}
+
+	bottles := make([]LockedBottle, 0, len(resolved))
+	for _, b := range resolved {
+		bottles = append(bottles, b)
+	}
+	sort.Slice(bottles, func(i, j int) bool { return bottles[i].Name < bottles[j].Name })
+	return bottles, nil
+}
@@ -0,0 +1,39 @@
+package brew
+
+import "testing"
+
+func TestInstallLockApply(t *testing.T) {
+	platform, err := GetPlatform()
+	if err != nil {
+		t.Skipf("unsupported platform: %v", err)
+	}
+	lock := NewInstallLock([]LockedBottle{{
+		Name:         "wget",
+		Version:      "1.24.5",
+		Revision:     1,
+		URL:          "https://example.com/wget-1.24.5_1.tar.gz",
+		SHA256:       "abc",
+		Dependencies: []string{"openssl@3"},
+	}})
+
+	f := &RemoteFormula{Name: "wget", Dependencies: []string{"openssl@3", "libidn2"}}
+	f.Versions.Stable = "1.25.0"
+	if err := lock.apply(f); err != nil {
+		t.Fatal(err)
+	}
+
+	url, sha, err := f.GetBottleInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Versions.Stable != "1.24.5" || f.Revision != 1 || url != lock.Bottles["wget"].URL || sha != "abc" {
+		t.Errorf("lock not applied for %s: %+v", platform, f)
+	}
+	if len(f.Dependencies) != 1 {
+		t.Errorf("dependencies = %v, want the locked set", f.Dependencies)
+	}
+
+	if err := lock.apply(&RemoteFormula{Name: "jq"}); err == nil {
+		t.Error("expected an error for a formula missing from the lock")
+	}
+}
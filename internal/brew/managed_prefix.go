@@ -0,0 +1,53 @@
+package brew
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// managedPrefixMarker is the file BootstrapPrefix leaves in a prefix it
+// created, telling it apart from a Homebrew installation.
+const managedPrefixMarker = ".fastbrew-prefix"
+
+// managedPrefixDirs are the directories of a Homebrew prefix that
+// installing and linking expect to exist.
+var managedPrefixDirs = []string{"Cellar", "Caskroom", "opt", "bin", "sbin", "lib", "include", "share", "etc", "var"}
+
+// BootstrapPrefix creates a prefix for fastbrew to manage at dir, with the
+// Cellar, opt and link directories of a Homebrew prefix, so fastbrew can
+// be used without Homebrew. Existing directories are kept, so it is safe to
+// run again.
+func BootstrapPrefix(dir string) error {
+	for _, sub := range managedPrefixDirs {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Join(dir, sub), err)
+		}
+	}
+	marker := filepath.Join(dir, managedPrefixMarker)
+	if _, err := os.Stat(marker); os.IsNotExist(err) {
+		if err := os.WriteFile(marker, []byte("This prefix is managed by fastbrew.\n"), 0644); err != nil {
+			return fmt.Errorf("failed to mark %s as managed: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+// ensurePrefix creates the prefix fastbrew manages itself, when the client
+// uses one, so only commands that install into it create it.
+func (c *Client) ensurePrefix() error {
+	if !c.managedPrefix {
+		return nil
+	}
+	if err := BootstrapPrefix(c.Prefix); err != nil {
+		return fmt.Errorf("could not find brew prefix, and %w. Set HOMEBREW_PREFIX environment variable", err)
+	}
+	return nil
+}
+
+// IsManagedPrefix reports whether dir was created by BootstrapPrefix rather
+// than by Homebrew.
+func IsManagedPrefix(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, managedPrefixMarker))
+	return err == nil
+}
@@ -0,0 +1,70 @@
+package brew
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBootstrapPrefix(t *testing.T) {
+	prefix := filepath.Join(t.TempDir(), "prefix")
+	if IsManagedPrefix(prefix) {
+		t.Fatal("missing prefix reported as managed")
+	}
+	if err := BootstrapPrefix(prefix); err != nil {
+		t.Fatalf("BootstrapPrefix failed: %v", err)
+	}
+	for _, dir := range []string{"Cellar", "opt", "bin"} {
+		if info, err := os.Stat(filepath.Join(prefix, dir)); err != nil || !info.IsDir() {
+			t.Errorf("%s was not created: %v", dir, err)
+		}
+	}
+	if !IsManagedPrefix(prefix) {
+		t.Error("bootstrapped prefix not reported as managed")
+	}
+
+	// Running again keeps what is installed.
+	keg := filepath.Join(prefix, "Cellar", "jq", "1.7.1")
+	if err := os.MkdirAll(keg, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := BootstrapPrefix(prefix); err != nil {
+		t.Fatalf("second BootstrapPrefix failed: %v", err)
+	}
+	if _, err := os.Stat(keg); err != nil {
+		t.Errorf("keg removed by a second bootstrap: %v", err)
+	}
+
+	client, err := NewClientAt(prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := NewDoctor(client, false).checkHomebrewInstallation()
+	if result.Status != StatusOK || !strings.Contains(result.Message, "fastbrew-managed") {
+		t.Errorf("doctor reported %+v for a managed prefix", result)
+	}
+}
+
+func TestManagedPrefixCreatedByFirstInstall(t *testing.T) {
+	prefix := filepath.Join(t.TempDir(), "prefix")
+	client := newClientAt(prefix)
+	client.managedPrefix = true
+
+	if _, err := client.ListInstalledNative(); err != nil {
+		t.Fatalf("listing without a prefix failed: %v", err)
+	}
+	if result := NewDoctor(client, false).checkHomebrewInstallation(); result.Status != StatusInfo {
+		t.Errorf("doctor reported %+v before the first install", result)
+	}
+	if _, err := os.Stat(prefix); !os.IsNotExist(err) {
+		t.Fatalf("prefix created before any install: %v", err)
+	}
+
+	if err := client.ensurePrefix(); err != nil {
+		t.Fatalf("ensurePrefix failed: %v", err)
+	}
+	if !IsManagedPrefix(prefix) {
+		t.Error("expected the first install to bootstrap the prefix")
+	}
+}
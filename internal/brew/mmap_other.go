@@ -0,0 +1,14 @@
+//go:build !unix
+
+package brew
+
+import "os"
+
+// mapFile reads path into memory where mapping is not supported.
+func mapFile(path string) ([]byte, func(), error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() {}, nil
+}
@@ -41,6 +41,13 @@ type MutationEvent struct {
 }
 
 func (c *Client) emitMutation(operation, pkg, phase, status, message string, current, total int64, unit string) {
+	switch status {
+	case MutationStatusProgress:
+	case MutationStatusFailed:
+		c.logger().Warn(message, "operation", operation, "package", pkg, "phase", phase, "status", status)
+	default:
+		c.logger().Debug(message, "operation", operation, "package", pkg, "phase", phase, "status", status)
+	}
 	c.notifyMutation(MutationEvent{
 		Operation: operation,
 		Package:   pkg,
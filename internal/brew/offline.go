@@ -0,0 +1,294 @@
+package brew
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fastbrew/internal/atomicfile"
+	"fastbrew/internal/retry"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// offlineBundleVersion is the format version written to bundle manifests.
+const offlineBundleVersion = 1
+
+// offlineManifestName is the first entry of every offline bundle.
+const offlineManifestName = "manifest.json"
+
+// OfflineManifest describes the contents of an offline bundle: the formula
+// metadata needed to install each bottle without reaching the API.
+type OfflineManifest struct {
+	Version  int       `json:"version"`
+	Platform string    `json:"platform"`
+	Created  time.Time `json:"created"`
+	// Packages are the formulae the bundle was exported for.
+	Packages []string `json:"packages"`
+	// Formulae lists every bottle in install order, dependencies first.
+	Formulae []OfflineFormula `json:"formulae"`
+}
+
+// OfflineFormula is one bottle in an offline bundle.
+type OfflineFormula struct {
+	Formula *RemoteFormula `json:"formula"`
+	// Bottle is the bottle's path inside the archive.
+	Bottle string `json:"bottle"`
+	SHA256 string `json:"sha256"`
+}
+
+// resolveClosure fetches the named formulae and all of their runtime and
+// recommended dependencies, ordered so that every formula follows its
+// dependencies.
+func (c *Client) resolveClosure(names []string) ([]*RemoteFormula, error) {
+	ctx := context.Background()
+	visited := make(map[string]bool)
+	var ordered []*RemoteFormula
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		if visited[name] {
+			return nil
+		}
+		visited[name] = true
+		if strings.Contains(name, "/") {
+			return fmt.Errorf("%s: tap formulae cannot be exported", name)
+		}
+		f, err := retry.WithResult(ctx, func() (*RemoteFormula, error) {
+			return c.FetchFormula(ctx, name)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to fetch formula %s: %w", name, err)
+		}
+		for _, dep := range f.depNames(DepsOptions{}) {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		ordered = append(ordered, f)
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// ExportBundle downloads the bottles for packages and their dependencies
+// for the current platform and writes them, with their formula metadata,
+// to a tar archive at outPath that ImportBundle installs from offline.
+func (c *Client) ExportBundle(packages []string, outPath string) (*OfflineManifest, error) {
+	if len(packages) == 0 {
+		return nil, fmt.Errorf("no packages to export")
+	}
+	platform, err := getPlatform(c.runner())
+	if err != nil {
+		return nil, err
+	}
+
+	c.printf("🔍 Resolving dependencies for %s...\n", strings.Join(packages, ", "))
+	formulae, err := c.resolveClosure(packages)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &OfflineManifest{
+		Version:  offlineBundleVersion,
+		Platform: platform,
+		Created:  time.Now().UTC(),
+		Packages: packages,
+	}
+	paths := make([]string, len(formulae))
+	c.printf("⬇️  Downloading %d bottle(s)...\n", len(formulae))
+	for i, f := range formulae {
+		_, sha, err := f.GetBottleInfo()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		if paths[i], err = c.DownloadBottle(context.Background(), f); err != nil {
+			return nil, fmt.Errorf("failed to download %s: %w", f.Name, err)
+		}
+		c.printf("  ✅ %s %s\n", f.Name, f.FullVersion())
+		manifest.Formulae = append(manifest.Formulae, OfflineFormula{
+			Formula: f,
+			Bottle:  path.Join("bottles", fmt.Sprintf("%s--%s.%s.bottle.tar.gz", f.Name, f.Versions.Stable, platform)),
+			SHA256:  sha,
+		})
+	}
+
+	if err := writeOfflineBundle(outPath, manifest, paths); err != nil {
+		return nil, err
+	}
+	c.printf("📦 Wrote %s\n", outPath)
+	return manifest, nil
+}
+
+// writeOfflineBundle writes the manifest followed by each bottle. The
+// archive replaces outPath atomically.
+func writeOfflineBundle(outPath string, manifest *OfflineManifest, bottlePaths []string) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = atomicfile.Write(outPath, 0644, func(w io.Writer) error {
+		tw := tar.NewWriter(w)
+		header := &tar.Header{Name: offlineManifestName, Mode: 0644, Size: int64(len(data)), ModTime: manifest.Created}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+		for i, entry := range manifest.Formulae {
+			if err := addFileToTar(tw, bottlePaths[i], entry.Bottle); err != nil {
+				return fmt.Errorf("failed to add %s: %w", entry.Formula.Name, err)
+			}
+		}
+		return tw.Close()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", outPath, err)
+	}
+	return nil
+}
+
+func addFileToTar(tw *tar.Writer, src, name string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	header := &tar.Header{Name: name, Mode: 0644, Size: info.Size(), ModTime: info.ModTime()}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// ImportBundle installs every formula in an offline bundle that is not
+// already installed, without network access. Each bottle is verified
+// against the checksum recorded at export time.
+func (c *Client) ImportBundle(archivePath string) (*OfflineManifest, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	tr := tar.NewReader(file)
+
+	header, err := tr.Next()
+	if err != nil || header.Name != offlineManifestName {
+		return nil, fmt.Errorf("%s is not a fastbrew export (missing %s)", archivePath, offlineManifestName)
+	}
+	var manifest OfflineManifest
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", offlineManifestName, err)
+	}
+	if manifest.Version != offlineBundleVersion {
+		return nil, fmt.Errorf("unsupported export format version %d", manifest.Version)
+	}
+	platform, err := getPlatform(c.runner())
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Platform != platform {
+		return nil, fmt.Errorf("export was made for %s, this machine is %s", manifest.Platform, platform)
+	}
+	if err := c.ensurePrefix(); err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]OfflineFormula)
+	for _, entry := range manifest.Formulae {
+		if entry.Formula == nil || entry.Formula.Name == "" {
+			return nil, fmt.Errorf("%s lists a bottle without a formula", offlineManifestName)
+		}
+		if c.isInstalled(entry.Formula.Name) {
+			c.printf("  ✓ %s is already installed\n", entry.Formula.Name)
+			continue
+		}
+		wanted[entry.Bottle] = entry
+	}
+	if len(wanted) == 0 {
+		return &manifest, nil
+	}
+
+	tmpDir, err := os.MkdirTemp("", "fastbrew-import-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	extracted := make(map[string]string, len(wanted))
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", archivePath, err)
+		}
+		entry, ok := wanted[header.Name]
+		if !ok {
+			continue
+		}
+		dest := filepath.Join(tmpDir, path.Base(header.Name))
+		if err := copyVerified(tr, dest, entry.SHA256); err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Formula.Name, err)
+		}
+		extracted[header.Name] = dest
+	}
+
+	var bottles []localBottle
+	for _, entry := range manifest.Formulae {
+		if _, ok := wanted[entry.Bottle]; !ok {
+			continue
+		}
+		dest, ok := extracted[entry.Bottle]
+		if !ok {
+			return nil, fmt.Errorf("%s is listed in the manifest but missing from the archive", entry.Bottle)
+		}
+		bottles = append(bottles, localBottle{
+			path:    dest,
+			name:    entry.Formula.Name,
+			version: entry.Formula.Versions.Stable,
+			formula: entry.Formula,
+		})
+	}
+	return &manifest, c.installBottleFiles(bottles)
+}
+
+// copyVerified writes r to dest and checks its SHA-256.
+func copyVerified(r io.Reader, dest, expectedSHA string) error {
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, h), r); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(actual, expectedSHA) {
+		return kindErrorf(KindChecksum, "checksum mismatch: expected %s, got %s", expectedSHA, actual)
+	}
+	return out.Close()
+}
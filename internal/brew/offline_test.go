@@ -0,0 +1,100 @@
+package brew
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTestBundle(t *testing.T, sha func(name, actual string) string) string {
+	t.Helper()
+	platform, err := GetPlatform()
+	if err != nil {
+		t.Skip(err)
+	}
+	dir := t.TempDir()
+	manifest := &OfflineManifest{
+		Version:  offlineBundleVersion,
+		Platform: platform,
+		Created:  time.Now().UTC(),
+		Packages: []string{"fbtestapp"},
+	}
+	var paths []string
+	for _, f := range []*RemoteFormula{
+		{Name: "fbtestlib"},
+		{Name: "fbtestapp", Dependencies: []string{"fbtestlib"}},
+	} {
+		f.Versions.Stable = "1.0"
+		path := writeTestBottle(t, dir, f.Name+".tar.gz", f.Name, "1.0")
+		actual, err := fileSHA256(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+		manifest.Formulae = append(manifest.Formulae, OfflineFormula{
+			Formula: f,
+			Bottle:  "bottles/" + f.Name + "--1.0." + platform + ".bottle.tar.gz",
+			SHA256:  sha(f.Name, actual),
+		})
+	}
+
+	out := filepath.Join(dir, "bundle.tar")
+	if err := writeOfflineBundle(out, manifest, paths); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestImportBundleInstallsDependenciesFirst(t *testing.T) {
+	client, _ := newCleanupTestClient(t)
+	client.Out = &strings.Builder{}
+	bundle := writeTestBundle(t, func(_, actual string) string { return actual })
+
+	manifest, err := client.ImportBundle(bundle)
+	if err != nil {
+		t.Fatalf("ImportBundle failed: %v", err)
+	}
+	if len(manifest.Formulae) != 2 {
+		t.Fatalf("expected 2 formulae in manifest, got %d", len(manifest.Formulae))
+	}
+	for _, name := range []string{"fbtestlib", "fbtestapp"} {
+		if _, err := os.Lstat(filepath.Join(client.Prefix, "bin", name)); err != nil {
+			t.Fatalf("%s not linked: %v", name, err)
+		}
+	}
+
+	// A second import finds everything installed and does nothing.
+	if _, err := client.ImportBundle(bundle); err != nil {
+		t.Fatalf("second ImportBundle failed: %v", err)
+	}
+}
+
+func TestImportBundleRejectsChecksumMismatch(t *testing.T) {
+	client, _ := newCleanupTestClient(t)
+	client.Out = &strings.Builder{}
+	bundle := writeTestBundle(t, func(name, actual string) string {
+		if name == "fbtestapp" {
+			return strings.Repeat("0", 64)
+		}
+		return actual
+	})
+
+	_, err := client.ImportBundle(bundle)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(client.Cellar, "fbtestlib")); err == nil {
+		t.Fatal("nothing should be installed after a checksum mismatch")
+	}
+}
+
+func TestImportBundleRejectsNonBundle(t *testing.T) {
+	client, _ := newCleanupTestClient(t)
+	path := writeTestBottle(t, t.TempDir(), "x.tar.gz", "fbtestpkg", "1.0")
+
+	if _, err := client.ImportBundle(path); err == nil || !strings.Contains(err.Error(), "not a fastbrew export") {
+		t.Fatalf("expected not-a-bundle error, got %v", err)
+	}
+}
@@ -4,14 +4,10 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
-	"fastbrew/internal/httpclient"
 	"fmt"
-	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
-	"strconv"
-	"strings"
 	"sync"
 	"time"
 )
@@ -22,14 +18,29 @@ type OutdatedPackage struct {
 	NewVersion     string `json:"new_version"`
 	IsCask         bool   `json:"is_cask"`
 	IsTap          bool   `json:"is_tap"`
+	// RenamedTo is the formula's current name when it was installed under
+	// an old one; upgrading installs it under the new name.
+	RenamedTo string `json:"renamed_to,omitempty"`
+	// Held is set when Constraint rules out NewVersion; upgrades skip the
+	// package.
+	Held       bool   `json:"held,omitempty"`
+	Constraint string `json:"constraint,omitempty"`
 }
 
 const CaskAPIURL = "https://formulae.brew.sh/api/cask"
 
 // RemoteCask represents the full JSON response from formulae.brew.sh for casks
 type RemoteCask struct {
-	Token   string `json:"token"`
-	Version string `json:"version"`
+	Token       string `json:"token"`
+	Version     string `json:"version"`
+	AutoUpdates bool   `json:"auto_updates"`
+}
+
+// OutdatedOptions controls which packages GetOutdatedWithOptions reports.
+type OutdatedOptions struct {
+	// Greedy also reports casks that update themselves (auto_updates),
+	// which are skipped by default like `brew outdated`.
+	Greedy bool
 }
 
 // FetchCask gets metadata for a single cask
@@ -39,27 +50,20 @@ func (c *Client) FetchCask(name string) (*RemoteCask, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request for cask %s: %w", name, err)
-	}
-
-	httpClient := httpclient.Get()
-	resp, err := httpClient.Do(req)
+	data, status, err := c.fetchAPIJSON(ctx, url, "cask", name)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch cask %s: %w", name, err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode == 404 {
-		return nil, fmt.Errorf("cask %s not found on API", name)
+	if status == 404 {
+		return nil, kindErrorf(KindNotFound, "cask %s not found on API", name)
 	}
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("api returned status %d for cask %s", resp.StatusCode, name)
+	if status != 200 {
+		return nil, kindErrorf(KindNetwork, "api returned status %d for cask %s", status, name)
 	}
 
 	var ck RemoteCask
-	if err := json.NewDecoder(resp.Body).Decode(&ck); err != nil {
+	if err := json.Unmarshal(data, &ck); err != nil {
 		return nil, fmt.Errorf("failed to parse cask json for %s: %w", name, err)
 	}
 
@@ -68,7 +72,7 @@ func (c *Client) FetchCask(name string) (*RemoteCask, error) {
 
 func (c *Client) GetOutdatedForPackages(packages []string) ([]OutdatedPackage, error) {
 	reqMap := make(map[string]bool)
-	for _, pkg := range packages {
+	for _, pkg := range c.WithDependencies(packages) {
 		reqMap[pkg] = true
 	}
 
@@ -90,53 +94,34 @@ func (c *Client) GetOutdatedForPackages(packages []string) ([]OutdatedPackage, e
 		}
 	}
 
-	idx, err := c.LoadIndex()
+	latestVersion, err := c.latestVersionLookup()
 	if err != nil {
 		return nil, err
 	}
 
-	formulaVersions := make(map[string]string, len(idx.Formulae))
-	for _, f := range idx.Formulae {
-		formulaVersions[f.Name] = f.FullVersion()
-	}
-
-	caskVersions := make(map[string]string, len(idx.Casks))
-	for _, cask := range idx.Casks {
-		caskVersions[cask.Token] = cask.Version
-	}
-
 	var outdated []OutdatedPackage
 
 	for _, pkg := range targetPkgs {
 		installedVer := pkg.Version
 		if pkg.IsCask {
-			if latest, ok := caskVersions[pkg.Name]; ok && isOutdated(installedVer, latest) {
+			if latest, ok := c.caskVersion(pkg.Name); ok && isOutdated(installedVer, latest.Version) {
 				outdated = append(outdated, OutdatedPackage{
 					Name:           pkg.Name,
 					CurrentVersion: pkg.Version,
-					NewVersion:     latest,
+					NewVersion:     latest.Version,
 					IsCask:         true,
 				})
-			} else if !ok {
-				cask, err := c.FetchCask(pkg.Name)
-				if err == nil && isOutdated(installedVer, cask.Version) {
-					outdated = append(outdated, OutdatedPackage{
-						Name:           pkg.Name,
-						CurrentVersion: pkg.Version,
-						NewVersion:     cask.Version,
-						IsCask:         true,
-					})
-				}
 			}
 			continue
 		}
 
-		if latest, ok := formulaVersions[pkg.Name]; ok && isOutdated(installedVer, latest) {
+		if latest, ok := latestVersion(pkg.Name); ok && isOutdated(installedVer, latest.Version) {
 			outdated = append(outdated, OutdatedPackage{
 				Name:           pkg.Name,
 				CurrentVersion: pkg.Version,
-				NewVersion:     latest,
+				NewVersion:     latest.Version,
 				IsCask:         false,
+				RenamedTo:      renamedFormula(pkg.Name, latest),
 			})
 		} else if !ok {
 			// Try tap formulas first
@@ -149,7 +134,7 @@ func (c *Client) GetOutdatedForPackages(packages []string) ([]OutdatedPackage, e
 					IsTap:          true,
 				})
 			} else if !tapOk {
-				remote, err := c.FetchFormula(pkg.Name)
+				remote, err := c.FetchFormula(context.Background(), pkg.Name)
 				if err == nil && isOutdated(installedVer, remote.Versions.Stable) {
 					outdated = append(outdated, OutdatedPackage{
 						Name:           pkg.Name,
@@ -162,11 +147,46 @@ func (c *Client) GetOutdatedForPackages(packages []string) ([]OutdatedPackage, e
 		}
 	}
 
+	MarkHeld(outdated, c.Constraints)
 	return outdated, nil
 }
 
-// GetOutdated returns a list of outdated packages (formulae and casks)
+// caskVersion returns the newest version of an installed cask. The cask
+// index answers for every cask the API knows; only when it cannot be loaded
+// is the cask fetched from the API.
+func (c *Client) caskVersion(token string) (indexedVersion, bool) {
+	if _, err := c.caskIndex(); err == nil {
+		cask, ok := c.LookupCask(token)
+		if !ok {
+			return indexedVersion{}, false
+		}
+		return indexedVersion{Version: cask.Version, AutoUpdates: cask.AutoUpdates, Name: cask.Token}, true
+	}
+	cask, err := c.FetchCask(token)
+	if err != nil {
+		return indexedVersion{}, false
+	}
+	return indexedVersion{Version: cask.Version, AutoUpdates: cask.AutoUpdates, Name: cask.Token}, true
+}
+
+// renamedFormula returns the current name of a formula found in the index
+// under the old name name, or "".
+func renamedFormula(name string, latest indexedVersion) string {
+	if latest.Name == "" || latest.Name == name {
+		return ""
+	}
+	return latest.Name
+}
+
+// GetOutdated returns a list of outdated packages (formulae and casks).
+// Self-updating casks are skipped; use GetOutdatedWithOptions to include them.
 func (c *Client) GetOutdated() ([]OutdatedPackage, error) {
+	return c.GetOutdatedWithOptions(OutdatedOptions{})
+}
+
+// GetOutdatedWithOptions returns outdated formulae and casks filtered by opts.
+// Packages held back by a version constraint are included and marked Held.
+func (c *Client) GetOutdatedWithOptions(opts OutdatedOptions) ([]OutdatedPackage, error) {
 	// 1. Get installed packages
 	installed, err := c.ListInstalledNative()
 	if err != nil {
@@ -177,19 +197,15 @@ func (c *Client) GetOutdated() ([]OutdatedPackage, error) {
 		return []OutdatedPackage{}, nil
 	}
 
-	idx, err := c.LoadIndex()
+	latestVersion, err := c.latestVersionLookup()
 	if err != nil {
 		return nil, err
 	}
-
-	formulaVersions := make(map[string]string, len(idx.Formulae))
-	for _, f := range idx.Formulae {
-		formulaVersions[f.Name] = f.FullVersion()
-	}
-
-	caskVersions := make(map[string]string, len(idx.Casks))
-	for _, cask := range idx.Casks {
-		caskVersions[cask.Token] = cask.Version
+	installedFormulae := make(map[string]bool, len(installed))
+	for _, pkg := range installed {
+		if !pkg.IsCask {
+			installedFormulae[pkg.Name] = true
+		}
 	}
 
 	// 2. Check each package against the cached index (fast path)
@@ -199,28 +215,36 @@ func (c *Client) GetOutdated() ([]OutdatedPackage, error) {
 	for _, pkg := range installed {
 		installedVer := pkg.Version
 		if pkg.IsCask {
-			if latest, ok := caskVersions[pkg.Name]; ok {
-				if isOutdated(installedVer, latest) {
+			if latest, ok := c.caskVersion(pkg.Name); ok {
+				if latest.AutoUpdates && !opts.Greedy {
+					continue
+				}
+				if isOutdated(installedVer, latest.Version) {
 					outdated = append(outdated, OutdatedPackage{
 						Name:           pkg.Name,
 						CurrentVersion: pkg.Version,
-						NewVersion:     latest,
+						NewVersion:     latest.Version,
 						IsCask:         true,
 					})
 				}
-				continue
 			}
-			unknown = append(unknown, pkg)
 			continue
 		}
 
-		if latest, ok := formulaVersions[pkg.Name]; ok {
-			if isOutdated(installedVer, latest) {
+		if latest, ok := latestVersion(pkg.Name); ok {
+			renamedTo := renamedFormula(pkg.Name, latest)
+			if renamedTo != "" && installedFormulae[renamedTo] {
+				// The formula is installed under its new name too and is
+				// checked as that.
+				continue
+			}
+			if isOutdated(installedVer, latest.Version) {
 				outdated = append(outdated, OutdatedPackage{
 					Name:           pkg.Name,
 					CurrentVersion: pkg.Version,
-					NewVersion:     latest,
+					NewVersion:     latest.Version,
 					IsCask:         false,
+					RenamedTo:      renamedTo,
 				})
 			}
 			continue
@@ -229,9 +253,9 @@ func (c *Client) GetOutdated() ([]OutdatedPackage, error) {
 		unknown = append(unknown, pkg)
 	}
 
-	// 3. Fallback to remote lookups for packages not in the cached index
+	// 3. Fallback to remote lookups for formulae not in the cached index
 	if len(unknown) > 0 {
-		const maxWorkers = 8
+		maxWorkers := c.scheduler().Concurrency()
 		jobs := make(chan PackageInfo)
 		results := make(chan OutdatedPackage, len(unknown))
 		var wg sync.WaitGroup
@@ -240,19 +264,6 @@ func (c *Client) GetOutdated() ([]OutdatedPackage, error) {
 			defer wg.Done()
 			for pkg := range jobs {
 				installedVer := pkg.Version
-				if pkg.IsCask {
-					cask, err := c.FetchCask(pkg.Name)
-					if err == nil && isOutdated(installedVer, cask.Version) {
-						results <- OutdatedPackage{
-							Name:           pkg.Name,
-							CurrentVersion: pkg.Version,
-							NewVersion:     cask.Version,
-							IsCask:         true,
-						}
-					}
-					continue
-				}
-
 				// Try tap formulas first
 				if tapVer, tapOk := c.GetTapFormulaVersion(pkg.Name); tapOk {
 					if isOutdated(installedVer, tapVer) {
@@ -267,7 +278,7 @@ func (c *Client) GetOutdated() ([]OutdatedPackage, error) {
 					continue
 				}
 
-				remote, err := c.FetchFormula(pkg.Name)
+				remote, err := c.FetchFormula(context.Background(), pkg.Name)
 				if err == nil && isOutdated(installedVer, remote.Versions.Stable) {
 					results <- OutdatedPackage{
 						Name:           pkg.Name,
@@ -296,118 +307,48 @@ func (c *Client) GetOutdated() ([]OutdatedPackage, error) {
 		}
 	}
 
+	MarkHeld(outdated, c.Constraints)
 	return outdated, nil
 }
 
-// stripRevision removes revision suffixes like "_1" from version strings.
-func stripRevision(version string) string {
-	if idx := strings.Index(version, "_"); idx != -1 {
-		return version[:idx]
-	}
-	return version
-}
-
-// extractRevision extracts the revision number from a version string.
-// e.g. "15.2.0_1" returns 1, "15.2.0" returns 0.
-func extractRevision(version string) int {
-	if idx := strings.LastIndex(version, "_"); idx != -1 {
-		rev, err := strconv.Atoi(version[idx+1:])
-		if err == nil {
-			return rev
-		}
-	}
-	return 0
-}
-
-// versionCompare compares two version strings semantically, including revisions.
-// Returns: -1 if v1 < v2, 0 if v1 == v2, 1 if v1 > v2
-func versionCompare(v1, v2 string) int {
-	base1 := stripRevision(v1)
-	base2 := stripRevision(v2)
-
-	parts1 := strings.Split(base1, ".")
-	parts2 := strings.Split(base2, ".")
-
-	maxLen := len(parts1)
-	if len(parts2) > maxLen {
-		maxLen = len(parts2)
-	}
-
-	for i := 0; i < maxLen; i++ {
-		p1 := 0
-		p2 := 0
-
-		if i < len(parts1) {
-			p1, _ = strconv.Atoi(parts1[i])
-		}
-		if i < len(parts2) {
-			p2, _ = strconv.Atoi(parts2[i])
-		}
-
-		if p1 < p2 {
-			return -1
-		}
-		if p1 > p2 {
-			return 1
-		}
-	}
-
-	// Base versions are equal, compare revisions
-	rev1 := extractRevision(v1)
-	rev2 := extractRevision(v2)
-	if rev1 < rev2 {
-		return -1
-	}
-	if rev1 > rev2 {
-		return 1
-	}
-
-	return 0
-}
-
-// isOutdated checks if installed version is outdated compared to new version
-func isOutdated(installed, latest string) bool {
-	return versionCompare(installed, latest) < 0
-}
-
 // versionLineRegex matches lines like: version "0.46.2"
 var versionLineRegex = regexp.MustCompile(`^\s*version\s+"([^"]+)"`)
 
 // GetTapFormulaVersion scans all installed taps for a formula .rb file
 // and extracts the version from it.
 func (c *Client) GetTapFormulaVersion(name string) (string, bool) {
-	detectHomebrewPaths()
-
-	entries, err := os.ReadDir(homebrewTapsDir)
-	if err != nil {
-		return "", false
-	}
-
-	for _, userEntry := range entries {
-		if !userEntry.IsDir() {
-			continue
-		}
-		userDir := filepath.Join(homebrewTapsDir, userEntry.Name())
-		repoEntries, err := os.ReadDir(userDir)
+	for _, root := range tapRoots() {
+		entries, err := os.ReadDir(root)
 		if err != nil {
 			continue
 		}
 
-		for _, repoEntry := range repoEntries {
-			if !repoEntry.IsDir() {
+		for _, userEntry := range entries {
+			if !userEntry.IsDir() {
 				continue
 			}
-			tapPath := filepath.Join(userDir, repoEntry.Name())
-
-			// Check Formula/ subdirectory and root for .rb files
-			candidatePaths := []string{
-				filepath.Join(tapPath, "Formula", name+".rb"),
-				filepath.Join(tapPath, name+".rb"),
+			userDir := filepath.Join(root, userEntry.Name())
+			repoEntries, err := os.ReadDir(userDir)
+			if err != nil {
+				continue
 			}
 
-			for _, rbPath := range candidatePaths {
-				if ver, ok := parseRubyFormulaVersion(rbPath); ok {
-					return ver, true
+			for _, repoEntry := range repoEntries {
+				if !repoEntry.IsDir() {
+					continue
+				}
+				tapPath := filepath.Join(userDir, repoEntry.Name())
+
+				// Check Formula/ subdirectory and root for .rb files
+				candidatePaths := []string{
+					filepath.Join(tapPath, "Formula", name+".rb"),
+					filepath.Join(tapPath, name+".rb"),
+				}
+
+				for _, rbPath := range candidatePaths {
+					if ver, ok := parseRubyFormulaVersion(rbPath); ok {
+						return ver, true
+					}
 				}
 			}
 		}
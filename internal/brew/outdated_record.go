@@ -0,0 +1,107 @@
+package brew
+
+import (
+	"encoding/json"
+	"fastbrew/internal/atomicfile"
+	"fastbrew/internal/paths"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// OutdatedRecordMaxAge is how long a recorded outdated count is shown as
+// a hint before it is considered stale.
+const OutdatedRecordMaxAge = 48 * time.Hour
+
+// OutdatedRecord is the outdated check `fastbrew update --record-outdated`
+// saves, so other commands can mention pending upgrades without checking
+// themselves.
+type OutdatedRecord struct {
+	CheckedAt time.Time `json:"checked_at"`
+	// Packages are the outdated packages upgrade would change; pinned and
+	// held ones are left out.
+	Packages []string `json:"packages"`
+}
+
+// Fresh reports whether r was recorded recently enough to show.
+func (r *OutdatedRecord) Fresh(now time.Time) bool {
+	return now.Sub(r.CheckedAt) < OutdatedRecordMaxAge
+}
+
+// OutdatedRecordPath returns the file holding the recorded outdated check.
+func OutdatedRecordPath() string {
+	return filepath.Join(paths.StateDir(), "outdated.json")
+}
+
+// LoadOutdatedRecord returns the recorded outdated check, or nil when none
+// is recorded.
+func LoadOutdatedRecord() (*OutdatedRecord, error) {
+	data, err := os.ReadFile(OutdatedRecordPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var record OutdatedRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func saveOutdatedRecord(record *OutdatedRecord) error {
+	path := OutdatedRecordPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, data, 0644)
+}
+
+// RecordOutdated checks for outdated packages and saves the result.
+func (c *Client) RecordOutdated() (*OutdatedRecord, error) {
+	outdated, err := c.GetOutdated()
+	if err != nil {
+		return nil, err
+	}
+	pinned, err := LoadPinned()
+	if err != nil {
+		return nil, err
+	}
+	record := &OutdatedRecord{CheckedAt: time.Now(), Packages: []string{}}
+	for _, pkg := range outdated {
+		if !pkg.Held && !pinned[pkg.Name] {
+			record.Packages = append(record.Packages, pkg.Name)
+		}
+	}
+	return record, saveOutdatedRecord(record)
+}
+
+// ForgetOutdated drops names from the recorded outdated check once they
+// were upgraded or uninstalled. With no names the record is removed.
+func ForgetOutdated(names ...string) error {
+	if len(names) == 0 {
+		err := os.Remove(OutdatedRecordPath())
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	record, err := LoadOutdatedRecord()
+	if err != nil || record == nil {
+		return err
+	}
+	kept := slices.DeleteFunc(record.Packages, func(name string) bool {
+		return slices.Contains(names, name)
+	})
+	if len(kept) == len(record.Packages) {
+		return nil
+	}
+	record.Packages = kept
+	return saveOutdatedRecord(record)
+}
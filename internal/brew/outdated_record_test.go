@@ -0,0 +1,43 @@
+package brew
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestForgetOutdated(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	if record, err := LoadOutdatedRecord(); err != nil || record != nil {
+		t.Fatalf("expected no record, got %v, %v", record, err)
+	}
+	if err := ForgetOutdated("jq"); err != nil {
+		t.Fatalf("forgetting without a record failed: %v", err)
+	}
+
+	checked := time.Now().Add(-time.Hour)
+	if err := saveOutdatedRecord(&OutdatedRecord{CheckedAt: checked, Packages: []string{"jq", "wget", "ripgrep"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ForgetOutdated("wget", "curl"); err != nil {
+		t.Fatalf("ForgetOutdated failed: %v", err)
+	}
+	record, err := LoadOutdatedRecord()
+	if err != nil || record == nil {
+		t.Fatalf("expected a record, got %v, %v", record, err)
+	}
+	if !reflect.DeepEqual(record.Packages, []string{"jq", "ripgrep"}) {
+		t.Errorf("unexpected packages %v", record.Packages)
+	}
+	if !record.Fresh(time.Now()) || record.Fresh(checked.Add(OutdatedRecordMaxAge)) {
+		t.Error("record should be fresh for OutdatedRecordMaxAge")
+	}
+
+	if err := ForgetOutdated(); err != nil {
+		t.Fatalf("ForgetOutdated failed: %v", err)
+	}
+	if record, _ := LoadOutdatedRecord(); record != nil {
+		t.Error("expected the record to be removed")
+	}
+}
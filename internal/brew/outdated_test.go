@@ -1,6 +1,8 @@
 package brew
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -56,3 +58,55 @@ func TestOutdatedPackageSort(t *testing.T) {
 		t.Errorf("Expected zebra third, got %s", sorted[2].Name)
 	}
 }
+
+func TestGetOutdatedWithOptionsGreedy(t *testing.T) {
+	prefix := t.TempDir()
+	cellar := filepath.Join(prefix, "Cellar")
+	for _, dir := range []string{
+		filepath.Join(cellar, "wget", "1.21.1"),
+		filepath.Join(prefix, "Caskroom", "firefox", "120.0"),
+		filepath.Join(prefix, "Caskroom", "iterm2", "3.4.15"),
+	} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client := &Client{Prefix: prefix, Cellar: cellar}
+	client.index = &Index{
+		Formulae: []Formula{{Name: "wget", Versions: FormulaVersions{Stable: "1.24.5"}}},
+		Casks: []Cask{
+			{Token: "firefox", Version: "128.0", AutoUpdates: true},
+			{Token: "iterm2", Version: "3.5.0"},
+		},
+	}
+	client.indexLoaded = true
+
+	names := func(pkgs []OutdatedPackage) map[string]bool {
+		set := make(map[string]bool, len(pkgs))
+		for _, pkg := range pkgs {
+			set[pkg.Name] = true
+		}
+		return set
+	}
+
+	outdated, err := client.GetOutdated()
+	if err != nil {
+		t.Fatalf("GetOutdated failed: %v", err)
+	}
+	got := names(outdated)
+	if !got["wget"] || !got["iterm2"] {
+		t.Errorf("Expected wget and iterm2 to be outdated, got %v", outdated)
+	}
+	if got["firefox"] {
+		t.Error("Expected self-updating cask to be skipped without greedy")
+	}
+
+	outdated, err = client.GetOutdatedWithOptions(OutdatedOptions{Greedy: true})
+	if err != nil {
+		t.Fatalf("GetOutdatedWithOptions failed: %v", err)
+	}
+	if !names(outdated)["firefox"] {
+		t.Errorf("Expected greedy mode to include firefox, got %v", outdated)
+	}
+}
@@ -0,0 +1,22 @@
+package brew
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+func (c *Client) out() io.Writer {
+	if c.Out == nil {
+		return os.Stdout
+	}
+	return c.Out
+}
+
+func (c *Client) printf(format string, args ...interface{}) {
+	fmt.Fprintf(c.out(), format, args...)
+}
+
+func (c *Client) println(args ...interface{}) {
+	fmt.Fprintln(c.out(), args...)
+}
@@ -0,0 +1,49 @@
+package brew
+
+import (
+	"fastbrew/internal/resume"
+	"os"
+	"sort"
+	"time"
+)
+
+// PartialDownloads lists the interrupted downloads in the cache, oldest
+// first. The next download of the same file resumes from them.
+func (c *Client) PartialDownloads() ([]*resume.PartialDownload, error) {
+	cacheDir, err := c.GetCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	downloads, err := resume.NewResumeManager(cacheDir).List()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(downloads, func(i, j int) bool {
+		return downloads[i].UpdatedAt.Before(downloads[j].UpdatedAt)
+	})
+	return downloads, nil
+}
+
+// ClearPartialDownloads removes the partial downloads not updated within
+// olderThan, or all of them when olderThan is zero, with their resume
+// metadata. It returns the ones it removed.
+func (c *Client) ClearPartialDownloads(olderThan time.Duration) ([]*resume.PartialDownload, error) {
+	downloads, err := c.PartialDownloads()
+	if err != nil {
+		return nil, err
+	}
+	var cleared []*resume.PartialDownload
+	for _, pd := range downloads {
+		if olderThan > 0 && time.Since(pd.UpdatedAt) < olderThan {
+			continue
+		}
+		if err := os.Remove(pd.LocalPath); err != nil && !os.IsNotExist(err) {
+			return cleared, err
+		}
+		if err := os.Remove(pd.MetadataPath()); err != nil && !os.IsNotExist(err) {
+			return cleared, err
+		}
+		cleared = append(cleared, pd)
+	}
+	return cleared, nil
+}
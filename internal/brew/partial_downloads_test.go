@@ -0,0 +1,92 @@
+package brew
+
+import (
+	"encoding/json"
+	"fastbrew/internal/resume"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writePartial records a partial download of name in cacheDir, last
+// updated age ago.
+func writePartial(t *testing.T, cacheDir, name string, age time.Duration, withFile bool) string {
+	t.Helper()
+	path := filepath.Join(cacheDir, name)
+	pd := resume.PartialDownload{
+		URL:             "https://ghcr.io/v2/homebrew/core/" + name + "/blobs/sha256:abc",
+		LocalPath:       path,
+		TotalSize:       100,
+		DownloadedBytes: 40,
+		State:           resume.StateFailed,
+		UpdatedAt:       time.Now().Add(-age),
+	}
+	data, _ := json.Marshal(pd)
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(pd.MetadataPath(), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if withFile {
+		if err := os.WriteFile(path, make([]byte, 40), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestClearPartialDownloads(t *testing.T) {
+	client, cacheDir := newCleanupTestClient(t)
+	old := writePartial(t, cacheDir, "wget.bottle", 10*24*time.Hour, true)
+	recent := writePartial(t, cacheDir, "jq.bottle", time.Hour, true)
+
+	downloads, err := client.PartialDownloads()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(downloads) != 2 || downloads[0].LocalPath != old {
+		t.Fatalf("PartialDownloads() = %v, want the oldest first", downloads)
+	}
+
+	cleared, err := client.ClearPartialDownloads(7 * 24 * time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cleared) != 1 || cleared[0].LocalPath != old {
+		t.Fatalf("ClearPartialDownloads() = %v, want only the old download", cleared)
+	}
+	for _, path := range []string{old, old + resume.ResumeMetadataSuffix} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", path)
+		}
+	}
+	if _, err := os.Stat(recent); err != nil {
+		t.Errorf("Expected the recent partial download to be kept: %v", err)
+	}
+
+	if cleared, _ := client.ClearPartialDownloads(0); len(cleared) != 1 {
+		t.Errorf("Expected clearing without an age to remove the rest, got %v", cleared)
+	}
+}
+
+func TestDoctorPartialDownloadsFindsStaleMetadata(t *testing.T) {
+	client, cacheDir := newCleanupTestClient(t)
+	writePartial(t, cacheDir, "wget.bottle", time.Hour, true)
+	missing := writePartial(t, cacheDir, "jq.bottle", time.Hour, false)
+
+	doctor := NewDoctor(client, false)
+	result := doctor.checkPartialDownloads()
+	if result.Status != StatusWarning || len(result.Details) != 1 || result.Details[0] != missing+resume.ResumeMetadataSuffix {
+		t.Fatalf("checkPartialDownloads() = %+v", result)
+	}
+
+	doctor.Fix = true
+	if result := doctor.checkPartialDownloads(); !result.Fixed {
+		t.Fatalf("Expected --fix to remove the stale metadata, got %+v", result)
+	}
+	if result := doctor.checkPartialDownloads(); result.Status != StatusOK {
+		t.Errorf("Expected the check to pass after the fix, got %+v", result)
+	}
+}
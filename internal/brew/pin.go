@@ -0,0 +1,66 @@
+package brew
+
+import (
+	"bufio"
+	"fastbrew/internal/atomicfile"
+	"fastbrew/internal/paths"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// PinnedPath returns the file listing pinned packages, one per line.
+func PinnedPath() string {
+	return filepath.Join(paths.StateDir(), "pinned")
+}
+
+// LoadPinned returns the pinned packages. A missing file means none are
+// pinned.
+func LoadPinned() (map[string]bool, error) {
+	pinned := make(map[string]bool)
+
+	file, err := os.Open(PinnedPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return pinned, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		name := strings.TrimSpace(scanner.Text())
+		if name != "" && !strings.HasPrefix(name, "#") {
+			pinned[name] = true
+		}
+	}
+	return pinned, scanner.Err()
+}
+
+// SavePinned replaces the pinned package list.
+func SavePinned(pinned map[string]bool) error {
+	path := PinnedPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(pinned))
+	for name, ok := range pinned {
+		if ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return atomicfile.Write(path, 0644, func(w io.Writer) error {
+		for _, name := range names {
+			if _, err := fmt.Fprintln(w, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -1,8 +1,8 @@
 package brew
 
 import (
+	"fastbrew/internal/cmdrunner"
 	"fmt"
-	"os/exec"
 	"runtime"
 	"strings"
 	"sync"
@@ -17,13 +17,19 @@ var (
 // GetPlatform returns the Homebrew-style platform string (e.g., "x86_64_linux", "arm64_sonoma").
 // The result is cached after first call to avoid repeated subprocess spawning.
 func GetPlatform() (string, error) {
+	return getPlatform(&cmdrunner.DefaultCommandRunner{})
+}
+
+// getPlatform is GetPlatform running sw_vers through runner, if it is the
+// first call.
+func getPlatform(runner cmdrunner.CommandRunner) (string, error) {
 	cachedPlatformOnce.Do(func() {
-		cachedPlatform, cachedPlatformErr = resolvePlatform()
+		cachedPlatform, cachedPlatformErr = resolvePlatform(runner)
 	})
 	return cachedPlatform, cachedPlatformErr
 }
 
-func resolvePlatform() (string, error) {
+func resolvePlatform(runner cmdrunner.CommandRunner) (string, error) {
 	osName := runtime.GOOS
 	arch := runtime.GOARCH
 
@@ -44,7 +50,7 @@ func resolvePlatform() (string, error) {
 		}
 
 		// Get macOS version name
-		version, err := getMacOSVersion()
+		version, err := getMacOSVersion(runner)
 		if err != nil {
 			return "", err
 		}
@@ -58,9 +64,8 @@ func resolvePlatform() (string, error) {
 	return "", fmt.Errorf("unsupported OS: %s", osName)
 }
 
-func getMacOSVersion() (string, error) {
-	cmd := exec.Command("sw_vers", "-productVersion")
-	out, err := cmd.Output()
+func getMacOSVersion(runner cmdrunner.CommandRunner) (string, error) {
+	out, err := runner.Run("sw_vers", "-productVersion")
 	if err != nil {
 		return "", err
 	}
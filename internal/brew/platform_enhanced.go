@@ -2,6 +2,7 @@ package brew
 
 import (
 	"bufio"
+	"fastbrew/internal/cmdrunner"
 	"fmt"
 	"os"
 	"runtime"
@@ -39,7 +40,7 @@ func DetectPlatform() (*PlatformInfo, error) {
 	info.SupportsServices = !info.IsContainer
 
 	if info.OS == "darwin" {
-		info.Version, _ = getMacOSVersion()
+		info.Version, _ = getMacOSVersion(&cmdrunner.DefaultCommandRunner{})
 	} else if info.OS == "linux" {
 		info.Version = detectLinuxDistro()
 	}
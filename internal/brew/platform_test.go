@@ -0,0 +1,34 @@
+package brew
+
+import (
+	"errors"
+	"fastbrew/internal/cmdrunner"
+	"testing"
+)
+
+func TestGetMacOSVersion(t *testing.T) {
+	tests := []struct {
+		output  string
+		want    string
+		wantErr bool
+	}{
+		{"15.3.1\n", "sequoia", false},
+		{"11.7\n", "big_sur", false},
+		{"27.0\n", "tahoe", false},
+		{"10.15.7\n", "", true},
+	}
+	for _, tt := range tests {
+		runner := cmdrunner.NewMock()
+		runner.SetOutput("sw_vers -productVersion", []byte(tt.output))
+		got, err := getMacOSVersion(runner)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("getMacOSVersion(%q) = %q, %v; want %q", tt.output, got, err, tt.want)
+		}
+	}
+
+	runner := cmdrunner.NewMock()
+	runner.SetError("sw_vers -productVersion", errors.New("not found"))
+	if _, err := getMacOSVersion(runner); err == nil {
+		t.Error("expected sw_vers failing to be an error")
+	}
+}
@@ -1,9 +1,16 @@
 package brew
 
 import (
+	"bytes"
+	"encoding/binary"
 	"encoding/gob"
+	"fastbrew/internal/atomicfile"
 	"fmt"
-	"os"
+	"io"
+	"maps"
+	"runtime"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 
@@ -13,29 +20,66 @@ import (
 const (
 	minPrefixLength    = 2
 	maxPrefixLength    = 3
-	prefixIndexVersion = 1
+	prefixIndexVersion = 2
+
+	// prefixIndexFileName is the on-disk index in the cache directory.
+	prefixIndexFileName = "prefix_index.idx"
+	// legacyPrefixIndexFileName is the gob file written by version 1.
+	legacyPrefixIndexFileName = "prefix_index.gob"
+)
+
+// Grams are keyed by kind and bytes packed into a uint32, so the saved
+// table can be binary searched in place. Name grams are 2 and 3 bytes long
+// (a 2-byte gram has a zero last byte); description grams are 3 bytes.
+const (
+	gramName = 'n'
+	gramDesc = 'd'
 )
 
+var prefixIndexMagic = [4]byte{'F', 'B', 'P', 'I'}
+
+// PrefixIndex is an n-gram index over package names and descriptions.
+// Each gram maps to the ascending positions of the items containing it, so
+// a substring query intersects the lists of its trigrams and only checks
+// the survivors.
 type PrefixIndex struct {
-	prefixes   map[string][]int
+	grams      map[uint32][]uint32
+	mapped     *mappedGrams
 	items      []SearchItem
 	version    int
 	totalItems int
 	mu         sync.RWMutex
 }
 
-type prefixIndexData struct {
-	Prefixes   map[string][]int
-	Items      []SearchItem
-	Version    int
-	TotalItems int
-}
-
 func NewPrefixIndex() *PrefixIndex {
 	return &PrefixIndex{
-		prefixes: make(map[string][]int),
-		version:  prefixIndexVersion,
+		grams:   make(map[uint32][]uint32),
+		version: prefixIndexVersion,
+	}
+}
+
+func gramKey(kind byte, gram string) uint32 {
+	key := uint32(kind)<<24 | uint32(gram[0])<<16 | uint32(gram[1])<<8
+	if len(gram) > 2 {
+		key |= uint32(gram[2])
+	}
+	return key
+}
+
+// itemGrams returns the distinct gram keys of item.
+func itemGrams(item SearchItem) []uint32 {
+	seen := make(map[uint32]bool)
+	name := strings.ToLower(item.Name)
+	for length := minPrefixLength; length <= maxPrefixLength; length++ {
+		for i := 0; i+length <= len(name); i++ {
+			seen[gramKey(gramName, name[i:i+length])] = true
+		}
+	}
+	desc := strings.ToLower(item.Desc)
+	for i := 0; i+3 <= len(desc); i++ {
+		seen[gramKey(gramDesc, desc[i:i+3])] = true
 	}
+	return slices.Collect(maps.Keys(seen))
 }
 
 func (pi *PrefixIndex) BuildIndex(items []SearchItem) error {
@@ -44,35 +88,153 @@ func (pi *PrefixIndex) BuildIndex(items []SearchItem) error {
 
 	pi.items = items
 	pi.totalItems = len(items)
-	pi.prefixes = make(map[string][]int)
+	pi.mapped = nil
+	pi.grams = make(map[uint32][]uint32)
+	pi.addGrams(0)
+	return nil
+}
 
-	for idx, item := range items {
-		name := strings.ToLower(item.Name)
+// addGrams indexes the items from position start on. Their positions are
+// higher than any already indexed, so the lists stay sorted.
+func (pi *PrefixIndex) addGrams(start int) {
+	for idx := start; idx < len(pi.items); idx++ {
+		for _, key := range itemGrams(pi.items[idx]) {
+			pi.grams[key] = append(pi.grams[key], uint32(idx))
+		}
+	}
+}
 
-		for length := minPrefixLength; length <= maxPrefixLength && length <= len(name); length++ {
-			for i := 0; i <= len(name)-length; i++ {
-				prefix := name[i : i+length]
-				pi.prefixes[prefix] = append(pi.prefixes[prefix], idx)
-			}
+// Update brings the index in line with items, re-reading only the items
+// that were added or changed. Unchanged items keep their grams, renumbered
+// past any removed ones; changed and new items are appended. It returns
+// whether anything changed.
+func (pi *PrefixIndex) Update(items []SearchItem) bool {
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+
+	type itemKey struct {
+		name   string
+		isCask bool
+	}
+	wanted := make(map[itemKey]SearchItem, len(items))
+	for _, item := range items {
+		wanted[itemKey{item.Name, item.IsCask}] = item
+	}
+
+	remap := make([]int64, len(pi.items))
+	kept := make([]SearchItem, 0, len(items))
+	keptKeys := make(map[itemKey]bool, len(items))
+	for i, old := range pi.items {
+		key := itemKey{old.Name, old.IsCask}
+		if item, ok := wanted[key]; ok && !keptKeys[key] && sameSearchItem(old, item) {
+			remap[i] = int64(len(kept))
+			kept = append(kept, old)
+			keptKeys[key] = true
+		} else {
+			remap[i] = -1
 		}
 	}
+	if len(kept) == len(pi.items) && len(kept) == len(items) {
+		return false
+	}
 
-	for prefix := range pi.prefixes {
-		indices := pi.prefixes[prefix]
-		seen := make(map[int]bool, len(indices))
-		unique := make([]int, 0, len(indices))
+	grams := pi.allGrams()
+	for key, indices := range grams {
+		renumbered := indices[:0]
 		for _, idx := range indices {
-			if !seen[idx] {
-				seen[idx] = true
-				unique = append(unique, idx)
+			if n := remap[idx]; n >= 0 {
+				renumbered = append(renumbered, uint32(n))
 			}
 		}
-		pi.prefixes[prefix] = unique
+		if len(renumbered) == 0 {
+			delete(grams, key)
+		} else {
+			grams[key] = renumbered
+		}
 	}
 
-	return nil
+	start := len(kept)
+	for _, item := range items {
+		if !keptKeys[itemKey{item.Name, item.IsCask}] {
+			kept = append(kept, item)
+		}
+	}
+	pi.items = kept
+	pi.totalItems = len(kept)
+	pi.grams = grams
+	pi.mapped = nil
+	pi.addGrams(start)
+	return true
+}
+
+func sameSearchItem(a, b SearchItem) bool {
+	return a.Desc == b.Desc && slices.Equal(a.Aliases, b.Aliases)
+}
+
+// allGrams returns the gram lists as a map the caller may modify, reading
+// them out of the mapped file if the index was loaded.
+func (pi *PrefixIndex) allGrams() map[uint32][]uint32 {
+	if pi.mapped == nil {
+		return pi.grams
+	}
+	grams := make(map[uint32][]uint32, pi.mapped.len())
+	for i := range pi.mapped.len() {
+		key, indices := pi.mapped.entry(i)
+		grams[key] = indices
+	}
+	return grams
+}
+
+// lookup returns the positions of the items containing the gram.
+func (pi *PrefixIndex) lookup(key uint32) []uint32 {
+	if pi.mapped != nil {
+		return pi.mapped.lookup(key)
+	}
+	return pi.grams[key]
+}
+
+// candidates returns the positions of the items whose name (kind
+// gramName) or description (gramDesc) may contain s, which must be at
+// least minPrefixLength bytes long.
+func (pi *PrefixIndex) candidates(kind byte, s string) []uint32 {
+	if len(s) < 3 {
+		return pi.lookup(gramKey(kind, s))
+	}
+	var result []uint32
+	for i := 0; i+3 <= len(s); i++ {
+		indices := pi.lookup(gramKey(kind, s[i:i+3]))
+		if i == 0 {
+			result = slices.Clone(indices)
+		} else {
+			result = intersectSorted(result, indices)
+		}
+		if len(result) == 0 {
+			return nil
+		}
+	}
+	return result
+}
+
+// intersectSorted keeps the entries of a that are also in b, reusing a.
+func intersectSorted(a, b []uint32) []uint32 {
+	out := a[:0]
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
 }
 
+// SearchPrefix returns the items whose name contains prefix, those that
+// start with it first. A prefix shorter than two bytes matches everything.
 func (pi *PrefixIndex) SearchPrefix(prefix string) []SearchItem {
 	pi.mu.RLock()
 	defer pi.mu.RUnlock()
@@ -82,17 +244,62 @@ func (pi *PrefixIndex) SearchPrefix(prefix string) []SearchItem {
 	}
 
 	prefix = strings.ToLower(prefix)
+	var starts, contains []SearchItem
+	for _, idx := range pi.candidates(gramName, prefix) {
+		item := pi.items[idx]
+		name := strings.ToLower(item.Name)
+		switch {
+		case strings.HasPrefix(name, prefix):
+			starts = append(starts, item)
+		case strings.Contains(name, prefix):
+			contains = append(contains, item)
+		}
+	}
+	return append(starts, contains...)
+}
+
+// SearchDesc returns the items whose description contains every word of
+// query. Words of three bytes or more narrow the candidates through the
+// index; shorter ones are only checked against the survivors.
+func (pi *PrefixIndex) SearchDesc(query string) []SearchItem {
+	pi.mu.RLock()
+	defer pi.mu.RUnlock()
+
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		return nil
+	}
 
-	if len(prefix) > maxPrefixLength {
-		searchPrefix := prefix[:maxPrefixLength]
-		candidates := pi.prefixes[searchPrefix]
-		return pi.filterByPrefix(candidates, prefix)
+	var indices []uint32
+	narrowed := false
+	for _, w := range words {
+		if len(w) < 3 {
+			continue
+		}
+		if !narrowed {
+			indices, narrowed = pi.candidates(gramDesc, w), true
+		} else {
+			indices = intersectSorted(indices, pi.candidates(gramDesc, w))
+		}
+		if len(indices) == 0 {
+			return nil
+		}
 	}
 
-	indices := pi.prefixes[prefix]
-	result := make([]SearchItem, len(indices))
-	for i, idx := range indices {
-		result[i] = pi.items[idx]
+	var result []SearchItem
+	check := func(item SearchItem) {
+		if descMatches(item.Desc, words) {
+			result = append(result, item)
+		}
+	}
+	if !narrowed {
+		for _, item := range pi.items {
+			check(item)
+		}
+		return result
+	}
+	for _, idx := range indices {
+		check(pi.items[idx])
 	}
 	return result
 }
@@ -111,7 +318,7 @@ func (pi *PrefixIndex) SearchFuzzy(query string) []fuzzy.Match {
 		searchPrefix = searchPrefix[:maxPrefixLength]
 	}
 
-	candidateIndices := pi.prefixes[searchPrefix]
+	candidateIndices := pi.candidates(gramName, searchPrefix)
 	if len(candidateIndices) == 0 {
 		return nil
 	}
@@ -125,7 +332,7 @@ func (pi *PrefixIndex) SearchFuzzy(query string) []fuzzy.Match {
 	matches := fuzzy.FindFrom(query, source)
 
 	for i := range matches {
-		matches[i].Index = candidateIndices[matches[i].Index]
+		matches[i].Index = int(candidateIndices[matches[i].Index])
 	}
 
 	return matches
@@ -143,66 +350,107 @@ func (pi *PrefixIndex) getAllItems() []SearchItem {
 	return result
 }
 
-func (pi *PrefixIndex) filterByPrefix(indices []int, prefix string) []SearchItem {
-	result := make([]SearchItem, 0, len(indices))
-	for _, idx := range indices {
-		item := pi.items[idx]
-		if strings.HasPrefix(strings.ToLower(item.Name), prefix) ||
-			strings.Contains(strings.ToLower(item.Name), prefix) {
-			result = append(result, item)
-		}
-	}
-	return result
-}
-
+// Save writes the index in a form Load can map into memory: a header, the
+// gob-encoded items, the gram table sorted by key and the position lists,
+// all little-endian.
 func (pi *PrefixIndex) Save(path string) error {
 	pi.mu.RLock()
 	defer pi.mu.RUnlock()
 
-	data := prefixIndexData{
-		Prefixes:   pi.prefixes,
-		Items:      pi.items,
-		Version:    pi.version,
-		TotalItems: pi.totalItems,
+	var items bytes.Buffer
+	if err := gob.NewEncoder(&items).Encode(pi.items); err != nil {
+		return fmt.Errorf("failed to encode prefix index items: %w", err)
+	}
+	for items.Len()%4 != 0 {
+		items.WriteByte(0)
 	}
 
-	file, err := os.Create(path)
-	if err != nil {
-		return fmt.Errorf("failed to create prefix index file: %w", err)
+	grams := pi.allGrams()
+	keys := slices.Sorted(maps.Keys(grams))
+	postings := 0
+	for _, key := range keys {
+		postings += len(grams[key])
 	}
-	defer file.Close()
 
-	if err := gob.NewEncoder(file).Encode(&data); err != nil {
-		return fmt.Errorf("failed to encode prefix index: %w", err)
+	err := atomicfile.Write(path, 0644, func(w io.Writer) error {
+		header := []uint32{prefixIndexVersion, uint32(items.Len()), uint32(len(keys)), uint32(postings)}
+		if _, err := w.Write(prefixIndexMagic[:]); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+			return err
+		}
+		if _, err := w.Write(items.Bytes()); err != nil {
+			return err
+		}
+		table := make([]uint32, 0, 3*len(keys))
+		offset := 0
+		for _, key := range keys {
+			table = append(table, key, uint32(offset), uint32(len(grams[key])))
+			offset += len(grams[key])
+		}
+		if err := binary.Write(w, binary.LittleEndian, table); err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := binary.Write(w, binary.LittleEndian, grams[key]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write prefix index: %w", err)
 	}
 
 	return nil
 }
 
+// Load maps the index saved at path. Only the items are decoded; gram
+// lists are read from the mapping as queries need them.
 func (pi *PrefixIndex) Load(path string) error {
 	pi.mu.Lock()
 	defer pi.mu.Unlock()
 
-	file, err := os.Open(path)
+	data, unmap, err := mapFile(path)
 	if err != nil {
 		return fmt.Errorf("failed to open prefix index file: %w", err)
 	}
-	defer file.Close()
 
-	var data prefixIndexData
-	if err := gob.NewDecoder(file).Decode(&data); err != nil {
-		return fmt.Errorf("failed to decode prefix index: %w", err)
+	const headerSize = 20
+	if len(data) < headerSize || !bytes.Equal(data[:4], prefixIndexMagic[:]) {
+		unmap()
+		return fmt.Errorf("failed to decode prefix index: not a prefix index file")
+	}
+	version := int(binary.LittleEndian.Uint32(data[4:]))
+	if version != prefixIndexVersion {
+		unmap()
+		return fmt.Errorf("prefix index version mismatch: got %d, expected %d", version, prefixIndexVersion)
+	}
+	itemsLen := int(binary.LittleEndian.Uint32(data[8:]))
+	gramCount := int(binary.LittleEndian.Uint32(data[12:]))
+	postings := int(binary.LittleEndian.Uint32(data[16:]))
+	tableStart := headerSize + itemsLen
+	postingsStart := tableStart + 12*gramCount
+	if itemsLen%4 != 0 || postingsStart+4*postings != len(data) {
+		unmap()
+		return fmt.Errorf("failed to decode prefix index: truncated file")
 	}
 
-	if data.Version != prefixIndexVersion {
-		return fmt.Errorf("prefix index version mismatch: got %d, expected %d", data.Version, prefixIndexVersion)
+	var items []SearchItem
+	if err := gob.NewDecoder(bytes.NewReader(data[headerSize:tableStart])).Decode(&items); err != nil {
+		unmap()
+		return fmt.Errorf("failed to decode prefix index: %w", err)
 	}
 
-	pi.prefixes = data.Prefixes
-	pi.items = data.Items
-	pi.version = data.Version
-	pi.totalItems = data.TotalItems
+	mapped := &mappedGrams{table: data[tableStart:postingsStart], postings: data[postingsStart:]}
+	runtime.AddCleanup(mapped, func(unmap func()) { unmap() }, unmap)
 
+	pi.items = items
+	pi.totalItems = len(items)
+	pi.version = version
+	pi.grams = nil
+	pi.mapped = mapped
 	return nil
 }
 
@@ -210,20 +458,59 @@ func (pi *PrefixIndex) Stats() (prefixCount int, totalItems int, avgBucketSize f
 	pi.mu.RLock()
 	defer pi.mu.RUnlock()
 
-	prefixCount = len(pi.prefixes)
+	totalIndices := 0
+	if pi.mapped != nil {
+		prefixCount = pi.mapped.len()
+		totalIndices = len(pi.mapped.postings) / 4
+	} else {
+		prefixCount = len(pi.grams)
+		for _, indices := range pi.grams {
+			totalIndices += len(indices)
+		}
+	}
 	totalItems = pi.totalItems
 
 	if prefixCount > 0 {
-		totalIndices := 0
-		for _, indices := range pi.prefixes {
-			totalIndices += len(indices)
-		}
 		avgBucketSize = float64(totalIndices) / float64(prefixCount)
 	}
 
 	return
 }
 
+// mappedGrams reads gram lists straight out of a mapped index file. The
+// mapping is released once nothing refers to it; lookups copy what they
+// return.
+type mappedGrams struct {
+	table    []byte
+	postings []byte
+}
+
+func (m *mappedGrams) len() int { return len(m.table) / 12 }
+
+func (m *mappedGrams) entry(i int) (uint32, []uint32) {
+	row := m.table[12*i:]
+	key := binary.LittleEndian.Uint32(row)
+	offset := int(binary.LittleEndian.Uint32(row[4:]))
+	count := int(binary.LittleEndian.Uint32(row[8:]))
+	indices := make([]uint32, count)
+	for j := range indices {
+		indices[j] = binary.LittleEndian.Uint32(m.postings[4*(offset+j):])
+	}
+	return key, indices
+}
+
+func (m *mappedGrams) lookup(key uint32) []uint32 {
+	n := m.len()
+	i := sort.Search(n, func(i int) bool {
+		return binary.LittleEndian.Uint32(m.table[12*i:]) >= key
+	})
+	if i == n || binary.LittleEndian.Uint32(m.table[12*i:]) != key {
+		return nil
+	}
+	_, indices := m.entry(i)
+	return indices
+}
+
 type prefixSearchSource struct {
 	items []SearchItem
 }
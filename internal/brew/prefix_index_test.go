@@ -2,6 +2,7 @@ package brew
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -127,7 +128,7 @@ func TestPrefixIndex_SaveAndLoad(t *testing.T) {
 	}
 
 	tmpDir := t.TempDir()
-	path := filepath.Join(tmpDir, "test_prefix_index.gob")
+	path := filepath.Join(tmpDir, "test_prefix_index.idx")
 
 	if err := pi.Save(path); err != nil {
 		t.Fatalf("Save failed: %v", err)
@@ -266,3 +267,132 @@ func BenchmarkFullFuzzySearch(b *testing.B) {
 		_ = fuzzy.FindFrom("pack", source)
 	}
 }
+
+func TestPrefixIndex_SearchPrefixRanksSubstrings(t *testing.T) {
+	pi := NewPrefixIndex()
+	items := []SearchItem{
+		{Name: "postgresql@16", Desc: "Object-relational database system"},
+		{Name: "mysql", Desc: "Open source relational database management system"},
+		{Name: "sqlite", Desc: "Command-line interface for SQLite"},
+		{Name: "jq", Desc: "Lightweight and flexible command-line JSON processor"},
+	}
+	if err := pi.BuildIndex(items); err != nil {
+		t.Fatalf("BuildIndex failed: %v", err)
+	}
+
+	var names []string
+	for _, item := range pi.SearchPrefix("sql") {
+		names = append(names, item.Name)
+	}
+	if fmt.Sprint(names) != "[sqlite postgresql@16 mysql]" {
+		t.Errorf("SearchPrefix(sql): expected the prefix match first, got %v", names)
+	}
+	if got := pi.SearchPrefix("gresq"); len(got) != 1 || got[0].Name != "postgresql@16" {
+		t.Errorf("SearchPrefix(gresq): expected [postgresql@16], got %v", got)
+	}
+}
+
+func TestPrefixIndex_SearchDesc(t *testing.T) {
+	pi := NewPrefixIndex()
+	items := []SearchItem{
+		{Name: "postgresql@16", Desc: "Object-relational database system"},
+		{Name: "mysql", Desc: "Open source relational database management system"},
+		{Name: "jq", Desc: "Lightweight and flexible command-line JSON processor"},
+	}
+	if err := pi.BuildIndex(items); err != nil {
+		t.Fatalf("BuildIndex failed: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected int
+	}{
+		{"database", 2},
+		{"relational SYSTEM", 2},
+		{"open database", 1},
+		{"json", 1},
+		{"of", 0},
+		{"graph database", 0},
+	}
+	for _, tc := range tests {
+		if got := pi.SearchDesc(tc.query); len(got) != tc.expected {
+			t.Errorf("SearchDesc(%q): expected %d results, got %v", tc.query, tc.expected, got)
+		}
+	}
+}
+
+func TestPrefixIndex_Update(t *testing.T) {
+	pi := NewPrefixIndex()
+	if err := pi.BuildIndex([]SearchItem{
+		{Name: "python", Desc: "Python programming language"},
+		{Name: "node", Desc: "Node.js runtime"},
+		{Name: "go", Desc: "Go programming language"},
+	}); err != nil {
+		t.Fatalf("BuildIndex failed: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "prefix_index.idx")
+	if err := pi.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded := NewPrefixIndex()
+	if err := loaded.Load(path); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	updated := []SearchItem{
+		{Name: "python", Desc: "Python programming language"},
+		{Name: "go", Desc: "Open source programming language"},
+		{Name: "nodejs", Desc: "Node.js runtime"},
+	}
+	if !loaded.Update(updated) {
+		t.Fatal("Update reported no change")
+	}
+	if loaded.Update(updated) {
+		t.Error("Update of an unchanged index reported a change")
+	}
+
+	check := func(pi *PrefixIndex) {
+		t.Helper()
+		if got := pi.SearchPrefix("node"); len(got) != 1 || got[0].Name != "nodejs" {
+			t.Errorf("SearchPrefix(node): expected [nodejs], got %v", got)
+		}
+		if got := pi.SearchDesc("open source"); len(got) != 1 || got[0].Name != "go" {
+			t.Errorf("SearchDesc(open source): expected [go], got %v", got)
+		}
+		if got := pi.SearchDesc("programming"); len(got) != 2 {
+			t.Errorf("SearchDesc(programming): expected 2 results, got %v", got)
+		}
+		if _, total, _ := pi.Stats(); total != 3 {
+			t.Errorf("expected 3 items, got %d", total)
+		}
+	}
+	check(loaded)
+
+	if err := loaded.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	reloaded := NewPrefixIndex()
+	if err := reloaded.Load(path); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	check(reloaded)
+}
+
+func TestPrefixIndex_LoadRejectsLegacyGob(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prefix_index.gob")
+	if err := os.WriteFile(path, []byte("\x1f\xff\x81\x03\x01\x01\x0fprefixIndexData"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewPrefixIndex().Load(path); err == nil {
+		t.Error("expected Load to reject a version 1 index")
+	}
+
+	empty := NewPrefixIndex()
+	if err := empty.Save(path); err != nil {
+		t.Fatalf("Save of an empty index failed: %v", err)
+	}
+	if err := NewPrefixIndex().Load(path); err != nil {
+		t.Errorf("Load of an empty index failed: %v", err)
+	}
+}
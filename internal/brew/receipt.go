@@ -0,0 +1,138 @@
+package brew
+
+import (
+	"encoding/json"
+	"fastbrew/internal/atomicfile"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// installReceiptName is the file Homebrew keeps in every keg to record how
+// it was installed. brew reads it for `brew leaves`, `brew autoremove` and
+// `brew info`, so kegs poured by fastbrew carry one too.
+const installReceiptName = "INSTALL_RECEIPT.json"
+
+// kegReceipt is the part of an INSTALL_RECEIPT.json fastbrew reads.
+type kegReceipt struct {
+	InstalledAsDependency bool  `json:"installed_as_dependency"`
+	InstalledOnRequest    bool  `json:"installed_on_request"`
+	Time                  int64 `json:"time"`
+}
+
+func readKegReceipt(kegDir string) (*kegReceipt, error) {
+	data, err := os.ReadFile(filepath.Join(kegDir, installReceiptName))
+	if err != nil {
+		return nil, err
+	}
+	var r kegReceipt
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", installReceiptName, err)
+	}
+	return &r, nil
+}
+
+// writeKegReceipt records in kegDir that f was poured from a bottle now,
+// as Homebrew does on pour. Bottles ship a receipt from the build; its
+// other fields, such as runtime_dependencies and the source tap, are kept.
+func writeKegReceipt(kegDir string, f *RemoteFormula, asDependency bool, now time.Time) error {
+	return updateKegReceipt(kegDir, func(receipt map[string]any) {
+		receipt["built_as_bottle"] = true
+		receipt["poured_from_bottle"] = true
+		receipt["installed_as_dependency"] = asDependency
+		receipt["installed_on_request"] = !asDependency
+		receipt["time"] = now.Unix()
+
+		source, _ := receipt["source"].(map[string]any)
+		if source == nil {
+			source = make(map[string]any)
+		}
+		versions, _ := source["versions"].(map[string]any)
+		if versions == nil {
+			versions = map[string]any{"head": nil, "version_scheme": 0}
+		}
+		versions["stable"] = f.Versions.Stable
+		source["versions"] = versions
+		source["spec"] = "stable"
+		receipt["source"] = source
+	})
+}
+
+// updateKegReceipt rewrites the receipt in kegDir through update, keeping
+// fields fastbrew does not know about. A missing or unreadable receipt is
+// started afresh.
+func updateKegReceipt(kegDir string, update func(receipt map[string]any)) error {
+	path := filepath.Join(kegDir, installReceiptName)
+	receipt := make(map[string]any)
+	if data, err := os.ReadFile(path); err == nil {
+		if json.Unmarshal(data, &receipt) != nil {
+			receipt = make(map[string]any)
+		}
+	}
+	update(receipt)
+
+	data, err := json.MarshalIndent(receipt, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", installReceiptName, err)
+	}
+	if err := atomicfile.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", installReceiptName, err)
+	}
+	return nil
+}
+
+// installedAsDependency reports whether name was only pulled in as a
+// dependency, going by its recorded install reason.
+func (c *Client) installedAsDependency(name string) bool {
+	return c.InstallReason(name) == ReasonDependency
+}
+
+// receiptSaysDependency reports whether the installed kegs of name say it
+// was only pulled in as a dependency. Kegs without a receipt count as
+// requested.
+func (c *Client) receiptSaysDependency(name string) bool {
+	asDependency := false
+	for _, kegDir := range c.kegDirs(name) {
+		r, err := readKegReceipt(kegDir)
+		if err != nil || r.InstalledOnRequest || !r.InstalledAsDependency {
+			return false
+		}
+		asDependency = true
+	}
+	return asDependency
+}
+
+// markInstalledOnRequest records in the receipts of name that, installed
+// earlier as a dependency, it has now been asked for explicitly, as
+// `brew install` does.
+func (c *Client) markInstalledOnRequest(name string) {
+	for _, kegDir := range c.kegDirs(name) {
+		r, err := readKegReceipt(kegDir)
+		if err != nil || r.InstalledOnRequest {
+			continue
+		}
+		if err := updateKegReceipt(kegDir, func(receipt map[string]any) {
+			receipt["installed_as_dependency"] = false
+			receipt["installed_on_request"] = true
+		}); err != nil {
+			c.printf("  ⚠️  Failed to update %s for %s: %v\n", installReceiptName, name, err)
+		}
+	}
+}
+
+// kegDirs lists the installed version directories of formula name.
+func (c *Client) kegDirs(name string) []string {
+	entries, err := os.ReadDir(filepath.Join(c.Cellar, name))
+	if err != nil {
+		return nil
+	}
+	var dirs []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			dirs = append(dirs, filepath.Join(c.Cellar, name, entry.Name()))
+		}
+	}
+	return dirs
+}
@@ -0,0 +1,121 @@
+package brew
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteKegReceiptKeepsBottleFields(t *testing.T) {
+	c := newTransactionTestClient(t)
+	keg := makeKeg(t, c, "wget", "1.24.5")
+	bottleReceipt := `{"homebrew_version":"4.3.0","runtime_dependencies":[{"full_name":"openssl@3"}],"source":{"tap":"homebrew/core","versions":{"stable":"1.24.5","head":null,"version_scheme":0}}}`
+	writeKegFile(t, filepath.Join(keg, installReceiptName), bottleReceipt)
+
+	f := &RemoteFormula{Name: "wget"}
+	f.Versions.Stable = "1.24.5"
+	now := time.Unix(1700000000, 0)
+	if err := writeKegReceipt(keg, f, true, now); err != nil {
+		t.Fatalf("writeKegReceipt failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(keg, installReceiptName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var receipt map[string]any
+	if err := json.Unmarshal(data, &receipt); err != nil {
+		t.Fatal(err)
+	}
+	if receipt["homebrew_version"] != "4.3.0" || receipt["runtime_dependencies"] == nil {
+		t.Errorf("bottle fields were dropped: %s", data)
+	}
+	if receipt["installed_as_dependency"] != true || receipt["installed_on_request"] != false || receipt["poured_from_bottle"] != true || receipt["built_as_bottle"] != true {
+		t.Errorf("install fields not set: %s", data)
+	}
+	if receipt["time"] != float64(now.Unix()) {
+		t.Errorf("time = %v, want %d", receipt["time"], now.Unix())
+	}
+	source := receipt["source"].(map[string]any)
+	if source["tap"] != "homebrew/core" || source["spec"] != "stable" || source["versions"].(map[string]any)["stable"] != "1.24.5" {
+		t.Errorf("unexpected source: %v", source)
+	}
+}
+
+func TestInstallReasonFromReceipts(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	c := newTransactionTestClient(t)
+	f := &RemoteFormula{Name: "libidn2"}
+	f.Versions.Stable = "2.3.7"
+	keg := makeKeg(t, c, "libidn2", "2.3.7")
+	if err := writeKegReceipt(keg, f, true, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	makeKeg(t, c, "jq", "1.7.1")
+
+	if !c.installedAsDependency("libidn2") {
+		t.Error("libidn2 should be reported as a dependency")
+	}
+	if c.installedAsDependency("jq") {
+		t.Error("a keg without a receipt must count as requested")
+	}
+	installed, err := c.ListInstalledNative()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pkg := range installed {
+		if want := pkg.Name == "libidn2"; pkg.InstalledAsDependency != want {
+			t.Errorf("%s: InstalledAsDependency = %v, want %v", pkg.Name, pkg.InstalledAsDependency, want)
+		}
+	}
+
+	c.markInstalledOnRequest("libidn2")
+	if c.installedAsDependency("libidn2") {
+		t.Error("libidn2 should be requested after markInstalledOnRequest")
+	}
+	r, err := readKegReceipt(keg)
+	if err != nil || r.InstalledAsDependency || !r.InstalledOnRequest {
+		t.Errorf("receipt after markInstalledOnRequest = %+v, %v", r, err)
+	}
+}
+
+func TestInstallReasonsRecordAndForget(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	c := newTransactionTestClient(t)
+	f := &RemoteFormula{Name: "jq"}
+	f.Versions.Stable = "1.7.1"
+	keg := makeKeg(t, c, "jq", "1.7.1")
+	if err := writeKegReceipt(keg, f, true, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.InstallReason("jq"); got != ReasonDependency {
+		t.Fatalf("reason from receipt = %q, want dependency", got)
+	}
+
+	if err := RecordInstallReasons(map[string]InstallReason{"jq": ReasonExplicit, "oniguruma": ReasonDependency}); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.InstallReason("jq"); got != ReasonExplicit {
+		t.Fatalf("recorded reason should win over the receipt, got %q", got)
+	}
+	// Being pulled in later does not demote an explicit install.
+	if err := RecordInstallReasons(map[string]InstallReason{"jq": ReasonDependency}); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.InstallReason("jq"); got != ReasonExplicit {
+		t.Fatalf("explicit install was demoted to %q", got)
+	}
+
+	if err := ForgetInstallReasons("jq"); err != nil {
+		t.Fatal(err)
+	}
+	reasons, err := LoadInstallReasons()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reasons["jq"]; ok || reasons["oniguruma"] != ReasonDependency {
+		t.Fatalf("reasons after forget = %v", reasons)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build darwin
+
+package brew
+
+import "golang.org/x/sys/unix"
+
+// reflinkFile clones src to dst with clonefile(2), which APFS supports.
+func reflinkFile(src, dst string) error {
+	return unix.Clonefile(src, dst, unix.CLONE_NOFOLLOW)
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package brew
+
+import "errors"
+
+// reflinkFile is not supported here; cloneFile falls back to hard links.
+func reflinkFile(src, dst string) error {
+	return errors.ErrUnsupported
+}
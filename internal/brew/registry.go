@@ -0,0 +1,68 @@
+package brew
+
+import (
+	"context"
+	"errors"
+	"fastbrew/internal/oci"
+	"fastbrew/internal/progress"
+	"fastbrew/internal/retry"
+	"fmt"
+)
+
+// registryClient returns the OCI client used to resolve bottles through
+// registry manifests. It shares the scheduler, mirrors and credentials of
+// plain downloads.
+func (c *Client) registryClient() *oci.Client {
+	c.registryOnce.Do(func() {
+		c.registry = oci.NewClient(c.scheduler())
+		c.registry.Credentials = c.Credentials
+		c.registry.Rewrite = c.mirrorURL
+	})
+	return c.registry
+}
+
+// bottleReference returns the registry tag holding f's bottles, e.g.
+// ghcr.io/homebrew/core/wget:1.21.4_1, and the bottle tag for this
+// platform. It returns false when the bottle is not served from an OCI
+// registry.
+func (f *RemoteFormula) bottleReference() (oci.Reference, string, bool) {
+	tag, file, err := f.bottleFile()
+	if err != nil {
+		return oci.Reference{}, "", false
+	}
+	ref, _, ok := oci.ParseBlobURL(file.URL)
+	if !ok {
+		return oci.Reference{}, "", false
+	}
+	version := f.FullVersion()
+	if rebuild := f.Bottle.Stable.Rebuild; rebuild > 0 {
+		version = fmt.Sprintf("%s-%d", version, rebuild)
+	}
+	return ref.WithReference(version), tag, true
+}
+
+// fetchBottleFromRegistry retries a failed bottle download by resolving it
+// through the registry's manifests instead of the blob URL embedded in the
+// formula. This covers pull-through mirrors that only serve blobs after
+// their manifest was requested, and registries whose blobs moved since the
+// formula JSON was generated. The resolved digest must match the formula's
+// checksum; cause is returned when the bottle is not in a registry.
+func (c *Client) fetchBottleFromRegistry(ctx context.Context, f *RemoteFormula, filename, sha256Sum string, tracker progress.ProgressTracker, cause error) (string, error) {
+	ref, bottleTag, ok := f.bottleReference()
+	if !ok || errors.Is(cause, retry.ErrCircuitOpen) || ctx.Err() != nil {
+		return "", cause
+	}
+	c.logger().Debug("resolving bottle through registry", "formula", f.Name, "ref", ref.String(), "tag", bottleTag, "cause", cause)
+
+	registry := c.registryClient()
+	layer, err := registry.ResolveBottle(ctx, ref, bottleTag)
+	if err != nil {
+		return "", fmt.Errorf("%w (registry lookup of %s failed: %v)", cause, ref, err)
+	}
+	if sha256Sum != "" && layer.Digest != "sha256:"+sha256Sum {
+		return "", fmt.Errorf("%w (registry serves %s for %s, expected sha256:%s)", cause, layer.Digest, ref, sha256Sum)
+	}
+
+	blobURL := c.mirrorURL(registry.BlobURL(ref, layer.Digest))
+	return c.fetchCached(ctx, blobURL, filename, sha256Sum, tracker)
+}
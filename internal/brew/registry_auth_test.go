@@ -0,0 +1,137 @@
+package brew
+
+import (
+	"encoding/base64"
+	"fastbrew/internal/auth"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testCredentials(host, value string) *auth.Store {
+	store := auth.NewStore(map[string]string{host: value})
+	store.GitHelper = false
+	return store
+}
+
+func TestDownloadToFileRetriesWithCredential(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	want := "Basic " + base64.StdEncoding.EncodeToString([]byte("deploy:s3cret"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != want {
+			w.Header().Set("Www-Authenticate", `Basic realm="artifactory"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("bottle"))
+	}))
+	defer server.Close()
+
+	c := &Client{Credentials: testCredentials("127.0.0.1", "deploy:s3cret")}
+	dest := filepath.Join(t.TempDir(), "bottle")
+	if _, _, err := c.downloadToFile(t.Context(), server.URL+"/bottle", dest, downloadOptions{}); err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	if data, _ := os.ReadFile(dest); string(data) != "bottle" {
+		t.Fatalf("unexpected contents %q", data)
+	}
+}
+
+func TestDownloadToFileRequestsRegistryTokenWithCredential(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("x-access-token:ghp_private"))
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			if r.Header.Get("Authorization") != basic {
+				w.Write([]byte(`{"token":"anonymous"}`))
+				return
+			}
+			w.Write([]byte(`{"token":"private"}`))
+		default:
+			if r.Header.Get("Authorization") != "Bearer private" {
+				w.Header().Set("Www-Authenticate", `Bearer realm="`+server.URL+`/token",service="registry",scope="repository:acme/tools/widget:pull"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte("bottle"))
+		}
+	}))
+	defer server.Close()
+
+	c := &Client{Credentials: testCredentials("127.0.0.1", "ghp_private")}
+	dest := filepath.Join(t.TempDir(), "bottle")
+	if _, _, err := c.downloadToFile(t.Context(), server.URL+"/v2/acme/tools/widget/blobs/sha256:abc", dest, downloadOptions{}); err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+}
+
+func TestDownloadToFileReusesRegistryToken(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	var server *httptest.Server
+	tokenRequests := 0
+	valid := "Bearer t1"
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			tokenRequests++
+			fmt.Fprintf(w, `{"token":"t%d","expires_in":300}`, tokenRequests)
+			return
+		}
+		if r.Header.Get("Authorization") != valid {
+			w.Header().Set("Www-Authenticate", `Bearer realm="`+server.URL+`/token",service="ghcr.io",scope="repository:homebrew/core/wget:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("bottle"))
+	}))
+	defer server.Close()
+
+	c := &Client{}
+	dir := t.TempDir()
+	for i, digest := range []string{"sha256:aaa", "sha256:bbb"} {
+		dest := filepath.Join(dir, fmt.Sprintf("bottle%d", i))
+		if _, _, err := c.downloadToFile(t.Context(), server.URL+"/v2/homebrew/core/wget/blobs/"+digest, dest, downloadOptions{}); err != nil {
+			t.Fatalf("download failed: %v", err)
+		}
+	}
+	if tokenRequests != 1 {
+		t.Errorf("Expected the token to be shared by both downloads, got %d token requests", tokenRequests)
+	}
+
+	// The registry revokes t1: the next download refreshes it.
+	valid = "Bearer t2"
+	if _, _, err := c.downloadToFile(t.Context(), server.URL+"/v2/homebrew/core/wget/blobs/sha256:ccc", filepath.Join(dir, "bottle2"), downloadOptions{}); err != nil {
+		t.Fatalf("download with a revoked token failed: %v", err)
+	}
+	if tokenRequests != 2 {
+		t.Errorf("Expected one refresh of the revoked token, got %d token requests", tokenRequests)
+	}
+}
+
+func TestGitAuthEnvPassesHeaderForHost(t *testing.T) {
+	tm := &TapManager{Credentials: testCredentials("ghe.example.com", "ghp_private")}
+
+	env := tm.gitAuthEnv("https://ghe.example.com/acme/homebrew-tools.git")
+	want := map[string]bool{
+		"GIT_CONFIG_COUNT=1": true,
+		"GIT_CONFIG_KEY_0=http.https://ghe.example.com/.extraHeader":                                                         true,
+		"GIT_CONFIG_VALUE_0=Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte("x-access-token:ghp_private")): true,
+	}
+	for _, kv := range env {
+		delete(want, kv)
+	}
+	if len(want) != 0 {
+		t.Fatalf("missing git config entries: %v", want)
+	}
+
+	if env := tm.gitAuthEnv("https://github.com/other/homebrew-tap.git"); len(env) != len(os.Environ()) {
+		t.Fatal("credential leaked to another host")
+	}
+}
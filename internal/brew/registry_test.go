@@ -0,0 +1,87 @@
+package brew
+
+import (
+	"context"
+	"encoding/json"
+	"fastbrew/internal/oci"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestDownloadBottleResolvesThroughRegistry(t *testing.T) {
+	client, _ := newCleanupTestClient(t)
+	platform, err := GetPlatform()
+	if err != nil {
+		t.Skip(err)
+	}
+
+	bottle := []byte("bottle tarball")
+	sha := sha256Hex(bottle)
+	manifest, _ := json.Marshal(oci.Manifest{
+		SchemaVersion: 2,
+		MediaType:     oci.MediaTypeImageManifest,
+		Layers:        []oci.Descriptor{{Digest: "sha256:" + sha, Size: int64(len(bottle))}},
+	})
+	manifestDigest := "sha256:" + sha256Hex(manifest)
+	index, _ := json.Marshal(oci.Manifest{
+		SchemaVersion: 2,
+		MediaType:     oci.MediaTypeImageIndex,
+		Manifests: []oci.Descriptor{{
+			Digest:      manifestDigest,
+			Annotations: map[string]string{oci.AnnotationRefName: "1.21.4_1-1." + platform},
+		}},
+	})
+
+	// Like a pull-through mirror, blobs are only served once their
+	// manifest has been requested.
+	primed := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/homebrew/core/wget/manifests/1.21.4_1-1":
+			w.Write(index)
+		case "/v2/homebrew/core/wget/manifests/" + manifestDigest:
+			primed = true
+			w.Write(manifest)
+		case "/v2/homebrew/core/wget/blobs/sha256:" + sha:
+			if !primed {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(bottle)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	client.Mirrors = map[string]string{"ghcr.io": server.URL}
+
+	f := &RemoteFormula{Name: "wget", Versions: Versions{Stable: "1.21.4"}, Revision: 1}
+	f.Bottle.Stable.Rebuild = 1
+	f.Bottle.Stable.Files = map[string]BottleFile{
+		platform: {URL: "https://ghcr.io/v2/homebrew/core/wget/blobs/sha256:" + sha, SHA256: sha},
+	}
+
+	path, err := client.DownloadBottle(context.Background(), f)
+	if err != nil {
+		t.Fatalf("DownloadBottle failed: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != string(bottle) {
+		t.Fatalf("unexpected bottle contents %q", data)
+	}
+}
+
+func TestBottleReferenceRequiresRegistry(t *testing.T) {
+	platform, err := GetPlatform()
+	if err != nil {
+		t.Skip(err)
+	}
+	f := &RemoteFormula{Name: "widget", Versions: Versions{Stable: "1.0"}}
+	f.Bottle.Stable.Files = map[string]BottleFile{
+		platform: {URL: "https://example.com/widget-1.0." + platform + ".bottle.tar.gz"},
+	}
+	if _, _, ok := f.bottleReference(); ok {
+		t.Fatal("a plain bottle URL has no registry reference")
+	}
+}
@@ -0,0 +1,205 @@
+package brew
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ReinstallOptions tune Reinstall.
+type ReinstallOptions struct {
+	// ForceDownload discards the cached bottle and downloads it again.
+	ForceDownload bool
+}
+
+// ReinstallResult reports what a reinstall changed.
+type ReinstallResult struct {
+	Name            string
+	PreviousVersion string
+	Version         string
+	// Downloaded is false when the bottle came from the download cache.
+	Downloaded bool
+	// RemovedKegs are other Cellar versions removed by the reinstall.
+	RemovedKegs  []string
+	LinksAdded   []string
+	LinksRemoved []string
+}
+
+// Changed reports whether the reinstall changed the version or links.
+func (r *ReinstallResult) Changed() bool {
+	return r.PreviousVersion != r.Version || len(r.LinksAdded) > 0 || len(r.LinksRemoved) > 0
+}
+
+// Reinstall replaces an installed formula's keg with a fresh copy of its
+// current bottle. The bottle is downloaded before anything is touched; the
+// old kegs are then unlinked and set aside, the bottle extracted and
+// linked, and the old kegs removed. The opt link is never removed, only
+// repointed, so dependents keep resolving throughout. If extraction fails
+// the previous keg is restored and relinked. Cancelling ctx stops the
+// metadata fetch or the download, before anything is touched.
+func (c *Client) Reinstall(ctx context.Context, name string, opts ReinstallOptions) (*ReinstallResult, error) {
+	previous := c.currentKegVersion(name)
+	if previous == "" {
+		return nil, kindErrorf(KindNotFound, "%s is not installed", name)
+	}
+
+	c.emitMutation(MutationOperationReinstall, name, MutationPhaseMetadata, MutationStatusRunning, "fetching metadata", 0, 0, "")
+	f, err := c.FetchFormula(ctx, name)
+	if err != nil {
+		c.emitMutation(MutationOperationReinstall, name, MutationPhaseMetadata, MutationStatusFailed, err.Error(), 0, 0, "")
+		return nil, fmt.Errorf("failed to fetch formula %s: %w", name, err)
+	}
+	c.emitMutation(MutationOperationReinstall, name, MutationPhaseMetadata, MutationStatusSucceeded, "metadata ready", 0, 0, "")
+	return c.reinstallFormula(ctx, f, previous, opts)
+}
+
+// reinstallFormula replaces the installed kegs of f, currently linked at
+// version previous, with f's bottle.
+func (c *Client) reinstallFormula(ctx context.Context, f *RemoteFormula, previous string, opts ReinstallOptions) (result *ReinstallResult, err error) {
+	_, sha, err := f.GetBottleInfo()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Name, err)
+	}
+	result = &ReinstallResult{Name: f.Name, PreviousVersion: previous, Version: f.Versions.Stable}
+
+	if opts.ForceDownload {
+		if err := c.evictCached(f.bottleCacheName(), sha); err != nil {
+			return nil, fmt.Errorf("failed to clear cached bottle: %w", err)
+		}
+	}
+	result.Downloaded = !c.hasCachedBlob(sha)
+
+	if result.Downloaded {
+		c.printf("  ⬇️  Downloading %s %s...\n", f.Name, f.FullVersion())
+	} else {
+		c.printf("  📦 Using cached bottle for %s %s\n", f.Name, f.FullVersion())
+	}
+	c.emitMutation(MutationOperationReinstall, f.Name, MutationPhaseDownload, MutationStatusRunning, "downloading bottle", 0, 0, "bytes")
+	tarPath, err := c.DownloadBottle(ctx, f)
+	if err != nil {
+		c.emitMutation(MutationOperationReinstall, f.Name, MutationPhaseDownload, MutationStatusFailed, err.Error(), 0, 0, "bytes")
+		return nil, fmt.Errorf("failed to download %s: %w", f.Name, err)
+	}
+	c.emitMutation(MutationOperationReinstall, f.Name, MutationPhaseDownload, MutationStatusSucceeded, "downloaded bottle", 0, 0, "bytes")
+
+	oldLinks := c.linkedFiles(f.Name, previous, f.KegOnly)
+
+	c.beginTransaction(MutationOperationReinstall, []string{f.Name})
+	defer func() { c.finishTransaction(err) }()
+
+	// Read before the kegs holding the receipts are set aside.
+	asDependency := c.installedAsDependency(f.Name)
+
+	c.printf("  🔗 Unlinking %s %s\n", f.Name, previous)
+	if err := c.unlinkKeg(f.Name, true); err != nil {
+		return nil, fmt.Errorf("failed to unlink %s: %w", f.Name, err)
+	}
+	backups, err := c.setAsideKegs(f.Name)
+	if err != nil {
+		c.restoreKegs(f, previous, backups)
+		return nil, err
+	}
+
+	c.emitMutation(MutationOperationReinstall, f.Name, MutationPhaseExtract, MutationStatusRunning, "extracting bottle", 0, 0, "")
+	if err := c.extractAndInstallBottle(f, tarPath, asDependency); err != nil {
+		c.emitMutation(MutationOperationReinstall, f.Name, MutationPhaseExtract, MutationStatusFailed, err.Error(), 0, 0, "")
+		c.restoreKegs(f, previous, backups)
+		return nil, fmt.Errorf("failed to extract %s: %w", f.Name, err)
+	}
+	c.emitMutation(MutationOperationReinstall, f.Name, MutationPhaseExtract, MutationStatusSucceeded, "extracted bottle", 0, 0, "")
+
+	if err := c.linkFormulae([]*RemoteFormula{f}, MutationOperationReinstall); err != nil {
+		return nil, err
+	}
+
+	for version, backup := range backups {
+		if err := os.RemoveAll(backup); err != nil {
+			c.printf("  ⚠️  Failed to remove old keg %s: %v\n", backup, err)
+			continue
+		}
+		if version != f.Versions.Stable {
+			result.RemovedKegs = append(result.RemovedKegs, version)
+		}
+	}
+	sort.Strings(result.RemovedKegs)
+
+	newLinks := c.linkedFiles(f.Name, f.Versions.Stable, f.KegOnly)
+	result.LinksAdded = missingFrom(newLinks, oldLinks)
+	result.LinksRemoved = missingFrom(oldLinks, newLinks)
+
+	c.notifyInvalidation(EventInstalledChanged)
+	return result, nil
+}
+
+// linkedFiles lists the files a keg links into the prefix. Keg-only kegs
+// link nothing.
+func (c *Client) linkedFiles(name, version string, kegOnly bool) []string {
+	if kegOnly {
+		return nil
+	}
+	result, err := c.LinkDryRun(name, version)
+	if err != nil {
+		return nil
+	}
+	return result.Binaries
+}
+
+// setAsideKegs renames every installed version of name to a hidden backup
+// inside Cellar/<name> and returns the backups by version.
+func (c *Client) setAsideKegs(name string) (map[string]string, error) {
+	pkgDir := filepath.Join(c.Cellar, name)
+	entries, err := os.ReadDir(pkgDir)
+	if err != nil {
+		return nil, err
+	}
+	backups := make(map[string]string)
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		backup := filepath.Join(pkgDir, ".reinstall-"+entry.Name())
+		os.RemoveAll(backup)
+		if err := os.Rename(filepath.Join(pkgDir, entry.Name()), backup); err != nil {
+			return backups, fmt.Errorf("failed to move aside %s %s: %w", name, entry.Name(), err)
+		}
+		backups[entry.Name()] = backup
+	}
+	return backups, nil
+}
+
+// restoreKegs puts kegs set aside by setAsideKegs back and relinks the
+// previous version.
+func (c *Client) restoreKegs(f *RemoteFormula, previous string, backups map[string]string) {
+	pkgDir := filepath.Join(c.Cellar, f.Name)
+	for version, backup := range backups {
+		dest := filepath.Join(pkgDir, version)
+		os.RemoveAll(dest)
+		if err := os.Rename(backup, dest); err != nil {
+			c.printf("  ⚠️  Failed to restore %s %s: %v\n", f.Name, version, err)
+		}
+	}
+	restored := *f
+	restored.Versions.Stable = previous
+	if err := c.linkFormulae([]*RemoteFormula{&restored}, MutationOperationReinstall); err != nil {
+		c.printf("  ⚠️  Failed to relink %s %s: %v\n", f.Name, previous, err)
+	}
+}
+
+// missingFrom returns the entries of a that are not in b, sorted.
+func missingFrom(a, b []string) []string {
+	seen := make(map[string]bool, len(b))
+	for _, s := range b {
+		seen[s] = true
+	}
+	var out []string
+	for _, s := range a {
+		if !seen[s] {
+			out = append(out, s)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,145 @@
+package brew
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newReinstallFormula serves a bottle of name at version and returns a
+// formula pointing at it, with the server's hit counter.
+func newReinstallFormula(t *testing.T, name, version string) (*RemoteFormula, *int32) {
+	t.Helper()
+	platform, err := GetPlatform()
+	if err != nil {
+		t.Skip(err)
+	}
+	path := writeTestBottle(t, t.TempDir(), "bottle.tar.gz", name, version)
+	body, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, hits := newBottleServer(t, body)
+
+	f := &RemoteFormula{Name: name}
+	f.Versions.Stable = version
+	f.Bottle.Stable.Files = map[string]BottleFile{
+		platform: {URL: srv.URL + "/" + name, SHA256: sha256Hex(body)},
+	}
+	return f, hits
+}
+
+func TestReinstallReplacesKegAndKeepsOptLink(t *testing.T) {
+	client, _ := newCleanupTestClient(t)
+	client.Out = &strings.Builder{}
+	makeKeg(t, client, "fbtestpkg", "1.0")
+	if _, err := client.Link("fbtestpkg", "1.0"); err != nil {
+		t.Fatal(err)
+	}
+	f, hits := newReinstallFormula(t, "fbtestpkg", "2.0")
+
+	result, err := client.reinstallFormula(context.Background(), f, "1.0", ReinstallOptions{})
+	if err != nil {
+		t.Fatalf("reinstall failed: %v", err)
+	}
+	if !result.Downloaded || atomic.LoadInt32(hits) != 1 {
+		t.Fatalf("expected one download, got Downloaded=%v hits=%d", result.Downloaded, atomic.LoadInt32(hits))
+	}
+	if !reflect.DeepEqual(result.RemovedKegs, []string{"1.0"}) {
+		t.Fatalf("RemovedKegs = %v, want [1.0]", result.RemovedKegs)
+	}
+	if !result.Changed() {
+		t.Fatal("a version change should be reported as a change")
+	}
+	if _, err := os.Stat(filepath.Join(client.Cellar, "fbtestpkg", "1.0")); !os.IsNotExist(err) {
+		t.Fatalf("old keg should be removed, stat err = %v", err)
+	}
+	if got := client.linkedVersion("fbtestpkg"); got != "2.0" {
+		t.Fatalf("opt link points at %q, want 2.0", got)
+	}
+	if _, err := os.Stat(filepath.Join(client.Prefix, "bin", "fbtestpkg")); err != nil {
+		t.Fatalf("binary not relinked: %v", err)
+	}
+}
+
+func TestReinstallUsesCacheUnlessForced(t *testing.T) {
+	client, _ := newCleanupTestClient(t)
+	client.Out = &strings.Builder{}
+	f, hits := newReinstallFormula(t, "fbtestpkg", "1.0")
+	makeKeg(t, client, "fbtestpkg", "1.0")
+
+	if _, err := client.reinstallFormula(context.Background(), f, "1.0", ReinstallOptions{}); err != nil {
+		t.Fatalf("first reinstall failed: %v", err)
+	}
+	result, err := client.reinstallFormula(context.Background(), f, "1.0", ReinstallOptions{})
+	if err != nil {
+		t.Fatalf("second reinstall failed: %v", err)
+	}
+	if result.Downloaded || atomic.LoadInt32(hits) != 1 {
+		t.Fatalf("expected the cached bottle, got Downloaded=%v hits=%d", result.Downloaded, atomic.LoadInt32(hits))
+	}
+	if len(result.RemovedKegs) != 0 || result.Changed() {
+		t.Fatalf("same-version reinstall should report no changes: %+v", result)
+	}
+
+	result, err = client.reinstallFormula(context.Background(), f, "1.0", ReinstallOptions{ForceDownload: true})
+	if err != nil {
+		t.Fatalf("forced reinstall failed: %v", err)
+	}
+	if !result.Downloaded || atomic.LoadInt32(hits) != 2 {
+		t.Fatalf("--force-download should download again, got Downloaded=%v hits=%d", result.Downloaded, atomic.LoadInt32(hits))
+	}
+}
+
+func TestReinstallRestoresKegWhenExtractionFails(t *testing.T) {
+	client, _ := newCleanupTestClient(t)
+	client.Out = &strings.Builder{}
+	makeKeg(t, client, "fbtestpkg", "1.0")
+	if _, err := client.Link("fbtestpkg", "1.0"); err != nil {
+		t.Fatal(err)
+	}
+
+	body := []byte("not a bottle")
+	srv, _ := newBottleServer(t, body)
+	platform, _ := GetPlatform()
+	f := &RemoteFormula{Name: "fbtestpkg"}
+	f.Versions.Stable = "2.0"
+	f.Bottle.Stable.Files = map[string]BottleFile{platform: {URL: srv.URL, SHA256: sha256Hex(body)}}
+
+	if _, err := client.reinstallFormula(context.Background(), f, "1.0", ReinstallOptions{}); err == nil {
+		t.Fatal("expected extraction to fail")
+	}
+	if _, err := os.Stat(filepath.Join(client.Cellar, "fbtestpkg", "1.0", "bin", "fbtestpkg")); err != nil {
+		t.Fatalf("previous keg not restored: %v", err)
+	}
+	if got := client.linkedVersion("fbtestpkg"); got != "1.0" {
+		t.Fatalf("opt link points at %q, want 1.0", got)
+	}
+	if _, err := os.Stat(filepath.Join(client.Prefix, "bin", "fbtestpkg")); err != nil {
+		t.Fatalf("previous keg not relinked: %v", err)
+	}
+}
+
+func TestReinstallStopsWhenContextCancelled(t *testing.T) {
+	client, _ := newCleanupTestClient(t)
+	client.Out = &strings.Builder{}
+	makeKeg(t, client, "fbtestpkg", "1.0")
+	f, hits := newReinstallFormula(t, "fbtestpkg", "2.0")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.reinstallFormula(ctx, f, "1.0", ReinstallOptions{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if atomic.LoadInt32(hits) != 0 {
+		t.Fatalf("cancelled reinstall should not download, hits=%d", atomic.LoadInt32(hits))
+	}
+	if _, err := os.Stat(filepath.Join(client.Cellar, "fbtestpkg", "1.0", "bin", "fbtestpkg")); err != nil {
+		t.Fatalf("previous keg should be kept: %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package brew
+
+import (
+	"encoding/json"
+	"fastbrew/internal/atomicfile"
+	"fastbrew/internal/paths"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// RunReport is the timing breakdown of an install or upgrade. The last
+// one is saved so `fastbrew last` can show it again.
+type RunReport struct {
+	Operation string    `json:"operation"`
+	StartedAt time.Time `json:"started_at"`
+	// WallTime covers the whole run, from resolving dependencies to the
+	// last link. Durations are nanoseconds in JSON.
+	WallTime time.Duration `json:"wall_time_ns"`
+	// BytesTransferred counts everything downloaded during the run;
+	// bottles reused from the download cache add nothing.
+	BytesTransferred int64           `json:"bytes_transferred"`
+	Packages         []PackageTiming `json:"packages"`
+	Error            string          `json:"error,omitempty"`
+}
+
+// PackageTiming is one formula's share of a RunReport.
+type PackageTiming struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	// DownloadSize is the size of the bottle, whether it was downloaded
+	// or taken from the cache.
+	DownloadSize int64         `json:"download_size"`
+	DownloadTime time.Duration `json:"download_time_ns"`
+	ExtractTime  time.Duration `json:"extract_time_ns"`
+	// Links is how many files were linked into the prefix.
+	Links int `json:"links"`
+}
+
+// DownloadTotal is the combined bottle size of the report's packages.
+func (r *RunReport) DownloadTotal() int64 {
+	var total int64
+	for _, pkg := range r.Packages {
+		total += pkg.DownloadSize
+	}
+	return total
+}
+
+// RunReportPath returns the file holding the last install or upgrade
+// report.
+func RunReportPath() string {
+	return filepath.Join(paths.StateDir(), "last_run.json")
+}
+
+// LoadRunReport returns the last saved report, or nil when there is none.
+func LoadRunReport() (*RunReport, error) {
+	data, err := os.ReadFile(RunReportPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var report RunReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func saveRunReport(report *RunReport) error {
+	path := RunReportPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, data, 0644)
+}
+
+// runRecorder collects a RunReport while an install or upgrade runs.
+type runRecorder struct {
+	report    RunReport
+	startByte int64
+	packages  map[string]*PackageTiming
+}
+
+// beginRunReport starts recording a report for operation. It returns false
+// when one is already being recorded, e.g. an install inside an upgrade,
+// and the caller must not finish it.
+func (c *Client) beginRunReport(operation string) bool {
+	c.reportMu.Lock()
+	defer c.reportMu.Unlock()
+	if c.report != nil {
+		return false
+	}
+	c.report = &runRecorder{
+		report:    RunReport{Operation: operation, StartedAt: time.Now()},
+		startByte: c.transferred.Load(),
+		packages:  make(map[string]*PackageTiming),
+	}
+	return true
+}
+
+// finishRunReport saves the report begun by beginRunReport. Runs that
+// installed nothing leave the previous report in place.
+func (c *Client) finishRunReport(opErr error) {
+	c.reportMu.Lock()
+	rec := c.report
+	c.report = nil
+	c.reportMu.Unlock()
+	if rec == nil || len(rec.packages) == 0 {
+		return
+	}
+
+	report := rec.report
+	report.WallTime = time.Since(report.StartedAt)
+	report.BytesTransferred = c.transferred.Load() - rec.startByte
+	if opErr != nil {
+		report.Error = opErr.Error()
+	}
+	for _, pkg := range rec.packages {
+		report.Packages = append(report.Packages, *pkg)
+	}
+	sort.Slice(report.Packages, func(i, j int) bool {
+		return report.Packages[i].Name < report.Packages[j].Name
+	})
+	if err := saveRunReport(&report); err != nil {
+		c.logger().Warn("failed to save run report", "error", err)
+	}
+}
+
+// recordTiming updates the timing of f in the report being recorded, if
+// any.
+func (c *Client) recordTiming(f *RemoteFormula, update func(*PackageTiming)) {
+	c.reportMu.Lock()
+	defer c.reportMu.Unlock()
+	if c.report == nil {
+		return
+	}
+	pkg, ok := c.report.packages[f.Name]
+	if !ok {
+		pkg = &PackageTiming{Name: f.Name, Version: f.FullVersion()}
+		c.report.packages[f.Name] = pkg
+	}
+	update(pkg)
+}
+
+func (c *Client) recordDownload(f *RemoteFormula, tarPath string, elapsed time.Duration) {
+	var size int64
+	if info, err := os.Stat(tarPath); err == nil {
+		size = info.Size()
+	}
+	c.recordTiming(f, func(pkg *PackageTiming) {
+		pkg.DownloadSize = size
+		pkg.DownloadTime = elapsed
+	})
+}
+
+func (c *Client) recordExtract(f *RemoteFormula, elapsed time.Duration) {
+	c.recordTiming(f, func(pkg *PackageTiming) { pkg.ExtractTime = elapsed })
+}
+
+func (c *Client) recordLinks(f *RemoteFormula, links int) {
+	c.recordTiming(f, func(pkg *PackageTiming) { pkg.Links = links })
+}
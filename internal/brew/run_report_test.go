@@ -0,0 +1,72 @@
+package brew
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunReportRecordsTimings(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	c := &Client{}
+	jq := &RemoteFormula{Name: "jq"}
+	jq.Versions.Stable = "1.7"
+	tarPath := filepath.Join(t.TempDir(), "jq.tar.gz")
+	if err := os.WriteFile(tarPath, make([]byte, 300), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if !c.beginRunReport(MutationOperationInstall) {
+		t.Fatal("beginRunReport = false, want true")
+	}
+	if c.beginRunReport(MutationOperationUpgrade) {
+		t.Error("nested beginRunReport = true, want false")
+	}
+	c.transferred.Add(300)
+	c.recordDownload(jq, tarPath, 2*time.Second)
+	c.recordExtract(jq, time.Second)
+	c.recordLinks(jq, 5)
+	c.finishRunReport(errors.New("link failed"))
+
+	report, err := LoadRunReport()
+	if err != nil || report == nil {
+		t.Fatalf("LoadRunReport() = %v, %v", report, err)
+	}
+	if report.Operation != MutationOperationInstall || report.BytesTransferred != 300 || report.Error != "link failed" {
+		t.Errorf("report = %+v", report)
+	}
+	if len(report.Packages) != 1 {
+		t.Fatalf("packages = %+v, want jq only", report.Packages)
+	}
+	got := report.Packages[0]
+	want := PackageTiming{Name: "jq", Version: "1.7", DownloadSize: 300, DownloadTime: 2 * time.Second, ExtractTime: time.Second, Links: 5}
+	if got != want {
+		t.Errorf("jq timing = %+v, want %+v", got, want)
+	}
+}
+
+func TestRunReportKeepsPreviousWhenNothingInstalled(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	if err := saveRunReport(&RunReport{Operation: MutationOperationUpgrade}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Client{}
+	c.beginRunReport(MutationOperationInstall)
+	c.finishRunReport(nil)
+
+	report, err := LoadRunReport()
+	if err != nil || report == nil || report.Operation != MutationOperationUpgrade {
+		t.Errorf("LoadRunReport() = %+v, %v; want the previous upgrade report", report, err)
+	}
+}
+
+func TestLoadRunReportMissing(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	report, err := LoadRunReport()
+	if err != nil || report != nil {
+		t.Errorf("LoadRunReport() = %+v, %v; want nil, nil", report, err)
+	}
+}
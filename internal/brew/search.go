@@ -0,0 +1,202 @@
+package brew
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sahilm/fuzzy"
+)
+
+// SearchOptions filter and limit Search results.
+type SearchOptions struct {
+	// FormulaOnly and CaskOnly restrict results to one kind of package.
+	FormulaOnly bool `json:"formula_only,omitempty"`
+	CaskOnly    bool `json:"cask_only,omitempty"`
+	// DescOnly matches the query against descriptions instead of names.
+	DescOnly bool `json:"desc_only,omitempty"`
+	// Limit caps the number of results; 0 returns them all.
+	Limit int `json:"limit,omitempty"`
+}
+
+// Search result ranks, best first: an exact name match, names starting with
+// the query, names containing it, fuzzy name matches, and finally packages
+// whose description contains every word of the query. Formula aliases rank
+// like names, and tap formulae match on their short name as well as
+// user/repo/name.
+const (
+	searchRankExact = iota
+	searchRankPrefix
+	searchRankSubstring
+	searchRankFuzzy
+	searchRankDesc
+)
+
+// Search finds formulae, casks and tap formulae matching query, ranked so
+// that name matches come before description matches.
+func (c *Client) Search(query string, opts SearchOptions) ([]SearchItem, error) {
+	prefixIdx, err := c.GetPrefixIndex()
+	if err != nil {
+		return nil, err
+	}
+	var items []SearchItem
+	if opts.DescOnly {
+		// Only packages whose description has every word can match.
+		items = prefixIdx.SearchDesc(query)
+	} else {
+		items = prefixIdx.GetItems()
+	}
+	items = append(items, c.TapSearchItems()...)
+	return rankSearch(query, items, opts), nil
+}
+
+// CompleteNames returns the formula, cask and tap formula names starting
+// with prefix, sorted, for shell completion. It reads only the prefix index
+// saved by an earlier search or update, even if that is out of date, and
+// never downloads; before the first update only tap formulae are offered.
+func (c *Client) CompleteNames(prefix string) []string {
+	var items []SearchItem
+	if cacheDir, err := c.GetCacheDir(); err == nil {
+		path := filepath.Join(cacheDir, prefixIndexFileName)
+		if _, err := os.Stat(path); err == nil {
+			idx := NewPrefixIndex()
+			if idx.Load(path) == nil {
+				items = idx.SearchPrefix(prefix)
+			}
+		}
+	}
+	items = append(items, c.TapSearchItems()...)
+
+	lower := strings.ToLower(prefix)
+	seen := make(map[string]bool, len(items))
+	var names []string
+	for _, item := range items {
+		if seen[item.Name] || !strings.HasPrefix(strings.ToLower(item.Name), lower) {
+			continue
+		}
+		seen[item.Name] = true
+		names = append(names, item.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type rankedItem struct {
+	item SearchItem
+	rank int
+	// order breaks ties within the fuzzy rank, where the matcher's score
+	// decides.
+	order int
+}
+
+// rankSearch filters items by opts and orders the matches for query.
+func rankSearch(query string, items []SearchItem, opts SearchOptions) []SearchItem {
+	q := strings.ToLower(strings.TrimSpace(query))
+	if q == "" {
+		return []SearchItem{}
+	}
+	words := strings.Fields(q)
+
+	var ranked []rankedItem
+	var unmatched []SearchItem
+	for _, item := range items {
+		if (opts.FormulaOnly && item.IsCask) || (opts.CaskOnly && !item.IsCask) {
+			continue
+		}
+		if opts.DescOnly {
+			if descMatches(item.Desc, words) {
+				ranked = append(ranked, rankedItem{item: item, rank: searchRankDesc})
+			}
+			continue
+		}
+
+		if rank, ok := itemRank(item, q); ok {
+			ranked = append(ranked, rankedItem{item: item, rank: rank})
+			continue
+		}
+		unmatched = append(unmatched, item)
+	}
+
+	if !opts.DescOnly {
+		fuzzyMatched := make(map[int]bool)
+		for order, match := range fuzzy.FindFrom(q, searchNameSource(unmatched)) {
+			fuzzyMatched[match.Index] = true
+			ranked = append(ranked, rankedItem{item: unmatched[match.Index], rank: searchRankFuzzy, order: order})
+		}
+		for i, item := range unmatched {
+			if !fuzzyMatched[i] && descMatches(item.Desc, words) {
+				ranked = append(ranked, rankedItem{item: item, rank: searchRankDesc})
+			}
+		}
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.rank != b.rank {
+			return a.rank < b.rank
+		}
+		if a.rank == searchRankFuzzy {
+			return a.order < b.order
+		}
+		if len(a.item.Name) != len(b.item.Name) {
+			return len(a.item.Name) < len(b.item.Name)
+		}
+		return a.item.Name < b.item.Name
+	})
+
+	if opts.Limit > 0 && len(ranked) > opts.Limit {
+		ranked = ranked[:opts.Limit]
+	}
+	result := make([]SearchItem, len(ranked))
+	for i, r := range ranked {
+		result[i] = r.item
+	}
+	return result
+}
+
+// itemRank is the best nameRank of item's name and its aliases, so
+// "python" finds python@3.13 as an exact match.
+func itemRank(item SearchItem, q string) (int, bool) {
+	best, found := nameRank(item.Name, q)
+	for _, alias := range item.Aliases {
+		if rank, ok := nameRank(alias, q); ok && (!found || rank < best) {
+			best, found = rank, true
+		}
+	}
+	return best, found
+}
+
+// nameRank classifies a non-fuzzy name match of the lower-case query q.
+func nameRank(name, q string) (int, bool) {
+	full := strings.ToLower(name)
+	short := full[strings.LastIndex(full, "/")+1:]
+	switch {
+	case full == q || short == q:
+		return searchRankExact, true
+	case strings.HasPrefix(full, q) || strings.HasPrefix(short, q):
+		return searchRankPrefix, true
+	case strings.Contains(full, q):
+		return searchRankSubstring, true
+	}
+	return 0, false
+}
+
+// descMatches reports whether desc contains every lower-case word.
+func descMatches(desc string, words []string) bool {
+	if desc == "" || len(words) == 0 {
+		return false
+	}
+	desc = strings.ToLower(desc)
+	for _, w := range words {
+		if !strings.Contains(desc, w) {
+			return false
+		}
+	}
+	return true
+}
+
+type searchNameSource []SearchItem
+
+func (s searchNameSource) String(i int) string { return s[i].Name }
+func (s searchNameSource) Len() int            { return len(s) }
@@ -0,0 +1,91 @@
+package brew
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func searchNames(items []SearchItem) []string {
+	names := make([]string, len(items))
+	for i, item := range items {
+		names[i] = item.Name
+	}
+	return names
+}
+
+func TestRankSearchOrdersNameMatchesBeforeDescriptions(t *testing.T) {
+	items := []SearchItem{
+		{Name: "uv", Desc: "Extremely fast Python package installer"},
+		{Name: "python-tk@3.12", Desc: "Python interface to Tcl/Tk"},
+		{Name: "bpython", Desc: "Fancy interface to the Python interpreter"},
+		{Name: "python", Desc: "Interpreted, interactive, object-oriented programming language"},
+		{Name: "python@3.12", Desc: "Interpreted, interactive, object-oriented programming language"},
+		{Name: "pycharm", Desc: "IDE for Python", IsCask: true},
+		{Name: "acme/tools/python", Desc: "Patched Python"},
+	}
+
+	got := searchNames(rankSearch("Python", items, SearchOptions{}))
+	want := []string{"python", "acme/tools/python", "python@3.12", "python-tk@3.12", "bpython", "uv", "pycharm"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("rankSearch(Python) = %v, want %v", got, want)
+	}
+}
+
+func TestRankSearchFilters(t *testing.T) {
+	items := []SearchItem{
+		{Name: "wget", Desc: "Internet file retriever"},
+		{Name: "wget2", Desc: "Successor of GNU Wget"},
+		{Name: "firefox", Desc: "Web browser", IsCask: true},
+		{Name: "curl", Desc: "Get a file from an HTTP, HTTPS or FTP server"},
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		opts  SearchOptions
+		want  []string
+	}{
+		{"formula only", "fire", SearchOptions{FormulaOnly: true}, []string{}},
+		{"cask only", "fire", SearchOptions{CaskOnly: true}, []string{"firefox"}},
+		{"description only", "file", SearchOptions{DescOnly: true}, []string{"curl", "wget"}},
+		{"all description words", "file http", SearchOptions{DescOnly: true}, []string{"curl"}},
+		{"limit", "wget", SearchOptions{Limit: 1}, []string{"wget"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := searchNames(rankSearch(tc.query, items, tc.opts))
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("rankSearch(%q) = %v, want %v", tc.query, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCompleteNamesUsesSavedPrefixIndex(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", "")
+	client := &Client{CacheDir: t.TempDir()}
+
+	if names := client.CompleteNames("wg"); len(names) != 0 {
+		t.Fatalf("expected no names without an index, got %v", names)
+	}
+
+	idx := NewPrefixIndex()
+	idx.BuildIndex([]SearchItem{
+		{Name: "wget"},
+		{Name: "wget2"},
+		{Name: "wireguard-tools"},
+		{Name: "firefox", IsCask: true},
+	})
+	if err := idx.Save(filepath.Join(client.CacheDir, prefixIndexFileName)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := client.CompleteNames("wge"), []string{"wget", "wget2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CompleteNames(wge) = %v, want %v", got, want)
+	}
+	if got := client.CompleteNames("w"); len(got) != 3 {
+		t.Errorf("CompleteNames(w) = %v, want the three w names", got)
+	}
+}
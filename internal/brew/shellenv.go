@@ -0,0 +1,97 @@
+package brew
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// shellenvMarker starts the block doctor --fix appends to a shell rc file,
+// and identifies it on later runs.
+const shellenvMarker = "# fastbrew shellenv"
+
+// DetectShell returns the name of the shell in $SHELL ("bash", "zsh",
+// "fish", ...), or "sh" when it is not set.
+func DetectShell() string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return filepath.Base(shell)
+	}
+	return "sh"
+}
+
+// ShellEnv returns the commands that put prefix's bin, sbin, man pages and
+// info pages on the search paths of shell. Shells other than fish get POSIX
+// sh syntax. An empty MANPATH entry is kept so the system man pages are
+// still found.
+func ShellEnv(prefix, shell string) string {
+	bin, sbin := filepath.Join(prefix, "bin"), filepath.Join(prefix, "sbin")
+	man, info := filepath.Join(prefix, "share", "man"), filepath.Join(prefix, "share", "info")
+	if shell == "fish" {
+		return fmt.Sprintf(`set --global --export PATH "%s" "%s" $PATH
+set --global --export MANPATH "%s" $MANPATH ""
+set --global --export INFOPATH "%s" $INFOPATH
+`, bin, sbin, man, info)
+	}
+	return fmt.Sprintf(`export PATH="%s:%s${PATH+:$PATH}"
+export MANPATH="%s:${MANPATH-}"
+export INFOPATH="%s:${INFOPATH-}"
+`, bin, sbin, man, info)
+}
+
+// ShellRCFile returns the startup file shell reads for interactive
+// sessions under home: ~/.zshrc (or $ZDOTDIR/.zshrc), ~/.bashrc
+// (~/.bash_profile on macOS, where terminals start login shells), fish's
+// config.fish, and ~/.profile for anything else.
+func ShellRCFile(shell, home string) string {
+	switch shell {
+	case "zsh":
+		if dir := os.Getenv("ZDOTDIR"); dir != "" {
+			return filepath.Join(dir, ".zshrc")
+		}
+		return filepath.Join(home, ".zshrc")
+	case "bash":
+		if runtime.GOOS == "darwin" {
+			return filepath.Join(home, ".bash_profile")
+		}
+		return filepath.Join(home, ".bashrc")
+	case "fish":
+		config := os.Getenv("XDG_CONFIG_HOME")
+		if config == "" {
+			config = filepath.Join(home, ".config")
+		}
+		return filepath.Join(config, "fish", "config.fish")
+	default:
+		return filepath.Join(home, ".profile")
+	}
+}
+
+// shellRCConfigured reports whether rc already adds prefix/bin to PATH,
+// through the block appendShellEnv writes or a line of its own.
+func shellRCConfigured(rc, prefix string) bool {
+	data, err := os.ReadFile(rc)
+	if err != nil {
+		return false
+	}
+	text := string(data)
+	return strings.Contains(text, shellenvMarker) || strings.Contains(text, filepath.Join(prefix, "bin"))
+}
+
+// appendShellEnv appends the ShellEnv block for prefix to rc, creating the
+// file and its directory when needed.
+func appendShellEnv(rc, prefix, shell string) error {
+	if err := os.MkdirAll(filepath.Dir(rc), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(rc, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	block := fmt.Sprintf("\n%s (added by fastbrew doctor --fix)\n%s", shellenvMarker, ShellEnv(prefix, shell))
+	if _, err := f.WriteString(block); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
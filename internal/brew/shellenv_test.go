@@ -0,0 +1,97 @@
+package brew
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestShellEnvSetsSearchPaths(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	script := ShellEnv("/opt/homebrew", "sh") + `echo "$PATH|$MANPATH|$INFOPATH"`
+
+	cmd := exec.Command("sh", "-c", script)
+	cmd.Env = []string{"PATH=/usr/bin:/bin"}
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("sh failed: %v", err)
+	}
+	want := "/opt/homebrew/bin:/opt/homebrew/sbin:/usr/bin:/bin|/opt/homebrew/share/man:|/opt/homebrew/share/info:"
+	if got := strings.TrimSpace(string(out)); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestShellEnvFish(t *testing.T) {
+	env := ShellEnv("/opt/homebrew", "fish")
+	for _, want := range []string{
+		`set --global --export PATH "/opt/homebrew/bin" "/opt/homebrew/sbin" $PATH`,
+		`set --global --export INFOPATH "/opt/homebrew/share/info" $INFOPATH`,
+	} {
+		if !strings.Contains(env, want) {
+			t.Errorf("fish shellenv missing %q:\n%s", want, env)
+		}
+	}
+}
+
+func TestShellRCFile(t *testing.T) {
+	t.Setenv("ZDOTDIR", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	bashrc := "/home/me/.bashrc"
+	if runtime.GOOS == "darwin" {
+		bashrc = "/home/me/.bash_profile"
+	}
+	for shell, want := range map[string]string{
+		"zsh":  "/home/me/.zshrc",
+		"bash": bashrc,
+		"fish": "/home/me/.config/fish/config.fish",
+		"ksh":  "/home/me/.profile",
+	} {
+		if got := ShellRCFile(shell, "/home/me"); got != want {
+			t.Errorf("%s: got %s, want %s", shell, got, want)
+		}
+	}
+	t.Setenv("ZDOTDIR", "/home/me/.config/zsh")
+	if got := ShellRCFile("zsh", "/home/me"); got != "/home/me/.config/zsh/.zshrc" {
+		t.Errorf("zsh with ZDOTDIR: got %s", got)
+	}
+}
+
+func TestDoctorPathFixAppendsShellEnv(t *testing.T) {
+	client, _ := newCleanupTestClient(t)
+	home, _ := os.UserHomeDir()
+	t.Setenv("SHELL", "/bin/zsh")
+	t.Setenv("ZDOTDIR", "")
+	t.Setenv("PATH", "/usr/bin:/bin")
+	rc := filepath.Join(home, ".zshrc")
+	if err := os.WriteFile(rc, []byte("alias ll='ls -l'\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDoctor(client, false)
+	if result := d.checkPathConfiguration(); result.Status != StatusWarning || !strings.Contains(result.Message, rc) {
+		t.Fatalf("expected a warning naming %s, got %s: %s", rc, result.Status, result.Message)
+	}
+
+	d.Fix = true
+	if result := d.checkPathConfiguration(); !result.Fixed || result.Status != StatusOK {
+		t.Fatalf("expected the fix to apply, got %s: %s", result.Status, result.Message)
+	}
+	data, _ := os.ReadFile(rc)
+	if !strings.HasPrefix(string(data), "alias ll='ls -l'\n") || !strings.Contains(string(data), ShellEnv(client.Prefix, "zsh")) {
+		t.Fatalf("rc file not appended to:\n%s", data)
+	}
+
+	// A second run must not append again.
+	if result := d.checkPathConfiguration(); result.Fixed || !strings.Contains(result.Suggestion, "source") {
+		t.Errorf("expected a restart hint once configured, got %s: %s (%s)", result.Status, result.Message, result.Suggestion)
+	}
+	if again, _ := os.ReadFile(rc); string(again) != string(data) {
+		t.Error("shellenv was appended twice")
+	}
+}
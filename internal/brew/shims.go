@@ -0,0 +1,179 @@
+package brew
+
+import (
+	"bufio"
+	"fastbrew/internal/atomicfile"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// shimHeader starts the line of a shim naming its package and target, so
+// shims can be listed and checked without a separate record.
+const shimHeader = "# fastbrew shim:"
+
+// Shim is a wrapper script in ShimDir that runs a keg binary.
+type Shim struct {
+	Name    string `json:"name"`
+	Package string `json:"package"`
+	Target  string `json:"target"`
+	Path    string `json:"path"`
+}
+
+// ShimDir returns the directory under the prefix holding fastbrew's shims.
+// It is only created once a shim is added, and must come before the
+// prefix's bin in PATH for shims to take effect.
+func (c *Client) ShimDir() string {
+	return filepath.Join(c.Prefix, "shims")
+}
+
+// CreateShims writes shims for the installed formula name, for keg-only
+// tools that are not linked or binaries wanted under another name (e.g.
+// python3.12 as python). Each entry of bins is a binary in the keg's bin
+// directory, optionally followed by "=" and the shim's name; no entries
+// shims every executable there. Targets go through opt/<name> when it
+// exists, so shims keep working across upgrades, and the shim puts the
+// keg's bin first in PATH so the tool finds its siblings.
+func (c *Client) CreateShims(name string, bins []string) ([]Shim, error) {
+	binDir := filepath.Join(c.Prefix, "opt", name, "bin")
+	if _, err := os.Stat(binDir); err != nil {
+		version := c.currentKegVersion(name)
+		if version == "" {
+			return nil, kindErrorf(KindNotFound, "%s is not installed", name)
+		}
+		binDir = filepath.Join(c.Cellar, name, version, "bin")
+	}
+
+	if len(bins) == 0 {
+		entries, err := os.ReadDir(binDir)
+		if err != nil {
+			return nil, fmt.Errorf("%s has no binaries to shim", name)
+		}
+		for _, entry := range entries {
+			if info, err := os.Stat(filepath.Join(binDir, entry.Name())); err == nil && !info.IsDir() && info.Mode()&0111 != 0 {
+				bins = append(bins, entry.Name())
+			}
+		}
+		if len(bins) == 0 {
+			return nil, fmt.Errorf("%s has no binaries to shim", name)
+		}
+	}
+
+	if err := os.MkdirAll(c.ShimDir(), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create shim directory: %w", err)
+	}
+	var shims []Shim
+	for _, entry := range bins {
+		bin, shimName, renamed := strings.Cut(entry, "=")
+		if !renamed {
+			shimName = bin
+		}
+		if bin == "" || shimName == "" || strings.ContainsRune(bin, filepath.Separator) || strings.ContainsRune(shimName, filepath.Separator) {
+			return shims, fmt.Errorf("invalid shim %q: want binary or binary=name", entry)
+		}
+		target := filepath.Join(binDir, bin)
+		if info, err := os.Stat(target); err != nil || info.IsDir() {
+			return shims, fmt.Errorf("%s has no binary %s", name, bin)
+		}
+		shim := Shim{Name: shimName, Package: name, Target: target, Path: filepath.Join(c.ShimDir(), shimName)}
+		if err := atomicfile.WriteFile(shim.Path, []byte(shimScript(shim, binDir)), 0755); err != nil {
+			return shims, fmt.Errorf("failed to write shim %s: %w", shimName, err)
+		}
+		shims = append(shims, shim)
+	}
+	return shims, nil
+}
+
+// shimScript returns the sh script for shim.
+func shimScript(shim Shim, binDir string) string {
+	return fmt.Sprintf(`#!/bin/sh
+%s package=%s target=%s
+PATH=%s:"$PATH"
+export PATH
+exec %s "$@"
+`, shimHeader, shim.Package, shim.Target, shellQuote(binDir), shellQuote(shim.Target))
+}
+
+// shellQuote quotes s for sh.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Shims returns the shims in ShimDir, sorted by name. Files without a shim
+// header were not written by fastbrew and are skipped.
+func (c *Client) Shims() ([]Shim, error) {
+	entries, err := os.ReadDir(c.ShimDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var shims []Shim
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if shim, ok := readShim(filepath.Join(c.ShimDir(), entry.Name())); ok {
+			shims = append(shims, shim)
+		}
+	}
+	sort.Slice(shims, func(i, j int) bool { return shims[i].Name < shims[j].Name })
+	return shims, nil
+}
+
+// readShim parses the header of the shim at path.
+func readShim(path string) (Shim, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Shim{}, false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for i := 0; i < 3 && scanner.Scan(); i++ {
+		rest, ok := strings.CutPrefix(scanner.Text(), shimHeader)
+		if !ok {
+			continue
+		}
+		shim := Shim{Name: filepath.Base(path), Path: path}
+		pkg, target, _ := strings.Cut(strings.TrimSpace(rest), " target=")
+		shim.Package = strings.TrimPrefix(pkg, "package=")
+		shim.Target = target
+		return shim, shim.Package != "" && shim.Target != ""
+	}
+	return Shim{}, false
+}
+
+// RemoveShims removes the shims named, or all shims of a package named,
+// and returns them. ShimDir is removed once empty.
+func (c *Client) RemoveShims(names ...string) ([]Shim, error) {
+	shims, err := c.Shims()
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+	var removed []Shim
+	for _, shim := range shims {
+		if !wanted[shim.Name] && !wanted[shim.Package] {
+			continue
+		}
+		if err := os.Remove(shim.Path); err != nil && !os.IsNotExist(err) {
+			return removed, fmt.Errorf("failed to remove shim %s: %w", shim.Name, err)
+		}
+		removed = append(removed, shim)
+	}
+	os.Remove(c.ShimDir())
+	return removed, nil
+}
+
+// Broken reports whether the shim's target is missing or not executable,
+// e.g. after its formula was uninstalled.
+func (s Shim) Broken() bool {
+	info, err := os.Stat(s.Target)
+	return err != nil || info.IsDir() || info.Mode()&0111 == 0
+}
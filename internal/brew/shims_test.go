@@ -0,0 +1,109 @@
+package brew
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestCreateShimsRunsKegBinary(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shims are sh scripts")
+	}
+	c := newTransactionTestClient(t)
+	keg := makeKeg(t, c, "python@3.12", "3.12.4")
+	script := "#!/bin/sh\necho \"$0 $*\"\n"
+	if err := os.WriteFile(filepath.Join(keg, "bin", "python3.12"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Link("python@3.12", "3.12.4"); err != nil {
+		t.Fatal(err)
+	}
+
+	shims, err := c.CreateShims("python@3.12", []string{"python3.12=python"})
+	if err != nil {
+		t.Fatalf("CreateShims failed: %v", err)
+	}
+	want := filepath.Join(c.Prefix, "opt", "python@3.12", "bin", "python3.12")
+	if len(shims) != 1 || shims[0].Name != "python" || shims[0].Target != want {
+		t.Fatalf("unexpected shims %+v", shims)
+	}
+
+	out, err := exec.Command(shims[0].Path, "-V").Output()
+	if err != nil {
+		t.Fatalf("running shim: %v", err)
+	}
+	if got := strings.TrimSpace(string(out)); got != want+" -V" {
+		t.Errorf("shim ran %q, want %q", got, want+" -V")
+	}
+
+	listed, err := c.Shims()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 1 || listed[0] != shims[0] {
+		t.Errorf("Shims() = %+v, want %+v", listed, shims)
+	}
+
+	if _, err := c.CreateShims("python@3.12", []string{"python4"}); err == nil {
+		t.Error("expected an error for a missing binary")
+	}
+	if _, err := c.CreateShims("ruby", nil); err == nil {
+		t.Error("expected an error for a formula that is not installed")
+	}
+
+	removed, err := c.RemoveShims("python@3.12")
+	if err != nil || len(removed) != 1 {
+		t.Fatalf("RemoveShims = %+v, %v", removed, err)
+	}
+	if _, err := os.Stat(c.ShimDir()); !os.IsNotExist(err) {
+		t.Error("empty shim directory was not removed")
+	}
+}
+
+func TestDoctorChecksShims(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shims are sh scripts")
+	}
+	c := newTransactionTestClient(t)
+	makeKeg(t, c, "jq", "1.7.1")
+	makeKeg(t, c, "wget", "1.21")
+	if _, err := c.CreateShims("jq", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateShims("wget", nil); err != nil {
+		t.Fatal(err)
+	}
+	d := NewDoctor(c, false)
+
+	t.Setenv("PATH", "/usr/bin")
+	if result := d.checkShims(); result.Status != StatusWarning || len(result.Details) != 2 {
+		t.Errorf("shims outside PATH: %+v, want a warning naming both", result)
+	}
+
+	t.Setenv("PATH", c.ShimDir()+string(os.PathListSeparator)+filepath.Join(c.Prefix, "bin"))
+	if result := d.checkShims(); result.Status != StatusOK {
+		t.Errorf("shims first in PATH: %+v, want OK", result)
+	}
+	if result := d.checkPathConfiguration(); result.Status != StatusOK {
+		t.Errorf("PATH check with shims before bin: %+v, want OK", result)
+	}
+
+	if err := os.RemoveAll(filepath.Join(c.Cellar, "wget")); err != nil {
+		t.Fatal(err)
+	}
+	result := d.checkShims()
+	if result.Status != StatusWarning || len(result.Details) != 1 || !strings.HasPrefix(result.Details[0], "wget ->") {
+		t.Errorf("broken shim: %+v, want a warning naming wget", result)
+	}
+	d.Fix = true
+	if result := d.checkShims(); !result.Fixed {
+		t.Errorf("Fix did not remove the broken shim: %+v", result)
+	}
+	if shims, _ := c.Shims(); len(shims) != 1 || shims[0].Name != "jq" {
+		t.Errorf("after fix, shims = %+v, want only jq", shims)
+	}
+}
@@ -2,9 +2,15 @@ package brew
 
 import (
 	"encoding/json"
+	"fastbrew/internal/atomicfile"
+	"fastbrew/internal/auth"
+	"fastbrew/internal/cmdrunner"
+	"fastbrew/internal/log"
+	"fastbrew/internal/paths"
 	"fmt"
+	"log/slog"
+	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -65,25 +71,28 @@ type TapManager struct {
 	taps         map[string]Tap
 	mu           sync.RWMutex
 	onInvalid    func(event string)
+	// Logger receives the structured debug log (discarded when nil).
+	Logger *slog.Logger
+	// Credentials authenticates clones of private taps (anonymous when nil).
+	Credentials *auth.Store
+	// Runner runs git to clone taps and read their remotes (os/exec when
+	// nil).
+	Runner cmdrunner.CommandRunner
 }
 
 func NewTapManager() (*TapManager, error) {
 	detectHomebrewPaths()
 
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return nil, fmt.Errorf("could not get home directory: %w", err)
-	}
-
-	fastbrewDir := filepath.Join(homeDir, ".fastbrew")
-	if err := os.MkdirAll(fastbrewDir, 0755); err != nil {
+	stateDir := paths.StateDir()
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
 		return nil, fmt.Errorf("could not create fastbrew directory: %w", err)
 	}
 
-	registryPath := filepath.Join(fastbrewDir, "taps.json")
+	registryPath := filepath.Join(stateDir, "taps.json")
 	tm := &TapManager{
 		registryPath: registryPath,
 		taps:         make(map[string]Tap),
+		Logger:       log.For("tap"),
 	}
 
 	if err := tm.loadRegistry(); err != nil {
@@ -106,7 +115,15 @@ func (tm *TapManager) loadRegistry() error {
 
 	var taps []Tap
 	if err := json.Unmarshal(data, &taps); err != nil {
-		return fmt.Errorf("could not parse taps registry: %w", err)
+		// The registry only caches what is on disk; ListTaps rebuilds it
+		// from the tap directories.
+		moved, qErr := atomicfile.Quarantine(tm.registryPath)
+		if qErr != nil {
+			return fmt.Errorf("could not parse taps registry: %w", err)
+		}
+		tm.logger().Warn("corrupt taps registry quarantined", "path", moved, "error", err)
+		fmt.Fprintf(os.Stderr, "Warning: taps registry was corrupt and will be rebuilt (saved as %s)\n", moved)
+		return nil
 	}
 
 	for _, tap := range taps {
@@ -129,13 +146,36 @@ func (tm *TapManager) saveRegistry() error {
 		return fmt.Errorf("could not marshal taps registry: %w", err)
 	}
 
-	if err := os.WriteFile(tm.registryPath, data, 0644); err != nil {
+	if err := atomicfile.WriteFile(tm.registryPath, data, 0644); err != nil {
 		return fmt.Errorf("could not save taps registry: %w", err)
 	}
 
 	return nil
 }
 
+func (tm *TapManager) logger() *slog.Logger {
+	if tm.Logger == nil {
+		return log.Discard()
+	}
+	return tm.Logger
+}
+
+func (tm *TapManager) runner() cmdrunner.CommandRunner {
+	if tm.Runner == nil {
+		return &cmdrunner.DefaultCommandRunner{}
+	}
+	return tm.Runner
+}
+
+// remoteURL returns the origin remote of the tap clone at path, or "".
+func (tm *TapManager) remoteURL(path string) string {
+	output, err := tm.runner().Run("git", "-C", path, "remote", "get-url", "origin")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
 func (tm *TapManager) SetInvalidationHook(fn func(event string)) {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
@@ -179,6 +219,43 @@ func normalizeTapRepoInput(repo string) (string, string, error) {
 	return "", "", fmt.Errorf("invalid tap repo format: %s (expected user/repo or full URL)", repo)
 }
 
+// gitAuthEnv returns the environment for a git command against remoteURL.
+// A credential for the remote host is passed as an extra HTTP header
+// through GIT_CONFIG_* variables, so the token never appears in the
+// process arguments or the tap's .git/config.
+func (tm *TapManager) gitAuthEnv(remoteURL string) []string {
+	env := os.Environ()
+	u, err := url.Parse(remoteURL)
+	if err != nil || u.Scheme != "https" {
+		return env
+	}
+	cred, ok := tm.Credentials.Lookup(u.Hostname())
+	if !ok {
+		return env
+	}
+	tm.logger().Debug("authenticating tap clone", "host", u.Hostname(), "source", cred.Source)
+	return append(env,
+		"GIT_CONFIG_COUNT=1",
+		"GIT_CONFIG_KEY_0=http."+u.Scheme+"://"+u.Host+"/.extraHeader",
+		"GIT_CONFIG_VALUE_0=Authorization: "+cred.BasicHeader(),
+	)
+}
+
+// fastbrewTapsDir holds taps cloned when the Homebrew taps directory is
+// not writable, e.g. on machines without brew.
+func fastbrewTapsDir() string {
+	return filepath.Join(paths.StateDir(), "Taps")
+}
+
+// tapRoots returns the directories taps are kept in, Homebrew's first.
+func tapRoots() []string {
+	detectHomebrewPaths()
+	return []string{homebrewTapsDir, fastbrewTapsDir()}
+}
+
+// tapLocalPath returns where a tap lives: an existing clone in any tap
+// root, otherwise the Homebrew taps directory if it can be written to, or
+// the fastbrew-managed one.
 func tapLocalPath(repo string) string {
 	parts := strings.SplitN(repo, "/", 2)
 	if len(parts) != 2 {
@@ -190,21 +267,88 @@ func tapLocalPath(repo string) string {
 		repoName = "homebrew-" + repoName
 	}
 
-	return filepath.Join(homebrewTapsDir, user, repoName)
+	roots := tapRoots()
+	for _, root := range roots {
+		path := filepath.Join(root, user, repoName)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	root := roots[0]
+	if !dirWritable(root) && len(roots) > 1 {
+		root = roots[1]
+	}
+	return filepath.Join(root, user, repoName)
+}
+
+// dirWritable reports whether dir, or its nearest existing parent, can be
+// written to.
+func dirWritable(dir string) bool {
+	for {
+		if info, err := os.Stat(dir); err == nil {
+			if !info.IsDir() {
+				return false
+			}
+			f, err := os.CreateTemp(dir, ".fastbrew-write-test-*")
+			if err != nil {
+				return false
+			}
+			f.Close()
+			os.Remove(f.Name())
+			return true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
 }
 
 func (tm *TapManager) ListTaps() ([]Tap, error) {
 	taps := make([]Tap, 0)
 
-	entries, err := os.ReadDir(homebrewTapsDir)
-	if err != nil {
-		if os.IsNotExist(err) {
-			tm.mu.RLock()
-			for _, tap := range tm.taps {
+	for _, root := range tapRoots() {
+		found, err := tm.scanTapRoot(root)
+		if err != nil {
+			return nil, err
+		}
+		taps = append(taps, found...)
+	}
+
+	tm.mu.RLock()
+	for name, tap := range tm.taps {
+		found := false
+		for _, t := range taps {
+			if t.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found && tap.LocalPath != "" {
+			if _, err := os.Stat(tap.LocalPath); err == nil {
 				taps = append(taps, tap)
 			}
-			tm.mu.RUnlock()
-			return taps, nil
+		}
+	}
+	tm.mu.RUnlock()
+
+	if err := tm.saveRegistry(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not save tap registry: %v\n", err)
+	}
+
+	return taps, nil
+}
+
+// scanTapRoot lists the git clones in one tap root and records them in the
+// registry.
+func (tm *TapManager) scanTapRoot(root string) ([]Tap, error) {
+	var taps []Tap
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
 		}
 		return nil, err
 	}
@@ -214,7 +358,7 @@ func (tm *TapManager) ListTaps() ([]Tap, error) {
 			continue
 		}
 
-		userDir := filepath.Join(homebrewTapsDir, userEntry.Name())
+		userDir := filepath.Join(root, userEntry.Name())
 		repoEntries, err := os.ReadDir(userDir)
 		if err != nil {
 			continue
@@ -250,10 +394,7 @@ func (tm *TapManager) ListTaps() ([]Tap, error) {
 				tap.InstalledAt = stat.ModTime()
 			}
 
-			cmd := exec.Command("git", "-C", tapPath, "remote", "get-url", "origin")
-			if output, err := cmd.Output(); err == nil {
-				tap.RemoteURL = strings.TrimSpace(string(output))
-			}
+			tap.RemoteURL = tm.remoteURL(tapPath)
 
 			taps = append(taps, tap)
 
@@ -263,27 +404,6 @@ func (tm *TapManager) ListTaps() ([]Tap, error) {
 		}
 	}
 
-	tm.mu.RLock()
-	for name, tap := range tm.taps {
-		found := false
-		for _, t := range taps {
-			if t.Name == name {
-				found = true
-				break
-			}
-		}
-		if !found && tap.LocalPath != "" {
-			if _, err := os.Stat(tap.LocalPath); err == nil {
-				taps = append(taps, tap)
-			}
-		}
-	}
-	tm.mu.RUnlock()
-
-	if err := tm.saveRegistry(); err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: could not save tap registry: %v\n", err)
-	}
-
 	return taps, nil
 }
 
@@ -292,7 +412,13 @@ func (tm *TapManager) Tap(repo string, full bool) error {
 	if err != nil {
 		return err
 	}
+	return tm.TapWithRemote(repoName, remoteURL, full)
+}
 
+// TapWithRemote taps repoName from an explicit remote, such as a
+// repository on GitHub Enterprise or another git host.
+func (tm *TapManager) TapWithRemote(repoName, remoteURL string, full bool) error {
+	repo := repoName
 	if remoteURL == "" {
 		return fmt.Errorf("could not determine remote URL for %s", repo)
 	}
@@ -303,12 +429,8 @@ func (tm *TapManager) Tap(repo string, full bool) error {
 	}
 
 	if _, err := os.Stat(localPath); err == nil {
-		cmd := exec.Command("git", "-C", localPath, "remote", "get-url", "origin")
-		if output, err := cmd.Output(); err == nil {
-			existingRemote := strings.TrimSpace(string(output))
-			if existingRemote != remoteURL {
-				return fmt.Errorf("tap already exists with different remote: %s (expected %s)", existingRemote, remoteURL)
-			}
+		if existingRemote := tm.remoteURL(localPath); existingRemote != "" && existingRemote != remoteURL {
+			return fmt.Errorf("tap already exists with different remote: %s (expected %s)", existingRemote, remoteURL)
 		}
 
 		fmt.Printf("Tap %s already present\n", repoName)
@@ -337,14 +459,15 @@ func (tm *TapManager) Tap(repo string, full bool) error {
 	}
 	args = append(args, remoteURL, localPath)
 
-	cmd := exec.Command("git", args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
+	tm.logger().Info("cloning tap", "tap", repoName, "remote", remoteURL, "path", localPath, "full", full)
+	opts := cmdrunner.Options{Env: tm.gitAuthEnv(remoteURL), Stdout: os.Stdout, Stderr: os.Stderr}
+	if _, err := tm.runner().RunWithOptions(opts, "git", args...); err != nil {
+		tm.logger().Error("tap clone failed", "tap", repoName, "error", err)
 		return fmt.Errorf("failed to clone %s: %w", remoteURL, err)
 	}
 
 	if err := tm.validateTapContents(localPath); err != nil {
+		tm.logger().Error("tap validation failed", "tap", repoName, "error", err)
 		os.RemoveAll(localPath)
 		return fmt.Errorf("tap validation failed: %w", err)
 	}
@@ -419,11 +542,11 @@ func (tm *TapManager) Untap(repo string, force bool) error {
 	}
 
 	if localPath == "" {
-		return fmt.Errorf("tap %s not found", repoName)
+		return kindErrorf(KindNotFound, "tap %s not found", repoName)
 	}
 
 	if _, err := os.Stat(localPath); err != nil {
-		return fmt.Errorf("tap %s not found", repoName)
+		return kindErrorf(KindNotFound, "tap %s not found", repoName)
 	}
 
 	if !force {
@@ -437,6 +560,7 @@ func (tm *TapManager) Untap(repo string, force bool) error {
 		}
 	}
 
+	tm.logger().Info("removing tap", "tap", repoName, "path", localPath, "force", force)
 	if err := os.RemoveAll(localPath); err != nil {
 		return fmt.Errorf("failed to remove tap directory: %w", err)
 	}
@@ -518,17 +642,13 @@ func (tm *TapManager) GetTapInfo(repo string, installedOnly bool) (*TapInfo, err
 	}
 
 	if localPath == "" {
-		return nil, fmt.Errorf("tap %s not found", repoName)
+		return nil, kindErrorf(KindNotFound, "tap %s not found", repoName)
 	}
 	if _, err := os.Stat(localPath); err != nil {
-		return nil, fmt.Errorf("tap %s not found", repoName)
+		return nil, kindErrorf(KindNotFound, "tap %s not found", repoName)
 	}
 
-	cmd := exec.Command("git", "-C", localPath, "remote", "get-url", "origin")
-	remoteURL := ""
-	if output, err := cmd.Output(); err == nil {
-		remoteURL = strings.TrimSpace(string(output))
-	}
+	remoteURL := tm.remoteURL(localPath)
 
 	tap := Tap{
 		Name:      repoName,
@@ -2,8 +2,10 @@ package brew
 
 import (
 	"archive/tar"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"fastbrew/internal/progress"
 	"fmt"
 	"io"
 	"os"
@@ -77,10 +79,13 @@ func NewTapDownloader(c *Client) *TapDownloader {
 }
 
 func (d *TapDownloader) Download(url, expectedSHA, name string) (string, error) {
-	cacheDir, _ := d.client.GetCacheDir()
-	tarPath := filepath.Join(cacheDir, fmt.Sprintf("%s-tap.bottle", name))
-
-	if err := d.client.DownloadWithProgress(url, tarPath, expectedSHA, nil); err != nil {
+	var tracker progress.ProgressTracker
+	if d.client.ProgressManager != nil {
+		tracker = d.client.ProgressManager.Register(name, url)
+		defer d.client.ProgressManager.Unregister(name)
+	}
+	tarPath, err := d.client.fetchCached(context.Background(), url, fmt.Sprintf("%s-tap.bottle", name), expectedSHA, tracker)
+	if err != nil {
 		return "", fmt.Errorf("download failed: %w", err)
 	}
 
@@ -90,7 +95,7 @@ func (d *TapDownloader) Download(url, expectedSHA, name string) (string, error)
 			return "", fmt.Errorf("checksum verification failed: %w", err)
 		}
 		if actualSHA != expectedSHA {
-			return "", fmt.Errorf("checksum mismatch: expected %s, got %s", expectedSHA, actualSHA)
+			return "", kindErrorf(KindChecksum, "checksum mismatch: expected %s, got %s", expectedSHA, actualSHA)
 		}
 	}
 
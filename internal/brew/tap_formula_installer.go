@@ -1,6 +1,7 @@
 package brew
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -57,7 +58,7 @@ func (i *TapFormulaInstaller) InstallTapFormula(ref string, opts InstallOptions)
 		return err
 	}
 	if installed {
-		fmt.Printf("  ✅ %s is already installed\n", resolved.Name)
+		i.client.printf("  ✅ %s is already installed\n", resolved.Name)
 		return nil
 	}
 
@@ -72,10 +73,12 @@ func (i *TapFormulaInstaller) InstallTapFormula(ref string, opts InstallOptions)
 }
 
 func (i *TapFormulaInstaller) installDependencies(meta *TapFormulaMetadata, opts InstallOptions) error {
-	for _, dep := range meta.RuntimeDeps {
+	deps := dependencyList(opts.Deps, meta.RuntimeDeps, meta.RecommendedDeps, meta.OptionalDeps, nil, nil)
+	for _, d := range deps {
+		dep := d.name
 		cellarPath := filepath.Join(i.client.Cellar, dep)
 		if _, err := os.Stat(cellarPath); os.IsNotExist(err) {
-			if err := i.client.InstallNativeWithOptions([]string{dep}, opts); err != nil {
+			if err := i.client.InstallNativeWithOptions(context.Background(), []string{dep}, opts); err != nil {
 				return fmt.Errorf("failed to install dependency %s: %w", dep, err)
 			}
 		}
@@ -129,20 +132,13 @@ func (i *TapFormulaInstaller) downloadAndInstall(meta *TapFormulaMetadata, resol
 }
 
 func (i *TapFormulaInstaller) downloadTapBottle(url, sha256, name string) (string, error) {
-	cacheDir, _ := i.client.GetCacheDir()
-	tarPath := filepath.Join(cacheDir, fmt.Sprintf("%s-tap.bottle", name))
-
 	var tracker progress.ProgressTracker
 	if i.client.ProgressManager != nil {
 		tracker = i.client.ProgressManager.Register(name, url)
 		defer i.client.ProgressManager.Unregister(name)
 	}
 
-	if err := i.client.DownloadWithProgress(url, tarPath, sha256, tracker); err != nil {
-		return "", err
-	}
-
-	return tarPath, nil
+	return i.client.fetchCached(context.Background(), url, fmt.Sprintf("%s-tap.bottle", name), sha256, tracker)
 }
 
 func (i *TapFormulaInstaller) stageFiles(meta *TapFormulaMetadata, versionPath string) error {
@@ -201,19 +197,19 @@ func (i *TapFormulaInstaller) linkFormula(name, version string) error {
 		return err
 	}
 	if !result.Success {
-		fmt.Printf("  ⚠️  Link completed with errors for %s\n", name)
+		i.client.printf("  ⚠️  Link completed with errors for %s\n", name)
 	}
 	return nil
 }
 
 func (i *TapFormulaInstaller) fallbackToBrew(ref string, cause error) error {
-	fmt.Printf("  ⚠️  Falling back to brew for %s: %v\n", ref, cause)
+	i.client.printf("  ⚠️  Falling back to brew for %s: %v\n", ref, cause)
 	ref = strings.TrimPrefix(ref, "homebrew/")
 	return i.client.InstallBrewFallback(ref)
 }
 
 func (i *TapFormulaInstaller) fallbackToBrewWithUnsupported(ref string, meta *TapFormulaMetadata) error {
-	fmt.Printf("  ⚠️  Falling back to brew for %s (unsupported stanzas: %v)\n", ref, meta.UnsupportedStanzas)
+	i.client.printf("  ⚠️  Falling back to brew for %s (unsupported stanzas: %v)\n", ref, meta.UnsupportedStanzas)
 	ref = strings.TrimPrefix(ref, "homebrew/")
 	return i.client.InstallBrewFallback(ref)
 }
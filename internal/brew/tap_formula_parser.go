@@ -4,12 +4,18 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
 )
 
 var (
 	reVersion        = regexp.MustCompile(`^\s*version\s+['"]([^'"]+)['"]`)
+	reDesc           = regexp.MustCompile(`^\s*desc\s+['"](.*)['"]\s*$`)
+	reHomepage       = regexp.MustCompile(`^\s*homepage\s+['"]([^'"]+)['"]`)
+	reSourceURL      = regexp.MustCompile(`^\s*url\s+['"]([^'"]+)['"]`)
+	reURLVersion     = regexp.MustCompile(`v?(\d+(?:\.\d+)+)`)
 	reRevision       = regexp.MustCompile(`^\s*revision\s+(\d+)`)
 	reDependsOn      = regexp.MustCompile(`^\s*depends_on\s+(.+)$`)
 	reOnMacOS        = regexp.MustCompile(`^\s*on_macos\s+`)
@@ -21,6 +27,7 @@ var (
 	reBottleRootURL  = regexp.MustCompile(`root_url\s+['"]([^'"]+)['"]`)
 	reBottleRebuild  = regexp.MustCompile(`rebuild\s+(\d+)`)
 	reBottleSHA256   = regexp.MustCompile(`sha256\s+['"]([a-f0-9]+)['"]\s+=>\s+['"]([^'"]+)['"]:`)
+	reBottleTagSHA   = regexp.MustCompile(`(\w+):\s*['"]([a-f0-9]{64})['"]`)
 	reBinInstall     = regexp.MustCompile(`^\s*bin\.install(?:\s+(.+))?$`)
 	reSbinInstall    = regexp.MustCompile(`^\s*sbin\.install(?:\s+(.+))?$`)
 	reLibexecInstall = regexp.MustCompile(`^\s*libexec\.install(?:\s+(.+))?$`)
@@ -62,6 +69,7 @@ func ParseTapFormula(formulaPath string) (*TapFormulaMetadata, error) {
 	if err := parseFormulaContent(string(content), meta); err != nil {
 		return nil, err
 	}
+	meta.Name = strings.TrimSuffix(filepath.Base(formulaPath), ".rb")
 
 	return meta, nil
 }
@@ -70,6 +78,7 @@ func parseFormulaContent(content string, meta *TapFormulaMetadata) error {
 	scanner := bufio.NewScanner(strings.NewReader(content))
 	state := StateNormal
 	lineNum := 0
+	sourceURL := ""
 
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -95,7 +104,7 @@ func parseFormulaContent(content string, meta *TapFormulaMetadata) error {
 			continue
 		}
 
-		if strings.HasPrefix(line, "end") && state != StateBottleBlock {
+		if strings.TrimSpace(line) == "end" && state != StateBottleBlock {
 			if state == StateOnMacOS || state == StateOnLinux {
 				state = StateNormal
 			}
@@ -134,21 +143,25 @@ func parseFormulaContent(content string, meta *TapFormulaMetadata) error {
 
 		if reBottleBlock.MatchString(line) {
 			state = StateBottleBlock
+			if meta.BinaryBottle == nil {
+				meta.BinaryBottle = &BottleInfo{}
+			}
 			continue
 		}
 
 		if state == StateBottleBlock {
-			if strings.HasPrefix(line, "end") {
+			if strings.TrimSpace(line) == "end" {
 				state = StateNormal
 				continue
 			}
 
 			if match := reBottleRootURL.FindStringSubmatch(line); match != nil {
 				meta.RootURL = match[1]
+				meta.BinaryBottle.RootURL = match[1]
 			}
 
 			if match := reBottleRebuild.FindStringSubmatch(line); match != nil {
-				fmt.Sscanf(match[1], "%d", &meta.Revision)
+				fmt.Sscanf(match[1], "%d", &meta.BinaryBottle.Rebuild)
 			}
 
 			if matches := reBottleSHA256.FindAllStringSubmatch(line, -1); len(matches) > 0 {
@@ -158,10 +171,32 @@ func parseFormulaContent(content string, meta *TapFormulaMetadata) error {
 						meta.SHA256s[tag] = m[1]
 					}
 				}
+			} else if strings.HasPrefix(strings.TrimSpace(line), "sha256 ") {
+				// sha256 cellar: :any, arm64_sonoma: "..."
+				for _, m := range reBottleTagSHA.FindAllStringSubmatch(line, -1) {
+					if m[1] != "cellar" {
+						meta.SHA256s[m[1]] = m[2]
+					}
+				}
 			}
 			continue
 		}
 
+		if match := reDesc.FindStringSubmatch(line); match != nil && meta.Description == "" {
+			meta.Description = match[1]
+			continue
+		}
+
+		if match := reHomepage.FindStringSubmatch(line); match != nil && meta.Homepage == "" {
+			meta.Homepage = match[1]
+			continue
+		}
+
+		if match := reSourceURL.FindStringSubmatch(line); match != nil && sourceURL == "" {
+			sourceURL = match[1]
+			continue
+		}
+
 		if match := reVersion.FindStringSubmatch(line); match != nil {
 			meta.Version = match[1]
 			continue
@@ -173,9 +208,17 @@ func parseFormulaContent(content string, meta *TapFormulaMetadata) error {
 		}
 
 		if reDependsOn.MatchString(line) {
-			dep := extractDependsOn(line)
-			if dep != "" {
+			// Dependencies of the other platform do not apply here.
+			if (state == StateOnMacOS && runtime.GOOS != "darwin") || (state == StateOnLinux && runtime.GOOS != "linux") {
+				continue
+			}
+			switch dep, kind := extractDependsOn(line); kind {
+			case DependencyRuntime:
 				meta.RuntimeDeps = append(meta.RuntimeDeps, dep)
+			case DependencyRecommended:
+				meta.RecommendedDeps = append(meta.RecommendedDeps, dep)
+			case DependencyOptional:
+				meta.OptionalDeps = append(meta.OptionalDeps, dep)
 			}
 			continue
 		}
@@ -250,6 +293,13 @@ func parseFormulaContent(content string, meta *TapFormulaMetadata) error {
 	meta.OnMacOS = true
 	meta.OnLinux = true
 
+	if meta.Version == "" && sourceURL != "" {
+		// Like Homebrew, fall back to the version in the source URL.
+		if match := reURLVersion.FindStringSubmatch(filepath.Base(sourceURL)); match != nil {
+			meta.Version = match[1]
+		}
+	}
+
 	if meta.Version == "" {
 		return fmt.Errorf("version not found in formula")
 	}
@@ -257,17 +307,26 @@ func parseFormulaContent(content string, meta *TapFormulaMetadata) error {
 	return nil
 }
 
-func extractDependsOn(line string) string {
+// extractDependsOn returns the formula a depends_on line names and its
+// dependency type, or "" for lines without a formula name.
+func extractDependsOn(line string) (string, string) {
 	re := regexp.MustCompile(`['"]([^'"]+)['"]`)
 	matches := re.FindAllStringSubmatch(line, -1)
-	if len(matches) > 0 {
-		dep := matches[0][1]
-		if strings.Contains(line, ":build") || strings.Contains(line, ":test") {
-			return ""
-		}
-		return dep
+	if len(matches) == 0 {
+		return "", ""
+	}
+	dep := matches[0][1]
+	switch {
+	case strings.Contains(line, ":build"):
+		return dep, DependencyBuild
+	case strings.Contains(line, ":test"):
+		return dep, DependencyTest
+	case strings.Contains(line, ":recommended"):
+		return dep, DependencyRecommended
+	case strings.Contains(line, ":optional"):
+		return dep, DependencyOptional
 	}
-	return ""
+	return dep, DependencyRuntime
 }
 
 func extractInstallArgs(line, prefix string) []string {
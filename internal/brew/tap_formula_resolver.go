@@ -36,7 +36,7 @@ func (r *TapFormulaResolver) resolveFullRef(pkg string) (*ResolvedFormula, error
 	}
 
 	if tap.LocalPath == "" {
-		return nil, fmt.Errorf("tap not found: %s", tapName)
+		return nil, kindErrorf(KindNotFound, "tap not found: %s", tapName)
 	}
 
 	formulaPath := r.findFormulaPath(tap.LocalPath, formulaName)
@@ -66,7 +66,7 @@ func (r *TapFormulaResolver) resolveShortNameWithTap(user, repo string) (*Resolv
 	}
 
 	if tap.LocalPath == "" {
-		return nil, fmt.Errorf("tap not found: %s", tapName)
+		return nil, kindErrorf(KindNotFound, "tap not found: %s", tapName)
 	}
 
 	return &ResolvedFormula{
@@ -110,7 +110,7 @@ func (r *TapFormulaResolver) resolveShortName(name string) (*ResolvedFormula, er
 
 	tap, ok := r.tapManager.GetTap(tapName)
 	if !ok {
-		return nil, fmt.Errorf("tap not found: %s", tapName)
+		return nil, kindErrorf(KindNotFound, "tap not found: %s", tapName)
 	}
 
 	formulaPath := r.findFormulaPath(tap.LocalPath, name)
@@ -154,7 +154,7 @@ func (r *TapFormulaResolver) ScanTapFormulas(tapName string) ([]string, error) {
 
 	tap, ok := r.tapManager.GetTap(tapName)
 	if !ok {
-		return nil, fmt.Errorf("tap not found: %s", tapName)
+		return nil, kindErrorf(KindNotFound, "tap not found: %s", tapName)
 	}
 
 	if tap.LocalPath == "" {
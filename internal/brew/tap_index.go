@@ -0,0 +1,205 @@
+package brew
+
+import (
+	"bufio"
+	"fastbrew/internal/auth"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sahilm/fuzzy"
+)
+
+// tapFormulae resolves formulae from taps into RemoteFormula so they
+// install through the same bottle pipeline as core formulae. Taps named in
+// fully qualified refs (user/repo/formula) are cloned on demand.
+type tapFormulae struct {
+	resolver *TapFormulaResolver
+	formulae map[string]*RemoteFormula // by short name
+}
+
+func newTapFormulae(creds *auth.Store) (*tapFormulae, error) {
+	tm, err := NewTapManager()
+	if err != nil {
+		return nil, err
+	}
+	tm.Credentials = creds
+	return &tapFormulae{
+		resolver: NewTapFormulaResolver(tm),
+		formulae: make(map[string]*RemoteFormula),
+	}, nil
+}
+
+// load resolves ref and returns its formula, whose Name is the short name
+// it is installed under.
+func (t *tapFormulae) load(ref string) (*RemoteFormula, error) {
+	short := shortFormulaName(ref)
+	if f, ok := t.formulae[short]; ok {
+		return f, nil
+	}
+
+	if strings.Count(ref, "/") == 1 {
+		return nil, fmt.Errorf("%s is a tap, not a formula (use user/repo/formula)", ref)
+	}
+	resolved, err := t.resolver.Resolve(ref)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := ParseTapFormula(resolved.FormulaPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", resolved.FullRef, err)
+	}
+
+	f := meta.remoteFormula(resolved.TapName)
+	if _, _, err := f.GetBottleInfo(); err != nil {
+		platform, _ := GetPlatform()
+		return nil, fmt.Errorf("%s has no bottle for %s; building tap formulae from source is not supported", resolved.FullRef, platform)
+	}
+	t.formulae[f.Name] = f
+	return f, nil
+}
+
+// lookupShort finds a formula by short name in the installed taps. It
+// returns "" if no tap has it.
+func (t *tapFormulae) lookupShort(name string) string {
+	resolved, err := t.resolver.Resolve(name)
+	if err != nil {
+		return ""
+	}
+	return resolved.FullRef
+}
+
+func shortFormulaName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
+
+// remoteFormula converts parsed tap formula metadata into the API shape.
+func (m *TapFormulaMetadata) remoteFormula(tapName string) *RemoteFormula {
+	f := &RemoteFormula{
+		Name:                    m.Name,
+		Desc:                    m.Description,
+		Homepage:                m.Homepage,
+		Versions:                Versions{Stable: m.Version},
+		Revision:                m.Revision,
+		Dependencies:            m.RuntimeDeps,
+		RecommendedDependencies: m.RecommendedDeps,
+		OptionalDependencies:    m.OptionalDeps,
+		KegOnly:                 m.KegOnly,
+	}
+
+	rootURL := m.RootURL
+	if rootURL == "" {
+		// Bottles of taps without a root_url live in the tap's GitHub
+		// Packages namespace.
+		user, repo, _ := strings.Cut(tapName, "/")
+		rootURL = "https://ghcr.io/v2/" + user + "/" + strings.TrimPrefix(repo, "homebrew-")
+	}
+	rebuild := 0
+	if m.BinaryBottle != nil {
+		rebuild = m.BinaryBottle.Rebuild
+	}
+
+	f.Bottle.Stable.Rebuild = rebuild
+	f.Bottle.Stable.RootURL = rootURL
+	f.Bottle.Stable.Files = make(map[string]BottleFile, len(m.SHA256s))
+	for tag, sha := range m.SHA256s {
+		// GetBottleURL also records SHAs under full URLs; skip those.
+		if strings.Contains(tag, "/") {
+			continue
+		}
+		f.Bottle.Stable.Files[tag] = BottleFile{
+			URL:    tapBottleURL(rootURL, f, tag, sha, rebuild),
+			SHA256: sha,
+		}
+	}
+	return f
+}
+
+// tapBottleURL builds a bottle URL the way Homebrew does: a blob URL for
+// GitHub Packages, otherwise a file name under the root URL.
+func tapBottleURL(rootURL string, f *RemoteFormula, tag, sha string, rebuild int) string {
+	rootURL = strings.TrimSuffix(rootURL, "/")
+	if strings.HasPrefix(rootURL, "https://ghcr.io/v2/") {
+		image := strings.ReplaceAll(strings.ReplaceAll(f.Name, "@", "/"), "+", "x")
+		return fmt.Sprintf("%s/%s/blobs/sha256:%s", rootURL, image, sha)
+	}
+	suffix := ".tar.gz"
+	if rebuild > 0 {
+		suffix = fmt.Sprintf(".%d.tar.gz", rebuild)
+	}
+	return fmt.Sprintf("%s/%s-%s.%s.bottle%s", rootURL, f.Name, f.FullVersion(), tag, suffix)
+}
+
+// TapSearchItems lists the formulae of third-party taps, named
+// user/repo/formula. The official homebrew/core and homebrew/cask taps are
+// skipped because the API index already covers them.
+func (c *Client) TapSearchItems() []SearchItem {
+	var items []SearchItem
+	for _, root := range tapRoots() {
+		users, err := os.ReadDir(root)
+		if err != nil {
+			continue
+		}
+		for _, user := range users {
+			repos, err := os.ReadDir(filepath.Join(root, user.Name()))
+			if err != nil {
+				continue
+			}
+			for _, repo := range repos {
+				repoName := strings.TrimPrefix(repo.Name(), "homebrew-")
+				if !repo.IsDir() || (user.Name() == "homebrew" && (repoName == "core" || repoName == "cask")) {
+					continue
+				}
+				tapName := user.Name() + "/" + repoName
+				items = append(items, tapFormulaSearchItems(filepath.Join(root, user.Name(), repo.Name()), tapName)...)
+			}
+		}
+	}
+	return items
+}
+
+func tapFormulaSearchItems(tapPath, tapName string) []SearchItem {
+	var items []SearchItem
+	seen := make(map[string]bool)
+	for _, pattern := range []string{"Formula/*.rb", "Formula/*/*.rb", "*.rb"} {
+		matches, _ := filepath.Glob(filepath.Join(tapPath, pattern))
+		for _, path := range matches {
+			name := strings.TrimSuffix(filepath.Base(path), ".rb")
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			items = append(items, SearchItem{Name: tapName + "/" + name, Desc: readFormulaDesc(path)})
+		}
+	}
+	return items
+}
+
+// readFormulaDesc returns the desc of a Ruby formula without parsing the
+// rest of it.
+func readFormulaDesc(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if match := reDesc.FindStringSubmatch(scanner.Text()); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
+
+// searchTapItems fuzzy-matches query against tap formulae. Tap names are
+// not in the prefix index, so the few of them are matched directly.
+func searchTapItems(query string, items []SearchItem) []SearchItem {
+	var result []SearchItem
+	for _, match := range fuzzy.FindFrom(query, searchSourceFromItems(items)) {
+		result = append(result, items[match.Index])
+	}
+	return result
+}
@@ -0,0 +1,120 @@
+package brew
+
+import (
+	"fastbrew/internal/paths"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const widgetFormula = `class Widget < Formula
+  desc "Makes widgets"
+  homepage "https://example.com/widget"
+  url "https://example.com/widget/archive/refs/tags/v1.4.2.tar.gz"
+  sha256 "0000000000000000000000000000000000000000000000000000000000000000"
+  license "MIT"
+
+  bottle do
+    root_url "https://ghcr.io/v2/acme/tools"
+    sha256 cellar: :any_skip_relocation, arm64_sonoma: "1111111111111111111111111111111111111111111111111111111111111111"
+    sha256 cellar: :any_skip_relocation, x86_64_linux: "2222222222222222222222222222222222222222222222222222222222222222"
+  end
+
+  depends_on "go" => :build
+  depends_on "acme/tools/gadget"
+
+  def install
+    system "go", "build", *std_go_args
+  end
+
+  test do
+    system bin/"widget", "--version"
+  end
+end
+`
+
+func TestTapFormulaRemoteFormula(t *testing.T) {
+	meta, err := ParseTapFormulaFromContent(widgetFormula)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta.Name = "widget"
+
+	f := meta.remoteFormula("acme/tools")
+	if f.Versions.Stable != "1.4.2" || f.Desc != "Makes widgets" {
+		t.Errorf("unexpected formula: version=%q desc=%q", f.Versions.Stable, f.Desc)
+	}
+	if len(f.Dependencies) != 1 || f.Dependencies[0] != "acme/tools/gadget" {
+		t.Errorf("dependencies = %v, want only the runtime dependency", f.Dependencies)
+	}
+
+	file, ok := f.Bottle.Stable.Files["x86_64_linux"]
+	want := "https://ghcr.io/v2/acme/tools/widget/blobs/sha256:" + strings.Repeat("2", 64)
+	if !ok || file.URL != want {
+		t.Errorf("x86_64_linux bottle = %+v, want URL %s", file, want)
+	}
+	if len(f.Bottle.Stable.Files) != 2 {
+		t.Errorf("expected 2 bottles, got %v", f.Bottle.Stable.Files)
+	}
+}
+
+func TestTapFormulaDependencyTypes(t *testing.T) {
+	content := strings.Replace(widgetFormula, `  depends_on "acme/tools/gadget"`, `  depends_on "acme/tools/gadget"
+  depends_on "jq" => :recommended
+  depends_on "yq" => :optional
+  depends_on "bats-core" => :test`, 1)
+	meta, err := ParseTapFormulaFromContent(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta.Name = "widget"
+
+	f := meta.remoteFormula("acme/tools")
+	if len(f.Dependencies) != 1 || f.Dependencies[0] != "acme/tools/gadget" {
+		t.Errorf("dependencies = %v, want only the runtime dependency", f.Dependencies)
+	}
+	if len(f.RecommendedDependencies) != 1 || f.RecommendedDependencies[0] != "jq" {
+		t.Errorf("recommended dependencies = %v, want [jq]", f.RecommendedDependencies)
+	}
+	if len(f.OptionalDependencies) != 1 || f.OptionalDependencies[0] != "yq" {
+		t.Errorf("optional dependencies = %v, want [yq]", f.OptionalDependencies)
+	}
+}
+
+func TestTapBottleURLPlainRoot(t *testing.T) {
+	f := &RemoteFormula{Name: "widget", Versions: Versions{Stable: "1.4.2"}, Revision: 1}
+	got := tapBottleURL("https://dl.example.com/bottles/", f, "arm64_sonoma", "abc", 2)
+	want := "https://dl.example.com/bottles/widget-1.4.2_1.arm64_sonoma.bottle.2.tar.gz"
+	if got != want {
+		t.Errorf("tapBottleURL = %q, want %q", got, want)
+	}
+}
+
+func TestTapSearchItems(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	formulaDir := filepath.Join(paths.StateDir(), "Taps", "acme", "homebrew-tools", "Formula")
+	if err := os.MkdirAll(formulaDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(formulaDir, "widget.rb"), []byte(widgetFormula), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	items := (&Client{}).TapSearchItems()
+	var found *SearchItem
+	for i := range items {
+		if items[i].Name == "acme/tools/widget" {
+			found = &items[i]
+		}
+	}
+	if found == nil || found.Desc != "Makes widgets" {
+		t.Fatalf("tap formula missing from search items: %+v", items)
+	}
+
+	if matches := searchTapItems("widg", items); len(matches) == 0 || matches[0].Name != "acme/tools/widget" {
+		t.Errorf("searchTapItems(widg) = %+v", matches)
+	}
+}
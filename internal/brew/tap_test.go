@@ -1,8 +1,12 @@
 package brew
 
 import (
+	"errors"
+	"fastbrew/internal/auth"
+	"fastbrew/internal/cmdrunner"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 )
@@ -298,3 +302,82 @@ func TestTapManagerConcurrentAccess(t *testing.T) {
 		<-done
 	}
 }
+
+func TestTapManagerRecoversCorruptRegistry(t *testing.T) {
+	registryPath := filepath.Join(t.TempDir(), "taps.json")
+	if err := os.WriteFile(registryPath, []byte(`[{"name": "test/ta`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tm := &TapManager{registryPath: registryPath, taps: make(map[string]Tap)}
+	if err := tm.loadRegistry(); err != nil {
+		t.Fatalf("corrupt registry should be recovered, got %v", err)
+	}
+	if len(tm.taps) != 0 {
+		t.Fatalf("expected an empty registry, got %v", tm.taps)
+	}
+	if moved, _ := filepath.Glob(registryPath + ".corrupt-*"); len(moved) != 1 {
+		t.Fatalf("expected the corrupt registry to be kept aside, got %v", moved)
+	}
+}
+
+// cloneRunner fakes git clone by creating a tap with one formula.
+type cloneRunner struct {
+	*cmdrunner.Mock
+	env []string
+}
+
+func (r *cloneRunner) RunWithOptions(opts cmdrunner.Options, name string, arg ...string) ([]byte, error) {
+	out, err := r.Mock.RunWithOptions(opts, name, arg...)
+	if err == nil && len(arg) > 0 && arg[0] == "clone" {
+		r.env = opts.Env
+		dir := filepath.Join(arg[len(arg)-1], "Formula")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+		return out, os.WriteFile(filepath.Join(dir, "tool.rb"), []byte("class Tool < Formula\nend\n"), 0644)
+	}
+	return out, err
+}
+
+func TestTapManagerClonesThroughRunner(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	detectHomebrewPaths()
+	origTapsDir := homebrewTapsDir
+	homebrewTapsDir = t.TempDir()
+	t.Cleanup(func() { homebrewTapsDir = origTapsDir })
+	remote := "https://git.example.com/acme/homebrew-tools.git"
+	runner := &cloneRunner{Mock: cmdrunner.NewMock()}
+	tm := &TapManager{
+		registryPath: filepath.Join(t.TempDir(), "taps.json"),
+		taps:         make(map[string]Tap),
+		Credentials:  auth.NewStore(map[string]string{"git.example.com": "secret"}),
+		Runner:       runner,
+	}
+
+	if err := tm.TapWithRemote("acme/tools", remote, false); err != nil {
+		t.Fatalf("TapWithRemote failed: %v", err)
+	}
+	tap, ok := tm.GetTap("acme/tools")
+	if !ok {
+		t.Fatal("expected the tap to be registered")
+	}
+	want := "git clone --depth=1 " + remote + " " + tap.LocalPath
+	if calls := runner.Calls(); len(calls) != 1 || calls[0] != want {
+		t.Errorf("calls = %v, want [%s]", calls, want)
+	}
+	if !slices.Contains(runner.env, "GIT_CONFIG_KEY_0=http.https://git.example.com/.extraHeader") {
+		t.Error("expected the clone to carry the credential for the remote host")
+	}
+
+	failing := cmdrunner.NewMock()
+	failing.SetError("git clone https://git.example.com/acme/homebrew-other.git "+tapLocalPath("acme/other"), errors.New("exit status 128"))
+	tm.Runner = failing
+	if err := tm.TapWithRemote("acme/other", "https://git.example.com/acme/homebrew-other.git", true); err == nil {
+		t.Error("expected a failed clone to fail the tap")
+	}
+	if _, ok := tm.GetTap("acme/other"); ok {
+		t.Error("a failed clone should not be registered")
+	}
+}
@@ -0,0 +1,491 @@
+package brew
+
+import (
+	"bufio"
+	"encoding/json"
+	"fastbrew/internal/paths"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TransactionStatusPending    = "pending"
+	TransactionStatusCommitted  = "committed"
+	TransactionStatusRolledBack = "rolled_back"
+
+	// StepKegCreated records a new Cellar/<name>/<version> directory.
+	StepKegCreated = "keg_created"
+	// StepSymlinkAdded records a symlink created where nothing was linked.
+	StepSymlinkAdded = "symlink_added"
+	// StepSymlinkReplaced records a symlink that replaced another symlink.
+	StepSymlinkReplaced = "symlink_replaced"
+	// StepTempDirCreated records a directory a bottle is extracted into
+	// before its keg is moved into place.
+	StepTempDirCreated = "temp_dir_created"
+
+	transactionJournalExt = ".journal"
+	maxCommittedJournals  = 20
+)
+
+// TransactionStep is one reversible filesystem change made by an install
+// or upgrade.
+type TransactionStep struct {
+	Package string `json:"package"`
+	Kind    string `json:"kind"`
+	Path    string `json:"path"`
+	// Target is the symlink destination written by the step.
+	Target string `json:"target,omitempty"`
+	// Previous is the destination of the symlink that was replaced.
+	Previous string `json:"previous,omitempty"`
+}
+
+// Transaction is the journal of a single install or upgrade run. Every step
+// is appended to <id>.journal as it happens, so a run that dies halfway
+// leaves a pending journal that `fastbrew rollback` can undo.
+type Transaction struct {
+	ID        string            `json:"id"`
+	Operation string            `json:"operation"`
+	Packages  []string          `json:"packages"`
+	StartedAt time.Time         `json:"started_at"`
+	Status    string            `json:"status"`
+	Steps     []TransactionStep `json:"steps"`
+
+	mu        sync.Mutex
+	file      *os.File
+	completed map[string]bool
+}
+
+// journalRecord is one line of a transaction journal.
+type journalRecord struct {
+	Type      string           `json:"type"`
+	ID        string           `json:"id,omitempty"`
+	Operation string           `json:"operation,omitempty"`
+	Packages  []string         `json:"packages,omitempty"`
+	Time      time.Time        `json:"time"`
+	Step      *TransactionStep `json:"step,omitempty"`
+	Status    string           `json:"status,omitempty"`
+}
+
+// GetTransactionDir returns the directory holding transaction journals,
+// transactions under the state directory unless TransactionDir is set.
+func (c *Client) GetTransactionDir() (string, error) {
+	dir := c.TransactionDir
+	if dir == "" {
+		dir = filepath.Join(paths.StateDir(), "transactions")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// beginTransaction starts journaling filesystem changes for operation. A
+// journal that cannot be written degrades to an in-memory transaction so
+// automatic rollback still works.
+func (c *Client) beginTransaction(operation string, packages []string) *Transaction {
+	now := time.Now()
+	txn := &Transaction{
+		ID:        fmt.Sprintf("%s-%s-%d", now.UTC().Format("20060102T150405"), operation, now.UnixNano()%1000000),
+		Operation: operation,
+		Packages:  packages,
+		StartedAt: now,
+		Status:    TransactionStatusPending,
+	}
+
+	if dir, err := c.GetTransactionDir(); err == nil {
+		f, err := os.OpenFile(filepath.Join(dir, txn.ID+transactionJournalExt), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err == nil {
+			txn.file = f
+		} else if c.Verbose {
+			c.printf("  ⚠️  Transaction journal unavailable: %v\n", err)
+		}
+	}
+	txn.append(journalRecord{Type: "begin", ID: txn.ID, Operation: operation, Packages: packages, Time: now})
+	c.logger().Info("transaction started", "id", txn.ID, "operation", operation, "packages", packages)
+
+	c.txnMu.Lock()
+	c.txn = txn
+	c.txnMu.Unlock()
+	return txn
+}
+
+// recordStep appends step to the active transaction, if any.
+func (c *Client) recordStep(step TransactionStep) {
+	c.txnMu.Lock()
+	txn := c.txn
+	c.txnMu.Unlock()
+	if txn == nil {
+		return
+	}
+
+	txn.mu.Lock()
+	txn.Steps = append(txn.Steps, step)
+	txn.mu.Unlock()
+	txn.append(journalRecord{Type: "step", Time: time.Now(), Step: &step})
+}
+
+// markPackageComplete records that name was fully installed and linked, so
+// a failure elsewhere in the operation does not roll it back.
+func (c *Client) markPackageComplete(name string) {
+	c.txnMu.Lock()
+	txn := c.txn
+	c.txnMu.Unlock()
+	if txn == nil {
+		return
+	}
+	txn.mu.Lock()
+	if txn.completed == nil {
+		txn.completed = make(map[string]bool)
+	}
+	txn.completed[name] = true
+	txn.mu.Unlock()
+}
+
+// packageCompleted reports whether markPackageComplete was called for name
+// in the active transaction.
+func (c *Client) packageCompleted(name string) bool {
+	c.txnMu.Lock()
+	txn := c.txn
+	c.txnMu.Unlock()
+	if txn == nil {
+		return false
+	}
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	return txn.completed[name]
+}
+
+// finishTransaction closes the active transaction. When the operation failed,
+// steps belonging to packages that never completed are rolled back; the
+// transaction is only marked rolled back if nothing was kept.
+func (c *Client) finishTransaction(opErr error) {
+	c.txnMu.Lock()
+	txn := c.txn
+	c.txn = nil
+	c.txnMu.Unlock()
+	if txn == nil {
+		return
+	}
+
+	txn.Status = TransactionStatusCommitted
+	if opErr != nil {
+		var incomplete []TransactionStep
+		for _, step := range txn.Steps {
+			if !txn.completed[step.Package] {
+				incomplete = append(incomplete, step)
+			}
+		}
+		if len(incomplete) > 0 {
+			c.printf("↩️  Rolling back incomplete changes from %s transaction %s...\n", txn.Operation, txn.ID)
+			for _, err := range rollbackSteps(incomplete) {
+				c.printf("  ⚠️  %v\n", err)
+			}
+		}
+		if len(incomplete) == len(txn.Steps) {
+			txn.Status = TransactionStatusRolledBack
+		}
+	}
+	txn.append(journalRecord{Type: "end", Time: time.Now(), Status: txn.Status})
+	txn.close()
+	if opErr != nil {
+		c.logger().Warn("transaction finished with errors", "id", txn.ID, "status", txn.Status, "error", opErr)
+	} else {
+		c.logger().Info("transaction finished", "id", txn.ID, "status", txn.Status)
+	}
+
+	if !txn.lasting() {
+		txn.remove(c)
+	}
+	c.pruneTransactions()
+}
+
+// rollbackPackage undoes the active transaction's steps for one package,
+// leaving the rest of the operation in place.
+func (c *Client) rollbackPackage(name string) {
+	c.txnMu.Lock()
+	txn := c.txn
+	c.txnMu.Unlock()
+	if txn == nil {
+		return
+	}
+
+	txn.mu.Lock()
+	var steps []TransactionStep
+	for _, step := range txn.Steps {
+		if step.Package == name {
+			steps = append(steps, step)
+		}
+	}
+	txn.mu.Unlock()
+	if len(steps) == 0 {
+		return
+	}
+
+	c.printf("  ↩️  Rolling back %s\n", name)
+	for _, err := range rollbackSteps(steps) {
+		c.printf("  ⚠️  %v\n", err)
+	}
+}
+
+// replaceSymlink points link at target, recording the change so it can be
+// reverted. Any existing file or symlink at link is removed first.
+func (c *Client) replaceSymlink(pkg, target, link string) error {
+	step := TransactionStep{Package: pkg, Kind: StepSymlinkAdded, Path: link, Target: target}
+	if info, err := os.Lstat(link); err == nil {
+		if info.Mode()&os.ModeSymlink != 0 {
+			if previous, err := os.Readlink(link); err == nil {
+				if previous == target {
+					return nil
+				}
+				step.Kind = StepSymlinkReplaced
+				step.Previous = previous
+			}
+		}
+		os.Remove(link)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		return err
+	}
+	c.recordStep(step)
+	return nil
+}
+
+// rollbackSteps reverts steps in reverse order. Each revert checks the
+// current state first, so rolling back the same steps twice is harmless.
+func rollbackSteps(steps []TransactionStep) []error {
+	var errs []error
+	for i := len(steps) - 1; i >= 0; i-- {
+		if err := revertStep(steps[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func revertStep(step TransactionStep) error {
+	switch step.Kind {
+	case StepKegCreated:
+		if err := os.RemoveAll(step.Path); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", step.Path, err)
+		}
+		// Drop Cellar/<name> too when this was its only version.
+		parent := filepath.Dir(step.Path)
+		if entries, err := os.ReadDir(parent); err == nil && len(entries) == 0 {
+			os.Remove(parent)
+		}
+	case StepTempDirCreated:
+		if err := os.RemoveAll(step.Path); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", step.Path, err)
+		}
+	case StepSymlinkAdded, StepSymlinkReplaced:
+		current, err := os.Readlink(step.Path)
+		if err != nil || current != step.Target {
+			// Already reverted or changed by someone else since.
+			return nil
+		}
+		if err := os.Remove(step.Path); err != nil {
+			return fmt.Errorf("failed to remove link %s: %w", step.Path, err)
+		}
+		if step.Kind == StepSymlinkReplaced {
+			if err := os.Symlink(step.Previous, step.Path); err != nil {
+				return fmt.Errorf("failed to restore link %s: %w", step.Path, err)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown transaction step %q", step.Kind)
+	}
+	return nil
+}
+
+// lasting reports whether t recorded a change that outlives the run; the
+// extraction directories of a finished run are already gone.
+func (t *Transaction) lasting() bool {
+	for _, step := range t.Steps {
+		if step.Kind != StepTempDirCreated {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Transaction) append(rec journalRecord) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.file == nil {
+		return
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	t.file.Write(append(data, '\n'))
+}
+
+func (t *Transaction) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.file != nil {
+		t.file.Close()
+		t.file = nil
+	}
+}
+
+func (t *Transaction) remove(c *Client) {
+	if dir, err := c.GetTransactionDir(); err == nil {
+		os.Remove(filepath.Join(dir, t.ID+transactionJournalExt))
+	}
+}
+
+// readTransaction replays a journal file. A journal without an end record
+// belongs to a run that never finished and is reported as pending.
+func readTransaction(path string) (*Transaction, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	txn := &Transaction{Status: TransactionStatusPending}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec journalRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// A torn final line from a crash; keep what was recorded.
+			break
+		}
+		switch rec.Type {
+		case "begin":
+			txn.ID = rec.ID
+			txn.Operation = rec.Operation
+			txn.Packages = rec.Packages
+			txn.StartedAt = rec.Time
+		case "step":
+			if rec.Step != nil {
+				txn.Steps = append(txn.Steps, *rec.Step)
+			}
+		case "end":
+			txn.Status = rec.Status
+		}
+	}
+	if txn.ID == "" {
+		return nil, fmt.Errorf("invalid transaction journal %s", path)
+	}
+	return txn, scanner.Err()
+}
+
+// ListTransactions returns the recorded transactions, newest first.
+func (c *Client) ListTransactions() ([]*Transaction, error) {
+	dir, err := c.GetTransactionDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var txns []*Transaction
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), transactionJournalExt) {
+			continue
+		}
+		txn, err := readTransaction(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		txns = append(txns, txn)
+	}
+	sort.Slice(txns, func(i, j int) bool {
+		return txns[i].StartedAt.After(txns[j].StartedAt)
+	})
+	return txns, nil
+}
+
+// RollbackTransaction reverts every step of the transaction with the given
+// id and marks its journal as rolled back.
+func (c *Client) RollbackTransaction(id string) (*Transaction, error) {
+	dir, err := c.GetTransactionDir()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, filepath.Base(id)+transactionJournalExt)
+	txn, err := readTransaction(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, kindErrorf(KindNotFound, "transaction %s not found", id)
+		}
+		return nil, err
+	}
+	if txn.Status == TransactionStatusRolledBack {
+		return txn, nil
+	}
+
+	errs := rollbackSteps(txn.Steps)
+	if len(errs) > 0 {
+		msgs := make([]string, len(errs))
+		for i, e := range errs {
+			msgs[i] = e.Error()
+		}
+		return txn, fmt.Errorf("rollback of %s incomplete: %s", id, strings.Join(msgs, "; "))
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return txn, err
+	}
+	txn.file = f
+	txn.Status = TransactionStatusRolledBack
+	txn.append(journalRecord{Type: "end", Time: time.Now(), Status: txn.Status})
+	txn.close()
+	c.notifyInvalidation(EventInstalledChanged)
+	return txn, nil
+}
+
+// RollbackPending reverts every transaction whose run never finished.
+func (c *Client) RollbackPending() ([]*Transaction, error) {
+	txns, err := c.ListTransactions()
+	if err != nil {
+		return nil, err
+	}
+	var rolledBack []*Transaction
+	for _, txn := range txns {
+		if txn.Status != TransactionStatusPending {
+			continue
+		}
+		done, err := c.RollbackTransaction(txn.ID)
+		if err != nil {
+			return rolledBack, err
+		}
+		rolledBack = append(rolledBack, done)
+	}
+	return rolledBack, nil
+}
+
+// pruneTransactions keeps only the newest finished journals. Pending
+// journals are never pruned.
+func (c *Client) pruneTransactions() {
+	txns, err := c.ListTransactions()
+	if err != nil {
+		return
+	}
+	dir, err := c.GetTransactionDir()
+	if err != nil {
+		return
+	}
+	kept := 0
+	for _, txn := range txns {
+		if txn.Status == TransactionStatusPending {
+			continue
+		}
+		kept++
+		if kept > maxCommittedJournals {
+			os.Remove(filepath.Join(dir, txn.ID+transactionJournalExt))
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package brew
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTransactionTestClient(t *testing.T) *Client {
+	t.Helper()
+	prefix := t.TempDir()
+	return &Client{
+		Prefix:         prefix,
+		Cellar:         filepath.Join(prefix, "Cellar"),
+		Out:            io.Discard,
+		TransactionDir: t.TempDir(),
+	}
+}
+
+func makeKeg(t *testing.T, c *Client, name, version string) string {
+	t.Helper()
+	binDir := filepath.Join(c.Cellar, name, version, "bin")
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(binDir, name), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(c.Cellar, name, version)
+}
+
+func TestTransactionRollbackRestoresPreviousLinks(t *testing.T) {
+	c := newTransactionTestClient(t)
+
+	oldKeg := makeKeg(t, c, "jq", "1.6")
+	if _, err := c.Link("jq", "1.6"); err != nil {
+		t.Fatalf("Link old version failed: %v", err)
+	}
+
+	c.beginTransaction(MutationOperationUpgrade, []string{"jq"})
+	newKeg := makeKeg(t, c, "jq", "1.7")
+	c.recordStep(TransactionStep{Package: "jq", Kind: StepKegCreated, Path: newKeg})
+	if _, err := c.Link("jq", "1.7"); err != nil {
+		t.Fatalf("Link new version failed: %v", err)
+	}
+	c.finishTransaction(errors.New("upgrade failed"))
+
+	if _, err := os.Stat(newKeg); !os.IsNotExist(err) {
+		t.Errorf("Expected new keg to be removed, stat err = %v", err)
+	}
+	target, err := os.Readlink(filepath.Join(c.Prefix, "bin", "jq"))
+	if err != nil {
+		t.Fatalf("Expected bin/jq link to be restored: %v", err)
+	}
+	if target != filepath.Join(oldKeg, "bin", "jq") {
+		t.Errorf("Expected bin/jq to point at old keg, got %s", target)
+	}
+	if target, _ := os.Readlink(filepath.Join(c.Prefix, "opt", "jq")); target != oldKeg {
+		t.Errorf("Expected opt/jq to point at old keg, got %s", target)
+	}
+
+	txns, err := c.ListTransactions()
+	if err != nil {
+		t.Fatalf("ListTransactions failed: %v", err)
+	}
+	if len(txns) != 1 || txns[0].Status != TransactionStatusRolledBack {
+		t.Errorf("Expected one rolled back transaction, got %+v", txns)
+	}
+}
+
+func TestTransactionKeepsCompletedPackages(t *testing.T) {
+	c := newTransactionTestClient(t)
+
+	c.beginTransaction(MutationOperationInstall, []string{"jq", "wget"})
+	for _, name := range []string{"jq", "wget"} {
+		keg := makeKeg(t, c, name, "1.0")
+		c.recordStep(TransactionStep{Package: name, Kind: StepKegCreated, Path: keg})
+	}
+	c.markPackageComplete("jq")
+	c.finishTransaction(errors.New("1 package(s) failed to install"))
+
+	if _, err := os.Stat(filepath.Join(c.Cellar, "jq", "1.0")); err != nil {
+		t.Errorf("Expected completed package to be kept: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(c.Cellar, "wget")); !os.IsNotExist(err) {
+		t.Errorf("Expected incomplete package to be rolled back, stat err = %v", err)
+	}
+}
+
+func TestRollbackPendingTransaction(t *testing.T) {
+	c := newTransactionTestClient(t)
+
+	txn := c.beginTransaction(MutationOperationInstall, []string{"jq"})
+	keg := makeKeg(t, c, "jq", "1.7")
+	c.recordStep(TransactionStep{Package: "jq", Kind: StepKegCreated, Path: keg})
+	if _, err := c.Link("jq", "1.7"); err != nil {
+		t.Fatalf("Link failed: %v", err)
+	}
+	// Simulate a crash: the journal is never finished.
+	txn.close()
+	c.txn = nil
+
+	rolledBack, err := c.RollbackPending()
+	if err != nil {
+		t.Fatalf("RollbackPending failed: %v", err)
+	}
+	if len(rolledBack) != 1 || rolledBack[0].ID != txn.ID {
+		t.Fatalf("Expected transaction %s to be rolled back, got %+v", txn.ID, rolledBack)
+	}
+	if _, err := os.Lstat(filepath.Join(c.Prefix, "bin", "jq")); !os.IsNotExist(err) {
+		t.Errorf("Expected bin/jq link to be removed, lstat err = %v", err)
+	}
+	if _, err := os.Stat(keg); !os.IsNotExist(err) {
+		t.Errorf("Expected keg to be removed, stat err = %v", err)
+	}
+
+	again, err := c.RollbackPending()
+	if err != nil {
+		t.Fatalf("Second RollbackPending failed: %v", err)
+	}
+	if len(again) != 0 {
+		t.Errorf("Expected nothing left to roll back, got %d", len(again))
+	}
+}
+
+func TestRollbackRemovesExtractionTempDir(t *testing.T) {
+	c := newTransactionTestClient(t)
+
+	txn := c.beginTransaction(MutationOperationInstall, []string{"jq"})
+	tmpDir := filepath.Join(c.Cellar, extractTempPrefix+"jq-1")
+	if err := os.MkdirAll(filepath.Join(tmpDir, "jq", "1.7"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	c.recordStep(TransactionStep{Package: "jq", Kind: StepTempDirCreated, Path: tmpDir})
+	// Simulate a crash mid-extraction.
+	txn.close()
+	c.txn = nil
+
+	if _, err := c.RollbackPending(); err != nil {
+		t.Fatalf("RollbackPending failed: %v", err)
+	}
+	if _, err := os.Stat(tmpDir); !os.IsNotExist(err) {
+		t.Errorf("Expected the extraction directory to be removed, stat err = %v", err)
+	}
+}
+
+func TestFinishedTransactionWithOnlyTempDirsIsDropped(t *testing.T) {
+	c := newTransactionTestClient(t)
+
+	c.beginTransaction(MutationOperationInstall, []string{"jq"})
+	c.recordStep(TransactionStep{Package: "jq", Kind: StepTempDirCreated, Path: filepath.Join(c.Cellar, extractTempPrefix+"jq-1")})
+	c.finishTransaction(nil)
+
+	txns, err := c.ListTransactions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txns) != 0 {
+		t.Errorf("Expected no journal kept, got %d", len(txns))
+	}
+}
@@ -0,0 +1,80 @@
+package brew
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// UninstallOptions controls UninstallFormula.
+type UninstallOptions struct {
+	// DryRun reports what would be removed without touching the disk or
+	// running hooks.
+	DryRun bool
+}
+
+// UninstallResult lists what UninstallFormula removed (or, in a dry run,
+// would remove).
+type UninstallResult struct {
+	Package string
+	// Kegs are the installed versions' directories in the Cellar.
+	Kegs []string
+	// Links are the symlinks in the prefix pointing into the kegs,
+	// opt/<name> included.
+	Links []string
+	// Bytes is the combined size of the kegs.
+	Bytes int64
+}
+
+// UninstallFormula removes the installed formula name: its links in the
+// prefix, then every version in the Cellar. Uninstall hooks run around the
+// removal; a failing pre-uninstall hook leaves the formula installed. Once
+// removed, the formula is dropped from the install reasons and the
+// outdated record.
+func (c *Client) UninstallFormula(name string, opts UninstallOptions) (*UninstallResult, error) {
+	pkgDir := filepath.Join(c.Cellar, name)
+	if _, err := os.Stat(pkgDir); err != nil {
+		if os.IsNotExist(err) {
+			return nil, kindErrorf(KindNotFound, "%s is not installed", name)
+		}
+		return nil, err
+	}
+
+	links, err := c.kegPrefixLinks(name, false)
+	if err != nil {
+		return nil, err
+	}
+	result := &UninstallResult{Package: name, Links: links, Bytes: dirSize(pkgDir)}
+	for _, version := range kegVersions(pkgDir) {
+		result.Kegs = append(result.Kegs, filepath.Join(pkgDir, version))
+	}
+	if opts.DryRun {
+		return result, nil
+	}
+
+	hookEnv := c.InstalledHookEnv(name)
+	if err := c.RunHooks(HookPreUninstall, hookEnv); err != nil {
+		return nil, err
+	}
+	if err := c.unlinkKeg(name, false); err != nil {
+		return nil, err
+	}
+	if err := os.RemoveAll(pkgDir); err != nil {
+		return nil, fmt.Errorf("failed to remove %s: %w", pkgDir, err)
+	}
+	c.forgetUninstalled(name)
+	c.RunHooks(HookPostUninstall, hookEnv)
+	return result, nil
+}
+
+// forgetUninstalled drops the uninstalled name from the install reasons
+// and the outdated record, so a reinstall starts afresh and upgrade no
+// longer offers it.
+func (c *Client) forgetUninstalled(name string) {
+	if err := ForgetInstallReasons(name); err != nil {
+		c.printf("  ⚠️  Failed to update install reasons: %v\n", err)
+	}
+	if err := ForgetOutdated(name); err != nil {
+		c.printf("  ⚠️  Failed to update the outdated record: %v\n", err)
+	}
+}
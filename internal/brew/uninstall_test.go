@@ -0,0 +1,80 @@
+package brew
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestUninstallFormulaDryRun(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	c := newTransactionTestClient(t)
+	keg := makeKeg(t, c, "jq", "1.7.1")
+	if _, err := c.Link("jq", "1.7.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := c.UninstallFormula("jq", UninstallOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("UninstallFormula failed: %v", err)
+	}
+	if len(result.Kegs) != 1 || result.Kegs[0] != keg {
+		t.Errorf("Kegs = %v, want [%s]", result.Kegs, keg)
+	}
+	wantLinks := map[string]bool{
+		filepath.Join(c.Prefix, "opt", "jq"): true,
+		filepath.Join(c.Prefix, "bin", "jq"): true,
+	}
+	if len(result.Links) != len(wantLinks) {
+		t.Errorf("Links = %v, want %d links", result.Links, len(wantLinks))
+	}
+	for _, link := range result.Links {
+		if !wantLinks[link] {
+			t.Errorf("unexpected link %s", link)
+		}
+		if _, err := os.Lstat(link); err != nil {
+			t.Errorf("dry run removed %s", link)
+		}
+	}
+	if _, err := os.Stat(keg); err != nil {
+		t.Errorf("dry run removed the keg: %v", err)
+	}
+
+	if _, err := c.UninstallFormula("jq", UninstallOptions{}); err != nil {
+		t.Fatalf("UninstallFormula failed: %v", err)
+	}
+	for link := range wantLinks {
+		if _, err := os.Lstat(link); !os.IsNotExist(err) {
+			t.Errorf("%s still exists", link)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(c.Cellar, "jq")); !os.IsNotExist(err) {
+		t.Error("Cellar directory still exists")
+	}
+	if _, err := c.UninstallFormula("jq", UninstallOptions{}); err == nil {
+		t.Error("expected an error for a formula that is not installed")
+	}
+}
+
+func TestUninstallFormulaForgetsRecords(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	c := newTransactionTestClient(t)
+	makeKeg(t, c, "jq", "1.7.1")
+	if err := RecordInstallReasons(map[string]InstallReason{"jq": ReasonExplicit, "wget": ReasonExplicit}); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveOutdatedRecord(&OutdatedRecord{Packages: []string{"jq", "wget"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.UninstallFormula("jq", UninstallOptions{}); err != nil {
+		t.Fatalf("UninstallFormula failed: %v", err)
+	}
+	if reasons, _ := LoadInstallReasons(); reasons["jq"] != "" || reasons["wget"] == "" {
+		t.Errorf("expected only jq's install reason to be forgotten, got %v", reasons)
+	}
+	if record, _ := LoadOutdatedRecord(); record == nil || !reflect.DeepEqual(record.Packages, []string{"wget"}) {
+		t.Errorf("expected only wget left outdated, got %+v", record)
+	}
+}
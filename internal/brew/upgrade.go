@@ -1,18 +1,23 @@
 package brew
 
 import (
+	"context"
 	"fmt"
-	"os/exec"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
 // UpgradeNative performs native upgrades using bottle installation for formulae
-// and brew upgrade --cask for casks
-func (c *Client) UpgradeNative(packages []string, precomputedOutdated []OutdatedPackage) error {
+// and brew upgrade --cask for casks. Packages held back by a version
+// constraint are skipped with an explanation. Cancelling ctx stops downloads and
+// leaves packages whose bottles were not extracted yet at their old version.
+func (c *Client) UpgradeNative(ctx context.Context, packages []string, precomputedOutdated []OutdatedPackage) (err error) {
+	if c.beginRunReport(MutationOperationUpgrade) {
+		defer func() { c.finishRunReport(err) }()
+	}
 	var outdated []OutdatedPackage
-	var err error
 
 	if len(precomputedOutdated) > 0 {
 		outdated = precomputedOutdated
@@ -25,7 +30,7 @@ func (c *Client) UpgradeNative(packages []string, precomputedOutdated []Outdated
 
 	if len(packages) > 0 {
 		reqMap := make(map[string]bool)
-		for _, name := range packages {
+		for _, name := range c.WithDependencies(packages) {
 			reqMap[name] = true
 		}
 		var filtered []OutdatedPackage
@@ -37,8 +42,18 @@ func (c *Client) UpgradeNative(packages []string, precomputedOutdated []Outdated
 		outdated = filtered
 	}
 
+	// Precomputed lists may come from a stale daemon snapshot; drop anything
+	// that is not genuinely older than the version it would upgrade to.
+	var actionable []OutdatedPackage
+	for _, pkg := range outdated {
+		if isOutdated(pkg.CurrentVersion, pkg.NewVersion) {
+			actionable = append(actionable, pkg)
+		}
+	}
+	outdated = c.holdBack(actionable)
+
 	if len(outdated) == 0 {
-		fmt.Println("✅ All packages up to date.")
+		c.println("✅ All packages up to date.")
 		return nil
 	}
 
@@ -54,32 +69,27 @@ func (c *Client) UpgradeNative(packages []string, precomputedOutdated []Outdated
 	}
 
 	if len(tapOutdated) > 0 {
-		fmt.Printf("\n🚰 Upgrading %d tap formula(e)...\n", len(tapOutdated))
+		c.printf("\n🚰 Upgrading %d tap formula(e)...\n", len(tapOutdated))
 		var tapWg sync.WaitGroup
 		tapSem := make(chan struct{}, c.getMaxParallel())
 		var tapErrMu sync.Mutex
 		var tapErrors []string
 
 		for _, pkg := range tapOutdated {
-			fmt.Printf("  %s %s → %s\n", pkg.Name, pkg.CurrentVersion, pkg.NewVersion)
+			c.printf("  %s %s → %s\n", pkg.Name, pkg.CurrentVersion, pkg.NewVersion)
 			tapWg.Add(1)
 			go func(p OutdatedPackage) {
 				defer tapWg.Done()
 				tapSem <- struct{}{}
 				defer func() { <-tapSem }()
 
-				// Re-use CaskInstaller which already shells out to brew,
-				// but change the operation since it's a formula, not a cask.
-				// Wait, CaskInstaller hardcodes `--cask`. We need a direct brew execution.
-				cmd := exec.Command("brew", "upgrade", p.Name)
-				cmd.Stdout = nil // suppress verbose output unless error
-				cmd.Stderr = nil
-				if err := cmd.Run(); err != nil {
+				// Tap formulae are upgraded by brew itself, quietly.
+				if _, err := c.runner().Run("brew", "upgrade", p.Name); err != nil {
 					tapErrMu.Lock()
 					tapErrors = append(tapErrors, fmt.Sprintf("%s: %v", p.Name, err))
 					tapErrMu.Unlock()
 				} else {
-					fmt.Printf("  ✅ Upgraded %s\n", p.Name)
+					c.printf("  ✅ Upgraded %s\n", p.Name)
 				}
 			}(pkg)
 		}
@@ -87,27 +97,30 @@ func (c *Client) UpgradeNative(packages []string, precomputedOutdated []Outdated
 
 		if len(tapErrors) > 0 {
 			for _, e := range tapErrors {
-				fmt.Printf("  ⚠️  %s\n", e)
+				c.printf("  ⚠️  %s\n", e)
 			}
 			return fmt.Errorf("tap upgrade failed for: %s", strings.Join(tapErrors, "; "))
 		}
 	}
 
 	if len(formulaOutdated) > 0 {
-		if err := c.upgradeFormulae(formulaOutdated); err != nil {
+		if err := c.upgradeFormulae(ctx, formulaOutdated); err != nil {
 			return err
 		}
 	}
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	if len(caskOutdated) > 0 {
-		fmt.Printf("\n🍷 Upgrading %d cask(s) in parallel...\n", len(caskOutdated))
+		c.printf("\n🍷 Upgrading %d cask(s) in parallel...\n", len(caskOutdated))
 		var caskWg sync.WaitGroup
 		caskSem := make(chan struct{}, c.getMaxParallel())
 		var caskErrMu sync.Mutex
 		var caskErrors []string
 
 		for _, pkg := range caskOutdated {
-			fmt.Printf("  %s %s → %s\n", pkg.Name, pkg.CurrentVersion, pkg.NewVersion)
+			c.printf("  %s %s → %s\n", pkg.Name, pkg.CurrentVersion, pkg.NewVersion)
 			caskWg.Add(1)
 			go func(p OutdatedPackage) {
 				defer caskWg.Done()
@@ -126,7 +139,7 @@ func (c *Client) UpgradeNative(packages []string, precomputedOutdated []Outdated
 
 		if len(caskErrors) > 0 {
 			for _, e := range caskErrors {
-				fmt.Printf("  ⚠️  %s\n", e)
+				c.printf("  ⚠️  %s\n", e)
 			}
 			return fmt.Errorf("cask upgrade failed for: %s", strings.Join(caskErrors, "; "))
 		}
@@ -143,9 +156,9 @@ type downloadResult struct {
 }
 
 // upgradeFormulae handles formula upgrades via bottles with clean phased output
-func (c *Client) upgradeFormulae(outdated []OutdatedPackage) error {
+func (c *Client) upgradeFormulae(ctx context.Context, outdated []OutdatedPackage) (err error) {
 	// Phase 1: Fetch metadata
-	fmt.Printf("🔍 Fetching formula metadata for %d package(s)...\n", len(outdated))
+	c.printf("🔍 Resolving formula metadata for %d package(s)...\n", len(outdated))
 
 	type metaResult struct {
 		pkg    OutdatedPackage
@@ -155,17 +168,26 @@ func (c *Client) upgradeFormulae(outdated []OutdatedPackage) error {
 
 	metaCh := make(chan metaResult, len(outdated))
 	var wg sync.WaitGroup
-	sem := make(chan struct{}, c.getMaxParallel())
+	sched := c.scheduler()
 
 	for _, pkg := range outdated {
 		c.emitMutation(MutationOperationUpgrade, pkg.Name, MutationPhaseMetadata, MutationStatusQueued, "metadata queued", 0, 0, "")
 		wg.Add(1)
 		go func(p OutdatedPackage) {
 			defer wg.Done()
-			sem <- struct{}{}
-			defer func() { <-sem }()
+			if remote := c.indexedFormula(p.Name); remote != nil {
+				c.emitMutation(MutationOperationUpgrade, p.Name, MutationPhaseMetadata, MutationStatusSucceeded, "metadata from index", 0, 0, "")
+				metaCh <- metaResult{pkg: p, remote: remote}
+				return
+			}
+			release, err := sched.Acquire(ctx)
+			if err != nil {
+				metaCh <- metaResult{pkg: p, err: err}
+				return
+			}
+			defer release()
 			c.emitMutation(MutationOperationUpgrade, p.Name, MutationPhaseMetadata, MutationStatusRunning, "fetching metadata", 0, 0, "")
-			remote, err := c.FetchFormula(p.Name)
+			remote, err := c.FetchFormula(ctx, p.Name)
 			if err != nil {
 				c.emitMutation(MutationOperationUpgrade, p.Name, MutationPhaseMetadata, MutationStatusFailed, err.Error(), 0, 0, "")
 			} else {
@@ -176,10 +198,15 @@ func (c *Client) upgradeFormulae(outdated []OutdatedPackage) error {
 	}
 	wg.Wait()
 	close(metaCh)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
 	nameToOutdated := make(map[string]OutdatedPackage, len(outdated))
+	oldVersions := make(map[string]string, len(outdated))
 	for _, pkg := range outdated {
 		nameToOutdated[pkg.Name] = pkg
+		oldVersions[pkg.Name] = pkg.CurrentVersion
 	}
 
 	var formulae []*RemoteFormula
@@ -187,6 +214,8 @@ func (c *Client) upgradeFormulae(outdated []OutdatedPackage) error {
 	for r := range metaCh {
 		if r.err != nil {
 			metaErrors = append(metaErrors, fmt.Sprintf("%s: %v", r.pkg.Name, r.err))
+		} else if r.pkg.CurrentVersion != "" && !isOutdated(r.pkg.CurrentVersion, r.remote.FullVersion()) {
+			c.emitMutation(MutationOperationUpgrade, r.pkg.Name, MutationPhaseMetadata, MutationStatusSkipped, "already up to date", 0, 0, "")
 		} else {
 			formulae = append(formulae, r.remote)
 		}
@@ -198,7 +227,7 @@ func (c *Client) upgradeFormulae(outdated []OutdatedPackage) error {
 
 	if len(metaErrors) > 0 {
 		for _, e := range metaErrors {
-			fmt.Printf("  ⚠️  %s\n", e)
+			c.printf("  ⚠️  %s\n", e)
 		}
 	}
 
@@ -207,36 +236,56 @@ func (c *Client) upgradeFormulae(outdated []OutdatedPackage) error {
 	}
 
 	// Print upgrade plan
-	fmt.Printf("\n📦 %d formula(e) to upgrade:\n", len(formulae))
+	c.printf("\n📦 %d formula(e) to upgrade:\n", len(formulae))
 	for _, f := range formulae {
 		if pkg, ok := nameToOutdated[f.Name]; ok {
-			fmt.Printf("  %s %s → %s\n", f.Name, pkg.CurrentVersion, f.FullVersion())
+			c.printf("  %s %s → %s\n", f.Name, pkg.CurrentVersion, f.FullVersion())
 		} else {
-			fmt.Printf("  %s → %s\n", f.Name, f.FullVersion())
+			c.printf("  %s → %s\n", f.Name, f.FullVersion())
 		}
 	}
 
+	c.sweepExtractionTempDirs()
+	if err := c.checkDiskSpace(ctx, formulae); err != nil {
+		return err
+	}
+
+	c.beginTransaction(MutationOperationUpgrade, formulaNames(formulae))
+	defer func() { c.finishTransaction(err) }()
+
 	// Phase 2: Download all bottles in parallel
-	fmt.Printf("\n⬇️  Downloading %d bottle(s)...\n", len(formulae))
+	c.printf("\n⬇️  Downloading %d bottle(s)...\n", len(formulae))
 	for _, f := range formulae {
 		c.emitMutation(MutationOperationUpgrade, f.Name, MutationPhaseDownload, MutationStatusQueued, "download queued", 0, 0, "bytes")
 	}
 
+	c.expectDownloads(len(formulae))
 	dlCh := make(chan downloadResult, len(formulae))
 
 	for _, f := range formulae {
 		wg.Add(1)
 		go func(frm *RemoteFormula) {
 			defer wg.Done()
-			sem <- struct{}{}
-			defer func() { <-sem }()
+			release, err := sched.Acquire(ctx)
+			if err != nil {
+				dlCh <- downloadResult{formula: frm, err: err}
+				return
+			}
+			defer release()
 			c.emitMutation(MutationOperationUpgrade, frm.Name, MutationPhaseDownload, MutationStatusRunning, "downloading bottle", 0, 0, "bytes")
-			tarPath, err := c.DownloadBottle(frm)
+			start := time.Now()
+			tarPath, err := c.DownloadBottle(ctx, frm)
+			if err == nil {
+				c.recordDownload(frm, tarPath, time.Since(start))
+			}
 			dlCh <- downloadResult{formula: frm, tarPath: tarPath, err: err}
 		}(f)
 	}
 	wg.Wait()
 	close(dlCh)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
 	var downloaded []downloadResult
 	var dlErrors []downloadResult
@@ -256,87 +305,229 @@ func (c *Client) upgradeFormulae(outdated []OutdatedPackage) error {
 
 	if len(dlErrors) > 0 {
 		for _, r := range dlErrors {
-			fmt.Printf("  ❌ %s: %v\n", r.formula.Name, r.err)
+			c.printf("  ❌ %s: %v\n", r.formula.Name, r.err)
 		}
 	}
 
-	fmt.Printf("  ✅ %d downloaded", len(downloaded))
+	c.printf("  ✅ %d downloaded", len(downloaded))
 	if len(dlErrors) > 0 {
-		fmt.Printf(", %d failed", len(dlErrors))
+		c.printf(", %d failed", len(dlErrors))
 	}
-	fmt.Println()
+	c.println()
 
 	if len(downloaded) == 0 {
-		return fmt.Errorf("%d package(s) failed to download", len(dlErrors))
+		errs := make([]error, 0, len(dlErrors))
+		for _, r := range dlErrors {
+			errs = append(errs, r.err)
+		}
+		return kindErrorf(SharedKind(errs), "%d package(s) failed to download", len(dlErrors))
+	}
+
+	// Phase 3: Extract and link level by level so every package is linked
+	// only after the upgraded dependencies it links against.
+	failed := make(map[string]bool, len(dlErrors))
+	for _, r := range dlErrors {
+		failed[r.formula.Name] = true
+	}
+	tarPaths := make(map[string]string, len(downloaded))
+	readyFormulae := make([]*RemoteFormula, 0, len(downloaded))
+	for _, d := range downloaded {
+		tarPaths[d.formula.Name] = d.tarPath
+		readyFormulae = append(readyFormulae, d.formula)
 	}
+	levels := dependencyLevels(readyFormulae)
 
-	// Phase 3: Extract all bottles in parallel
-	fmt.Printf("\n📦 Extracting %d bottle(s)...\n", len(downloaded))
+	c.printf("\n📦 Extracting and linking %d bottle(s) in %d dependency level(s)...\n", len(downloaded), len(levels))
 
 	type extractResult struct {
 		formula *RemoteFormula
 		err     error
 	}
 
-	exCh := make(chan extractResult, len(downloaded))
-
-	for _, dl := range downloaded {
-		wg.Add(1)
-		go func(d downloadResult) {
-			defer wg.Done()
-			sem <- struct{}{}
-			defer func() { <-sem }()
-			c.emitMutation(MutationOperationUpgrade, d.formula.Name, MutationPhaseExtract, MutationStatusRunning, "extracting bottle", 0, 0, "")
-			err := c.ExtractAndInstallBottle(d.formula, d.tarPath)
-			exCh <- extractResult{formula: d.formula, err: err}
-		}(dl)
-	}
-	wg.Wait()
-	close(exCh)
-
 	var extracted []*RemoteFormula
 	var exErrors []extractResult
-	for r := range exCh {
-		if r.err != nil {
-			exErrors = append(exErrors, r)
-			c.emitMutation(MutationOperationUpgrade, r.formula.Name, MutationPhaseExtract, MutationStatusFailed, r.err.Error(), 0, 0, "")
-		} else {
-			extracted = append(extracted, r.formula)
-			c.emitMutation(MutationOperationUpgrade, r.formula.Name, MutationPhaseExtract, MutationStatusSucceeded, "extracted bottle", 0, 0, "")
+	sem := make(chan struct{}, c.getMaxParallel())
+
+	for i, level := range levels {
+		// Levels already linked stay upgraded; the rest keep their old
+		// version.
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var ready []*RemoteFormula
+		for _, f := range level {
+			if dep := failedDependency(f, failed); dep != "" {
+				err := fmt.Errorf("dependency %s failed to upgrade", dep)
+				failed[f.Name] = true
+				exErrors = append(exErrors, extractResult{formula: f, err: err})
+				c.emitMutation(MutationOperationUpgrade, f.Name, MutationPhaseExtract, MutationStatusSkipped, err.Error(), 0, 0, "")
+				continue
+			}
+			ready = append(ready, f)
+		}
+		if len(ready) == 0 {
+			continue
+		}
+		if len(levels) > 1 {
+			c.printf("  Level %d/%d: %s\n", i+1, len(levels), strings.Join(formulaNames(ready), ", "))
+		}
+
+		exCh := make(chan extractResult, len(ready))
+		for _, f := range ready {
+			wg.Add(1)
+			go func(frm *RemoteFormula) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				if err := c.RunHooks(HookPreUpgrade, c.formulaHookEnv(frm, oldVersions[frm.Name])); err != nil {
+					exCh <- extractResult{formula: frm, err: err}
+					return
+				}
+				c.emitMutation(MutationOperationUpgrade, frm.Name, MutationPhaseExtract, MutationStatusRunning, "extracting bottle", 0, 0, "")
+				start := time.Now()
+				err := c.ExtractAndInstallBottle(frm, tarPaths[frm.Name])
+				if err == nil {
+					c.recordExtract(frm, time.Since(start))
+				}
+				exCh <- extractResult{formula: frm, err: err}
+			}(f)
+		}
+		wg.Wait()
+		close(exCh)
+
+		var levelExtracted []*RemoteFormula
+		for r := range exCh {
+			if r.err != nil {
+				failed[r.formula.Name] = true
+				exErrors = append(exErrors, r)
+				c.emitMutation(MutationOperationUpgrade, r.formula.Name, MutationPhaseExtract, MutationStatusFailed, r.err.Error(), 0, 0, "")
+			} else {
+				levelExtracted = append(levelExtracted, r.formula)
+				c.emitMutation(MutationOperationUpgrade, r.formula.Name, MutationPhaseExtract, MutationStatusSucceeded, "extracted bottle", 0, 0, "")
+			}
 		}
+		if len(levelExtracted) == 0 {
+			continue
+		}
+		sort.Slice(levelExtracted, func(i, j int) bool {
+			return levelExtracted[i].Name < levelExtracted[j].Name
+		})
+
+		if err := c.linkParallel(levelExtracted, MutationOperationUpgrade); err != nil {
+			return err
+		}
+		c.runPostHooks(HookPostUpgrade, levelExtracted, oldVersions)
+		extracted = append(extracted, levelExtracted...)
 	}
 
 	if len(exErrors) > 0 {
 		for _, r := range exErrors {
-			fmt.Printf("  ❌ %s: %v\n", r.formula.Name, r.err)
+			c.printf("  ❌ %s: %v\n", r.formula.Name, r.err)
 		}
 	}
 
-	fmt.Printf("  ✅ %d extracted", len(extracted))
+	c.printf("  ✅ %d upgraded", len(extracted))
 	if len(exErrors) > 0 {
-		fmt.Printf(", %d failed", len(exErrors))
+		c.printf(", %d failed", len(exErrors))
 	}
-	fmt.Println()
+	c.println()
 
 	if len(extracted) == 0 {
-		totalFailed := len(dlErrors) + len(exErrors)
-		return fmt.Errorf("%d package(s) failed to upgrade", totalFailed)
-	}
-
-	// Phase 4: Link
-	fmt.Println("\n🔗 Linking binaries...")
-	if err := c.linkParallel(extracted, MutationOperationUpgrade); err != nil {
-		return err
+		errs := make([]error, 0, len(dlErrors)+len(exErrors))
+		for _, r := range dlErrors {
+			errs = append(errs, r.err)
+		}
+		for _, r := range exErrors {
+			errs = append(errs, r.err)
+		}
+		return kindErrorf(SharedKind(errs), "%d package(s) failed to upgrade", len(errs))
 	}
+	c.printCaveats(extracted)
 
 	totalFailed := len(dlErrors) + len(exErrors)
 	if totalFailed > 0 {
-		return fmt.Errorf("%d package(s) failed to upgrade", totalFailed)
+		return kindErrorf(KindPartialFailure, "%d package(s) failed to upgrade", totalFailed)
 	}
 
 	return nil
 }
 
+// WithDependencies returns packages followed by their recursive
+// dependencies, so that upgrading a package also picks up outdated
+// libraries it depends on. When the index is unavailable the packages are
+// returned unchanged.
+func (c *Client) WithDependencies(packages []string) []string {
+	deps, err := c.ResolveDeps(packages)
+	if err != nil {
+		return packages
+	}
+	return unique(append(append([]string{}, packages...), deps...))
+}
+
+// dependencyLevels groups formulae into levels such that every formula's
+// dependencies within the set appear in an earlier level. Formulae in the
+// same level are independent of each other. Members of a dependency cycle
+// are placed together in a final level.
+func dependencyLevels(formulae []*RemoteFormula) [][]*RemoteFormula {
+	byName := make(map[string]*RemoteFormula, len(formulae))
+	for _, f := range formulae {
+		byName[f.Name] = f
+	}
+
+	pending := make(map[string]int, len(formulae))
+	dependents := make(map[string][]string)
+	for _, f := range formulae {
+		pending[f.Name] = 0
+		for _, dep := range unique(f.Dependencies) {
+			if _, ok := byName[dep]; ok && dep != f.Name {
+				pending[f.Name]++
+				dependents[dep] = append(dependents[dep], f.Name)
+			}
+		}
+	}
+
+	var levels [][]*RemoteFormula
+	for len(pending) > 0 {
+		var level []*RemoteFormula
+		for name, n := range pending {
+			if n == 0 {
+				level = append(level, byName[name])
+			}
+		}
+		if len(level) == 0 {
+			for name := range pending {
+				level = append(level, byName[name])
+			}
+		}
+		sort.Slice(level, func(i, j int) bool {
+			return level[i].Name < level[j].Name
+		})
+		for _, f := range level {
+			delete(pending, f.Name)
+		}
+		for _, f := range level {
+			for _, dependent := range dependents[f.Name] {
+				if _, ok := pending[dependent]; ok {
+					pending[dependent]--
+				}
+			}
+		}
+		levels = append(levels, level)
+	}
+	return levels
+}
+
+// failedDependency returns the first direct dependency of f recorded in
+// failed, or "" if none failed.
+func failedDependency(f *RemoteFormula, failed map[string]bool) string {
+	for _, dep := range f.Dependencies {
+		if failed[dep] {
+			return dep
+		}
+	}
+	return ""
+}
+
 func filterByNames(installed []PackageInfo, requested []string) []PackageInfo {
 	var filtered []PackageInfo
 	reqMap := make(map[string]bool)
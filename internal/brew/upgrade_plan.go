@@ -0,0 +1,119 @@
+package brew
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// UpgradePlanEntry is one package UpgradeNative would upgrade.
+type UpgradePlanEntry struct {
+	Name           string `json:"name"`
+	CurrentVersion string `json:"current_version"`
+	NewVersion     string `json:"new_version"`
+	IsCask         bool   `json:"is_cask"`
+	IsTap          bool   `json:"is_tap"`
+	// DownloadSize is the bottle size in bytes, 0 when the bottle is
+	// already cached and -1 when it is not known (casks, tap formulae and
+	// bottles outside a registry).
+	DownloadSize int64 `json:"download_size"`
+	Cached       bool  `json:"cached"`
+	// Error is set when the formula's metadata could not be fetched; the
+	// upgrade would report the same failure.
+	Error string `json:"error,omitempty"`
+}
+
+// UpgradePlan lists what an upgrade would do without doing it.
+type UpgradePlan struct {
+	Packages []UpgradePlanEntry `json:"packages"`
+	// DownloadSize is the total of the known download sizes.
+	DownloadSize int64 `json:"download_size"`
+	// UnknownSizes counts the packages whose size is not included.
+	UnknownSizes int `json:"unknown_sizes"`
+}
+
+// PlanUpgrade resolves outdated into the upgrade UpgradeNative would run,
+// fetching formula metadata and bottle sizes from the registry manifests
+// but downloading and changing nothing. Formulae already at the newest
+// version are dropped, as the upgrade would skip them.
+func (c *Client) PlanUpgrade(ctx context.Context, outdated []OutdatedPackage) (*UpgradePlan, error) {
+	entries := make([]*UpgradePlanEntry, 0, len(outdated))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, c.getMaxParallel())
+	for _, pkg := range outdated {
+		if !isOutdated(pkg.CurrentVersion, pkg.NewVersion) {
+			continue
+		}
+		entry := &UpgradePlanEntry{
+			Name:           pkg.Name,
+			CurrentVersion: pkg.CurrentVersion,
+			NewVersion:     pkg.NewVersion,
+			IsCask:         pkg.IsCask,
+			IsTap:          pkg.IsTap,
+			DownloadSize:   -1,
+		}
+		entries = append(entries, entry)
+		if pkg.IsCask || pkg.IsTap {
+			continue
+		}
+
+		wg.Add(1)
+		go func(entry *UpgradePlanEntry) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			remote, err := c.FetchFormula(ctx, entry.Name)
+			if err != nil {
+				entry.Error = err.Error()
+				return
+			}
+			entry.NewVersion = remote.FullVersion()
+			entry.DownloadSize, entry.Cached = c.bottleDownloadSize(ctx, remote)
+		}(entry)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	plan := &UpgradePlan{Packages: []UpgradePlanEntry{}}
+	for _, entry := range entries {
+		upToDate := entry.CurrentVersion != "" && !isOutdated(entry.CurrentVersion, entry.NewVersion)
+		if entry.Error == "" && !entry.IsCask && !entry.IsTap && upToDate {
+			continue
+		}
+		plan.Packages = append(plan.Packages, *entry)
+		if entry.DownloadSize < 0 {
+			plan.UnknownSizes++
+		} else {
+			plan.DownloadSize += entry.DownloadSize
+		}
+	}
+	sort.Slice(plan.Packages, func(i, j int) bool {
+		return plan.Packages[i].Name < plan.Packages[j].Name
+	})
+	return plan, nil
+}
+
+// bottleDownloadSize returns how many bytes downloading f's bottle would
+// take: 0 when it is already cached, otherwise the layer size in its
+// registry manifest, or -1 when that cannot be found.
+func (c *Client) bottleDownloadSize(ctx context.Context, f *RemoteFormula) (int64, bool) {
+	_, sha256Sum, err := f.GetBottleInfo()
+	if err != nil {
+		return -1, false
+	}
+	if c.hasCachedBlob(sha256Sum) {
+		return 0, true
+	}
+	ref, bottleTag, ok := f.bottleReference()
+	if !ok {
+		return -1, false
+	}
+	layer, err := c.registryClient().ResolveBottle(ctx, ref, bottleTag)
+	if err != nil || layer.Digest != "sha256:"+sha256Sum {
+		c.logger().Debug("bottle size unavailable", "formula", f.Name, "ref", ref.String(), "error", err)
+		return -1, false
+	}
+	return layer.Size, false
+}
@@ -0,0 +1,84 @@
+package brew
+
+import (
+	"context"
+	"encoding/json"
+	"fastbrew/internal/oci"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBottleDownloadSize(t *testing.T) {
+	client, cacheDir := newCleanupTestClient(t)
+	platform, err := GetPlatform()
+	if err != nil {
+		t.Skip(err)
+	}
+
+	sha := sha256Hex([]byte("bottle tarball"))
+	manifest, _ := json.Marshal(oci.Manifest{
+		SchemaVersion: 2,
+		MediaType:     oci.MediaTypeImageManifest,
+		Layers:        []oci.Descriptor{{Digest: "sha256:" + sha, Size: 4_200_000}},
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/homebrew/core/wget/manifests/1.24.5" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(manifest)
+	}))
+	defer server.Close()
+	client.Mirrors = map[string]string{"ghcr.io": server.URL}
+
+	f := &RemoteFormula{Name: "wget", Versions: Versions{Stable: "1.24.5"}}
+	f.Bottle.Stable.Files = map[string]BottleFile{
+		platform: {URL: "https://ghcr.io/v2/homebrew/core/wget/blobs/sha256:" + sha, SHA256: sha},
+	}
+
+	size, cached := client.bottleDownloadSize(context.Background(), f)
+	if size != 4_200_000 || cached {
+		t.Errorf("Expected 4200000 uncached bytes, got %d (cached %v)", size, cached)
+	}
+
+	blob := filepath.Join(cacheDir, blobDirName, sha)
+	if err := os.MkdirAll(filepath.Dir(blob), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(blob, []byte("bottle tarball"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if size, cached := client.bottleDownloadSize(context.Background(), f); size != 0 || !cached {
+		t.Errorf("Expected a cached bottle to need no download, got %d (cached %v)", size, cached)
+	}
+
+	other := &RemoteFormula{Name: "widget", Versions: Versions{Stable: "1.0"}}
+	other.Bottle.Stable.Files = map[string]BottleFile{
+		platform: {URL: "https://example.com/widget-1.0.tar.gz", SHA256: sha256Hex([]byte("other"))},
+	}
+	if size, _ := client.bottleDownloadSize(context.Background(), other); size != -1 {
+		t.Errorf("Expected unknown size outside a registry, got %d", size)
+	}
+}
+
+func TestPlanUpgradeLeavesCaskSizesUnknown(t *testing.T) {
+	client, _ := newCleanupTestClient(t)
+
+	plan, err := client.PlanUpgrade(context.Background(), []OutdatedPackage{
+		{Name: "firefox", CurrentVersion: "119.0", NewVersion: "120.0", IsCask: true},
+		{Name: "stale", CurrentVersion: "2.0", NewVersion: "2.0", IsCask: true},
+		{Name: "mytool", CurrentVersion: "0.1", NewVersion: "0.2", IsTap: true},
+	})
+	if err != nil {
+		t.Fatalf("PlanUpgrade failed: %v", err)
+	}
+	if len(plan.Packages) != 2 || plan.Packages[0].Name != "firefox" || plan.Packages[1].Name != "mytool" {
+		t.Fatalf("Expected firefox and mytool in the plan, got %+v", plan.Packages)
+	}
+	if plan.UnknownSizes != 2 || plan.DownloadSize != 0 {
+		t.Errorf("Expected two unknown sizes and nothing known, got %+v", plan)
+	}
+}
@@ -0,0 +1,59 @@
+package brew
+
+import (
+	"reflect"
+	"testing"
+)
+
+func levelNames(levels [][]*RemoteFormula) [][]string {
+	names := make([][]string, len(levels))
+	for i, level := range levels {
+		names[i] = formulaNames(level)
+	}
+	return names
+}
+
+func TestDependencyLevels(t *testing.T) {
+	formulae := []*RemoteFormula{
+		{Name: "curl", Dependencies: []string{"openssl@3", "libnghttp2", "zstd"}},
+		{Name: "wget", Dependencies: []string{"openssl@3", "libidn2"}},
+		{Name: "openssl@3", Dependencies: []string{"ca-certificates"}},
+		{Name: "ca-certificates"},
+		{Name: "git", Dependencies: []string{"curl", "pcre2"}},
+	}
+
+	got := levelNames(dependencyLevels(formulae))
+	want := [][]string{
+		{"ca-certificates"},
+		{"openssl@3"},
+		{"curl", "wget"},
+		{"git"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dependencyLevels = %v, want %v", got, want)
+	}
+}
+
+func TestDependencyLevelsCycle(t *testing.T) {
+	formulae := []*RemoteFormula{
+		{Name: "a", Dependencies: []string{"b"}},
+		{Name: "b", Dependencies: []string{"a"}},
+		{Name: "c"},
+	}
+
+	got := levelNames(dependencyLevels(formulae))
+	want := [][]string{{"c"}, {"a", "b"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dependencyLevels = %v, want %v", got, want)
+	}
+}
+
+func TestFailedDependency(t *testing.T) {
+	f := &RemoteFormula{Name: "curl", Dependencies: []string{"openssl@3", "zstd"}}
+	if dep := failedDependency(f, map[string]bool{"zstd": true}); dep != "zstd" {
+		t.Errorf("failedDependency = %q, want zstd", dep)
+	}
+	if dep := failedDependency(f, map[string]bool{"wget": true}); dep != "" {
+		t.Errorf("failedDependency = %q, want none", dep)
+	}
+}
@@ -1,63 +1,185 @@
 package brew
 
 import (
+	"bytes"
 	"crypto/sha256"
+	"encoding/gob"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fastbrew/internal/resume"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
+// Cache artifacts, as named in CacheStatus.Artifact.
+const (
+	ArtifactFormulaIndex   = "formula index"
+	ArtifactCaskIndex      = "cask index"
+	ArtifactSearchIndex    = "search index"
+	ArtifactPrefixIndex    = "prefix index"
+	ArtifactIndexDB        = "index database"
+	ArtifactResumeMetadata = "resume metadata"
+)
+
+// jsonSampleSize is how many entries of a cached index are decoded when it
+// is validated. Loading an index decodes all of it; the sample, with the
+// zstd frame checksum and the closing bracket, catches truncation and a
+// wrong payload at a fraction of the cost.
+const jsonSampleSize = 100
+
+// zstdMagic starts every zstd frame.
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// CacheValidator checks the cached indexes, the indexes built from them and
+// the resume metadata of partial downloads, and repairs the broken ones.
 type CacheValidator struct {
 	cacheDir string
+	// client downloads and rebuilds what Repair removes. Without one,
+	// Repair only removes broken artifacts and restores index snapshots.
+	client *Client
 }
 
 func NewCacheValidator(cacheDir string) *CacheValidator {
 	return &CacheValidator{cacheDir: cacheDir}
 }
 
+// CacheValidator returns a validator for the client's cache directory whose
+// Repair can download and rebuild indexes.
+func (c *Client) CacheValidator() (*CacheValidator, error) {
+	cacheDir, err := c.GetCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return &CacheValidator{cacheDir: cacheDir, client: c}, nil
+}
+
 type CacheStatus struct {
-	Path      string
-	Valid     bool
-	Size      int64
-	Checksum  string
-	Error     error
+	Path     string
+	Artifact string
+	Valid    bool
+	// Missing is set when the file does not exist. Only the formula and
+	// cask indexes are required; the others are built on demand.
+	Missing  bool
+	Size     int64
+	Checksum string
+	Error    error
 }
 
+// ValidateAll checks every cache artifact: the formula and cask indexes,
+// the search index, prefix index and index database built from them, and
+// each partial download's resume metadata.
 func (v *CacheValidator) ValidateAll() ([]CacheStatus, error) {
-	var statuses []CacheStatus
-
-	formulaStatus := v.ValidateFormulaCache()
-	statuses = append(statuses, formulaStatus)
-
-	caskStatus := v.ValidateCaskCache()
-	statuses = append(statuses, caskStatus)
-
-	searchStatus := v.ValidateSearchCache()
-	statuses = append(statuses, searchStatus)
-
-	return statuses, nil
+	statuses := []CacheStatus{
+		v.ValidateFormulaCache(),
+		v.ValidateCaskCache(),
+		v.ValidateSearchCache(),
+		v.ValidatePrefixIndex(),
+		v.ValidateIndexDB(),
+	}
+	resumeStatuses, err := v.ValidateResumeMetadata()
+	if err != nil {
+		return statuses, err
+	}
+	return append(statuses, resumeStatuses...), nil
 }
 
 func (v *CacheValidator) ValidateFormulaCache() CacheStatus {
-	path := fmt.Sprintf("%s/formula.json.zst", v.cacheDir)
-	return v.validateJSONCache(path)
+	return v.validate(filepath.Join(v.cacheDir, "formula.json.zst"), ArtifactFormulaIndex, true, func(data []byte) error {
+		return validateIndexJSON(data, false)
+	})
 }
 
+// ValidateCaskCache checks the cask index, which may be empty, as it is on
+// Linux mirrors without casks.
 func (v *CacheValidator) ValidateCaskCache() CacheStatus {
-	path := fmt.Sprintf("%s/cask.json.zst", v.cacheDir)
-	return v.validateJSONCache(path)
+	return v.validate(filepath.Join(v.cacheDir, "cask.json.zst"), ArtifactCaskIndex, true, func(data []byte) error {
+		return validateIndexJSON(data, true)
+	})
 }
 
+// ValidateSearchCache checks that the search index decodes into this
+// version's SearchItem, which fails for one written with incompatible types.
 func (v *CacheValidator) ValidateSearchCache() CacheStatus {
-	path := fmt.Sprintf("%s/search.gob.zst", v.cacheDir)
-	return v.validateGOBCache(path)
+	return v.validate(filepath.Join(v.cacheDir, "search.gob.zst"), ArtifactSearchIndex, false, func(data []byte) error {
+		data, err := decompressCached(data)
+		if err != nil {
+			return err
+		}
+		var items []SearchItem
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&items); err != nil {
+			return fmt.Errorf("gob decode failed: %w", err)
+		}
+		return nil
+	})
 }
 
-func (v *CacheValidator) validateJSONCache(path string) CacheStatus {
-	status := CacheStatus{Path: path}
+// ValidatePrefixIndex checks the prefix index's magic, format version and
+// length, and decodes its items.
+func (v *CacheValidator) ValidatePrefixIndex() CacheStatus {
+	path := filepath.Join(v.cacheDir, prefixIndexFileName)
+	return v.validate(path, ArtifactPrefixIndex, false, func([]byte) error {
+		return NewPrefixIndex().Load(path)
+	})
+}
+
+// ValidateIndexDB checks that the index database opens as a bbolt file of
+// this format version, and reads every record so damaged pages are found
+// here rather than by a lookup.
+func (v *CacheValidator) ValidateIndexDB() CacheStatus {
+	path := filepath.Join(v.cacheDir, indexDBFileName)
+	return v.validate(path, ArtifactIndexDB, false, func([]byte) error {
+		db, err := OpenIndexDB(path)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		return db.check()
+	})
+}
+
+// ValidateResumeMetadata checks the resume metadata of each partial
+// download: that it parses, names a URL and a partial file that exists,
+// and does not record more bytes than the file's total size. Downloads
+// resumed from inconsistent metadata fail their checksum at the end.
+func (v *CacheValidator) ValidateResumeMetadata() ([]CacheStatus, error) {
+	entries, err := os.ReadDir(v.cacheDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var statuses []CacheStatus
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), resume.ResumeMetadataSuffix) {
+			continue
+		}
+		path := filepath.Join(v.cacheDir, entry.Name())
+		statuses = append(statuses, v.validate(path, ArtifactResumeMetadata, true, func(data []byte) error {
+			return validateResumeMetadata(path, data)
+		}))
+	}
+	return statuses, nil
+}
+
+// validate reads path and runs check on its contents. A missing file is
+// valid unless the artifact is required.
+func (v *CacheValidator) validate(path, artifact string, required bool, check func([]byte) error) CacheStatus {
+	status := CacheStatus{Path: path, Artifact: artifact}
 
 	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		status.Missing = true
+		status.Valid = !required
+		if required {
+			status.Error = errors.New("missing")
+		}
+		return status
+	}
 	if err != nil {
 		status.Error = fmt.Errorf("failed to read: %w", err)
 		return status
@@ -67,41 +189,204 @@ func (v *CacheValidator) validateJSONCache(path string) CacheStatus {
 	hash := sha256.Sum256(data)
 	status.Checksum = hex.EncodeToString(hash[:])
 
-	decompressed, err := decompressWithPool(data)
-	if err != nil {
-		status.Error = fmt.Errorf("decompression failed: %w", err)
+	if err := check(data); err != nil {
+		status.Error = err
 		return status
 	}
+	status.Valid = true
+	return status
+}
 
-	var result interface{}
-	if err := json.Unmarshal(decompressed, &result); err != nil {
-		status.Error = fmt.Errorf("json validation failed: %w", err)
-		return status
+// decompressCached decompresses a cache file written with compressFile.
+// Data under minCompressSize is stored uncompressed and returned as is;
+// anything larger must start with the zstd magic.
+func decompressCached(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, zstdMagic) {
+		if len(data) >= minCompressSize {
+			return nil, errors.New("not zstd-compressed: bad magic")
+		}
+		return data, nil
 	}
+	decompressed, err := decompressFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("decompression failed: %w", err)
+	}
+	return decompressed, nil
+}
 
-	status.Valid = true
-	return status
+// validateIndexJSON checks a cached formula or cask index: that it
+// decompresses, that its first jsonSampleSize entries decode and have a
+// name, and that the list is closed.
+func validateIndexJSON(data []byte, allowEmpty bool) error {
+	data, err := decompressCached(data)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return errors.New("json validation failed: not a list of packages")
+	}
+	n := 0
+	for ; n < jsonSampleSize && dec.More(); n++ {
+		var entry indexEntry
+		if err := dec.Decode(&entry); err != nil {
+			return fmt.Errorf("json validation failed at entry %d: %w", n, err)
+		}
+		if !entry.named() {
+			return fmt.Errorf("entry %d has no name", n)
+		}
+	}
+	if n == 0 && !allowEmpty {
+		return errEmptyIndex
+	}
+	if trimmed := bytes.TrimRight(data, " \t\r\n"); trimmed[len(trimmed)-1] != ']' {
+		return errors.New("json validation failed: truncated")
+	}
+	return nil
 }
 
-func (v *CacheValidator) validateGOBCache(path string) CacheStatus {
-	status := CacheStatus{Path: path}
+// errPartialFileMissing is the problem with resume metadata whose partial
+// file was deleted. Doctor reports these under partial downloads.
+var errPartialFileMissing = errors.New("partial file is missing")
 
-	data, err := os.ReadFile(path)
+// validateResumeMetadata checks the resume metadata at path, read as data.
+func validateResumeMetadata(path string, data []byte) error {
+	var pd resume.PartialDownload
+	if err := json.Unmarshal(data, &pd); err != nil {
+		return fmt.Errorf("failed to parse: %w", err)
+	}
+	if pd.URL == "" {
+		return errors.New("no URL")
+	}
+	info, err := os.Stat(strings.TrimSuffix(path, resume.ResumeMetadataSuffix))
 	if err != nil {
-		status.Error = fmt.Errorf("failed to read: %w", err)
-		return status
+		return errPartialFileMissing
+	}
+	if pd.TotalSize > 0 && (info.Size() > pd.TotalSize || pd.DownloadedBytes > pd.TotalSize) {
+		return fmt.Errorf("partial file has %d bytes, more than the %d expected", max(info.Size(), pd.DownloadedBytes), pd.TotalSize)
 	}
+	return nil
+}
 
-	status.Size = int64(len(data))
-	hash := sha256.Sum256(data)
-	status.Checksum = hex.EncodeToString(hash[:])
+// Repair actions, as recorded in CacheRepair.Action.
+const (
+	RepairRemoved    = "removed"
+	RepairRestored   = "restored from snapshot"
+	RepairDownloaded = "downloaded again"
+	RepairRebuilt    = "rebuilt"
+)
+
+// CacheRepair records what Repair did about one broken artifact.
+type CacheRepair struct {
+	Path     string
+	Artifact string
+	// Problem is what ValidateAll found wrong with it.
+	Problem error
+	Action  string
+	// Err is set when the repair failed.
+	Err error
+}
 
-	_, err = decompressWithPool(data)
+// Repair fixes the artifacts ValidateAll finds broken, and leaves the rest
+// alone. A broken formula or cask index is replaced with its copy in the
+// newest index snapshot that validates, or else downloaded again. A broken
+// search index, prefix index or index database is removed and rebuilt.
+// Inconsistent resume metadata is removed with its partial file, so that
+// download starts over. Without a client nothing is downloaded or rebuilt.
+func (v *CacheValidator) Repair() ([]CacheRepair, error) {
+	statuses, err := v.ValidateAll()
 	if err != nil {
-		status.Error = fmt.Errorf("decompression failed: %w", err)
-		return status
+		return nil, err
+	}
+	var repairs []CacheRepair
+	rebuild := false
+	for _, status := range statuses {
+		if status.Valid {
+			continue
+		}
+		repair := CacheRepair{Path: status.Path, Artifact: status.Artifact, Problem: status.Error}
+		switch status.Artifact {
+		case ArtifactFormulaIndex, ArtifactCaskIndex:
+			repair.Action, repair.Err = v.repairIndex(status.Artifact)
+			rebuild = rebuild || repair.Err == nil
+		case ArtifactResumeMetadata:
+			repair.Action = RepairRemoved
+			repair.Err = removeFiles(status.Path, strings.TrimSuffix(status.Path, resume.ResumeMetadataSuffix))
+		default:
+			repair.Action = RepairRemoved
+			repair.Err = removeFiles(status.Path)
+			if v.client != nil && repair.Err == nil {
+				repair.Action = RepairRebuilt
+				rebuild = true
+			}
+		}
+		repairs = append(repairs, repair)
 	}
 
-	status.Valid = true
-	return status
+	// Indexes built from a replaced formula or cask index are stale now,
+	// and are rebuilt along with the removed ones.
+	if rebuild && v.client != nil {
+		v.client.resetIndexState()
+		if err := v.client.RebuildIndexes(); err != nil {
+			for i := range repairs {
+				if repairs[i].Action == RepairRebuilt {
+					repairs[i].Err = err
+				}
+			}
+		}
+	}
+	return repairs, nil
+}
+
+// repairIndex replaces a broken formula or cask index. The cache metadata
+// goes with it, as its ETag would have the server answer Not Modified.
+func (v *CacheValidator) repairIndex(artifact string) (string, error) {
+	name, url, label := "formula.json.zst", FormulaAPI, "Formula"
+	check := (*CacheValidator).ValidateFormulaCache
+	if artifact == ArtifactCaskIndex {
+		name, url, label = "cask.json.zst", CaskAPI, "Cask"
+		check = (*CacheValidator).ValidateCaskCache
+	}
+	path := filepath.Join(v.cacheDir, name)
+	if err := removeFiles(path, path+".meta.json"); err != nil {
+		return RepairRemoved, err
+	}
+
+	snapshots, _ := listIndexSnapshots(v.cacheDir)
+	for _, snapshot := range snapshots {
+		if !check(&CacheValidator{cacheDir: snapshot.Path}).Valid {
+			continue
+		}
+		if err := linkOrCopy(filepath.Join(snapshot.Path, name), path); err != nil {
+			return RepairRestored, err
+		}
+		linkOrCopy(filepath.Join(snapshot.Path, name+".meta.json"), path+".meta.json")
+		// Newer than the indexes built from the broken copy, so those
+		// count as stale.
+		now := time.Now()
+		os.Chtimes(path, now, now)
+		return RepairRestored, nil
+	}
+
+	if v.client == nil {
+		return RepairRemoved, nil
+	}
+	if _, err := v.client.downloadAndCompress(url, path, label); err != nil {
+		return RepairDownloaded, err
+	}
+	if status := check(v); !status.Valid {
+		os.Remove(path)
+		return RepairDownloaded, fmt.Errorf("downloaded %s is broken too: %w", artifact, status.Error)
+	}
+	return RepairDownloaded, nil
+}
+
+// removeFiles removes paths, ignoring those already gone.
+func removeFiles(paths ...string) error {
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,189 @@
+package brew
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testIndexJSON returns a formula index listing n formulae.
+func testIndexJSON(n int) []byte {
+	entries := make([]string, n)
+	for i := range entries {
+		entries[i] = fmt.Sprintf(`{"name": "pkg%d", "versions": {"stable": "1.%d"}}`, i, i)
+	}
+	return []byte("[" + strings.Join(entries, ", ") + "]")
+}
+
+func writeCacheFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeBrokenCache fills cacheDir with one broken copy of each artifact
+// next to a valid formula index, and returns the paths expected invalid.
+func writeBrokenCache(t *testing.T, cacheDir string) map[string]bool {
+	t.Helper()
+	writeCacheFile(t, filepath.Join(cacheDir, "formula.json.zst"), compressWithPool(testIndexJSON(200)))
+
+	truncated := compressWithPool(testIndexJSON(200))
+	writeCacheFile(t, filepath.Join(cacheDir, "cask.json.zst"), truncated[:len(truncated)/2])
+	writeCacheFile(t, filepath.Join(cacheDir, "search.gob.zst"), bytes.Repeat([]byte("not zstd"), 200))
+
+	oldPrefix := make([]byte, 20)
+	copy(oldPrefix, prefixIndexMagic[:])
+	binary.LittleEndian.PutUint32(oldPrefix[4:], prefixIndexVersion-1)
+	writeCacheFile(t, filepath.Join(cacheDir, prefixIndexFileName), oldPrefix)
+
+	overrun := filepath.Join(cacheDir, "wget.tar.gz")
+	writeCacheFile(t, overrun, make([]byte, 64))
+	writeCacheFile(t, overrun+".fastbrew-resume", []byte(`{"url": "https://example.com/wget.tar.gz", "total_size": 32, "downloaded_bytes": 64}`))
+	writeCacheFile(t, filepath.Join(cacheDir, "jq.tar.gz.fastbrew-resume"), []byte(`{"url": `))
+
+	healthy := filepath.Join(cacheDir, "curl.tar.gz")
+	writeCacheFile(t, healthy, make([]byte, 16))
+	writeCacheFile(t, healthy+".fastbrew-resume", []byte(`{"url": "https://example.com/curl.tar.gz", "total_size": 32, "downloaded_bytes": 16}`))
+
+	return map[string]bool{
+		filepath.Join(cacheDir, "cask.json.zst"):             true,
+		filepath.Join(cacheDir, "search.gob.zst"):            true,
+		filepath.Join(cacheDir, prefixIndexFileName):         true,
+		overrun + ".fastbrew-resume":                         true,
+		filepath.Join(cacheDir, "jq.tar.gz.fastbrew-resume"): true,
+	}
+}
+
+func TestCacheValidatorFindsBrokenArtifacts(t *testing.T) {
+	cacheDir := t.TempDir()
+	broken := writeBrokenCache(t, cacheDir)
+
+	statuses, err := NewCacheValidator(cacheDir).ValidateAll()
+	if err != nil {
+		t.Fatalf("ValidateAll failed: %v", err)
+	}
+	for _, status := range statuses {
+		if status.Valid == broken[status.Path] {
+			t.Errorf("%s (%s): valid = %v, error %v", filepath.Base(status.Path), status.Artifact, status.Valid, status.Error)
+		}
+		if status.Artifact == ArtifactIndexDB && (!status.Valid || !status.Missing) {
+			t.Errorf("a missing index database should be valid: %+v", status)
+		}
+	}
+}
+
+func TestValidateIndexJSON(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       []byte
+		allowEmpty bool
+		wantErr    bool
+	}{
+		{"compressed", compressWithPool(testIndexJSON(200)), false, false},
+		{"small and uncompressed", testIndexJSON(2), false, false},
+		{"empty cask index", []byte("[]"), true, false},
+		{"cask with display names", []byte(`[{"token": "firefox", "name": ["Firefox"]}]`), true, false},
+		{"empty formula index", []byte("[]"), false, true},
+		{"not a list", []byte(`{"name": "wget"}`), false, true},
+		{"nameless entry", []byte(`[{"desc": "x"}]`), false, true},
+		{"truncated past the sample", testIndexJSON(200)[:900], false, true},
+		{"large without magic", testIndexJSON(200), false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateIndexJSON(tt.data, tt.allowEmpty); (err != nil) != tt.wantErr {
+				t.Errorf("validateIndexJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCacheValidatorRepair(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	casks := `[{"token": "firefox", "name": ["Firefox"]}]`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/cask.json" {
+			_, _ = w.Write([]byte(casks))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	client := &Client{Out: &bytes.Buffer{}, CacheDir: t.TempDir(), Mirrors: map[string]string{"formulae.brew.sh": server.URL}}
+	cacheDir := client.CacheDir
+	writeBrokenCache(t, cacheDir)
+	formulaBefore, _ := os.ReadFile(filepath.Join(cacheDir, "formula.json.zst"))
+
+	validator, err := client.CacheValidator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repairs, err := validator.Repair()
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	actions := map[string]string{}
+	for _, repair := range repairs {
+		if repair.Err != nil {
+			t.Errorf("repair of %s failed: %v", repair.Path, repair.Err)
+		}
+		actions[filepath.Base(repair.Path)] = repair.Action
+	}
+	want := map[string]string{
+		"cask.json.zst":               RepairDownloaded,
+		"search.gob.zst":              RepairRebuilt,
+		prefixIndexFileName:           RepairRebuilt,
+		"wget.tar.gz.fastbrew-resume": RepairRemoved,
+		"jq.tar.gz.fastbrew-resume":   RepairRemoved,
+	}
+	if fmt.Sprint(actions) != fmt.Sprint(want) {
+		t.Errorf("repairs = %v, want %v", actions, want)
+	}
+
+	if formulaAfter, _ := os.ReadFile(filepath.Join(cacheDir, "formula.json.zst")); !bytes.Equal(formulaBefore, formulaAfter) {
+		t.Error("valid formula index was replaced")
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, "wget.tar.gz")); !os.IsNotExist(err) {
+		t.Error("partial file of broken resume metadata was kept")
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, "curl.tar.gz.fastbrew-resume")); err != nil {
+		t.Errorf("consistent resume metadata was removed: %v", err)
+	}
+	statuses, _ := validator.ValidateAll()
+	for _, status := range statuses {
+		if !status.Valid {
+			t.Errorf("%s still invalid after repair: %v", filepath.Base(status.Path), status.Error)
+		}
+	}
+}
+
+func TestCacheValidatorRepairRestoresIndexSnapshot(t *testing.T) {
+	cacheDir := t.TempDir()
+	snapshot := filepath.Join(cacheDir, indexSnapshotDirName, "20260101T000000.000000000Z")
+	if err := os.MkdirAll(snapshot, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeCacheFile(t, filepath.Join(snapshot, "formula.json.zst"), testIndexJSON(2))
+	writeCacheFile(t, filepath.Join(cacheDir, "formula.json.zst"), []byte(`[{"name": "wg`))
+	writeCacheFile(t, filepath.Join(cacheDir, "cask.json.zst"), []byte(`[]`))
+
+	repairs, err := NewCacheValidator(cacheDir).Repair()
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	if len(repairs) != 1 || repairs[0].Action != RepairRestored || repairs[0].Err != nil {
+		t.Fatalf("expected the formula index restored from the snapshot, got %+v", repairs)
+	}
+	if status := NewCacheValidator(cacheDir).ValidateFormulaCache(); !status.Valid {
+		t.Errorf("restored formula index invalid: %v", status.Error)
+	}
+}
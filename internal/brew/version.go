@@ -0,0 +1,281 @@
+package brew
+
+import (
+	"strconv"
+	"strings"
+)
+
+// versionTokenKind orders the token classes used by Homebrew's version
+// comparison. Pre-release tokens sort below a missing component, patch
+// tokens sort above it.
+type versionTokenKind int
+
+const (
+	tokenPreRelease versionTokenKind = iota
+	tokenNull
+	tokenString
+	tokenPatch
+	tokenNumeric
+)
+
+type versionToken struct {
+	kind versionTokenKind
+	// rank orders pre-release tokens: alpha < beta < pre < rc.
+	rank int
+	// num holds the digits of a numeric token, or the trailing number of
+	// a pre-release/patch token ("rc2" -> "2"), without leading zeros.
+	num string
+	str string
+}
+
+var preReleaseRanks = map[string]int{
+	"alpha": 1, "a": 1,
+	"beta": 2, "b": 2,
+	"pre": 3,
+	"rc":  4,
+}
+
+var patchWords = map[string]bool{
+	"p":     true,
+	"patch": true,
+	"post":  true,
+}
+
+// parsedVersion is a version string split into epoch, comparable tokens
+// and Homebrew revision ("_N" suffix).
+type parsedVersion struct {
+	epoch    int
+	tokens   []versionToken
+	revision int
+}
+
+// stripRevision removes revision suffixes like "_1" from version strings.
+func stripRevision(version string) string {
+	if idx := strings.Index(version, "_"); idx != -1 {
+		return version[:idx]
+	}
+	return version
+}
+
+// extractRevision extracts the revision number from a version string.
+// e.g. "15.2.0_1" returns 1, "15.2.0" returns 0.
+func extractRevision(version string) int {
+	if idx := strings.LastIndex(version, "_"); idx != -1 {
+		rev, err := strconv.Atoi(version[idx+1:])
+		if err == nil {
+			return rev
+		}
+	}
+	return 0
+}
+
+// splitEpoch separates a leading "N:" epoch from the version.
+func splitEpoch(version string) (int, string) {
+	idx := strings.Index(version, ":")
+	if idx <= 0 {
+		return 0, version
+	}
+	epoch, err := strconv.Atoi(version[:idx])
+	if err != nil {
+		return 0, version
+	}
+	return epoch, version[idx+1:]
+}
+
+func parseVersion(version string) parsedVersion {
+	version = strings.TrimSpace(version)
+	epoch, rest := splitEpoch(version)
+
+	revision := 0
+	if idx := strings.LastIndex(rest, "_"); idx != -1 {
+		if rev, err := strconv.Atoi(rest[idx+1:]); err == nil {
+			revision = rev
+			rest = rest[:idx]
+		}
+	}
+
+	return parsedVersion{
+		epoch:    epoch,
+		tokens:   tokenizeVersion(rest),
+		revision: revision,
+	}
+}
+
+// tokenizeVersion splits a version into alternating runs of digits and
+// letters. Separators (".", "-", "_", "+", ",") only delimit tokens.
+func tokenizeVersion(version string) []versionToken {
+	lower := strings.ToLower(version)
+	var tokens []versionToken
+
+	for i := 0; i < len(lower); {
+		ch := lower[i]
+		switch {
+		case isDigit(ch):
+			j := i
+			for j < len(lower) && isDigit(lower[j]) {
+				j++
+			}
+			tokens = append(tokens, versionToken{kind: tokenNumeric, num: trimLeadingZeros(lower[i:j])})
+			i = j
+		case isLetter(ch):
+			j := i
+			for j < len(lower) && isLetter(lower[j]) {
+				j++
+			}
+			word := lower[i:j]
+			k := j
+			for k < len(lower) && isDigit(lower[k]) {
+				k++
+			}
+			digits := lower[j:k]
+
+			if rank, ok := preReleaseRanks[word]; ok && (len(word) > 1 || digits != "") {
+				tokens = append(tokens, versionToken{kind: tokenPreRelease, rank: rank, num: trimLeadingZeros(digits)})
+				i = k
+				continue
+			}
+			if patchWords[word] {
+				tokens = append(tokens, versionToken{kind: tokenPatch, num: trimLeadingZeros(digits)})
+				i = k
+				continue
+			}
+			tokens = append(tokens, versionToken{kind: tokenString, str: word})
+			i = j
+		default:
+			i++
+		}
+	}
+
+	return tokens
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isLetter(ch byte) bool {
+	return ch >= 'a' && ch <= 'z'
+}
+
+func trimLeadingZeros(digits string) string {
+	trimmed := strings.TrimLeft(digits, "0")
+	if trimmed == "" && digits != "" {
+		return "0"
+	}
+	return trimmed
+}
+
+// compareDigits compares two non-negative integers given as digit strings
+// without leading zeros, so arbitrarily long date-style versions never overflow.
+func compareDigits(a, b string) int {
+	if a == "" {
+		a = "0"
+	}
+	if b == "" {
+		b = "0"
+	}
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func compareTokens(a, b versionToken) int {
+	// A zero numeric component is equivalent to a missing one: 1.0 == 1.0.0.
+	if a.kind == tokenNumeric && a.num == "0" {
+		a = versionToken{kind: tokenNull}
+	}
+	if b.kind == tokenNumeric && b.num == "0" {
+		b = versionToken{kind: tokenNull}
+	}
+
+	if a.kind != b.kind {
+		if a.kind < b.kind {
+			return -1
+		}
+		return 1
+	}
+
+	switch a.kind {
+	case tokenNumeric:
+		return compareDigits(a.num, b.num)
+	case tokenPreRelease:
+		if a.rank != b.rank {
+			if a.rank < b.rank {
+				return -1
+			}
+			return 1
+		}
+		return compareDigits(a.num, b.num)
+	case tokenPatch:
+		return compareDigits(a.num, b.num)
+	case tokenString:
+		return strings.Compare(a.str, b.str)
+	}
+	return 0
+}
+
+func compareInts(a, b int) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+// versionCompare compares two version strings using Homebrew-style ordering:
+// an optional "N:" epoch first, then numeric and alphabetic components
+// (with alpha < beta < pre < rc < release < patch), then the "_N" revision.
+// Returns: -1 if v1 < v2, 0 if v1 == v2, 1 if v1 > v2
+func versionCompare(v1, v2 string) int {
+	p1 := parseVersion(v1)
+	p2 := parseVersion(v2)
+
+	if cmp := compareInts(p1.epoch, p2.epoch); cmp != 0 {
+		return cmp
+	}
+
+	maxLen := len(p1.tokens)
+	if len(p2.tokens) > maxLen {
+		maxLen = len(p2.tokens)
+	}
+
+	null := versionToken{kind: tokenNull}
+	for i := 0; i < maxLen; i++ {
+		t1, t2 := null, null
+		if i < len(p1.tokens) {
+			t1 = p1.tokens[i]
+		}
+		if i < len(p2.tokens) {
+			t2 = p2.tokens[i]
+		}
+		if cmp := compareTokens(t1, t2); cmp != 0 {
+			return cmp
+		}
+	}
+
+	return compareInts(p1.revision, p2.revision)
+}
+
+// isComparableVersion reports whether a version can be ordered at all.
+// Casks with version "latest" and HEAD builds have no meaningful ordering.
+func isComparableVersion(version string) bool {
+	v := strings.TrimSpace(version)
+	if v == "" || strings.EqualFold(v, "latest") {
+		return false
+	}
+	return !strings.HasPrefix(strings.ToUpper(v), "HEAD")
+}
+
+// isOutdated checks if installed version is outdated compared to new version
+func isOutdated(installed, latest string) bool {
+	if !isComparableVersion(installed) || !isComparableVersion(latest) {
+		return false
+	}
+	return versionCompare(installed, latest) < 0
+}
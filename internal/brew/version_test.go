@@ -26,6 +26,21 @@ func TestVersionCompare(t *testing.T) {
 		{"0.9.0", "1.0.0", -1},
 		{"15.2.0", "15.2.0_1", -1},
 		{"3.12.12", "3.12.12_2", -1},
+		{"1.0.0-alpha", "1.0.0", -1},
+		{"1.0.0-alpha", "1.0.0-beta", -1},
+		{"1.0.0-beta2", "1.0.0-beta10", -1},
+		{"1.0.0-rc1", "1.0.0-beta3", 1},
+		{"1.0rc1", "1.0", -1},
+		{"1.0a1", "1.0b1", -1},
+		{"1.0p1", "1.0", 1},
+		{"1.0a", "1.0", 1},
+		{"1.0.1", "1.0p1", 1},
+		{"1:1.0", "2.0", 1},
+		{"1:1.0", "1:1.1", -1},
+		{"20240101", "20231231", 1},
+		{"123456789012345678901", "123456789012345678902", -1},
+		{"1.02", "1.2", 0},
+		{"134.0.6998.88,abc", "134.0.6998.89,def", -1},
 	}
 
 	for _, tt := range tests {
@@ -53,6 +68,12 @@ func TestIsOutdated(t *testing.T) {
 		{"3.12.12", "3.12.12_2", true},
 		{"1.0.0", "1.0.1", true},
 		{"10.0", "9.9", false},
+		{"1.0", "1.0.0", false},
+		{"2.0-rc1", "2.0", true},
+		{"2.0", "2.0-rc1", false},
+		{"latest", "latest", false},
+		{"1.0", "latest", false},
+		{"HEAD-abc1234", "1.0", false},
 	}
 
 	for _, tt := range tests {
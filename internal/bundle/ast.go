@@ -78,6 +78,9 @@ func (w *WhitespaceCommand) Type() string       { return "whitespace" }
 type Brewfile struct {
 	Nodes []Node
 	Path  string // original file path
+
+	// source holds the original text of parsed nodes, see Write.
+	source map[Node]*sourceText
 }
 
 // GetBrews returns all brew commands from the Brewfile
@@ -0,0 +1,97 @@
+package bundle
+
+import (
+	"strings"
+)
+
+// CheckState describes what is currently installed on the system.
+type CheckState struct {
+	Formulae map[string]bool
+	Casks    map[string]bool
+	// Taps holds lowercase "user/repo" names.
+	Taps map[string]bool
+	// MasApps holds installed App Store IDs; nil when mas is unavailable.
+	MasApps map[int]bool
+}
+
+// CheckEntry is the satisfaction state of one Brewfile entry.
+type CheckEntry struct {
+	Type      string // "tap", "brew", "cask" or "mas"
+	Name      string
+	Satisfied bool
+	// Reason explains an unsatisfied entry when "not installed" would be
+	// misleading.
+	Reason string
+}
+
+// CheckReport lists every Brewfile entry with its satisfaction state.
+type CheckReport struct {
+	Entries []CheckEntry
+}
+
+// Missing returns the entries that are not satisfied.
+func (r *CheckReport) Missing() []CheckEntry {
+	var missing []CheckEntry
+	for _, e := range r.Entries {
+		if !e.Satisfied {
+			missing = append(missing, e)
+		}
+	}
+	return missing
+}
+
+// Satisfied reports whether every entry is satisfied.
+func (r *CheckReport) Satisfied() bool {
+	return len(r.Missing()) == 0
+}
+
+// builtinTaps are served from the Homebrew API and never need cloning.
+var builtinTaps = map[string]bool{
+	"homebrew/core": true,
+	"homebrew/cask": true,
+}
+
+// Check compares the entries of a Brewfile against state, in the order
+// taps, formulae, casks, Mac App Store apps.
+func Check(bf *Brewfile, state CheckState) *CheckReport {
+	report := &CheckReport{}
+
+	for _, tap := range bf.GetTaps() {
+		name := tap.User + "/" + tap.Repo
+		key := strings.ToLower(name)
+		report.Entries = append(report.Entries, CheckEntry{
+			Type:      "tap",
+			Name:      name,
+			Satisfied: builtinTaps[key] || state.Taps[key],
+		})
+	}
+
+	for _, b := range bf.GetBrews() {
+		// Tap formulae are installed under their short name.
+		short := b.Name[strings.LastIndex(b.Name, "/")+1:]
+		report.Entries = append(report.Entries, CheckEntry{
+			Type:      "brew",
+			Name:      b.Name,
+			Satisfied: state.Formulae[short],
+		})
+	}
+
+	for _, c := range bf.GetCasks() {
+		short := c.Name[strings.LastIndex(c.Name, "/")+1:]
+		report.Entries = append(report.Entries, CheckEntry{
+			Type:      "cask",
+			Name:      c.Name,
+			Satisfied: state.Casks[short],
+		})
+	}
+
+	for _, app := range bf.GetMasApps() {
+		entry := CheckEntry{Type: "mas", Name: app.Name, Satisfied: state.MasApps[app.ID]}
+		if state.MasApps == nil {
+			entry.Reason = "mas CLI not available"
+		}
+		report.Entries = append(report.Entries, entry)
+	}
+
+	return report
+}
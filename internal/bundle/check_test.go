@@ -0,0 +1,53 @@
+package bundle
+
+import (
+	"testing"
+)
+
+func TestCheckReportsMissingEntries(t *testing.T) {
+	bf := &Brewfile{Nodes: []Node{
+		&TapCommand{User: "homebrew", Repo: "core"},
+		&TapCommand{User: "Acme", Repo: "tools"},
+		&TapCommand{User: "other", Repo: "tap"},
+		&BrewCommand{Name: "wget"},
+		&BrewCommand{Name: "acme/tools/widget"},
+		&BrewCommand{Name: "jq"},
+		&CaskCommand{Name: "iterm2"},
+		&CaskCommand{Name: "firefox"},
+		&MasCommand{Name: "Xcode", ID: 497799835},
+	}}
+	state := CheckState{
+		Formulae: map[string]bool{"wget": true, "widget": true},
+		Casks:    map[string]bool{"iterm2": true},
+		Taps:     map[string]bool{"acme/tools": true},
+		MasApps:  map[int]bool{497799835: true},
+	}
+
+	report := Check(bf, state)
+	if len(report.Entries) != 9 {
+		t.Fatalf("expected an entry per Brewfile line, got %+v", report.Entries)
+	}
+
+	missing := report.Missing()
+	want := []string{"tap:other/tap", "brew:jq", "cask:firefox"}
+	if len(missing) != len(want) {
+		t.Fatalf("missing = %+v, want %v", missing, want)
+	}
+	for i, entry := range missing {
+		if got := entry.Type + ":" + entry.Name; got != want[i] {
+			t.Errorf("missing[%d] = %s, want %s", i, got, want[i])
+		}
+	}
+	if report.Satisfied() {
+		t.Error("report should not be satisfied")
+	}
+}
+
+func TestCheckMasUnavailable(t *testing.T) {
+	bf := &Brewfile{Nodes: []Node{&MasCommand{Name: "Xcode", ID: 497799835}}}
+
+	missing := Check(bf, CheckState{}).Missing()
+	if len(missing) != 1 || missing[0].Reason == "" {
+		t.Fatalf("expected mas app to be missing with a reason, got %+v", missing)
+	}
+}
@@ -1,19 +1,25 @@
 package bundle
 
 import (
+	"fastbrew/internal/cmdrunner"
 	"fmt"
-	"os/exec"
 	"strings"
 )
 
 // Dumper collects information about installed packages for Brewfile generation
 type Dumper struct {
 	brewPath string
+	runner   cmdrunner.CommandRunner
 }
 
 // NewDumper creates a new Dumper using the system's brew command
 func NewDumper() *Dumper {
-	return &Dumper{brewPath: "brew"}
+	return NewDumperWithRunner(&cmdrunner.DefaultCommandRunner{})
+}
+
+// NewDumperWithRunner creates a Dumper that runs brew and mas through runner
+func NewDumperWithRunner(runner cmdrunner.CommandRunner) *Dumper {
+	return &Dumper{brewPath: "brew", runner: runner}
 }
 
 // DumpOptions configures what to include in the Brewfile
@@ -115,8 +121,7 @@ func (d *Dumper) Dump(opts DumpOptions) (*DumpResult, error) {
 
 // DumpBrews returns installed formulae
 func (d *Dumper) DumpBrews() ([]BrewInfo, error) {
-	cmd := exec.Command(d.brewPath, "list", "--formula", "--versions")
-	out, err := cmd.Output()
+	out, err := d.runner.Run(d.brewPath, "list", "--formula", "--versions")
 	if err != nil {
 		return nil, err
 	}
@@ -147,8 +152,7 @@ func (d *Dumper) DumpBrews() ([]BrewInfo, error) {
 
 // DumpCasks returns installed casks
 func (d *Dumper) DumpCasks() ([]CaskInfo, error) {
-	cmd := exec.Command(d.brewPath, "list", "--cask", "--versions")
-	out, err := cmd.Output()
+	out, err := d.runner.Run(d.brewPath, "list", "--cask", "--versions")
 	if err != nil {
 		return nil, err
 	}
@@ -179,8 +183,7 @@ func (d *Dumper) DumpCasks() ([]CaskInfo, error) {
 
 // DumpTaps returns active taps
 func (d *Dumper) DumpTaps() ([]TapInfo, error) {
-	cmd := exec.Command(d.brewPath, "tap")
-	out, err := cmd.Output()
+	out, err := d.runner.Run(d.brewPath, "tap")
 	if err != nil {
 		return nil, err
 	}
@@ -208,8 +211,7 @@ func (d *Dumper) DumpTaps() ([]TapInfo, error) {
 
 // DumpMas returns installed Mac App Store apps
 func (d *Dumper) DumpMas() ([]MasInfo, error) {
-	cmd := exec.Command("mas", "list")
-	out, err := cmd.Output()
+	out, err := d.runner.Run("mas", "list")
 	if err != nil {
 		return nil, err
 	}
@@ -236,7 +238,6 @@ func (d *Dumper) DumpMas() ([]MasInfo, error) {
 
 // IsMasInstalled checks if mas CLI is available
 func (d *Dumper) IsMasInstalled() bool {
-	cmd := exec.Command("mas", "--version")
-	err := cmd.Run()
+	_, err := d.runner.Run("mas", "--version")
 	return err == nil
 }
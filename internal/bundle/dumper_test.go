@@ -0,0 +1,41 @@
+package bundle
+
+import (
+	"errors"
+	"fastbrew/internal/cmdrunner"
+	"reflect"
+	"testing"
+)
+
+func TestDumperParsesBrewOutput(t *testing.T) {
+	runner := cmdrunner.NewMock()
+	runner.SetOutput("brew list --formula --versions", []byte("wget 1.21.4\njq 1.7.1\n"))
+	runner.SetOutput("brew list --cask --versions", []byte("firefox 120.0\n"))
+	runner.SetOutput("brew tap", []byte("homebrew/core\nuser/tools\n"))
+	runner.SetError("mas list", errors.New("mas: command not found"))
+
+	result, err := NewDumperWithRunner(runner).Dump(DefaultDumpOptions())
+	if err != nil {
+		t.Fatalf("Dump failed: %v", err)
+	}
+	if want := []BrewInfo{{Name: "wget", Version: "1.21.4"}, {Name: "jq", Version: "1.7.1"}}; !reflect.DeepEqual(result.Brews, want) {
+		t.Errorf("Brews = %+v, want %+v", result.Brews, want)
+	}
+	if want := []CaskInfo{{Name: "firefox", Version: "120.0"}}; !reflect.DeepEqual(result.Casks, want) {
+		t.Errorf("Casks = %+v, want %+v", result.Casks, want)
+	}
+	if len(result.Taps) != 2 || result.Taps[1].Name != "user/tools" {
+		t.Errorf("Taps = %+v", result.Taps)
+	}
+	if result.Mas == nil || len(result.Mas) != 0 {
+		t.Errorf("Mas = %+v, want empty when mas fails", result.Mas)
+	}
+}
+
+func TestDumperReportsBrewFailure(t *testing.T) {
+	runner := cmdrunner.NewMock()
+	runner.SetError("brew tap", errors.New("brew: command not found"))
+	if _, err := NewDumperWithRunner(runner).Dump(DefaultDumpOptions()); err == nil {
+		t.Fatal("expected an error when brew fails")
+	}
+}
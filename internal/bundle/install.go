@@ -0,0 +1,142 @@
+package bundle
+
+import (
+	"errors"
+	"fastbrew/internal/cmdrunner"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// InstallBackend performs the individual operations of a bundle install.
+type InstallBackend interface {
+	// Tap adds a tap; it should succeed when the tap already exists.
+	Tap(tap *TapCommand) error
+	// Install installs formulae or casks, skipping installed ones.
+	Install(names []string) error
+	// InstallMas installs a Mac App Store app by ID.
+	InstallMas(app *MasCommand) error
+}
+
+// EntryResult is the outcome of one Brewfile entry.
+type EntryResult struct {
+	Type string // "tap", "brew", "cask" or "mas"
+	Name string
+	Err  error
+}
+
+// InstallReport lists the outcome of every entry in Brewfile order per type.
+type InstallReport struct {
+	Results []EntryResult
+}
+
+// Failed returns the entries that could not be installed.
+func (r *InstallReport) Failed() []EntryResult {
+	var failed []EntryResult
+	for _, res := range r.Results {
+		if res.Err != nil {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// Install applies a Brewfile: taps first, then formulae, casks and Mac App
+// Store apps. Formulae and casks are installed as one batch each so their
+// downloads run in parallel; if a batch fails, its entries are retried one
+// at a time to find out which of them failed. Formulae from a tap that
+// could not be added fail without being attempted. onResult, if non-nil,
+// is called as each entry finishes.
+func Install(bf *Brewfile, backend InstallBackend, onResult func(EntryResult)) *InstallReport {
+	report := &InstallReport{}
+	add := func(res EntryResult) {
+		report.Results = append(report.Results, res)
+		if onResult != nil {
+			onResult(res)
+		}
+	}
+
+	failedTaps := make(map[string]error)
+	for _, tap := range bf.GetTaps() {
+		name := tap.User + "/" + tap.Repo
+		err := backend.Tap(tap)
+		if err != nil {
+			failedTaps[strings.ToLower(name)] = err
+		}
+		add(EntryResult{Type: "tap", Name: name, Err: err})
+	}
+
+	var brews []string
+	for _, b := range bf.GetBrews() {
+		if tap := formulaTap(b.Name); tap != "" {
+			if err, failed := failedTaps[strings.ToLower(tap)]; failed {
+				add(EntryResult{Type: "brew", Name: b.Name, Err: fmt.Errorf("tap %s failed: %w", tap, err)})
+				continue
+			}
+		}
+		brews = append(brews, b.Name)
+	}
+	installBatch(backend, "brew", brews, add)
+
+	var casks []string
+	for _, c := range bf.GetCasks() {
+		casks = append(casks, c.Name)
+	}
+	installBatch(backend, "cask", casks, add)
+
+	for _, app := range bf.GetMasApps() {
+		add(EntryResult{Type: "mas", Name: app.Name, Err: backend.InstallMas(app)})
+	}
+
+	return report
+}
+
+func installBatch(backend InstallBackend, kind string, names []string, add func(EntryResult)) {
+	if len(names) == 0 {
+		return
+	}
+	if err := backend.Install(names); err == nil || len(names) == 1 {
+		for _, name := range names {
+			add(EntryResult{Type: kind, Name: name, Err: err})
+		}
+		return
+	}
+	for _, name := range names {
+		add(EntryResult{Type: kind, Name: name, Err: backend.Install([]string{name})})
+	}
+}
+
+// formulaTap returns "user/repo" for a fully qualified "user/repo/formula"
+// name, or "" for a core formula.
+func formulaTap(name string) string {
+	parts := strings.Split(name, "/")
+	if len(parts) != 3 {
+		return ""
+	}
+	return parts[0] + "/" + parts[1]
+}
+
+// InstallMasApp installs a Mac App Store app with the mas CLI.
+func InstallMasApp(id int) error {
+	return installMasApp(&cmdrunner.DefaultCommandRunner{}, id)
+}
+
+func installMasApp(runner cmdrunner.CommandRunner, id int) error {
+	out, err := runner.Run("mas", "install", strconv.Itoa(id))
+	if errors.Is(err, exec.ErrNotFound) {
+		return fmt.Errorf("mas CLI not found (install it with `fastbrew install mas`)")
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			out = append(out, exitErr.Stderr...)
+		}
+		msg := strings.TrimSpace(string(out))
+		if msg == "" {
+			return fmt.Errorf("mas install %d: %w", id, err)
+		}
+		return fmt.Errorf("mas install %d: %s", id, msg)
+	}
+	return nil
+}
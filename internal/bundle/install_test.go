@@ -0,0 +1,114 @@
+package bundle
+
+import (
+	"errors"
+	"fastbrew/internal/cmdrunner"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+type fakeBackend struct {
+	failTaps  map[string]bool
+	failNames map[string]bool
+	calls     [][]string
+	mas       []int
+}
+
+func (f *fakeBackend) Tap(tap *TapCommand) error {
+	if f.failTaps[tap.User+"/"+tap.Repo] {
+		return errors.New("clone failed")
+	}
+	return nil
+}
+
+func (f *fakeBackend) Install(names []string) error {
+	f.calls = append(f.calls, names)
+	for _, name := range names {
+		if f.failNames[name] {
+			return errors.New("no bottle for " + name)
+		}
+	}
+	return nil
+}
+
+func (f *fakeBackend) InstallMas(app *MasCommand) error {
+	f.mas = append(f.mas, app.ID)
+	return nil
+}
+
+func TestInstallBatchesAndReportsEveryEntry(t *testing.T) {
+	bf := &Brewfile{Nodes: []Node{
+		&TapCommand{User: "acme", Repo: "tools"},
+		&BrewCommand{Name: "wget"},
+		&BrewCommand{Name: "jq"},
+		&CaskCommand{Name: "iterm2"},
+		&MasCommand{Name: "Xcode", ID: 497799835},
+	}}
+	backend := &fakeBackend{}
+
+	var seen []string
+	report := Install(bf, backend, func(res EntryResult) { seen = append(seen, res.Type+":"+res.Name) })
+
+	if len(report.Failed()) != 0 {
+		t.Fatalf("unexpected failures: %+v", report.Failed())
+	}
+	want := "tap:acme/tools brew:wget brew:jq cask:iterm2 mas:Xcode"
+	if got := strings.Join(seen, " "); got != want {
+		t.Errorf("results = %q, want %q", got, want)
+	}
+	if len(backend.calls) != 2 || len(backend.calls[0]) != 2 {
+		t.Errorf("expected one formula batch and one cask batch, got %v", backend.calls)
+	}
+	if len(backend.mas) != 1 || backend.mas[0] != 497799835 {
+		t.Errorf("expected mas install of Xcode, got %v", backend.mas)
+	}
+}
+
+func TestInstallAttributesBatchFailure(t *testing.T) {
+	bf := &Brewfile{Nodes: []Node{
+		&BrewCommand{Name: "wget"},
+		&BrewCommand{Name: "broken"},
+		&BrewCommand{Name: "jq"},
+	}}
+	report := Install(bf, &fakeBackend{failNames: map[string]bool{"broken": true}}, nil)
+
+	failed := report.Failed()
+	if len(failed) != 1 || failed[0].Name != "broken" {
+		t.Fatalf("expected only broken to fail, got %+v", failed)
+	}
+	if len(report.Results) != 3 {
+		t.Fatalf("expected a result per entry, got %+v", report.Results)
+	}
+}
+
+func TestInstallSkipsFormulaeFromFailedTap(t *testing.T) {
+	bf := &Brewfile{Nodes: []Node{
+		&TapCommand{User: "acme", Repo: "tools"},
+		&BrewCommand{Name: "acme/tools/widget"},
+		&BrewCommand{Name: "wget"},
+	}}
+	backend := &fakeBackend{failTaps: map[string]bool{"acme/tools": true}}
+	report := Install(bf, backend, nil)
+
+	failed := report.Failed()
+	if len(failed) != 2 || failed[0].Type != "tap" || failed[1].Name != "acme/tools/widget" {
+		t.Fatalf("expected tap and its formula to fail, got %+v", failed)
+	}
+	for _, call := range backend.calls {
+		for _, name := range call {
+			if name == "acme/tools/widget" {
+				t.Fatal("formula from failed tap should not be attempted")
+			}
+		}
+	}
+}
+
+func TestInstallMasAppReportsMissingMas(t *testing.T) {
+	runner := cmdrunner.NewMock()
+	runner.SetError("mas install 497799835", &exec.Error{Name: "mas", Err: exec.ErrNotFound})
+	err := installMasApp(runner, 497799835)
+	if err == nil || !strings.Contains(err.Error(), "mas CLI not found") {
+		t.Errorf("installMasApp() = %v, want mas CLI not found", err)
+	}
+}
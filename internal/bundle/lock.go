@@ -0,0 +1,135 @@
+package bundle
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fastbrew/internal/atomicfile"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// LockFileName is written next to the Brewfile it locks.
+const LockFileName = "Brewfile.lock.json"
+
+// lockfileVersion is bumped whenever the lock format changes incompatibly.
+const lockfileVersion = 1
+
+// Lockfile records the exact artifacts a Brewfile resolved to.
+type Lockfile struct {
+	Version int `json:"version"`
+	// Entries is a digest of the Brewfile entries. Comments and ordering
+	// do not affect it, so only real changes make the lock stale.
+	Entries  string          `json:"entries_sha256"`
+	Platform string          `json:"platform"`
+	Taps     []string        `json:"taps,omitempty"`
+	Brews    []LockedFormula `json:"brews,omitempty"`
+	Casks    []LockedCask    `json:"casks,omitempty"`
+	Mas      []LockedMas     `json:"mas,omitempty"`
+}
+
+// LockedFormula pins a formula, or one of its dependencies, to a bottle.
+type LockedFormula struct {
+	Name         string   `json:"name"`
+	Version      string   `json:"version"`
+	Revision     int      `json:"revision,omitempty"`
+	URL          string   `json:"url"`
+	SHA256       string   `json:"sha256"`
+	Dependencies []string `json:"dependencies,omitempty"`
+}
+
+// LockedCask pins a cask to one artifact.
+type LockedCask struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	URL     string `json:"url"`
+	SHA256  string `json:"sha256"`
+}
+
+// LockedMas records a Mac App Store app. The store only serves the latest
+// version, so only the ID is locked.
+type LockedMas struct {
+	Name string `json:"name"`
+	ID   int    `json:"id"`
+}
+
+// LockPath returns the lock file path for a Brewfile.
+func LockPath(brewfilePath string) string {
+	return filepath.Join(filepath.Dir(brewfilePath), LockFileName)
+}
+
+// EntriesDigest hashes the sorted entries of a Brewfile.
+func EntriesDigest(bf *Brewfile) string {
+	var entries []string
+	for _, tap := range bf.GetTaps() {
+		entries = append(entries, "tap:"+tap.User+"/"+tap.Repo)
+	}
+	for _, b := range bf.GetBrews() {
+		entries = append(entries, "brew:"+b.Name)
+	}
+	for _, c := range bf.GetCasks() {
+		entries = append(entries, "cask:"+c.Name)
+	}
+	for _, app := range bf.GetMasApps() {
+		entries = append(entries, "mas:"+strconv.Itoa(app.ID))
+	}
+	sort.Strings(entries)
+
+	h := sha256.New()
+	for _, entry := range entries {
+		h.Write([]byte(entry))
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ReadLockfile loads a lock file written by Write.
+func ReadLockfile(path string) (*Lockfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var lock Lockfile
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if lock.Version != lockfileVersion {
+		return nil, fmt.Errorf("%s has unsupported version %d", path, lock.Version)
+	}
+	return &lock, nil
+}
+
+// Write saves the lock file atomically.
+func (l *Lockfile) Write(path string) error {
+	l.Version = lockfileVersion
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// CheckFresh returns an error if the lock no longer matches the Brewfile or
+// was resolved for a different platform.
+func (l *Lockfile) CheckFresh(bf *Brewfile, platform string) error {
+	if l.Entries != EntriesDigest(bf) {
+		return fmt.Errorf("lock file is stale: Brewfile entries changed since it was written")
+	}
+	if l.Platform != platform {
+		return fmt.Errorf("lock file is stale: resolved for %s, not %s", l.Platform, platform)
+	}
+	return nil
+}
+
+// Cask returns the locked cask with the given name.
+func (l *Lockfile) Cask(name string) (LockedCask, bool) {
+	for _, c := range l.Casks {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return LockedCask{}, false
+}
@@ -0,0 +1,70 @@
+package bundle
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestEntriesDigestIgnoresCommentsAndOrder(t *testing.T) {
+	a := &Brewfile{Nodes: []Node{
+		&BrewCommand{Name: "wget"},
+		&CaskCommand{Name: "iterm2"},
+	}}
+	b := &Brewfile{Nodes: []Node{
+		&WhitespaceCommand{Content: "# tools"},
+		&CaskCommand{Name: "iterm2"},
+		&WhitespaceCommand{},
+		&BrewCommand{Name: "wget"},
+	}}
+	c := &Brewfile{Nodes: []Node{
+		&BrewCommand{Name: "wget"},
+		&BrewCommand{Name: "jq"},
+		&CaskCommand{Name: "iterm2"},
+	}}
+
+	if EntriesDigest(a) != EntriesDigest(b) {
+		t.Error("comments and ordering should not change the digest")
+	}
+	if EntriesDigest(a) == EntriesDigest(c) {
+		t.Error("adding an entry should change the digest")
+	}
+}
+
+func TestLockfileRoundTripAndFreshness(t *testing.T) {
+	bf := &Brewfile{Nodes: []Node{&BrewCommand{Name: "wget"}}}
+	path := LockPath(filepath.Join(t.TempDir(), "Brewfile"))
+
+	lock := &Lockfile{
+		Entries:  EntriesDigest(bf),
+		Platform: "arm64_sonoma",
+		Brews: []LockedFormula{{
+			Name:         "wget",
+			Version:      "1.24.5",
+			URL:          "https://example.com/wget.tar.gz",
+			SHA256:       "abc",
+			Dependencies: []string{"openssl@3"},
+		}},
+	}
+	if err := lock.Write(path); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := ReadLockfile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read.Brews) != 1 || read.Brews[0].SHA256 != "abc" || read.Brews[0].Dependencies[0] != "openssl@3" {
+		t.Fatalf("round trip lost data: %+v", read)
+	}
+
+	if err := read.CheckFresh(bf, "arm64_sonoma"); err != nil {
+		t.Errorf("lock should be fresh: %v", err)
+	}
+	if err := read.CheckFresh(bf, "x86_64_linux"); err == nil {
+		t.Error("lock for another platform should be stale")
+	}
+	changed := &Brewfile{Nodes: []Node{&BrewCommand{Name: "wget"}, &BrewCommand{Name: "jq"}}}
+	if err := read.CheckFresh(changed, "arm64_sonoma"); err == nil {
+		t.Error("lock should be stale after adding an entry")
+	}
+}
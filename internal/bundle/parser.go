@@ -3,7 +3,10 @@ package bundle
 import (
 	"fmt"
 	"io"
+	"os"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
 type Parser interface {
@@ -74,13 +77,475 @@ type rubyParser struct {
 }
 
 func (p *rubyParser) Parse(r io.Reader) (*Brewfile, error) {
-	return nil, fmt.Errorf("parser implementation not yet available")
+	limited := r
+	if p.options.MaxFileSize > 0 {
+		limited = io.LimitReader(r, p.options.MaxFileSize+1)
+	}
+	data, err := io.ReadAll(limited)
+	if err != nil {
+		return nil, &ParserError{Message: err.Error(), Type: IoError}
+	}
+	if p.options.MaxFileSize > 0 && int64(len(data)) > p.options.MaxFileSize {
+		return nil, &ParserError{Message: fmt.Sprintf("Brewfile exceeds %d bytes", p.options.MaxFileSize), Type: IoError}
+	}
+	return p.parse(string(data))
 }
 
 func (p *rubyParser) ParseFile(path string) (*Brewfile, error) {
-	return nil, fmt.Errorf("parser implementation not yet available")
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, &ParserError{Message: err.Error(), Type: IoError}
+	}
+	defer f.Close()
+
+	bf, err := p.Parse(f)
+	if err != nil {
+		return nil, err
+	}
+	bf.Path = path
+	return bf, nil
 }
 
 func (p *rubyParser) ParseString(content string) (*Brewfile, error) {
 	return p.Parse(io.NopCloser(strings.NewReader(content)))
 }
+
+// parse splits the content into statements and records the original text
+// of each one so the Brewfile can be written back unchanged.
+func (p *rubyParser) parse(content string) (*Brewfile, error) {
+	bf := &Brewfile{source: make(map[Node]*sourceText)}
+
+	offset := 0
+	line := 1
+	for offset < len(content) {
+		stmt := nextStatement(content[offset:])
+		pos := Position{Line: line, Column: len(stmt.indent) + 1, Offset: offset}
+
+		var node Node
+		if stmt.code == "" {
+			if p.options.PreserveComments {
+				node = &WhitespaceCommand{Pos: pos, Content: strings.TrimSpace(stmt.comment)}
+			}
+		} else {
+			var err error
+			node, err = p.parseCommand(stmt.code, pos)
+			if err != nil {
+				if !IsUnsupportedCommand(err) || !p.options.AllowUnknownCommands {
+					return nil, err
+				}
+				// Keep unknown commands verbatim so writing the file back
+				// does not drop them.
+				node = &WhitespaceCommand{Pos: pos, Content: strings.TrimSpace(stmt.code + stmt.comment)}
+			}
+		}
+
+		if node != nil {
+			bf.Nodes = append(bf.Nodes, node)
+			bf.source[node] = &sourceText{
+				raw:       stmt.raw,
+				formatted: formatNode(node),
+				indent:    stmt.indent,
+				comment:   stmt.comment,
+				eol:       stmt.eol,
+			}
+		}
+
+		offset += len(stmt.raw)
+		line += strings.Count(stmt.raw, "\n")
+	}
+
+	return bf, nil
+}
+
+// statement is one logical Brewfile line. Entries continue onto the next
+// line while a bracket is open or the line ends with a comma.
+type statement struct {
+	raw     string // original text including the line ending
+	indent  string
+	code    string // text without indentation and trailing comment
+	comment string // trailing comment including the whitespace before it
+	eol     string
+}
+
+func nextStatement(s string) statement {
+	var stmt statement
+	depth := 0
+	rest := s
+	for {
+		lineEnd := strings.IndexByte(rest, '\n')
+		text, eol := rest, ""
+		if lineEnd >= 0 {
+			text, eol = rest[:lineEnd], "\n"
+		}
+		if strings.HasSuffix(text, "\r") {
+			text, eol = text[:len(text)-1], "\r"+eol
+		}
+
+		code, comment := splitComment(text)
+		depth += bracketDepth(code)
+
+		if stmt.raw == "" {
+			stmt.indent = code[:len(code)-len(strings.TrimLeft(code, " \t"))]
+			code = code[len(stmt.indent):]
+		} else {
+			code = " " + strings.TrimSpace(code)
+		}
+		trimmed := strings.TrimRight(code, " \t")
+		stmt.code += trimmed
+		stmt.comment = code[len(trimmed):] + comment
+		stmt.raw += text + eol
+		stmt.eol = eol
+		rest = rest[len(text)+len(eol):]
+
+		more := depth > 0 || strings.HasSuffix(trimmed, ",")
+		if !more || rest == "" || stmt.code == "" {
+			return stmt
+		}
+	}
+}
+
+// splitComment separates a trailing "#" comment from code, ignoring "#"
+// inside string literals.
+func splitComment(line string) (code, comment string) {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i], line[i:]
+		}
+	}
+	return line, ""
+}
+
+func bracketDepth(code string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(code); i++ {
+		c := code[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{' || c == '(':
+			depth++
+		case c == ']' || c == '}' || c == ')':
+			depth--
+		}
+	}
+	return depth
+}
+
+func (p *rubyParser) parseCommand(code string, pos Position) (Node, error) {
+	lx := &lexer{src: code, pos: pos}
+	name := lx.ident()
+	if name == "" {
+		return nil, lx.errorf(SyntaxError, "expected a command")
+	}
+
+	var positional []interface{}
+	named := make(map[string]interface{})
+	lx.skipSpace()
+	if !lx.done() {
+		for {
+			key, value, err := lx.arg()
+			if err != nil {
+				return nil, err
+			}
+			if key != "" {
+				named[key] = value
+			} else if len(named) > 0 {
+				return nil, lx.errorf(SyntaxError, "positional argument after keyword arguments")
+			} else {
+				positional = append(positional, value)
+			}
+			lx.skipSpace()
+			if lx.done() {
+				break
+			}
+			if !lx.consume(',') {
+				return nil, lx.errorf(SyntaxError, "expected ','")
+			}
+		}
+	}
+
+	switch name {
+	case "brew", "cask":
+		pkg, err := stringArg(lx, name, positional, 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(positional) > 1 {
+			return nil, lx.errorf(InvalidArgumentError, "%s takes one name", name)
+		}
+		if name == "brew" {
+			return &BrewCommand{Pos: pos, Name: pkg, Args: argsOrNil(named)}, nil
+		}
+		return &CaskCommand{Pos: pos, Name: pkg, Args: argsOrNil(named)}, nil
+
+	case "tap":
+		repo, err := stringArg(lx, name, positional, 0)
+		if err != nil {
+			return nil, err
+		}
+		parts := strings.Split(repo, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, lx.errorf(InvalidArgumentError, "tap %q is not user/repo", repo)
+		}
+		tap := &TapCommand{Pos: pos, User: parts[0], Repo: parts[1]}
+		if len(positional) > 1 {
+			if tap.URL, err = stringArg(lx, name, positional, 1); err != nil {
+				return nil, err
+			}
+		}
+		if force, ok := named["force"]; ok {
+			b, isBool := force.(bool)
+			if !isBool {
+				return nil, lx.errorf(InvalidArgumentError, "tap force must be true or false")
+			}
+			tap.Force = b
+			delete(named, "force")
+		}
+		tap.Custom = argsOrNil(named)
+		return tap, nil
+
+	case "mas":
+		app, err := stringArg(lx, name, positional, 0)
+		if err != nil {
+			return nil, err
+		}
+		id, ok := named["id"].(int)
+		if !ok {
+			return nil, lx.errorf(InvalidArgumentError, "mas %q needs a numeric id", app)
+		}
+		delete(named, "id")
+		return &MasCommand{Pos: pos, Name: app, ID: id, Args: argsOrNil(named)}, nil
+	}
+
+	return nil, &ParserError{Pos: pos, Message: fmt.Sprintf("unknown command %q", name), Type: UnsupportedCommandError}
+}
+
+func stringArg(lx *lexer, command string, positional []interface{}, i int) (string, error) {
+	if i >= len(positional) {
+		return "", lx.errorf(InvalidArgumentError, "%s needs a name", command)
+	}
+	s, ok := positional[i].(string)
+	if !ok || s == "" {
+		return "", lx.errorf(InvalidArgumentError, "%s argument %d must be a string", command, i+1)
+	}
+	return s, nil
+}
+
+func argsOrNil(args map[string]interface{}) map[string]interface{} {
+	if len(args) == 0 {
+		return nil
+	}
+	return args
+}
+
+// lexer reads the Ruby literals used in Brewfiles: strings, symbols,
+// integers, booleans, nil, arrays and hashes.
+type lexer struct {
+	src string
+	i   int
+	pos Position
+}
+
+func (lx *lexer) errorf(typ ErrorType, format string, args ...interface{}) error {
+	pos := lx.pos
+	pos.Column += lx.i
+	return &ParserError{Pos: pos, Message: fmt.Sprintf(format, args...), Type: typ}
+}
+
+func (lx *lexer) done() bool { return lx.i >= len(lx.src) }
+
+func (lx *lexer) peek() byte {
+	if lx.done() {
+		return 0
+	}
+	return lx.src[lx.i]
+}
+
+func (lx *lexer) skipSpace() {
+	for !lx.done() && (lx.src[lx.i] == ' ' || lx.src[lx.i] == '\t') {
+		lx.i++
+	}
+}
+
+func (lx *lexer) consume(c byte) bool {
+	lx.skipSpace()
+	if lx.peek() == c {
+		lx.i++
+		return true
+	}
+	return false
+}
+
+func (lx *lexer) ident() string {
+	start := lx.i
+	for !lx.done() {
+		c := lx.src[lx.i]
+		if c == '_' || c == '?' || c == '!' || unicode.IsLetter(rune(c)) || (lx.i > start && unicode.IsDigit(rune(c))) {
+			lx.i++
+			continue
+		}
+		break
+	}
+	return lx.src[start:lx.i]
+}
+
+// arg reads a positional value or a "key: value", ":key => value" or
+// "\"key\" => value" pair.
+func (lx *lexer) arg() (string, interface{}, error) {
+	lx.skipSpace()
+	start := lx.i
+
+	if key := lx.ident(); key != "" && lx.peek() == ':' && !strings.HasPrefix(lx.src[lx.i:], "::") {
+		lx.i++
+		value, err := lx.value()
+		return key, value, err
+	}
+	lx.i = start
+
+	value, err := lx.value()
+	if err != nil {
+		return "", nil, err
+	}
+	lx.skipSpace()
+	if strings.HasPrefix(lx.src[lx.i:], "=>") {
+		key, ok := value.(string)
+		if !ok {
+			return "", nil, lx.errorf(SyntaxError, "hash keys must be strings or symbols")
+		}
+		lx.i += 2
+		value, err = lx.value()
+		return key, value, err
+	}
+	return "", value, nil
+}
+
+func (lx *lexer) value() (interface{}, error) {
+	lx.skipSpace()
+	switch c := lx.peek(); {
+	case c == '"' || c == '\'':
+		return lx.str()
+	case c == ':':
+		lx.i++
+		if lx.peek() == '"' {
+			return lx.str()
+		}
+		sym := lx.ident()
+		if sym == "" {
+			return nil, lx.errorf(SyntaxError, "invalid symbol")
+		}
+		return sym, nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := lx.i
+		lx.i++
+		for !lx.done() && (unicode.IsDigit(rune(lx.src[lx.i])) || lx.src[lx.i] == '_') {
+			lx.i++
+		}
+		n, err := strconv.Atoi(strings.ReplaceAll(lx.src[start:lx.i], "_", ""))
+		if err != nil {
+			return nil, lx.errorf(SyntaxError, "invalid number %q", lx.src[start:lx.i])
+		}
+		return n, nil
+	case c == '[':
+		lx.i++
+		var list []interface{}
+		for !lx.consume(']') {
+			if len(list) > 0 && !lx.consume(',') {
+				return nil, lx.errorf(SyntaxError, "expected ',' or ']'")
+			}
+			if lx.consume(']') {
+				break
+			}
+			v, err := lx.value()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		if list == nil {
+			list = []interface{}{}
+		}
+		return list, nil
+	case c == '{':
+		lx.i++
+		hash := make(map[string]interface{})
+		for !lx.consume('}') {
+			if len(hash) > 0 && !lx.consume(',') {
+				return nil, lx.errorf(SyntaxError, "expected ',' or '}'")
+			}
+			if lx.consume('}') {
+				break
+			}
+			key, v, err := lx.arg()
+			if err != nil {
+				return nil, err
+			}
+			if key == "" {
+				return nil, lx.errorf(SyntaxError, "expected key: value")
+			}
+			hash[key] = v
+		}
+		return hash, nil
+	}
+
+	switch word := lx.ident(); word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "nil":
+		return nil, nil
+	case "":
+		return nil, lx.errorf(SyntaxError, "unexpected %q", string(lx.peek()))
+	default:
+		return nil, lx.errorf(SyntaxError, "unsupported expression %q", word)
+	}
+}
+
+func (lx *lexer) str() (string, error) {
+	quote := lx.src[lx.i]
+	lx.i++
+	var b strings.Builder
+	for !lx.done() {
+		c := lx.src[lx.i]
+		lx.i++
+		switch {
+		case c == quote:
+			return b.String(), nil
+		case c == '\\' && !lx.done():
+			next := lx.src[lx.i]
+			lx.i++
+			switch {
+			case quote == '"' && next == 'n':
+				b.WriteByte('\n')
+			case quote == '"' && next == 't':
+				b.WriteByte('\t')
+			case next == quote || next == '\\':
+				b.WriteByte(next)
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(next)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", lx.errorf(SyntaxError, "unterminated string")
+}
@@ -0,0 +1,74 @@
+package bundle
+
+import (
+	"testing"
+)
+
+func TestParseCommands(t *testing.T) {
+	content := `# Taps
+tap "homebrew/bundle"
+tap "acme/tools", "https://example.com/tools.git", force: true
+
+brew "wget"
+brew 'git', args: ["with-pcre2"], restart_service: :changed # vcs
+brew "postgresql@16",
+  link: true,
+  conflicts_with: ["postgresql"]
+cask "firefox", args: { appdir: "~/Applications" }
+mas "Xcode", id: 497_799_835
+`
+	bf, err := SimpleParser().ParseString(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	taps := bf.GetTaps()
+	if len(taps) != 2 || taps[1].User != "acme" || taps[1].URL != "https://example.com/tools.git" || !taps[1].Force {
+		t.Errorf("unexpected taps: %+v", taps)
+	}
+
+	brews := bf.GetBrews()
+	if len(brews) != 3 {
+		t.Fatalf("expected 3 brews, got %+v", brews)
+	}
+	if brews[1].Name != "git" || brews[1].Args["restart_service"] != "changed" {
+		t.Errorf("unexpected git entry: %+v", brews[1])
+	}
+	if brews[2].Pos.Line != 7 || brews[2].Args["link"] != true {
+		t.Errorf("unexpected multi-line entry: %+v", brews[2])
+	}
+
+	casks := bf.GetCasks()
+	if appdir := casks[0].Args["args"].(map[string]interface{})["appdir"]; appdir != "~/Applications" {
+		t.Errorf("appdir = %v", appdir)
+	}
+
+	apps := bf.GetMasApps()
+	if len(apps) != 1 || apps[0].ID != 497799835 {
+		t.Errorf("unexpected mas apps: %+v", apps)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		content string
+		check   func(error) bool
+	}{
+		{`vscode "golang.go"`, IsUnsupportedCommand},
+		{`brew "wget`, IsSyntaxError},
+		{`brew wget`, IsSyntaxError},
+	}
+	for _, tt := range tests {
+		_, err := SimpleParser().ParseString(tt.content)
+		if err == nil || !tt.check(err) {
+			t.Errorf("ParseString(%q) error = %v", tt.content, err)
+		}
+	}
+
+	opts := DefaultParserOptions()
+	opts.AllowUnknownCommands = true
+	bf, err := NewParser(opts).ParseString("vscode \"golang.go\"\nbrew \"wget\"\n")
+	if err != nil || len(bf.GetBrews()) != 1 {
+		t.Errorf("unknown commands should be kept when allowed: %v", err)
+	}
+}
@@ -0,0 +1,211 @@
+package bundle
+
+import (
+	"fastbrew/internal/atomicfile"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sourceText is the original text of a parsed node.
+type sourceText struct {
+	raw       string // exact bytes, including the line ending
+	formatted string // formatNode output when parsed
+	indent    string
+	comment   string // trailing comment, with the whitespace before it
+	eol       string
+}
+
+// Write writes the Brewfile back out. Nodes that are unchanged since
+// parsing are written byte for byte, so comments, blank lines, ordering and
+// formatting survive; changed nodes are rewritten on one line, keeping
+// their indentation and trailing comment, and added nodes use the
+// canonical format.
+func (b *Brewfile) Write(w io.Writer) error {
+	var out strings.Builder
+	for _, node := range b.Nodes {
+		if out.Len() > 0 && !strings.HasSuffix(out.String(), "\n") {
+			out.WriteString("\n")
+		}
+
+		formatted := formatNode(node)
+		src := b.source[node]
+		switch {
+		case src == nil:
+			out.WriteString(formatted + "\n")
+		case src.formatted == formatted:
+			out.WriteString(src.raw)
+		default:
+			out.WriteString(src.indent + formatted + src.comment + src.eol)
+		}
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// WriteFile atomically replaces path with the Brewfile's contents,
+// keeping the file's permissions.
+func (b *Brewfile) WriteFile(path string) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	return atomicfile.Write(path, mode, b.Write)
+}
+
+// Add inserts an entry after the last entry of the same type, or at the
+// end of the file if there is none. It returns false if the entry is
+// already present.
+func (b *Brewfile) Add(node Node) bool {
+	key := entryKey(node)
+	if key == "" || b.find(key) >= 0 {
+		return false
+	}
+
+	at := len(b.Nodes)
+	for i := len(b.Nodes) - 1; i >= 0; i-- {
+		if b.Nodes[i].Type() == node.Type() {
+			at = i + 1
+			break
+		}
+	}
+	b.Nodes = append(b.Nodes, nil)
+	copy(b.Nodes[at+1:], b.Nodes[at:])
+	b.Nodes[at] = node
+	return true
+}
+
+// Update replaces the entry with the same identity (type and name, or ID
+// for mas apps) in place. It returns false if there is no such entry.
+func (b *Brewfile) Update(node Node) bool {
+	i := b.find(entryKey(node))
+	if i < 0 {
+		return false
+	}
+	old := b.Nodes[i]
+	b.Nodes[i] = node
+	if src, ok := b.source[old]; ok {
+		delete(b.source, old)
+		b.source[node] = src
+	}
+	return true
+}
+
+// Remove deletes the entry with the same identity as node. Comments above
+// it are kept. It returns false if there is no such entry.
+func (b *Brewfile) Remove(node Node) bool {
+	i := b.find(entryKey(node))
+	if i < 0 {
+		return false
+	}
+	delete(b.source, b.Nodes[i])
+	b.Nodes = append(b.Nodes[:i], b.Nodes[i+1:]...)
+	return true
+}
+
+func (b *Brewfile) find(key string) int {
+	if key == "" {
+		return -1
+	}
+	for i, node := range b.Nodes {
+		if entryKey(node) == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// entryKey identifies an entry regardless of its arguments. Whitespace
+// nodes have no identity.
+func entryKey(node Node) string {
+	switch n := node.(type) {
+	case *BrewCommand:
+		return "brew:" + n.Name
+	case *CaskCommand:
+		return "cask:" + n.Name
+	case *TapCommand:
+		return "tap:" + strings.ToLower(n.User+"/"+n.Repo)
+	case *MasCommand:
+		return "mas:" + strconv.Itoa(n.ID)
+	}
+	return ""
+}
+
+// formatNode renders a node as a single canonical Brewfile line without
+// the line ending.
+func formatNode(node Node) string {
+	switch n := node.(type) {
+	case *BrewCommand:
+		return "brew " + formatValue(n.Name) + formatArgs(n.Args)
+	case *CaskCommand:
+		return "cask " + formatValue(n.Name) + formatArgs(n.Args)
+	case *TapCommand:
+		line := "tap " + formatValue(n.User+"/"+n.Repo)
+		if n.URL != "" {
+			line += ", " + formatValue(n.URL)
+		}
+		if n.Force {
+			line += ", force: true"
+		}
+		return line + formatArgs(n.Custom)
+	case *MasCommand:
+		return "mas " + formatValue(n.Name) + ", id: " + strconv.Itoa(n.ID) + formatArgs(n.Args)
+	case *WhitespaceCommand:
+		return n.Content
+	}
+	return ""
+}
+
+func formatArgs(args map[string]interface{}) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return ", " + formatPairs(args)
+}
+
+func formatPairs(hash map[string]interface{}) string {
+	keys := make([]string, 0, len(hash))
+	for key := range hash {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + ": " + formatValue(hash[key])
+	}
+	return strings.Join(pairs, ", ")
+}
+
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case string:
+		r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+		return `"` + r.Replace(v) + `"`
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = formatValue(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case []string:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = formatValue(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case map[string]interface{}:
+		return "{" + formatPairs(v) + "}"
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,84 @@
+package bundle
+
+import (
+	"strings"
+	"testing"
+)
+
+const editedBrewfile = `# My tools
+tap "acme/tools"
+
+brew "wget"   # downloads
+  brew "jq",args:["HEAD"]
+brew "postgresql@16",
+  link: true
+
+# Apps
+cask "firefox"
+mas "Xcode", id: 497799835`
+
+func writeString(t *testing.T, bf *Brewfile) string {
+	t.Helper()
+	var out strings.Builder
+	if err := bf.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	for _, content := range []string{
+		editedBrewfile,
+		editedBrewfile + "\n",
+		strings.ReplaceAll(editedBrewfile, "\n", "\r\n") + "\r\n",
+		"\n\n   \n",
+	} {
+		bf, err := SimpleParser().ParseString(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := writeString(t, bf); got != content {
+			t.Errorf("round trip mismatch:\n got %q\nwant %q", got, content)
+		}
+	}
+}
+
+func TestWriteEdits(t *testing.T) {
+	bf, err := SimpleParser().ParseString(editedBrewfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bf.Add(&BrewCommand{Name: "ripgrep"}) {
+		t.Fatal("Add returned false")
+	}
+	if bf.Add(&BrewCommand{Name: "wget"}) {
+		t.Error("Add should not duplicate an entry")
+	}
+	if !bf.Update(&BrewCommand{Name: "wget", Args: map[string]interface{}{"link": false}}) {
+		t.Fatal("Update returned false")
+	}
+	if !bf.Remove(&CaskCommand{Name: "firefox"}) {
+		t.Fatal("Remove returned false")
+	}
+	if !bf.Add(&MasCommand{Name: "Keynote", ID: 409183694}) {
+		t.Fatal("Add returned false")
+	}
+
+	want := `# My tools
+tap "acme/tools"
+
+brew "wget", link: false   # downloads
+  brew "jq",args:["HEAD"]
+brew "postgresql@16",
+  link: true
+brew "ripgrep"
+
+# Apps
+mas "Xcode", id: 497799835
+mas "Keynote", id: 409183694
+`
+	if got := writeString(t, bf); got != want {
+		t.Errorf("edited Brewfile mismatch:\n got %q\nwant %q", got, want)
+	}
+}
@@ -0,0 +1,119 @@
+// Package cmdrunner runs external commands behind an interface, so code
+// that shells out to brew, git, launchctl and the like can be tested with
+// canned output instead of the real tools.
+package cmdrunner
+
+import (
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// CommandRunner runs a command and returns its standard output. A command
+// that exits non-zero returns an *exec.ExitError holding its stderr.
+type CommandRunner interface {
+	Run(name string, arg ...string) ([]byte, error)
+	RunWithStdin(name string, stdin io.Reader, arg ...string) ([]byte, error)
+	RunWithOptions(opts Options, name string, arg ...string) ([]byte, error)
+}
+
+// Options change how RunWithOptions runs a command.
+type Options struct {
+	// Env is the command's environment, the current one when nil.
+	Env []string
+	// Stdout and Stderr receive the command's output as it is written,
+	// for commands that report progress. Output sent to Stdout is not
+	// returned.
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// DefaultCommandRunner runs commands with os/exec.
+type DefaultCommandRunner struct{}
+
+func (d *DefaultCommandRunner) Run(name string, arg ...string) ([]byte, error) {
+	cmd := exec.Command(name, arg...)
+	return cmd.Output()
+}
+
+func (d *DefaultCommandRunner) RunWithStdin(name string, stdin io.Reader, arg ...string) ([]byte, error) {
+	cmd := exec.Command(name, arg...)
+	cmd.Stdin = stdin
+	return cmd.Output()
+}
+
+func (d *DefaultCommandRunner) RunWithOptions(opts Options, name string, arg ...string) ([]byte, error) {
+	cmd := exec.Command(name, arg...)
+	cmd.Env = opts.Env
+	cmd.Stderr = opts.Stderr
+	if opts.Stdout == nil {
+		return cmd.Output()
+	}
+	cmd.Stdout = opts.Stdout
+	return nil, cmd.Run()
+}
+
+// Mock is a CommandRunner for tests. Commands are keyed by name and
+// arguments joined with spaces, e.g. "brew list --formula --versions";
+// ones without a canned output or error succeed with no output.
+type Mock struct {
+	mu      sync.Mutex
+	outputs map[string][]byte
+	errors  map[string]error
+	calls   []string
+}
+
+func NewMock() *Mock {
+	return &Mock{
+		outputs: make(map[string][]byte),
+		errors:  make(map[string]error),
+	}
+}
+
+// SetOutput makes command print output.
+func (m *Mock) SetOutput(command string, output []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.outputs[command] = output
+}
+
+// SetError makes command fail with err.
+func (m *Mock) SetError(command string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errors[command] = err
+}
+
+// Calls returns the commands run so far, in order.
+func (m *Mock) Calls() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.calls...)
+}
+
+func (m *Mock) Run(name string, arg ...string) ([]byte, error) {
+	key := strings.Join(append([]string{name}, arg...), " ")
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, key)
+	if err, ok := m.errors[key]; ok {
+		return m.outputs[key], err
+	}
+	return m.outputs[key], nil
+}
+
+func (m *Mock) RunWithStdin(name string, stdin io.Reader, arg ...string) ([]byte, error) {
+	return m.Run(name, arg...)
+}
+
+// RunWithOptions runs the command as Run does, writing its canned output
+// to opts.Stdout when set.
+func (m *Mock) RunWithOptions(opts Options, name string, arg ...string) ([]byte, error) {
+	out, err := m.Run(name, arg...)
+	if opts.Stdout != nil {
+		opts.Stdout.Write(out)
+		return nil, err
+	}
+	return out, err
+}
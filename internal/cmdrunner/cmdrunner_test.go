@@ -0,0 +1,38 @@
+package cmdrunner
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDefaultCommandRunner(t *testing.T) {
+	runner := &DefaultCommandRunner{}
+	out, err := runner.RunWithStdin("cat", strings.NewReader("hello"))
+	if err != nil || string(out) != "hello" {
+		t.Fatalf("RunWithStdin(cat) = %q, %v", out, err)
+	}
+	if _, err := runner.Run("fastbrew-no-such-command"); err == nil {
+		t.Error("expected an error for a missing command")
+	}
+}
+
+func TestMock(t *testing.T) {
+	mock := NewMock()
+	mock.SetOutput("brew tap", []byte("homebrew/core\n"))
+	mock.SetError("mas list", errors.New("not signed in"))
+
+	if out, err := mock.Run("brew", "tap"); err != nil || string(out) != "homebrew/core\n" {
+		t.Errorf("Run(brew tap) = %q, %v", out, err)
+	}
+	if _, err := mock.Run("mas", "list"); err == nil {
+		t.Error("expected the canned error")
+	}
+	if out, err := mock.Run("git", "status"); err != nil || len(out) != 0 {
+		t.Errorf("unknown command = %q, %v; want no output", out, err)
+	}
+	if got, want := mock.Calls(), []string{"brew tap", "mas list", "git status"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Calls() = %v, want %v", got, want)
+	}
+}
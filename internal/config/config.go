@@ -2,8 +2,15 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
+	"fastbrew/internal/atomicfile"
+	"fastbrew/internal/paths"
+	"fastbrew/internal/prompt"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -16,12 +23,48 @@ type DaemonConfig struct {
 	Prewarm     bool   `json:"prewarm"`
 }
 
+// NetworkConfig configures the proxy and TLS trust used for all downloads.
+type NetworkConfig struct {
+	Proxy              string `json:"proxy,omitempty"`
+	NoProxy            string `json:"no_proxy,omitempty"`
+	CABundle           string `json:"ca_bundle,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
+}
+
+// HTTPConfig holds the timeouts for network requests, as durations such
+// as "30s".
+type HTTPConfig struct {
+	ConnectTimeout        string `json:"connect_timeout"`
+	ResponseHeaderTimeout string `json:"response_header_timeout"`
+	Timeout               string `json:"timeout"`
+}
+
 type Config struct {
-	ParallelDownloads int          `json:"parallel_downloads"`
-	ShowProgress      bool         `json:"show_progress"`
-	AutoCleanup       bool         `json:"auto_cleanup"`
-	Verbose           bool         `json:"verbose"`
-	Daemon            DaemonConfig `json:"daemon"`
+	ParallelDownloads  int               `json:"parallel_downloads"`
+	MaxConnsPerHost    int               `json:"max_connections_per_host"`
+	MaxBandwidth       string            `json:"max_bandwidth"`
+	ShowProgress       bool              `json:"show_progress"`
+	AutoCleanup        bool              `json:"auto_cleanup"`
+	CleanupMaxAgeDays  int               `json:"cleanup_max_age_days"`
+	MaxCacheSize       string            `json:"max_cache_size"`
+	CacheDir           string            `json:"cache_dir,omitempty"`
+	Prefix             string            `json:"prefix,omitempty"`
+	Color              bool              `json:"color"`
+	Emoji              bool              `json:"emoji"`
+	RetryAttempts      int               `json:"retry_attempts"`
+	IndexSnapshots     int               `json:"index_snapshots,omitempty"`
+	ExtractWorkers     int               `json:"extract_workers"`
+	ExtractCache       bool              `json:"extract_cache"`
+	Verbose            bool              `json:"verbose"`
+	VerifyAttestations bool              `json:"verify_attestations"`
+	Confirm            string            `json:"confirm,omitempty"`
+	Mirrors            map[string]string `json:"mirrors,omitempty"`
+	Credentials        map[string]string `json:"credentials,omitempty"`
+	Constraints        map[string]string `json:"constraints,omitempty"`
+	Hooks              map[string]string `json:"hooks,omitempty"`
+	HTTP               HTTPConfig        `json:"http"`
+	Network            NetworkConfig     `json:"network"`
+	Daemon             DaemonConfig      `json:"daemon"`
 }
 
 var (
@@ -34,7 +77,15 @@ func DefaultConfig() *Config {
 		ParallelDownloads: 10,
 		ShowProgress:      false,
 		AutoCleanup:       false,
+		Color:             true,
+		Emoji:             true,
 		Verbose:           false,
+		ExtractCache:      true,
+		HTTP: HTTPConfig{
+			ConnectTimeout:        "30s",
+			ResponseHeaderTimeout: "30s",
+			Timeout:               "120s",
+		},
 		Daemon: DaemonConfig{
 			Enabled:     false,
 			AutoStart:   true,
@@ -46,30 +97,74 @@ func DefaultConfig() *Config {
 }
 
 func DefaultDaemonSocketPath() string {
-	home, _ := os.UserHomeDir()
-	return filepath.Join(home, ".fastbrew", "run", "daemon.sock")
+	return filepath.Join(paths.RuntimeDir(), "daemon.sock")
+}
+
+// legacyDaemonSocketPath is the default socket of releases that kept
+// everything in ~/.fastbrew. Config files saved by them still name it.
+func legacyDaemonSocketPath() string {
+	return filepath.Join(paths.LegacyDir(), "run", "daemon.sock")
 }
 
 func GetConfigPath() string {
-	home, _ := os.UserHomeDir()
-	return filepath.Join(home, ".fastbrew", "config.json")
+	return filepath.Join(paths.ConfigDir(), "config.json")
 }
 
+// Load returns the effective configuration: the defaults, overridden by
+// the config file, overridden in turn by FASTBREW_* environment variables
+// (see Keys). The result is cached for the life of the process.
 func Load() *Config {
 	cfgOnce.Do(func() {
-		cfg = DefaultConfig()
 		path := GetConfigPath()
 
-		data, err := os.ReadFile(path)
+		loaded, err := LoadFile()
 		if err != nil {
-			return
+			// Start from the defaults rather than a half-parsed file, and
+			// keep the original so settings can be recovered by hand.
+			loaded = DefaultConfig()
+			var corrupt *CorruptError
+			if errors.As(err, &corrupt) {
+				if moved, qErr := atomicfile.Quarantine(path); qErr == nil {
+					fmt.Fprintf(os.Stderr, "Warning: %s is corrupt (%v); using defaults, original saved as %s\n", path, corrupt.Err, moved)
+				}
+			}
 		}
-
-		json.Unmarshal(data, cfg)
+		loaded.applyEnv()
+		cfg = loaded
 	})
 	return cfg
 }
 
+// LoadFile reads the config file over the defaults, without environment
+// overrides. Use it for configuration that will be saved back, so that
+// overrides do not end up in the file. A missing file yields the defaults.
+func LoadFile() (*Config, error) {
+	c := DefaultConfig()
+	data, err := os.ReadFile(GetConfigPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, &CorruptError{Path: GetConfigPath(), Err: err}
+	}
+	return c, nil
+}
+
+// CorruptError reports a config file that is not valid JSON.
+type CorruptError struct {
+	Path string
+	Err  error
+}
+
+func (e *CorruptError) Error() string {
+	return fmt.Sprintf("%s is corrupt: %v", e.Path, e.Err)
+}
+
+func (e *CorruptError) Unwrap() error { return e.Err }
+
 func (c *Config) Save() error {
 	path := GetConfigPath()
 	dir := filepath.Dir(path)
@@ -82,7 +177,8 @@ func (c *Config) Save() error {
 		return err
 	}
 
-	return os.WriteFile(path, data, 0644)
+	// The file may hold credentials, so keep it private.
+	return atomicfile.WriteFile(path, data, 0600)
 }
 
 func Get() *Config {
@@ -99,8 +195,173 @@ func (c *Config) GetParallelDownloads() int {
 	return c.ParallelDownloads
 }
 
+func (c *Config) GetMaxConnsPerHost() int {
+	if c.MaxConnsPerHost < 0 {
+		return 0
+	}
+	return c.MaxConnsPerHost
+}
+
+// GetMaxBandwidth returns the download bandwidth cap in bytes per second,
+// or 0 when unlimited or unparseable.
+func (c *Config) GetMaxBandwidth() int64 {
+	n, err := ParseByteRate(c.MaxBandwidth)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// ParseByteRate parses a bandwidth such as "500K", "2MB" or "1048576" into
+// bytes per second. Units are binary (1K = 1024 bytes). Empty means unlimited.
+func ParseByteRate(value string) (int64, error) {
+	n, err := ParseByteSize(strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(value)), "/S"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid bandwidth %q", value)
+	}
+	return n, nil
+}
+
+// ParseByteSize parses a size such as "500M", "2GB" or "1048576" into
+// bytes. Units are binary (1K = 1024 bytes). Empty means no limit.
+func ParseByteSize(value string) (int64, error) {
+	v := strings.ToUpper(strings.TrimSpace(value))
+	if v == "" || v == "0" {
+		return 0, nil
+	}
+
+	multiplier := float64(1)
+	for _, unit := range []struct {
+		suffix string
+		mult   float64
+	}{
+		{"GB", 1 << 30}, {"G", 1 << 30},
+		{"MB", 1 << 20}, {"M", 1 << 20},
+		{"KB", 1 << 10}, {"K", 1 << 10},
+		{"B", 1},
+	} {
+		if strings.HasSuffix(v, unit.suffix) {
+			v = strings.TrimSpace(strings.TrimSuffix(v, unit.suffix))
+			multiplier = unit.mult
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return int64(n * multiplier), nil
+}
+
+// GetNetwork returns the network settings with FASTBREW_PROXY,
+// FASTBREW_NO_PROXY, FASTBREW_CA_BUNDLE and FASTBREW_INSECURE_SKIP_VERIFY
+// taking precedence over the config file.
+func (c *Config) GetNetwork() NetworkConfig {
+	n := c.Network
+	if v := os.Getenv("FASTBREW_PROXY"); v != "" {
+		n.Proxy = v
+	}
+	if v := os.Getenv("FASTBREW_NO_PROXY"); v != "" {
+		n.NoProxy = v
+	}
+	if v := os.Getenv("FASTBREW_CA_BUNDLE"); v != "" {
+		n.CABundle = v
+	}
+	if v := os.Getenv("FASTBREW_INSECURE_SKIP_VERIFY"); v != "" {
+		n.InsecureSkipVerify, _ = strconv.ParseBool(v)
+	}
+	return n
+}
+
+// GetCleanupMaxAge returns how old cached downloads and superseded versions
+// must be before cleanup removes them, or 0 for no age limit.
+func (c *Config) GetCleanupMaxAge() time.Duration {
+	if c.CleanupMaxAgeDays <= 0 {
+		return 0
+	}
+	return time.Duration(c.CleanupMaxAgeDays) * 24 * time.Hour
+}
+
+// GetConfirmPolicy returns how questions are answered when neither --yes
+// nor --no-input is given.
+func (c *Config) GetConfirmPolicy() prompt.Policy {
+	policy, err := prompt.ParsePolicy(c.Confirm)
+	if err != nil {
+		return prompt.PolicyAsk
+	}
+	return policy
+}
+
+// GetMaxCacheSize returns the size the download cache is pruned down to, in
+// bytes, or 0 for no limit.
+func (c *Config) GetMaxCacheSize() int64 {
+	n, err := ParseByteSize(c.MaxCacheSize)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// GetCacheDir returns the download and index cache directory, or "" for the
+// default (see paths.CacheDir).
+func (c *Config) GetCacheDir() string {
+	return expandHome(c.CacheDir)
+}
+
+// GetPrefix returns the Homebrew prefix to use, or "" to detect it.
+func (c *Config) GetPrefix() string {
+	return expandHome(c.Prefix)
+}
+
+// GetRetryAttempts returns how many times a failed request is tried, or 0
+// for the built-in defaults.
+func (c *Config) GetRetryAttempts() int {
+	if c.RetryAttempts < 0 {
+		return 0
+	}
+	return c.RetryAttempts
+}
+
+// HTTPTimeouts are the parsed http.* settings. Zero means no limit.
+type HTTPTimeouts struct {
+	Connect        time.Duration
+	ResponseHeader time.Duration
+	Request        time.Duration
+}
+
+// GetHTTPTimeouts returns the request timeouts. Unparseable values fall
+// back to the defaults.
+func (c *Config) GetHTTPTimeouts() HTTPTimeouts {
+	defaults := DefaultConfig().HTTP
+	parse := func(v, fallback string) time.Duration {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			d, _ = time.ParseDuration(fallback)
+		}
+		return d
+	}
+	return HTTPTimeouts{
+		Connect:        parse(c.HTTP.ConnectTimeout, defaults.ConnectTimeout),
+		ResponseHeader: parse(c.HTTP.ResponseHeaderTimeout, defaults.ResponseHeaderTimeout),
+		Request:        parse(c.HTTP.Timeout, defaults.Timeout),
+	}
+}
+
+// expandHome replaces a leading ~ with the user's home directory.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
+
 func (c *Config) GetDaemonSocketPath() string {
-	if c.Daemon.SocketPath == "" {
+	if c.Daemon.SocketPath == "" || c.Daemon.SocketPath == legacyDaemonSocketPath() {
 		return DefaultDaemonSocketPath()
 	}
 	return c.Daemon.SocketPath
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fastbrew/internal/paths"
 	"os"
 	"path/filepath"
 	"sync"
@@ -65,7 +66,7 @@ func TestSaveAndLoad(t *testing.T) {
 		t.Fatalf("Save() failed: %v", err)
 	}
 
-	configPath := filepath.Join(tmpDir, ".fastbrew", "config.json")
+	configPath := filepath.Join(paths.ConfigDir(), "config.json")
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		t.Error("Config file was not created")
 	}
@@ -134,8 +135,7 @@ func TestGetReturnsSameInstance(t *testing.T) {
 }
 
 func TestGetConfigPath(t *testing.T) {
-	home, _ := os.UserHomeDir()
-	expected := filepath.Join(home, ".fastbrew", "config.json")
+	expected := filepath.Join(paths.ConfigDir(), "config.json")
 	actual := GetConfigPath()
 
 	if actual != expected {
@@ -149,7 +149,7 @@ func TestLoadWithInvalidJSON(t *testing.T) {
 	os.Setenv("HOME", tmpDir)
 	defer os.Setenv("HOME", originalHome)
 
-	configDir := filepath.Join(tmpDir, ".fastbrew")
+	configDir := paths.ConfigDir()
 	os.MkdirAll(configDir, 0755)
 	configPath := filepath.Join(configDir, "config.json")
 	os.WriteFile(configPath, []byte("invalid json{{{"), 0644)
@@ -160,6 +160,12 @@ func TestLoadWithInvalidJSON(t *testing.T) {
 	if cfg.ParallelDownloads != 10 {
 		t.Errorf("Expected default ParallelDownloads on invalid JSON, got %d", cfg.ParallelDownloads)
 	}
+	if _, err := os.Stat(configPath); !os.IsNotExist(err) {
+		t.Errorf("Expected corrupt config to be moved aside, stat err = %v", err)
+	}
+	if moved, _ := filepath.Glob(configPath + ".corrupt-*"); len(moved) != 1 {
+		t.Errorf("Expected one quarantined config, got %v", moved)
+	}
 }
 
 func TestLoadWithPartialJSON(t *testing.T) {
@@ -168,7 +174,7 @@ func TestLoadWithPartialJSON(t *testing.T) {
 	os.Setenv("HOME", tmpDir)
 	defer os.Setenv("HOME", originalHome)
 
-	configDir := filepath.Join(tmpDir, ".fastbrew")
+	configDir := paths.ConfigDir()
 	os.MkdirAll(configDir, 0755)
 	configPath := filepath.Join(configDir, "config.json")
 	os.WriteFile(configPath, []byte(`{"parallel_downloads": 5}`), 0644)
@@ -207,7 +213,65 @@ func TestGetDaemonHelpers(t *testing.T) {
 	}
 }
 
+func TestParseByteRate(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+		wantErr  bool
+	}{
+		{"", 0, false},
+		{"0", 0, false},
+		{"1048576", 1048576, false},
+		{"500K", 500 * 1024, false},
+		{"2MB", 2 * 1024 * 1024, false},
+		{"1.5m", 1536 * 1024, false},
+		{"1G/s", 1 << 30, false},
+		{"fast", 0, true},
+		{"-1M", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseByteRate(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseByteRate(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("ParseByteRate(%q) = %d, want %d", tt.input, got, tt.expected)
+		}
+	}
+
+	cfg := DefaultConfig()
+	cfg.MaxBandwidth = "garbage"
+	if cfg.GetMaxBandwidth() != 0 {
+		t.Error("Invalid max_bandwidth should fall back to unlimited")
+	}
+}
+
 func resetConfigSingleton() {
 	cfgOnce = sync.Once{}
 	cfg = nil
 }
+
+func TestGetNetworkEnvOverrides(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Network = NetworkConfig{Proxy: "http://file-proxy:8080", CABundle: "/etc/file.pem"}
+
+	t.Setenv("FASTBREW_PROXY", "http://env-proxy:3128")
+	t.Setenv("FASTBREW_NO_PROXY", "localhost")
+	t.Setenv("FASTBREW_INSECURE_SKIP_VERIFY", "true")
+
+	n := cfg.GetNetwork()
+	if n.Proxy != "http://env-proxy:3128" {
+		t.Errorf("Proxy = %q, want env override", n.Proxy)
+	}
+	if n.NoProxy != "localhost" {
+		t.Errorf("NoProxy = %q, want localhost", n.NoProxy)
+	}
+	if n.CABundle != "/etc/file.pem" {
+		t.Errorf("CABundle = %q, want config file value", n.CABundle)
+	}
+	if !n.InsecureSkipVerify {
+		t.Error("Expected InsecureSkipVerify=true from env")
+	}
+}
@@ -0,0 +1,479 @@
+package config
+
+import (
+	"fastbrew/internal/prompt"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Key is a setting that can be read and changed with `fastbrew config`.
+type Key struct {
+	Name string
+	// Env is the environment variable that overrides the key, if any.
+	Env  string
+	Help string
+
+	get func(c *Config) string
+	set func(c *Config, value string) error
+}
+
+// Map-valued settings are addressed as prefix + host.
+const (
+	MirrorKeyPrefix     = "mirrors."
+	CredentialKeyPrefix = "credentials."
+	// ConstraintKeyPrefix is followed by a package name rather than a host.
+	ConstraintKeyPrefix = "constraints."
+	// HookKeyPrefix is followed by a hook event, optionally with ".<package>".
+	HookKeyPrefix = "hooks."
+)
+
+var keys = []Key{
+	intKey("parallel_downloads", "Number of bottles downloaded at once", 1,
+		func(c *Config) *int { return &c.ParallelDownloads }),
+	intKey("max_connections_per_host", "Connections per host, 0 to adapt to measured throughput", 0,
+		func(c *Config) *int { return &c.MaxConnsPerHost }),
+	{
+		Name: "max_bandwidth",
+		Help: "Download bandwidth cap such as 500K or 2M, 0 for unlimited",
+		get:  func(c *Config) string { return c.MaxBandwidth },
+		set: func(c *Config, v string) error {
+			if _, err := ParseByteRate(v); err != nil {
+				return fmt.Errorf("%v (examples: 500K, 2M, 0 for unlimited)", err)
+			}
+			c.MaxBandwidth = v
+			return nil
+		},
+	},
+	boolKey("show_progress", "Show the progress dashboard for install and upgrade",
+		func(c *Config) *bool { return &c.ShowProgress }),
+	boolKey("auto_cleanup", "Reserved for automatic cleanup; currently unused",
+		func(c *Config) *bool { return &c.AutoCleanup }),
+	intKey("cleanup_max_age_days", "Age after which cleanup removes cached downloads, 0 for no limit", 0,
+		func(c *Config) *int { return &c.CleanupMaxAgeDays }),
+	{
+		Name: "max_cache_size",
+		Help: "Size cache prune and cleanup trim downloads to, such as 5G, 0 for unlimited",
+		get:  func(c *Config) string { return c.MaxCacheSize },
+		set: func(c *Config, v string) error {
+			if _, err := ParseByteSize(v); err != nil {
+				return fmt.Errorf("%v (examples: 500M, 5G, 0 for unlimited)", err)
+			}
+			c.MaxCacheSize = v
+			return nil
+		},
+	},
+	pathKey("cache_dir", "Directory for downloads and the package index, default ~/.cache/fastbrew",
+		func(c *Config) *string { return &c.CacheDir }),
+	pathKey("prefix", "Homebrew prefix to manage, instead of detecting it",
+		func(c *Config) *string { return &c.Prefix }),
+	boolKey("color", "Use colors in the interactive screens and dashboards",
+		func(c *Config) *bool { return &c.Color }),
+	boolKey("emoji", "Decorate command output with emoji",
+		func(c *Config) *bool { return &c.Emoji }),
+	intKey("retry_attempts", "Attempts for a failed API request or download, 0 for the defaults", 0,
+		func(c *Config) *int { return &c.RetryAttempts }),
+	intKey("index_snapshots", "Previous package indexes kept for update --rollback, 0 for the default (3)", 0,
+		func(c *Config) *int { return &c.IndexSnapshots }),
+	intKey("extract_workers", "Workers decompressing and writing each bottle, 0 for one per CPU (up to 8)", 0,
+		func(c *Config) *int { return &c.ExtractWorkers }),
+	boolKey("extract_cache", "Keep unpacked bottles in the cache and clone them into the Cellar on reinstall",
+		func(c *Config) *bool { return &c.ExtractCache }),
+	boolKey("verbose", "Print more detail",
+		func(c *Config) *bool { return &c.Verbose }),
+	boolKey("verify_attestations", "Require a build provenance attestation for every bottle",
+		func(c *Config) *bool { return &c.VerifyAttestations }),
+	{
+		Name: "confirm",
+		Help: "How questions are answered: ask, yes (as --yes) or never (as --no-input)",
+		get:  func(c *Config) string { return string(c.GetConfirmPolicy()) },
+		set: func(c *Config, v string) error {
+			policy, err := prompt.ParsePolicy(v)
+			if err != nil {
+				return err
+			}
+			c.Confirm = string(policy)
+			return nil
+		},
+	},
+	durationKey("http.connect_timeout", "How long to wait for a connection, 0 for no limit",
+		func(c *Config) *string { return &c.HTTP.ConnectTimeout }),
+	durationKey("http.response_header_timeout", "How long to wait for a server to start responding, 0 for no limit",
+		func(c *Config) *string { return &c.HTTP.ResponseHeaderTimeout }),
+	durationKey("http.timeout", "Limit on a whole request, including reading the body, 0 for no limit",
+		func(c *Config) *string { return &c.HTTP.Timeout }),
+	{
+		Name: "network.proxy",
+		Env:  "FASTBREW_PROXY",
+		Help: "Proxy URL for all downloads",
+		get:  func(c *Config) string { return c.Network.Proxy },
+		set: func(c *Config, v string) error {
+			if v != "" {
+				u, err := url.Parse(v)
+				if err != nil || u.Host == "" {
+					return fmt.Errorf("network.proxy must be a URL such as http://proxy.example.com:3128")
+				}
+			}
+			c.Network.Proxy = v
+			return nil
+		},
+	},
+	stringKey("network.no_proxy", "FASTBREW_NO_PROXY", "Hosts, domains and CIDRs that bypass the proxy",
+		func(c *Config) *string { return &c.Network.NoProxy }),
+	{
+		Name: "network.ca_bundle",
+		Env:  "FASTBREW_CA_BUNDLE",
+		Help: "PEM file of extra trusted certificate authorities",
+		get:  func(c *Config) string { return c.Network.CABundle },
+		set: func(c *Config, v string) error {
+			if v != "" {
+				if _, err := os.Stat(v); err != nil {
+					return fmt.Errorf("network.ca_bundle: %v", err)
+				}
+			}
+			c.Network.CABundle = v
+			return nil
+		},
+	},
+	withEnv(boolKey("network.insecure_skip_verify", "Skip TLS certificate verification",
+		func(c *Config) *bool { return &c.Network.InsecureSkipVerify }), "FASTBREW_INSECURE_SKIP_VERIFY"),
+	boolKey("daemon.enabled", "Route commands through the background daemon",
+		func(c *Config) *bool { return &c.Daemon.Enabled }),
+	boolKey("daemon.auto_start", "Start the daemon on demand",
+		func(c *Config) *bool { return &c.Daemon.AutoStart }),
+	durationKey("daemon.idle_timeout", "How long the daemon stays up without requests",
+		func(c *Config) *string { return &c.Daemon.IdleTimeout }),
+	stringKey("daemon.socket_path", "", "Unix socket the daemon listens on",
+		func(c *Config) *string { return &c.Daemon.SocketPath }),
+	boolKey("daemon.prewarm", "Load the index when the daemon starts",
+		func(c *Config) *bool { return &c.Daemon.Prewarm }),
+}
+
+func init() {
+	for i := range keys {
+		if keys[i].Env == "" {
+			keys[i].Env = envName(keys[i].Name)
+		}
+	}
+}
+
+// envName derives FASTBREW_DAEMON_ENABLED from daemon.enabled.
+func envName(key string) string {
+	return "FASTBREW_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+func intKey(name, help string, minimum int, field func(*Config) *int) Key {
+	return Key{
+		Name: name,
+		Help: help,
+		get:  func(c *Config) string { return strconv.Itoa(*field(c)) },
+		set: func(c *Config, v string) error {
+			n, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil || n < minimum {
+				if minimum > 0 {
+					return fmt.Errorf("%s must be a positive integer", name)
+				}
+				return fmt.Errorf("%s must be a non-negative integer", name)
+			}
+			*field(c) = n
+			return nil
+		},
+	}
+}
+
+func boolKey(name, help string, field func(*Config) *bool) Key {
+	return Key{
+		Name: name,
+		Help: help,
+		get:  func(c *Config) string { return strconv.FormatBool(*field(c)) },
+		set: func(c *Config, v string) error {
+			b, err := ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			*field(c) = b
+			return nil
+		},
+	}
+}
+
+func stringKey(name, env, help string, field func(*Config) *string) Key {
+	return Key{
+		Name: name,
+		Env:  env,
+		Help: help,
+		get:  func(c *Config) string { return *field(c) },
+		set: func(c *Config, v string) error {
+			*field(c) = v
+			return nil
+		},
+	}
+}
+
+func durationKey(name, help string, field func(*Config) *string) Key {
+	return Key{
+		Name: name,
+		Help: help,
+		get:  func(c *Config) string { return *field(c) },
+		set: func(c *Config, v string) error {
+			d, err := time.ParseDuration(strings.TrimSpace(v))
+			if err != nil || d < 0 {
+				return fmt.Errorf("%s must be a duration such as 30s or 2m", name)
+			}
+			*field(c) = strings.TrimSpace(v)
+			return nil
+		},
+	}
+}
+
+// pathKey holds a directory, which must be absolute; ~ is allowed. Empty
+// means the default.
+func pathKey(name, help string, field func(*Config) *string) Key {
+	return Key{
+		Name: name,
+		Help: help,
+		get:  func(c *Config) string { return *field(c) },
+		set: func(c *Config, v string) error {
+			if v != "" && !filepath.IsAbs(expandHome(v)) {
+				return fmt.Errorf("%s must be an absolute path", name)
+			}
+			*field(c) = v
+			return nil
+		},
+	}
+}
+
+func withEnv(k Key, env string) Key {
+	k.Env = env
+	return k
+}
+
+// ParseBool accepts true/false, yes/no, on/off and 1/0.
+func ParseBool(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "true", "yes", "on":
+		return true, nil
+	case "0", "false", "no", "off":
+		return false, nil
+	}
+	return false, fmt.Errorf("%q is not a boolean (use true or false)", value)
+}
+
+// Keys returns the fixed configuration keys in a stable order. Mirrors,
+// credentials, constraints and hooks are addressed per host, package or
+// event and are not included.
+func Keys() []Key {
+	return append([]Key(nil), keys...)
+}
+
+// KeyNames lists every key for help and error messages.
+func KeyNames() []string {
+	names := make([]string, 0, len(keys)+4)
+	for _, k := range keys {
+		names = append(names, k.Name)
+	}
+	return append(names, MirrorKeyPrefix+"<host>", CredentialKeyPrefix+"<host>", ConstraintKeyPrefix+"<package>", HookKeyPrefix+"<event>[.<package>]")
+}
+
+// LookupKey finds a fixed key by name.
+func LookupKey(name string) (Key, bool) {
+	for _, k := range keys {
+		if k.Name == name {
+			return k, true
+		}
+	}
+	return Key{}, false
+}
+
+// UnknownKeyError is returned for a key that is not a setting.
+type UnknownKeyError struct {
+	Key string
+}
+
+func (e UnknownKeyError) Error() string {
+	return fmt.Sprintf("unknown config key %q (available: %s)", e.Key, strings.Join(KeyNames(), ", "))
+}
+
+// Value returns the value of key as text.
+func (c *Config) Value(key string) (string, error) {
+	if k, ok := LookupKey(key); ok {
+		return k.get(c), nil
+	}
+	if host, ok := mapKeyHost(key, MirrorKeyPrefix); ok {
+		return c.Mirrors[host], nil
+	}
+	if host, ok := mapKeyHost(key, CredentialKeyPrefix); ok {
+		return c.Credentials[host], nil
+	}
+	if name, ok := mapKeyHost(key, ConstraintKeyPrefix); ok {
+		return c.Constraints[name], nil
+	}
+	if name, ok := mapKeyHost(key, HookKeyPrefix); ok {
+		return c.Hooks[name], nil
+	}
+	return "", UnknownKeyError{Key: key}
+}
+
+// SetValue validates value and stores it under key.
+func (c *Config) SetValue(key, value string) error {
+	if k, ok := LookupKey(key); ok {
+		return k.set(c, value)
+	}
+	if host, ok := mapKeyHost(key, MirrorKeyPrefix); ok {
+		return c.setMirror(host, value)
+	}
+	if host, ok := mapKeyHost(key, CredentialKeyPrefix); ok {
+		c.setCredential(host, value)
+		return nil
+	}
+	if name, ok := mapKeyHost(key, ConstraintKeyPrefix); ok {
+		return c.setConstraint(name, value)
+	}
+	if name, ok := mapKeyHost(key, HookKeyPrefix); ok {
+		c.setHook(name, value)
+		return nil
+	}
+	return UnknownKeyError{Key: key}
+}
+
+// Unset restores key to its default, or removes a mirror, credential or
+// constraint.
+func (c *Config) Unset(key string) error {
+	if k, ok := LookupKey(key); ok {
+		return k.set(c, k.get(DefaultConfig()))
+	}
+	if host, ok := mapKeyHost(key, MirrorKeyPrefix); ok {
+		delete(c.Mirrors, host)
+		return nil
+	}
+	if host, ok := mapKeyHost(key, CredentialKeyPrefix); ok {
+		delete(c.Credentials, host)
+		return nil
+	}
+	if name, ok := mapKeyHost(key, ConstraintKeyPrefix); ok {
+		delete(c.Constraints, name)
+		return nil
+	}
+	if name, ok := mapKeyHost(key, HookKeyPrefix); ok {
+		delete(c.Hooks, name)
+		return nil
+	}
+	return UnknownKeyError{Key: key}
+}
+
+// MapKeys returns the mirrors.<host>, credentials.<host>,
+// constraints.<package> and hooks.<event> keys that are set, sorted.
+func (c *Config) MapKeys() []string {
+	var out []string
+	for host := range c.Mirrors {
+		out = append(out, MirrorKeyPrefix+host)
+	}
+	for host := range c.Credentials {
+		out = append(out, CredentialKeyPrefix+host)
+	}
+	for name := range c.Constraints {
+		out = append(out, ConstraintKeyPrefix+name)
+	}
+	for name := range c.Hooks {
+		out = append(out, HookKeyPrefix+name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// IsSecretKey reports whether key holds a credential that should not be
+// printed.
+func IsSecretKey(key string) bool {
+	return strings.HasPrefix(key, CredentialKeyPrefix)
+}
+
+func mapKeyHost(key, prefix string) (string, bool) {
+	host, ok := strings.CutPrefix(key, prefix)
+	if !ok || host == "" {
+		return "", false
+	}
+	return strings.ToLower(host), true
+}
+
+// setMirror maps host to a mirror base URL. An empty value or "none"
+// removes the mirror.
+func (c *Config) setMirror(host, value string) error {
+	if value == "" || value == "none" {
+		delete(c.Mirrors, host)
+		return nil
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("mirrors.%s must be an http(s) URL", host)
+	}
+	if c.Mirrors == nil {
+		c.Mirrors = make(map[string]string)
+	}
+	c.Mirrors[host] = value
+	return nil
+}
+
+// setCredential stores a "user:secret" pair or bare token for host. An
+// empty value or "none" removes it.
+func (c *Config) setCredential(host, value string) {
+	if value == "" || value == "none" {
+		delete(c.Credentials, host)
+		return
+	}
+	if c.Credentials == nil {
+		c.Credentials = make(map[string]string)
+	}
+	c.Credentials[host] = value
+}
+
+// setConstraint stores a version constraint for the package name. An empty
+// value or "none" removes it. Only the shape is checked here; the
+// expression is parsed where versions are compared.
+func (c *Config) setConstraint(name, value string) error {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "none" {
+		delete(c.Constraints, name)
+		return nil
+	}
+	if !strings.ContainsAny(value[:1], "<>=!0123456789") {
+		return fmt.Errorf("constraints.%s must be a version constraint such as \"<21\" or \"=16.*\"", name)
+	}
+	if c.Constraints == nil {
+		c.Constraints = make(map[string]string)
+	}
+	c.Constraints[name] = value
+	return nil
+}
+
+// applyEnv overrides keys from their environment variables. Invalid values
+// are reported and ignored.
+func (c *Config) applyEnv() {
+	for _, k := range keys {
+		v, ok := os.LookupEnv(k.Env)
+		if !ok || v == "" {
+			continue
+		}
+		if err := k.set(c, v); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring %s: %v\n", k.Env, err)
+		}
+	}
+}
+
+// setHook stores the shell command run for the hook event (and package)
+// in name. An empty value or "none" removes it. Event names are checked
+// where hooks run.
+func (c *Config) setHook(name, value string) {
+	if value == "" || value == "none" {
+		delete(c.Hooks, name)
+		return
+	}
+	if c.Hooks == nil {
+		c.Hooks = make(map[string]string)
+	}
+	c.Hooks[name] = value
+}
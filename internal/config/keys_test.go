@@ -0,0 +1,157 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetValueValidatesTypes(t *testing.T) {
+	cfg := DefaultConfig()
+
+	valid := map[string]string{
+		"parallel_downloads":       "12",
+		"show_progress":            "yes",
+		"max_bandwidth":            "2M",
+		"daemon.idle_timeout":      "30m",
+		"mirrors.GHCR.io":          "https://mirror.example.com/ghcr",
+		"credentials.ghcr.io":      "token",
+		"network.no_proxy":         "localhost",
+		"cleanup_max_age_days":     "0",
+		"daemon.enabled":           "off",
+		"max_connections_per_host": "4",
+		"max_cache_size":           "5G",
+		"cache_dir":                "~/fastbrew-cache",
+		"http.timeout":             "0",
+		"retry_attempts":           "5",
+		"index_snapshots":          "5",
+		"emoji":                    "false",
+		"constraints.node":         "<21",
+		"hooks.post-install.wget":  "echo installed",
+	}
+	for key, value := range valid {
+		if err := cfg.SetValue(key, value); err != nil {
+			t.Errorf("SetValue(%q, %q) = %v", key, value, err)
+		}
+	}
+	if cfg.ParallelDownloads != 12 || !cfg.ShowProgress || cfg.Mirrors["ghcr.io"] == "" || cfg.Constraints["node"] != "<21" || cfg.Hooks["post-install.wget"] != "echo installed" {
+		t.Errorf("values not stored: %+v", cfg)
+	}
+
+	invalid := map[string]string{
+		"parallel_downloads":   "0",
+		"show_progress":        "maybe",
+		"max_bandwidth":        "fast",
+		"daemon.idle_timeout":  "soon",
+		"mirrors.ghcr.io":      "ftp://mirror",
+		"network.proxy":        "not a url",
+		"max_cache_size":       "huge",
+		"prefix":               "relative/path",
+		"http.connect_timeout": "-1s",
+		"no_such_key":          "1",
+		"constraints.node":     "latest",
+	}
+	for key, value := range invalid {
+		if err := cfg.SetValue(key, value); err == nil {
+			t.Errorf("SetValue(%q, %q) should fail", key, value)
+		}
+	}
+	if cfg.ParallelDownloads != 12 {
+		t.Errorf("rejected value changed parallel_downloads to %d", cfg.ParallelDownloads)
+	}
+}
+
+func TestUnsetRestoresDefaults(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.SetValue("daemon.idle_timeout", "1h")
+	cfg.SetValue("mirrors.ghcr.io", "https://mirror.example.com")
+	cfg.SetValue("constraints.postgresql@16", "=16.*")
+
+	if err := cfg.Unset("daemon.idle_timeout"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Unset("mirrors.ghcr.io"); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Daemon.IdleTimeout != "15m" {
+		t.Errorf("IdleTimeout = %q, want default 15m", cfg.Daemon.IdleTimeout)
+	}
+	if _, ok := cfg.Mirrors["ghcr.io"]; ok {
+		t.Error("expected mirror to be removed")
+	}
+	if err := cfg.Unset("constraints.postgresql@16"); err != nil || len(cfg.Constraints) != 0 {
+		t.Errorf("expected constraint to be removed, got %v (err %v)", cfg.Constraints, err)
+	}
+	cfg.SetValue("hooks.pre-upgrade", "backup-dotfiles")
+	if err := cfg.Unset("hooks.pre-upgrade"); err != nil || len(cfg.Hooks) != 0 {
+		t.Errorf("expected hook to be removed, got %v (err %v)", cfg.Hooks, err)
+	}
+
+	err := cfg.Unset("bogus")
+	if err == nil || !strings.Contains(err.Error(), "parallel_downloads") {
+		t.Errorf("expected an unknown key error listing the keys, got %v", err)
+	}
+}
+
+func TestLoadAppliesEnvOverrides(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	resetConfigSingleton()
+	defer resetConfigSingleton()
+
+	file := DefaultConfig()
+	file.ParallelDownloads = 5
+	file.Verbose = true
+	if err := file.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("FASTBREW_PARALLEL_DOWNLOADS", "7")
+	t.Setenv("FASTBREW_DAEMON_ENABLED", "true")
+	t.Setenv("FASTBREW_VERBOSE", "not-a-bool")
+
+	cfg := Load()
+	if cfg.ParallelDownloads != 7 {
+		t.Errorf("ParallelDownloads = %d, want env override 7", cfg.ParallelDownloads)
+	}
+	if !cfg.Daemon.Enabled {
+		t.Error("expected FASTBREW_DAEMON_ENABLED to enable the daemon")
+	}
+	if !cfg.Verbose {
+		t.Error("an invalid override should leave the file value in place")
+	}
+
+	onDisk, err := LoadFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if onDisk.ParallelDownloads != 5 || onDisk.Daemon.Enabled {
+		t.Errorf("LoadFile should not apply overrides, got %+v", onDisk)
+	}
+}
+
+func TestGettersForCacheAndTimeouts(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	cfg := DefaultConfig()
+	if cfg.GetCacheDir() != "" || cfg.GetMaxCacheSize() != 0 {
+		t.Errorf("defaults should leave the cache unconfigured: %q, %d", cfg.GetCacheDir(), cfg.GetMaxCacheSize())
+	}
+	if got := cfg.GetHTTPTimeouts(); got.Connect != 30*time.Second || got.Request != 120*time.Second {
+		t.Errorf("default timeouts = %+v", got)
+	}
+
+	cfg.SetValue("cache_dir", "~/cache")
+	cfg.SetValue("max_cache_size", "2G")
+	cfg.HTTP.ResponseHeaderTimeout = "garbage"
+	if got, want := cfg.GetCacheDir(), filepath.Join(home, "cache"); got != want {
+		t.Errorf("GetCacheDir() = %q, want %q", got, want)
+	}
+	if got := cfg.GetMaxCacheSize(); got != 2<<30 {
+		t.Errorf("GetMaxCacheSize() = %d, want %d", got, 2<<30)
+	}
+	if got := cfg.GetHTTPTimeouts().ResponseHeader; got != 30*time.Second {
+		t.Errorf("invalid response_header_timeout should fall back to 30s, got %v", got)
+	}
+}
@@ -2,6 +2,7 @@ package daemon
 
 import (
 	"container/list"
+	"fmt"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -222,11 +223,12 @@ func (c *Cache) loadOutdated(loader func() ([]brew.OutdatedPackage, error)) ([]b
 	return v.([]brew.OutdatedPackage), nil
 }
 
-func (c *Cache) loadSearch(query string, loader func(string) ([]brew.SearchItem, error)) ([]brew.SearchItem, error) {
+func (c *Cache) loadSearch(query string, opts brew.SearchOptions, loader func(string, brew.SearchOptions) ([]brew.SearchItem, error)) ([]brew.SearchItem, error) {
 	key := strings.TrimSpace(strings.ToLower(query))
 	if key == "" {
 		key = query
 	}
+	key = fmt.Sprintf("%s\x00%+v", key, opts)
 
 	c.mu.RLock()
 	if cached, ok := c.searchByQuery[key]; ok && time.Now().Before(cached.expires) {
@@ -239,7 +241,7 @@ func (c *Cache) loadSearch(query string, loader func(string) ([]brew.SearchItem,
 	c.cacheMisses.Add(1)
 
 	v, err, _ := c.sf.Do("search:"+key, func() (interface{}, error) {
-		items, loadErr := loader(query)
+		items, loadErr := loader(query, opts)
 		if loadErr != nil {
 			return nil, loadErr
 		}
@@ -63,9 +63,9 @@ func (c *Client) Invalidate(event string) error {
 	return c.call(RequestInvalidate, InvalidateRequest{Event: event}, nil)
 }
 
-func (c *Client) Search(query string) ([]brew.SearchItem, error) {
+func (c *Client) Search(query string, opts brew.SearchOptions) ([]brew.SearchItem, error) {
 	var resp SearchResponse
-	if err := c.call(RequestSearch, SearchRequest{Query: query}, &resp); err != nil {
+	if err := c.call(RequestSearch, SearchRequest{Query: query, Options: opts}, &resp); err != nil {
 		return nil, err
 	}
 	return resp.Items, nil
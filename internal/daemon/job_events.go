@@ -170,16 +170,19 @@ func (s *Server) attachJobEventBridges(job *Job) func() {
 
 				status, level := mapProgressEventStatus(event.Type)
 				currentPtr, totalPtr := progressPointers(event)
-				job.addPackageEvent(
-					level,
-					event.ID,
-					JobEventPhaseDownload,
-					status,
-					event.Message,
-					currentPtr,
-					totalPtr,
-					"bytes",
-				)
+				job.addEventWithDetails(JobEvent{
+					Level:     level,
+					Message:   event.Message,
+					Kind:      JobEventKindPackage,
+					Operation: job.operation,
+					Package:   event.ID,
+					Phase:     JobEventPhaseDownload,
+					Status:    status,
+					Current:   currentPtr,
+					Total:     totalPtr,
+					Unit:      "bytes",
+					Progress:  &event,
+				})
 			}
 		}
 	}()
@@ -1,6 +1,7 @@
 package daemon
 
 import (
+	"fastbrew/internal/brew"
 	"fmt"
 	"sync"
 	"sync/atomic"
@@ -15,6 +16,7 @@ type Job struct {
 	packages  []string
 	status    string
 	errText   string
+	errKind   string
 
 	submitted  time.Time
 	startedAt  *time.Time
@@ -58,6 +60,7 @@ func (j *Job) markFinished(err error) {
 	if err != nil {
 		j.status = JobStatusFailed
 		j.errText = err.Error()
+		j.errKind = string(brew.KindOf(err))
 		j.notifyWaitersLocked()
 		return
 	}
@@ -122,6 +125,7 @@ func (j *Job) snapshotLocked() JobView {
 		Packages:   cloneSlice(j.packages),
 		Status:     j.status,
 		Error:      j.errText,
+		ErrorKind:  j.errKind,
 		Submitted:  j.submitted,
 		StartedAt:  cloneTimePtr(j.startedAt),
 		FinishedAt: cloneTimePtr(j.finishedAt),
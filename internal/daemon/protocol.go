@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"fastbrew/internal/brew"
+	"fastbrew/internal/progress"
 	"fastbrew/internal/services"
 )
 
@@ -103,7 +104,8 @@ type HandshakeResponse struct {
 }
 
 type SearchRequest struct {
-	Query string `json:"query"`
+	Query   string             `json:"query"`
+	Options brew.SearchOptions `json:"options"`
 }
 
 type InfoRequest struct {
@@ -199,8 +201,29 @@ type ServicesListResponse struct {
 }
 
 type JobSubmitOptions struct {
-	Pinned       []string `json:"pinned,omitempty"`
-	StrictNative bool     `json:"strict_native,omitempty"`
+	Pinned []string `json:"pinned,omitempty"`
+	// Constraints maps package names to version constraints; upgrades
+	// hold back packages whose new version they rule out.
+	Constraints  map[string]string `json:"constraints,omitempty"`
+	StrictNative bool              `json:"strict_native,omitempty"`
+	// ForceDownload makes reinstall ignore cached bottles.
+	ForceDownload bool `json:"force_download,omitempty"`
+	// Dependency types installed alongside each formula, as in
+	// brew.DepsOptions.
+	IncludeBuild    bool `json:"include_build,omitempty"`
+	IncludeTest     bool `json:"include_test,omitempty"`
+	IncludeOptional bool `json:"include_optional,omitempty"`
+	SkipRecommended bool `json:"skip_recommended,omitempty"`
+}
+
+// DepsOptions returns the dependency selection o carries.
+func (o JobSubmitOptions) DepsOptions() brew.DepsOptions {
+	return brew.DepsOptions{
+		IncludeBuild:    o.IncludeBuild,
+		IncludeTest:     o.IncludeTest,
+		IncludeOptional: o.IncludeOptional,
+		SkipRecommended: o.SkipRecommended,
+	}
 }
 
 type JobSubmitRequest struct {
@@ -236,6 +259,9 @@ type JobEvent struct {
 	Current   *int64    `json:"current,omitempty"`
 	Total     *int64    `json:"total,omitempty"`
 	Unit      string    `json:"unit,omitempty"`
+	// Progress is the download progress event a download event was made
+	// from, for clients that pass it on (--progress-json).
+	Progress *progress.ProgressEvent `json:"progress,omitempty"`
 }
 
 type JobView struct {
@@ -244,6 +270,7 @@ type JobView struct {
 	Packages   []string   `json:"packages"`
 	Status     string     `json:"status"`
 	Error      string     `json:"error,omitempty"`
+	ErrorKind  string     `json:"error_kind,omitempty"`
 	Submitted  time.Time  `json:"submitted_at"`
 	StartedAt  *time.Time `json:"started_at,omitempty"`
 	FinishedAt *time.Time `json:"finished_at,omitempty"`
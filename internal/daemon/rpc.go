@@ -0,0 +1,250 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"fastbrew/internal/progress"
+)
+
+// The daemon also speaks JSON-RPC 2.0 on its socket, for editors and other
+// tools that do not link against this package. A connection whose first
+// message carries "jsonrpc": "2.0" is served as JSON-RPC and needs no
+// handshake. Methods are the request types (search, list, outdated, info,
+// deps, leaves, tap_info, services_list, job_submit, job_status,
+// job_stream, stats, status, ping, warmup, invalidate, shutdown) with
+// their payload as params, plus:
+//
+//   - install, upgrade, uninstall and reinstall, which take
+//     {"packages": [...], "options": {...}} and return {"job_id": ...};
+//   - job_watch, which takes {"job_id": ..., "from_seq": n}, sends a
+//     job_event notification for every event of the job as it happens and
+//     returns the finished job;
+//   - progress_watch, which sends a progress notification with every
+//     download progress event, of any job, until the client closes the
+//     connection or sends anything else on it.
+const (
+	RPCMethodJobWatch       = "job_watch"
+	RPCMethodProgressWatch  = "progress_watch"
+	RPCNotificationJobEvent = "job_event"
+	RPCNotificationProgress = "progress"
+)
+
+// JSON-RPC 2.0 error codes.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+type rpcNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+// RPCError is a JSON-RPC error. Data holds the daemon's response code
+// (bad_request, internal_error) when there is one.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    string `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return e.Message
+}
+
+// JobWatchRequest is the params of job_watch.
+type JobWatchRequest struct {
+	JobID   string `json:"job_id"`
+	FromSeq int    `json:"from_seq"`
+}
+
+// rpcJobParams is the params of install, upgrade, uninstall and reinstall.
+type rpcJobParams struct {
+	Packages []string         `json:"packages"`
+	Options  JobSubmitOptions `json:"options,omitempty"`
+}
+
+// isRPCMessage reports whether raw is a JSON-RPC 2.0 message.
+func isRPCMessage(raw json.RawMessage) bool {
+	var probe struct {
+		JSONRPC string `json:"jsonrpc"`
+	}
+	return json.Unmarshal(raw, &probe) == nil && probe.JSONRPC == "2.0"
+}
+
+// serveRPC answers JSON-RPC requests on a connection, starting with first,
+// until the client disconnects. Requests without an id are notifications
+// and get no response.
+func (s *Server) serveRPC(decoder *json.Decoder, encoder *json.Encoder, first json.RawMessage) {
+	raw := first
+	for {
+		var req rpcRequest
+		if err := json.Unmarshal(raw, &req); err != nil || req.JSONRPC != "2.0" || req.Method == "" {
+			code := rpcInvalidRequest
+			if !json.Valid(raw) {
+				code = rpcParseError
+			}
+			_ = encoder.Encode(rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &RPCError{Code: code, Message: "invalid JSON-RPC request"}})
+		} else if req.Method == RPCMethodProgressWatch {
+			s.watchProgress(decoder, encoder, req.ID)
+			return
+		} else {
+			result, rpcErr := s.callRPC(encoder, req)
+			if req.ID != nil {
+				resp := rpcResponse{JSONRPC: "2.0", ID: req.ID, Result: result, Error: rpcErr}
+				if rpcErr == nil && result == nil {
+					resp.Result = struct{}{}
+				}
+				_ = encoder.Encode(resp)
+			}
+			if req.Method == RequestShutdown && rpcErr == nil {
+				go func() {
+					_ = s.Close()
+				}()
+				return
+			}
+		}
+
+		raw = nil
+		if err := decoder.Decode(&raw); err != nil {
+			if !errors.Is(err, io.EOF) {
+				_ = encoder.Encode(rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &RPCError{Code: rpcParseError, Message: err.Error()}})
+			}
+			return
+		}
+		s.touch()
+		s.cache.TrackRequest()
+	}
+}
+
+// callRPC runs one JSON-RPC request.
+func (s *Server) callRPC(encoder *json.Encoder, req rpcRequest) (interface{}, *RPCError) {
+	switch req.Method {
+	case RequestHandshake:
+		return HandshakeResponse{APIVersion: APIVersion, BinaryVersion: s.binaryVersion}, nil
+	case RequestShutdown:
+		return map[string]string{"status": "shutting_down"}, nil
+	case JobOperationInstall, JobOperationUpgrade, JobOperationUninstall, JobOperationReinstall:
+		var params rpcJobParams
+		if err := unmarshalParams(req.Params, &params); err != nil {
+			return nil, &RPCError{Code: rpcInvalidParams, Message: err.Error(), Data: ResponseCodeBadReq}
+		}
+		payload, err := json.Marshal(JobSubmitRequest{Operation: req.Method, Packages: params.Packages, Options: params.Options})
+		if err != nil {
+			return nil, &RPCError{Code: rpcInternalError, Message: err.Error(), Data: ResponseCodeErr}
+		}
+		req.Method, req.Params = RequestJobSubmit, payload
+	case RPCMethodJobWatch:
+		var params JobWatchRequest
+		if err := unmarshalParams(req.Params, &params); err != nil {
+			return nil, &RPCError{Code: rpcInvalidParams, Message: err.Error(), Data: ResponseCodeBadReq}
+		}
+		return s.watchJob(encoder, params)
+	}
+
+	params := req.Params
+	if len(params) == 0 {
+		params = json.RawMessage("{}")
+	}
+	result, code, err := s.dispatch(req.Method, params)
+	if err == nil {
+		return result, nil
+	}
+	rpcErr := &RPCError{Code: rpcInternalError, Message: err.Error(), Data: code}
+	if code == ResponseCodeBadReq {
+		rpcErr.Code = rpcInvalidParams
+		if errors.Is(err, errUnknownRequest) {
+			rpcErr.Code = rpcMethodNotFound
+		}
+	}
+	return nil, rpcErr
+}
+
+// watchJob sends a job_event notification for each event of the job from
+// params.FromSeq on until it finishes, and returns the finished job.
+func (s *Server) watchJob(encoder *json.Encoder, params JobWatchRequest) (interface{}, *RPCError) {
+	fromSeq := params.FromSeq
+	for {
+		job, events, ok := s.jobs.Stream(params.JobID, fromSeq, true)
+		if !ok {
+			return nil, &RPCError{Code: rpcInvalidParams, Message: fmt.Sprintf("job %s not found", params.JobID), Data: ResponseCodeBadReq}
+		}
+		for _, event := range events {
+			if err := encoder.Encode(rpcNotification{JSONRPC: "2.0", Method: RPCNotificationJobEvent, Params: event}); err != nil {
+				return nil, &RPCError{Code: rpcInternalError, Message: err.Error(), Data: ResponseCodeErr}
+			}
+			fromSeq = event.Seq + 1
+		}
+		s.touch()
+		if job.Status == JobStatusSucceeded || job.Status == JobStatusFailed {
+			return JobStatusResponse{Job: job}, nil
+		}
+	}
+}
+
+// watchProgress answers a progress_watch request, then sends a progress
+// notification for every event on the client's progress manager until the
+// client closes the connection or sends another message.
+func (s *Server) watchProgress(decoder *json.Decoder, encoder *json.Encoder, id json.RawMessage) {
+	// Subscribe before answering, so no event after the answer is missed,
+	// but hold events back until the answer is written.
+	answered := make(chan struct{})
+	failed := make(chan struct{})
+	var failOnce sync.Once
+	stop := s.client.ProgressManager.Follow("rpc-progress", func(event progress.ProgressEvent) {
+		<-answered
+		s.touch()
+		if err := encoder.Encode(rpcNotification{JSONRPC: "2.0", Method: RPCNotificationProgress, Params: event}); err != nil {
+			failOnce.Do(func() { close(failed) })
+		}
+	})
+	defer stop()
+
+	if id != nil {
+		if err := encoder.Encode(rpcResponse{JSONRPC: "2.0", ID: id, Result: map[string]string{"status": "watching"}}); err != nil {
+			failOnce.Do(func() { close(failed) })
+		}
+	}
+	close(answered)
+
+	closed := make(chan struct{})
+	go func() {
+		var raw json.RawMessage
+		_ = decoder.Decode(&raw)
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-failed:
+	}
+}
+
+func unmarshalParams(raw json.RawMessage, v interface{}) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, v)
+}
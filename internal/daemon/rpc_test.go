@@ -0,0 +1,163 @@
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"fastbrew/internal/brew"
+	"fastbrew/internal/progress"
+)
+
+// rpcTestConn serves one connection of a server without a brew client, so
+// only requests that need none can be made.
+func rpcTestConn(t *testing.T) (*Server, net.Conn, *bufio.Scanner) {
+	t.Helper()
+	s := &Server{binaryVersion: "test", cache: NewCache(), jobs: NewJobManager()}
+	serverConn, clientConn := net.Pipe()
+	go s.handleConn(serverConn)
+	t.Cleanup(func() { clientConn.Close() })
+	_ = clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+	return s, clientConn, bufio.NewScanner(clientConn)
+}
+
+func rpcCall(t *testing.T, conn net.Conn, scanner *bufio.Scanner, message string) map[string]json.RawMessage {
+	t.Helper()
+	if _, err := conn.Write([]byte(message + "\n")); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	return rpcRead(t, scanner)
+}
+
+func rpcRead(t *testing.T, scanner *bufio.Scanner) map[string]json.RawMessage {
+	t.Helper()
+	if !scanner.Scan() {
+		t.Fatalf("no response: %v", scanner.Err())
+	}
+	var msg map[string]json.RawMessage
+	if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+		t.Fatalf("invalid response %q: %v", scanner.Text(), err)
+	}
+	return msg
+}
+
+func rpcErrorCode(t *testing.T, msg map[string]json.RawMessage) int {
+	t.Helper()
+	var rpcErr RPCError
+	if err := json.Unmarshal(msg["error"], &rpcErr); err != nil {
+		t.Fatalf("expected an error, got %v", msg)
+	}
+	return rpcErr.Code
+}
+
+func TestIsRPCMessage(t *testing.T) {
+	if !isRPCMessage(json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"ping"}`)) {
+		t.Error("expected a JSON-RPC 2.0 message to be recognised")
+	}
+	if isRPCMessage(json.RawMessage(`{"type":"handshake","payload":{}}`)) {
+		t.Error("daemon protocol requests are not JSON-RPC")
+	}
+}
+
+func TestRPCRequests(t *testing.T) {
+	_, conn, scanner := rpcTestConn(t)
+
+	resp := rpcCall(t, conn, scanner, `{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+	if string(resp["id"]) != "1" || string(resp["result"]) != `{"status":"ok"}` {
+		t.Errorf("unexpected ping response %v", resp)
+	}
+
+	// Notifications get no response, so the next line answers id 2.
+	if _, err := conn.Write([]byte(`{"jsonrpc":"2.0","method":"ping"}` + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	resp = rpcCall(t, conn, scanner, `{"jsonrpc":"2.0","id":"two","method":"frobnicate"}`)
+	if string(resp["id"]) != `"two"` || rpcErrorCode(t, resp) != rpcMethodNotFound {
+		t.Errorf("unexpected response to an unknown method %v", resp)
+	}
+
+	resp = rpcCall(t, conn, scanner, `{"jsonrpc":"2.0","id":3,"method":"job_status","params":{"job_id":"missing"}}`)
+	if rpcErrorCode(t, resp) != rpcInvalidParams {
+		t.Errorf("unexpected response for a missing job %v", resp)
+	}
+
+	resp = rpcCall(t, conn, scanner, `{"jsonrpc":"2.0","id":4}`)
+	if rpcErrorCode(t, resp) != rpcInvalidRequest {
+		t.Errorf("unexpected response to a request without a method %v", resp)
+	}
+}
+
+func TestRPCJobWatch(t *testing.T) {
+	s, conn, scanner := rpcTestConn(t)
+	release := make(chan struct{})
+	job := s.jobs.Submit(JobOperationInstall, []string{"jq"}, func(job *Job) error {
+		job.addEvent("info", "first")
+		<-release
+		job.addEvent("info", "second")
+		return nil
+	})
+
+	if _, err := conn.Write([]byte(`{"jsonrpc":"2.0","id":1,"method":"job_watch","params":{"job_id":"` + job.id + `"}}` + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+
+	var messages []string
+	for {
+		msg := rpcRead(t, scanner)
+		if _, ok := msg["id"]; ok {
+			var result JobStatusResponse
+			if err := json.Unmarshal(msg["result"], &result); err != nil {
+				t.Fatalf("unexpected job_watch result %v", msg)
+			}
+			if result.Job.Status != JobStatusSucceeded {
+				t.Errorf("expected a succeeded job, got %s", result.Job.Status)
+			}
+			break
+		}
+		if string(msg["method"]) != `"job_event"` {
+			t.Fatalf("unexpected notification %v", msg)
+		}
+		var event JobEvent
+		if err := json.Unmarshal(msg["params"], &event); err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, event.Message)
+	}
+
+	var sawFirst, sawSecond bool
+	for _, m := range messages {
+		sawFirst = sawFirst || m == "first"
+		sawSecond = sawSecond || m == "second"
+	}
+	if !sawFirst || !sawSecond {
+		t.Errorf("expected both events to be sent, got %q", messages)
+	}
+}
+
+func TestRPCProgressWatch(t *testing.T) {
+	s, conn, scanner := rpcTestConn(t)
+	s.client = &brew.Client{}
+	s.client.EnableProgress()
+
+	resp := rpcCall(t, conn, scanner, `{"jsonrpc":"2.0","id":1,"method":"progress_watch"}`)
+	if string(resp["result"]) != `{"status":"watching"}` {
+		t.Fatalf("unexpected progress_watch response %v", resp)
+	}
+
+	tracker := s.client.ProgressManager.Register("wget", "https://example.com/wget")
+	tracker.Start(100)
+	msg := rpcRead(t, scanner)
+	if string(msg["method"]) != `"progress"` {
+		t.Fatalf("unexpected notification %v", msg)
+	}
+	var event progress.ProgressEvent
+	if err := json.Unmarshal(msg["params"], &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.ID != "wget" || event.Type != progress.EventDownloadStart || event.Total != 100 {
+		t.Errorf("unexpected progress event %+v", event)
+	}
+}
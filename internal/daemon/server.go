@@ -17,6 +17,7 @@ import (
 	"time"
 
 	"fastbrew/internal/brew"
+	"fastbrew/internal/lockfile"
 	"fastbrew/internal/services"
 )
 
@@ -25,6 +26,10 @@ type ServerOptions struct {
 	IdleTimeout   time.Duration
 	BinaryVersion string
 	Prewarm       bool
+	// Prefix and CacheDir override the detected Homebrew prefix and the
+	// default cache directory.
+	Prefix   string
+	CacheDir string
 }
 
 type Server struct {
@@ -51,10 +56,12 @@ func NewServer(opts ServerOptions) (*Server, error) {
 		return nil, fmt.Errorf("socket path is required")
 	}
 
-	client, err := brew.NewClient()
+	client, err := brew.NewClientWithOptions(brew.ClientOptions{Prefix: opts.Prefix, CacheDir: opts.CacheDir})
 	if err != nil {
 		return nil, err
 	}
+	// Progress stays on so progress_watch has events to follow between jobs.
+	client.EnableProgress()
 
 	s := &Server{
 		socketPath:    opts.SocketPath,
@@ -181,8 +188,8 @@ func (s *Server) handleConn(conn net.Conn) {
 	handshakeDone := false
 
 	for {
-		var req Request
-		if err := decoder.Decode(&req); err != nil {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
 			if errors.Is(err, io.EOF) {
 				return
 			}
@@ -192,6 +199,18 @@ func (s *Server) handleConn(conn net.Conn) {
 		s.touch()
 		s.cache.TrackRequest()
 
+		// Connections that open with a JSON-RPC message speak JSON-RPC
+		// throughout; see rpc.go.
+		if !handshakeDone && isRPCMessage(raw) {
+			s.serveRPC(decoder, encoder, raw)
+			return
+		}
+
+		var req Request
+		if err := json.Unmarshal(raw, &req); err != nil {
+			_ = writeErrorResponse(encoder, ResponseCodeBadReq, err)
+			return
+		}
 		if !handshakeDone && req.Type != RequestHandshake {
 			_ = writeErrorResponse(encoder, ResponseCodeVer, fmt.Errorf("handshake required before request"))
 			return
@@ -203,181 +222,178 @@ func (s *Server) handleConn(conn net.Conn) {
 				return
 			}
 			handshakeDone = true
-		case RequestPing:
-			_ = writeOKResponse(encoder, map[string]string{"status": "ok"})
-		case RequestStatus:
-			resp := StatusResponse{
-				PID:             os.Getpid(),
-				SocketPath:      s.socketPath,
-				StartedAt:       s.startedAt,
-				LastActivityAt:  s.lastActivityTime(),
-				IdleTimeoutSecs: int(s.idleTimeout.Seconds()),
-			}
-			_ = writeOKResponse(encoder, resp)
-		case RequestStats:
-			stats := s.cache.stats(s.startedAt)
-			jobStats := s.jobs.Stats()
-			stats.JobsTotal = jobStats.Total
-			stats.JobsRunning = jobStats.Running
-			stats.JobsFailed = jobStats.Failed
-			_ = writeOKResponse(encoder, stats)
-		case RequestWarmup:
-			if err := s.Warmup(); err != nil {
-				_ = writeErrorResponse(encoder, ResponseCodeErr, err)
-				continue
-			}
-			_ = writeOKResponse(encoder, map[string]string{"status": "warmed"})
 		case RequestShutdown:
 			_ = writeOKResponse(encoder, map[string]string{"status": "shutting_down"})
 			go func() {
 				_ = s.Close()
 			}()
 			return
-		case RequestInvalidate:
-			var payload InvalidateRequest
-			if err := json.Unmarshal(req.Payload, &payload); err != nil {
-				_ = writeErrorResponse(encoder, ResponseCodeBadReq, err)
-				continue
-			}
-			s.cache.invalidate(payload.Event)
-			_ = writeOKResponse(encoder, map[string]string{"status": "invalidated"})
-		case RequestSearch:
-			var payload SearchRequest
-			if err := json.Unmarshal(req.Payload, &payload); err != nil {
-				_ = writeErrorResponse(encoder, ResponseCodeBadReq, err)
-				continue
-			}
-			items, err := s.cache.loadSearch(payload.Query, s.client.SearchFuzzyWithIndex)
-			if err != nil {
-				_ = writeErrorResponse(encoder, ResponseCodeErr, err)
-				continue
-			}
-			_ = writeOKResponse(encoder, SearchResponse{Items: items})
-		case RequestList:
-			items, err := s.cache.loadInstalled(s.client.ListInstalledNative)
-			if err != nil {
-				_ = writeErrorResponse(encoder, ResponseCodeErr, err)
-				continue
-			}
-			_ = writeOKResponse(encoder, ListResponse{Items: items})
-		case RequestOutdated:
-			items, err := s.cache.loadOutdated(s.client.GetOutdated)
-			if err != nil {
-				_ = writeErrorResponse(encoder, ResponseCodeErr, err)
-				continue
-			}
-			_ = writeOKResponse(encoder, OutdatedResponse{Items: items})
-		case RequestInfo:
-			var payload InfoRequest
-			if err := json.Unmarshal(req.Payload, &payload); err != nil {
-				_ = writeErrorResponse(encoder, ResponseCodeBadReq, err)
-				continue
-			}
-			info, err := s.loadPackageInfo(payload.Packages)
-			if err != nil {
-				_ = writeErrorResponse(encoder, ResponseCodeErr, err)
-				continue
-			}
-			_ = writeOKResponse(encoder, InfoResponse{Packages: info})
-		case RequestDeps:
-			var payload DepsRequest
-			if err := json.Unmarshal(req.Payload, &payload); err != nil {
-				_ = writeErrorResponse(encoder, ResponseCodeBadReq, err)
-				continue
-			}
-			deps, err := s.cache.loadDeps(payload.Packages, func() ([]string, error) {
-				return s.client.ResolveDeps(payload.Packages)
-			})
-			if err != nil {
-				_ = writeErrorResponse(encoder, ResponseCodeErr, err)
-				continue
-			}
-			_ = writeOKResponse(encoder, DepsResponse{Dependencies: deps})
-		case RequestLeaves:
-			leaves, err := s.cache.loadLeaves(s.computeLeaves)
-			if err != nil {
-				_ = writeErrorResponse(encoder, ResponseCodeErr, err)
-				continue
-			}
-			_ = writeOKResponse(encoder, LeavesResponse{Items: leaves})
-		case RequestTapInfo:
-			var payload TapInfoRequest
-			if err := json.Unmarshal(req.Payload, &payload); err != nil {
-				_ = writeErrorResponse(encoder, ResponseCodeBadReq, err)
-				continue
-			}
-			info, err := s.cache.loadTapInfo(payload.Repo, payload.InstalledOnly, func() (*brew.TapInfo, error) {
-				manager, managerErr := brew.NewTapManager()
-				if managerErr != nil {
-					return nil, managerErr
-				}
-				return manager.GetTapInfo(payload.Repo, payload.InstalledOnly)
-			})
-			if err != nil {
-				_ = writeErrorResponse(encoder, ResponseCodeErr, err)
-				continue
-			}
-			_ = writeOKResponse(encoder, TapInfoResponse{Info: info})
-		case RequestServices:
-			var payload ServicesListRequest
-			if err := json.Unmarshal(req.Payload, &payload); err != nil {
-				_ = writeErrorResponse(encoder, ResponseCodeBadReq, err)
-				continue
-			}
-			svcs, err := s.cache.loadServices(payload.Scope, func() ([]services.Service, error) {
-				if payload.Scope == "" {
-					return services.NewServiceManager().ListServices()
-				}
-				manager, managerErr := services.NewServiceManagerWithScope(services.ServiceScope(payload.Scope))
-				if managerErr != nil {
-					return nil, managerErr
-				}
-				return manager.ListServices()
-			})
-			if err != nil {
-				_ = writeErrorResponse(encoder, ResponseCodeErr, err)
-				continue
-			}
-			_ = writeOKResponse(encoder, ServicesListResponse{Items: svcs})
-		case RequestJobSubmit:
-			var payload JobSubmitRequest
-			if err := json.Unmarshal(req.Payload, &payload); err != nil {
-				_ = writeErrorResponse(encoder, ResponseCodeBadReq, err)
-				continue
-			}
-			jobID, err := s.submitJob(payload)
+		default:
+			payload, code, err := s.dispatch(req.Type, req.Payload)
 			if err != nil {
-				_ = writeErrorResponse(encoder, ResponseCodeErr, err)
+				_ = writeErrorResponse(encoder, code, err)
 				continue
 			}
-			_ = writeOKResponse(encoder, JobSubmitResponse{JobID: jobID})
-		case RequestJobStatus:
-			var payload JobStatusRequest
-			if err := json.Unmarshal(req.Payload, &payload); err != nil {
-				_ = writeErrorResponse(encoder, ResponseCodeBadReq, err)
-				continue
-			}
-			job, ok := s.jobs.Status(payload.JobID)
-			if !ok {
-				_ = writeErrorResponse(encoder, ResponseCodeBadReq, fmt.Errorf("job %s not found", payload.JobID))
-				continue
+			_ = writeOKResponse(encoder, payload)
+		}
+	}
+}
+
+// dispatch runs a request other than the handshake and shutdown, which
+// change the connection, and returns its response payload or an error
+// with its response code.
+// errUnknownRequest is returned by dispatch for request types it does not
+// know.
+var errUnknownRequest = errors.New("unknown request type")
+
+func (s *Server) dispatch(reqType string, raw json.RawMessage) (interface{}, string, error) {
+	switch reqType {
+	case RequestPing:
+		return map[string]string{"status": "ok"}, "", nil
+	case RequestStatus:
+		return StatusResponse{
+			PID:             os.Getpid(),
+			SocketPath:      s.socketPath,
+			StartedAt:       s.startedAt,
+			LastActivityAt:  s.lastActivityTime(),
+			IdleTimeoutSecs: int(s.idleTimeout.Seconds()),
+		}, "", nil
+	case RequestStats:
+		stats := s.cache.stats(s.startedAt)
+		jobStats := s.jobs.Stats()
+		stats.JobsTotal = jobStats.Total
+		stats.JobsRunning = jobStats.Running
+		stats.JobsFailed = jobStats.Failed
+		return stats, "", nil
+	case RequestWarmup:
+		if err := s.Warmup(); err != nil {
+			return nil, ResponseCodeErr, err
+		}
+		return map[string]string{"status": "warmed"}, "", nil
+	case RequestInvalidate:
+		var payload InvalidateRequest
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return nil, ResponseCodeBadReq, err
+		}
+		s.cache.invalidate(payload.Event)
+		return map[string]string{"status": "invalidated"}, "", nil
+	case RequestSearch:
+		var payload SearchRequest
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return nil, ResponseCodeBadReq, err
+		}
+		items, err := s.cache.loadSearch(payload.Query, payload.Options, s.client.Search)
+		if err != nil {
+			return nil, ResponseCodeErr, err
+		}
+		return SearchResponse{Items: items}, "", nil
+	case RequestList:
+		items, err := s.cache.loadInstalled(s.client.ListInstalledNative)
+		if err != nil {
+			return nil, ResponseCodeErr, err
+		}
+		return ListResponse{Items: items}, "", nil
+	case RequestOutdated:
+		items, err := s.cache.loadOutdated(s.client.GetOutdated)
+		if err != nil {
+			return nil, ResponseCodeErr, err
+		}
+		return OutdatedResponse{Items: items}, "", nil
+	case RequestInfo:
+		var payload InfoRequest
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return nil, ResponseCodeBadReq, err
+		}
+		info, err := s.loadPackageInfo(payload.Packages)
+		if err != nil {
+			return nil, ResponseCodeErr, err
+		}
+		return InfoResponse{Packages: info}, "", nil
+	case RequestDeps:
+		var payload DepsRequest
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return nil, ResponseCodeBadReq, err
+		}
+		deps, err := s.cache.loadDeps(payload.Packages, func() ([]string, error) {
+			return s.client.ResolveDeps(payload.Packages)
+		})
+		if err != nil {
+			return nil, ResponseCodeErr, err
+		}
+		return DepsResponse{Dependencies: deps}, "", nil
+	case RequestLeaves:
+		leaves, err := s.cache.loadLeaves(s.computeLeaves)
+		if err != nil {
+			return nil, ResponseCodeErr, err
+		}
+		return LeavesResponse{Items: leaves}, "", nil
+	case RequestTapInfo:
+		var payload TapInfoRequest
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return nil, ResponseCodeBadReq, err
+		}
+		info, err := s.cache.loadTapInfo(payload.Repo, payload.InstalledOnly, func() (*brew.TapInfo, error) {
+			manager, managerErr := brew.NewTapManager()
+			if managerErr != nil {
+				return nil, managerErr
 			}
-			_ = writeOKResponse(encoder, JobStatusResponse{Job: job})
-		case RequestJobStream:
-			var payload JobStreamRequest
-			if err := json.Unmarshal(req.Payload, &payload); err != nil {
-				_ = writeErrorResponse(encoder, ResponseCodeBadReq, err)
-				continue
+			return manager.GetTapInfo(payload.Repo, payload.InstalledOnly)
+		})
+		if err != nil {
+			return nil, ResponseCodeErr, err
+		}
+		return TapInfoResponse{Info: info}, "", nil
+	case RequestServices:
+		var payload ServicesListRequest
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return nil, ResponseCodeBadReq, err
+		}
+		svcs, err := s.cache.loadServices(payload.Scope, func() ([]services.Service, error) {
+			if payload.Scope == "" {
+				return services.NewServiceManager().ListServices()
 			}
-			job, events, ok := s.jobs.Stream(payload.JobID, payload.FromSeq, payload.Blocking)
-			if !ok {
-				_ = writeErrorResponse(encoder, ResponseCodeBadReq, fmt.Errorf("job %s not found", payload.JobID))
-				continue
+			manager, managerErr := services.NewServiceManagerWithScope(services.ServiceScope(payload.Scope))
+			if managerErr != nil {
+				return nil, managerErr
 			}
-			_ = writeOKResponse(encoder, JobStreamResponse{Job: job, Events: events})
-		default:
-			_ = writeErrorResponse(encoder, ResponseCodeBadReq, fmt.Errorf("unknown request type %q", req.Type))
+			return manager.ListServices()
+		})
+		if err != nil {
+			return nil, ResponseCodeErr, err
 		}
+		return ServicesListResponse{Items: svcs}, "", nil
+	case RequestJobSubmit:
+		var payload JobSubmitRequest
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return nil, ResponseCodeBadReq, err
+		}
+		jobID, err := s.submitJob(payload)
+		if err != nil {
+			return nil, ResponseCodeErr, err
+		}
+		return JobSubmitResponse{JobID: jobID}, "", nil
+	case RequestJobStatus:
+		var payload JobStatusRequest
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return nil, ResponseCodeBadReq, err
+		}
+		job, ok := s.jobs.Status(payload.JobID)
+		if !ok {
+			return nil, ResponseCodeBadReq, fmt.Errorf("job %s not found", payload.JobID)
+		}
+		return JobStatusResponse{Job: job}, "", nil
+	case RequestJobStream:
+		var payload JobStreamRequest
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return nil, ResponseCodeBadReq, err
+		}
+		job, events, ok := s.jobs.Stream(payload.JobID, payload.FromSeq, payload.Blocking)
+		if !ok {
+			return nil, ResponseCodeBadReq, fmt.Errorf("job %s not found", payload.JobID)
+		}
+		return JobStreamResponse{Job: job, Events: events}, "", nil
+	default:
+		return nil, ResponseCodeBadReq, fmt.Errorf("%w %q", errUnknownRequest, reqType)
 	}
 }
 
@@ -406,7 +422,9 @@ func (s *Server) handleHandshake(encoder *json.Encoder, payload json.RawMessage)
 func (s *Server) loadPackageInfo(packages []string) ([]PackageInfo, error) {
 	info := make([]PackageInfo, 0, len(packages))
 	for _, name := range packages {
-		formula, err := s.cache.loadFormula(name, s.client.FetchFormula)
+		formula, err := s.cache.loadFormula(name, func(name string) (*brew.RemoteFormula, error) {
+			return s.client.FetchFormula(context.Background(), name)
+		})
 		if err == nil {
 			info = append(info, PackageInfo{
 				Name:         formula.Name,
@@ -437,41 +455,7 @@ func (s *Server) computeLeaves() ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
-	if len(installed) == 0 {
-		return nil, nil
-	}
-
-	idx, err := s.client.LoadIndex()
-	if err != nil {
-		return nil, err
-	}
-	formulaMap := make(map[string]brew.Formula, len(idx.Formulae))
-	for _, formula := range idx.Formulae {
-		formulaMap[formula.Name] = formula
-	}
-
-	isDependency := make(map[string]bool)
-	for _, pkg := range installed {
-		if pkg.IsCask {
-			continue
-		}
-		if formula, ok := formulaMap[pkg.Name]; ok {
-			for _, dep := range formula.Dependencies {
-				isDependency[dep] = true
-			}
-		}
-	}
-
-	leaves := make([]string, 0, len(installed))
-	for _, pkg := range installed {
-		if pkg.IsCask {
-			continue
-		}
-		if !isDependency[pkg.Name] {
-			leaves = append(leaves, pkg.Name)
-		}
-	}
-	return leaves, nil
+	return s.client.LeavesOf(installed)
 }
 
 func (s *Server) submitJob(req JobSubmitRequest) (string, error) {
@@ -486,18 +470,25 @@ func (s *Server) submitJob(req JobSubmitRequest) (string, error) {
 		s.mutationMu.Lock()
 		defer s.mutationMu.Unlock()
 
+		// Wait for any fastbrew command running outside the daemon.
+		lock, err := lockfile.Acquire(context.Background(), lockfile.DefaultPath())
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+
 		cleanup := s.attachJobEventBridges(job)
 		defer cleanup()
 
 		switch operation {
 		case JobOperationInstall:
-			return s.executeInstallJob(job, req.Packages, req.Options.StrictNative)
+			return s.executeInstallJob(job, req.Packages, req.Options)
 		case JobOperationUpgrade:
-			return s.executeUpgradeJob(job, req.Packages, req.Options.Pinned)
+			return s.executeUpgradeJob(job, req.Packages, req.Options.Pinned, req.Options.Constraints)
 		case JobOperationUninstall:
 			return s.executeUninstallJob(job, req.Packages)
 		case JobOperationReinstall:
-			return s.executeReinstallJob(job, req.Packages)
+			return s.executeReinstallJob(job, req.Packages, req.Options.ForceDownload)
 		default:
 			return fmt.Errorf("unknown job operation %q", operation)
 		}
@@ -506,7 +497,7 @@ func (s *Server) submitJob(req JobSubmitRequest) (string, error) {
 	return job.id, nil
 }
 
-func (s *Server) executeInstallJob(job *Job, packages []string, strictNative bool) error {
+func (s *Server) executeInstallJob(job *Job, packages []string, options JobSubmitOptions) error {
 	if len(packages) == 0 {
 		return fmt.Errorf("install requires at least one package")
 	}
@@ -514,14 +505,14 @@ func (s *Server) executeInstallJob(job *Job, packages []string, strictNative boo
 	for _, pkg := range packages {
 		job.addPackageEvent("info", pkg, JobEventPhaseInstall, JobEventStatusQueued, "package queued", nil, nil, "")
 	}
-	if err := s.client.InstallNativeWithOptions(packages, brew.InstallOptions{StrictNative: strictNative}); err != nil {
+	if err := s.client.InstallNativeWithOptions(context.Background(), packages, brew.InstallOptions{StrictNative: options.StrictNative, Deps: options.DepsOptions()}); err != nil {
 		return err
 	}
 	job.addEvent("info", "Install completed")
 	return nil
 }
 
-func (s *Server) executeUpgradeJob(job *Job, packages []string, pinned []string) error {
+func (s *Server) executeUpgradeJob(job *Job, packages []string, pinned []string, constraints map[string]string) error {
 	job.addEvent("info", "Resolving outdated packages")
 	outdated, err := s.cache.loadOutdated(s.client.GetOutdated)
 	if err != nil {
@@ -530,7 +521,7 @@ func (s *Server) executeUpgradeJob(job *Job, packages []string, pinned []string)
 
 	if len(packages) > 0 {
 		requested := make(map[string]struct{}, len(packages))
-		for _, pkg := range packages {
+		for _, pkg := range s.client.WithDependencies(packages) {
 			requested[pkg] = struct{}{}
 		}
 		filtered := make([]brew.OutdatedPackage, 0, len(outdated))
@@ -559,13 +550,30 @@ func (s *Server) executeUpgradeJob(job *Job, packages []string, pinned []string)
 		outdated = filtered
 	}
 
+	if len(constraints) > 0 {
+		// The cached list is shared, so mark a copy.
+		outdated = append([]brew.OutdatedPackage(nil), outdated...)
+		brew.MarkHeld(outdated, constraints)
+		filtered := make([]brew.OutdatedPackage, 0, len(outdated))
+		for _, pkg := range outdated {
+			if pkg.Held {
+				reason := fmt.Sprintf("held back: %s is outside constraint %q", pkg.NewVersion, pkg.Constraint)
+				job.addEvent("info", fmt.Sprintf("Holding %s at %s (%s)", pkg.Name, pkg.CurrentVersion, reason))
+				job.addPackageEvent("warn", pkg.Name, JobEventPhaseInstall, JobEventStatusSkipped, reason, nil, nil, "")
+				continue
+			}
+			filtered = append(filtered, pkg)
+		}
+		outdated = filtered
+	}
+
 	if len(outdated) == 0 {
-		job.addEvent("info", "All packages up to date or pinned.")
+		job.addEvent("info", "All packages up to date, pinned or held.")
 		return nil
 	}
 
 	job.addEvent("info", fmt.Sprintf("Upgrading %d package(s)", len(outdated)))
-	if err := s.client.UpgradeNative(nil, outdated); err != nil {
+	if err := s.client.UpgradeNative(context.Background(), nil, outdated); err != nil {
 		return err
 	}
 	job.addEvent("info", "Upgrade completed")
@@ -591,22 +599,15 @@ func (s *Server) executeUninstallJob(job *Job, packages []string) error {
 			continue
 		}
 
-		pkgPath := filepath.Join(s.client.Cellar, pkg)
-		if _, err := os.Stat(pkgPath); os.IsNotExist(err) {
+		if _, err := os.Stat(filepath.Join(s.client.Cellar, pkg)); os.IsNotExist(err) {
 			job.addEvent("warn", fmt.Sprintf("%s is not installed", pkg))
 			job.addPackageEvent("warn", pkg, JobEventPhaseUninstall, JobEventStatusSkipped, "package is not installed", nil, nil, "")
 			continue
 		}
 
 		job.addPackageEvent("info", pkg, JobEventPhaseUninstall, JobEventStatusRunning, "removing package", nil, nil, "")
-		_ = s.client.Unlink(pkg)
-		optLink := filepath.Join(s.client.Prefix, "opt", pkg)
-		if info, err := os.Lstat(optLink); err == nil && info.Mode()&os.ModeSymlink != 0 {
-			_ = os.Remove(optLink)
-		}
-
-		if err := os.RemoveAll(pkgPath); err != nil {
-			job.addEvent("warn", fmt.Sprintf("Error removing %s: %v", pkg, err))
+		if _, err := s.client.UninstallFormula(pkg, brew.UninstallOptions{}); err != nil {
+			job.addEvent("warn", fmt.Sprintf("Not uninstalling %s: %v", pkg, err))
 			job.addPackageEvent("error", pkg, JobEventPhaseUninstall, JobEventStatusFailed, err.Error(), nil, nil, "")
 			continue
 		}
@@ -618,7 +619,7 @@ func (s *Server) executeUninstallJob(job *Job, packages []string) error {
 	return nil
 }
 
-func (s *Server) executeReinstallJob(job *Job, packages []string) error {
+func (s *Server) executeReinstallJob(job *Job, packages []string, forceDownload bool) error {
 	if len(packages) == 0 {
 		return fmt.Errorf("reinstall requires at least one package")
 	}
@@ -644,39 +645,13 @@ func (s *Server) executeReinstallJob(job *Job, packages []string) error {
 			continue
 		}
 
-		if err := s.client.Unlink(pkg); err != nil {
-			job.addEvent("warn", fmt.Sprintf("Unlink warning for %s: %v", pkg, err))
-			job.addPackageEvent("warn", pkg, JobEventPhaseUninstall, JobEventStatusFailed, err.Error(), nil, nil, "")
-		}
-		if err := os.RemoveAll(filepath.Join(s.client.Cellar, pkg)); err != nil {
-			job.addEvent("warn", fmt.Sprintf("Removal warning for %s: %v", pkg, err))
-			job.addPackageEvent("warn", pkg, JobEventPhaseUninstall, JobEventStatusFailed, err.Error(), nil, nil, "")
-		}
-
-		formula, err := s.client.FetchFormula(pkg)
+		result, err := s.client.Reinstall(context.Background(), pkg, brew.ReinstallOptions{ForceDownload: forceDownload})
 		if err != nil {
-			job.addEvent("warn", fmt.Sprintf("Error fetching formula %s: %v", pkg, err))
-			job.addPackageEvent("error", pkg, JobEventPhaseMetadata, JobEventStatusFailed, err.Error(), nil, nil, "")
-			continue
-		}
-		if err := s.client.InstallBottle(formula); err != nil {
-			job.addEvent("warn", fmt.Sprintf("Error installing %s: %v", pkg, err))
+			job.addEvent("warn", fmt.Sprintf("Error reinstalling %s: %v", pkg, err))
 			job.addPackageEvent("error", pkg, JobEventPhaseInstall, JobEventStatusFailed, err.Error(), nil, nil, "")
 			continue
 		}
-		result, err := s.client.Link(formula.Name, formula.Versions.Stable)
-		if err != nil {
-			job.addEvent("warn", fmt.Sprintf("Error linking %s: %v", pkg, err))
-			job.addPackageEvent("error", pkg, JobEventPhaseLink, JobEventStatusFailed, err.Error(), nil, nil, "")
-			continue
-		}
-		if !result.Success {
-			job.addEvent("warn", fmt.Sprintf("Reinstalled %s with %d link error(s)", pkg, len(result.Errors)))
-			job.addPackageEvent("warn", pkg, JobEventPhaseLink, JobEventStatusFailed, "link completed with errors", nil, nil, "")
-			continue
-		}
-
-		job.addEvent("info", fmt.Sprintf("Reinstalled %s successfully", pkg))
+		job.addEvent("info", fmt.Sprintf("Reinstalled %s %s → %s", pkg, result.PreviousVersion, result.Version))
 		job.addPackageEvent("info", pkg, JobEventPhaseComplete, JobEventStatusSucceeded, "formula reinstalled", nil, nil, "")
 	}
 
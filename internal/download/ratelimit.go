@@ -0,0 +1,76 @@
+package download
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket refilled at a fixed number of bytes per
+// second, holding at most one second's worth of tokens.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+func NewRateLimiter(bytesPerSecond int64) *RateLimiter {
+	rate := float64(bytesPerSecond)
+	return &RateLimiter{
+		rate:   rate,
+		burst:  rate,
+		tokens: rate,
+		last:   time.Now(),
+		now:    time.Now,
+		sleep:  sleepContext,
+	}
+}
+
+// Burst returns the largest single reservation the bucket can satisfy.
+func (l *RateLimiter) Burst() int {
+	return int(l.burst)
+}
+
+// WaitN blocks until n bytes may be consumed. Requests larger than the burst
+// are treated as a full bucket.
+func (l *RateLimiter) WaitN(ctx context.Context, n int) error {
+	need := float64(n)
+	if need > l.burst {
+		need = l.burst
+	}
+
+	l.mu.Lock()
+	now := l.now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	// Reserve immediately, possibly going into debt; the caller then sleeps
+	// for however long the debt takes to repay. This keeps concurrent
+	// readers fair without a wake-up queue.
+	l.tokens -= need
+	deficit := -l.tokens
+	l.mu.Unlock()
+
+	if deficit <= 0 {
+		return nil
+	}
+	wait := time.Duration(deficit / l.rate * float64(time.Second))
+	return l.sleep(ctx, wait)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,227 @@
+package download
+
+import (
+	"context"
+	"fastbrew/internal/httpclient"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultConcurrency is used when a Config does not set MaxConcurrent.
+const DefaultConcurrency = 4
+
+// Config controls how a Scheduler shares the network between fetches.
+type Config struct {
+	// MaxConcurrent is the number of fetch jobs allowed to run at once.
+	MaxConcurrent int
+	// MaxPerHost caps in-flight requests per host. Zero means unlimited.
+	MaxPerHost int
+	// MaxBytesPerSecond caps the combined read rate of all response
+	// bodies. Zero means unlimited.
+	MaxBytesPerSecond int64
+	// ThroughputStatsPath, when set, keeps per-host throughput samples in
+	// that file. Without MaxPerHost, each host's cap is then derived from
+	// its samples (see ThroughputStats.Limit) instead of being unlimited.
+	ThroughputStatsPath string
+}
+
+// Scheduler is the single gate that metadata and bottle fetches go through.
+// Callers take a global slot with Acquire/Run for each unit of work and send
+// requests with Do, which applies per-host caps and bandwidth limiting.
+type Scheduler struct {
+	client  *http.Client
+	global  chan struct{}
+	perHost int
+	limiter *RateLimiter
+	stats   *ThroughputStats
+
+	hostMu sync.Mutex
+	hosts  map[string]*hostGate
+}
+
+// hostGate counts the requests in flight to one host.
+type hostGate struct {
+	inFlight int
+	// released is closed, and replaced, whenever a request finishes.
+	released chan struct{}
+}
+
+func NewScheduler(cfg Config) *Scheduler {
+	concurrency := cfg.MaxConcurrent
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	s := &Scheduler{
+		client:  httpclient.Get(),
+		global:  make(chan struct{}, concurrency),
+		perHost: cfg.MaxPerHost,
+		hosts:   make(map[string]*hostGate),
+	}
+	if cfg.MaxBytesPerSecond > 0 {
+		s.limiter = NewRateLimiter(cfg.MaxBytesPerSecond)
+	}
+	if cfg.ThroughputStatsPath != "" {
+		s.stats = LoadThroughputStats(cfg.ThroughputStatsPath)
+	}
+	return s
+}
+
+// SetHTTPClient overrides the client used by Do.
+func (s *Scheduler) SetHTTPClient(client *http.Client) {
+	s.client = client
+}
+
+// Concurrency returns the number of global slots.
+func (s *Scheduler) Concurrency() int {
+	return cap(s.global)
+}
+
+// Acquire blocks until a global slot is free and returns its release func.
+func (s *Scheduler) Acquire(ctx context.Context) (func(), error) {
+	select {
+	case s.global <- struct{}{}:
+		var once sync.Once
+		return func() { once.Do(func() { <-s.global }) }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Run executes fn while holding a global slot.
+func (s *Scheduler) Run(ctx context.Context, fn func() error) error {
+	release, err := s.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return fn()
+}
+
+// HostLimit returns the number of requests sent to host at once, or 0
+// when it is not capped.
+func (s *Scheduler) HostLimit(host string) int {
+	if s.perHost > 0 {
+		return s.perHost
+	}
+	if s.stats != nil {
+		return s.stats.Limit(host, cap(s.global))
+	}
+	return 0
+}
+
+// acquireHost waits until host is below its limit and counts a request to
+// it. It returns the number now in flight and the release func.
+func (s *Scheduler) acquireHost(ctx context.Context, host string) (int, func(), error) {
+	for {
+		s.hostMu.Lock()
+		gate, ok := s.hosts[host]
+		if !ok {
+			gate = &hostGate{released: make(chan struct{})}
+			s.hosts[host] = gate
+		}
+		if limit := s.HostLimit(host); limit <= 0 || gate.inFlight < limit {
+			gate.inFlight++
+			inFlight := gate.inFlight
+			s.hostMu.Unlock()
+			var once sync.Once
+			return inFlight, func() {
+				once.Do(func() {
+					s.hostMu.Lock()
+					gate.inFlight--
+					close(gate.released)
+					gate.released = make(chan struct{})
+					s.hostMu.Unlock()
+				})
+			}, nil
+		}
+		released := gate.released
+		s.hostMu.Unlock()
+
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return 0, nil, ctx.Err()
+		}
+	}
+}
+
+// Do sends req once its host is below its limit. The request counts
+// against the limit until the response body is closed, and body reads are
+// rate limited. With throughput stats, a body read to the end is recorded
+// as a sample.
+func (s *Scheduler) Do(req *http.Request) (*http.Response, error) {
+	concurrent := 0
+	release := func() {}
+	if s.perHost > 0 || s.stats != nil {
+		var err error
+		if concurrent, release, err = s.acquireHost(req.Context(), req.URL.Host); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	body := &scheduledBody{
+		ReadCloser: resp.Body,
+		ctx:        req.Context(),
+		limiter:    s.limiter,
+		release:    release,
+	}
+	// Rate limited reads say nothing about what the host can do.
+	if s.stats != nil && s.limiter == nil {
+		host, start := req.URL.Host, time.Now()
+		body.done = func(n int64) {
+			if n >= minSampleBytes {
+				s.stats.Record(host, Sample{Bytes: n, Seconds: time.Since(start).Seconds(), Concurrent: concurrent, At: start})
+			}
+		}
+	}
+	resp.Body = body
+	return resp, nil
+}
+
+type scheduledBody struct {
+	io.ReadCloser
+	ctx     context.Context
+	limiter *RateLimiter
+	release func()
+	// done, when set, is called with the number of bytes read once the
+	// body has been read to the end.
+	done func(n int64)
+	read int64
+}
+
+func (b *scheduledBody) Read(p []byte) (int, error) {
+	if b.limiter == nil {
+		n, err := b.ReadCloser.Read(p)
+		b.read += int64(n)
+		if err == io.EOF && b.done != nil {
+			b.done(b.read)
+			b.done = nil
+		}
+		return n, err
+	}
+	if burst := b.limiter.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		if waitErr := b.limiter.WaitN(b.ctx, n); waitErr != nil && err == nil {
+			err = waitErr
+		}
+	}
+	return n, err
+}
+
+func (b *scheduledBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
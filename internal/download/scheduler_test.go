@@ -0,0 +1,246 @@
+package download
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScheduler_AcquireRespectsConcurrency(t *testing.T) {
+	s := NewScheduler(Config{MaxConcurrent: 2})
+	if s.Concurrency() != 2 {
+		t.Fatalf("Expected concurrency 2, got %d", s.Concurrency())
+	}
+
+	var active, peak int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = s.Run(context.Background(), func() error {
+				n := atomic.AddInt32(&active, 1)
+				for {
+					p := atomic.LoadInt32(&peak)
+					if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				atomic.AddInt32(&active, -1)
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	if peak > 2 {
+		t.Errorf("Expected at most 2 concurrent jobs, saw %d", peak)
+	}
+}
+
+func TestScheduler_DefaultConcurrency(t *testing.T) {
+	s := NewScheduler(Config{})
+	if s.Concurrency() != DefaultConcurrency {
+		t.Errorf("Expected default concurrency %d, got %d", DefaultConcurrency, s.Concurrency())
+	}
+}
+
+func TestScheduler_AcquireHonorsContext(t *testing.T) {
+	s := NewScheduler(Config{MaxConcurrent: 1})
+	release, err := s.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := s.Acquire(ctx); err == nil {
+		t.Error("Expected Acquire to fail when context expires")
+	}
+}
+
+func TestScheduler_PerHostCap(t *testing.T) {
+	var active, peak int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&active, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&active, -1)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	s := NewScheduler(Config{MaxConcurrent: 8, MaxPerHost: 1})
+	s.SetHTTPClient(server.Client())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest("GET", server.URL, nil)
+			resp, err := s.Do(req)
+			if err != nil {
+				t.Errorf("Do failed: %v", err)
+				return
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	if peak != 1 {
+		t.Errorf("Expected at most 1 in-flight request per host, saw %d", peak)
+	}
+}
+
+func TestScheduler_BandwidthLimit(t *testing.T) {
+	payload := strings.Repeat("x", 3000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(payload))
+	}))
+	defer server.Close()
+
+	s := NewScheduler(Config{MaxBytesPerSecond: 1000})
+	s.SetHTTPClient(server.Client())
+
+	var slept time.Duration
+	clock := time.Now()
+	s.limiter.last = clock
+	s.limiter.now = func() time.Time { return clock }
+	s.limiter.sleep = func(ctx context.Context, d time.Duration) error {
+		slept += d
+		clock = clock.Add(d)
+		return nil
+	}
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	resp, err := s.Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if len(data) != len(payload) {
+		t.Fatalf("Expected %d bytes, got %d", len(payload), len(data))
+	}
+
+	// The bucket starts full (1000 bytes), so the remaining 2000 bytes
+	// must wait roughly two seconds at 1000 B/s.
+	if slept < 1900*time.Millisecond || slept > 2100*time.Millisecond {
+		t.Errorf("Expected ~2s of throttling, got %v", slept)
+	}
+}
+
+func TestRateLimiter_WaitNWithinBurst(t *testing.T) {
+	l := NewRateLimiter(100)
+	l.now = func() time.Time { return l.last }
+	l.sleep = func(ctx context.Context, d time.Duration) error {
+		t.Errorf("Unexpected sleep of %v", d)
+		return nil
+	}
+
+	if err := l.WaitN(context.Background(), 100); err != nil {
+		t.Fatalf("WaitN failed: %v", err)
+	}
+}
+
+func TestThroughputStats_Limit(t *testing.T) {
+	stats := LoadThroughputStats(filepath.Join(t.TempDir(), "throughput.json"))
+	if got := stats.Limit("ghcr.io", 8); got != 0 {
+		t.Fatalf("Expected no limit without history, got %d", got)
+	}
+
+	// One request at a time ran at 10 MB/s; two at once got 8 MB/s each.
+	stats.Record("ghcr.io", Sample{Bytes: 10 << 20, Seconds: 1, Concurrent: 1})
+	stats.Record("ghcr.io", Sample{Bytes: 8 << 20, Seconds: 1, Concurrent: 2})
+	if got := stats.Limit("ghcr.io", 8); got != 3 {
+		t.Errorf("Expected to probe one above the best level, got %d", got)
+	}
+	if got := stats.Limit("ghcr.io", 2); got != 2 {
+		t.Errorf("Expected the limit to stay under the ceiling, got %d", got)
+	}
+
+	// Three at once saturated the host at 4 MB/s each.
+	stats.Record("ghcr.io", Sample{Bytes: 4 << 20, Seconds: 1, Concurrent: 3})
+	if got := stats.Limit("ghcr.io", 8); got != 2 {
+		t.Errorf("Expected the best level to be kept, got %d", got)
+	}
+
+	reloaded := LoadThroughputStats(stats.path)
+	if got := reloaded.Limit("ghcr.io", 8); got != 2 {
+		t.Errorf("Expected samples to persist across loads, got limit %d", got)
+	}
+}
+
+func TestScheduler_AdaptivePerHostCap(t *testing.T) {
+	var active, peak int32
+	payload := strings.Repeat("x", minSampleBytes)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&active, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&active, -1)
+		w.Write([]byte(payload))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "throughput.json")
+	host := strings.TrimPrefix(server.URL, "http://")
+	seed := LoadThroughputStats(path)
+	seed.Record(host, Sample{Bytes: 10 << 20, Seconds: 1, Concurrent: 1})
+	seed.Record(host, Sample{Bytes: 2 << 20, Seconds: 1, Concurrent: 2})
+
+	s := NewScheduler(Config{MaxConcurrent: 8, ThroughputStatsPath: path})
+	s.SetHTTPClient(server.Client())
+	if got := s.HostLimit(host); got != 1 {
+		t.Fatalf("Expected a limit of 1 from the recorded samples, got %d", got)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest("GET", server.URL, nil)
+			resp, err := s.Do(req)
+			if err != nil {
+				t.Errorf("Do failed: %v", err)
+				return
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	if peak != 1 {
+		t.Errorf("Expected at most 1 in-flight request per host, saw %d", peak)
+	}
+	if got := len(LoadThroughputStats(path).hosts[host]); got != 6 {
+		t.Errorf("Expected the 4 downloads to be recorded, got %d samples", got)
+	}
+}
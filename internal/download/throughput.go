@@ -0,0 +1,104 @@
+package download
+
+import (
+	"encoding/json"
+	"fastbrew/internal/atomicfile"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Throughput samples are kept per host, most recent last.
+const maxSamplesPerHost = 32
+
+// minSampleBytes is the smallest transfer recorded; the time of smaller
+// ones is dominated by latency rather than bandwidth.
+const minSampleBytes = 256 << 10
+
+// Sample is one completed response body read from a host.
+type Sample struct {
+	Bytes   int64   `json:"bytes"`
+	Seconds float64 `json:"seconds"`
+	// Concurrent is the number of requests in flight to the host, this one
+	// included, when it was sent.
+	Concurrent int       `json:"concurrent"`
+	At         time.Time `json:"at"`
+}
+
+// ThroughputStats are per-host throughput samples, kept across runs in a
+// JSON file so each run starts from what earlier ones learned.
+type ThroughputStats struct {
+	mu    sync.Mutex
+	path  string
+	hosts map[string][]Sample
+}
+
+// LoadThroughputStats reads the samples stored at path. A missing or
+// unreadable file starts an empty history.
+func LoadThroughputStats(path string) *ThroughputStats {
+	t := &ThroughputStats{path: path, hosts: make(map[string][]Sample)}
+	if data, err := os.ReadFile(path); err == nil {
+		if json.Unmarshal(data, &t.hosts) != nil {
+			t.hosts = make(map[string][]Sample)
+		}
+	}
+	return t
+}
+
+// Record adds a sample for host and saves the history. Failing to save
+// only loses the sample.
+func (t *ThroughputStats) Record(host string, s Sample) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	samples := append(t.hosts[host], s)
+	if len(samples) > maxSamplesPerHost {
+		samples = samples[len(samples)-maxSamplesPerHost:]
+	}
+	t.hosts[host] = samples
+	if data, err := json.Marshal(t.hosts); err == nil {
+		_ = atomicfile.WriteFile(t.path, data, 0644)
+	}
+}
+
+// Limit returns how many requests to send to host at once, at most
+// ceiling, or 0 when there is no history for it. Samples are grouped by
+// their concurrency, and the level whose aggregate throughput (level times
+// the mean per-request rate) was highest wins. When that is the highest
+// level tried, the host may not be saturated yet, so one more is allowed.
+func (t *ThroughputStats) Limit(host string, ceiling int) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	samples := t.hosts[host]
+	if len(samples) == 0 || ceiling <= 0 {
+		return 0
+	}
+
+	rates := make(map[int]float64)
+	counts := make(map[int]int)
+	for _, s := range samples {
+		if s.Seconds <= 0 {
+			continue
+		}
+		level := min(max(s.Concurrent, 1), ceiling)
+		rates[level] += float64(s.Bytes) / s.Seconds
+		counts[level]++
+	}
+	if len(counts) == 0 {
+		return 0
+	}
+
+	levels := slices.Sorted(maps.Keys(counts))
+	best, bestAggregate := 0, 0.0
+	for _, level := range levels {
+		aggregate := float64(level) * rates[level] / float64(counts[level])
+		if aggregate > bestAggregate {
+			best, bestAggregate = level, aggregate
+		}
+	}
+	if best == levels[len(levels)-1] {
+		return min(best+1, ceiling)
+	}
+	return best
+}
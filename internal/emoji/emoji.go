@@ -0,0 +1,133 @@
+// Package emoji removes emoji from output, for terminals, fonts and log
+// collectors that cannot show them.
+package emoji
+
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	variationSelector = '\uFE0F'
+	zeroWidthJoiner   = '\u200D'
+	keycap            = '\u20E3'
+)
+
+// Strip removes emoji from s together with the spaces that follow them, so
+// "✅ Installed jq" becomes "Installed jq". Text symbols such as ✓, → and
+// box-drawing characters are kept.
+func Strip(s string) string {
+	out, _ := strip(s, false)
+	return out
+}
+
+// strip removes emoji from s. skipSpace reports whether s starts right
+// after an emoji, and the result reports the same for whatever follows s.
+func strip(s string, skipSpace bool) (string, bool) {
+	if !skipSpace && !containsEmoji(s) {
+		return s, false
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for i, r := range s {
+		switch {
+		case isEmoji(r) || isPresentation(r) || followedBySelector(s[i:]):
+			skipSpace = true
+		case r == ' ' && skipSpace:
+		default:
+			skipSpace = false
+			b.WriteRune(r)
+		}
+	}
+	return b.String(), skipSpace
+}
+
+func containsEmoji(s string) bool {
+	for i, r := range s {
+		if isEmoji(r) || isPresentation(r) || followedBySelector(s[i:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmoji reports whether r is in a block made up of pictographs.
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF:
+		return true
+	case r >= 0x2600 && r <= 0x26FF:
+		return true
+	case r >= 0x2700 && r <= 0x27BF:
+		// Check marks and crosses read fine as text.
+		return r < 0x2713 || r > 0x2718
+	case r >= 0x23E9 && r <= 0x23FA:
+		return true
+	case r >= 0x2B05 && r <= 0x2B55:
+		return true
+	}
+	return false
+}
+
+// isPresentation reports whether r only modifies the emoji before it.
+func isPresentation(r rune) bool {
+	return r == variationSelector || r == zeroWidthJoiner || r == keycap
+}
+
+// followedBySelector reports whether the rune starting s is requested in
+// emoji form, as with "ℹ️".
+func followedBySelector(s string) bool {
+	_, size := utf8.DecodeRuneInString(s)
+	next, _ := utf8.DecodeRuneInString(s[size:])
+	return next == variationSelector
+}
+
+// Writer strips emoji from everything written through it.
+type Writer struct {
+	w         io.Writer
+	pending   []byte
+	skipSpace bool
+}
+
+// NewWriter returns a Writer that passes output without emoji to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Write strips p and writes the result. A character split across writes
+// is held back until it is complete.
+func (w *Writer) Write(p []byte) (int, error) {
+	buf := append(w.pending, p...)
+	cut := len(buf)
+	if start := lastRuneStart(buf); !utf8.FullRune(buf[start:]) {
+		cut = start
+	}
+	out, skip := strip(string(buf[:cut]), w.skipSpace)
+	w.pending = append(w.pending[:0:0], buf[cut:]...)
+	w.skipSpace = skip
+	if _, err := io.WriteString(w.w, out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes any character still held back.
+func (w *Writer) Flush() error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+	out, _ := strip(string(w.pending), w.skipSpace)
+	w.pending = nil
+	_, err := io.WriteString(w.w, out)
+	return err
+}
+
+func lastRuneStart(buf []byte) int {
+	for i := len(buf) - 1; i >= 0 && i >= len(buf)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(buf[i]) {
+			return i
+		}
+	}
+	return 0
+}
@@ -0,0 +1,42 @@
+package emoji
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStrip(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"✅ Installed jq\n", "Installed jq\n"},
+		{"⚠️  HOMEBREW_PREFIX is set\n", "HOMEBREW_PREFIX is set\n"},
+		{"  🧽 Removed /tmp/x (4 B)\n", "  Removed /tmp/x (4 B)\n"},
+		{"ℹ️ note", "note"},
+		{"jq 1.7 → 1.7.1 ✓", "jq 1.7 → 1.7.1 ✓"},
+		{"├── wget", "├── wget"},
+		{"plain text", "plain text"},
+	}
+	for _, tt := range tests {
+		if got := Strip(tt.in); got != tt.want {
+			t.Errorf("Strip(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWriterSplitCharacter(t *testing.T) {
+	var out strings.Builder
+	w := NewWriter(&out)
+	data := []byte("📦 Fetching jq\n")
+	for i := range data {
+		if _, err := w.Write(data[i : i+1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "Fetching jq\n" {
+		t.Errorf("got %q, want %q", got, "Fetching jq\n")
+	}
+}
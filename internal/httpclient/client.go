@@ -24,7 +24,7 @@ func createClient() *http.Client {
 	transport := &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
 		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
+			Timeout:   DefaultConfig.DialTimeout,
 			KeepAlive: 30 * time.Second,
 		}).DialContext,
 		MaxIdleConns:          DefaultConfig.MaxIdleConns,
@@ -43,6 +43,6 @@ func createClient() *http.Client {
 
 	return &http.Client{
 		Transport: transport,
-		Timeout:   120 * time.Second,
+		Timeout:   DefaultConfig.Timeout,
 	}
 }
@@ -3,6 +3,11 @@ package httpclient
 import "time"
 
 type ClientConfig struct {
+	// DialTimeout limits how long a connection takes to establish.
+	DialTimeout time.Duration
+	// Timeout limits a whole request, including reading the body. Zero
+	// means no limit.
+	Timeout               time.Duration
 	MaxIdleConns          int
 	MaxIdleConnsPerHost   int
 	MaxConnsPerHost       int
@@ -15,6 +20,8 @@ type ClientConfig struct {
 }
 
 var DefaultConfig = ClientConfig{
+	DialTimeout:           30 * time.Second,
+	Timeout:               120 * time.Second,
 	MaxIdleConns:          100,
 	MaxIdleConnsPerHost:   100,
 	MaxConnsPerHost:       100,
@@ -0,0 +1,185 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// NetworkConfig holds the proxy and TLS settings applied to the shared
+// transport. The zero value keeps Go's defaults (proxy from the
+// HTTP_PROXY/HTTPS_PROXY/NO_PROXY environment, system roots).
+type NetworkConfig struct {
+	// ProxyURL forces every request through this proxy.
+	ProxyURL string
+	// NoProxy lists hosts that bypass the proxy, in NO_PROXY syntax. When
+	// empty and ProxyURL is set, the NO_PROXY environment variable is used.
+	NoProxy string
+	// CABundle is a PEM file whose certificates are trusted in addition to
+	// the system roots, e.g. a corporate TLS inspection CA.
+	CABundle string
+	// InsecureSkipVerify disables TLS certificate verification.
+	InsecureSkipVerify bool
+}
+
+var configureMu sync.Mutex
+
+// Configure applies cfg, along with the timeouts in DefaultConfig, to the
+// shared client. It can be called after Get, since callers keep the same
+// *http.Client.
+func Configure(cfg NetworkConfig) error {
+	proxy, err := proxyFunc(cfg)
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := tlsConfigFor(cfg)
+	if err != nil {
+		return err
+	}
+
+	configureMu.Lock()
+	defer configureMu.Unlock()
+
+	client := Get()
+	transport := createClient().Transport.(*http.Transport)
+	transport.Proxy = proxy
+	transport.TLSClientConfig = tlsConfig
+	if old, ok := client.Transport.(*http.Transport); ok {
+		old.CloseIdleConnections()
+	}
+	client.Transport = transport
+	client.Timeout = DefaultConfig.Timeout
+	return nil
+}
+
+func proxyFunc(cfg NetworkConfig) (func(*http.Request) (*url.URL, error), error) {
+	var fixed *url.URL
+	if cfg.ProxyURL != "" {
+		u, err := url.Parse(cfg.ProxyURL)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", cfg.ProxyURL)
+		}
+		fixed = u
+	}
+
+	noProxy := cfg.NoProxy
+	if noProxy == "" && fixed != nil {
+		noProxy = firstEnv("NO_PROXY", "no_proxy")
+	}
+	bypass := parseNoProxy(noProxy)
+
+	return func(req *http.Request) (*url.URL, error) {
+		if bypass.matches(req.URL) {
+			return nil, nil
+		}
+		if fixed != nil {
+			return fixed, nil
+		}
+		return http.ProxyFromEnvironment(req)
+	}, nil
+}
+
+func tlsConfigFor(cfg NetworkConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		SessionTicketsDisabled: false,
+		InsecureSkipVerify:     cfg.InsecureSkipVerify,
+	}
+	if cfg.CABundle == "" {
+		return tlsConfig, nil
+	}
+
+	pem, err := os.ReadFile(cfg.CABundle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", cfg.CABundle)
+	}
+	tlsConfig.RootCAs = pool
+	return tlsConfig, nil
+}
+
+// noProxyList matches hosts against NO_PROXY entries: "*", domain suffixes
+// ("example.com" or ".example.com"), IP addresses and CIDR ranges, each
+// optionally with a ":port".
+type noProxyList struct {
+	all     bool
+	domains []noProxyEntry
+	nets    []*net.IPNet
+}
+
+type noProxyEntry struct {
+	host string
+	port string
+}
+
+func parseNoProxy(value string) noProxyList {
+	var list noProxyList
+	for _, raw := range strings.Split(value, ",") {
+		entry := strings.ToLower(strings.TrimSpace(raw))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			list.all = true
+			continue
+		}
+		if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+			list.nets = append(list.nets, ipNet)
+			continue
+		}
+		host, port := entry, ""
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			host, port = h, p
+		}
+		list.domains = append(list.domains, noProxyEntry{host: strings.TrimPrefix(host, "."), port: port})
+	}
+	return list
+}
+
+func (l noProxyList) matches(u *url.URL) bool {
+	if l.all {
+		return true
+	}
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		for _, n := range l.nets {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+	}
+	for _, d := range l.domains {
+		if d.port != "" && d.port != port {
+			continue
+		}
+		if host == d.host || strings.HasSuffix(host, "."+d.host) {
+			return true
+		}
+	}
+	return false
+}
+
+func firstEnv(keys ...string) string {
+	for _, key := range keys {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
+	}
+	return ""
+}
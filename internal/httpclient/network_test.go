@@ -0,0 +1,123 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNoProxyMatches(t *testing.T) {
+	list := parseNoProxy("localhost, .corp.example.com, 10.0.0.0/8, registry.local:5000")
+
+	tests := []struct {
+		rawURL string
+		want   bool
+	}{
+		{"http://localhost/x", true},
+		{"https://corp.example.com/", true},
+		{"https://api.corp.example.com/", true},
+		{"https://notcorp.example.com/", false},
+		{"http://10.1.2.3/", true},
+		{"http://11.1.2.3/", false},
+		{"http://registry.local:5000/v2/", true},
+		{"https://registry.local/v2/", false},
+		{"https://ghcr.io/v2/", false},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.rawURL)
+		if got := list.matches(u); got != tt.want {
+			t.Errorf("matches(%s) = %v, want %v", tt.rawURL, got, tt.want)
+		}
+	}
+
+	if !parseNoProxy("*").matches(&url.URL{Scheme: "https", Host: "ghcr.io"}) {
+		t.Error("* should bypass every host")
+	}
+}
+
+func TestProxyFuncExplicitURL(t *testing.T) {
+	proxy, err := proxyFunc(NetworkConfig{ProxyURL: "http://proxy.example.com:3128", NoProxy: "internal.example.com"})
+	if err != nil {
+		t.Fatalf("proxyFunc failed: %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://ghcr.io/v2/", nil)
+	got, err := proxy(req)
+	if err != nil || got == nil || got.Host != "proxy.example.com:3128" {
+		t.Errorf("proxy(ghcr.io) = %v, %v; want proxy.example.com:3128", got, err)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "https://internal.example.com/", nil)
+	if got, _ := proxy(req); got != nil {
+		t.Errorf("proxy(internal.example.com) = %v, want direct", got)
+	}
+
+	if _, err := proxyFunc(NetworkConfig{ProxyURL: "not a url"}); err == nil {
+		t.Error("expected error for invalid proxy URL")
+	}
+}
+
+func TestTLSConfigCABundle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, []byte("not a certificate"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tlsConfigFor(NetworkConfig{CABundle: path}); err == nil {
+		t.Error("expected error for CA bundle without certificates")
+	}
+	if _, err := tlsConfigFor(NetworkConfig{CABundle: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Error("expected error for missing CA bundle")
+	}
+
+	cfg, err := tlsConfigFor(NetworkConfig{InsecureSkipVerify: true})
+	if err != nil || !cfg.InsecureSkipVerify {
+		t.Errorf("tlsConfigFor(insecure) = %+v, %v", cfg, err)
+	}
+}
+
+func TestConfigureKeepsClientInstance(t *testing.T) {
+	resetSingleton()
+	client := Get()
+
+	if err := Configure(NetworkConfig{ProxyURL: "http://proxy.example.com:3128"}); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	if Get() != client {
+		t.Error("Configure should keep the singleton client")
+	}
+	transport := client.Transport.(*http.Transport)
+	if transport.MaxIdleConns != DefaultConfig.MaxIdleConns {
+		t.Errorf("MaxIdleConns = %d, want %d", transport.MaxIdleConns, DefaultConfig.MaxIdleConns)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "https://ghcr.io/", nil)
+	if got, _ := transport.Proxy(req); got == nil || got.Host != "proxy.example.com:3128" {
+		t.Errorf("transport proxy = %v, want proxy.example.com:3128", got)
+	}
+
+	resetSingleton()
+}
+
+func TestConfigureAppliesTimeouts(t *testing.T) {
+	resetSingleton()
+	saved := DefaultConfig
+	defer func() {
+		DefaultConfig = saved
+		resetSingleton()
+	}()
+	client := Get()
+
+	DefaultConfig.ResponseHeaderTimeout = 5 * time.Second
+	DefaultConfig.Timeout = 0
+	if err := Configure(NetworkConfig{}); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	if client.Timeout != 0 {
+		t.Errorf("client Timeout = %v, want no limit", client.Timeout)
+	}
+	if got := client.Transport.(*http.Transport).ResponseHeaderTimeout; got != 5*time.Second {
+		t.Errorf("ResponseHeaderTimeout = %v, want 5s", got)
+	}
+}
@@ -0,0 +1,125 @@
+// Package lockfile serialises fastbrew processes that modify the Cellar,
+// the download cache or the package index.
+//
+// Locks are advisory (flock on Unix, LockFileEx on Windows) and held on an
+// open file, so the operating system releases them when the holder exits,
+// however it exits. The holder's pid is written into the file for error
+// messages.
+package lockfile
+
+import (
+	"context"
+	"errors"
+	"fastbrew/internal/paths"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrLocked is returned, wrapped in a *HeldError, while another process
+// holds the lock.
+var ErrLocked = errors.New("lock is held by another process")
+
+// pollInterval is how often Acquire retries a held lock.
+var pollInterval = 200 * time.Millisecond
+
+// HeldError reports the process holding a lock.
+type HeldError struct {
+	Path string
+	// PID is the holder's process id, or 0 if it could not be read.
+	PID int
+}
+
+func (e *HeldError) Error() string {
+	if e.PID > 0 {
+		return fmt.Sprintf("another fastbrew process is running (pid %d)", e.PID)
+	}
+	return "another fastbrew process is running"
+}
+
+func (e *HeldError) Unwrap() error { return ErrLocked }
+
+// Lock is an acquired lock. Release it when done.
+type Lock struct {
+	path string
+	file *os.File
+}
+
+// DefaultPath returns the lock shared by every fastbrew command that
+// changes installed packages, the download cache or the index.
+func DefaultPath() string {
+	return filepath.Join(paths.RuntimeDir(), "fastbrew.lock")
+}
+
+// TryAcquire takes the lock at path without waiting. It returns a
+// *HeldError if another process holds it.
+func TryAcquire(path string) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockFile(file); err != nil {
+		file.Close()
+		if errors.Is(err, ErrLocked) {
+			return nil, &HeldError{Path: path, PID: readPID(path)}
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+
+	if err := file.Truncate(0); err == nil {
+		file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return &Lock{path: path, file: file}, nil
+}
+
+// Acquire takes the lock at path, waiting until it is free or ctx is done.
+// When ctx ends first the last *HeldError is returned.
+func Acquire(ctx context.Context, path string) (*Lock, error) {
+	for {
+		lock, err := TryAcquire(path)
+		if !errors.Is(err, ErrLocked) {
+			return lock, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// Release clears the recorded pid and releases the lock. The file itself is
+// left in place: removing it would let a waiting process lock a file that
+// a newcomer can no longer see.
+func (l *Lock) Release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	l.file.Truncate(0)
+	err := unlockFile(l.file)
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	l.file = nil
+	return err
+}
+
+// readPID returns the pid recorded in the lock file at path, or 0.
+func readPID(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
@@ -0,0 +1,76 @@
+package lockfile
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTryAcquireReportsHolder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run", "fastbrew.lock")
+
+	lock, err := TryAcquire(path)
+	if err != nil {
+		t.Fatalf("TryAcquire failed: %v", err)
+	}
+
+	_, err = TryAcquire(path)
+	var held *HeldError
+	if !errors.As(err, &held) || !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected a HeldError, got %v", err)
+	}
+	if held.PID != os.Getpid() {
+		t.Fatalf("PID = %d, want %d", held.PID, os.Getpid())
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	again, err := TryAcquire(path)
+	if err != nil {
+		t.Fatalf("lock not released: %v", err)
+	}
+	again.Release()
+}
+
+func TestAcquireWaitsForRelease(t *testing.T) {
+	pollInterval = 10 * time.Millisecond
+	path := filepath.Join(t.TempDir(), "fastbrew.lock")
+
+	lock, err := TryAcquire(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		lock.Release()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	waited, err := Acquire(ctx, path)
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	waited.Release()
+}
+
+func TestAcquireGivesUpWhenContextEnds(t *testing.T) {
+	pollInterval = 10 * time.Millisecond
+	path := filepath.Join(t.TempDir(), "fastbrew.lock")
+
+	lock, err := TryAcquire(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if _, err := Acquire(ctx, path); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+}
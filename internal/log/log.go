@@ -0,0 +1,135 @@
+// Package log provides the structured debug log written alongside fastbrew's
+// console output. Nothing is recorded until Setup is given a log file, so the
+// console UI is unaffected by default.
+package log
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Options configures the log destination and verbosity.
+type Options struct {
+	// File is appended to when set; otherwise logging is disabled.
+	File  string
+	Level slog.Level
+}
+
+var (
+	mu      sync.RWMutex
+	handler slog.Handler = discardHandler{}
+	closer  io.Closer
+)
+
+// Setup routes every module logger to opts.File. It may be called again to
+// reconfigure; the previous file is closed.
+func Setup(opts Options) error {
+	var next slog.Handler = discardHandler{}
+	var nextCloser io.Closer
+
+	if opts.File != "" {
+		if err := os.MkdirAll(filepath.Dir(opts.File), 0755); err != nil {
+			return fmt.Errorf("failed to create log directory: %w", err)
+		}
+		f, err := os.OpenFile(opts.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open log file: %w", err)
+		}
+		next = slog.NewTextHandler(f, &slog.HandlerOptions{Level: opts.Level})
+		nextCloser = f
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if closer != nil {
+		closer.Close()
+	}
+	handler, closer = next, nextCloser
+	return nil
+}
+
+// Close flushes and closes the log file, if any, and disables logging.
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+	handler = discardHandler{}
+	if closer == nil {
+		return nil
+	}
+	err := closer.Close()
+	closer = nil
+	return err
+}
+
+// For returns a logger tagged with module=name. Loggers follow later Setup
+// calls, so they can be created before logging is configured.
+func For(module string) *slog.Logger {
+	return slog.New(moduleHandler{}).With("module", module)
+}
+
+// Discard returns a logger that drops every record.
+func Discard() *slog.Logger {
+	return slog.New(discardHandler{})
+}
+
+// ParseLevel parses "debug", "info", "warn" or "error".
+func ParseLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(value))); err != nil {
+		return slog.LevelInfo, fmt.Errorf("invalid log level %q (use debug, info, warn or error)", value)
+	}
+	return level, nil
+}
+
+func current() slog.Handler {
+	mu.RLock()
+	defer mu.RUnlock()
+	return handler
+}
+
+// moduleHandler forwards to whichever handler Setup installed most recently,
+// replaying the WithAttrs/WithGroup calls made on it.
+type moduleHandler struct {
+	wrap []func(slog.Handler) slog.Handler
+}
+
+func (h moduleHandler) resolve() slog.Handler {
+	next := current()
+	for _, w := range h.wrap {
+		next = w(next)
+	}
+	return next
+}
+
+func (h moduleHandler) with(w func(slog.Handler) slog.Handler) moduleHandler {
+	return moduleHandler{wrap: append(append([]func(slog.Handler) slog.Handler{}, h.wrap...), w)}
+}
+
+func (h moduleHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return current().Enabled(ctx, level)
+}
+
+func (h moduleHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.resolve().Handle(ctx, r)
+}
+
+func (h moduleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithAttrs(attrs) })
+}
+
+func (h moduleHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithGroup(name) })
+}
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (d discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return d }
+func (d discardHandler) WithGroup(string) slog.Handler           { return d }
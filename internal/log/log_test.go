@@ -0,0 +1,69 @@
+package log
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetupWritesModuleRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "fastbrew.log")
+	logger := For("brew").With("package", "wget")
+
+	if err := Setup(Options{File: path, Level: slog.LevelInfo}); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	defer Close()
+
+	logger.Debug("hidden")
+	logger.Info("download finished", "bytes", 42)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read log: %v", err)
+	}
+	out := string(data)
+	if strings.Contains(out, "hidden") {
+		t.Errorf("debug record written at info level: %s", out)
+	}
+	for _, want := range []string{"download finished", "module=brew", "package=wget", "bytes=42"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log missing %q: %s", want, out)
+		}
+	}
+}
+
+func TestDisabledWithoutFile(t *testing.T) {
+	if err := Setup(Options{}); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	if For("tap").Enabled(t.Context(), slog.LevelError) {
+		t.Error("expected logging to be disabled without a log file")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    slog.Level
+		wantErr bool
+	}{
+		{"debug", slog.LevelDebug, false},
+		{"INFO", slog.LevelInfo, false},
+		{" warn ", slog.LevelWarn, false},
+		{"error", slog.LevelError, false},
+		{"loud", slog.LevelInfo, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLevel(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseLevel(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseLevel(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
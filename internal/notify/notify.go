@@ -0,0 +1,52 @@
+// Package notify posts desktop notifications: through osascript on macOS
+// and notify-send (libnotify) on Linux.
+package notify
+
+import (
+	"errors"
+	"fastbrew/internal/cmdrunner"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// ErrUnsupported is returned on platforms without a notification command.
+var ErrUnsupported = errors.New("desktop notifications are not supported on " + runtime.GOOS)
+
+// Command returns the command that posts a notification on goos.
+func Command(goos, title, body string) (string, []string, error) {
+	switch goos {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(body), appleScriptString(title))
+		return "osascript", []string{"-e", script}, nil
+	case "linux":
+		return "notify-send", []string{"--app-name=fastbrew", title, body}, nil
+	}
+	return "", nil, ErrUnsupported
+}
+
+// Send posts a notification through runner.
+func Send(runner cmdrunner.CommandRunner, title, body string) error {
+	name, args, err := Command(runtime.GOOS, title, body)
+	if err != nil {
+		return err
+	}
+	if _, err := runner.Run(name, args...); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return fmt.Errorf("%s not found (on Linux it comes with libnotify): %w", name, err)
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return fmt.Errorf("%s: %s", name, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// appleScriptString quotes s as an AppleScript string literal.
+func appleScriptString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
@@ -0,0 +1,41 @@
+package notify
+
+import (
+	"errors"
+	"fastbrew/internal/cmdrunner"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestCommand(t *testing.T) {
+	name, args, err := Command("darwin", "fastbrew", `2 packages are "outdated"`)
+	if err != nil || name != "osascript" {
+		t.Fatalf("Command(darwin) = %s, %v", name, err)
+	}
+	if want := []string{"-e", `display notification "2 packages are \"outdated\"" with title "fastbrew"`}; !reflect.DeepEqual(args, want) {
+		t.Errorf("osascript args = %q, want %q", args, want)
+	}
+
+	name, args, err = Command("linux", "fastbrew", "wget, jq")
+	if err != nil || name != "notify-send" || !reflect.DeepEqual(args, []string{"--app-name=fastbrew", "fastbrew", "wget, jq"}) {
+		t.Errorf("Command(linux) = %s %q, %v", name, args, err)
+	}
+
+	if _, _, err := Command("windows", "fastbrew", "x"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Command(windows) error = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestSend(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("no notification command on " + runtime.GOOS)
+	}
+	runner := cmdrunner.NewMock()
+	if err := Send(runner, "fastbrew", "wget"); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if calls := runner.Calls(); len(calls) != 1 {
+		t.Errorf("expected one command, got %q", calls)
+	}
+}
@@ -0,0 +1,346 @@
+package oci
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fastbrew/internal/auth"
+	"fastbrew/internal/httpclient"
+	"fastbrew/internal/retry"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxManifestSize bounds manifest downloads; real ones are a few KB.
+const maxManifestSize = 4 << 20
+
+// Doer sends HTTP requests; *http.Client and download.Scheduler satisfy it.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Client talks to OCI registries. Its zero value is not usable; create
+// one with NewClient.
+type Client struct {
+	http Doer
+	// Credentials authenticates token requests and Basic-auth registries
+	// (anonymous when nil).
+	Credentials *auth.Store
+	// Rewrite, when set, maps request URLs onto a mirror.
+	Rewrite func(rawURL string) string
+
+	// Tokens caches bearer tokens; share it with other downloads from the
+	// same registries.
+	Tokens *TokenCache
+}
+
+// NewClient returns a client sending requests through doer, or through
+// the shared HTTP client when doer is nil.
+func NewClient(doer Doer) *Client {
+	if doer == nil {
+		doer = httpclient.Get()
+	}
+	return &Client{http: doer, Tokens: NewTokenCache()}
+}
+
+// StatusError is returned for unexpected registry responses.
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: %s", e.URL, e.Status)
+}
+
+// IsNotFound reports whether err is a 404 from the registry.
+func IsNotFound(err error) bool {
+	var se *StatusError
+	return errors.As(err, &se) && se.StatusCode == http.StatusNotFound
+}
+
+// GetManifest fetches the manifest or index ref points at. When ref is a
+// digest the content is verified against it.
+func (c *Client) GetManifest(ctx context.Context, ref Reference) (*Manifest, error) {
+	accept := strings.Join([]string{
+		MediaTypeImageIndex, MediaTypeImageManifest,
+		MediaTypeDockerManifestList, MediaTypeDockerManifest,
+	}, ", ")
+	resp, err := c.get(ctx, ref, ref.baseURL()+"/manifests/"+ref.Reference, accept)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxManifestSize {
+		return nil, retry.NonRetryable(fmt.Errorf("manifest %s is larger than %d bytes", ref, maxManifestSize))
+	}
+	if isDigest(ref.Reference) {
+		if err := verifyBytes(data, ref.Reference); err != nil {
+			return nil, fmt.Errorf("manifest %s: %w", ref, err)
+		}
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, retry.NonRetryable(fmt.Errorf("failed to parse manifest %s: %w", ref, err))
+	}
+	if m.MediaType == "" {
+		m.MediaType = resp.Header.Get("Content-Type")
+	}
+	return &m, nil
+}
+
+// ResolveBottle returns the bottle layer for bottleTag (e.g.
+// "arm64_sonoma" or "all") under ref, following an index to the matching
+// platform manifest. Homebrew names each manifest in its indexes
+// "<tag>.<bottle tag>".
+func (c *Client) ResolveBottle(ctx context.Context, ref Reference, bottleTag string) (Descriptor, error) {
+	m, err := c.GetManifest(ctx, ref)
+	if err != nil {
+		return Descriptor{}, err
+	}
+	if m.IsIndex() {
+		desc, ok := selectManifest(m.Manifests, ref.Reference, bottleTag)
+		if !ok {
+			return Descriptor{}, retry.NonRetryable(fmt.Errorf("%s has no bottle for %s", ref, bottleTag))
+		}
+		if m, err = c.GetManifest(ctx, ref.WithReference(desc.Digest)); err != nil {
+			return Descriptor{}, err
+		}
+	}
+	if len(m.Layers) == 0 {
+		return Descriptor{}, retry.NonRetryable(fmt.Errorf("manifest %s has no layers", ref))
+	}
+	// Bottle manifests carry the bottle tarball as their only layer.
+	return m.Layers[0], nil
+}
+
+func selectManifest(manifests []Descriptor, tag, bottleTag string) (Descriptor, bool) {
+	for _, d := range manifests {
+		if d.Annotations[AnnotationRefName] == tag+"."+bottleTag {
+			return d, true
+		}
+	}
+	for _, d := range manifests {
+		if strings.HasSuffix(d.Annotations[AnnotationRefName], "."+bottleTag) {
+			return d, true
+		}
+	}
+	return Descriptor{}, false
+}
+
+// BlobURL returns the upstream URL of a blob in ref's repository.
+func (c *Client) BlobURL(ref Reference, digest string) string {
+	return ref.baseURL() + "/blobs/" + digest
+}
+
+// FetchBlob streams a blob into w and verifies it against digest. It
+// returns the number of bytes written.
+func (c *Client) FetchBlob(ctx context.Context, ref Reference, digest string, w io.Writer) (int64, error) {
+	if !isDigest(digest) {
+		return 0, fmt.Errorf("unsupported digest %q", digest)
+	}
+	resp, err := c.get(ctx, ref, c.BlobURL(ref, digest), "")
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, h), resp.Body)
+	if err != nil {
+		return n, err
+	}
+	if err := verifyHash(h, digest); err != nil {
+		return n, fmt.Errorf("blob %s: %w", digest, err)
+	}
+	return n, nil
+}
+
+// get sends an authenticated GET, answering one auth challenge.
+func (c *Client) get(ctx context.Context, ref Reference, rawURL, accept string) (*http.Response, error) {
+	if c.Rewrite != nil {
+		rawURL = c.Rewrite(rawURL)
+	}
+	scope := TokenScope(rawURL)
+
+	send := func(authorization string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		return c.http.Do(req)
+	}
+
+	cached := c.Tokens.Cached(scope)
+	authorization := ""
+	if cached != "" {
+		authorization = "Bearer " + cached
+	}
+
+	resp, err := send(authorization)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("Www-Authenticate")
+		resp.Body.Close()
+
+		var host string
+		if u, err := url.Parse(rawURL); err == nil {
+			host = u.Hostname()
+		}
+		cred, hasCred := c.Credentials.Lookup(host)
+		switch {
+		case strings.HasPrefix(strings.TrimSpace(challenge), "Bearer "):
+			var credPtr *auth.Credential
+			if hasCred {
+				credPtr = &cred
+			}
+			token, err := c.Tokens.Token(ctx, c.http, scope, challenge, credPtr, cached)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get registry token: %w", err)
+			}
+			authorization = "Bearer " + token
+		case hasCred:
+			authorization = cred.Header()
+		default:
+			return nil, retry.NonRetryable(&StatusError{URL: rawURL, StatusCode: resp.StatusCode, Status: resp.Status})
+		}
+		if resp, err = send(authorization); err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		err := error(&StatusError{URL: rawURL, StatusCode: resp.StatusCode, Status: resp.Status})
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+			err = retry.NonRetryable(err)
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+func verifyBytes(data []byte, digest string) error {
+	h := sha256.New()
+	h.Write(data)
+	return verifyHash(h, digest)
+}
+
+func verifyHash(h hash.Hash, digest string) error {
+	got := "sha256:" + hex.EncodeToString(h.Sum(nil))
+	if !strings.EqualFold(got, digest) {
+		return retry.NonRetryable(fmt.Errorf("digest mismatch: expected %s, got %s", digest, got))
+	}
+	return nil
+}
+
+// FetchToken answers a Bearer challenge such as
+//
+//	Bearer realm="https://ghcr.io/token",service="ghcr.io",scope="repository:homebrew/core/wget:pull"
+//
+// by requesting a token from the realm. With cred the token is requested
+// with Basic auth, falling back to an anonymous token if the registry
+// rejects it, so a stale token in the environment cannot break public
+// downloads.
+func FetchToken(ctx context.Context, doer Doer, challenge string, cred *auth.Credential) (string, error) {
+	token, _, err := fetchToken(ctx, doer, challenge, cred)
+	return token, err
+}
+
+// fetchToken is FetchToken, also returning how long the token is valid.
+func fetchToken(ctx context.Context, doer Doer, challenge string, cred *auth.Credential) (string, time.Duration, error) {
+	params := parseChallenge(challenge)
+	realm := params["realm"]
+	if realm == "" {
+		return "", 0, fmt.Errorf("could not find realm in Www-Authenticate")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", realm, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	q := req.URL.Query()
+	for _, key := range []string{"service", "scope"} {
+		if v := params[key]; v != "" {
+			q.Set(key, v)
+		}
+	}
+	req.URL.RawQuery = q.Encode()
+
+	if cred != nil {
+		authed := req.Clone(ctx)
+		authed.Header.Set("Authorization", cred.BasicHeader())
+		if token, lifetime, err := requestToken(doer, authed); err == nil {
+			return token, lifetime, nil
+		}
+	}
+	return requestToken(doer, req)
+}
+
+func requestToken(doer Doer, req *http.Request) (string, time.Duration, error) {
+	resp, err := doer.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("failed to get token from %s: %s", req.URL, resp.Status)
+	}
+	var result struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxManifestSize)).Decode(&result); err != nil {
+		return "", 0, err
+	}
+	if result.Token == "" {
+		result.Token = result.AccessToken
+	}
+	if result.Token == "" {
+		return "", 0, fmt.Errorf("token response from %s has no token", req.URL)
+	}
+	lifetime := defaultTokenLifetime
+	if result.ExpiresIn > 0 {
+		lifetime = time.Duration(result.ExpiresIn) * time.Second
+	}
+	return result.Token, lifetime, nil
+}
+
+func parseChallenge(challenge string) map[string]string {
+	challenge = strings.TrimSpace(challenge)
+	challenge = strings.TrimPrefix(challenge, "Bearer ")
+
+	params := make(map[string]string)
+	for _, part := range strings.Split(challenge, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		params[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), "\"")
+	}
+	return params
+}
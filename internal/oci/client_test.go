@@ -0,0 +1,165 @@
+package oci
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// fakeRegistry serves one bottle for arm64_sonoma behind bearer auth, the
+// way ghcr.io lays out Homebrew bottles.
+type fakeRegistry struct {
+	*httptest.Server
+	bottle        []byte
+	manifest      []byte
+	index         []byte
+	tokenRequests int
+}
+
+func newFakeRegistry(t *testing.T, bottle []byte) *fakeRegistry {
+	t.Helper()
+	r := &fakeRegistry{bottle: bottle}
+	r.manifest, _ = json.Marshal(Manifest{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeImageManifest,
+		Layers: []Descriptor{{
+			MediaType: "application/vnd.oci.image.layer.v1.tar+gzip",
+			Digest:    digestOf(bottle),
+			Size:      int64(len(bottle)),
+		}},
+	})
+	r.index, _ = json.Marshal(Manifest{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeImageIndex,
+		Manifests: []Descriptor{
+			{Digest: "sha256:" + strings.Repeat("0", 64), Annotations: map[string]string{AnnotationRefName: "1.21.4.x86_64_linux"}},
+			{Digest: digestOf(r.manifest), Annotations: map[string]string{AnnotationRefName: "1.21.4.arm64_sonoma"}},
+		},
+	})
+
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/token" {
+			r.tokenRequests++
+			w.Write([]byte(`{"token":"t0k"}`))
+			return
+		}
+		if req.Header.Get("Authorization") != "Bearer t0k" {
+			w.Header().Set("Www-Authenticate", `Bearer realm="`+r.URL+`/token",service="ghcr.io",scope="repository:homebrew/core/wget:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch req.URL.Path {
+		case "/v2/homebrew/core/wget/manifests/1.21.4":
+			w.Write(r.index)
+		case "/v2/homebrew/core/wget/manifests/" + digestOf(r.manifest):
+			w.Write(r.manifest)
+		case "/v2/homebrew/core/wget/blobs/" + digestOf(bottle):
+			w.Write(r.bottle)
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *fakeRegistry) client() *Client {
+	c := NewClient(nil)
+	c.Rewrite = func(rawURL string) string {
+		return strings.Replace(rawURL, "https://ghcr.io", r.URL, 1)
+	}
+	return c
+}
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Reference
+	}{
+		{"ghcr.io/homebrew/core/wget:1.21.4", Reference{"ghcr.io", "homebrew/core/wget", "1.21.4"}},
+		{"registry.local:5000/bottles/jq@sha256:abc", Reference{"registry.local:5000", "bottles/jq", "sha256:abc"}},
+		{"ghcr.io/acme/tool", Reference{"ghcr.io", "acme/tool", "latest"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseReference(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseReference(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+		if got.String() != tt.in && tt.want.Reference != "latest" {
+			t.Errorf("String() = %q, want %q", got.String(), tt.in)
+		}
+	}
+	if _, err := ParseReference("wget"); err == nil {
+		t.Error("expected error for a reference without a registry")
+	}
+}
+
+func TestParseBlobURL(t *testing.T) {
+	ref, digest, ok := ParseBlobURL("https://ghcr.io/v2/homebrew/core/openssl/3/blobs/sha256:abc")
+	if !ok || ref.Registry != "ghcr.io" || ref.Repository != "homebrew/core/openssl/3" || digest != "sha256:abc" {
+		t.Fatalf("ParseBlobURL = %+v, %q, %v", ref, digest, ok)
+	}
+	if _, _, ok := ParseBlobURL("https://example.com/wget-1.21.4.arm64_sonoma.bottle.tar.gz"); ok {
+		t.Error("plain bottle URL should not parse as a blob URL")
+	}
+}
+
+func TestResolveBottleFollowsIndex(t *testing.T) {
+	bottle := []byte("bottle tarball")
+	reg := newFakeRegistry(t, bottle)
+	c := reg.client()
+
+	ref := Reference{Registry: "ghcr.io", Repository: "homebrew/core/wget", Reference: "1.21.4"}
+	layer, err := c.ResolveBottle(t.Context(), ref, "arm64_sonoma")
+	if err != nil {
+		t.Fatalf("ResolveBottle failed: %v", err)
+	}
+	if layer.Digest != digestOf(bottle) {
+		t.Fatalf("resolved %s, want %s", layer.Digest, digestOf(bottle))
+	}
+
+	var buf bytes.Buffer
+	if _, err := c.FetchBlob(t.Context(), ref, layer.Digest, &buf); err != nil {
+		t.Fatalf("FetchBlob failed: %v", err)
+	}
+	if buf.String() != string(bottle) {
+		t.Fatalf("unexpected blob %q", buf.String())
+	}
+	if reg.tokenRequests != 1 {
+		t.Errorf("expected the token to be reused, got %d token requests", reg.tokenRequests)
+	}
+
+	if _, err := c.ResolveBottle(t.Context(), ref, "arm64_tahoe"); err == nil {
+		t.Error("expected an error for a platform missing from the index")
+	}
+}
+
+func TestFetchBlobVerifiesDigest(t *testing.T) {
+	reg := newFakeRegistry(t, []byte("bottle tarball"))
+	reg.bottle = []byte("tampered")
+
+	ref := Reference{Registry: "ghcr.io", Repository: "homebrew/core/wget"}
+	var buf bytes.Buffer
+	_, err := reg.client().FetchBlob(t.Context(), ref, digestOf([]byte("bottle tarball")), &buf)
+	if err == nil || !strings.Contains(err.Error(), "digest mismatch") {
+		t.Fatalf("expected digest mismatch, got %v", err)
+	}
+}
+
+func TestGetManifestMissing(t *testing.T) {
+	reg := newFakeRegistry(t, []byte("bottle tarball"))
+	ref := Reference{Registry: "ghcr.io", Repository: "homebrew/core/wget", Reference: "9.9.9"}
+	if _, err := reg.client().GetManifest(t.Context(), ref); !IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
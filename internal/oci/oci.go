@@ -0,0 +1,133 @@
+// Package oci is a minimal client for the OCI distribution API, enough to
+// resolve and fetch Homebrew bottles from ghcr.io, registry mirrors and
+// private or air-gapped registries.
+package oci
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Media types accepted when fetching manifests.
+const (
+	MediaTypeImageIndex         = "application/vnd.oci.image.index.v1+json"
+	MediaTypeImageManifest      = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+)
+
+// AnnotationRefName names a manifest within an index. Homebrew sets it to
+// "<version>.<bottle tag>", e.g. "1.21.4.arm64_sonoma".
+const AnnotationRefName = "org.opencontainers.image.ref.name"
+
+// Reference names a repository in a registry and a tag or digest within
+// it, e.g. ghcr.io/homebrew/core/wget:1.21.4.
+type Reference struct {
+	Registry   string
+	Repository string
+	// Reference is a tag or a "sha256:..." digest.
+	Reference string
+}
+
+// ParseReference parses "registry/repository:tag" or
+// "registry/repository@sha256:...".
+func ParseReference(s string) (Reference, error) {
+	registry, rest, ok := strings.Cut(s, "/")
+	if !ok || registry == "" || rest == "" {
+		return Reference{}, fmt.Errorf("invalid OCI reference %q", s)
+	}
+	ref := Reference{Registry: registry}
+	if repo, digest, ok := strings.Cut(rest, "@"); ok {
+		ref.Repository, ref.Reference = repo, digest
+	} else if i := strings.LastIndex(rest, ":"); i > 0 {
+		ref.Repository, ref.Reference = rest[:i], rest[i+1:]
+	} else {
+		ref.Repository, ref.Reference = rest, "latest"
+	}
+	if ref.Repository == "" || ref.Reference == "" {
+		return Reference{}, fmt.Errorf("invalid OCI reference %q", s)
+	}
+	return ref, nil
+}
+
+// ParseBlobURL splits a registry blob URL such as
+// https://ghcr.io/v2/homebrew/core/wget/blobs/sha256:abc into the
+// repository reference and the blob digest.
+func ParseBlobURL(rawURL string) (Reference, string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return Reference{}, "", false
+	}
+	path, ok := strings.CutPrefix(u.Path, "/v2/")
+	if !ok {
+		return Reference{}, "", false
+	}
+	i := strings.LastIndex(path, "/blobs/")
+	if i <= 0 {
+		return Reference{}, "", false
+	}
+	digest := path[i+len("/blobs/"):]
+	if !strings.HasPrefix(digest, "sha256:") {
+		return Reference{}, "", false
+	}
+	return Reference{Registry: u.Host, Repository: path[:i], Reference: digest}, digest, true
+}
+
+// String formats the reference the way ParseReference accepts it.
+func (r Reference) String() string {
+	sep := ":"
+	if isDigest(r.Reference) {
+		sep = "@"
+	}
+	return r.Registry + "/" + r.Repository + sep + r.Reference
+}
+
+// WithReference returns r pointing at another tag or digest.
+func (r Reference) WithReference(reference string) Reference {
+	r.Reference = reference
+	return r
+}
+
+func (r Reference) baseURL() string {
+	return "https://" + r.Registry + "/v2/" + r.Repository
+}
+
+// Descriptor points at a manifest or blob.
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *Platform         `json:"platform,omitempty"`
+}
+
+// Platform describes what a manifest in an index was built for.
+type Platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	OSVersion    string `json:"os.version,omitempty"`
+}
+
+// Manifest is an image manifest or, when Manifests is set, an index
+// (manifest list) of per-platform manifests.
+type Manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	Manifests     []Descriptor      `json:"manifests,omitempty"`
+	Layers        []Descriptor      `json:"layers,omitempty"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// IsIndex reports whether m lists other manifests.
+func (m *Manifest) IsIndex() bool {
+	switch m.MediaType {
+	case MediaTypeImageIndex, MediaTypeDockerManifestList:
+		return true
+	}
+	return len(m.Manifests) > 0
+}
+
+func isDigest(s string) bool {
+	return strings.HasPrefix(s, "sha256:")
+}
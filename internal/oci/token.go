@@ -0,0 +1,134 @@
+package oci
+
+import (
+	"context"
+	"fastbrew/internal/auth"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultTokenLifetime is assumed for tokens issued without expires_in,
+// as the Docker token specification prescribes.
+const defaultTokenLifetime = 60 * time.Second
+
+// tokenExpiryMargin retires tokens this long before they expire, so a
+// request is not sent with a token that lapses on the way.
+const tokenExpiryMargin = 10 * time.Second
+
+// TokenCache holds registry bearer tokens by scope until they expire. It
+// is safe for concurrent use: parallel downloads from one repository share
+// a single token request.
+type TokenCache struct {
+	mu      sync.Mutex
+	entries map[string]*cachedToken
+	now     func() time.Time
+}
+
+type cachedToken struct {
+	token   string
+	expires time.Time
+	err     error
+	// done is closed once token or err is set.
+	done chan struct{}
+}
+
+// NewTokenCache returns an empty cache.
+func NewTokenCache() *TokenCache {
+	return &TokenCache{entries: make(map[string]*cachedToken), now: time.Now}
+}
+
+// TokenScope returns the cache key of the token that authorizes rawURL:
+// the registry host and repository of a /v2/<repository>/blobs/ or
+// /manifests/ URL. It returns "" for other URLs, whose tokens are not
+// cached.
+func TokenScope(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	path, ok := strings.CutPrefix(u.Path, "/v2/")
+	if !ok {
+		return ""
+	}
+	for _, sep := range []string{"/blobs/", "/manifests/"} {
+		if i := strings.LastIndex(path, sep); i > 0 {
+			return u.Host + "/" + path[:i]
+		}
+	}
+	return ""
+}
+
+// Cached returns the unexpired token of scope, or "" when there is none
+// yet.
+func (tc *TokenCache) Cached(scope string) string {
+	if scope == "" {
+		return ""
+	}
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	e := tc.entries[scope]
+	if e == nil {
+		return ""
+	}
+	select {
+	case <-e.done:
+		if e.err == nil && tc.now().Before(e.expires) {
+			return e.token
+		}
+	default:
+	}
+	return ""
+}
+
+// Token returns a token for scope, answering challenge with FetchToken
+// unless an unexpired one is cached. rejected is the token the registry
+// just answered 401 to, if any; it is never returned, so a token revoked
+// or expired early is refreshed mid-download. Callers asking for the same
+// scope while a token is being fetched wait for it.
+func (tc *TokenCache) Token(ctx context.Context, doer Doer, scope, challenge string, cred *auth.Credential, rejected string) (string, error) {
+	if scope == "" {
+		token, _, err := fetchToken(ctx, doer, challenge, cred)
+		return token, err
+	}
+
+	tc.mu.Lock()
+	e := tc.entries[scope]
+	if e != nil {
+		select {
+		case <-e.done:
+			if e.err != nil || e.token == rejected || !tc.now().Before(e.expires) {
+				e = nil
+			}
+		default:
+		}
+	}
+	if e == nil {
+		e = &cachedToken{done: make(chan struct{})}
+		tc.entries[scope] = e
+		tc.mu.Unlock()
+
+		// The token is shared, so one caller giving up must not fail the
+		// others waiting for it.
+		token, lifetime, err := fetchToken(context.WithoutCancel(ctx), doer, challenge, cred)
+
+		tc.mu.Lock()
+		e.token, e.err = token, err
+		e.expires = tc.now().Add(lifetime - min(tokenExpiryMargin, lifetime/2))
+		if err != nil && tc.entries[scope] == e {
+			delete(tc.entries, scope)
+		}
+		close(e.done)
+		tc.mu.Unlock()
+		return token, err
+	}
+	tc.mu.Unlock()
+
+	select {
+	case <-e.done:
+		return e.token, e.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
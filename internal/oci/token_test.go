@@ -0,0 +1,107 @@
+package oci
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokenScope(t *testing.T) {
+	tests := map[string]string{
+		"https://ghcr.io/v2/homebrew/core/wget/blobs/sha256:abc": "ghcr.io/homebrew/core/wget",
+		"https://ghcr.io/v2/homebrew/core/wget/manifests/1.21.4": "ghcr.io/homebrew/core/wget",
+		"https://ghcr.io/token":                                  "",
+		"https://example.com/bottle.tar.gz":                      "",
+	}
+	for rawURL, want := range tests {
+		if got := TokenScope(rawURL); got != want {
+			t.Errorf("TokenScope(%q) = %q, want %q", rawURL, got, want)
+		}
+	}
+}
+
+// tokenServer issues numbered tokens valid for expiresIn seconds.
+func tokenServer(t *testing.T, expiresIn int, delay time.Duration) (string, *atomic.Int32) {
+	t.Helper()
+	var issued atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		n := issued.Add(1)
+		fmt.Fprintf(w, `{"token":"t%d","expires_in":%d}`, n, expiresIn)
+	}))
+	t.Cleanup(server.Close)
+	return `Bearer realm="` + server.URL + `/token",service="ghcr.io",scope="repository:homebrew/core/wget:pull"`, &issued
+}
+
+func TestTokenCacheSharesConcurrentRequests(t *testing.T) {
+	challenge, issued := tokenServer(t, 5, 50*time.Millisecond)
+	tc := NewTokenCache()
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 8)
+	for i := range tokens {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := tc.Token(t.Context(), http.DefaultClient, "ghcr.io/homebrew/core/wget", challenge, nil, "")
+			if err != nil {
+				t.Error(err)
+			}
+			tokens[i] = token
+		}()
+	}
+	wg.Wait()
+
+	if issued.Load() != 1 {
+		t.Errorf("Expected one token request, got %d", issued.Load())
+	}
+	for _, token := range tokens {
+		if token != "t1" {
+			t.Errorf("Expected every caller to get t1, got %v", tokens)
+			break
+		}
+	}
+	if got := tc.Cached("ghcr.io/homebrew/core/wget"); got != "t1" {
+		t.Errorf("Cached() = %q, want t1", got)
+	}
+}
+
+func TestTokenCacheExpiryAndRejection(t *testing.T) {
+	challenge, issued := tokenServer(t, 9, 0)
+	tc := NewTokenCache()
+	now := time.Now()
+	tc.now = func() time.Time { return now }
+	scope := "ghcr.io/homebrew/core/wget"
+
+	token, err := tc.Token(t.Context(), http.DefaultClient, scope, challenge, nil, "")
+	if err != nil || token != "t1" {
+		t.Fatalf("Token() = %q, %v", token, err)
+	}
+
+	// expires_in is 9s, so the token is retired after 4.5s, half of it.
+	now = now.Add(4 * time.Second)
+	if got := tc.Cached(scope); got != "t1" {
+		t.Errorf("Cached() = %q before expiry, want t1", got)
+	}
+	now = now.Add(time.Second)
+	if got := tc.Cached(scope); got != "" {
+		t.Errorf("Cached() = %q after expiry, want none", got)
+	}
+	if token, _ := tc.Token(t.Context(), http.DefaultClient, scope, challenge, nil, ""); token != "t2" {
+		t.Errorf("Expected an expired token to be refreshed, got %q", token)
+	}
+
+	if token, _ := tc.Token(t.Context(), http.DefaultClient, scope, challenge, nil, "t2"); token != "t3" {
+		t.Errorf("Expected a rejected token to be refreshed, got %q", token)
+	}
+	if token, _ := tc.Token(t.Context(), http.DefaultClient, scope, challenge, nil, "t2"); token != "t3" {
+		t.Errorf("Expected the refreshed token to be reused, got %q", token)
+	}
+	if issued.Load() != 3 {
+		t.Errorf("Expected 3 token requests, got %d", issued.Load())
+	}
+}
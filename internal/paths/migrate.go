@@ -0,0 +1,122 @@
+package paths
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// legacyRunDir holds the lock and daemon socket in ~/.fastbrew. It is never
+// moved, since a process started by an older release may still be using
+// it; its files are recreated under RuntimeDir as needed.
+const legacyRunDir = "run"
+
+// Move is one ~/.fastbrew entry and its current location.
+type Move struct {
+	From string
+	To   string
+}
+
+// legacyLayout maps entries of ~/.fastbrew to where they live now.
+func legacyLayout() map[string]string {
+	state := StateDir()
+	return map[string]string{
+		"config.json":  filepath.Join(ConfigDir(), "config.json"),
+		"services":     filepath.Join(ConfigDir(), "services"),
+		"cache":        CacheDir(),
+		"pinned":       filepath.Join(state, "pinned"),
+		"taps.json":    filepath.Join(state, "taps.json"),
+		"Taps":         filepath.Join(state, "Taps"),
+		"transactions": filepath.Join(state, "transactions"),
+	}
+}
+
+// Migrate moves data left in ~/.fastbrew by older releases to the current
+// directories, and removes ~/.fastbrew once nothing is left in it. Entries
+// whose new location already has data are left in place; Leftovers reports
+// them. It does nothing when ~/.fastbrew does not exist, so it is cheap to
+// call on every start.
+func Migrate() ([]Move, error) {
+	legacy := LegacyDir()
+	entries, err := os.ReadDir(legacy)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	layout := legacyLayout()
+	var moved []Move
+	var firstErr error
+	for _, entry := range entries {
+		to, ok := layout[entry.Name()]
+		if !ok {
+			continue
+		}
+		from := filepath.Join(legacy, entry.Name())
+		if !isEmptyOrMissing(to) {
+			continue
+		}
+		if err := move(from, to); err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("moving %s to %s: %w", from, to, err)
+			}
+			continue
+		}
+		moved = append(moved, Move{From: from, To: to})
+	}
+
+	// Only succeeds once everything, including the run directory, is gone.
+	os.Remove(filepath.Join(legacy, legacyRunDir))
+	os.Remove(legacy)
+	return moved, firstErr
+}
+
+// Leftovers lists data still in ~/.fastbrew beside the current
+// directories, typically because both held data when Migrate ran. Such
+// split state means changes in one copy are invisible to the other.
+func Leftovers() []Move {
+	entries, err := os.ReadDir(LegacyDir())
+	if err != nil {
+		return nil
+	}
+	layout := legacyLayout()
+	var out []Move
+	for _, entry := range entries {
+		if entry.Name() == legacyRunDir {
+			continue
+		}
+		from := filepath.Join(LegacyDir(), entry.Name())
+		if entry.IsDir() && isEmptyOrMissing(from) {
+			continue
+		}
+		out = append(out, Move{From: from, To: layout[entry.Name()]})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].From < out[j].From })
+	return out
+}
+
+func move(from, to string) error {
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	// An empty directory left by an earlier run is in the way of rename.
+	os.Remove(to)
+	return os.Rename(from, to)
+}
+
+// isEmptyOrMissing reports whether path does not exist or is an empty
+// directory.
+func isEmptyOrMissing(path string) bool {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return true
+	}
+	if err != nil || !info.IsDir() {
+		return false
+	}
+	entries, err := os.ReadDir(path)
+	return err == nil && len(entries) == 0
+}
@@ -0,0 +1,78 @@
+// Package paths locates fastbrew's configuration, cache and state.
+//
+// On Linux and other Unix systems the XDG base directory variables are
+// followed: configuration in $XDG_CONFIG_HOME/fastbrew (~/.config/fastbrew),
+// downloads and the index in $XDG_CACHE_HOME/fastbrew (~/.cache/fastbrew),
+// and pins, taps and transaction journals in $XDG_STATE_HOME/fastbrew
+// (~/.local/state/fastbrew). On macOS the defaults are
+// ~/Library/Application Support/fastbrew and ~/Library/Caches/fastbrew,
+// though XDG variables that are set explicitly still apply. Without
+// Homebrew, packages go to a prefix fastbrew manages (PrefixDir).
+//
+// Older releases kept everything in ~/.fastbrew; Migrate moves it.
+package paths
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+const appName = "fastbrew"
+
+// ConfigDir holds config.json.
+func ConfigDir() string {
+	return baseDir("XDG_CONFIG_HOME", ".config", "Library/Application Support", os.UserConfigDir)
+}
+
+// CacheDir holds downloads and the package index, which can be fetched
+// again if lost.
+func CacheDir() string {
+	return baseDir("XDG_CACHE_HOME", ".cache", "Library/Caches", os.UserCacheDir)
+}
+
+// StateDir holds pinned packages, install reasons, the tap registry, taps
+// cloned by fastbrew, transaction journals and the recorded outdated check.
+func StateDir() string {
+	return baseDir("XDG_STATE_HOME", ".local/state", "Library/Application Support", os.UserCacheDir)
+}
+
+// RuntimeDir holds the process lock, the daemon socket and its log:
+// $XDG_RUNTIME_DIR/fastbrew when set, otherwise the run directory under
+// StateDir.
+func RuntimeDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, appName)
+	}
+	return filepath.Join(StateDir(), "run")
+}
+
+// PrefixDir is the prefix fastbrew manages itself on machines without
+// Homebrew: prefix under $XDG_DATA_HOME/fastbrew (~/.local/share/fastbrew).
+func PrefixDir() string {
+	return filepath.Join(baseDir("XDG_DATA_HOME", ".local/share", "Library/Application Support", os.UserConfigDir), "prefix")
+}
+
+// LegacyDir is ~/.fastbrew, where older releases kept everything.
+func LegacyDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".fastbrew")
+}
+
+// baseDir resolves one base directory. Relative XDG values are ignored, as
+// the specification requires.
+func baseDir(env, unixDefault, darwinDefault string, windowsDir func() (string, error)) string {
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return filepath.Join(dir, appName)
+	}
+	switch runtime.GOOS {
+	case "windows":
+		if dir, err := windowsDir(); err == nil {
+			return filepath.Join(dir, appName)
+		}
+	case "darwin":
+		unixDefault = darwinDefault
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, filepath.FromSlash(unixDefault), appName)
+}
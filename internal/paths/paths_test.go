@@ -0,0 +1,123 @@
+package paths
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func setHome(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, env := range []string{"XDG_CONFIG_HOME", "XDG_CACHE_HOME", "XDG_STATE_HOME", "XDG_DATA_HOME", "XDG_RUNTIME_DIR"} {
+		t.Setenv(env, "")
+	}
+	return home
+}
+
+func TestDirsFollowXDG(t *testing.T) {
+	setHome(t)
+	t.Setenv("XDG_CONFIG_HOME", "/xdg/config")
+	t.Setenv("XDG_CACHE_HOME", "/xdg/cache")
+	t.Setenv("XDG_STATE_HOME", "relative/is/ignored")
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+
+	if got := ConfigDir(); got != filepath.Join("/xdg/config", "fastbrew") {
+		t.Errorf("ConfigDir() = %q", got)
+	}
+	if got := CacheDir(); got != filepath.Join("/xdg/cache", "fastbrew") {
+		t.Errorf("CacheDir() = %q", got)
+	}
+	if got := RuntimeDir(); got != filepath.Join("/run/user/1000", "fastbrew") {
+		t.Errorf("RuntimeDir() = %q", got)
+	}
+	if got := StateDir(); !filepath.IsAbs(got) {
+		t.Errorf("StateDir() = %q, want an absolute default", got)
+	}
+}
+
+func TestDefaultDirs(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("XDG defaults apply on Linux")
+	}
+	home := setHome(t)
+
+	want := map[string]string{
+		ConfigDir():  filepath.Join(home, ".config", "fastbrew"),
+		CacheDir():   filepath.Join(home, ".cache", "fastbrew"),
+		StateDir():   filepath.Join(home, ".local", "state", "fastbrew"),
+		RuntimeDir(): filepath.Join(home, ".local", "state", "fastbrew", "run"),
+		PrefixDir():  filepath.Join(home, ".local", "share", "fastbrew", "prefix"),
+	}
+	for got, expected := range want {
+		if got != expected {
+			t.Errorf("got %q, want %q", got, expected)
+		}
+	}
+}
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateMovesLegacyData(t *testing.T) {
+	home := setHome(t)
+	legacy := filepath.Join(home, ".fastbrew")
+	writeFile(t, filepath.Join(legacy, "config.json"), "{}")
+	writeFile(t, filepath.Join(legacy, "pinned"), "jq\n")
+	writeFile(t, filepath.Join(legacy, "cache", "formula.json.zst"), "index")
+
+	moved, err := Migrate()
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if len(moved) != 3 {
+		t.Errorf("expected 3 moves, got %+v", moved)
+	}
+	for _, path := range []string{
+		filepath.Join(ConfigDir(), "config.json"),
+		filepath.Join(StateDir(), "pinned"),
+		filepath.Join(CacheDir(), "formula.json.zst"),
+	} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s should exist after migration: %v", path, err)
+		}
+	}
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Errorf("empty legacy directory should be removed, stat err = %v", err)
+	}
+
+	// A second run has nothing to do.
+	if moved, err := Migrate(); err != nil || len(moved) != 0 {
+		t.Errorf("second Migrate = %+v, %v", moved, err)
+	}
+}
+
+func TestMigrateKeepsConflictsAndReportsThem(t *testing.T) {
+	home := setHome(t)
+	legacy := filepath.Join(home, ".fastbrew")
+	writeFile(t, filepath.Join(legacy, "pinned"), "old\n")
+	writeFile(t, filepath.Join(legacy, "run", "fastbrew.lock"), "123\n")
+	writeFile(t, filepath.Join(StateDir(), "pinned"), "new\n")
+
+	moved, err := Migrate()
+	if err != nil || len(moved) != 0 {
+		t.Fatalf("Migrate = %+v, %v; want no moves", moved, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(StateDir(), "pinned")); string(data) != "new\n" {
+		t.Errorf("existing data was overwritten: %q", data)
+	}
+
+	leftovers := Leftovers()
+	if len(leftovers) != 1 || leftovers[0].From != filepath.Join(legacy, "pinned") || leftovers[0].To != filepath.Join(StateDir(), "pinned") {
+		t.Errorf("Leftovers() = %+v, want only the conflicting pinned file", leftovers)
+	}
+}
@@ -18,16 +18,19 @@ const (
 	EventDownloadError EventType = "download_error"
 )
 
-// ProgressEvent represents a single progress update event
+// ProgressEvent represents a single progress update event. Its JSON form
+// is what --progress-json prints and the daemon's progress_watch sends.
 type ProgressEvent struct {
-	Type    EventType
-	ID      string
-	Message string
-	Current int64
-	Total   int64
+	Type    EventType `json:"type"`
+	ID      string    `json:"id"`
+	Message string    `json:"message,omitempty"`
+	Current int64     `json:"current"`
+	// Total is -1 when the size is unknown.
+	Total int64 `json:"total"`
 }
 
-// CalculatePercentage returns the progress percentage (0-100)
+// CalculatePercentage returns the progress percentage (0-100), or 0 when
+// the total size is unknown (-1 or 0).
 func (e ProgressEvent) CalculatePercentage() float64 {
 	if e.Total <= 0 {
 		return 0
@@ -0,0 +1,32 @@
+package progress
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// JSONRenderer writes progress events as newline-delimited JSON, one
+// ProgressEvent per line, for tools that show their own progress.
+type JSONRenderer struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONRenderer creates a renderer writing to out.
+func NewJSONRenderer(out io.Writer) *JSONRenderer {
+	return &JSONRenderer{enc: json.NewEncoder(out)}
+}
+
+// Handle writes a single progress event.
+func (r *JSONRenderer) Handle(event ProgressEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_ = r.enc.Encode(event)
+}
+
+// Attach subscribes the renderer to m's event bus. The returned function
+// unsubscribes and writes any events still queued.
+func (r *JSONRenderer) Attach(m *Manager) func() {
+	return m.Follow("json-renderer", r.Handle)
+}
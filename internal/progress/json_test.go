@@ -0,0 +1,41 @@
+package progress
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestJSONRendererWritesOneEventPerLine(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewManager()
+	m.StartEventRouter()
+	defer m.Close()
+
+	detach := NewJSONRenderer(&buf).Attach(m)
+	tracker := m.Register("wget", "https://example.com/wget")
+	tracker.Start(100)
+	tracker.Complete()
+
+	// Let the router publish the queued events before detaching.
+	time.Sleep(50 * time.Millisecond)
+	detach()
+
+	var types []EventType
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var event ProgressEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("line %q is not a ProgressEvent: %v", scanner.Text(), err)
+		}
+		if event.ID != "wget" {
+			t.Errorf("expected id wget, got %q", event.ID)
+		}
+		types = append(types, event.Type)
+	}
+	if len(types) < 2 || types[0] != EventDownloadStart || types[len(types)-1] != EventDownloadComplete {
+		t.Fatalf("expected start ... complete events, got %v", types)
+	}
+}
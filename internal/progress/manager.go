@@ -1,6 +1,7 @@
 package progress
 
 import (
+	"fmt"
 	"sync"
 	"time"
 )
@@ -11,6 +12,16 @@ type Manager struct {
 	trackers map[string]ProgressTracker
 	events   chan ProgressEvent
 	eventBus *EventBus
+	expected int
+	finished finishedDownloads
+}
+
+// finishedDownloads tallies unregistered trackers, so aggregate progress
+// keeps counting downloads that are done.
+type finishedDownloads struct {
+	completed int
+	failed    int
+	bytes     int64
 }
 
 // NewManager creates a new progress Manager instance
@@ -41,11 +52,34 @@ func (m *Manager) Register(id, url string) ProgressTracker {
 	return tracker
 }
 
-// Unregister removes a progress tracker from the manager
+// Unregister removes a progress tracker from the manager. It still counts
+// towards GetAggregateProgress: as failed if it failed, otherwise as
+// completed, including trackers never started because the file was
+// already cached.
 func (m *Manager) Unregister(id string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	tracker, ok := m.trackers[id]
+	if !ok {
+		return
+	}
 	delete(m.trackers, id)
+
+	progress := tracker.GetDownloadProgress()
+	if progress.Error != nil {
+		m.finished.failed++
+		return
+	}
+	m.finished.completed++
+	m.finished.bytes += progress.TotalBytes
+}
+
+// Expect records that n more downloads are planned, so aggregate progress
+// can report "X of N" before all of them have started.
+func (m *Manager) Expect(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expected += n
 }
 
 // GetTracker returns a tracker by ID, or nil if not found
@@ -108,6 +142,8 @@ func (m *Manager) Close() {
 
 	close(m.events)
 	m.trackers = make(map[string]ProgressTracker)
+	m.expected = 0
+	m.finished = finishedDownloads{}
 }
 
 // AggregateProgress calculates overall progress across all downloads
@@ -120,6 +156,7 @@ type AggregateProgress struct {
 	DownloadedBytes    int64
 	OverallPercentage  float64
 	AverageSpeed       float64 // bytes per second
+	Speed              float64 // combined bytes per second of active downloads
 }
 
 // GetAggregateProgress calculates the aggregate progress of all trackers
@@ -127,13 +164,14 @@ func (m *Manager) GetAggregateProgress() AggregateProgress {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	var totalBytes, downloadedBytes int64
+	totalBytes, downloadedBytes := m.finished.bytes, m.finished.bytes
 	var totalSpeed float64
-	var activeCount, completedCount, failedCount int
+	activeCount, completedCount, failedCount := 0, m.finished.completed, m.finished.failed
 
 	for _, tracker := range m.trackers {
 		progress := tracker.GetDownloadProgress()
-		totalBytes += progress.TotalBytes
+		// Unknown sizes (-1) would otherwise shrink the total.
+		totalBytes += max(progress.TotalBytes, 0)
 		downloadedBytes += progress.DownloadedBytes
 
 		if progress.Error != nil {
@@ -148,7 +186,7 @@ func (m *Manager) GetAggregateProgress() AggregateProgress {
 
 	overallPercentage := float64(0)
 	if totalBytes > 0 {
-		overallPercentage = float64(downloadedBytes) / float64(totalBytes) * 100
+		overallPercentage = min(float64(downloadedBytes)/float64(totalBytes)*100, 100)
 	}
 
 	averageSpeed := float64(0)
@@ -157,7 +195,7 @@ func (m *Manager) GetAggregateProgress() AggregateProgress {
 	}
 
 	return AggregateProgress{
-		TotalDownloads:     len(m.trackers),
+		TotalDownloads:     max(m.expected, len(m.trackers)+m.finished.completed+m.finished.failed),
 		ActiveDownloads:    activeCount,
 		CompletedDownloads: completedCount,
 		FailedDownloads:    failedCount,
@@ -165,7 +203,25 @@ func (m *Manager) GetAggregateProgress() AggregateProgress {
 		DownloadedBytes:    downloadedBytes,
 		OverallPercentage:  overallPercentage,
 		AverageSpeed:       averageSpeed,
+		Speed:              totalSpeed,
+	}
+}
+
+// String renders the aggregate as "3 of 7 downloads, 12.3 MB / 40.0 MB,
+// 5.1 MB/s", leaving out sizes and speed that are not known yet.
+func (a AggregateProgress) String() string {
+	done := a.CompletedDownloads + a.FailedDownloads
+	line := fmt.Sprintf("%d of %d downloads", done, a.TotalDownloads)
+	if a.FailedDownloads > 0 {
+		line += fmt.Sprintf(" (%d failed)", a.FailedDownloads)
+	}
+	if a.TotalBytes > 0 {
+		line += fmt.Sprintf(", %s / %s", FormatBytes(a.DownloadedBytes), FormatBytes(a.TotalBytes))
+	}
+	if a.ActiveDownloads > 0 && a.Speed > 0 {
+		line += fmt.Sprintf(", %s/s", FormatBytes(int64(a.Speed)))
 	}
+	return line
 }
 
 // IsComplete returns true if all downloads have finished
@@ -195,6 +251,45 @@ func (m *Manager) UnsubscribeFromEvents(id string) {
 	m.eventBus.Unsubscribe(id)
 }
 
+// Follow calls handle, on its own goroutine, for every event published on
+// the event bus. The returned function unsubscribes and waits for the
+// events still queued to be handled. name prefixes the subscription ID.
+func (m *Manager) Follow(name string, handle func(ProgressEvent)) func() {
+	subID := fmt.Sprintf("%s-%d", name, time.Now().UnixNano())
+	events := make(chan ProgressEvent, 256)
+	m.SubscribeToEvents(subID, events)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case event := <-events:
+				handle(event)
+			case <-stop:
+				for {
+					select {
+					case event := <-events:
+						handle(event)
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.UnsubscribeFromEvents(subID)
+			close(stop)
+			<-done
+		})
+	}
+}
+
 // StartEventRouter starts a goroutine that routes events to the event bus
 // Call this after setting up subscriptions to receive events
 func (m *Manager) StartEventRouter() {
@@ -3,6 +3,7 @@
 package progress
 
 import (
+	"math"
 	"sync"
 	"time"
 )
@@ -24,13 +25,15 @@ type ProgressTracker interface {
 	GetDownloadProgress() DownloadProgress
 }
 
-// DownloadProgress holds the state of a download operation
+// DownloadProgress holds the state of a download operation. TotalBytes is
+// -1 (or 0) when the server did not send a Content-Length; ETA is then 0.
 type DownloadProgress struct {
 	ID              string
 	URL             string
 	TotalBytes      int64
 	DownloadedBytes int64
-	Speed           float64 // bytes per second
+	Speed           float64 // smoothed bytes per second, used for ETA
+	InstantSpeed    float64 // bytes per second over the last sample
 	ETA             time.Duration
 	StartedAt       time.Time
 	UpdatedAt       time.Time
@@ -55,13 +58,45 @@ func (dp DownloadProgress) IsComplete() bool {
 	return !dp.CompletedAt.IsZero() || dp.Error != nil
 }
 
+// estimateETA returns the time left at the smoothed speed, or 0 when the
+// total size or speed is unknown.
+func (dp *DownloadProgress) estimateETA() time.Duration {
+	remaining := dp.TotalBytes - dp.DownloadedBytes
+	if dp.TotalBytes <= 0 || dp.Speed <= 0 || remaining <= 0 {
+		return 0
+	}
+	return time.Duration(float64(remaining) / dp.Speed * float64(time.Second))
+}
+
+// SpeedWindow is the time constant of the moving average behind
+// DownloadProgress.Speed: samples older than this weigh about a third of a
+// fresh one. Longer windows give steadier ETAs that react later to real
+// changes in throughput.
+var SpeedWindow = 5 * time.Second
+
+// speedSampleInterval is the minimum time between speed samples. Chunks
+// arrive in bursts, so rates over shorter spans are mostly noise.
+const speedSampleInterval = 250 * time.Millisecond
+
+// UpdateEventInterval is the minimum time between progress events sent by
+// one tracker. Downloads call Update for every chunk read; sending each one
+// would fill the event channel when many downloads run in parallel, and
+// start, completion and error events would then be dropped.
+var UpdateEventInterval = 100 * time.Millisecond
+
 // baseTracker is a basic implementation of ProgressTracker
 type baseTracker struct {
-	id       string
-	url      string
-	events   chan<- ProgressEvent
-	progress DownloadProgress
-	mu       sync.RWMutex
+	id        string
+	url       string
+	events    chan<- ProgressEvent
+	progress  DownloadProgress
+	lastEvent time.Time
+	mu        sync.RWMutex
+
+	// sampleAt and sampleBytes mark the start of the current speed sample.
+	sampleAt    time.Time
+	sampleBytes int64
+	now         func() time.Time
 }
 
 func (t *baseTracker) trySend(event ProgressEvent) {
@@ -78,6 +113,7 @@ func NewProgressTracker(id, url string, events chan<- ProgressEvent) ProgressTra
 		id:     id,
 		url:    url,
 		events: events,
+		now:    time.Now,
 		progress: DownloadProgress{
 			ID:  id,
 			URL: url,
@@ -90,9 +126,14 @@ func (t *baseTracker) Start(total int64) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
+	now := t.now()
 	t.progress.TotalBytes = total
-	t.progress.StartedAt = time.Now()
-	t.progress.UpdatedAt = time.Now()
+	t.progress.StartedAt = now
+	t.progress.UpdatedAt = now
+	t.progress.Speed, t.progress.InstantSpeed = 0, 0
+	// The first Update sets the baseline, so a resumed download does not
+	// count the bytes it already had as speed.
+	t.sampleAt = time.Time{}
 
 	t.trySend(ProgressEvent{
 		Type:    EventDownloadStart,
@@ -108,23 +149,16 @@ func (t *baseTracker) Update(current int64) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	now := time.Now()
-	bytesSinceLastUpdate := current - t.progress.DownloadedBytes
-	timeSinceLastUpdate := now.Sub(t.progress.UpdatedAt).Seconds()
-
-	if timeSinceLastUpdate > 0 {
-		t.progress.Speed = float64(bytesSinceLastUpdate) / timeSinceLastUpdate
-	}
-
-	// Calculate ETA
-	remainingBytes := t.progress.TotalBytes - current
-	if t.progress.Speed > 0 && remainingBytes > 0 {
-		t.progress.ETA = time.Duration(float64(remainingBytes)/t.progress.Speed) * time.Second
-	}
-
+	now := t.now()
+	t.sampleSpeed(now, current)
 	t.progress.DownloadedBytes = current
 	t.progress.UpdatedAt = now
+	t.progress.ETA = t.progress.estimateETA()
 
+	if now.Sub(t.lastEvent) < UpdateEventInterval {
+		return
+	}
+	t.lastEvent = now
 	t.trySend(ProgressEvent{
 		Type:    EventDownloadProgress,
 		ID:      t.id,
@@ -134,13 +168,42 @@ func (t *baseTracker) Update(current int64) {
 	})
 }
 
-// Complete marks the download as successfully completed
+// sampleSpeed folds the rate since the last sample into the moving
+// average. The weight of a sample grows with the time it covers, so
+// irregular update intervals do not skew the average.
+func (t *baseTracker) sampleSpeed(now time.Time, current int64) {
+	if t.sampleAt.IsZero() {
+		t.sampleAt, t.sampleBytes = now, current
+		return
+	}
+	elapsed := now.Sub(t.sampleAt)
+	if elapsed < speedSampleInterval {
+		return
+	}
+	instant := max(float64(current-t.sampleBytes)/elapsed.Seconds(), 0)
+	t.progress.InstantSpeed = instant
+	if t.progress.Speed == 0 {
+		t.progress.Speed = instant
+	} else {
+		alpha := 1 - math.Exp(-elapsed.Seconds()/SpeedWindow.Seconds())
+		t.progress.Speed += alpha * (instant - t.progress.Speed)
+	}
+	t.sampleAt, t.sampleBytes = now, current
+}
+
+// Complete marks the download as successfully completed. When the total
+// size was unknown, it becomes the number of bytes downloaded.
 func (t *baseTracker) Complete() {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	t.progress.CompletedAt = time.Now()
-	t.progress.DownloadedBytes = t.progress.TotalBytes
+	t.progress.CompletedAt = t.now()
+	if t.progress.TotalBytes > 0 {
+		t.progress.DownloadedBytes = t.progress.TotalBytes
+	} else {
+		t.progress.TotalBytes = t.progress.DownloadedBytes
+	}
+	t.progress.ETA = 0
 
 	t.trySend(ProgressEvent{
 		Type:    EventDownloadComplete,
@@ -157,7 +220,7 @@ func (t *baseTracker) Error(err error) {
 	defer t.mu.Unlock()
 
 	t.progress.Error = err
-	t.progress.CompletedAt = time.Now()
+	t.progress.CompletedAt = t.now()
 
 	t.trySend(ProgressEvent{
 		Type:    EventDownloadError,
@@ -2,6 +2,7 @@ package progress
 
 import (
 	"errors"
+	"strings"
 	"testing"
 	"time"
 )
@@ -139,6 +140,7 @@ func TestDownloadProgress_CalculateProgress(t *testing.T) {
 		{"100% complete", 1000, 1000, 100},
 		{"capped at 100%", 1000, 1500, 100},
 		{"zero total", 0, 0, 0},
+		{"unknown total", -1, 500, 0},
 	}
 
 	for _, tt := range tests {
@@ -167,6 +169,7 @@ func TestProgressEvent_CalculatePercentage(t *testing.T) {
 		{"100% complete", 1000, 1000, 100},
 		{"capped at 100%", 1500, 1000, 100},
 		{"zero total", 0, 0, 0},
+		{"unknown total", 500, -1, 0},
 	}
 
 	for _, tt := range tests {
@@ -361,3 +364,149 @@ func TestManagerWithBuffer(t *testing.T) {
 		t.Errorf("Expected TotalBytes 1000, got %d", progress.TotalBytes)
 	}
 }
+
+func TestProgressTracker_UpdateEventsAreThrottled(t *testing.T) {
+	events := make(chan ProgressEvent, 100)
+	tracker := NewProgressTracker("test-throttle", "http://example.com/file.tar.gz", events)
+
+	tracker.Start(1000)
+	for i := int64(1); i <= 50; i++ {
+		tracker.Update(i * 10)
+	}
+	tracker.Complete()
+	close(events)
+
+	var progressEvents int
+	var last ProgressEvent
+	for event := range events {
+		if event.Type == EventDownloadProgress {
+			progressEvents++
+		}
+		last = event
+	}
+	if progressEvents != 1 {
+		t.Errorf("expected 1 progress event within the interval, got %d", progressEvents)
+	}
+	if last.Type != EventDownloadComplete {
+		t.Errorf("completion must always be sent, last event was %s", last.Type)
+	}
+	if got := tracker.GetDownloadProgress().DownloadedBytes; got != 1000 {
+		t.Errorf("state must follow every update, got %d bytes", got)
+	}
+}
+
+func TestManager_AggregateCountsFinishedAndExpected(t *testing.T) {
+	manager := NewManager()
+	defer manager.Close()
+	manager.Expect(4)
+
+	done := manager.Register("wget", "http://example.com/wget")
+	done.Start(1000)
+	done.Complete()
+	manager.Unregister("wget")
+
+	// Served from the cache: registered but never started.
+	manager.Register("jq", "http://example.com/jq")
+	manager.Unregister("jq")
+
+	failed := manager.Register("curl", "http://example.com/curl")
+	failed.Start(500)
+	failed.Error(errors.New("boom"))
+	manager.Unregister("curl")
+
+	active := manager.Register("git", "http://example.com/git")
+	active.Start(3000)
+	active.Update(1000)
+
+	agg := manager.GetAggregateProgress()
+	if agg.TotalDownloads != 4 || agg.CompletedDownloads != 2 || agg.FailedDownloads != 1 || agg.ActiveDownloads != 1 {
+		t.Fatalf("unexpected counts: %+v", agg)
+	}
+	if agg.TotalBytes != 4000 || agg.DownloadedBytes != 2000 {
+		t.Fatalf("unexpected bytes: %+v", agg)
+	}
+	if got := agg.String(); !strings.HasPrefix(got, "3 of 4 downloads (1 failed), 2.0 KB / 3.9 KB") {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+// fakeClock steps a tracker's clock by hand.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+func newClockedTracker(c *fakeClock) *baseTracker {
+	tracker := NewProgressTracker("test-speed", "http://example.com/file.tar.gz", make(chan ProgressEvent, 100)).(*baseTracker)
+	tracker.now = c.now
+	return tracker
+}
+
+func TestProgressTracker_SpeedIsSmoothed(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	tracker := newClockedTracker(clock)
+
+	// A resumed download starts at 5000 bytes; those are not speed.
+	tracker.Start(100_000)
+	tracker.Update(5000)
+	var current int64 = 5000
+	for range 10 {
+		clock.advance(time.Second)
+		current += 1000
+		tracker.Update(current)
+	}
+	progress := tracker.GetDownloadProgress()
+	if progress.Speed != 1000 || progress.InstantSpeed != 1000 {
+		t.Fatalf("steady rate: speed %f, instant %f, want 1000", progress.Speed, progress.InstantSpeed)
+	}
+	if progress.ETA != 85*time.Second {
+		t.Errorf("ETA = %s, want 85s", progress.ETA)
+	}
+
+	// A one-second burst moves the instantaneous speed fully but the
+	// smoothed speed only part of the way.
+	clock.advance(time.Second)
+	current += 11_000
+	tracker.Update(current)
+	progress = tracker.GetDownloadProgress()
+	if progress.InstantSpeed != 11_000 {
+		t.Errorf("InstantSpeed = %f, want 11000", progress.InstantSpeed)
+	}
+	if progress.Speed <= 1000 || progress.Speed >= 3500 {
+		t.Errorf("Speed = %f, want a damped rise from 1000", progress.Speed)
+	}
+
+	// Updates closer together than the sample interval leave speed alone.
+	speed := progress.Speed
+	clock.advance(10 * time.Millisecond)
+	tracker.Update(current + 50_000)
+	if got := tracker.GetDownloadProgress().Speed; got != speed {
+		t.Errorf("speed changed within the sample interval: %f -> %f", speed, got)
+	}
+}
+
+func TestProgressTracker_UnknownSize(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	tracker := newClockedTracker(clock)
+
+	tracker.Start(-1)
+	tracker.Update(0)
+	clock.advance(time.Second)
+	tracker.Update(2048)
+
+	progress := tracker.GetDownloadProgress()
+	if progress.Speed != 2048 {
+		t.Errorf("Speed = %f, want 2048", progress.Speed)
+	}
+	if progress.ETA != 0 {
+		t.Errorf("ETA = %s, want 0 for an unknown size", progress.ETA)
+	}
+	if got := progress.CalculateProgress(); got != 0 {
+		t.Errorf("CalculateProgress() = %f, want 0", got)
+	}
+
+	tracker.Complete()
+	progress = tracker.GetDownloadProgress()
+	if progress.TotalBytes != 2048 || progress.DownloadedBytes != 2048 {
+		t.Errorf("Complete() left %d / %d bytes, want 2048 / 2048", progress.DownloadedBytes, progress.TotalBytes)
+	}
+}
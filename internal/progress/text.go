@@ -0,0 +1,151 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// DefaultTextInterval is how often TextRenderer prints an update for a
+// single download.
+const DefaultTextInterval = time.Second
+
+// TextRenderer prints download progress as plain single-line updates, for
+// output that cannot be redrawn such as CI logs and pipes. Updates for each
+// download are throttled to one per interval; start, completion and failure
+// are always printed. When attached to a Manager running several downloads,
+// an aggregate line is printed at most once per interval and after each
+// download finishes.
+type TextRenderer struct {
+	out           io.Writer
+	quiet         bool
+	interval      time.Duration
+	now           func() time.Time
+	mu            sync.Mutex
+	downloads     map[string]*textDownload
+	aggregate     func() AggregateProgress
+	lastAggregate time.Time
+}
+
+type textDownload struct {
+	startedAt   time.Time
+	startBytes  int64
+	lastPrinted time.Time
+}
+
+// NewTextRenderer creates a renderer writing to out. When quiet is set only
+// start and finish lines are printed.
+func NewTextRenderer(out io.Writer, quiet bool) *TextRenderer {
+	return &TextRenderer{
+		out:       out,
+		quiet:     quiet,
+		interval:  DefaultTextInterval,
+		now:       time.Now,
+		downloads: make(map[string]*textDownload),
+	}
+}
+
+// Handle renders a single progress event.
+func (r *TextRenderer) Handle(event ProgressEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	d, ok := r.downloads[event.ID]
+	if !ok || event.Type == EventDownloadStart {
+		d = &textDownload{startedAt: now, startBytes: event.Current}
+		r.downloads[event.ID] = d
+	}
+
+	switch event.Type {
+	case EventDownloadStart:
+		d.lastPrinted = now
+		if event.Total > 0 {
+			fmt.Fprintf(r.out, "  ⬇️  %s: downloading %s\n", event.ID, FormatBytes(event.Total))
+		} else {
+			fmt.Fprintf(r.out, "  ⬇️  %s: downloading\n", event.ID)
+		}
+
+	case EventDownloadProgress:
+		if r.quiet || now.Sub(d.lastPrinted) < r.interval {
+			return
+		}
+		d.lastPrinted = now
+		fmt.Fprintf(r.out, "  ⏳ %s\n", formatTextProgress(event, d, now))
+		if now.Sub(r.lastAggregate) >= r.interval {
+			r.printAggregate(now)
+		}
+
+	case EventDownloadComplete:
+		delete(r.downloads, event.ID)
+		fmt.Fprintf(r.out, "  ✅ %s: downloaded %s in %s\n",
+			event.ID, FormatBytes(event.Total), now.Sub(d.startedAt).Round(100*time.Millisecond))
+		r.printAggregate(now)
+
+	case EventDownloadError:
+		delete(r.downloads, event.ID)
+		fmt.Fprintf(r.out, "  ❌ %s: download failed: %s\n", event.ID, event.Message)
+		r.printAggregate(now)
+	}
+}
+
+// printAggregate prints the overall progress line, unless quiet or fewer
+// than two downloads are tracked.
+func (r *TextRenderer) printAggregate(now time.Time) {
+	if r.quiet || r.aggregate == nil {
+		return
+	}
+	agg := r.aggregate()
+	if agg.TotalDownloads < 2 {
+		return
+	}
+	r.lastAggregate = now
+	fmt.Fprintf(r.out, "  📊 %s\n", agg)
+}
+
+func formatTextProgress(event ProgressEvent, d *textDownload, now time.Time) string {
+	line := fmt.Sprintf("%s: %s", event.ID, FormatBytes(event.Current))
+	if event.Total > 0 {
+		line = fmt.Sprintf("%s: %5.1f%% (%s / %s)", event.ID, event.CalculatePercentage(),
+			FormatBytes(event.Current), FormatBytes(event.Total))
+	}
+
+	elapsed := now.Sub(d.startedAt).Seconds()
+	if elapsed <= 0 {
+		return line
+	}
+	speed := float64(event.Current-d.startBytes) / elapsed
+	if speed <= 0 {
+		return line
+	}
+	line += fmt.Sprintf(", %s/s", FormatBytes(int64(speed)))
+	if remaining := event.Total - event.Current; event.Total > 0 && remaining > 0 {
+		eta := time.Duration(float64(remaining)/speed) * time.Second
+		line += fmt.Sprintf(", ETA %s", eta.Round(time.Second))
+	}
+	return line
+}
+
+// Attach subscribes the renderer to m's event bus. The returned function
+// unsubscribes and renders any events still queued.
+func (r *TextRenderer) Attach(m *Manager) func() {
+	r.mu.Lock()
+	r.aggregate = m.GetAggregateProgress
+	r.mu.Unlock()
+	return m.Follow("text-renderer", r.Handle)
+}
+
+// FormatBytes renders a byte count with a binary unit, e.g. "12.3 MB".
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,134 @@
+package progress
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestTextRenderer(buf *bytes.Buffer, quiet bool, now *time.Time) *TextRenderer {
+	r := NewTextRenderer(buf, quiet)
+	r.now = func() time.Time { return *now }
+	return r
+}
+
+func TestTextRendererThrottlesPerDownload(t *testing.T) {
+	var buf bytes.Buffer
+	now := time.Unix(1000, 0)
+	r := newTestTextRenderer(&buf, false, &now)
+
+	r.Handle(ProgressEvent{Type: EventDownloadStart, ID: "wget", Total: 4096})
+	r.Handle(ProgressEvent{Type: EventDownloadStart, ID: "jq", Total: 4096})
+
+	now = now.Add(500 * time.Millisecond)
+	r.Handle(ProgressEvent{Type: EventDownloadProgress, ID: "wget", Current: 512, Total: 4096})
+
+	now = now.Add(500 * time.Millisecond)
+	r.Handle(ProgressEvent{Type: EventDownloadProgress, ID: "wget", Current: 1024, Total: 4096})
+	r.Handle(ProgressEvent{Type: EventDownloadProgress, ID: "jq", Current: 2048, Total: 4096})
+	r.Handle(ProgressEvent{Type: EventDownloadProgress, ID: "wget", Current: 1536, Total: 4096})
+
+	out := buf.String()
+	if got := strings.Count(out, "⏳ wget"); got != 1 {
+		t.Fatalf("expected 1 wget update, got %d:\n%s", got, out)
+	}
+	if got := strings.Count(out, "⏳ jq"); got != 1 {
+		t.Fatalf("expected 1 jq update, got %d:\n%s", got, out)
+	}
+	for _, want := range []string{"wget:  25.0% (1.0 KB / 4.0 KB), 1.0 KB/s, ETA 3s", "jq:  50.0% (2.0 KB / 4.0 KB), 2.0 KB/s, ETA 1s"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestTextRendererQuietPrintsOnlyStartAndFinish(t *testing.T) {
+	var buf bytes.Buffer
+	now := time.Unix(1000, 0)
+	r := newTestTextRenderer(&buf, true, &now)
+
+	r.Handle(ProgressEvent{Type: EventDownloadStart, ID: "wget", Total: 2048})
+	now = now.Add(2 * time.Second)
+	r.Handle(ProgressEvent{Type: EventDownloadProgress, ID: "wget", Current: 1024, Total: 2048})
+	now = now.Add(2 * time.Second)
+	r.Handle(ProgressEvent{Type: EventDownloadComplete, ID: "wget", Current: 2048, Total: 2048})
+	r.Handle(ProgressEvent{Type: EventDownloadStart, ID: "jq"})
+	r.Handle(ProgressEvent{Type: EventDownloadError, ID: "jq", Message: "checksum mismatch"})
+
+	out := buf.String()
+	if strings.Contains(out, "⏳") {
+		t.Fatalf("quiet renderer printed a progress update:\n%s", out)
+	}
+	for _, want := range []string{
+		"wget: downloading 2.0 KB",
+		"wget: downloaded 2.0 KB in 4s",
+		"jq: downloading\n",
+		"jq: download failed: checksum mismatch",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestTextRendererAttachDrainsOnStop(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewManager()
+	m.StartEventRouter()
+	defer m.Close()
+
+	detach := NewTextRenderer(&buf, true).Attach(m)
+	tracker := m.Register("wget", "https://example.com/wget")
+	tracker.Start(100)
+	tracker.Complete()
+
+	// Let the router publish the queued events before detaching.
+	time.Sleep(50 * time.Millisecond)
+	detach()
+	detach()
+
+	if out := buf.String(); !strings.Contains(out, "wget: downloaded") {
+		t.Fatalf("expected completion line, got:\n%s", out)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		512:             "512 B",
+		1536:            "1.5 KB",
+		5 * 1024 * 1024: "5.0 MB",
+	}
+	for in, want := range tests {
+		if got := FormatBytes(in); got != want {
+			t.Errorf("FormatBytes(%d) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestTextRendererPrintsAggregate(t *testing.T) {
+	var buf bytes.Buffer
+	now := time.Unix(1000, 0)
+	r := newTestTextRenderer(&buf, false, &now)
+	agg := AggregateProgress{TotalDownloads: 3, CompletedDownloads: 1, ActiveDownloads: 2, TotalBytes: 8192, DownloadedBytes: 4096, Speed: 2048}
+	r.aggregate = func() AggregateProgress { return agg }
+
+	r.Handle(ProgressEvent{Type: EventDownloadStart, ID: "wget", Total: 4096})
+	now = now.Add(time.Second)
+	r.Handle(ProgressEvent{Type: EventDownloadProgress, ID: "wget", Current: 1024, Total: 4096})
+	r.Handle(ProgressEvent{Type: EventDownloadStart, ID: "jq", Total: 4096})
+	r.Handle(ProgressEvent{Type: EventDownloadProgress, ID: "jq", Current: 1024, Total: 4096})
+
+	out := buf.String()
+	if got := strings.Count(out, "📊"); got != 1 {
+		t.Fatalf("expected 1 aggregate line within the interval, got %d:\n%s", got, out)
+	}
+	if !strings.Contains(out, "📊 1 of 3 downloads, 4.0 KB / 8.0 KB, 2.0 KB/s") {
+		t.Errorf("unexpected aggregate line:\n%s", out)
+	}
+
+	r.Handle(ProgressEvent{Type: EventDownloadComplete, ID: "jq", Current: 4096, Total: 4096})
+	if got := strings.Count(buf.String(), "📊"); got != 2 {
+		t.Errorf("a finished download should print the aggregate, got %d lines", got)
+	}
+}
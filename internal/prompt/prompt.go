@@ -0,0 +1,92 @@
+// Package prompt asks the user questions on the terminal. Every question
+// fastbrew asks goes through a Prompter, whose Policy lets scripts and CI
+// answer yes up front or fail at once instead of waiting on stdin.
+package prompt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Policy says how questions are answered.
+type Policy string
+
+const (
+	// PolicyAsk reads each answer from the input.
+	PolicyAsk Policy = "ask"
+	// PolicyYes answers yes to confirmations and takes the default for
+	// other questions, without reading the input.
+	PolicyYes Policy = "yes"
+	// PolicyNever fails every question with ErrInputRequired, without
+	// reading the input.
+	PolicyNever Policy = "never"
+)
+
+// ParsePolicy parses ask, yes or never.
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(strings.ToLower(strings.TrimSpace(s))); p {
+	case PolicyAsk, PolicyYes, PolicyNever:
+		return p, nil
+	case "":
+		return PolicyAsk, nil
+	}
+	return "", fmt.Errorf("unknown confirmation policy %q (want ask, yes or never)", s)
+}
+
+// ErrInputRequired is returned for a question that needs an answer when
+// none can be read: the policy is PolicyNever or the input has ended.
+var ErrInputRequired = errors.New("input required")
+
+// Prompter asks questions on out and reads the answers from in.
+type Prompter struct {
+	in     *bufio.Reader
+	out    io.Writer
+	policy Policy
+}
+
+// New returns a Prompter reading from in and writing to out under policy.
+func New(in io.Reader, out io.Writer, policy Policy) *Prompter {
+	if policy == "" {
+		policy = PolicyAsk
+	}
+	return &Prompter{in: bufio.NewReader(in), out: out, policy: policy}
+}
+
+// Policy returns the policy p answers under.
+func (p *Prompter) Policy() Policy {
+	return p.policy
+}
+
+// Confirm asks a yes/no question that defaults to no, and reports whether
+// the answer was y or yes.
+func (p *Prompter) Confirm(question string) (bool, error) {
+	answer, err := p.Line(question+" [y/N]", "y")
+	if err != nil {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+// Line asks question and returns the answer with surrounding space
+// trimmed. Under PolicyYes, yesAnswer is returned without reading.
+func (p *Prompter) Line(question, yesAnswer string) (string, error) {
+	switch p.policy {
+	case PolicyYes:
+		fmt.Fprintf(p.out, "\n❓ %s: %s (--yes)\n", question, yesAnswer)
+		return yesAnswer, nil
+	case PolicyNever:
+		return "", fmt.Errorf("%w to answer %q; pass --yes to accept it, or run without --no-input", ErrInputRequired, question)
+	}
+
+	fmt.Fprintf(p.out, "\n❓ %s: ", question)
+	line, err := p.in.ReadString('\n')
+	if err != nil && line == "" {
+		fmt.Fprintln(p.out)
+		return "", fmt.Errorf("%w to answer %q, but the input has ended; pass --yes or --no-input", ErrInputRequired, question)
+	}
+	return strings.TrimSpace(line), nil
+}
@@ -0,0 +1,56 @@
+package prompt
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestConfirm(t *testing.T) {
+	tests := []struct {
+		policy  Policy
+		input   string
+		want    bool
+		wantErr bool
+	}{
+		{PolicyAsk, "y\n", true, false},
+		{PolicyAsk, "YES\n", true, false},
+		{PolicyAsk, "\n", false, false},
+		{PolicyAsk, "n\n", false, false},
+		{PolicyAsk, "y", true, false},
+		{PolicyAsk, "", false, true},
+		{PolicyYes, "", true, false},
+		{PolicyNever, "y\n", false, true},
+	}
+	for _, tt := range tests {
+		got, err := New(strings.NewReader(tt.input), io.Discard, tt.policy).Confirm("Remove?")
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("%s with %q: got %v, %v; want %v (error %v)", tt.policy, tt.input, got, err, tt.want, tt.wantErr)
+		}
+		if err != nil && !errors.Is(err, ErrInputRequired) {
+			t.Errorf("%s with %q: error %v is not ErrInputRequired", tt.policy, tt.input, err)
+		}
+	}
+}
+
+func TestNeverDoesNotRead(t *testing.T) {
+	in := strings.NewReader("y\n")
+	if _, err := New(in, io.Discard, PolicyNever).Line("Which?", ""); !errors.Is(err, ErrInputRequired) {
+		t.Fatalf("got %v, want ErrInputRequired", err)
+	}
+	if in.Len() != 2 {
+		t.Error("PolicyNever read the input")
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	for input, want := range map[string]Policy{"": PolicyAsk, "ask": PolicyAsk, "Yes": PolicyYes, "never": PolicyNever} {
+		if got, err := ParsePolicy(input); err != nil || got != want {
+			t.Errorf("ParsePolicy(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	if _, err := ParsePolicy("maybe"); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+}
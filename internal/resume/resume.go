@@ -4,6 +4,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"fastbrew/internal/atomicfile"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -210,7 +211,7 @@ func (rm *ResumeManager) Save(pd *PartialDownload) error {
 		return fmt.Errorf("failed to marshal resume metadata: %w", err)
 	}
 
-	if err := os.WriteFile(metadataPath, data, 0644); err != nil {
+	if err := atomicfile.WriteFile(metadataPath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write resume metadata: %w", err)
 	}
 
@@ -590,6 +590,26 @@ func TestValidateChecksum(t *testing.T) {
 	}
 }
 
+func TestCheckRemoteSizeChanged(t *testing.T) {
+	pd := &PartialDownload{TotalSize: 1000}
+
+	if CheckRemoteSizeChanged(pd, 1000) {
+		t.Error("CheckRemoteSizeChanged() should return false for the same size")
+	}
+	if !CheckRemoteSizeChanged(pd, 1200) {
+		t.Error("CheckRemoteSizeChanged() should return true for a different size")
+	}
+	if CheckRemoteSizeChanged(pd, -1) {
+		t.Error("CheckRemoteSizeChanged() should return false for an unknown size")
+	}
+	if CheckRemoteSizeChanged(&PartialDownload{}, 1200) {
+		t.Error("CheckRemoteSizeChanged() should return false when the expected size is unknown")
+	}
+	if !CheckRemoteSizeChanged(nil, 1000) {
+		t.Error("CheckRemoteSizeChanged(nil) should return true")
+	}
+}
+
 func TestCanResumeDownload(t *testing.T) {
 	tempDir := t.TempDir()
 	testFile := filepath.Join(tempDir, "test.file")
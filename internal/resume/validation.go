@@ -108,6 +108,16 @@ func CheckRemoteFileChanged(pd *PartialDownload, remoteLastModified, remoteETag
 	return false
 }
 
+// CheckRemoteSizeChanged reports whether the remote file's size, as sent
+// in Content-Length, differs from the size the partial download expects.
+// A negative remoteSize means it is unknown.
+func CheckRemoteSizeChanged(pd *PartialDownload, remoteSize int64) bool {
+	if pd == nil {
+		return true
+	}
+	return pd.TotalSize > 0 && remoteSize >= 0 && remoteSize != pd.TotalSize
+}
+
 func DetectCorruption(pd *PartialDownload) error {
 	if pd == nil {
 		return fmt.Errorf("partial download is nil")
@@ -0,0 +1,98 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned, wrapped, while a host's breaker is open.
+var ErrCircuitOpen = errors.New("circuit open")
+
+// Breaker tracks consecutive failures per host. Once a host fails
+// Threshold times in a row its circuit opens and further attempts fail
+// immediately until Cooldown has passed. The next attempt after that is a
+// probe: success closes the circuit, failure reopens it at once.
+//
+// A Breaker is shared by concurrent workers so that one dead host is
+// given up on once, not retried by every worker. A nil Breaker allows
+// everything.
+type Breaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+	now   func() time.Time
+}
+
+type hostState struct {
+	failures  int
+	openUntil time.Time
+}
+
+// NewBreaker returns a breaker that opens after threshold consecutive
+// failures and stays open for cooldown.
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		Threshold: threshold,
+		Cooldown:  cooldown,
+		hosts:     make(map[string]*hostState),
+		now:       time.Now,
+	}
+}
+
+// Allow returns a non-retryable error wrapping ErrCircuitOpen while host's
+// circuit is open.
+func (b *Breaker) Allow(host string) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state, ok := b.hosts[host]
+	if !ok {
+		return nil
+	}
+	if wait := state.openUntil.Sub(b.now()); wait > 0 {
+		return NonRetryable(fmt.Errorf("%s: %w after %d consecutive failures (retry in %s)", host, ErrCircuitOpen, state.failures, wait.Round(time.Second)))
+	}
+	return nil
+}
+
+// Record counts the outcome of an attempt against host. Only retryable
+// errors count as failures: a non-retryable one such as a 404 means the
+// host is up.
+func (b *Breaker) Record(host string, err error) {
+	if b == nil || errors.Is(err, ErrCircuitOpen) {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil || !IsRetryable(err) {
+		delete(b.hosts, host)
+		return
+	}
+	state, ok := b.hosts[host]
+	if !ok {
+		state = &hostState{}
+		b.hosts[host] = state
+	}
+	state.failures++
+	if state.failures >= b.Threshold {
+		state.openUntil = b.now().Add(b.Cooldown)
+	}
+}
+
+// Do runs fn unless host's circuit is open and records its outcome.
+func (b *Breaker) Do(host string, fn func() error) error {
+	if err := b.Allow(host); err != nil {
+		return err
+	}
+	err := fn()
+	b.Record(host, err)
+	return err
+}
@@ -0,0 +1,69 @@
+package retry
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	now := time.Now()
+	b := NewBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+	transient := errors.New("503 Service Unavailable")
+
+	b.Record("ghcr.io", transient)
+	if err := b.Allow("ghcr.io"); err != nil {
+		t.Fatalf("circuit opened before the threshold: %v", err)
+	}
+	b.Record("ghcr.io", transient)
+
+	err := b.Allow("ghcr.io")
+	if !errors.Is(err, ErrCircuitOpen) || IsRetryable(err) {
+		t.Fatalf("expected a non-retryable open circuit, got %v", err)
+	}
+	if err := b.Allow("mirror.example.com"); err != nil {
+		t.Fatalf("other hosts must not be affected: %v", err)
+	}
+
+	calls := 0
+	b.Do("ghcr.io", func() error { calls++; return nil })
+	if calls != 0 {
+		t.Fatal("Do must not run fn while the circuit is open")
+	}
+
+	// After the cool-down one probe goes through; a failure reopens the
+	// circuit straight away.
+	now = now.Add(time.Minute)
+	if err := b.Do("ghcr.io", func() error { return transient }); err != transient {
+		t.Fatalf("expected the probe to run, got %v", err)
+	}
+	if err := b.Allow("ghcr.io"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("failed probe should reopen the circuit, got %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	if err := b.Do("ghcr.io", func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	b.Record("ghcr.io", transient)
+	if err := b.Allow("ghcr.io"); err != nil {
+		t.Fatalf("a success should reset the failure count: %v", err)
+	}
+}
+
+func TestBreakerIgnoresNonRetryableErrors(t *testing.T) {
+	b := NewBreaker(1, time.Minute)
+	b.Record("ghcr.io", NonRetryable(errors.New("404 Not Found")))
+	if err := b.Allow("ghcr.io"); err != nil {
+		t.Fatalf("a 404 must not open the circuit: %v", err)
+	}
+}
+
+func TestNilBreakerAllowsEverything(t *testing.T) {
+	var b *Breaker
+	b.Record("ghcr.io", errors.New("boom"))
+	if err := b.Do("ghcr.io", func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+}
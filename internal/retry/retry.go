@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -45,8 +48,7 @@ func DoWithConfig(ctx context.Context, cfg Config, fn func() error) error {
 				return err
 			}
 
-			jitter := time.Duration(float64(delay) * cfg.JitterFactor * (rand.Float64()*2 - 1))
-			sleepDuration := delay + jitter
+			sleepDuration := backoff(err, delay, cfg)
 
 			select {
 			case <-ctx.Done():
@@ -90,8 +92,7 @@ func WithResultConfig[T any](ctx context.Context, cfg Config, fn func() (T, erro
 				return result, err
 			}
 
-			jitter := time.Duration(float64(delay) * cfg.JitterFactor * (rand.Float64()*2 - 1))
-			sleepDuration := delay + jitter
+			sleepDuration := backoff(err, delay, cfg)
 
 			select {
 			case <-ctx.Done():
@@ -108,6 +109,66 @@ func WithResultConfig[T any](ctx context.Context, cfg Config, fn func() (T, erro
 	return result, lastErr
 }
 
+// MaxRetryAfter caps how long a server-supplied Retry-After delay may hold
+// up a retry.
+const MaxRetryAfter = time.Minute
+
+// backoff returns how long to wait before the next attempt: the jittered
+// exponential delay, or the server's Retry-After when that is longer.
+func backoff(err error, delay time.Duration, cfg Config) time.Duration {
+	jitter := time.Duration(float64(delay) * cfg.JitterFactor * (rand.Float64()*2 - 1))
+	sleepDuration := delay + jitter
+	if after, ok := RetryAfter(err); ok && after > sleepDuration {
+		sleepDuration = min(after, MaxRetryAfter)
+	}
+	return sleepDuration
+}
+
+type retryAfterError struct {
+	err   error
+	delay time.Duration
+}
+
+func (e *retryAfterError) Error() string { return e.err.Error() }
+func (e *retryAfterError) Unwrap() error { return e.err }
+
+// After marks err as retryable no sooner than delay, as a server asked
+// with a Retry-After header.
+func After(err error, delay time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &retryAfterError{err: err, delay: delay}
+}
+
+// RetryAfter returns the delay attached to err by After.
+func RetryAfter(err error) (time.Duration, bool) {
+	var rae *retryAfterError
+	if errors.As(err, &rae) {
+		return rae.delay, true
+	}
+	return 0, false
+}
+
+// ParseRetryAfter parses a Retry-After header value, either delay seconds
+// or an HTTP date.
+func ParseRetryAfter(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
 type nonRetryableError struct {
 	err error
 }
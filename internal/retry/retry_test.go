@@ -3,6 +3,7 @@ package retry
 import (
 	"context"
 	"errors"
+	"net/http"
 	"sync"
 	"testing"
 	"time"
@@ -246,3 +247,48 @@ func TestConcurrentRetries(t *testing.T) {
 		t.Errorf("Expected no errors, got %d", errCount)
 	}
 }
+
+func TestDoHonorsRetryAfter(t *testing.T) {
+	cfg := Config{MaxAttempts: 2, InitialDelay: time.Millisecond, Multiplier: 1.0}
+
+	start := time.Now()
+	calls := 0
+	err := DoWithConfig(context.Background(), cfg, func() error {
+		calls++
+		if calls == 1 {
+			return After(errors.New("503 Service Unavailable"), 50*time.Millisecond)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("DoWithConfig() returned error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("retried after %v, before the Retry-After delay", elapsed)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d, ok := ParseRetryAfter("120"); !ok || d != 2*time.Minute {
+		t.Errorf("ParseRetryAfter(120) = %v, %v", d, ok)
+	}
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if d, ok := ParseRetryAfter(date); !ok || d < 59*time.Minute {
+		t.Errorf("ParseRetryAfter(%q) = %v, %v", date, d, ok)
+	}
+	for _, value := range []string{"", "-1", "soon"} {
+		if _, ok := ParseRetryAfter(value); ok {
+			t.Errorf("ParseRetryAfter(%q) should fail", value)
+		}
+	}
+}
+
+func TestRetryAfterKeepsRetryability(t *testing.T) {
+	err := After(NonRetryable(errors.New("gone")), time.Second)
+	if IsRetryable(err) {
+		t.Error("After must not make a non-retryable error retryable")
+	}
+	if d, ok := RetryAfter(err); !ok || d != time.Second {
+		t.Errorf("RetryAfter() = %v, %v", d, ok)
+	}
+}
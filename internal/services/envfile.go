@@ -0,0 +1,81 @@
+package services
+
+import (
+	"fastbrew/internal/paths"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// EnvDir holds per-service environment files, <name>.env, where name is
+// the service or formula name. Their variables are injected into the
+// service definition each time the service starts, so they survive
+// upgrades that regenerate it.
+func EnvDir() string {
+	return filepath.Join(paths.ConfigDir(), "services")
+}
+
+// EnvFilePath returns the environment file of serviceName in dir: the one
+// named after the service if it exists, otherwise the one named after its
+// formula.
+func EnvFilePath(dir, serviceName string) string {
+	path := filepath.Join(dir, serviceName+".env")
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+	return filepath.Join(dir, FormulaName(serviceName)+".env")
+}
+
+// LoadEnvFile reads the KEY=VALUE lines of path. A missing file sets no
+// variables.
+func LoadEnvFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	env, err := ParseEnvFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return env, nil
+}
+
+// ParseEnvFile parses KEY=VALUE lines. Blank lines and lines starting with
+// # are skipped, an "export " prefix is allowed, and values may be quoted
+// with " (Go escapes) or ' (literal).
+func ParseEnvFile(data []byte) (map[string]string, error) {
+	env := make(map[string]string)
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", i+1)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if key == "" || strings.ContainsAny(key, " \t\"'") {
+			return nil, fmt.Errorf("line %d: invalid variable name %q", i+1, key)
+		}
+		if len(value) >= 2 {
+			switch {
+			case value[0] == '"' && value[len(value)-1] == '"':
+				unquoted, err := strconv.Unquote(value)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %s: %w", i+1, key, err)
+				}
+				value = unquoted
+			case value[0] == '\'' && value[len(value)-1] == '\'':
+				value = value[1 : len(value)-1]
+			}
+		}
+		env[key] = value
+	}
+	return env, nil
+}
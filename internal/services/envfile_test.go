@@ -0,0 +1,188 @@
+package services
+
+import (
+	"fastbrew/internal/cmdrunner"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	data := `# postgres settings
+PGDATA=/opt/homebrew/var/postgres
+export LC_ALL="en_US.UTF-8"
+GREETING='hello # world'
+
+ESCAPED="a\tb"
+`
+	env, err := ParseEnvFile([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseEnvFile failed: %v", err)
+	}
+	want := map[string]string{
+		"PGDATA":   "/opt/homebrew/var/postgres",
+		"LC_ALL":   "en_US.UTF-8",
+		"GREETING": "hello # world",
+		"ESCAPED":  "a\tb",
+	}
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("ParseEnvFile() = %v, want %v", env, want)
+	}
+
+	for data, wantErr := range map[string]string{
+		"PGDATA\n":         "line 1: expected KEY=VALUE",
+		"\nMY VAR=1\n":     "line 2: invalid variable name",
+		"A=\"unclosed\\\"": "line 1: A:",
+	} {
+		if _, err := ParseEnvFile([]byte(data)); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("ParseEnvFile(%q) error = %v, want %q", data, err, wantErr)
+		}
+	}
+}
+
+func TestEnvFilePath(t *testing.T) {
+	dir := t.TempDir()
+	if got := EnvFilePath(dir, "homebrew.mxcl.redis"); got != filepath.Join(dir, "redis.env") {
+		t.Errorf("EnvFilePath() = %s, want the formula's file", got)
+	}
+	servicePath := filepath.Join(dir, "homebrew.mxcl.redis.env")
+	if err := os.WriteFile(servicePath, []byte("A=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := EnvFilePath(dir, "homebrew.mxcl.redis"); got != servicePath {
+		t.Errorf("EnvFilePath() = %s, want the service's own file", got)
+	}
+
+	env, err := LoadEnvFile(filepath.Join(dir, "missing.env"))
+	if err != nil || env != nil {
+		t.Errorf("LoadEnvFile() = %v, %v; want no variables for a missing file", env, err)
+	}
+}
+
+func TestLaunchdManager_StartAppliesEnvFile(t *testing.T) {
+	agents, envDir := t.TempDir(), t.TempDir()
+	plistPath := filepath.Join(agents, "homebrew.mxcl.redis.plist")
+	plist := `<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>homebrew.mxcl.redis</string>
+	<key>ProgramArguments</key>
+	<array><string>/opt/homebrew/bin/redis-server</string></array>
+	<key>LimitLoadToSessionType</key>
+	<array><string>Aqua</string><string>Background</string></array>
+	<key>EnvironmentVariables</key>
+	<dict><key>PATH</key><string>/usr/bin</string></dict>
+</dict>
+</plist>
+`
+	if err := os.WriteFile(plistPath, []byte(plist), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(envDir, "redis.env"), []byte("REDIS_PORT=6380\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mgr := NewLaunchdManagerWithRunner(newMockCommandRunner())
+	mgr.userAgentPaths = []string{agents}
+	mgr.systemAgentPaths = []string{}
+	mgr.envDir = envDir
+	if err := mgr.Start("homebrew.mxcl.redis"); err != nil {
+		t.Fatalf("Start() returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(plistPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := decodePlist(data)
+	if err != nil {
+		t.Fatalf("rewritten plist does not decode: %v", err)
+	}
+	dict := root.(map[string]any)
+	wantEnv := map[string]any{"PATH": "/usr/bin", "REDIS_PORT": "6380"}
+	if !reflect.DeepEqual(dict["EnvironmentVariables"], wantEnv) {
+		t.Errorf("EnvironmentVariables = %v, want %v", dict["EnvironmentVariables"], wantEnv)
+	}
+	if !reflect.DeepEqual(dict["LimitLoadToSessionType"], []any{"Aqua", "Background"}) {
+		t.Errorf("Expected keys fastbrew does not model to be kept, got %v", dict)
+	}
+}
+
+func TestSystemdManager_StartAppliesEnvFile(t *testing.T) {
+	units, envDir := t.TempDir(), t.TempDir()
+	servicePath := filepath.Join(units, "homebrew.redis.service")
+	if err := os.WriteFile(servicePath, []byte("[Service]\nExecStart=/usr/bin/redis-server\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	envPath := filepath.Join(envDir, "redis.env")
+	if err := os.WriteFile(envPath, []byte("REDIS_ARGS=\"--port 6380 --save 60%\"\nA=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reloads := 0
+	runner := &countingRunner{count: &reloads, command: "systemctl --user daemon-reload"}
+	mgr := NewSystemdManagerWithRunner(runner)
+	mgr.userServicePaths = []string{units}
+	mgr.systemServicePaths = []string{}
+	mgr.envDir = envDir
+
+	if err := mgr.Start("homebrew.redis"); err != nil {
+		t.Fatalf("Start() returned error: %v", err)
+	}
+	dropIn, err := os.ReadFile(filepath.Join(servicePath+".d", envDropIn))
+	if err != nil {
+		t.Fatalf("Expected a drop-in: %v", err)
+	}
+	want := "# Generated by fastbrew from " + envPath + "; edit that file instead.\n[Service]\nEnvironment=\"A=1\"\nEnvironment=\"REDIS_ARGS=--port 6380 --save 60%%\"\n"
+	if string(dropIn) != want {
+		t.Errorf("drop-in = %q, want %q", dropIn, want)
+	}
+	if reloads != 1 {
+		t.Errorf("Expected one daemon-reload, got %d", reloads)
+	}
+
+	if err := mgr.Restart("homebrew.redis"); err != nil {
+		t.Fatalf("Restart() returned error: %v", err)
+	}
+	if reloads != 1 {
+		t.Errorf("Expected no daemon-reload for an unchanged drop-in, got %d", reloads)
+	}
+
+	if err := os.Remove(envPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.Start("homebrew.redis"); err != nil {
+		t.Fatalf("Start() returned error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(servicePath+".d", envDropIn)); !os.IsNotExist(err) {
+		t.Errorf("Expected the drop-in to be removed with the environment file, got %v", err)
+	}
+	if reloads != 2 {
+		t.Errorf("Expected a daemon-reload after removing the drop-in, got %d", reloads)
+	}
+}
+
+// countingRunner counts runs of one command and succeeds on all.
+type countingRunner struct {
+	count   *int
+	command string
+}
+
+func (r *countingRunner) Run(name string, arg ...string) ([]byte, error) {
+	if name+" "+strings.Join(arg, " ") == r.command {
+		*r.count++
+	}
+	return nil, nil
+}
+
+func (r *countingRunner) RunWithStdin(name string, stdin io.Reader, arg ...string) ([]byte, error) {
+	return r.Run(name, arg...)
+}
+
+func (r *countingRunner) RunWithOptions(opts cmdrunner.Options, name string, arg ...string) ([]byte, error) {
+	return r.Run(name, arg...)
+}
@@ -23,6 +23,20 @@ func (e ServiceNotFoundError) ServiceName() string {
 	return e.Name
 }
 
+// NoLogsError indicates a service does not record its output anywhere
+// fastbrew can read
+type NoLogsError struct {
+	Name string
+}
+
+func (e NoLogsError) Error() string {
+	return fmt.Sprintf("service %s does not set StandardOutPath or StandardErrorPath, so its output is not saved", e.Name)
+}
+
+func (e NoLogsError) ServiceName() string {
+	return e.Name
+}
+
 // PlistNotFoundError indicates a plist file was not found
 type PlistNotFoundError struct {
 	Name string
@@ -117,4 +131,8 @@ func (e SystemctlError) Unwrap() error {
 	return e.Cause
 }
 
+// ErrSystemScopeNeedsRoot is the cause of a SystemctlError when a system
+// service is started, stopped or changed without root privileges.
+var ErrSystemScopeNeedsRoot = fmt.Errorf("system services can only be managed as root; run with sudo")
+
 var ErrInvalidScope = fmt.Errorf("invalid service scope: must be 'user', 'system', or 'all'")
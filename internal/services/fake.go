@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// FakeManager is an in-memory ServiceManager for tests of code that
+// manages services. Start, Stop and Restart change the status of the
+// service in Services, and every action is recorded in Calls.
+type FakeManager struct {
+	mu       sync.Mutex
+	Services []Service
+	// Calls lists the actions taken, in order, as "start <name>", "stop
+	// <name>", "restart <name>", "enable <name>" or "disable <name>".
+	Calls []string
+	// LogLines holds the output Logs and FollowLogs return per service.
+	LogLines map[string][]string
+	// Errors makes every method fail with the error given for a service.
+	Errors map[string]error
+}
+
+// NewFakeManager returns a FakeManager managing svcs.
+func NewFakeManager(svcs ...Service) *FakeManager {
+	return &FakeManager{Services: svcs}
+}
+
+// Called returns the actions taken so far; see Calls.
+func (m *FakeManager) Called() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.Calls...)
+}
+
+func (m *FakeManager) ListServices() ([]Service, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Service(nil), m.Services...), nil
+}
+
+func (m *FakeManager) GetStatus(name string) (Service, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	svc, err := m.find(name)
+	if err != nil {
+		return Service{}, err
+	}
+	return *svc, nil
+}
+
+func (m *FakeManager) Start(name string) error {
+	return m.act("start", name, StatusRunning)
+}
+
+func (m *FakeManager) Stop(name string) error {
+	return m.act("stop", name, StatusStopped)
+}
+
+func (m *FakeManager) Restart(name string) error {
+	return m.act("restart", name, StatusRunning)
+}
+
+func (m *FakeManager) Enable(name string) error {
+	return m.act("enable", name, "")
+}
+
+func (m *FakeManager) Disable(name string) error {
+	return m.act("disable", name, "")
+}
+
+func (m *FakeManager) Logs(name string, lines int) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.find(name); err != nil {
+		return nil, err
+	}
+	logs := m.LogLines[name]
+	if lines > 0 && len(logs) > lines {
+		logs = logs[len(logs)-lines:]
+	}
+	return append([]string(nil), logs...), nil
+}
+
+// FollowLogs writes the service's LogLines to w and waits for ctx to be
+// cancelled.
+func (m *FakeManager) FollowLogs(ctx context.Context, name string, w io.Writer) error {
+	logs, err := m.Logs(name, 0)
+	if err != nil {
+		return err
+	}
+	for _, line := range logs {
+		fmt.Fprintln(w, line)
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func (m *FakeManager) UnitPath(name string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	svc, err := m.find(name)
+	if err != nil {
+		return "", err
+	}
+	return svc.PlistPath, nil
+}
+
+// act records action on name and, unless status is empty, sets the
+// service's status.
+func (m *FakeManager) act(action, name string, status ServiceStatus) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	svc, err := m.find(name)
+	if err != nil {
+		return err
+	}
+	m.Calls = append(m.Calls, action+" "+name)
+	switch status {
+	case StatusRunning:
+		svc.Status = StatusRunning
+	case StatusStopped:
+		svc.Status, svc.Pid = StatusStopped, 0
+	}
+	return nil
+}
+
+func (m *FakeManager) find(name string) (*Service, error) {
+	if err := m.Errors[name]; err != nil {
+		return nil, err
+	}
+	for i := range m.Services {
+		if m.Services[i].Name == name {
+			return &m.Services[i], nil
+		}
+	}
+	return nil, ServiceNotFoundError{Name: name}
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFakeManager(t *testing.T) {
+	var mgr ServiceManager = NewFakeManager(
+		Service{Name: "homebrew.mxcl.redis", Status: StatusRunning, Pid: 42, PlistPath: "/tmp/homebrew.mxcl.redis.plist"},
+		Service{Name: "homebrew.mxcl.nginx", Status: StatusStopped},
+	)
+	fake := mgr.(*FakeManager)
+
+	if err := mgr.Stop("homebrew.mxcl.redis"); err != nil {
+		t.Fatal(err)
+	}
+	if svc, _ := mgr.GetStatus("homebrew.mxcl.redis"); svc.Status != StatusStopped || svc.Pid != 0 {
+		t.Errorf("Expected redis to be stopped, got %+v", svc)
+	}
+	if err := mgr.Restart("homebrew.mxcl.nginx"); err != nil {
+		t.Fatal(err)
+	}
+	if svc, _ := mgr.GetStatus("homebrew.mxcl.nginx"); svc.Status != StatusRunning {
+		t.Errorf("Expected nginx to be running, got %+v", svc)
+	}
+	if path, err := mgr.UnitPath("homebrew.mxcl.redis"); err != nil || path != "/tmp/homebrew.mxcl.redis.plist" {
+		t.Errorf("UnitPath() = %s, %v", path, err)
+	}
+
+	var notFound ServiceNotFoundError
+	if err := mgr.Start("homebrew.mxcl.missing"); !errors.As(err, &notFound) {
+		t.Errorf("Expected ServiceNotFoundError for an unknown service, got %v", err)
+	}
+	fake.Errors = map[string]error{"homebrew.mxcl.nginx": errors.New("launchctl failed")}
+	if err := mgr.Stop("homebrew.mxcl.nginx"); err == nil {
+		t.Error("Expected the configured error")
+	}
+
+	want := []string{"stop homebrew.mxcl.redis", "restart homebrew.mxcl.nginx"}
+	if !reflect.DeepEqual(fake.Called(), want) {
+		t.Errorf("Called() = %v, want %v", fake.Called(), want)
+	}
+}
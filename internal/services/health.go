@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultHealthTimeout bounds a health check whose policy sets no timeout.
+const DefaultHealthTimeout = 5 * time.Second
+
+// HealthCheck probes whether a service does its job, beyond its process
+// running.
+type HealthCheck interface {
+	// Check returns nil when the service is healthy.
+	Check(ctx context.Context) error
+	String() string
+}
+
+// TCPCheck passes when Address (host:port) accepts a connection.
+type TCPCheck struct {
+	Address string
+}
+
+func (c TCPCheck) Check(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", c.Address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func (c TCPCheck) String() string { return "tcp " + c.Address }
+
+// HTTPCheck passes when a GET of URL answers with a status below 400.
+type HTTPCheck struct {
+	URL string
+}
+
+func (c HTTPCheck) Check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("%s returned %s", c.URL, resp.Status)
+	}
+	return nil
+}
+
+func (c HTTPCheck) String() string { return "http " + c.URL }
+
+// CommandCheck passes when Command, run by sh, exits with status 0.
+type CommandCheck struct {
+	Command string
+}
+
+func (c CommandCheck) Check(ctx context.Context) error {
+	out, err := exec.CommandContext(ctx, "sh", "-c", c.Command).CombinedOutput()
+	if err != nil {
+		if lines := strings.Split(strings.TrimSpace(string(out)), "\n"); lines[len(lines)-1] != "" {
+			return fmt.Errorf("%w: %s", err, lines[len(lines)-1])
+		}
+		return err
+	}
+	return nil
+}
+
+func (c CommandCheck) String() string { return "command " + c.Command }
+
+// HealthPolicy is the health check configured for one service, and what
+// the watchdog does when it fails.
+type HealthPolicy struct {
+	// Service is the service name or the name of its formula.
+	Service string
+	Check   HealthCheck
+	// Timeout bounds the check; DefaultHealthTimeout when zero.
+	Timeout time.Duration
+	// Restart lets the watchdog restart the service while it is unhealthy.
+	Restart bool
+}
+
+// Matches reports whether p applies to the service name.
+func (p HealthPolicy) Matches(name string) bool {
+	return p.Service == name || p.Service == FormulaName(name)
+}
+
+// Run runs p's check within its timeout.
+func (p HealthPolicy) Run(ctx context.Context) error {
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = DefaultHealthTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := p.Check.Check(ctx)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("no answer within %s", timeout)
+	}
+	return err
+}
+
+// LoadHealthPolicies reads the health checks configured in path. A missing
+// file configures none.
+func LoadHealthPolicies(path string) ([]HealthPolicy, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	policies, err := ParseHealthPolicies(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return policies, nil
+}
+
+// ParseHealthPolicies parses services.yaml: each unindented "name:" line
+// starts a service, followed by indented settings:
+//
+//	postgresql@14:
+//	  tcp: localhost:5432
+//	  restart: true
+//	web:
+//	  http: http://localhost:8080/health
+//	  timeout: 2s
+//
+// Each service has exactly one of tcp, http or command. Only this subset
+// of YAML is understood; comments start with #.
+func ParseHealthPolicies(data []byte) ([]HealthPolicy, error) {
+	var policies []HealthPolicy
+	seen := make(map[string]bool)
+	for i, line := range strings.Split(string(data), "\n") {
+		line = stripYAMLComment(strings.TrimRight(line, "\r"))
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", i+1)
+		}
+		key, value = unquoteYAML(strings.TrimSpace(key)), unquoteYAML(strings.TrimSpace(value))
+
+		if line[0] != ' ' && line[0] != '\t' {
+			if value != "" {
+				return nil, fmt.Errorf("line %d: the settings of %s go on the indented lines below it", i+1, key)
+			}
+			if seen[key] {
+				return nil, fmt.Errorf("line %d: %s is configured twice", i+1, key)
+			}
+			seen[key] = true
+			policies = append(policies, HealthPolicy{Service: key})
+			continue
+		}
+		if len(policies) == 0 {
+			return nil, fmt.Errorf("line %d: %s is not under a service", i+1, key)
+		}
+		p := &policies[len(policies)-1]
+		var err error
+		switch key {
+		case "tcp", "http", "command":
+			if p.Check != nil {
+				return nil, fmt.Errorf("line %d: %s already has a %s check", i+1, p.Service, p.Check)
+			}
+			if value == "" {
+				return nil, fmt.Errorf("line %d: %s needs a value", i+1, key)
+			}
+			switch key {
+			case "tcp":
+				p.Check = TCPCheck{Address: value}
+			case "http":
+				p.Check = HTTPCheck{URL: value}
+			default:
+				p.Check = CommandCheck{Command: value}
+			}
+		case "timeout":
+			p.Timeout, err = time.ParseDuration(value)
+		case "restart":
+			p.Restart, err = strconv.ParseBool(value)
+		default:
+			return nil, fmt.Errorf("line %d: unknown setting %s (want tcp, http, command, timeout or restart)", i+1, key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", i+1, key, err)
+		}
+	}
+	for _, p := range policies {
+		if p.Check == nil {
+			return nil, fmt.Errorf("%s has no tcp, http or command check", p.Service)
+		}
+	}
+	return policies, nil
+}
+
+// stripYAMLComment drops a # comment that starts the line or follows
+// whitespace, so URLs with fragments survive.
+func stripYAMLComment(line string) string {
+	for i, r := range line {
+		if r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t') {
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func unquoteYAML(s string) string {
+	if len(s) >= 2 {
+		switch {
+		case s[0] == '"' && s[len(s)-1] == '"':
+			if unquoted, err := strconv.Unquote(s); err == nil {
+				return unquoted
+			}
+		case s[0] == '\'' && s[len(s)-1] == '\'':
+			return strings.ReplaceAll(s[1:len(s)-1], "''", "'")
+		}
+	}
+	return s
+}
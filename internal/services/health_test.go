@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseHealthPolicies(t *testing.T) {
+	data := `# health checks
+postgresql@14:
+  tcp: localhost:5432
+  restart: true
+
+"homebrew.mxcl.web":
+  http: http://localhost:8080/health#ready  # comment
+  timeout: 2s
+redis:
+	command: 'redis-cli ping | grep -q PONG'
+`
+	policies, err := ParseHealthPolicies([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseHealthPolicies failed: %v", err)
+	}
+	want := []HealthPolicy{
+		{Service: "postgresql@14", Check: TCPCheck{Address: "localhost:5432"}, Restart: true},
+		{Service: "homebrew.mxcl.web", Check: HTTPCheck{URL: "http://localhost:8080/health#ready"}, Timeout: 2 * time.Second},
+		{Service: "redis", Check: CommandCheck{Command: "redis-cli ping | grep -q PONG"}},
+	}
+	if !reflect.DeepEqual(policies, want) {
+		t.Errorf("ParseHealthPolicies() = %+v, want %+v", policies, want)
+	}
+	if !policies[0].Matches("homebrew.mxcl.postgresql@14") || policies[0].Matches("homebrew.mxcl.postgresql@15") {
+		t.Error("Expected a policy to match its formula's service only")
+	}
+}
+
+func TestParseHealthPoliciesErrors(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{"redis: tcp\n", "line 1: the settings of redis go on the indented lines below it"},
+		{"  tcp: localhost:6379\n", "line 1: tcp is not under a service"},
+		{"redis:\n  tcp: localhost:6379\n  http: http://localhost\n", "line 3: redis already has a tcp localhost:6379 check"},
+		{"redis:\n  port: 6379\n", "line 2: unknown setting port"},
+		{"redis:\n  tcp: localhost:6379\n  timeout: soon\n", "line 3: timeout:"},
+		{"redis:\n  restart: true\n", "redis has no tcp, http or command check"},
+		{"redis:\n  tcp: a:1\nredis:\n  tcp: b:2\n", "line 3: redis is configured twice"},
+	}
+	for _, tt := range tests {
+		_, err := ParseHealthPolicies([]byte(tt.data))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseHealthPolicies(%q) error = %v, want %q", tt.data, err, tt.want)
+		}
+	}
+}
+
+func TestHealthChecks(t *testing.T) {
+	ctx := context.Background()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	if err := (HealthPolicy{Check: TCPCheck{Address: addr}}).Run(ctx); err != nil {
+		t.Errorf("Expected the tcp check of a listening port to pass: %v", err)
+	}
+	listener.Close()
+	if err := (HealthPolicy{Check: TCPCheck{Address: addr}}).Run(ctx); err == nil {
+		t.Error("Expected the tcp check of a closed port to fail")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	if err := (HealthPolicy{Check: HTTPCheck{URL: server.URL + "/health"}}).Run(ctx); err != nil {
+		t.Errorf("Expected the http check to pass: %v", err)
+	}
+	if err := (HealthPolicy{Check: HTTPCheck{URL: server.URL + "/broken"}}).Run(ctx); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("Expected the http check to fail with the status, got %v", err)
+	}
+	err = (HealthPolicy{Check: HTTPCheck{URL: server.URL + "/slow"}, Timeout: 20 * time.Millisecond}).Run(ctx)
+	if err == nil || err.Error() != "no answer within 20ms" {
+		t.Errorf("Expected the http check to time out, got %v", err)
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	if err := (HealthPolicy{Check: CommandCheck{Command: "true"}}).Run(ctx); err != nil {
+		t.Errorf("Expected the command check to pass: %v", err)
+	}
+	err = (HealthPolicy{Check: CommandCheck{Command: "echo not ready >&2; exit 3"}}).Run(ctx)
+	if err == nil || !strings.HasSuffix(err.Error(), ": not ready") {
+		t.Errorf("Expected the command check to fail with its output, got %v", err)
+	}
+}
+
+func TestLoadHealthPolicies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "services.yaml")
+	policies, err := LoadHealthPolicies(path)
+	if err != nil || policies != nil {
+		t.Errorf("LoadHealthPolicies() = %v, %v; want no policies for a missing file", policies, err)
+	}
+
+	if err := os.WriteFile(path, []byte("redis:\n  tcp localhost\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadHealthPolicies(path)
+	if err == nil || !strings.HasPrefix(err.Error(), path+": line 2:") {
+		t.Errorf("Expected the error to name the file and line, got %v", err)
+	}
+}
@@ -1,6 +1,12 @@
 package services
 
 import (
+	"context"
+	"fastbrew/internal/atomicfile"
+	"fastbrew/internal/log"
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -18,12 +24,17 @@ const (
 )
 
 type Service struct {
-	Name         string
-	Status       ServiceStatus
-	Pid          int
+	Name   string
+	Status ServiceStatus
+	Pid    int
+	// PlistPath is the service definition: a launchd plist or a systemd
+	// unit file.
 	PlistPath    string
 	Label        string
 	LastExitCode int
+	// Scope is ScopeUser for services defined in the user's home and
+	// ScopeSystem otherwise.
+	Scope ServiceScope
 }
 
 type LaunchdManager struct {
@@ -31,10 +42,14 @@ type LaunchdManager struct {
 	systemAgentPaths []string
 	parser           *PlistParser
 	runner           CommandRunner
+	logger           *slog.Logger
+	// envDir holds the environment files applied on start; see EnvDir.
+	envDir string
 }
 
 func NewLaunchdManager() *LaunchdManager {
 	homeDir, _ := os.UserHomeDir()
+	logger := log.For("services")
 
 	return &LaunchdManager{
 		userAgentPaths: []string{
@@ -45,13 +60,15 @@ func NewLaunchdManager() *LaunchdManager {
 			"/Library/LaunchDaemons",
 		},
 		parser: NewPlistParser(),
-		runner: &DefaultCommandRunner{},
+		runner: newLoggingRunner(&DefaultCommandRunner{}, logger),
+		logger: logger,
+		envDir: EnvDir(),
 	}
 }
 
 func NewLaunchdManagerWithRunner(runner CommandRunner) *LaunchdManager {
 	mgr := NewLaunchdManager()
-	mgr.runner = runner
+	mgr.runner = newLoggingRunner(runner, mgr.logger)
 	return mgr
 }
 
@@ -150,6 +167,14 @@ func (m *LaunchdManager) findPlistPath(serviceName string) string {
 	return ""
 }
 
+func (m *LaunchdManager) UnitPath(serviceName string) (string, error) {
+	plistPath := m.findPlistPath(serviceName)
+	if plistPath == "" {
+		return "", ServiceNotFoundError{Name: serviceName}
+	}
+	return plistPath, nil
+}
+
 func (m *LaunchdManager) getLaunchctlList() (map[string]launchctlEntry, error) {
 	output, err := m.runner.Run("launchctl", "list")
 	if err != nil {
@@ -222,6 +247,7 @@ func (m *LaunchdManager) parseServiceFromPlist(plistPath string, launchctlData m
 			Name:      name,
 			Status:    StatusError,
 			PlistPath: plistPath,
+			Scope:     m.scopeOf(plistPath),
 		}
 	}
 
@@ -236,6 +262,7 @@ func (m *LaunchdManager) parseServiceFromPlist(plistPath string, launchctlData m
 		Name:      name,
 		Label:     label,
 		PlistPath: plistPath,
+		Scope:     m.scopeOf(plistPath),
 	}
 
 	if !exists {
@@ -262,11 +289,21 @@ func (m *LaunchdManager) IsSystemService(plistPath string) bool {
 	return strings.HasPrefix(plistPath, "/Library/LaunchDaemons")
 }
 
+func (m *LaunchdManager) scopeOf(plistPath string) ServiceScope {
+	if m.IsUserService(plistPath) {
+		return ScopeUser
+	}
+	return ScopeSystem
+}
+
 func (m *LaunchdManager) Start(serviceName string) error {
 	plistPath := m.findPlistPath(serviceName)
 	if plistPath == "" {
 		return ServiceNotFoundError{Name: serviceName}
 	}
+	if err := m.applyEnvFile(plistPath, serviceName); err != nil {
+		return err
+	}
 
 	_, err := m.runner.Run("launchctl", "load", "-w", plistPath)
 	if err != nil {
@@ -308,6 +345,9 @@ func (m *LaunchdManager) Enable(serviceName string) error {
 	if plistPath == "" {
 		return ServiceNotFoundError{Name: serviceName}
 	}
+	if err := m.applyEnvFile(plistPath, serviceName); err != nil {
+		return err
+	}
 
 	_, err := m.runner.Run("launchctl", "load", "-w", plistPath)
 	if err != nil {
@@ -334,3 +374,102 @@ func (m *LaunchdManager) Disable(serviceName string) error {
 	}
 	return nil
 }
+
+// applyEnvFile merges the service's environment file into the
+// EnvironmentVariables of its plist, rewriting the plist only when a value
+// changes. Variables later removed from the file stay in the plist until
+// it is regenerated.
+func (m *LaunchdManager) applyEnvFile(plistPath, serviceName string) error {
+	env, err := LoadEnvFile(EnvFilePath(m.envDir, serviceName))
+	if err != nil || len(env) == 0 {
+		return err
+	}
+	data, err := os.ReadFile(plistPath)
+	if err != nil {
+		return err
+	}
+	root, err := decodePlist(data)
+	if err != nil {
+		return InvalidPlistError{Path: plistPath, Name: serviceName, Cause: err}
+	}
+	dict, ok := root.(map[string]any)
+	if !ok {
+		return InvalidPlistError{Path: plistPath, Name: serviceName, Cause: fmt.Errorf("top-level value is not a dictionary")}
+	}
+	vars, _ := dict["EnvironmentVariables"].(map[string]any)
+	if vars == nil {
+		vars = make(map[string]any)
+	}
+	changed := false
+	for key, value := range env {
+		if vars[key] != value {
+			vars[key] = value
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	dict["EnvironmentVariables"] = vars
+	m.logger.Debug("applying environment file", "service", serviceName, "plist", plistPath)
+	return atomicfile.WriteFile(plistPath, encodeXMLPlist(dict), 0644)
+}
+
+// Logs tails the files named by the plist's StandardOutPath and
+// StandardErrorPath.
+func (m *LaunchdManager) Logs(serviceName string, lines int) ([]string, error) {
+	paths, err := m.logPaths(serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []string
+	for _, path := range paths {
+		tail, err := tailFile(path, lines)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		out = append(out, tail...)
+	}
+	if lines > 0 && len(out) > lines {
+		out = out[len(out)-lines:]
+	}
+	return out, nil
+}
+
+// FollowLogs writes whatever is appended to the service's log files.
+func (m *LaunchdManager) FollowLogs(ctx context.Context, serviceName string, w io.Writer) error {
+	paths, err := m.logPaths(serviceName)
+	if err != nil {
+		return err
+	}
+	return newFileFollower(paths).run(ctx, w)
+}
+
+// logPaths returns the plist's StandardOutPath and StandardErrorPath,
+// once each.
+func (m *LaunchdManager) logPaths(serviceName string) ([]string, error) {
+	plistPath := m.findPlistPath(serviceName)
+	if plistPath == "" {
+		return nil, ServiceNotFoundError{Name: serviceName}
+	}
+
+	info, err := m.parser.ParseFile(plistPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, path := range []string{info.StandardOutPath, info.StandardErrorPath} {
+		if path != "" && (len(paths) == 0 || paths[0] != path) {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, NoLogsError{Name: serviceName}
+	}
+	return paths, nil
+}
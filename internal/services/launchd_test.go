@@ -1,6 +1,7 @@
 package services
 
 import (
+	"fastbrew/internal/cmdrunner"
 	"io"
 	"os"
 	"path/filepath"
@@ -35,6 +36,10 @@ func (m *mockCommandRunner) RunWithStdin(name string, stdin io.Reader, arg ...st
 	return m.Run(name, arg...)
 }
 
+func (m *mockCommandRunner) RunWithOptions(opts cmdrunner.Options, name string, arg ...string) ([]byte, error) {
+	return m.Run(name, arg...)
+}
+
 func (m *mockCommandRunner) setOutput(command string, output []byte) {
 	m.outputs[command] = output
 }
@@ -153,6 +158,25 @@ func TestIsHomebrewService(t *testing.T) {
 	}
 }
 
+func TestFormulaName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"homebrew.mxcl.postgresql@14", "postgresql@14"},
+		{"homebrew.redis", "redis"},
+		{"brew.services.redis", ""},
+		{"com.apple.test", ""},
+	}
+
+	for _, test := range tests {
+		result := FormulaName(test.name)
+		if result != test.expected {
+			t.Errorf("FormulaName(%s) = %s, expected %s", test.name, result, test.expected)
+		}
+	}
+}
+
 func TestPlistParser_Parse(t *testing.T) {
 	parser := NewPlistParser()
 
@@ -567,4 +591,74 @@ func TestLaunchdManager_findPlistPath(t *testing.T) {
 	if notFound != "" {
 		t.Errorf("findPlistPath() for nonexistent = %s, expected empty string", notFound)
 	}
+
+	if path, err := mgr.UnitPath("test"); err != nil || path != expected {
+		t.Errorf("UnitPath() = %s, %v; expected %s", path, err, expected)
+	}
+	if _, err := mgr.UnitPath("nonexistent"); err == nil {
+		t.Error("UnitPath() for nonexistent should return an error")
+	}
+}
+
+func TestLaunchdManager_Logs(t *testing.T) {
+	tmpDir := t.TempDir()
+	mgr := NewLaunchdManager()
+	mgr.userAgentPaths = []string{tmpDir}
+	mgr.systemAgentPaths = []string{}
+
+	logPath := filepath.Join(tmpDir, "redis.log")
+	os.WriteFile(logPath, []byte("one\ntwo\nthree\n"), 0644)
+
+	plistContent := `<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>homebrew.mxcl.redis</string>
+	<key>StandardOutPath</key>
+	<string>` + logPath + `</string>
+	<key>StandardErrorPath</key>
+	<string>` + logPath + `</string>
+</dict>
+</plist>`
+	os.WriteFile(filepath.Join(tmpDir, "homebrew.mxcl.redis.plist"), []byte(plistContent), 0644)
+
+	lines, err := mgr.Logs("homebrew.mxcl.redis", 2)
+	if err != nil {
+		t.Fatalf("Logs() returned error: %v", err)
+	}
+	if len(lines) != 2 || lines[0] != "two" || lines[1] != "three" {
+		t.Errorf("Logs() = %v, expected [two three]", lines)
+	}
+
+	os.WriteFile(filepath.Join(tmpDir, "quiet.plist"), []byte(`<plist><dict><key>Label</key><string>quiet</string></dict></plist>`), 0644)
+	if _, err := mgr.Logs("quiet", 10); err == nil {
+		t.Error("Logs() for a service without log paths should fail")
+	} else if _, ok := err.(NoLogsError); !ok {
+		t.Errorf("Logs() error = %T, expected NoLogsError", err)
+	}
+
+	if _, err := mgr.Logs("nonexistent", 10); err == nil {
+		t.Error("Logs() for nonexistent service should fail")
+	}
+}
+
+func TestLaunchdManager_parseServiceFromPlist_Scope(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	mgr := NewLaunchdManager()
+
+	plist := `<plist version="1.0"><dict><key>Label</key><string>homebrew.mxcl.redis</string></dict></plist>`
+	userPath := filepath.Join(home, "Library", "LaunchAgents", "homebrew.mxcl.redis.plist")
+	os.MkdirAll(filepath.Dir(userPath), 0755)
+	os.WriteFile(userPath, []byte(plist), 0644)
+
+	if service := mgr.parseServiceFromPlist(userPath, nil); service.Scope != ScopeUser {
+		t.Errorf("Scope = %s, expected %s", service.Scope, ScopeUser)
+	}
+
+	systemPath := filepath.Join(t.TempDir(), "homebrew.mxcl.redis.plist")
+	os.WriteFile(systemPath, []byte(plist), 0644)
+	if service := mgr.parseServiceFromPlist(systemPath, nil); service.Scope != ScopeSystem {
+		t.Errorf("Scope = %s, expected %s", service.Scope, ScopeSystem)
+	}
 }
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// logTailBytes bounds how much of a log file is read to find its last lines.
+const logTailBytes = 64 * 1024
+
+// followInterval is how often followed log files are checked for new data.
+var followInterval = 500 * time.Millisecond
+
+// tailFile returns up to n trailing lines of the file at path.
+func tailFile(path string, n int) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	offset := info.Size() - logTailBytes
+	if offset < 0 {
+		offset = 0
+	}
+	data, err := io.ReadAll(io.NewSectionReader(f, offset, info.Size()-offset))
+	if err != nil {
+		return nil, err
+	}
+
+	text := string(data)
+	if offset > 0 {
+		// Drop the partial first line.
+		if i := strings.IndexByte(text, '\n'); i >= 0 {
+			text = text[i+1:]
+		}
+	}
+	return lastLines(text, n), nil
+}
+
+// lastLines splits text into lines and keeps the last n.
+func lastLines(text string, n int) []string {
+	text = strings.TrimRight(text, "\n")
+	if text == "" {
+		return nil
+	}
+	lines := strings.Split(text, "\n")
+	if n > 0 && len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// fileFollower copies data appended to log files after it was created. A
+// file that shrinks was truncated or rotated and is read again from the
+// start; files that do not exist yet are picked up once they appear.
+type fileFollower struct {
+	paths   []string
+	offsets map[string]int64
+}
+
+func newFileFollower(paths []string) *fileFollower {
+	f := &fileFollower{paths: paths, offsets: make(map[string]int64, len(paths))}
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			f.offsets[path] = info.Size()
+		}
+	}
+	return f
+}
+
+// run writes new data to w until ctx is cancelled.
+func (f *fileFollower) run(ctx context.Context, w io.Writer) error {
+	ticker := time.NewTicker(followInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		for _, path := range f.paths {
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			offset := f.offsets[path]
+			if info.Size() < offset {
+				offset = 0
+			}
+			if info.Size() == offset {
+				continue
+			}
+
+			n, err := copyFrom(path, offset, w)
+			if err != nil {
+				return err
+			}
+			f.offsets[path] = offset + n
+		}
+	}
+}
+
+func copyFrom(path string, offset int64, w io.Writer) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.Copy(w, f)
+}
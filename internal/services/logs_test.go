@@ -0,0 +1,86 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTailFileReadsOnlyTheEnd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "big.log")
+	var b strings.Builder
+	for b.Len() < 2*logTailBytes {
+		b.WriteString("filler line that is long enough to matter\n")
+	}
+	b.WriteString("last\n")
+	os.WriteFile(path, []byte(b.String()), 0644)
+
+	lines, err := tailFile(path, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 3 || lines[2] != "last" {
+		t.Errorf("tailFile() = %v", lines)
+	}
+}
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestFileFollowerPrintsAppendedOutput(t *testing.T) {
+	old := followInterval
+	followInterval = 10 * time.Millisecond
+	defer func() { followInterval = old }()
+
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "out.log")
+	later := filepath.Join(dir, "err.log")
+	os.WriteFile(existing, []byte("already printed\n"), 0644)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var out syncBuffer
+	done := make(chan error)
+	follower := newFileFollower([]string{existing, later})
+	go func() { done <- follower.run(ctx, &out) }()
+
+	f, _ := os.OpenFile(existing, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString("appended\n")
+	f.Close()
+	os.WriteFile(later, []byte("new file\n"), 0644)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) && !(strings.Contains(out.String(), "appended") && strings.Contains(out.String(), "new file")) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("run() returned error: %v", err)
+	}
+
+	got := out.String()
+	if strings.Contains(got, "already printed") {
+		t.Errorf("run() repeated existing output: %q", got)
+	}
+	if !strings.Contains(got, "appended") || !strings.Contains(got, "new file") {
+		t.Errorf("run() = %q, expected appended lines from both files", got)
+	}
+}
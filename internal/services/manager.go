@@ -1,5 +1,10 @@
 package services
 
+import (
+	"context"
+	"io"
+)
+
 type ServiceScope string
 
 const (
@@ -8,6 +13,9 @@ const (
 	ScopeAll    ServiceScope = "all"
 )
 
+// ServiceManager manages Homebrew services through the platform's service
+// manager. NewServiceManager returns the one for this platform; FakeManager
+// stands in for it in tests.
 type ServiceManager interface {
 	ListServices() ([]Service, error)
 	GetStatus(name string) (Service, error)
@@ -16,6 +24,14 @@ type ServiceManager interface {
 	Restart(name string) error
 	Enable(name string) error
 	Disable(name string) error
+	// Logs returns up to lines of the service's most recent output.
+	Logs(name string, lines int) ([]string, error)
+	// FollowLogs writes the service's output to w as it is produced, until
+	// ctx is cancelled.
+	FollowLogs(ctx context.Context, name string, w io.Writer) error
+	// UnitPath returns the file defining the service: a launchd plist or a
+	// systemd unit.
+	UnitPath(name string) (string, error)
 }
 
 func NewServiceManagerWithScope(scope ServiceScope) (ServiceManager, error) {
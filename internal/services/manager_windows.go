@@ -2,7 +2,11 @@
 
 package services
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"io"
+)
 
 type WindowsServiceManager struct{}
 
@@ -49,3 +53,15 @@ func (m *WindowsServiceManager) Enable(name string) error {
 func (m *WindowsServiceManager) Disable(name string) error {
 	return errors.New("services management not supported on Windows")
 }
+
+func (m *WindowsServiceManager) Logs(name string, lines int) ([]string, error) {
+	return nil, errors.New("services management not supported on Windows")
+}
+
+func (m *WindowsServiceManager) FollowLogs(ctx context.Context, name string, w io.Writer) error {
+	return errors.New("services management not supported on Windows")
+}
+
+func (m *WindowsServiceManager) UnitPath(name string) (string, error) {
+	return "", errors.New("services management not supported on Windows")
+}
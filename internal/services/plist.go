@@ -1,34 +1,36 @@
 package services
 
 import (
+	"fastbrew/internal/cmdrunner"
 	"fmt"
+	"html"
 	"io"
+	"log/slog"
+	"maps"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"regexp"
+	"slices"
 	"strings"
 )
 
-var (
-	plistLabelRegex     = regexp.MustCompile(`<key>Label</key>\s*<string>([^<]+)</string>`)
-	plistProgramRegex   = regexp.MustCompile(`<key>Program</key>\s*<string>([^<]+)</string>`)
-	plistRunAtLoadRegex = regexp.MustCompile(`<key>RunAtLoad</key>\s*<true\s*/?>`)
-	plistStdoutRegex    = regexp.MustCompile(`<key>StandardOutPath</key>\s*<string>([^<]+)</string>`)
-	plistStderrRegex    = regexp.MustCompile(`<key>StandardErrorPath</key>\s*<string>([^<]+)</string>`)
-	plistWorkDirRegex   = regexp.MustCompile(`<key>WorkingDirectory</key>\s*<string>([^<]+)</string>`)
-)
-
+// ServiceInfo is the launchd job a plist defines.
 type ServiceInfo struct {
-	Label                string
-	Program              string
-	ProgramArgs          []string
-	RunAtLoad            bool
+	Label       string
+	Program     string
+	ProgramArgs []string
+	RunAtLoad   bool
+	// KeepAlive is set when launchd keeps the job running, always or under
+	// the KeepAliveConditions (e.g. SuccessfulExit: false).
 	KeepAlive            bool
+	KeepAliveConditions  map[string]bool
 	StandardOutPath      string
 	StandardErrorPath    string
 	WorkingDirectory     string
 	EnvironmentVariables map[string]string
+	// StartInterval runs the job every this many seconds.
+	StartInterval int
+	ProcessType   string
+	LowPriorityIO bool
 }
 
 type PlistParser struct{}
@@ -49,42 +51,129 @@ func (p *PlistParser) ParseFile(path string) (*ServiceInfo, error) {
 	return p.Parse(data, path)
 }
 
+// Parse decodes an XML or binary plist into a ServiceInfo. Keys it does
+// not model are ignored.
 func (p *PlistParser) Parse(data []byte, sourcePath string) (*ServiceInfo, error) {
-	content := string(data)
-
-	labelMatch := plistLabelRegex.FindStringSubmatch(content)
-	if len(labelMatch) < 2 {
-		return nil, InvalidPlistError{
-			Path:  sourcePath,
-			Name:  filepath.Base(sourcePath),
-			Cause: fmt.Errorf("missing required Label field"),
-		}
+	invalid := func(cause error) error {
+		return InvalidPlistError{Path: sourcePath, Name: filepath.Base(sourcePath), Cause: cause}
+	}
+	root, err := decodePlist(data)
+	if err != nil {
+		return nil, invalid(err)
+	}
+	dict, ok := root.(map[string]any)
+	if !ok {
+		return nil, invalid(fmt.Errorf("top-level value is not a dictionary"))
+	}
+	label, _ := dict["Label"].(string)
+	if label == "" {
+		return nil, invalid(fmt.Errorf("missing required Label field"))
 	}
 
 	info := &ServiceInfo{
-		Label:                labelMatch[1],
+		Label:                label,
 		EnvironmentVariables: make(map[string]string),
 	}
-
-	if programMatch := plistProgramRegex.FindStringSubmatch(content); len(programMatch) >= 2 {
-		info.Program = programMatch[1]
+	info.Program, _ = dict["Program"].(string)
+	if args, ok := dict["ProgramArguments"].([]any); ok {
+		for _, arg := range args {
+			if s, ok := arg.(string); ok {
+				info.ProgramArgs = append(info.ProgramArgs, s)
+			}
+		}
+	}
+	info.RunAtLoad, _ = dict["RunAtLoad"].(bool)
+	switch keepAlive := dict["KeepAlive"].(type) {
+	case bool:
+		info.KeepAlive = keepAlive
+	case map[string]any:
+		info.KeepAlive = true
+		for key, value := range keepAlive {
+			if b, ok := value.(bool); ok {
+				if info.KeepAliveConditions == nil {
+					info.KeepAliveConditions = make(map[string]bool)
+				}
+				info.KeepAliveConditions[key] = b
+			}
+		}
 	}
+	info.StandardOutPath, _ = dict["StandardOutPath"].(string)
+	info.StandardErrorPath, _ = dict["StandardErrorPath"].(string)
+	info.WorkingDirectory, _ = dict["WorkingDirectory"].(string)
+	if env, ok := dict["EnvironmentVariables"].(map[string]any); ok {
+		for key, value := range env {
+			if s, ok := value.(string); ok {
+				info.EnvironmentVariables[key] = s
+			}
+		}
+	}
+	if interval, ok := dict["StartInterval"].(int64); ok {
+		info.StartInterval = int(interval)
+	}
+	info.ProcessType, _ = dict["ProcessType"].(string)
+	info.LowPriorityIO, _ = dict["LowPriorityIO"].(bool)
 
-	info.RunAtLoad = plistRunAtLoadRegex.MatchString(content)
+	return info, nil
+}
 
-	if stdoutMatch := plistStdoutRegex.FindStringSubmatch(content); len(stdoutMatch) >= 2 {
-		info.StandardOutPath = stdoutMatch[1]
+// Plist renders info as an XML property list, leaving out unset fields.
+func (info *ServiceInfo) Plist() []byte {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+`)
+	writeString := func(key, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "\t<key>%s</key>\n\t<string>%s</string>\n", key, html.EscapeString(value))
+		}
 	}
-
-	if stderrMatch := plistStderrRegex.FindStringSubmatch(content); len(stderrMatch) >= 2 {
-		info.StandardErrorPath = stderrMatch[1]
+	writeTrue := func(key string) {
+		fmt.Fprintf(&b, "\t<key>%s</key>\n\t<true/>\n", key)
 	}
 
-	if workDirMatch := plistWorkDirRegex.FindStringSubmatch(content); len(workDirMatch) >= 2 {
-		info.WorkingDirectory = workDirMatch[1]
+	writeString("Label", info.Label)
+	writeString("Program", info.Program)
+	if len(info.ProgramArgs) > 0 {
+		b.WriteString("\t<key>ProgramArguments</key>\n\t<array>\n")
+		for _, arg := range info.ProgramArgs {
+			fmt.Fprintf(&b, "\t\t<string>%s</string>\n", html.EscapeString(arg))
+		}
+		b.WriteString("\t</array>\n")
 	}
-
-	return info, nil
+	if len(info.EnvironmentVariables) > 0 {
+		b.WriteString("\t<key>EnvironmentVariables</key>\n\t<dict>\n")
+		for _, key := range slices.Sorted(maps.Keys(info.EnvironmentVariables)) {
+			fmt.Fprintf(&b, "\t\t<key>%s</key>\n\t\t<string>%s</string>\n", html.EscapeString(key), html.EscapeString(info.EnvironmentVariables[key]))
+		}
+		b.WriteString("\t</dict>\n")
+	}
+	writeString("WorkingDirectory", info.WorkingDirectory)
+	if info.RunAtLoad {
+		writeTrue("RunAtLoad")
+	}
+	switch {
+	case len(info.KeepAliveConditions) > 0:
+		b.WriteString("\t<key>KeepAlive</key>\n\t<dict>\n")
+		for _, key := range slices.Sorted(maps.Keys(info.KeepAliveConditions)) {
+			fmt.Fprintf(&b, "\t\t<key>%s</key>\n\t\t<%t/>\n", html.EscapeString(key), info.KeepAliveConditions[key])
+		}
+		b.WriteString("\t</dict>\n")
+	case info.KeepAlive:
+		writeTrue("KeepAlive")
+	}
+	if info.StartInterval > 0 {
+		fmt.Fprintf(&b, "\t<key>StartInterval</key>\n\t<integer>%d</integer>\n", info.StartInterval)
+	}
+	writeString("ProcessType", info.ProcessType)
+	if info.LowPriorityIO {
+		writeTrue("LowPriorityIO")
+	}
+	writeString("StandardOutPath", info.StandardOutPath)
+	writeString("StandardErrorPath", info.StandardErrorPath)
+	b.WriteString("</dict>\n</plist>\n")
+	return []byte(b.String())
 }
 
 func GetServiceNameFromPath(path string) string {
@@ -101,20 +190,55 @@ func IsHomebrewService(name string) bool {
 	return strings.Contains(lowerName, "homebrew") || strings.Contains(lowerName, "brew")
 }
 
-type CommandRunner interface {
-	Run(name string, arg ...string) ([]byte, error)
-	RunWithStdin(name string, stdin io.Reader, arg ...string) ([]byte, error)
+// FormulaName returns the formula a Homebrew service runs, taken from its
+// launchd label (homebrew.mxcl.<formula>) or systemd unit name
+// (homebrew.<formula>), or "" for services not named that way.
+func FormulaName(service string) string {
+	for _, prefix := range []string{"homebrew.mxcl.", "homebrew."} {
+		if name, ok := strings.CutPrefix(service, prefix); ok {
+			return name
+		}
+	}
+	return ""
+}
+
+// CommandRunner runs launchctl and systemctl; see cmdrunner.
+type CommandRunner = cmdrunner.CommandRunner
+
+type DefaultCommandRunner = cmdrunner.DefaultCommandRunner
+
+// loggingRunner records every service-manager command and its outcome.
+type loggingRunner struct {
+	runner CommandRunner
+	logger *slog.Logger
+}
+
+func newLoggingRunner(runner CommandRunner, logger *slog.Logger) CommandRunner {
+	return &loggingRunner{runner: runner, logger: logger}
 }
 
-type DefaultCommandRunner struct{}
+func (r *loggingRunner) Run(name string, arg ...string) ([]byte, error) {
+	out, err := r.runner.Run(name, arg...)
+	r.log(name, arg, err)
+	return out, err
+}
+
+func (r *loggingRunner) RunWithStdin(name string, stdin io.Reader, arg ...string) ([]byte, error) {
+	out, err := r.runner.RunWithStdin(name, stdin, arg...)
+	r.log(name, arg, err)
+	return out, err
+}
 
-func (d *DefaultCommandRunner) Run(name string, arg ...string) ([]byte, error) {
-	cmd := exec.Command(name, arg...)
-	return cmd.Output()
+func (r *loggingRunner) RunWithOptions(opts cmdrunner.Options, name string, arg ...string) ([]byte, error) {
+	out, err := r.runner.RunWithOptions(opts, name, arg...)
+	r.log(name, arg, err)
+	return out, err
 }
 
-func (d *DefaultCommandRunner) RunWithStdin(name string, stdin io.Reader, arg ...string) ([]byte, error) {
-	cmd := exec.Command(name, arg...)
-	cmd.Stdin = stdin
-	return cmd.Output()
+func (r *loggingRunner) log(name string, arg []string, err error) {
+	if err != nil {
+		r.logger.Warn("command failed", "command", name, "args", arg, "error", err)
+		return
+	}
+	r.logger.Debug("command succeeded", "command", name, "args", arg)
 }
@@ -0,0 +1,423 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Property list values decode to map[string]any (dict), []any (array),
+// string, int64 (integer and UID), float64 (real), bool, time.Time (date)
+// and []byte (data).
+
+// maxPlistDepth bounds nesting, which also stops reference cycles in
+// binary plists.
+const maxPlistDepth = 64
+
+// binaryPlistMagic starts binary property lists.
+const binaryPlistMagic = "bplist00"
+
+// plistEpoch is the reference date of binary plist dates.
+var plistEpoch = time.Date(2001, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// decodePlist decodes an XML or binary property list.
+func decodePlist(data []byte) (any, error) {
+	if bytes.HasPrefix(data, []byte(binaryPlistMagic)) {
+		return decodeBinaryPlist(data)
+	}
+	return decodeXMLPlist(data)
+}
+
+// errPlistEnd is returned by nextXMLStart at the end of the enclosing
+// element.
+var errPlistEnd = errors.New("end of element")
+
+func decodeXMLPlist(data []byte) (any, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	root, err := nextXMLStart(d)
+	if err != nil {
+		return nil, fmt.Errorf("no plist element: %w", err)
+	}
+	if root.Name.Local != "plist" {
+		return nil, fmt.Errorf("root element is <%s>, not <plist>", root.Name.Local)
+	}
+	top, err := nextXMLStart(d)
+	if err != nil {
+		return nil, fmt.Errorf("empty plist: %w", err)
+	}
+	return decodeXMLValue(d, top, 0)
+}
+
+// nextXMLStart returns the next start element, skipping text, comments and
+// directives.
+func nextXMLStart(d *xml.Decoder) (xml.StartElement, error) {
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			return tok, nil
+		case xml.EndElement:
+			return xml.StartElement{}, errPlistEnd
+		}
+	}
+}
+
+func decodeXMLValue(d *xml.Decoder, se xml.StartElement, depth int) (any, error) {
+	if depth > maxPlistDepth {
+		return nil, fmt.Errorf("plist nested deeper than %d", maxPlistDepth)
+	}
+	switch se.Name.Local {
+	case "dict":
+		dict := make(map[string]any)
+		for {
+			keyElem, err := nextXMLStart(d)
+			if err == errPlistEnd {
+				return dict, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			if keyElem.Name.Local != "key" {
+				return nil, fmt.Errorf("expected <key> in dict, found <%s>", keyElem.Name.Local)
+			}
+			var key string
+			if err := d.DecodeElement(&key, &keyElem); err != nil {
+				return nil, err
+			}
+			valueElem, err := nextXMLStart(d)
+			if err != nil {
+				return nil, fmt.Errorf("key %s has no value", key)
+			}
+			if dict[key], err = decodeXMLValue(d, valueElem, depth+1); err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+		}
+	case "array":
+		array := []any{}
+		for {
+			elem, err := nextXMLStart(d)
+			if err == errPlistEnd {
+				return array, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeXMLValue(d, elem, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+	case "true", "false":
+		if err := d.Skip(); err != nil {
+			return nil, err
+		}
+		return se.Name.Local == "true", nil
+	}
+
+	var text string
+	if err := d.DecodeElement(&text, &se); err != nil {
+		return nil, err
+	}
+	switch se.Name.Local {
+	case "string":
+		return text, nil
+	case "integer":
+		return strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+	case "real":
+		return strconv.ParseFloat(strings.TrimSpace(text), 64)
+	case "date":
+		return time.Parse(time.RFC3339, strings.TrimSpace(text))
+	case "data":
+		return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(text), ""))
+	default:
+		return nil, fmt.Errorf("unknown plist element <%s>", se.Name.Local)
+	}
+}
+
+// binaryPlist decodes the objects of a bplist00 file.
+type binaryPlist struct {
+	data        []byte
+	offsetSize  int
+	refSize     int
+	numObjects  uint64
+	offsetTable int
+}
+
+func decodeBinaryPlist(data []byte) (any, error) {
+	if len(data) < len(binaryPlistMagic)+32 {
+		return nil, errors.New("binary plist is truncated")
+	}
+	trailer := data[len(data)-32:]
+	b := &binaryPlist{
+		data:       data[:len(data)-32],
+		offsetSize: int(trailer[6]),
+		refSize:    int(trailer[7]),
+		numObjects: binary.BigEndian.Uint64(trailer[8:16]),
+	}
+	top := binary.BigEndian.Uint64(trailer[16:24])
+	tableOffset := binary.BigEndian.Uint64(trailer[24:32])
+	if b.offsetSize < 1 || b.offsetSize > 8 || b.refSize < 1 || b.refSize > 8 {
+		return nil, errors.New("binary plist has invalid integer sizes")
+	}
+	if tableOffset > uint64(len(b.data)) || b.numObjects > (uint64(len(b.data))-tableOffset)/uint64(b.offsetSize) || top >= b.numObjects {
+		return nil, errors.New("binary plist has an invalid offset table")
+	}
+	b.offsetTable = int(tableOffset)
+	return b.object(top, 0)
+}
+
+func (b *binaryPlist) slice(start, n int) ([]byte, error) {
+	if start < 0 || n < 0 || start > len(b.data) || n > len(b.data)-start {
+		return nil, errors.New("binary plist object runs past the end of the file")
+	}
+	return b.data[start : start+n], nil
+}
+
+func readUint(p []byte) uint64 {
+	var v uint64
+	for _, c := range p {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+// length returns the element count of the object whose marker is at off,
+// and where its contents start.
+func (b *binaryPlist) length(off int, info byte) (int, int, error) {
+	if info != 0xF {
+		return int(info), off + 1, nil
+	}
+	marker, err := b.slice(off+1, 1)
+	if err != nil {
+		return 0, 0, err
+	}
+	if marker[0]>>4 != 0x1 {
+		return 0, 0, errors.New("binary plist length is not an integer")
+	}
+	size := 1 << (marker[0] & 0xF)
+	p, err := b.slice(off+2, size)
+	if err != nil {
+		return 0, 0, err
+	}
+	n := readUint(p)
+	if n > uint64(len(b.data)) {
+		return 0, 0, errors.New("binary plist length is out of range")
+	}
+	return int(n), off + 2 + size, nil
+}
+
+func (b *binaryPlist) refs(start, n int) ([]uint64, error) {
+	if n > len(b.data)/b.refSize {
+		return nil, errors.New("binary plist collection is too long")
+	}
+	p, err := b.slice(start, n*b.refSize)
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]uint64, n)
+	for i := range refs {
+		refs[i] = readUint(p[i*b.refSize : (i+1)*b.refSize])
+	}
+	return refs, nil
+}
+
+func (b *binaryPlist) object(ref uint64, depth int) (any, error) {
+	if depth > maxPlistDepth {
+		return nil, fmt.Errorf("plist nested deeper than %d", maxPlistDepth)
+	}
+	if ref >= b.numObjects {
+		return nil, errors.New("binary plist object reference is out of range")
+	}
+	entry, err := b.slice(b.offsetTable+int(ref)*b.offsetSize, b.offsetSize)
+	if err != nil {
+		return nil, err
+	}
+	off := int(readUint(entry))
+	markerBytes, err := b.slice(off, 1)
+	if err != nil {
+		return nil, err
+	}
+	kind, info := markerBytes[0]>>4, markerBytes[0]&0xF
+
+	switch kind {
+	case 0x0:
+		switch info {
+		case 0x8:
+			return false, nil
+		case 0x9:
+			return true, nil
+		}
+		return nil, nil
+	case 0x1, 0x8:
+		size := 1 << info
+		if kind == 0x8 {
+			size = int(info) + 1
+		}
+		p, err := b.slice(off+1, size)
+		if err != nil {
+			return nil, err
+		}
+		if size > 8 {
+			p = p[size-8:]
+		}
+		return int64(readUint(p)), nil
+	case 0x2:
+		p, err := b.slice(off+1, 1<<info)
+		if err != nil {
+			return nil, err
+		}
+		switch len(p) {
+		case 4:
+			return float64(math.Float32frombits(binary.BigEndian.Uint32(p))), nil
+		case 8:
+			return math.Float64frombits(binary.BigEndian.Uint64(p)), nil
+		}
+		return nil, errors.New("binary plist real has an unsupported size")
+	case 0x3:
+		p, err := b.slice(off+1, 8)
+		if err != nil {
+			return nil, err
+		}
+		seconds := math.Float64frombits(binary.BigEndian.Uint64(p))
+		return plistEpoch.Add(time.Duration(seconds * float64(time.Second))), nil
+	case 0x4, 0x5, 0x6:
+		n, start, err := b.length(off, info)
+		if err != nil {
+			return nil, err
+		}
+		if kind == 0x6 {
+			if n > len(b.data)/2 {
+				return nil, errors.New("binary plist string is too long")
+			}
+			p, err := b.slice(start, 2*n)
+			if err != nil {
+				return nil, err
+			}
+			units := make([]uint16, n)
+			for i := range units {
+				units[i] = binary.BigEndian.Uint16(p[2*i:])
+			}
+			return string(utf16.Decode(units)), nil
+		}
+		p, err := b.slice(start, n)
+		if err != nil {
+			return nil, err
+		}
+		if kind == 0x4 {
+			return append([]byte(nil), p...), nil
+		}
+		return string(p), nil
+	case 0xA:
+		n, start, err := b.length(off, info)
+		if err != nil {
+			return nil, err
+		}
+		refs, err := b.refs(start, n)
+		if err != nil {
+			return nil, err
+		}
+		array := make([]any, n)
+		for i, r := range refs {
+			if array[i], err = b.object(r, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return array, nil
+	case 0xD:
+		n, start, err := b.length(off, info)
+		if err != nil {
+			return nil, err
+		}
+		refs, err := b.refs(start, 2*n)
+		if err != nil {
+			return nil, err
+		}
+		dict := make(map[string]any, n)
+		for i := 0; i < n; i++ {
+			key, err := b.object(refs[i], depth+1)
+			if err != nil {
+				return nil, err
+			}
+			keyString, ok := key.(string)
+			if !ok {
+				return nil, errors.New("binary plist dict key is not a string")
+			}
+			if dict[keyString], err = b.object(refs[n+i], depth+1); err != nil {
+				return nil, fmt.Errorf("%s: %w", keyString, err)
+			}
+		}
+		return dict, nil
+	default:
+		return nil, fmt.Errorf("binary plist object type 0x%x is not supported", kind)
+	}
+}
+
+// encodeXMLPlist renders a value decoded by decodePlist as an XML property
+// list, with dict keys sorted. Binary plist nulls, which XML cannot
+// express, are left out.
+func encodeXMLPlist(v any) []byte {
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+`)
+	encodeXMLValue(&b, v, 0)
+	b.WriteString("</plist>\n")
+	return b.Bytes()
+}
+
+func encodeXMLValue(b *bytes.Buffer, v any, depth int) {
+	indent := strings.Repeat("\t", depth)
+	escape := func(s string) string {
+		var e bytes.Buffer
+		xml.EscapeText(&e, []byte(s))
+		return e.String()
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		b.WriteString(indent + "<dict>\n")
+		keys := make([]string, 0, len(v))
+		for key, value := range v {
+			if value != nil {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(b, "%s\t<key>%s</key>\n", indent, escape(key))
+			encodeXMLValue(b, v[key], depth+1)
+		}
+		b.WriteString(indent + "</dict>\n")
+	case []any:
+		b.WriteString(indent + "<array>\n")
+		for _, elem := range v {
+			encodeXMLValue(b, elem, depth+1)
+		}
+		b.WriteString(indent + "</array>\n")
+	case string:
+		fmt.Fprintf(b, "%s<string>%s</string>\n", indent, escape(v))
+	case int64:
+		fmt.Fprintf(b, "%s<integer>%d</integer>\n", indent, v)
+	case float64:
+		fmt.Fprintf(b, "%s<real>%s</real>\n", indent, strconv.FormatFloat(v, 'g', -1, 64))
+	case bool:
+		fmt.Fprintf(b, "%s<%t/>\n", indent, v)
+	case time.Time:
+		fmt.Fprintf(b, "%s<date>%s</date>\n", indent, v.UTC().Format(time.RFC3339))
+	case []byte:
+		fmt.Fprintf(b, "%s<data>%s</data>\n", indent, base64.StdEncoding.EncodeToString(v))
+	}
+}
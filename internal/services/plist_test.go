@@ -0,0 +1,139 @@
+package services
+
+import (
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// homebrewPlist is a plist shaped like the ones brew services writes.
+const homebrewPlist = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>EnvironmentVariables</key>
+	<dict>
+		<key>LC_ALL</key>
+		<string>en_US.UTF-8</string>
+	</dict>
+	<key>KeepAlive</key>
+	<dict>
+		<key>SuccessfulExit</key>
+		<false/>
+	</dict>
+	<key>Label</key>
+	<string>homebrew.mxcl.postgresql@14</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>/opt/homebrew/opt/postgresql@14/bin/postgres</string>
+		<string>-D</string>
+		<string>/opt/homebrew/var/postgresql@14</string>
+	</array>
+	<key>RunAtLoad</key>
+	<true/>
+	<!-- keys fastbrew does not model are skipped -->
+	<key>Sockets</key>
+	<dict>
+		<key>Listeners</key>
+		<array><dict><key>SockServiceName</key><integer>5432</integer></dict></array>
+	</dict>
+	<key>StandardErrorPath</key>
+	<string>/opt/homebrew/var/log/postgresql@14.log</string>
+	<key>StartInterval</key>
+	<integer>300</integer>
+	<key>WorkingDirectory</key>
+	<string>/opt/homebrew</string>
+</dict>
+</plist>`
+
+// binaryHomebrewPlist is homebrewPlist, less Sockets and plus a UTF-16
+// Comment, written by Python's plistlib in the binary format.
+const binaryHomebrewPlist = "YnBsaXN0MDDZAQIDBAUGBwgJCgsOERIWFxgZV0NvbW1lbnRfEBRFbnZpcm9ubWVudFZhcmlhYmxlc1lLZWVwQWxpdmVVTGFiZWxfEBBQcm9ncmFtQXJndW1lbnRzWVJ1bkF0TG9hZF8QEVN0YW5kYXJkRXJyb3JQYXRoXVN0YXJ0SW50ZXJ2YWxfEBBXb3JraW5nRGlyZWN0b3J5ZwBuAGEA7wB2AGUAICcT0QwNVkxDX0FMTFtlbl9VUy5VVEYtONEPEF5TdWNjZXNzZnVsRXhpdAhfEBtob21lYnJldy5teGNsLnBvc3RncmVzcWxAMTSjExQVXxAsL29wdC9ob21lYnJldy9vcHQvcG9zdGdyZXNxbEAxNC9iaW4vcG9zdGdyZXNSLURfEB8vb3B0L2hvbWVicmV3L3Zhci9wb3N0Z3Jlc3FsQDE0CV8QJy9vcHQvaG9tZWJyZXcvdmFyL2xvZy9wb3N0Z3Jlc3FsQDE0LmxvZxEBLF0vb3B0L2hvbWVicmV3AAgAGwAjADoARABKAF0AZwB7AIkAnACrAK4AtQDBAMQA0wDUAPIA9gElASgBSgFLAXUBeAAAAAAAAAIBAAAAAAAAABoAAAAAAAAAAAAAAAAAAAGG"
+
+var wantHomebrewInfo = &ServiceInfo{
+	Label:                "homebrew.mxcl.postgresql@14",
+	ProgramArgs:          []string{"/opt/homebrew/opt/postgresql@14/bin/postgres", "-D", "/opt/homebrew/var/postgresql@14"},
+	RunAtLoad:            true,
+	KeepAlive:            true,
+	KeepAliveConditions:  map[string]bool{"SuccessfulExit": false},
+	StandardErrorPath:    "/opt/homebrew/var/log/postgresql@14.log",
+	WorkingDirectory:     "/opt/homebrew",
+	EnvironmentVariables: map[string]string{"LC_ALL": "en_US.UTF-8"},
+	StartInterval:        300,
+}
+
+func TestPlistParser_ParseAllFields(t *testing.T) {
+	info, err := NewPlistParser().Parse([]byte(homebrewPlist), "homebrew.mxcl.postgresql@14.plist")
+	if err != nil {
+		t.Fatalf("Parse() returned error: %v", err)
+	}
+	if !reflect.DeepEqual(info, wantHomebrewInfo) {
+		t.Errorf("Parse() = %+v, expected %+v", info, wantHomebrewInfo)
+	}
+}
+
+func TestPlistParser_ParseBinary(t *testing.T) {
+	data, err := base64.StdEncoding.DecodeString(binaryHomebrewPlist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := NewPlistParser().Parse(data, "homebrew.mxcl.postgresql@14.plist")
+	if err != nil {
+		t.Fatalf("Parse() returned error: %v", err)
+	}
+	if !reflect.DeepEqual(info, wantHomebrewInfo) {
+		t.Errorf("Parse() = %+v, expected %+v", info, wantHomebrewInfo)
+	}
+
+	root, _ := decodePlist(data)
+	if comment := root.(map[string]any)["Comment"]; comment != "naïve ✓" {
+		t.Errorf("Comment = %q, expected the UTF-16 string decoded", comment)
+	}
+
+	// Truncated and corrupted files fail without panicking.
+	for _, n := range []int{8, 40, len(data) / 2, len(data) - 1} {
+		if _, err := decodePlist(data[:n]); err == nil {
+			t.Errorf("decodePlist() of %d of %d bytes should fail", n, len(data))
+		}
+	}
+	corrupt := append([]byte(nil), data...)
+	for i := 8; i < len(corrupt)-32; i += 7 {
+		corrupt[i] = 0xFF
+	}
+	decodePlist(corrupt)
+}
+
+func TestServiceInfoPlistRoundTrip(t *testing.T) {
+	info := *wantHomebrewInfo
+	info.ProcessType = "Background"
+	info.LowPriorityIO = true
+	info.EnvironmentVariables = map[string]string{"LC_ALL": "en_US.UTF-8", "OPTS": `--name "a&b" <x>`}
+
+	data := info.Plist()
+	if !strings.Contains(string(data), "<string>--name &#34;a&amp;b&#34; &lt;x&gt;</string>") {
+		t.Errorf("values should be escaped:\n%s", data)
+	}
+	parsed, err := NewPlistParser().Parse(data, "roundtrip.plist")
+	if err != nil {
+		t.Fatalf("generated plist does not parse: %v\n%s", err, data)
+	}
+	if !reflect.DeepEqual(parsed, &info) {
+		t.Errorf("round trip = %+v, expected %+v", parsed, &info)
+	}
+}
+
+func TestPlistParser_ParseInvalid(t *testing.T) {
+	for _, data := range []string{
+		"",
+		"<html></html>",
+		"<plist><array><string>x</string></array></plist>",
+		"<plist><dict><key>Label</key></dict></plist>",
+		"<plist><dict><key>Label</key><string>x</string><key>StartInterval</key><integer>soon</integer></dict></plist>",
+	} {
+		_, err := NewPlistParser().Parse([]byte(data), "bad.plist")
+		if _, ok := err.(InvalidPlistError); !ok {
+			t.Errorf("Parse(%q) = %v, expected InvalidPlistError", data, err)
+		}
+	}
+}
@@ -1,23 +1,66 @@
 package services
 
 import (
+	"bytes"
+	"context"
+	"fastbrew/internal/atomicfile"
+	"fastbrew/internal/log"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+
+	sdbus "github.com/coreos/go-systemd/v22/dbus"
 )
 
+// systemdBusTimeout bounds connecting to a systemd manager over D-Bus and
+// reading the state of the units of one listing.
+const systemdBusTimeout = 5 * time.Second
+
+// systemdBus is the part of a D-Bus connection to a systemd manager that
+// unitStates uses; *sdbus.Conn implements it.
+type systemdBus interface {
+	GetUnitPropertiesContext(ctx context.Context, unit string) (map[string]any, error)
+	GetUnitTypePropertiesContext(ctx context.Context, unit, unitType string) (map[string]any, error)
+	Close()
+}
+
+// connectSystemdBus connects to the user or system manager, by the
+// systemctl scope flag.
+func connectSystemdBus(ctx context.Context, scope string) (systemdBus, error) {
+	if scope == "--user" {
+		return sdbus.NewUserConnectionContext(ctx)
+	}
+	return sdbus.NewSystemConnectionContext(ctx)
+}
+
 // SystemdManager manages systemd services on Linux
 type SystemdManager struct {
 	userServicePaths   []string
 	systemServicePaths []string
 	parser             *ServiceFileParser
 	runner             CommandRunner
+	logger             *slog.Logger
+	// envDir holds the environment files applied on start; see EnvDir.
+	envDir string
+	// isRoot reports whether system services can be managed.
+	isRoot func() bool
+	// connectBus connects to the systemd manager of a scope to read unit
+	// state; when nil, or when it fails, systemctl is run instead.
+	connectBus func(ctx context.Context, scope string) (systemdBus, error)
 }
 
 // NewSystemdManager creates a new SystemdManager with default paths
 func NewSystemdManager() *SystemdManager {
 	homeDir, _ := os.UserHomeDir()
+	logger := log.For("services")
 
 	return &SystemdManager{
 		userServicePaths: []string{
@@ -27,37 +70,52 @@ func NewSystemdManager() *SystemdManager {
 			"/etc/systemd/system",
 			"/usr/lib/systemd/system",
 		},
-		parser: NewServiceFileParser(),
-		runner: &DefaultCommandRunner{},
+		parser:     NewServiceFileParser(),
+		runner:     newLoggingRunner(&DefaultCommandRunner{}, logger),
+		logger:     logger,
+		envDir:     EnvDir(),
+		isRoot:     func() bool { return os.Geteuid() == 0 },
+		connectBus: connectSystemdBus,
 	}
 }
 
-// NewSystemdManagerWithRunner creates a new SystemdManager with a custom command runner (for testing)
+// NewSystemdManagerWithRunner creates a new SystemdManager with a custom command runner (for testing).
+// Unit state is read through the runner too, not over D-Bus.
 func NewSystemdManagerWithRunner(runner CommandRunner) *SystemdManager {
 	mgr := NewSystemdManager()
-	mgr.runner = runner
+	mgr.runner = newLoggingRunner(runner, mgr.logger)
+	mgr.connectBus = nil
 	return mgr
 }
 
-// ListServices returns a list of all Homebrew systemd services
+// ListServices returns a list of all Homebrew systemd services, user
+// services from the user manager and system services from the system one.
 func (m *SystemdManager) ListServices() ([]Service, error) {
 	var services []Service
 
-	servicePaths, err := m.findServiceFiles()
-	if err != nil {
-		return nil, err
-	}
-
-	// Get systemctl output for user services (default, no root required)
-	userSystemctlOutput, err := m.getSystemctlList("--user")
-	if err != nil {
-		return nil, err
+	byScope := make(map[string][]string)
+	for _, path := range m.findServiceFiles() {
+		if IsHomebrewService(GetServiceNameFromPath(path)) {
+			scope := m.scopeFlag(path)
+			byScope[scope] = append(byScope[scope], path)
+		}
 	}
 
-	for _, path := range servicePaths {
-		service := m.parseServiceFromFile(path, userSystemctlOutput)
-		if IsHomebrewService(service.Name) {
-			services = append(services, service)
+	for _, scope := range []string{"--user", "--system"} {
+		paths := byScope[scope]
+		if len(paths) == 0 {
+			continue
+		}
+		names := make([]string, len(paths))
+		for i, path := range paths {
+			names[i] = GetServiceNameFromPath(path)
+		}
+		states, err := m.unitStates(scope, names)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			services = append(services, m.parseServiceFromFile(path, states))
 		}
 	}
 
@@ -71,21 +129,20 @@ func (m *SystemdManager) GetStatus(serviceName string) (Service, error) {
 		return Service{}, ServiceNotFoundError{Name: serviceName}
 	}
 
-	// Try user services first (default, no root required)
-	userSystemctlOutput, err := m.getSystemctlList("--user")
+	states, err := m.unitStates(m.scopeFlag(servicePath), []string{serviceName})
 	if err != nil {
 		return Service{}, err
 	}
 
-	service := m.parseServiceFromFile(servicePath, userSystemctlOutput)
-	return service, nil
+	return m.parseServiceFromFile(servicePath, states), nil
 }
 
-// findServiceFiles finds all .service files in user service directories
-func (m *SystemdManager) findServiceFiles() ([]string, error) {
+// findServiceFiles finds all .service files in the user and system service
+// directories
+func (m *SystemdManager) findServiceFiles() []string {
 	var paths []string
 
-	for _, dir := range m.userServicePaths {
+	for _, dir := range append(slices.Clone(m.userServicePaths), m.systemServicePaths...) {
 		files, err := m.scanServiceDirectory(dir)
 		if err != nil {
 			continue
@@ -93,7 +150,7 @@ func (m *SystemdManager) findServiceFiles() ([]string, error) {
 		paths = append(paths, files...)
 	}
 
-	return paths, nil
+	return paths
 }
 
 // scanServiceDirectory scans a directory for .service files
@@ -145,6 +202,81 @@ func (m *SystemdManager) findServiceFilePath(serviceName string) string {
 	return ""
 }
 
+func (m *SystemdManager) UnitPath(serviceName string) (string, error) {
+	servicePath := m.findServiceFilePath(serviceName)
+	if servicePath == "" {
+		return "", ServiceNotFoundError{Name: serviceName}
+	}
+	return servicePath, nil
+}
+
+// unitStates returns the state of the named units in scope, read from the
+// manager over D-Bus, or else with systemctl show, for the exact main PID
+// and exit status. When both fail, or show yields nothing as on very old
+// systemd, it falls back to parsing systemctl list-units, which has
+// neither.
+func (m *SystemdManager) unitStates(scope string, names []string) (map[string]systemctlEntry, error) {
+	if m.connectBus != nil {
+		states, err := m.busUnitStates(scope, names)
+		if err == nil {
+			return states, nil
+		}
+		m.logger.Debug("reading unit state over D-Bus failed; running systemctl", "scope", scope, "error", err)
+	}
+	args := []string{scope, "show", "--property=" + systemctlShowProperties}
+	for _, name := range names {
+		args = append(args, name+".service")
+	}
+	output, err := m.runner.Run("systemctl", args...)
+	if err == nil {
+		if states := m.parser.ParseSystemctlShow(output); len(states) > 0 {
+			return states, nil
+		}
+	} else {
+		m.logger.Debug("systemctl show failed; parsing list-units", "scope", scope, "error", err)
+	}
+	return m.getSystemctlList(scope)
+}
+
+// busUnitStates reads the state of the named units from the manager of
+// scope over D-Bus, keyed like ParseSystemctlShow's entries.
+func (m *SystemdManager) busUnitStates(scope string, names []string) (map[string]systemctlEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), systemdBusTimeout)
+	defer cancel()
+	conn, err := m.connectBus(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	states := make(map[string]systemctlEntry, len(names))
+	for _, name := range names {
+		unit := name + ".service"
+		props, err := conn.GetUnitPropertiesContext(ctx, unit)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", unit, err)
+		}
+		service, err := conn.GetUnitTypePropertiesContext(ctx, unit, "Service")
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", unit, err)
+		}
+		entry := systemctlEntry{Unit: unit}
+		entry.Load, _ = props["LoadState"].(string)
+		entry.Active, _ = props["ActiveState"].(string)
+		entry.SubState, _ = props["SubState"].(string)
+		entry.Description, _ = props["Description"].(string)
+		entry.Result, _ = service["Result"].(string)
+		if pid, ok := service["MainPID"].(uint32); ok {
+			entry.Pid = int(pid)
+		}
+		if status, ok := service["ExecMainStatus"].(int32); ok {
+			entry.ExitCode = int(status)
+		}
+		states[name] = entry
+	}
+	return states, nil
+}
+
 // getSystemctlList runs systemctl list-units and parses the output
 func (m *SystemdManager) getSystemctlList(scope string) (map[string]systemctlEntry, error) {
 	args := []string{"list-units", "--type=service", "--all", "--no-pager", "--no-legend"}
@@ -180,6 +312,7 @@ func (m *SystemdManager) parseServiceFromFile(servicePath string, systemctlData
 			PlistPath:    servicePath,
 			Label:        name,
 			LastExitCode: 0,
+			Scope:        m.scopeOf(servicePath),
 		}
 	}
 
@@ -194,6 +327,7 @@ func (m *SystemdManager) parseServiceFromFile(servicePath string, systemctlData
 		Name:      name,
 		Label:     label,
 		PlistPath: servicePath,
+		Scope:     m.scopeOf(servicePath),
 	}
 
 	if !exists {
@@ -227,86 +361,168 @@ func (m *SystemdManager) IsSystemService(servicePath string) bool {
 	return false
 }
 
-func (m *SystemdManager) Start(serviceName string) error {
-	servicePath := m.findServiceFilePath(serviceName)
-	if servicePath == "" {
-		return ServiceNotFoundError{Name: serviceName}
+// scopeOf returns the scope of the unit at servicePath: system for units
+// in the system directories, user otherwise.
+func (m *SystemdManager) scopeOf(servicePath string) ServiceScope {
+	if m.IsSystemService(servicePath) && !m.IsUserService(servicePath) {
+		return ScopeSystem
 	}
+	return ScopeUser
+}
 
-	_, err := m.runner.Run("systemctl", "--user", "start", serviceName)
-	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			return SystemctlError{Command: "start", Scope: "--user", Cause: err, Output: string(exitErr.Stderr)}
-		}
-		return SystemctlError{Command: "start", Scope: "--user", Cause: err}
+// scopeFlag returns the systemctl flag selecting the manager that runs the
+// unit at servicePath.
+func (m *SystemdManager) scopeFlag(servicePath string) string {
+	if m.scopeOf(servicePath) == ScopeUser {
+		return "--user"
 	}
-	return nil
+	return "--system"
 }
 
-func (m *SystemdManager) Stop(serviceName string) error {
+// systemctl runs `systemctl <scope> command serviceName` against the
+// manager the service is installed in. System services are only managed
+// as root.
+func (m *SystemdManager) systemctl(command, serviceName string) error {
 	servicePath := m.findServiceFilePath(serviceName)
 	if servicePath == "" {
 		return ServiceNotFoundError{Name: serviceName}
 	}
 
-	_, err := m.runner.Run("systemctl", "--user", "stop", serviceName)
+	scope := m.scopeFlag(servicePath)
+	if scope == "--system" && !m.isRoot() {
+		return SystemctlError{Command: command, Scope: scope, Cause: ErrSystemScopeNeedsRoot}
+	}
+	if command == "start" || command == "restart" {
+		if err := m.applyEnvFile(servicePath, serviceName, scope); err != nil {
+			return err
+		}
+	}
+	_, err := m.runner.Run("systemctl", scope, command, serviceName)
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
-			return SystemctlError{Command: "stop", Scope: "--user", Cause: err, Output: string(exitErr.Stderr)}
+			return SystemctlError{Command: command, Scope: scope, Cause: err, Output: string(exitErr.Stderr)}
 		}
-		return SystemctlError{Command: "stop", Scope: "--user", Cause: err}
+		return SystemctlError{Command: command, Scope: scope, Cause: err}
 	}
 	return nil
 }
 
+func (m *SystemdManager) Start(serviceName string) error {
+	return m.systemctl("start", serviceName)
+}
+
+func (m *SystemdManager) Stop(serviceName string) error {
+	return m.systemctl("stop", serviceName)
+}
+
 func (m *SystemdManager) Restart(serviceName string) error {
+	return m.systemctl("restart", serviceName)
+}
+
+func (m *SystemdManager) Enable(serviceName string) error {
+	return m.systemctl("enable", serviceName)
+}
+
+func (m *SystemdManager) Disable(serviceName string) error {
+	return m.systemctl("disable", serviceName)
+}
+
+// Logs returns the service's most recent journal entries.
+func (m *SystemdManager) Logs(serviceName string, lines int) ([]string, error) {
 	servicePath := m.findServiceFilePath(serviceName)
 	if servicePath == "" {
-		return ServiceNotFoundError{Name: serviceName}
+		return nil, ServiceNotFoundError{Name: serviceName}
 	}
 
-	_, err := m.runner.Run("systemctl", "--user", "restart", serviceName)
+	output, err := m.runner.Run("journalctl", journalScope(servicePath, m), "--unit", serviceName, "--lines", strconv.Itoa(lines), "--no-pager", "--output", "cat")
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			return SystemctlError{Command: "restart", Scope: "--user", Cause: err, Output: string(exitErr.Stderr)}
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("journalctl failed: %w (output: %s)", err, strings.TrimSpace(string(exitErr.Stderr)))
 		}
-		return SystemctlError{Command: "restart", Scope: "--user", Cause: err}
+		return nil, fmt.Errorf("journalctl failed: %w", err)
 	}
-	return nil
+	return lastLines(string(output), lines), nil
 }
 
-func (m *SystemdManager) Enable(serviceName string) error {
+// FollowLogs streams the service's journal.
+func (m *SystemdManager) FollowLogs(ctx context.Context, serviceName string, w io.Writer) error {
 	servicePath := m.findServiceFilePath(serviceName)
 	if servicePath == "" {
 		return ServiceNotFoundError{Name: serviceName}
 	}
 
-	_, err := m.runner.Run("systemctl", "--user", "enable", serviceName)
-	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			return SystemctlError{Command: "enable", Scope: "--user", Cause: err, Output: string(exitErr.Stderr)}
-		}
-		return SystemctlError{Command: "enable", Scope: "--user", Cause: err}
+	cmd := exec.CommandContext(ctx, "journalctl", journalScope(servicePath, m), "--unit", serviceName, "--lines", "0", "--follow", "--no-pager", "--output", "cat")
+	cmd.Stdout = w
+	cmd.Stderr = w
+	if err := cmd.Run(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("journalctl failed: %w", err)
 	}
 	return nil
 }
 
-func (m *SystemdManager) Disable(serviceName string) error {
-	servicePath := m.findServiceFilePath(serviceName)
-	if servicePath == "" {
-		return ServiceNotFoundError{Name: serviceName}
+// envDropIn is the drop-in, in the unit's <name>.service.d directory, that
+// carries the variables of the service's environment file.
+const envDropIn = "fastbrew-env.conf"
+
+// applyEnvFile writes the service's environment file as Environment= lines
+// of a drop-in next to its unit, which survives regeneration of the unit
+// itself, and removes the drop-in once the file is gone. systemd reloads
+// its units when the drop-in changes.
+func (m *SystemdManager) applyEnvFile(servicePath, serviceName, scope string) error {
+	envPath := EnvFilePath(m.envDir, serviceName)
+	env, err := LoadEnvFile(envPath)
+	if err != nil {
+		return err
+	}
+	dropInPath := filepath.Join(servicePath+".d", envDropIn)
+	current, err := os.ReadFile(dropInPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
 	}
 
-	_, err := m.runner.Run("systemctl", "--user", "disable", serviceName)
-	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			return SystemctlError{Command: "disable", Scope: "--user", Cause: err, Output: string(exitErr.Stderr)}
+	var want []byte
+	if len(env) > 0 {
+		var b strings.Builder
+		fmt.Fprintf(&b, "# Generated by fastbrew from %s; edit that file instead.\n[Service]\n", envPath)
+		for _, key := range slices.Sorted(maps.Keys(env)) {
+			fmt.Fprintf(&b, "Environment=%s\n", systemdEnvQuote(key+"="+env[key]))
 		}
-		return SystemctlError{Command: "disable", Scope: "--user", Cause: err}
+		want = []byte(b.String())
+	}
+	if bytes.Equal(current, want) {
+		return nil
+	}
+
+	if want == nil {
+		err = os.Remove(dropInPath)
+	} else if err = os.MkdirAll(filepath.Dir(dropInPath), 0755); err == nil {
+		err = atomicfile.WriteFile(dropInPath, want, 0644)
+	}
+	if err != nil {
+		return err
+	}
+	m.logger.Debug("applied environment file", "service", serviceName, "drop_in", dropInPath)
+	if _, err := m.runner.Run("systemctl", scope, "daemon-reload"); err != nil {
+		return SystemctlError{Command: "daemon-reload", Scope: scope, Cause: err}
 	}
 	return nil
 }
 
+// systemdEnvQuote quotes an Environment= assignment, escaping specifiers.
+func systemdEnvQuote(assignment string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `%`, `%%`, "\n", `\n`)
+	return `"` + r.Replace(assignment) + `"`
+}
+
+// journalScope selects the user journal for user services and the system
+// journal, readable by root and the systemd-journal group, otherwise.
+func journalScope(servicePath string, m *SystemdManager) string {
+	if m.scopeFlag(servicePath) == "--user" {
+		return "--user"
+	}
+	return "--system"
+}
+
 // UserServicePathError indicates an error with the user service directory
 type UserServicePathError struct {
 	Path  string
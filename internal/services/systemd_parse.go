@@ -96,6 +96,54 @@ func (p *ServiceFileParser) Parse(data []byte, sourcePath string) (*ServiceFile,
 	return info, nil
 }
 
+// systemctlShowProperties are the unit properties unitStates reads with
+// systemctl show.
+const systemctlShowProperties = "Id,MainPID,ActiveState,SubState,Result,ExecMainStatus"
+
+// ParseSystemctlShow parses the output of systemctl show for one or more
+// units: blocks of Property=value lines separated by blank lines. Entries
+// are keyed by unit name without the .service suffix, like
+// ParseSystemctlOutput's.
+func (p *ServiceFileParser) ParseSystemctlShow(output []byte) map[string]systemctlEntry {
+	entries := make(map[string]systemctlEntry)
+	var entry systemctlEntry
+	flush := func() {
+		if entry.Unit != "" {
+			entries[strings.TrimSuffix(entry.Unit, ".service")] = entry
+		}
+		entry = systemctlEntry{}
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			flush()
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "Id":
+			entry.Unit = value
+		case "MainPID":
+			entry.Pid, _ = strconv.Atoi(value)
+		case "ActiveState":
+			entry.Active = value
+		case "SubState":
+			entry.SubState = value
+		case "Result":
+			entry.Result = value
+		case "ExecMainStatus":
+			entry.ExitCode, _ = strconv.Atoi(value)
+		}
+	}
+	flush()
+	return entries
+}
+
 // ParseSystemctlOutput parses the output of systemctl list-units
 func (p *ServiceFileParser) ParseSystemctlOutput(output []byte) map[string]systemctlEntry {
 	entries := make(map[string]systemctlEntry)
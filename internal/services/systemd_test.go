@@ -1,9 +1,14 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"fastbrew/internal/cmdrunner"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -36,6 +41,10 @@ func (m *mockSystemdRunner) RunWithStdin(name string, stdin io.Reader, arg ...st
 	return m.Run(name, arg...)
 }
 
+func (m *mockSystemdRunner) RunWithOptions(opts cmdrunner.Options, name string, arg ...string) ([]byte, error) {
+	return m.Run(name, arg...)
+}
+
 func (m *mockSystemdRunner) setOutput(command string, output []byte) {
 	m.outputs[command] = output
 }
@@ -563,3 +572,214 @@ Jan 15 10:00:00 hostname systemd[1234]: Started Redis Server.
 		}
 	}
 }
+
+func TestSystemdManager_Logs(t *testing.T) {
+	tmpDir := t.TempDir()
+	runner := newMockSystemdRunner()
+	mgr := NewSystemdManagerWithRunner(runner)
+	mgr.userServicePaths = []string{tmpDir}
+	mgr.systemServicePaths = []string{}
+
+	os.WriteFile(filepath.Join(tmpDir, "homebrew.redis.service"), []byte("[Service]\nExecStart=/usr/bin/redis-server\n"), 0644)
+	runner.setOutput("journalctl --user --unit homebrew.redis --lines 2 --no-pager --output cat", []byte("Ready to accept connections\nDB saved on disk\n"))
+
+	lines, err := mgr.Logs("homebrew.redis", 2)
+	if err != nil {
+		t.Fatalf("Logs() returned error: %v", err)
+	}
+	if len(lines) != 2 || lines[1] != "DB saved on disk" {
+		t.Errorf("Logs() = %v", lines)
+	}
+
+	if _, err := mgr.Logs("nonexistent", 2); err == nil {
+		t.Error("Logs() for nonexistent service should fail")
+	}
+}
+
+func TestServiceFileParser_ParseSystemctlShow(t *testing.T) {
+	parser := NewServiceFileParser()
+
+	output := []byte(`Id=homebrew.mxcl.redis.service
+MainPID=1234
+ActiveState=active
+SubState=running
+Result=success
+ExecMainStatus=0
+
+Id=homebrew.mxcl.postgresql@14.service
+MainPID=0
+ActiveState=failed
+SubState=failed
+Result=exit-code
+ExecMainStatus=3
+`)
+
+	entries := parser.ParseSystemctlShow(output)
+	if len(entries) != 2 {
+		t.Fatalf("ParseSystemctlShow() returned %d entries, expected 2", len(entries))
+	}
+	if redis := entries["homebrew.mxcl.redis"]; redis.Pid != 1234 || redis.Active != "active" || redis.SubState != "running" {
+		t.Errorf("redis = %+v", redis)
+	}
+	if pg := entries["homebrew.mxcl.postgresql@14"]; pg.Active != "failed" || pg.Result != "exit-code" || pg.ExitCode != 3 {
+		t.Errorf("postgresql@14 = %+v", pg)
+	}
+}
+
+func TestSystemdManager_GetStatusFromShow(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.WriteFile(filepath.Join(tmpDir, "homebrew.mxcl.redis.service"), []byte("[Service]\nExecStart=/usr/bin/redis-server\n"), 0644)
+
+	runner := newMockSystemdRunner()
+	runner.setOutput("systemctl --user show --property="+systemctlShowProperties+" homebrew.mxcl.redis.service",
+		[]byte("Id=homebrew.mxcl.redis.service\nMainPID=4321\nActiveState=active\nSubState=running\nResult=success\nExecMainStatus=0\n"))
+	// list-units would report the service stopped; show takes precedence.
+	runner.setOutput("systemctl --user list-units --type=service --all --no-pager --no-legend",
+		[]byte("homebrew.mxcl.redis.service loaded inactive dead Redis Server\n"))
+
+	mgr := NewSystemdManagerWithRunner(runner)
+	mgr.userServicePaths = []string{tmpDir}
+	mgr.systemServicePaths = []string{}
+
+	service, err := mgr.GetStatus("homebrew.mxcl.redis")
+	if err != nil {
+		t.Fatalf("GetStatus() returned error: %v", err)
+	}
+	if service.Status != StatusRunning || service.Pid != 4321 {
+		t.Errorf("GetStatus() = %s pid %d, expected running pid 4321", service.Status, service.Pid)
+	}
+}
+
+func TestSystemdManager_GetStatusFallsBackToListUnits(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.WriteFile(filepath.Join(tmpDir, "homebrew.mxcl.redis.service"), []byte("[Service]\nExecStart=/usr/bin/redis-server\n"), 0644)
+
+	runner := newMockSystemdRunner()
+	runner.setError("systemctl --user show --property="+systemctlShowProperties+" homebrew.mxcl.redis.service", errors.New("unknown operation"))
+	runner.setOutput("systemctl --user list-units --type=service --all --no-pager --no-legend",
+		[]byte("homebrew.mxcl.redis.service loaded active running Redis Server\n"))
+
+	mgr := NewSystemdManagerWithRunner(runner)
+	mgr.userServicePaths = []string{tmpDir}
+	mgr.systemServicePaths = []string{}
+
+	service, err := mgr.GetStatus("homebrew.mxcl.redis")
+	if err != nil {
+		t.Fatalf("GetStatus() returned error: %v", err)
+	}
+	if service.Status != StatusRunning {
+		t.Errorf("Status = %s, expected %s", service.Status, StatusRunning)
+	}
+}
+
+func TestSystemdManager_SystemScope(t *testing.T) {
+	systemDir := t.TempDir()
+	os.WriteFile(filepath.Join(systemDir, "homebrew.mxcl.nginx.service"), []byte("[Service]\nExecStart=/usr/sbin/nginx\n"), 0644)
+
+	runner := newMockSystemdRunner()
+	runner.setOutput("systemctl --system show --property="+systemctlShowProperties+" homebrew.mxcl.nginx.service",
+		[]byte("Id=homebrew.mxcl.nginx.service\nMainPID=99\nActiveState=active\nSubState=running\n"))
+
+	mgr := NewSystemdManagerWithRunner(runner)
+	mgr.userServicePaths = []string{}
+	mgr.systemServicePaths = []string{systemDir}
+
+	services, err := mgr.ListServices()
+	if err != nil {
+		t.Fatalf("ListServices() returned error: %v", err)
+	}
+	if len(services) != 1 || services[0].Scope != ScopeSystem || services[0].Pid != 99 {
+		t.Fatalf("ListServices() = %+v, expected nginx running in system scope", services)
+	}
+
+	mgr.isRoot = func() bool { return false }
+	err = mgr.Restart("homebrew.mxcl.nginx")
+	var sysErr SystemctlError
+	if !errors.As(err, &sysErr) || !errors.Is(err, ErrSystemScopeNeedsRoot) {
+		t.Errorf("Restart() as non-root = %v, expected ErrSystemScopeNeedsRoot", err)
+	}
+
+	mgr.isRoot = func() bool { return true }
+	if err := mgr.Restart("homebrew.mxcl.nginx"); err != nil {
+		t.Errorf("Restart() as root returned error: %v", err)
+	}
+}
+
+// fakeSystemdBus serves unit properties like a systemd manager over D-Bus.
+type fakeSystemdBus struct {
+	units    map[string]map[string]any
+	services map[string]map[string]any
+	closed   bool
+}
+
+func (b *fakeSystemdBus) GetUnitPropertiesContext(ctx context.Context, unit string) (map[string]any, error) {
+	return b.units[unit], nil
+}
+
+func (b *fakeSystemdBus) GetUnitTypePropertiesContext(ctx context.Context, unit, unitType string) (map[string]any, error) {
+	if unitType != "Service" {
+		return nil, fmt.Errorf("unexpected unit type %s", unitType)
+	}
+	return b.services[unit], nil
+}
+
+func (b *fakeSystemdBus) Close() {
+	b.closed = true
+}
+
+func TestSystemdManager_GetStatusOverDBus(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.WriteFile(filepath.Join(tmpDir, "homebrew.mxcl.redis.service"), []byte("[Service]\nExecStart=/usr/bin/redis-server\n"), 0644)
+
+	bus := &fakeSystemdBus{
+		units: map[string]map[string]any{
+			"homebrew.mxcl.redis.service": {"LoadState": "loaded", "ActiveState": "failed", "SubState": "failed"},
+		},
+		services: map[string]map[string]any{
+			"homebrew.mxcl.redis.service": {"MainPID": uint32(0), "Result": "exit-code", "ExecMainStatus": int32(3)},
+		},
+	}
+	runner := newMockSystemdRunner()
+	mgr := NewSystemdManagerWithRunner(runner)
+	mgr.userServicePaths = []string{tmpDir}
+	mgr.systemServicePaths = []string{}
+	var scopes []string
+	mgr.connectBus = func(ctx context.Context, scope string) (systemdBus, error) {
+		scopes = append(scopes, scope)
+		return bus, nil
+	}
+
+	service, err := mgr.GetStatus("homebrew.mxcl.redis")
+	if err != nil {
+		t.Fatalf("GetStatus() returned error: %v", err)
+	}
+	if service.Status != StatusError || service.LastExitCode != 3 {
+		t.Errorf("GetStatus() = %s exit %d, expected error exit 3", service.Status, service.LastExitCode)
+	}
+	if !reflect.DeepEqual(scopes, []string{"--user"}) || !bus.closed {
+		t.Errorf("expected one closed connection to the user manager, got scopes %v, closed %v", scopes, bus.closed)
+	}
+}
+
+func TestSystemdManager_GetStatusFallsBackFromDBus(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.WriteFile(filepath.Join(tmpDir, "homebrew.mxcl.redis.service"), []byte("[Service]\nExecStart=/usr/bin/redis-server\n"), 0644)
+
+	runner := newMockSystemdRunner()
+	runner.setOutput("systemctl --user show --property="+systemctlShowProperties+" homebrew.mxcl.redis.service",
+		[]byte("Id=homebrew.mxcl.redis.service\nMainPID=4321\nActiveState=active\nSubState=running\n"))
+	mgr := NewSystemdManagerWithRunner(runner)
+	mgr.userServicePaths = []string{tmpDir}
+	mgr.systemServicePaths = []string{}
+	mgr.connectBus = func(ctx context.Context, scope string) (systemdBus, error) {
+		return nil, errors.New("no session bus")
+	}
+
+	service, err := mgr.GetStatus("homebrew.mxcl.redis")
+	if err != nil {
+		t.Fatalf("GetStatus() returned error: %v", err)
+	}
+	if service.Status != StatusRunning || service.Pid != 4321 {
+		t.Errorf("GetStatus() = %s pid %d, expected systemctl's running pid 4321", service.Status, service.Pid)
+	}
+}
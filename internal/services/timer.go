@@ -0,0 +1,78 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Timer is a command run periodically in the background for the current
+// user: a launchd agent with a StartInterval on macOS, a systemd user
+// service and timer pair on Linux. Timers are not Homebrew services and do
+// not show up in ListServices.
+type Timer struct {
+	// Label names the launchd agent and the systemd units.
+	Label       string
+	Description string
+	// Command is the program and its arguments.
+	Command  []string
+	Interval time.Duration
+	// LogPath receives the command's output under launchd; systemd sends
+	// it to the journal.
+	LogPath string
+}
+
+// LaunchdPlist renders t as a launchd agent property list.
+func (t Timer) LaunchdPlist() []byte {
+	info := ServiceInfo{
+		Label:             t.Label,
+		ProgramArgs:       t.Command,
+		StartInterval:     int(t.Interval.Seconds()),
+		ProcessType:       "Background",
+		LowPriorityIO:     true,
+		StandardOutPath:   t.LogPath,
+		StandardErrorPath: t.LogPath,
+	}
+	return info.Plist()
+}
+
+// SystemdUnits renders t as a oneshot systemd service and the timer that
+// starts it: a few minutes after boot, or right away when enabled later,
+// and then every Interval.
+func (t Timer) SystemdUnits() (service, timer []byte) {
+	quoted := make([]string, len(t.Command))
+	for i, arg := range t.Command {
+		quoted[i] = systemdQuote(arg)
+	}
+	service = []byte(fmt.Sprintf(`[Unit]
+Description=%s
+
+[Service]
+Type=oneshot
+ExecStart=%s
+Nice=10
+`, t.Description, strings.Join(quoted, " ")))
+
+	seconds := int(t.Interval.Seconds())
+	timer = []byte(fmt.Sprintf(`[Unit]
+Description=%s (timer)
+
+[Timer]
+OnBootSec=5min
+OnUnitActiveSec=%ds
+RandomizedDelaySec=%ds
+
+[Install]
+WantedBy=timers.target
+`, t.Description, seconds, min(seconds/10, 3600)))
+	return service, timer
+}
+
+// systemdQuote quotes arg for an ExecStart line when it needs it.
+func systemdQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'\\%$;") {
+		return arg
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `%`, `%%`, `$`, `$$`)
+	return `"` + r.Replace(arg) + `"`
+}
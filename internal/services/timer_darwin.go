@@ -0,0 +1,58 @@
+//go:build darwin
+
+package services
+
+import (
+	"fastbrew/internal/atomicfile"
+	"fastbrew/internal/log"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// TimerPath returns the launch agent file of the timer label.
+func TimerPath(label string) string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, "Library", "LaunchAgents", label+".plist")
+}
+
+// InstallTimer writes t as a launch agent and loads it, replacing any
+// timer with the same label.
+func InstallTimer(t Timer) error {
+	path := TimerPath(t.Label)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return UserAgentPathError{Path: filepath.Dir(path), Cause: err}
+	}
+	runner := newLoggingRunner(&DefaultCommandRunner{}, log.For("services"))
+	if _, err := os.Stat(path); err == nil {
+		_, _ = runner.Run("launchctl", "unload", path)
+	}
+	if err := atomicfile.WriteFile(path, t.LaunchdPlist(), 0644); err != nil {
+		return err
+	}
+	if _, err := runner.Run("launchctl", "load", "-w", path); err != nil {
+		return launchctlError("load", err)
+	}
+	return nil
+}
+
+// RemoveTimer unloads and deletes the timer label. Removing a timer that
+// is not installed does nothing.
+func RemoveTimer(label string) error {
+	path := TimerPath(label)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	runner := newLoggingRunner(&DefaultCommandRunner{}, log.For("services"))
+	if _, err := runner.Run("launchctl", "unload", "-w", path); err != nil {
+		return launchctlError("unload", err)
+	}
+	return os.Remove(path)
+}
+
+func launchctlError(command string, err error) error {
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return LaunchctlError{Command: command, Cause: err, Output: string(exitErr.Stderr)}
+	}
+	return LaunchctlError{Command: command, Cause: err}
+}
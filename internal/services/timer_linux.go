@@ -0,0 +1,72 @@
+//go:build linux
+
+package services
+
+import (
+	"fastbrew/internal/atomicfile"
+	"fastbrew/internal/log"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// TimerPath returns the systemd timer unit of the timer label. Its service
+// unit sits next to it.
+func TimerPath(label string) string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".config", "systemd", "user", label+".timer")
+}
+
+// InstallTimer writes t as a systemd user service and timer and enables
+// the timer, replacing any timer with the same label.
+func InstallTimer(t Timer) error {
+	timerPath := TimerPath(t.Label)
+	dir := filepath.Dir(timerPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return UserServicePathError{Path: dir, Cause: err}
+	}
+	service, timer := t.SystemdUnits()
+	if err := atomicfile.WriteFile(filepath.Join(dir, t.Label+".service"), service, 0644); err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(timerPath, timer, 0644); err != nil {
+		return err
+	}
+
+	runner := newLoggingRunner(&DefaultCommandRunner{}, log.For("services"))
+	if _, err := runner.Run("systemctl", "--user", "daemon-reload"); err != nil {
+		return systemctlError("daemon-reload", err)
+	}
+	if _, err := runner.Run("systemctl", "--user", "enable", "--now", t.Label+".timer"); err != nil {
+		return systemctlError("enable", err)
+	}
+	return nil
+}
+
+// RemoveTimer disables and deletes the timer label. Removing a timer that
+// is not installed does nothing.
+func RemoveTimer(label string) error {
+	timerPath := TimerPath(label)
+	if _, err := os.Stat(timerPath); os.IsNotExist(err) {
+		return nil
+	}
+	runner := newLoggingRunner(&DefaultCommandRunner{}, log.For("services"))
+	if _, err := runner.Run("systemctl", "--user", "disable", "--now", label+".timer"); err != nil {
+		return systemctlError("disable", err)
+	}
+	if err := os.Remove(timerPath); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(filepath.Dir(timerPath), label+".service")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	_, _ = runner.Run("systemctl", "--user", "daemon-reload")
+	return nil
+}
+
+func systemctlError(command string, err error) error {
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return SystemctlError{Command: command, Scope: "--user", Cause: err, Output: string(exitErr.Stderr)}
+	}
+	return SystemctlError{Command: command, Scope: "--user", Cause: err}
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func testTimer() Timer {
+	return Timer{
+		Label:       "fastbrew.autoupdate",
+		Description: "fastbrew index auto-update",
+		Command:     []string{"/opt/my tools/fastbrew", "update", "--record-outdated"},
+		Interval:    6 * time.Hour,
+		LogPath:     "/tmp/autoupdate.log",
+	}
+}
+
+func TestTimerLaunchdPlist(t *testing.T) {
+	plist := string(testTimer().LaunchdPlist())
+	for _, want := range []string{
+		"<key>Label</key>\n\t<string>fastbrew.autoupdate</string>",
+		"\t\t<string>/opt/my tools/fastbrew</string>\n\t\t<string>update</string>\n\t\t<string>--record-outdated</string>",
+		"<key>StartInterval</key>\n\t<integer>21600</integer>",
+		"<key>StandardOutPath</key>\n\t<string>/tmp/autoupdate.log</string>",
+	} {
+		if !strings.Contains(plist, want) {
+			t.Errorf("plist missing %q:\n%s", want, plist)
+		}
+	}
+
+	info, err := NewPlistParser().Parse([]byte(plist), "fastbrew.autoupdate.plist")
+	if err != nil {
+		t.Fatalf("generated plist does not parse: %v", err)
+	}
+	if info.Label != "fastbrew.autoupdate" {
+		t.Errorf("parsed label %q", info.Label)
+	}
+}
+
+func TestTimerSystemdUnits(t *testing.T) {
+	service, timer := testTimer().SystemdUnits()
+	if !strings.Contains(string(service), `ExecStart="/opt/my tools/fastbrew" update --record-outdated`) {
+		t.Errorf("unexpected service unit:\n%s", service)
+	}
+	if !strings.Contains(string(service), "Type=oneshot") {
+		t.Errorf("service should be oneshot:\n%s", service)
+	}
+	for _, want := range []string{"OnUnitActiveSec=21600s", "RandomizedDelaySec=2160s", "WantedBy=timers.target"} {
+		if !strings.Contains(string(timer), want) {
+			t.Errorf("timer unit missing %q:\n%s", want, timer)
+		}
+	}
+}
+
+func TestSystemdQuote(t *testing.T) {
+	tests := map[string]string{
+		"update":        "update",
+		"/a b/fastbrew": `"/a b/fastbrew"`,
+		`100%$`:         `"100%%$$"`,
+		"":              `""`,
+	}
+	for in, want := range tests {
+		if got := systemdQuote(in); got != want {
+			t.Errorf("systemdQuote(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
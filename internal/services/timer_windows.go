@@ -0,0 +1,18 @@
+//go:build windows
+
+package services
+
+import "errors"
+
+// TimerPath returns "": timers are not supported on Windows.
+func TimerPath(label string) string {
+	return ""
+}
+
+func InstallTimer(t Timer) error {
+	return errors.New("background timers not supported on Windows")
+}
+
+func RemoveTimer(label string) error {
+	return nil
+}
@@ -0,0 +1,335 @@
+package tui
+
+import (
+	"fastbrew/internal/brew"
+	"fastbrew/internal/daemon"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Actions offered by the action menu.
+const (
+	actionInstall   = "install"
+	actionUninstall = "uninstall"
+	actionInfo      = "info"
+	actionPin       = "pin"
+)
+
+var (
+	menuStyle         = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(0, 1).BorderForeground(lipgloss.Color("205"))
+	menuSelectedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("170")).Bold(true)
+	markedStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+	noticeStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("244")).PaddingLeft(2)
+
+	markKey   = key.NewBinding(key.WithKeys(" "), key.WithHelp("space", "mark"))
+	menuKey   = key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "actions"))
+	queueKey  = key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", "queue"))
+	removeKey = key.NewBinding(key.WithKeys(" ", "x", "backspace"), key.WithHelp("space", "remove"))
+	clearKey  = key.NewBinding(key.WithKeys("c"), key.WithHelp("c", "clear"))
+)
+
+// actionMenu is the open action menu and the packages it acts on.
+type actionMenu struct {
+	targets []item
+	actions []string
+	cursor  int
+}
+
+// itemKey identifies a package in the queue; a cask and a formula can
+// share a name.
+func itemKey(i item) string {
+	if i.isCask {
+		return "cask:" + i.title
+	}
+	return "formula:" + i.title
+}
+
+func itemNames(items []item) []string {
+	names := make([]string, len(items))
+	for n, i := range items {
+		names[n] = i.title
+	}
+	return names
+}
+
+// toggleMark adds the selected item to the install queue, or removes it.
+func (m *model) toggleMark() {
+	i, ok := m.list.SelectedItem().(item)
+	if !ok {
+		return
+	}
+	k := itemKey(i)
+	if m.marked[k] {
+		m.unqueue(k)
+	} else {
+		m.marked[k] = true
+		m.queue = append(m.queue, i)
+	}
+	m.list.CursorDown()
+}
+
+func (m *model) unqueue(k string) {
+	delete(m.marked, k)
+	for n, i := range m.queue {
+		if itemKey(i) == k {
+			m.queue = append(m.queue[:n], m.queue[n+1:]...)
+			break
+		}
+	}
+	if m.queueCursor >= len(m.queue) && m.queueCursor > 0 {
+		m.queueCursor = len(m.queue) - 1
+	}
+}
+
+func (m *model) clearQueue() {
+	m.queue = nil
+	m.marked = make(map[string]bool)
+	m.queueCursor = 0
+}
+
+// targets are the packages an action applies to: the queue when anything
+// is marked, otherwise the selected package.
+func (m *model) targets() []item {
+	if len(m.queue) > 0 {
+		return append([]item(nil), m.queue...)
+	}
+	if i, ok := m.list.SelectedItem().(item); ok {
+		return []item{i}
+	}
+	return nil
+}
+
+func (m *model) openMenu(targets []item) {
+	if len(targets) == 0 {
+		return
+	}
+	m.menu = &actionMenu{
+		targets: targets,
+		actions: []string{actionInstall, actionUninstall, actionInfo, actionPin},
+	}
+	m.updateListSize()
+}
+
+func (m *model) closeMenu() {
+	m.menu = nil
+	m.updateListSize()
+}
+
+// handleMenuKey drives the open action menu.
+func (m *model) handleMenuKey(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "up", "k":
+		if m.menu.cursor > 0 {
+			m.menu.cursor--
+		}
+	case "down", "j":
+		if m.menu.cursor < len(m.menu.actions)-1 {
+			m.menu.cursor++
+		}
+	case "esc", "q":
+		m.closeMenu()
+	case "enter":
+		action, targets := m.menu.actions[m.menu.cursor], m.menu.targets
+		m.closeMenu()
+		return m.runAction(action, targets)
+	}
+	return nil
+}
+
+// handleQueueKey drives the queue view.
+func (m *model) handleQueueKey(msg tea.KeyMsg) tea.Cmd {
+	switch {
+	case key.Matches(msg, queueKey), msg.String() == "esc":
+		m.showQueue = false
+	case msg.String() == "up", msg.String() == "k":
+		if m.queueCursor > 0 {
+			m.queueCursor--
+		}
+	case msg.String() == "down", msg.String() == "j":
+		if m.queueCursor < len(m.queue)-1 {
+			m.queueCursor++
+		}
+	case key.Matches(msg, removeKey):
+		if m.queueCursor < len(m.queue) {
+			m.unqueue(itemKey(m.queue[m.queueCursor]))
+		}
+	case key.Matches(msg, clearKey):
+		m.clearQueue()
+	case key.Matches(msg, menuKey):
+		m.openMenu(m.targets())
+	}
+	return nil
+}
+
+// runAction performs action on targets. Install and uninstall run as a
+// job with progress in the job panel; they take the whole queue, which is
+// cleared once the job starts.
+func (m *model) runAction(action string, targets []item) tea.Cmd {
+	m.info = ""
+	m.notice = ""
+	switch action {
+	case actionInstall, actionUninstall:
+		if m.jobActive {
+			m.notice = "A job is already running."
+			return nil
+		}
+		operation := daemon.JobOperationInstall
+		if action == actionUninstall {
+			operation = daemon.JobOperationUninstall
+			var installed []item
+			for _, i := range targets {
+				if m.installed[i.title] {
+					installed = append(installed, i)
+				}
+			}
+			if len(installed) == 0 {
+				m.notice = "Nothing to uninstall: none of the selected packages are installed."
+				return nil
+			}
+			targets = installed
+		}
+		m.clearQueue()
+		m.showQueue = false
+		return m.startJob(itemNames(targets), operation)
+	case actionInfo:
+		m.info = m.describe(targets)
+		m.updateListSize()
+	case actionPin:
+		m.togglePins(targets)
+		return m.updateListItems()
+	}
+	return nil
+}
+
+// togglePins unpins targets when all of them are pinned and pins them
+// otherwise.
+func (m *model) togglePins(targets []item) {
+	pinned, err := brew.LoadPinned()
+	if err != nil {
+		m.notice = fmt.Sprintf("Error loading pinned packages: %v", err)
+		return
+	}
+	allPinned := true
+	for _, i := range targets {
+		allPinned = allPinned && pinned[i.title]
+	}
+	for _, i := range targets {
+		if allPinned {
+			delete(pinned, i.title)
+		} else {
+			pinned[i.title] = true
+		}
+	}
+	if err := brew.SavePinned(pinned); err != nil {
+		m.notice = fmt.Sprintf("Error saving pinned packages: %v", err)
+		return
+	}
+	m.pinned = pinned
+
+	verb := "📌 Pinned"
+	if allPinned {
+		verb = "📍 Unpinned"
+	}
+	m.notice = fmt.Sprintf("%s %s", verb, strings.Join(itemNames(targets), ", "))
+}
+
+// describe renders the index entries of targets for the info panel.
+func (m *model) describe(targets []item) string {
+	formulae := make(map[string]brew.Formula)
+	casks := make(map[string]brew.Cask)
+	if m.index != nil {
+		for _, f := range m.index.Formulae {
+			formulae[f.Name] = f
+		}
+		for _, c := range m.index.Casks {
+			casks[c.Token] = c
+		}
+	}
+
+	var blocks []string
+	for _, i := range targets {
+		var lines []string
+		if c, ok := casks[i.title]; ok && i.isCask {
+			lines = append(lines, titleStyle.UnsetMarginLeft().Render(fmt.Sprintf("%s (cask) %s", c.Token, c.Version)), c.Desc)
+			if c.Homepage != "" {
+				lines = append(lines, c.Homepage)
+			}
+		} else if f, ok := formulae[i.title]; ok {
+			lines = append(lines, titleStyle.UnsetMarginLeft().Render(fmt.Sprintf("%s %s", f.Name, f.FullVersion())), f.Desc)
+			if f.Homepage != "" {
+				lines = append(lines, f.Homepage)
+			}
+			if len(f.Dependencies) > 0 {
+				lines = append(lines, "Depends on: "+strings.Join(f.Dependencies, ", "))
+			}
+		} else {
+			lines = append(lines, i.title, i.desc)
+		}
+
+		status := "Not installed"
+		if m.installed[i.title] {
+			status = "Installed"
+		}
+		if m.pinned[i.title] {
+			status += ", pinned"
+		}
+		lines = append(lines, status)
+		blocks = append(blocks, strings.Join(lines, "\n"))
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+func (m *model) renderMenu() string {
+	names := itemNames(m.menu.targets)
+	header := strings.Join(names, ", ")
+	if len(names) > 3 {
+		header = fmt.Sprintf("%s and %d more", strings.Join(names[:3], ", "), len(names)-3)
+	}
+
+	lines := []string{titleStyle.UnsetMarginLeft().Render(header)}
+	for n, action := range m.menu.actions {
+		if n == m.menu.cursor {
+			lines = append(lines, menuSelectedStyle.Render("> "+action))
+		} else {
+			lines = append(lines, "  "+action)
+		}
+	}
+	lines = append(lines, helpStyle.UnsetPaddingLeft().UnsetPaddingBottom().Render("enter run • esc close"))
+	return menuStyle.Render(strings.Join(lines, "\n"))
+}
+
+func (m *model) renderQueue() string {
+	lines := []string{titleStyle.Render(fmt.Sprintf("Queue (%d)", len(m.queue))), ""}
+	if len(m.queue) == 0 {
+		lines = append(lines, itemStyle.Render("Nothing queued. Mark packages with space."))
+	}
+	for n, i := range m.queue {
+		kind := "📦"
+		if i.isCask {
+			kind = "🍷"
+		}
+		line := fmt.Sprintf("%s %s", kind, i.title)
+		if m.installed[i.title] {
+			line += installedStyle.Render(" (installed)")
+		}
+		if n == m.queueCursor {
+			lines = append(lines, selectedItemStyle.Render("> "+line))
+		} else {
+			lines = append(lines, itemStyle.Render(line))
+		}
+	}
+	lines = append(lines, "", helpStyle.Render("enter actions • space remove • c clear • tab back"))
+	return strings.Join(lines, "\n")
+}
+
+func (m *model) renderInfo() string {
+	info := m.info + "\n\n" + helpStyle.UnsetPaddingLeft().UnsetPaddingBottom().Render("esc close")
+	if m.width > 6 {
+		return menuStyle.Width(m.width - 6).Render(info)
+	}
+	return menuStyle.Render(info)
+}
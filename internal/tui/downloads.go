@@ -0,0 +1,343 @@
+package tui
+
+import (
+	"bytes"
+	"fastbrew/internal/progress"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	tprogress "github.com/charmbracelet/bubbles/progress"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	downloadNameWidth = 20
+	downloadBarWidth  = 30
+	// maxDashboardRows caps the per-download rows; the rest are summarised.
+	maxDashboardRows = 8
+)
+
+var (
+	downloadDoneStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+	downloadFailedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+	downloadMutedStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("244"))
+	downloadTotalStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("205")).Bold(true)
+)
+
+type downloadState struct {
+	ID         string
+	Current    int64
+	Total      int64
+	StartBytes int64
+	StartedAt  time.Time
+	UpdatedAt  time.Time
+	Done       bool
+	Err        string
+}
+
+// Speed returns the average transfer rate since the download started, in
+// bytes per second. Bytes that were already present when a resumed download
+// started are not counted.
+func (d *downloadState) Speed() float64 {
+	elapsed := d.UpdatedAt.Sub(d.StartedAt).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(d.Current-d.StartBytes) / elapsed
+}
+
+// ETA estimates the time left from the average speed, or 0 when unknown.
+func (d *downloadState) ETA() time.Duration {
+	speed := d.Speed()
+	if speed <= 0 || d.Total <= 0 || d.Current >= d.Total {
+		return 0
+	}
+	return time.Duration(float64(d.Total-d.Current)/speed) * time.Second
+}
+
+func (d *downloadState) percent() float64 {
+	if d.Done && d.Err == "" {
+		return 1
+	}
+	if d.Total <= 0 {
+		return 0
+	}
+	return min(1, float64(d.Current)/float64(d.Total))
+}
+
+type downloadEventMsg progress.ProgressEvent
+type downloadsQuitMsg struct{}
+
+// downloadsModel renders one progress bar per bottle plus an aggregate bar.
+type downloadsModel struct {
+	downloads map[string]*downloadState
+	order     []string
+	bar       tprogress.Model
+	now       func() time.Time
+	quitting  bool
+	// aggregate, when set, supplies the download counts, which include
+	// downloads planned but not started and bottles served from the cache.
+	aggregate func() progress.AggregateProgress
+}
+
+func newDownloadsModel() *downloadsModel {
+	return &downloadsModel{
+		downloads: make(map[string]*downloadState),
+		bar:       tprogress.New(tprogress.WithDefaultGradient(), tprogress.WithWidth(downloadBarWidth)),
+		now:       time.Now,
+	}
+}
+
+func (m *downloadsModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *downloadsModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case downloadEventMsg:
+		m.applyEvent(progress.ProgressEvent(msg))
+	case downloadsQuitMsg:
+		m.quitting = true
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+func (m *downloadsModel) applyEvent(event progress.ProgressEvent) {
+	now := m.now()
+	d, ok := m.downloads[event.ID]
+	if !ok || event.Type == progress.EventDownloadStart {
+		d = &downloadState{ID: event.ID, StartBytes: event.Current, StartedAt: now}
+		if !ok {
+			m.order = append(m.order, event.ID)
+		}
+		m.downloads[event.ID] = d
+	}
+
+	d.Current = event.Current
+	if event.Total > 0 {
+		d.Total = event.Total
+	}
+	d.UpdatedAt = now
+
+	switch event.Type {
+	case progress.EventDownloadComplete:
+		d.Done = true
+	case progress.EventDownloadError:
+		d.Done = true
+		d.Err = event.Message
+	}
+}
+
+func (m *downloadsModel) View() string {
+	if len(m.order) == 0 {
+		if m.quitting {
+			return ""
+		}
+		return downloadMutedStyle.Render("  Waiting for downloads...") + "\n"
+	}
+
+	var lines []string
+	var active, done, failed int
+	var current, total int64
+	var speed float64
+	shown, hidden := 0, 0
+
+	for _, id := range m.order {
+		d := m.downloads[id]
+		current += d.Current
+		total += d.Total
+		switch {
+		case d.Err != "":
+			failed++
+		case d.Done:
+			done++
+		default:
+			active++
+			speed += d.Speed()
+		}
+
+		// Finished downloads are listed only when the dashboard exits, so
+		// the live view stays focused on what is still transferring.
+		if d.Done && !m.quitting {
+			continue
+		}
+		if shown == maxDashboardRows {
+			hidden++
+			continue
+		}
+		shown++
+		lines = append(lines, m.renderRow(d))
+	}
+
+	if hidden > 0 {
+		lines = append(lines, downloadMutedStyle.Render(fmt.Sprintf("  … and %d more", hidden)))
+	}
+
+	count := len(m.order)
+	if m.aggregate != nil {
+		agg := m.aggregate()
+		done, failed = agg.CompletedDownloads, agg.FailedDownloads
+		count = max(count, agg.TotalDownloads)
+	}
+	overall := 0.0
+	if total > 0 {
+		overall = min(1, float64(current)/float64(total))
+	}
+	if active == 0 && failed == 0 && done >= count {
+		overall = 1
+	}
+	summary := fmt.Sprintf("%d/%d done", done, count)
+	if failed > 0 {
+		summary += fmt.Sprintf(", %d failed", failed)
+	}
+	if active > 0 && speed > 0 {
+		summary += fmt.Sprintf(" · %s/s", progress.FormatBytes(int64(speed)))
+	}
+	lines = append(lines, fmt.Sprintf("  %s %s %s",
+		downloadTotalStyle.Render(fmt.Sprintf("%-*s", downloadNameWidth, "Total")), m.bar.ViewAs(overall), summary))
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func (m *downloadsModel) renderRow(d *downloadState) string {
+	name := fmt.Sprintf("%-*s", downloadNameWidth, truncateName(d.ID))
+	bar := m.bar.ViewAs(d.percent())
+
+	switch {
+	case d.Err != "":
+		return fmt.Sprintf("  %s %s %s", name, bar, downloadFailedStyle.Render("✗ "+d.Err))
+	case d.Done:
+		return fmt.Sprintf("  %s %s %s", name, bar, downloadDoneStyle.Render("✓ "+progress.FormatBytes(d.Total)))
+	}
+
+	stats := progress.FormatBytes(d.Current)
+	if d.Total > 0 {
+		stats += " / " + progress.FormatBytes(d.Total)
+	}
+	if speed := d.Speed(); speed > 0 {
+		stats += fmt.Sprintf(" · %s/s", progress.FormatBytes(int64(speed)))
+	}
+	if eta := d.ETA(); eta > 0 {
+		stats += " · ETA " + eta.Round(time.Second).String()
+	}
+	return fmt.Sprintf("  %s %s %s", name, bar, downloadMutedStyle.Render(stats))
+}
+
+func truncateName(name string) string {
+	if len(name) <= downloadNameWidth {
+		return name
+	}
+	return name[:downloadNameWidth-1] + "…"
+}
+
+// DownloadDashboard renders live download progress from a progress.Manager
+// below the regular command output.
+type DownloadDashboard struct {
+	pm      *progress.Manager
+	program *tea.Program
+	subID   string
+	events  chan progress.ProgressEvent
+	stop    chan struct{}
+	done    chan struct{}
+	out     *dashboardWriter
+	once    sync.Once
+}
+
+// StartDownloadDashboard subscribes to pm's event bus and starts rendering
+// to stdout. Text that would otherwise be written to stdout while the
+// dashboard runs should go through Writer so it is printed above the bars.
+func StartDownloadDashboard(pm *progress.Manager) *DownloadDashboard {
+	model := newDownloadsModel()
+	model.aggregate = pm.GetAggregateProgress
+	program := tea.NewProgram(model,
+		tea.WithOutput(os.Stdout),
+		tea.WithInput(nil),
+		tea.WithoutSignalHandler(),
+	)
+
+	d := &DownloadDashboard{
+		pm:      pm,
+		program: program,
+		subID:   fmt.Sprintf("download-dashboard-%d", time.Now().UnixNano()),
+		events:  make(chan progress.ProgressEvent, 256),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	d.out = &dashboardWriter{program: program}
+	pm.SubscribeToEvents(d.subID, d.events)
+
+	go func() {
+		defer close(d.done)
+		program.Run()
+	}()
+	go func() {
+		for {
+			select {
+			case <-d.stop:
+				return
+			case event := <-d.events:
+				program.Send(downloadEventMsg(event))
+			}
+		}
+	}()
+	return d
+}
+
+// Writer returns an io.Writer whose complete lines are printed above the
+// dashboard.
+func (d *DownloadDashboard) Writer() io.Writer {
+	return d.out
+}
+
+// Stop unsubscribes from the event bus, draws the final state and restores
+// the terminal. It is safe to call more than once.
+func (d *DownloadDashboard) Stop() {
+	d.once.Do(func() {
+		d.pm.UnsubscribeFromEvents(d.subID)
+		close(d.stop)
+		d.out.flush()
+		d.program.Send(downloadsQuitMsg{})
+		<-d.done
+	})
+}
+
+// dashboardWriter buffers partial lines and hands complete ones to the
+// program, which prints them above the rendered view.
+type dashboardWriter struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	program *tea.Program
+}
+
+func (w *dashboardWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf.Write(p)
+	for {
+		line, err := w.buf.ReadString('\n')
+		if err != nil {
+			// Incomplete line; keep it for the next write.
+			w.buf.Reset()
+			w.buf.WriteString(line)
+			break
+		}
+		w.program.Println(strings.TrimRight(line, "\r\n"))
+	}
+	return len(p), nil
+}
+
+func (w *dashboardWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.buf.Len() > 0 {
+		w.program.Println(w.buf.String())
+		w.buf.Reset()
+	}
+}
@@ -0,0 +1,87 @@
+package tui
+
+import (
+	"fastbrew/internal/progress"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestDownloadsModel(now *time.Time) *downloadsModel {
+	m := newDownloadsModel()
+	m.now = func() time.Time { return *now }
+	return m
+}
+
+func TestDownloadsModelTracksSpeedAndETA(t *testing.T) {
+	now := time.Unix(1000, 0)
+	m := newTestDownloadsModel(&now)
+
+	m.applyEvent(progress.ProgressEvent{Type: progress.EventDownloadStart, ID: "wget", Total: 4096})
+	now = now.Add(2 * time.Second)
+	m.applyEvent(progress.ProgressEvent{Type: progress.EventDownloadProgress, ID: "wget", Current: 2048, Total: 4096})
+
+	d := m.downloads["wget"]
+	if got := d.Speed(); got != 1024 {
+		t.Fatalf("expected 1024 B/s, got %.1f", got)
+	}
+	if got := d.ETA(); got != 2*time.Second {
+		t.Fatalf("expected ETA 2s, got %v", got)
+	}
+
+	view := m.View()
+	for _, want := range []string{"wget", "2.0 KB / 4.0 KB", "1.0 KB/s", "ETA 2s", "0/1 done"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("view missing %q:\n%s", want, view)
+		}
+	}
+}
+
+func TestDownloadsModelResumedDownloadSpeed(t *testing.T) {
+	now := time.Unix(1000, 0)
+	m := newTestDownloadsModel(&now)
+
+	m.applyEvent(progress.ProgressEvent{Type: progress.EventDownloadStart, ID: "jq", Current: 3000, Total: 5000})
+	now = now.Add(time.Second)
+	m.applyEvent(progress.ProgressEvent{Type: progress.EventDownloadProgress, ID: "jq", Current: 4000, Total: 5000})
+
+	if got := m.downloads["jq"].Speed(); got != 1000 {
+		t.Fatalf("expected resumed bytes to be excluded from speed, got %.1f", got)
+	}
+}
+
+func TestDownloadsModelHidesFinishedUntilQuit(t *testing.T) {
+	now := time.Unix(1000, 0)
+	m := newTestDownloadsModel(&now)
+
+	m.applyEvent(progress.ProgressEvent{Type: progress.EventDownloadStart, ID: "wget", Total: 100})
+	m.applyEvent(progress.ProgressEvent{Type: progress.EventDownloadComplete, ID: "wget", Current: 100, Total: 100})
+	m.applyEvent(progress.ProgressEvent{Type: progress.EventDownloadStart, ID: "curl", Total: 100})
+	m.applyEvent(progress.ProgressEvent{Type: progress.EventDownloadError, ID: "curl", Message: "checksum mismatch"})
+
+	view := m.View()
+	if strings.Contains(view, "wget") || strings.Contains(view, "curl") {
+		t.Fatalf("expected finished downloads to be hidden while running:\n%s", view)
+	}
+	if !strings.Contains(view, "1/2 done, 1 failed") {
+		t.Fatalf("expected aggregate summary, got:\n%s", view)
+	}
+
+	m.Update(downloadsQuitMsg{})
+	view = m.View()
+	if !strings.Contains(view, "✓") || !strings.Contains(view, "✗ checksum mismatch") {
+		t.Fatalf("expected final view to list finished downloads:\n%s", view)
+	}
+}
+
+func TestDownloadsModelCapsRows(t *testing.T) {
+	now := time.Unix(1000, 0)
+	m := newTestDownloadsModel(&now)
+
+	for i := 0; i < maxDashboardRows+3; i++ {
+		m.applyEvent(progress.ProgressEvent{Type: progress.EventDownloadStart, ID: strings.Repeat("x", i+1), Total: 100})
+	}
+	if view := m.View(); !strings.Contains(view, "… and 3 more") {
+		t.Fatalf("expected overflow summary, got:\n%s", view)
+	}
+}
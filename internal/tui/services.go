@@ -0,0 +1,349 @@
+package tui
+
+import (
+	"fastbrew/internal/brew"
+	"fastbrew/internal/config"
+	"fastbrew/internal/daemon"
+	"fastbrew/internal/services"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	// servicesRefreshInterval is how often statuses and the log pane are
+	// reloaded.
+	servicesRefreshInterval = 2 * time.Second
+	serviceLogLines         = 200
+	serviceListWidth        = 44
+)
+
+var (
+	serviceRunningStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+	serviceErrorStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+	serviceMutedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("244"))
+	servicePaneStyle    = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(0, 1).BorderForeground(lipgloss.Color("62"))
+)
+
+type servicesLoadedMsg struct {
+	Services []services.Service
+	Err      error
+}
+
+type serviceLogsMsg struct {
+	Name  string
+	Lines []string
+	Err   error
+}
+
+type serviceActionMsg struct {
+	Name   string
+	Action string
+	Err    error
+}
+
+type servicesTickMsg struct{}
+
+// servicesModel is the service manager screen: a list of services with
+// their live status next to the selected service's recent output.
+type servicesModel struct {
+	mgr      services.ServiceManager
+	services []services.Service
+	cursor   int
+	loaded   bool
+	err      error
+
+	logsFor string
+	logs    []string
+	logErr  error
+
+	pending string
+	notice  string
+
+	width  int
+	height int
+}
+
+func newServicesModel(mgr services.ServiceManager) *servicesModel {
+	return &servicesModel{mgr: mgr}
+}
+
+// StartServices runs the service manager screen.
+func StartServices(mgr services.ServiceManager) error {
+	p := tea.NewProgram(newServicesModel(mgr), tea.WithAltScreen())
+	_, err := p.Run()
+	return err
+}
+
+func (m *servicesModel) Init() tea.Cmd {
+	return tea.Batch(m.loadServices(), servicesTick())
+}
+
+func servicesTick() tea.Cmd {
+	return tea.Tick(servicesRefreshInterval, func(time.Time) tea.Msg { return servicesTickMsg{} })
+}
+
+func (m *servicesModel) loadServices() tea.Cmd {
+	mgr := m.mgr
+	return func() tea.Msg {
+		svcs, err := mgr.ListServices()
+		return servicesLoadedMsg{Services: svcs, Err: err}
+	}
+}
+
+func (m *servicesModel) loadLogs() tea.Cmd {
+	svc, ok := m.selected()
+	if !ok {
+		return nil
+	}
+	mgr, name := m.mgr, svc.Name
+	return func() tea.Msg {
+		lines, err := mgr.Logs(name, serviceLogLines)
+		return serviceLogsMsg{Name: name, Lines: lines, Err: err}
+	}
+}
+
+func (m *servicesModel) runAction(action string) tea.Cmd {
+	svc, ok := m.selected()
+	if !ok || m.pending != "" {
+		return nil
+	}
+	m.pending = svc.Name
+	m.notice = fmt.Sprintf("%s %s...", strings.ToUpper(action[:1])+action[1:], svc.Name)
+
+	mgr, name := m.mgr, svc.Name
+	return func() tea.Msg {
+		var err error
+		switch action {
+		case "start":
+			err = mgr.Start(name)
+		case "stop":
+			err = mgr.Stop(name)
+		case "restart":
+			err = mgr.Restart(name)
+		}
+		if err == nil {
+			notifyServiceChanged()
+		}
+		return serviceActionMsg{Name: name, Action: action, Err: err}
+	}
+}
+
+// notifyServiceChanged drops the daemon's cached service list, if a daemon
+// is in use.
+func notifyServiceChanged() {
+	cfg := config.Get()
+	if !cfg.Daemon.Enabled {
+		return
+	}
+	_ = daemon.NewClient(cfg.GetDaemonSocketPath(), "").Invalidate(brew.EventServiceChanged)
+}
+
+func (m *servicesModel) selected() (services.Service, bool) {
+	if m.cursor < 0 || m.cursor >= len(m.services) {
+		return services.Service{}, false
+	}
+	return m.services[m.cursor], true
+}
+
+func (m *servicesModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "q", "esc":
+			return m, tea.Quit
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+				return m, m.loadLogs()
+			}
+		case "down", "j":
+			if m.cursor < len(m.services)-1 {
+				m.cursor++
+				return m, m.loadLogs()
+			}
+		case "s":
+			return m, m.runAction("start")
+		case "x":
+			return m, m.runAction("stop")
+		case "r":
+			return m, m.runAction("restart")
+		case "R":
+			return m, tea.Batch(m.loadServices(), m.loadLogs())
+		}
+
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+
+	case servicesTickMsg:
+		return m, tea.Batch(m.loadServices(), m.loadLogs(), servicesTick())
+
+	case servicesLoadedMsg:
+		m.loaded = true
+		m.err = msg.Err
+		if msg.Err != nil {
+			return m, nil
+		}
+		// Keep the cursor on the same service when the list changes.
+		prev, hadPrev := m.selected()
+		m.services = msg.Services
+		m.cursor = 0
+		if hadPrev {
+			for i, svc := range m.services {
+				if svc.Name == prev.Name {
+					m.cursor = i
+					break
+				}
+			}
+		}
+		if svc, ok := m.selected(); ok && svc.Name != m.logsFor {
+			return m, m.loadLogs()
+		}
+
+	case serviceLogsMsg:
+		if svc, ok := m.selected(); !ok || svc.Name != msg.Name {
+			return m, nil
+		}
+		m.logsFor = msg.Name
+		m.logs = msg.Lines
+		m.logErr = msg.Err
+
+	case serviceActionMsg:
+		m.pending = ""
+		if msg.Err != nil {
+			m.notice = serviceErrorStyle.Render(fmt.Sprintf("Error: %s %s: %v", msg.Action, msg.Name, msg.Err))
+		} else {
+			m.notice = serviceRunningStyle.Render(fmt.Sprintf("✅ %s %s", pastTense(msg.Action), msg.Name))
+		}
+		return m, tea.Batch(m.loadServices(), m.loadLogs())
+	}
+	return m, nil
+}
+
+func pastTense(action string) string {
+	switch action {
+	case "start":
+		return "Started"
+	case "stop":
+		return "Stopped"
+	case "restart":
+		return "Restarted"
+	}
+	return action
+}
+
+func (m *servicesModel) View() string {
+	if !m.loaded {
+		return "\n   Loading services..."
+	}
+	if m.err != nil {
+		return docStyle.Render(serviceErrorStyle.Render(fmt.Sprintf("Error listing services: %v", m.err)) + "\n\n" + helpStyle.Render("q quit • R retry"))
+	}
+
+	h, v := docStyle.GetFrameSize()
+	paneHeight := m.height - v - 4
+	if paneHeight < 5 {
+		paneHeight = 5
+	}
+
+	list := m.renderServiceList(paneHeight)
+	logWidth := m.width - h - serviceListWidth - 4
+	if logWidth < 20 {
+		logWidth = 20
+	}
+	logs := m.renderServiceLogs(logWidth, paneHeight)
+
+	view := titleStyle.Render("FastBrew Services") + "\n" +
+		lipgloss.JoinHorizontal(lipgloss.Top, list, logs)
+	if m.notice != "" {
+		view += "\n" + noticeStyle.Render(m.notice)
+	}
+	view += "\n" + helpStyle.UnsetPaddingBottom().Render("↑/↓ select • s start • x stop • r restart • R refresh • q quit")
+	return docStyle.Render(view)
+}
+
+func (m *servicesModel) renderServiceList(height int) string {
+	var lines []string
+	if len(m.services) == 0 {
+		lines = append(lines, serviceMutedStyle.Render("No services found."))
+	}
+	for i, svc := range m.services {
+		status := string(svc.Status)
+		switch svc.Status {
+		case services.StatusRunning:
+			status = serviceRunningStyle.Render(status)
+		case services.StatusError:
+			status = serviceErrorStyle.Render(status)
+		default:
+			status = serviceMutedStyle.Render(status)
+		}
+		pid := "-"
+		if svc.Pid > 0 {
+			pid = fmt.Sprintf("%d", svc.Pid)
+		}
+		name := truncate(svc.Name, 22)
+		line := fmt.Sprintf("%-22s %s %s", name, padRight(status, string(svc.Status), 8), pid)
+		if i == m.cursor {
+			line = selectedItemStyle.UnsetPaddingLeft().Render("> ") + line
+		} else {
+			line = "  " + line
+		}
+		lines = append(lines, line)
+	}
+	return servicePaneStyle.Width(serviceListWidth).Height(height).Render(strings.Join(lines, "\n"))
+}
+
+func (m *servicesModel) renderServiceLogs(width, height int) string {
+	svc, ok := m.selected()
+	var lines []string
+	switch {
+	case !ok:
+		lines = append(lines, serviceMutedStyle.Render("Select a service to see its output."))
+	case m.logErr != nil && m.logsFor == svc.Name:
+		lines = append(lines, serviceMutedStyle.Render(m.logErr.Error()))
+	case m.logsFor != svc.Name:
+		lines = append(lines, serviceMutedStyle.Render("Loading logs..."))
+	case len(m.logs) == 0:
+		lines = append(lines, serviceMutedStyle.Render("No output yet."))
+	default:
+		// Show the newest lines that fit, leaving room for the header.
+		logs := m.logs
+		if room := height - 2; room > 0 && len(logs) > room {
+			logs = logs[len(logs)-room:]
+		}
+		for _, line := range logs {
+			lines = append(lines, truncate(line, width-2))
+		}
+	}
+
+	header := "Logs"
+	if ok {
+		header = "Logs: " + svc.Name
+	}
+	body := titleStyle.UnsetMarginLeft().Render(header) + "\n" + strings.Join(lines, "\n")
+	return servicePaneStyle.Width(width).Height(height).Render(body)
+}
+
+func truncate(s string, width int) string {
+	if width <= 1 || lipgloss.Width(s) <= width {
+		return s
+	}
+	r := []rune(s)
+	if len(r) > width-1 {
+		r = r[:width-1]
+	}
+	return string(r) + "…"
+}
+
+// padRight pads a styled string to width using the length of its plain
+// text, since escape sequences would throw off fmt's padding.
+func padRight(styled, plain string, width int) string {
+	if n := width - len(plain); n > 0 {
+		return styled + strings.Repeat(" ", n)
+	}
+	return styled
+}
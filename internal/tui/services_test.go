@@ -0,0 +1,69 @@
+package tui
+
+import (
+	"fastbrew/internal/services"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// drive feeds msg to the model and then every message its commands
+// produce, skipping the refresh ticker.
+func drive(m *servicesModel, msg tea.Msg) {
+	queue := []tea.Msg{msg}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		_, cmd := m.Update(next)
+		queue = append(queue, runCmd(cmd)...)
+	}
+}
+
+func runCmd(cmd tea.Cmd) []tea.Msg {
+	if cmd == nil {
+		return nil
+	}
+	switch msg := cmd().(type) {
+	case tea.BatchMsg:
+		var out []tea.Msg
+		for _, c := range msg {
+			out = append(out, runCmd(c)...)
+		}
+		return out
+	case servicesTickMsg:
+		return nil
+	default:
+		return []tea.Msg{msg}
+	}
+}
+
+func TestServicesModelShowsLogsAndStartsSelected(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	mgr := services.NewFakeManager(
+		services.Service{Name: "homebrew.mxcl.postgresql", Status: services.StatusRunning, Pid: 42},
+		services.Service{Name: "homebrew.mxcl.redis", Status: services.StatusStopped},
+	)
+	mgr.LogLines = map[string][]string{
+		"homebrew.mxcl.postgresql": {"database system is ready"},
+		"homebrew.mxcl.redis":      {"Ready to accept connections"},
+	}
+	m := newServicesModel(mgr)
+
+	drive(m, servicesLoadedMsg{Services: mgr.Services})
+	if m.logsFor != "homebrew.mxcl.postgresql" || len(m.logs) != 1 {
+		t.Fatalf("expected postgresql logs, got %q %v", m.logsFor, m.logs)
+	}
+
+	drive(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("j")})
+	if m.logsFor != "homebrew.mxcl.redis" || m.logs[0] != "Ready to accept connections" {
+		t.Fatalf("expected redis logs after moving down, got %q %v", m.logsFor, m.logs)
+	}
+
+	drive(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	if calls := mgr.Called(); len(calls) != 1 || calls[0] != "start homebrew.mxcl.redis" {
+		t.Fatalf("expected redis to be started, got %v", calls)
+	}
+	if m.pending != "" || m.cursor != 1 {
+		t.Fatalf("expected the action to finish with the cursor kept, pending=%q cursor=%d", m.pending, m.cursor)
+	}
+}
@@ -1,21 +1,22 @@
 package tui
 
 import (
+	"context"
 	"errors"
 	"fastbrew/internal/brew"
 	"fastbrew/internal/config"
 	"fastbrew/internal/daemon"
+	"fastbrew/internal/lockfile"
 	"fastbrew/internal/progress"
 	"fmt"
 	"io"
 	"math"
-	"os"
-	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	tprogress "github.com/charmbracelet/bubbles/progress"
 	"github.com/charmbracelet/bubbles/spinner"
@@ -54,7 +55,9 @@ func (i item) Title() string {
 func (i item) Description() string { return i.desc }
 func (i item) FilterValue() string { return i.title }
 
-type itemDelegate struct{}
+type itemDelegate struct {
+	m *model
+}
 
 func (d itemDelegate) Height() int                             { return 1 }
 func (d itemDelegate) Spacing() int                            { return 0 }
@@ -77,6 +80,14 @@ func (d itemDelegate) Render(w io.Writer, m list.Model, index int, listItem list
 	} else {
 		str = "   " + str
 	}
+	if d.m.pinned[i.title] {
+		str += " 📌"
+	}
+
+	mark := "  "
+	if d.m.marked[itemKey(i)] {
+		mark = markedStyle.Render("● ")
+	}
 
 	fn := itemStyle.Render
 	if index == m.Index() {
@@ -85,7 +96,7 @@ func (d itemDelegate) Render(w io.Writer, m list.Model, index int, listItem list
 		}
 	}
 
-	fmt.Fprint(w, fn(str))
+	fmt.Fprint(w, mark+fn(str))
 }
 
 type model struct {
@@ -110,6 +121,19 @@ type model struct {
 	jobLogs     []string
 	jobPackages map[string]*packageProgress
 	progressBar tprogress.Model
+
+	marked      map[string]bool
+	queue       []item
+	queueCursor int
+	showQueue   bool
+	menu        *actionMenu
+	info        string
+	notice      string
+	pinned      map[string]bool
+
+	// ctx is cancelled when the TUI quits, stopping a job it runs itself.
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 type installedMsg map[string]bool
@@ -124,6 +148,12 @@ type jobFinishedMsg struct {
 	Err       error
 	Installed map[string]bool
 }
+
+// jobLockHeldMsg reports that a local job is waiting for another fastbrew
+// process to release the lock.
+type jobLockHeldMsg struct {
+	Held *lockfile.HeldError
+}
 type jobWorkerStartedMsg struct{}
 type jobWorkerClosedMsg struct{}
 
@@ -139,7 +169,12 @@ type packageProgress struct {
 }
 
 func InitialModel() *model {
-	client, _ := brew.NewClient()
+	cfg := config.Get()
+	client, _ := brew.NewClientAt(cfg.GetPrefix())
+	if client != nil {
+		client.CacheDir = cfg.GetCacheDir()
+		client.RetryAttempts = cfg.GetRetryAttempts()
+	}
 
 	s := spinner.New()
 	s.Spinner = spinner.Dot
@@ -147,20 +182,34 @@ func InitialModel() *model {
 
 	p := tprogress.New(tprogress.WithDefaultGradient())
 
-	delegate := itemDelegate{}
-	l := list.New([]list.Item{}, delegate, 0, 0)
-	l.Title = "FastBrew Packages"
-	l.Styles.Title = titleStyle
-	l.SetShowStatusBar(false)
+	pinned, _ := brew.LoadPinned()
+	if pinned == nil {
+		pinned = make(map[string]bool)
+	}
 
-	return &model{
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &model{
+		ctx:         ctx,
+		cancel:      cancel,
 		client:      client,
-		list:        l,
 		installed:   make(map[string]bool),
 		jobPackages: make(map[string]*packageProgress),
 		spinner:     s,
 		progressBar: p,
+		marked:      make(map[string]bool),
+		pinned:      pinned,
+	}
+
+	l := list.New([]list.Item{}, itemDelegate{m: m}, 0, 0)
+	l.Title = "FastBrew Packages"
+	l.Styles.Title = titleStyle
+	l.SetShowStatusBar(false)
+	l.AdditionalShortHelpKeys = func() []key.Binding {
+		return []key.Binding{markKey, menuKey, queueKey}
 	}
+	m.list = l
+
+	return m
 }
 
 func (m *model) Init() tea.Cmd {
@@ -203,32 +252,43 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case tea.KeyMsg:
 		if msg.String() == "ctrl+c" {
+			m.cancel()
 			return m, tea.Quit
 		}
 
-		if msg.String() == "tab" {
-			if m.list.FilterState() != list.Filtering {
-				// We can toggle a view state here if we add a filter toggle or just let Bubbletea handle built in filtering (`/`). Fastbrew originally didn't have tab. Let's just leave it empty or add specific filtering logic later if needed.
-			}
+		if m.menu != nil {
+			return m, m.handleMenuKey(msg)
 		}
-
-		if msg.String() == "enter" || msg.String() == "i" {
-			if m.jobActive {
-				return m, nil
-			}
-
-			if i, ok := m.list.SelectedItem().(item); ok {
-				return m, m.startJob(i.title, daemon.JobOperationInstall)
-			}
+		if m.showQueue {
+			return m, m.handleQueueKey(msg)
+		}
+		if m.info != "" && (msg.String() == "esc" || msg.String() == "enter") {
+			m.info = ""
+			m.updateListSize()
+			return m, nil
+		}
+		// While typing a filter every key belongs to the filter input.
+		if m.list.FilterState() == list.Filtering {
+			break
 		}
 
-		if msg.String() == "u" || msg.String() == "x" {
-			if m.jobActive {
-				return m, nil
-			}
-			if i, ok := m.list.SelectedItem().(item); ok && i.installed {
-				return m, m.startJob(i.title, daemon.JobOperationUninstall)
-			}
+		switch msg.String() {
+		case " ":
+			m.toggleMark()
+			return m, nil
+		case "tab":
+			m.showQueue = true
+			m.queueCursor = 0
+			return m, nil
+		case "enter", "a":
+			m.openMenu(m.targets())
+			return m, nil
+		case "i":
+			return m, m.runAction(actionInstall, m.targets())
+		case "u", "x":
+			return m, m.runAction(actionUninstall, m.targets())
+		case "p":
+			return m, m.runAction(actionPin, m.targets())
 		}
 
 	case tea.WindowSizeMsg:
@@ -276,6 +336,14 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case jobLockHeldMsg:
+		m.notice = fmt.Sprintf("⏳ %v, waiting for it to finish...", msg.Held)
+		m.appendJobLog(m.notice)
+		if m.jobActive {
+			return m, waitForJobMsg(m.jobEvents)
+		}
+		return m, nil
+
 	case jobFinishedMsg:
 		m.jobActive = false
 		if msg.Err != nil {
@@ -306,12 +374,12 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
-func (m *model) startJob(target string, operation string) tea.Cmd {
+func (m *model) startJob(pkgs []string, operation string) tea.Cmd {
 	events := make(chan tea.Msg, 1024)
 	m.jobActive = true
 	m.jobVisible = true
 	m.jobSource = "local"
-	m.jobTarget = target
+	m.jobTarget = strings.Join(pkgs, ", ")
 	m.jobStatus = daemon.JobStatusQueued
 	m.jobError = ""
 	m.jobEvents = events
@@ -319,14 +387,14 @@ func (m *model) startJob(target string, operation string) tea.Cmd {
 	m.jobPackages = make(map[string]*packageProgress)
 	m.updateListSize()
 
-	runFunc := func() { runLocalInstall(events, m.client, target) }
+	runFunc := func() { runLocalInstall(m.ctx, events, m.client, pkgs) }
 	if operation == daemon.JobOperationUninstall {
-		runFunc = func() { runLocalUninstall(events, m.client, target) }
+		runFunc = func() { runLocalUninstall(m.ctx, events, m.client, pkgs) }
 	}
 
 	if daemonClient, daemonErr := daemonClientForTUI(); daemonErr == nil {
 		m.jobSource = "daemon"
-		runFunc = func() { runDaemonJob(events, daemonClient, m.client, target, operation) }
+		runFunc = func() { runDaemonJob(events, daemonClient, m.client, pkgs, operation) }
 	}
 
 	return tea.Batch(
@@ -370,16 +438,37 @@ func (m *model) View() string {
 		return fmt.Sprintf("\n\n   %s Loading FastBrew Index...", m.spinner.View())
 	}
 
+	if m.showQueue {
+		return docStyle.Render(m.renderQueue())
+	}
+
 	view := m.list.View()
-	if m.jobVisible {
-		view = view + "\n" + m.renderJobPanel()
+	if m.notice != "" {
+		view += "\n" + noticeStyle.Render(m.notice)
+	}
+	switch {
+	case m.menu != nil:
+		view += "\n" + m.renderMenu()
+	case m.info != "":
+		view += "\n" + m.renderInfo()
+	case m.jobVisible:
+		view += "\n" + m.renderJobPanel()
 	}
 	return docStyle.Render(view)
 }
 
 func Start() error {
-	p := tea.NewProgram(InitialModel(), tea.WithAltScreen())
+	m := InitialModel()
+	p := tea.NewProgram(m, tea.WithAltScreen())
 	_, err := p.Run()
+	// A job run in-process stops once cancelled; wait for it so it
+	// releases the lock and cleans up before the process exits. A daemon
+	// job carries on in the daemon.
+	m.cancel()
+	if m.jobSource == "local" && m.jobEvents != nil {
+		for range m.jobEvents {
+		}
+	}
 	return err
 }
 
@@ -390,7 +479,7 @@ func (m *model) updateListSize() {
 
 	h, v := docStyle.GetFrameSize()
 	panel := 0
-	if m.jobVisible {
+	if m.jobVisible || m.menu != nil || m.info != "" {
 		panel = jobPanelHeight
 	}
 
@@ -607,10 +696,10 @@ func sendBlocking(events chan<- tea.Msg, msg tea.Msg) {
 	events <- msg
 }
 
-func runDaemonJob(events chan<- tea.Msg, daemonClient *daemon.Client, brewClient *brew.Client, pkg string, operation string) {
+func runDaemonJob(events chan<- tea.Msg, daemonClient *daemon.Client, brewClient *brew.Client, pkgs []string, operation string) {
 	defer close(events)
 
-	jobID, err := daemonClient.SubmitJob(operation, []string{pkg}, daemon.JobSubmitOptions{})
+	jobID, err := daemonClient.SubmitJob(operation, pkgs, daemon.JobSubmitOptions{})
 	if err != nil {
 		sendBlocking(events, jobFinishedMsg{Err: err})
 		return
@@ -775,9 +864,17 @@ func mutationToJobEvent(event brew.MutationEvent) daemon.JobEvent {
 	}
 }
 
-func runLocalInstall(events chan<- tea.Msg, client *brew.Client, pkg string) {
+func runLocalInstall(ctx context.Context, events chan<- tea.Msg, client *brew.Client, pkgs []string) {
 	defer close(events)
 
+	unlock, err := lockLocalJob(ctx, events)
+	if err != nil {
+		sendBestEffort(events, jobStatusMsg{Status: daemon.JobStatusFailed, Error: err.Error()})
+		sendBlocking(events, jobFinishedMsg{Err: err})
+		return
+	}
+	defer unlock()
+
 	client.EnableProgress()
 	pm := client.ProgressManager
 	subID := fmt.Sprintf("tui-progress-%d", time.Now().UnixNano())
@@ -811,11 +908,15 @@ func runLocalInstall(events chan<- tea.Msg, client *brew.Client, pkg string) {
 	})
 
 	sendBestEffort(events, jobStatusMsg{Status: daemon.JobStatusRunning})
-	err := client.InstallNative([]string{pkg})
+	err = client.InstallNative(ctx, pkgs)
 	client.SetMutationHook(nil)
 	pm.UnsubscribeFromEvents(subID)
 	close(stopProgress)
 	<-progressDone
+	if ctx.Err() != nil {
+		// Still holding the lock, so no other install is using them.
+		client.RemoveExtractionTempDirs()
+	}
 
 	if err != nil {
 		sendBestEffort(events, jobStatusMsg{Status: daemon.JobStatusFailed, Error: err.Error()})
@@ -831,35 +932,50 @@ func runLocalInstall(events chan<- tea.Msg, client *brew.Client, pkg string) {
 	})
 }
 
-func runLocalUninstall(events chan<- tea.Msg, client *brew.Client, pkg string) {
-	defer close(events)
-
-	sendBestEffort(events, jobStatusMsg{Status: daemon.JobStatusRunning})
+// lockLocalJob takes the lock the fastbrew commands and the daemon hold
+// while changing the Cellar, so a job run in-process does not race them.
+// While another process holds it the job waits, and the TUI says so, until
+// ctx is cancelled.
+func lockLocalJob(ctx context.Context, events chan<- tea.Msg) (func(), error) {
+	path := lockfile.DefaultPath()
+	lock, err := lockfile.TryAcquire(path)
+	var held *lockfile.HeldError
+	if errors.As(err, &held) {
+		sendBestEffort(events, jobLockHeldMsg{Held: held})
+		lock, err = lockfile.Acquire(ctx, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return func() { lock.Release() }, nil
+}
 
-	pkgPath := filepath.Join(client.Cellar, pkg)
+func runLocalUninstall(ctx context.Context, events chan<- tea.Msg, client *brew.Client, pkgs []string) {
+	defer close(events)
 
-	if _, err := os.Stat(pkgPath); os.IsNotExist(err) {
-		sendBestEffort(events, jobStatusMsg{Status: daemon.JobStatusFailed, Error: fmt.Sprintf("%s is not installed", pkg)})
+	unlock, err := lockLocalJob(ctx, events)
+	if err != nil {
+		sendBestEffort(events, jobStatusMsg{Status: daemon.JobStatusFailed, Error: err.Error()})
 		sendBlocking(events, jobFinishedMsg{Err: err})
 		return
 	}
+	defer unlock()
 
-	sendBestEffort(events, jobEventMsg{Event: daemon.JobEvent{Kind: daemon.JobEventKindPackage, Operation: daemon.JobOperationUninstall, Package: pkg, Phase: brew.MutationPhaseUninstall, Status: daemon.JobEventStatusRunning}})
-
-	client.Unlink(pkg)
-
-	optLink := filepath.Join(client.Prefix, "opt", pkg)
-	if info, err := os.Lstat(optLink); err == nil && info.Mode()&os.ModeSymlink != 0 {
-		os.Remove(optLink)
-	}
+	sendBestEffort(events, jobStatusMsg{Status: daemon.JobStatusRunning})
 
-	if err := os.RemoveAll(pkgPath); err != nil {
-		sendBestEffort(events, jobStatusMsg{Status: daemon.JobStatusFailed, Error: err.Error()})
-		sendBlocking(events, jobFinishedMsg{Err: err})
-		return
+	for _, pkg := range pkgs {
+		err := ctx.Err()
+		if err == nil {
+			err = uninstallLocal(events, client, pkg)
+		}
+		if err != nil {
+			sendBestEffort(events, jobStatusMsg{Status: daemon.JobStatusFailed, Error: err.Error()})
+			installed, _ := loadInstalledMap(client)
+			sendBlocking(events, jobFinishedMsg{Err: err, Installed: installed})
+			return
+		}
 	}
 
-	sendBestEffort(events, jobEventMsg{Event: daemon.JobEvent{Kind: daemon.JobEventKindPackage, Operation: daemon.JobOperationUninstall, Package: pkg, Phase: brew.MutationPhaseUninstall, Status: daemon.JobEventStatusSucceeded}})
 	sendBestEffort(events, jobStatusMsg{Status: daemon.JobStatusSucceeded})
 
 	installed, loadErr := loadInstalledMap(client)
@@ -868,3 +984,14 @@ func runLocalUninstall(events chan<- tea.Msg, client *brew.Client, pkg string) {
 		Installed: installed,
 	})
 }
+
+func uninstallLocal(events chan<- tea.Msg, client *brew.Client, pkg string) error {
+	sendBestEffort(events, jobEventMsg{Event: daemon.JobEvent{Kind: daemon.JobEventKindPackage, Operation: daemon.JobOperationUninstall, Package: pkg, Phase: brew.MutationPhaseUninstall, Status: daemon.JobEventStatusRunning}})
+
+	if _, err := client.UninstallFormula(pkg, brew.UninstallOptions{}); err != nil {
+		return err
+	}
+
+	sendBestEffort(events, jobEventMsg{Event: daemon.JobEvent{Kind: daemon.JobEventKindPackage, Operation: daemon.JobOperationUninstall, Package: pkg, Phase: brew.MutationPhaseUninstall, Status: daemon.JobEventStatusSucceeded}})
+	return nil
+}
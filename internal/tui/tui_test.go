@@ -1,10 +1,17 @@
 package tui
 
 import (
+	"context"
 	"fastbrew/internal/brew"
 	"fastbrew/internal/daemon"
+	"fastbrew/internal/lockfile"
 	"fastbrew/internal/progress"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
 )
 
 func TestApplyJobEventUpdatesPackageProgress(t *testing.T) {
@@ -102,3 +109,224 @@ func TestProgressAndMutationEventMappings(t *testing.T) {
 		t.Fatalf("expected level info, got %q", mutationEvent.Level)
 	}
 }
+
+func newActionTestModel(t *testing.T) *model {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+
+	m := InitialModel()
+	m.index = &brew.Index{
+		Formulae: []brew.Formula{
+			{Name: "jq", Desc: "Lightweight JSON processor", Homepage: "https://jqlang.github.io/jq/", Versions: brew.FormulaVersions{Stable: "1.7.1"}, Dependencies: []string{"oniguruma"}},
+			{Name: "wget", Desc: "Internet file retriever"},
+		},
+		Casks: []brew.Cask{{Token: "firefox", Desc: "Web browser", Version: "130.0"}},
+	}
+	m.loaded = true
+	m.updateListItems()
+	return m
+}
+
+func pressKey(m *model, k string) *model {
+	msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+	switch k {
+	case "enter":
+		msg = tea.KeyMsg{Type: tea.KeyEnter}
+	case "esc":
+		msg = tea.KeyMsg{Type: tea.KeyEsc}
+	case "tab":
+		msg = tea.KeyMsg{Type: tea.KeyTab}
+	case " ":
+		msg = tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}}
+	}
+	updated, _ := m.Update(msg)
+	return updated.(*model)
+}
+
+func TestMarkingBuildsQueue(t *testing.T) {
+	m := newActionTestModel(t)
+
+	m = pressKey(m, " ")
+	m = pressKey(m, " ")
+	if got := itemNames(m.targets()); len(got) != 2 || got[0] != "jq" || got[1] != "wget" {
+		t.Fatalf("targets = %v, want [jq wget]", got)
+	}
+
+	m = pressKey(m, "tab")
+	if !m.showQueue {
+		t.Fatal("expected tab to open the queue view")
+	}
+	m = pressKey(m, "x")
+	if got := itemNames(m.queue); len(got) != 1 || got[0] != "wget" {
+		t.Fatalf("queue after removal = %v, want [wget]", got)
+	}
+	if m.marked[itemKey(item{title: "jq"})] {
+		t.Fatal("expected jq to be unmarked after removal from the queue")
+	}
+
+	m = pressKey(m, "c")
+	m = pressKey(m, "esc")
+	if m.showQueue || len(m.queue) != 0 {
+		t.Fatalf("expected an empty queue and the list view, got queue %v", itemNames(m.queue))
+	}
+}
+
+func TestActionMenuShowsInfo(t *testing.T) {
+	m := newActionTestModel(t)
+
+	m = pressKey(m, "enter")
+	if m.menu == nil {
+		t.Fatal("expected enter to open the action menu")
+	}
+	for m.menu.actions[m.menu.cursor] != actionInfo {
+		m = pressKey(m, "j")
+	}
+	m = pressKey(m, "enter")
+
+	if m.menu != nil {
+		t.Fatal("expected the menu to close after running an action")
+	}
+	for _, want := range []string{"jq 1.7.1", "Lightweight JSON processor", "Depends on: oniguruma", "Not installed"} {
+		if !strings.Contains(m.info, want) {
+			t.Errorf("info %q missing %q", m.info, want)
+		}
+	}
+
+	m = pressKey(m, "esc")
+	if m.info != "" {
+		t.Fatal("expected esc to close the info panel")
+	}
+}
+
+func TestPinActionTogglesPins(t *testing.T) {
+	m := newActionTestModel(t)
+
+	m = pressKey(m, "p")
+	pinned, err := brew.LoadPinned()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pinned["jq"] || !m.pinned["jq"] {
+		t.Fatalf("expected jq to be pinned, got %v", pinned)
+	}
+
+	m = pressKey(m, "p")
+	pinned, _ = brew.LoadPinned()
+	if pinned["jq"] || m.pinned["jq"] {
+		t.Fatalf("expected jq to be unpinned, got %v", pinned)
+	}
+}
+
+func TestUninstallSkipsPackagesThatAreNotInstalled(t *testing.T) {
+	m := newActionTestModel(t)
+
+	m = pressKey(m, "u")
+	if m.jobActive {
+		t.Fatal("expected no job for a package that is not installed")
+	}
+	if m.notice == "" {
+		t.Fatal("expected a notice explaining why nothing ran")
+	}
+}
+
+func TestRunLocalUninstallForgetsRecords(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	prefix := t.TempDir()
+	client := &brew.Client{Prefix: prefix, Cellar: filepath.Join(prefix, "Cellar")}
+	if err := os.MkdirAll(filepath.Join(client.Cellar, "jq", "1.7.1", "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := brew.RecordInstallReasons(map[string]brew.InstallReason{"jq": brew.ReasonExplicit}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(brew.OutdatedRecordPath()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(brew.OutdatedRecordPath(), []byte(`{"checked_at": "2026-01-01T00:00:00Z", "packages": ["jq", "wget"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	events := make(chan tea.Msg, 64)
+	runLocalUninstall(context.Background(), events, client, []string{"jq"})
+	for msg := range events {
+		if finished, ok := msg.(jobFinishedMsg); ok && finished.Err != nil {
+			t.Fatalf("uninstall failed: %v", finished.Err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(client.Cellar, "jq")); !os.IsNotExist(err) {
+		t.Errorf("expected jq to be removed from the Cellar, stat err = %v", err)
+	}
+	if reasons, _ := brew.LoadInstallReasons(); reasons["jq"] != "" {
+		t.Errorf("expected the install reason to be forgotten, got %v", reasons)
+	}
+	if record, _ := brew.LoadOutdatedRecord(); record == nil || strings.Join(record.Packages, ",") != "wget" {
+		t.Errorf("expected only wget left outdated, got %+v", record)
+	}
+}
+
+func TestLocalJobWaitsForTheLock(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	lock, err := lockfile.TryAcquire(lockfile.DefaultPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix := t.TempDir()
+	client := &brew.Client{Prefix: prefix, Cellar: filepath.Join(prefix, "Cellar")}
+
+	events := make(chan tea.Msg, 64)
+	go runLocalUninstall(context.Background(), events, client, []string{"jq"})
+	held, ok := (<-events).(jobLockHeldMsg)
+	if !ok || held.Held == nil {
+		t.Fatalf("expected the job to report the held lock first, got %#v", held)
+	}
+
+	m := InitialModel()
+	m.Update(held)
+	if !strings.Contains(m.notice, "another fastbrew process is running") {
+		t.Errorf("expected a notice about the other process, got %q", m.notice)
+	}
+
+	lock.Release()
+	var finished *jobFinishedMsg
+	for msg := range events {
+		if f, ok := msg.(jobFinishedMsg); ok {
+			finished = &f
+		}
+	}
+	if finished == nil || finished.Err == nil {
+		t.Errorf("expected the job to run once the lock was free and fail on the missing jq, got %+v", finished)
+	}
+}
+
+func TestLocalJobStopsWaitingWhenCancelled(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	lock, err := lockfile.TryAcquire(lockfile.DefaultPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Release()
+	prefix := t.TempDir()
+	client := &brew.Client{Prefix: prefix, Cellar: filepath.Join(prefix, "Cellar")}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan tea.Msg, 64)
+	go runLocalInstall(ctx, events, client, []string{"jq"})
+	if _, ok := (<-events).(jobLockHeldMsg); !ok {
+		t.Fatal("expected the job to wait for the lock")
+	}
+	cancel()
+
+	var finished *jobFinishedMsg
+	for msg := range events {
+		if f, ok := msg.(jobFinishedMsg); ok {
+			finished = &f
+		}
+	}
+	if finished == nil || finished.Err == nil {
+		t.Errorf("expected the cancelled job to fail without the lock, got %+v", finished)
+	}
+}